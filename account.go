@@ -0,0 +1,46 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import "time"
+
+// AccountInfo is the user's account and upload quota.
+type AccountInfo struct {
+	// Username is the user's login name.
+	Username string
+	// Name is the user's full name.
+	Name string
+	// AccountType is the service level, for example Basic or Premium.
+	AccountType string
+	// UploadLimit is the number of bytes that can be uploaded in the
+	// current upload cycle.
+	UploadLimit int64
+	// Uploaded is the number of bytes uploaded in the current cycle.
+	Uploaded int64
+	// CycleEnd is when the upload cycle ends and the quota is reset.
+	CycleEnd time.Time
+}
+
+// Remaining returns the number of bytes that can still be uploaded in
+// the current cycle.
+func (a *AccountInfo) Remaining() int64 {
+	if a.Uploaded >= a.UploadLimit {
+		return 0
+	}
+	return a.UploadLimit - a.Uploaded
+}
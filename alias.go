@@ -0,0 +1,108 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrUnterminatedQuote is returned if an alias has a quote that isn't closed.
+	ErrUnterminatedQuote = errors.New("unterminated quote in alias")
+	// ErrInvalidAliasName is returned if the alias name is empty, has white
+	// space or starts with a dash.
+	ErrInvalidAliasName = errors.New("an alias name can't be empty, have spaces or start with a dash")
+)
+
+// ValidateAliasName returns ErrInvalidAliasName if the name can't be used
+// as an alias.
+func ValidateAliasName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") || strings.IndexFunc(name, unicode.IsSpace) != -1 {
+		return ErrInvalidAliasName
+	}
+	return nil
+}
+
+// AliasNames returns the names of the user's aliases, sorted.
+func (s *Settings) AliasNames() []string {
+	names := make([]string, 0, len(s.Aliases))
+	for name := range s.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandAlias replaces the first argument with the alias' command if it
+// matches an alias. The rest of the arguments are appended to the expanded
+// command. Aliases are only expanded once so an alias can't refer to
+// another alias.
+func ExpandAlias(aliases map[string]string, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+	cmd, ok := aliases[args[0]]
+	if !ok {
+		return args, nil
+	}
+	expanded, err := splitArgs(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return append(expanded, args[1:]...), nil
+}
+
+// splitArgs splits the string into arguments. Arguments are separated by
+// white space. Single or double quotes can be used to include white space
+// in an argument.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
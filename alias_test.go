@@ -0,0 +1,67 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandAlias(t *testing.T) {
+	assert := assert.New(t)
+	aliases := map[string]string{
+		"ls":   "note list --count 20",
+		"work": `note list --notebook "Work Notes"`,
+		"bad":  `note "title`,
+	}
+
+	t.Run("No alias", func(t *testing.T) {
+		args, err := ExpandAlias(aliases, []string{"note", "list"})
+		assert.NoError(err)
+		assert.Equal([]string{"note", "list"}, args)
+	})
+
+	t.Run("Expand with extra arguments", func(t *testing.T) {
+		args, err := ExpandAlias(aliases, []string{"ls", "--search", "term"})
+		assert.NoError(err)
+		assert.Equal([]string{"note", "list", "--count", "20", "--search", "term"}, args)
+	})
+
+	t.Run("Quoted arguments", func(t *testing.T) {
+		args, err := ExpandAlias(aliases, []string{"work"})
+		assert.NoError(err)
+		assert.Equal([]string{"note", "list", "--notebook", "Work Notes"}, args)
+	})
+
+	t.Run("Unterminated quote", func(t *testing.T) {
+		_, err := ExpandAlias(aliases, []string{"bad"})
+		assert.Equal(ErrUnterminatedQuote, err)
+	})
+}
+
+func TestValidateAliasName(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(ValidateAliasName("todo"))
+	assert.NoError(ValidateAliasName("ls-work"))
+	for _, name := range []string{"", "-t", "my todo", "a\tb"} {
+		assert.Equal(ErrInvalidAliasName, ValidateAliasName(name), name)
+	}
+	s := &Settings{Aliases: map[string]string{"todo": "note find tag:todo", "ls": "note list"}}
+	assert.Equal([]string{"ls", "todo"}, s.AliasNames())
+}
@@ -0,0 +1,264 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"regexp"
+	"strings"
+)
+
+// asciidocFormatter shows and edits notes in AsciiDoc. Headings, lists,
+// checklists, tables, source and quote blocks, links, images and the
+// emphasis markup are converted.
+type asciidocFormatter struct{}
+
+func (asciidocFormatter) Name() string      { return FormatAsciiDoc }
+func (asciidocFormatter) Extension() string { return ".adoc" }
+
+var (
+	adocHeading   = regexp.MustCompile(`^(={1,6})\s+(.*)$`)
+	adocBullet    = regexp.MustCompile(`^(\*{1,5}|-)\s+(.*)$`)
+	adocNumbered  = regexp.MustCompile(`^(\.{1,5})\s+(.*)$`)
+	adocSource    = regexp.MustCompile(`^\[source(?:,\s*([^\],]+))?[^\]]*\]\s*$`)
+	adocImage     = regexp.MustCompile(`image::?([^\s\[]+)\[([^\]]*)\]`)
+	adocLinkMacro = regexp.MustCompile(`link:([^\s\[]+)\[([^\]]*)\]`)
+	adocURL       = regexp.MustCompile(`((?:https?|mailto|ftp):[^\s\[]+)\[([^\]]*)\]`)
+	adocMono      = regexp.MustCompile("`([^`]+)`")
+	adocStrike    = regexp.MustCompile(`\[\.line-through\]#([^#]+)#`)
+	adocBold      = regexp.MustCompile(`\*\*([^*]+?)\*\*`)
+	adocItalic    = regexp.MustCompile(`__([^_]+?)__`)
+)
+
+var mdToAdocProtected = []inlineRule{
+	{mdCodeSpan, func(m []string) string { return "`" + strings.Trim(m[0], "` ") + "`" }},
+	{mdImage, func(m []string) string { return "image:" + m[2] + "[" + m[1] + "]" }},
+	{mdLink, func(m []string) string { return adocLink(m[2], m[1]) }},
+	{mdAutoLink, func(m []string) string { return m[1] }},
+}
+
+var mdToAdocRules = []inlineRule{
+	{mdBold, func(m []string) string { return boldMarker + firstNonEmpty(m[1], m[2]) + boldMarker }},
+	{mdStrike, func(m []string) string { return "[.line-through]#" + m[1] + "#" }},
+	{mdItalic, func(m []string) string { return "_" + m[1] + "_" }},
+}
+
+var adocToMDProtected = []inlineRule{
+	{adocMono, func(m []string) string { return m[0] }},
+	{adocImage, func(m []string) string { return "![" + m[2] + "](" + m[1] + ")" }},
+	{adocLinkMacro, func(m []string) string { return "[" + firstNonEmpty(m[2], m[1]) + "](" + m[1] + ")" }},
+	{adocURL, func(m []string) string {
+		if m[2] == "" {
+			return "<" + m[1] + ">"
+		}
+		return "[" + m[2] + "](" + m[1] + ")"
+	}},
+}
+
+var adocToMDRules = []inlineRule{
+	{adocStrike, func(m []string) string { return "~~" + m[1] + "~~" }},
+	{adocBold, func(m []string) string { return boldMarker + boldMarker + m[1] + boldMarker + boldMarker }},
+	markupRule("*", func(s string) string { return boldMarker + boldMarker + s + boldMarker + boldMarker }),
+	{adocItalic, func(m []string) string { return "_" + m[1] + "_" }},
+}
+
+// adocLink returns the AsciiDoc link to the URL. URLs with a scheme are
+// links without the link macro.
+func adocLink(url, text string) string {
+	if adocURL.MatchString(url + "[]") {
+		return url + "[" + text + "]"
+	}
+	return "link:" + url + "[" + text + "]"
+}
+
+// FromMarkdown converts the Markdown to AsciiDoc.
+func (asciidocFormatter) FromMarkdown(md string) string {
+	var out []string
+	var fence string
+	var table [][]string
+	quote := false
+	inline := func(s string) string { return convertInline(s, mdToAdocProtected, mdToAdocRules) }
+	flushTable := func() {
+		out = append(out, "|===")
+		for i, row := range table {
+			out = append(out, "| "+strings.Join(row, " | "))
+			if i == 0 && len(table) > 1 {
+				out = append(out, "")
+			}
+		}
+		out = append(out, "|===")
+		table = nil
+	}
+	for _, line := range strings.Split(md, "\n") {
+		if fence != "" {
+			if closingFence(line, fence) {
+				out = append(out, "----")
+				fence = ""
+			} else {
+				out = append(out, line)
+			}
+			continue
+		}
+		if table != nil && !mdTableRow.MatchString(line) {
+			flushTable()
+		}
+		if m := mdQuote.FindStringSubmatch(line); m != nil {
+			if !quote {
+				out = append(out, "____")
+				quote = true
+			}
+			out = append(out, inline(m[1]))
+			continue
+		}
+		if quote {
+			out = append(out, "____")
+			quote = false
+		}
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			if m[2] != "" {
+				out = append(out, "[source,"+m[2]+"]")
+			}
+			out = append(out, "----")
+			continue
+		}
+		if mdTableRow.MatchString(line) {
+			if !mdTableSep.MatchString(line) {
+				cells := splitTableRow(line)
+				for i := range cells {
+					cells[i] = inline(cells[i])
+				}
+				table = append(table, cells)
+			}
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			out = append(out, strings.Repeat("=", len(m[1]))+" "+inline(m[2]))
+			continue
+		}
+		if mdRule.MatchString(line) {
+			out = append(out, "'''")
+			continue
+		}
+		if m := mdBullet.FindStringSubmatch(line); m != nil {
+			out = append(out, strings.Repeat("*", adocLevel(m[1]))+" "+inline(m[2]))
+			continue
+		}
+		if m := mdNumbered.FindStringSubmatch(line); m != nil {
+			out = append(out, strings.Repeat(".", adocLevel(m[1]))+" "+inline(m[3]))
+			continue
+		}
+		out = append(out, inline(line))
+	}
+	if table != nil {
+		flushTable()
+	}
+	if quote {
+		out = append(out, "____")
+	}
+	if fence != "" {
+		out = append(out, "----")
+	}
+	return strings.Join(out, "\n")
+}
+
+// adocLevel returns the AsciiDoc list level, at most 5, of a Markdown list
+// item with the indentation.
+func adocLevel(indent string) int {
+	if level := listLevel(indent); level < 5 {
+		return level
+	}
+	return 5
+}
+
+// ToMarkdown converts the AsciiDoc text to Markdown.
+func (asciidocFormatter) ToMarkdown(text string) string {
+	var out []string
+	var delim, lang string
+	var table [][]string
+	inTable, quote := false, false
+	inline := func(s string) string { return convertInline(s, adocToMDProtected, adocToMDRules) }
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if delim != "" {
+			if trimmed == delim {
+				out = append(out, "```")
+				delim = ""
+			} else {
+				out = append(out, line)
+			}
+			continue
+		}
+		if inTable {
+			if trimmed == "|===" {
+				out = append(out, markdownTable(table)...)
+				table, inTable = nil, false
+			} else if strings.HasPrefix(trimmed, "|") {
+				cells := splitTableRow(" " + trimmed[1:])
+				for i := range cells {
+					cells[i] = inline(cells[i])
+				}
+				table = append(table, cells)
+			}
+			continue
+		}
+		prefix := ""
+		if quote {
+			prefix = "> "
+		}
+		switch {
+		case trimmed == "|===":
+			inTable = true
+			continue
+		case trimmed == "____":
+			quote = !quote
+			continue
+		case trimmed == "----" || trimmed == "....":
+			delim = trimmed
+			out = append(out, prefix+"```"+lang)
+			lang = ""
+			continue
+		case trimmed == "'''":
+			out = append(out, prefix+"---")
+			continue
+		}
+		if m := adocSource.FindStringSubmatch(trimmed); m != nil {
+			lang = strings.TrimSpace(m[1])
+			continue
+		}
+		if m := adocHeading.FindStringSubmatch(line); m != nil {
+			out = append(out, strings.Repeat("#", len(m[1]))+" "+inline(m[2]))
+			continue
+		}
+		if m := adocBullet.FindStringSubmatch(line); m != nil {
+			out = append(out, prefix+strings.Repeat("  ", len(m[1])-1)+"- "+inline(m[2]))
+			continue
+		}
+		if m := adocNumbered.FindStringSubmatch(line); m != nil {
+			out = append(out, prefix+strings.Repeat("   ", len(m[1])-1)+"1. "+inline(m[2]))
+			continue
+		}
+		out = append(out, strings.TrimRight(prefix+inline(line), " "))
+	}
+	if inTable {
+		out = append(out, markdownTable(table)...)
+	}
+	if delim != "" {
+		out = append(out, "```")
+	}
+	return strings.Join(out, "\n")
+}
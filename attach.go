@@ -0,0 +1,167 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrAttachmentNotFound is returned if the note has no attachment with the
+// given index or hash.
+var ErrAttachmentNotFound = errors.New("no attachment found")
+
+var (
+	mediaHashPattern = regexp.MustCompile(`hash="([^"]*)"`)
+	mediaTypePattern = regexp.MustCompile(`type="([^"]*)"`)
+)
+
+// GetAttachments returns the attachments of the note, including the data.
+func GetAttachments(db Storager, ns NotestoreClient, title string) (*Note, []*Resource, error) {
+	n, err := GetNoteExact(db, ns, title, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	resources, err := getCachedNoteResources(db, ns, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return n, resources, nil
+}
+
+// RemoveAttachment removes the attachment from the note. The attachment is
+// given by its position in the note, starting at 1, or by its hash. The
+// references to the attachment are removed from the note content.
+func RemoveAttachment(db Storager, ns NotestoreClient, title, attachment string) (*Resource, error) {
+	n, resources, i, err := getAttachment(db, ns, title, attachment)
+	if err != nil {
+		return nil, err
+	}
+	prev := *n
+	removed := resources[i]
+	n.Resources = append(resources[:i:i], resources[i+1:]...)
+	n.Body = replaceMedia(n.Body, removed.Hash, func(string) string { return "" })
+	return removed, updateNoteContent(db, ns, &prev, n, RawNote)
+}
+
+// ReplaceAttachment replaces the attachment in the note with the file's data.
+// The attachment is given by its position in the note, starting at 1, or by
+// its hash. The references to the attachment in the note content are updated
+// to the new attachment.
+func ReplaceAttachment(db Storager, ns NotestoreClient, title, attachment, filename string, data []byte) (*Resource, error) {
+	n, resources, i, err := getAttachment(db, ns, title, attachment)
+	if err != nil {
+		return nil, err
+	}
+	prev := *n
+	old := resources[i]
+	r := &Resource{
+		Hash:     resourceHash(data),
+		Mime:     fileMimeType(filename, data),
+		Filename: filepath.Base(filename),
+		Size:     len(data),
+		Data:     data,
+	}
+	n.Resources = append(resources[:i:i], r)
+	n.Resources = append(n.Resources, resources[i+1:]...)
+	n.Body = replaceMedia(n.Body, old.Hash, func(elem string) string {
+		elem = mediaHashPattern.ReplaceAllLiteralString(elem, `hash="`+r.Hash+`"`)
+		return mediaTypePattern.ReplaceAllLiteralString(elem, `type="`+r.Mime+`"`)
+	})
+	return r, updateNoteContent(db, ns, &prev, n, RawNote)
+}
+
+// getAttachment returns the note with its content, its attachments and the
+// index of the requested attachment.
+func getAttachment(db Storager, ns NotestoreClient, title, attachment string) (*Note, []*Resource, int, error) {
+	n, err := getNoteExactWithContent(db, ns, title)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	resources, err := getCachedNoteResources(db, ns, n)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if index, err := strconv.Atoi(attachment); err == nil {
+		if index < 1 || index > len(resources) {
+			return nil, nil, 0, ErrAttachmentNotFound
+		}
+		return n, resources, index - 1, nil
+	}
+	for i, r := range resources {
+		if strings.EqualFold(r.Hash, attachment) {
+			return n, resources, i, nil
+		}
+	}
+	return nil, nil, 0, ErrAttachmentNotFound
+}
+
+// replaceMedia replaces the en-media elements referencing the hash in the
+// content with the result of the replace function.
+func replaceMedia(content, hash string, replace func(elem string) string) string {
+	return enMediaPattern.ReplaceAllStringFunc(content, func(elem string) string {
+		m := mediaHashPattern.FindStringSubmatch(elem)
+		if m == nil || !strings.EqualFold(m[1], hash) {
+			return elem
+		}
+		return replace(elem)
+	})
+}
+
+// mediaResource returns the resource referenced by the en-media element, or
+// nil if the note has no such resource.
+func mediaResource(elem string, resources []*Resource) *Resource {
+	m := mediaHashPattern.FindStringSubmatch(elem)
+	if m == nil {
+		return nil
+	}
+	for _, r := range resources {
+		if strings.EqualFold(r.Hash, m[1]) {
+			return r
+		}
+	}
+	return nil
+}
+
+// noteResources returns the note's resources, including the data. Note
+// services without resources give no resources instead of an error.
+func noteResources(ns NotestoreClient, guid string) ([]*Resource, error) {
+	resources, err := ns.GetNoteResources(guid)
+	if err == ErrNotSupported {
+		return nil, nil
+	}
+	return resources, err
+}
+
+// fileMimeType returns the mime type for the file, from its extension or
+// its content.
+func fileMimeType(filename string, data []byte) string {
+	t := mime.TypeByExtension(filepath.Ext(filename))
+	if t == "" {
+		t = http.DetectContentType(data)
+	}
+	if mediaType, _, err := mime.ParseMediaType(t); err == nil {
+		return mediaType
+	}
+	return t
+}
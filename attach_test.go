@@ -0,0 +1,98 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachments(t *testing.T) {
+	oldData, newData := []byte("old"), []byte("%PDF-1.4")
+	oldHash, newHash := resourceHash(oldData), resourceHash(newData)
+	body := `<div>Invoice</div><en-media type="image/png" hash="` + oldHash + `"/><div>Kept</div><en-media hash="keep" type="image/png"></en-media>`
+	setup := func(saved **Note) NotestoreClient {
+		note := &Note{GUID: "GUID", Title: "Invoice", Notebook: &Notebook{}}
+		ns := nsWithNote(note)
+		ns.getNoteContent = func(string) (string, error) {
+			return XMLHeader + "<en-note>" + body + "</en-note>", nil
+		}
+		ns.getResources = func(string) ([]*Resource, error) {
+			return []*Resource{
+				{GUID: "1", Hash: oldHash, Mime: "image/png", Data: oldData},
+				{GUID: "2", Hash: "keep", Mime: "image/png", Data: []byte("keep")},
+			}, nil
+		}
+		ns.updateNote = func(n *Note) error { *saved = n; return nil }
+		return ns
+	}
+
+	t.Run("remove by index", func(t *testing.T) {
+		assert := assert.New(t)
+		var saved *Note
+		ns := setup(&saved)
+		removed, err := RemoveAttachment(new(mockStore), ns, "Invoice", "1")
+		assert.NoError(err)
+		assert.Equal("1", removed.GUID)
+		if assert.NotNil(saved) && assert.Len(saved.Resources, 1) {
+			assert.Equal("2", saved.Resources[0].GUID)
+			assert.NotContains(saved.Body, oldHash)
+			assert.Contains(saved.Body, `<div>Invoice</div><div>Kept</div><en-media hash="keep"`)
+		}
+	})
+
+	t.Run("replace by hash", func(t *testing.T) {
+		assert := assert.New(t)
+		var saved *Note
+		ns := setup(&saved)
+		added, err := ReplaceAttachment(new(mockStore), ns, "Invoice", oldHash, "/tmp/invoice.pdf", newData)
+		assert.NoError(err)
+		assert.Equal(&Resource{Hash: newHash, Mime: "application/pdf", Filename: "invoice.pdf", Size: len(newData), Data: newData}, added)
+		if assert.NotNil(saved) && assert.Len(saved.Resources, 2) {
+			assert.Equal(added, saved.Resources[0])
+			assert.Equal("2", saved.Resources[1].GUID)
+			assert.Contains(saved.Body, `<en-media type="application/pdf" hash="`+newHash+`"/>`)
+			assert.Contains(saved.Body, `<en-media hash="keep" type="image/png">`)
+		}
+	})
+
+	t.Run("undo remove", func(t *testing.T) {
+		assert := assert.New(t)
+		var saved *Note
+		ns := setup(&saved)
+		db := &mockUndoStore{mockStore: &mockStore{}}
+		_, err := RemoveAttachment(db, ns, "Invoice", "1")
+		assert.NoError(err)
+		if assert.Len(db.entries, 1, "The removal should be added to the undo log") {
+			assert.Equal(UndoEdit, db.entries[0].Op)
+			assert.Contains(db.entries[0].Note.Body, oldHash)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		assert := assert.New(t)
+		var saved *Note
+		ns := setup(&saved)
+		_, err := RemoveAttachment(new(mockStore), ns, "Invoice", "3")
+		assert.Equal(ErrAttachmentNotFound, err)
+		_, err = ReplaceAttachment(new(mockStore), ns, "Invoice", "missing", "new.pdf", newData)
+		assert.Equal(ErrAttachmentNotFound, err)
+		assert.Nil(saved)
+	})
+}
@@ -0,0 +1,130 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultNoteTitle is the title of new notes without a title when the
+// title isn't derived from the content.
+const DefaultNoteTitle = "Untitled note"
+
+// maxTitleLength is the longest note title, in characters, accepted by
+// Evernote.
+const maxTitleLength = 255
+
+var (
+	markdownHeading = regexp.MustCompile(`^#{1,6}(?:\s+|$)(.*?)[\s#]*$`)
+	// markdownInline matches the emphasis and code markers, and the link
+	// targets, removed from titles.
+	markdownInline = regexp.MustCompile("[*_`~]+|\\]\\([^)]*\\)|!?\\[")
+	// markdownLinePrefix matches list, quote and task markers.
+	markdownLinePrefix = regexp.MustCompile(`^\s*(?:[>*+-]\s+|\d+[.)]\s+)*(?:\[[ xX]\]\s+)?`)
+)
+
+// TitleFromContent returns a title for the Markdown content. The text of
+// the first heading is used, or the first line with text if the content
+// has no headings. An empty string is returned if the content has no
+// text.
+func TitleFromContent(md string) string {
+	first := ""
+	scanner := bufio.NewScanner(strings.NewReader(md))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			if title := cleanTitle(m[1]); title != "" {
+				return title
+			}
+			continue
+		}
+		if first == "" {
+			first = cleanTitle(markdownLinePrefix.ReplaceAllString(line, ""))
+		}
+	}
+	return first
+}
+
+// titleFromENML returns a title for the ENML content. The text of the
+// first heading is used, or the first text if the content has no headings.
+func titleFromENML(content string) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	first := ""
+	var walk func(n *html.Node) string
+	walk = func(n *html.Node) string {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				if title := cleanTitle(nodeText(n)); title != "" {
+					return title
+				}
+			}
+		}
+		if n.Type == html.TextNode && first == "" {
+			first = cleanTitle(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if title := walk(c); title != "" {
+				return title
+			}
+		}
+		return ""
+	}
+	if title := walk(doc); title != "" {
+		return title
+	}
+	return first
+}
+
+// cleanTitle removes the Markdown markers and collapses the white space.
+// Titles longer than Evernote accepts are shortened.
+func cleanTitle(s string) string {
+	s = strings.Join(strings.Fields(markdownInline.ReplaceAllString(s, "")), " ")
+	if utf8.RuneCountInString(s) > maxTitleLength {
+		s = strings.TrimSpace(string([]rune(s)[:maxTitleLength-1])) + "…"
+	}
+	return s
+}
+
+// SetNewNoteTitle sets the title of a new note without a title. Unless
+// it's turned off in the settings, the title is derived from the note's
+// content. DefaultNoteTitle is used if the content has no text.
+func SetNewNoteTitle(settings *Settings, note *Note, opts NoteOption) {
+	if strings.TrimSpace(note.Title) != "" {
+		return
+	}
+	note.Title = ""
+	if !settings.DisableAutoTitle {
+		if opts&RawNote != 0 {
+			note.Title = titleFromENML(note.Body)
+		} else {
+			note.Title = TitleFromContent(note.MD)
+		}
+	}
+	if note.Title == "" {
+		note.Title = DefaultNoteTitle
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTitleFromContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		title   string
+	}{
+		{"Heading", "Some text\n\n## Weekly *plan* ##\nMore", "Weekly plan"},
+		{"First line", "\n  Buy **milk** and [bread](https://example.com)\nSecond line", "Buy milk and bread"},
+		{"List item", "- [ ] Call the bank\n- Pay rent", "Call the bank"},
+		{"Empty heading", "#\nFirst line", "First line"},
+		{"No text", "\n  \n", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.title, TitleFromContent(test.content))
+		})
+	}
+
+	long := TitleFromContent(strings.Repeat("word ", 100))
+	assert.Equal(t, maxTitleLength, len([]rune(long)))
+	assert.True(t, strings.HasSuffix(long, "…"))
+}
+
+func TestSetNewNoteTitle(t *testing.T) {
+	assert := assert.New(t)
+	settings := new(Settings)
+
+	note := &Note{MD: "# Groceries\n- milk"}
+	SetNewNoteTitle(settings, note, DefaultNoteOption)
+	assert.Equal("Groceries", note.Title)
+
+	note = &Note{Body: `<en-note><div>Intro</div><h2>Meeting notes</h2></en-note>`}
+	SetNewNoteTitle(settings, note, RawNote)
+	assert.Equal("Meeting notes", note.Title)
+
+	note = &Note{Title: "Given", MD: "# Other"}
+	SetNewNoteTitle(settings, note, DefaultNoteOption)
+	assert.Equal("Given", note.Title)
+
+	note = &Note{Title: " ", MD: ""}
+	SetNewNoteTitle(settings, note, DefaultNoteOption)
+	assert.Equal(DefaultNoteTitle, note.Title)
+
+	assert.NoError(SetConfigValue(settings, "note.auto-title", "off"))
+	note = &Note{MD: "# Groceries"}
+	SetNewNoteTitle(settings, note, DefaultNoteOption)
+	assert.Equal(DefaultNoteTitle, note.Title)
+	assert.Error(SetConfigValue(settings, "note.auto-title", "maybe"))
+}
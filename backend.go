@@ -0,0 +1,51 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotSupported is returned if the note service doesn't support the
+// operation.
+var ErrNotSupported = errors.New("not supported by the note service")
+
+// NoteBackend is a note service CLInote can work with, like Evernote or
+// Joplin or a local folder. The backend is selected by the type of the active credential.
+type NoteBackend interface {
+	// GetConfig returns the configuration.
+	GetConfig() Configuration
+	// GetNoteStore returns the notestore for the active credential.
+	GetNoteStore() (NotestoreClient, error)
+	// NewNoteStore returns a new notestore. Notestores are not safe for
+	// concurrent use so each goroutine should use its own.
+	NewNoteStore() (NotestoreClient, error)
+	// SetDryRun enables dry run mode. The calls that would change the
+	// user's notes are written to w instead of being sent.
+	SetDryRun(w io.Writer)
+	// Close shuts down the backend.
+	Close() error
+}
+
+// AccountInfoBackend is implemented by the backends that can report the
+// user's account and upload quota.
+type AccountInfoBackend interface {
+	// GetAccountInfo returns the user's account and upload quota.
+	GetAccountInfo() (*AccountInfo, error)
+}
@@ -0,0 +1,217 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+)
+
+var (
+	// ErrNoLinkInfo is returned if the user ID and shard needed for an
+	// in-app note link can't be read from the credential.
+	ErrNoLinkInfo = errors.New("the user ID and shard can't be read from the credential")
+	// ErrBacklinksNotSupported is returned if the storage can't hold the
+	// backlink index.
+	ErrBacklinksNotSupported = errors.New("storage can't save the backlink index")
+)
+
+// noteLinkPatterns match the links to notes in note content. The first
+// submatch is the GUID of the linked note.
+var noteLinkPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`evernote:///view/\d+/[^/"']+/([0-9a-fA-F-]{36})/`),
+	regexp.MustCompile(`/shard/[^/"']+/nl/\d+/([0-9a-fA-F-]{36})`),
+	regexp.MustCompile(`Home\.action#n=([0-9a-fA-F-]{36})`),
+}
+
+// Backlink is a note linking to another note.
+type Backlink struct {
+	// GUID is the linking note's GUID.
+	GUID string
+	// Title is the linking note's title.
+	Title string
+}
+
+// BacklinkStore stores the backlink index.
+type BacklinkStore interface {
+	// GetBacklinkIndex returns the backlinks keyed by the GUID of the
+	// linked note.
+	GetBacklinkIndex() (map[string][]*Backlink, error)
+	// SaveBacklinkIndex replaces the backlink index.
+	SaveBacklinkIndex(map[string][]*Backlink) error
+}
+
+// NoteAppLink returns the evernote:// link that opens the note in the
+// Evernote app.
+func NoteAppLink(cred *Credential, guid string) (string, error) {
+	info := ParseToken(cred.Secret)
+	if info.Shard == "" || info.UserID == 0 {
+		return "", ErrNoLinkInfo
+	}
+	return fmt.Sprintf("evernote:///view/%d/%s/%s/%s/", info.UserID, info.Shard, guid, guid), nil
+}
+
+// LinkNote adds a link to the note to at the end of the note from. The
+// link opens the note in the Evernote app. The backlink index is updated
+// if the storage supports it.
+func LinkNote(db Storager, ns NotestoreClient, cred *Credential, from, to string) error {
+	target, err := GetNoteExact(db, ns, to, "")
+	if err != nil {
+		return err
+	}
+	link, err := NoteAppLink(cred, target.GUID)
+	if err != nil {
+		return err
+	}
+	n, err := getNoteExactWithContent(db, ns, from)
+	if err != nil {
+		return err
+	}
+	n.Body += fmt.Sprintf(`<div><a href="%s">%s</a></div>`, html.EscapeString(link), html.EscapeString(target.Title))
+	if err = saveChanges(ns, n, true, true); err != nil {
+		return err
+	}
+	s, ok := db.(BacklinkStore)
+	if !ok {
+		return nil
+	}
+	index, err := s.GetBacklinkIndex()
+	if err != nil {
+		return err
+	}
+	for _, b := range index[target.GUID] {
+		if b.GUID == n.GUID {
+			return nil
+		}
+	}
+	index[target.GUID] = append(index[target.GUID], &Backlink{GUID: n.GUID, Title: n.Title})
+	return s.SaveBacklinkIndex(index)
+}
+
+// LinkedNotes returns the GUIDs of the notes linked from the content.
+func LinkedNotes(content string) []string {
+	seen := make(map[string]bool)
+	var guids []string
+	for _, p := range noteLinkPatterns {
+		for _, m := range p.FindAllStringSubmatch(content, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				guids = append(guids, m[1])
+			}
+		}
+	}
+	return guids
+}
+
+// ChangedBacklinkNotes returns the notes whose links have to be indexed
+// again, the notes updated since the index was last updated. All the notes
+// are returned if the notes don't have USNs.
+func ChangedBacklinkNotes(db Storager, notes []*Note) ([]*Note, error) {
+	state, err := db.GetSyncState()
+	if err != nil {
+		return nil, err
+	}
+	var changed []*Note
+	for _, n := range notes {
+		if state.BacklinkUSN == 0 || n.USN > state.BacklinkUSN {
+			changed = append(changed, n)
+		}
+	}
+	return changed, nil
+}
+
+// UpdateBacklinkIndex updates the index of the notes linking to each note.
+// The notes are all the indexed notes, links from notes not in the list
+// are removed. Only the content of the notes updated since the last update
+// is read, using the content cache. The progress function is called after
+// each read note, if not nil.
+func UpdateBacklinkIndex(db Storager, ns NotestoreClient, notes []*Note, progress func(*Note)) (map[string][]*Backlink, error) {
+	s, ok := db.(BacklinkStore)
+	if !ok {
+		return nil, ErrBacklinksNotSupported
+	}
+	changed, err := ChangedBacklinkNotes(db, notes)
+	if err != nil {
+		return nil, err
+	}
+	index, err := s.GetBacklinkIndex()
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(notes))
+	var usn int32
+	for _, n := range notes {
+		keep[n.GUID] = true
+		if n.USN > usn {
+			usn = n.USN
+		}
+	}
+	for _, n := range changed {
+		keep[n.GUID] = false
+	}
+	updated := make(map[string][]*Backlink)
+	for guid, links := range index {
+		for _, l := range links {
+			if keep[l.GUID] {
+				updated[guid] = append(updated[guid], l)
+			}
+		}
+	}
+	for _, n := range changed {
+		content, err := getCachedNoteContent(db, ns, n)
+		if err != nil {
+			return nil, err
+		}
+		for _, guid := range LinkedNotes(content) {
+			if guid != n.GUID {
+				updated[guid] = append(updated[guid], &Backlink{GUID: n.GUID, Title: n.Title})
+			}
+		}
+		if progress != nil {
+			progress(n)
+		}
+	}
+	if err = s.SaveBacklinkIndex(updated); err != nil {
+		return nil, err
+	}
+	state, err := db.GetSyncState()
+	if err != nil {
+		return nil, err
+	}
+	state.BacklinkUSN = usn
+	return updated, db.SaveSyncState(state)
+}
+
+// GetBacklinks returns the notes linking to the note, sorted by title.
+// The backlinks are read from the index built during sync.
+func GetBacklinks(db Storager, guid string) ([]*Backlink, error) {
+	s, ok := db.(BacklinkStore)
+	if !ok {
+		return nil, ErrBacklinksNotSupported
+	}
+	index, err := s.GetBacklinkIndex()
+	if err != nil {
+		return nil, err
+	}
+	links := index[guid]
+	sort.Slice(links, func(i, j int) bool { return links[i].Title < links[j].Title })
+	return links, nil
+}
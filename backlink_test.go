@@ -0,0 +1,149 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	linkedGUID  = "4dbd2b4e-0b1a-4c4e-9f27-5c8a3e6f1a2b"
+	linkingGUID = "9a1c5e3d-7f2b-4e8a-b6d4-1c3e5a7f9b2d"
+)
+
+type mockBacklinkStore struct {
+	*mockStore
+	index map[string][]*Backlink
+	state SyncState
+}
+
+func (m *mockBacklinkStore) GetSyncState() (*SyncState, error) {
+	state := m.state
+	return &state, nil
+}
+
+func (m *mockBacklinkStore) SaveSyncState(state *SyncState) error {
+	m.state = *state
+	return nil
+}
+
+func (m *mockBacklinkStore) GetBacklinkIndex() (map[string][]*Backlink, error) {
+	if m.index == nil {
+		return make(map[string][]*Backlink), nil
+	}
+	return m.index, nil
+}
+
+func (m *mockBacklinkStore) SaveBacklinkIndex(index map[string][]*Backlink) error {
+	m.index = index
+	return nil
+}
+
+func TestNoteAppLink(t *testing.T) {
+	assert := assert.New(t)
+	link, err := NoteAppLink(&Credential{Secret: "S=s1:U=8f:E=15e"}, linkedGUID)
+	assert.NoError(err)
+	assert.Equal("evernote:///view/143/s1/"+linkedGUID+"/"+linkedGUID+"/", link)
+
+	_, err = NoteAppLink(&Credential{Secret: "token"}, linkedGUID)
+	assert.Equal(ErrNoLinkInfo, err)
+}
+
+func TestLinkedNotes(t *testing.T) {
+	content := `<a href="evernote:///view/143/s1/` + linkedGUID + `/` + linkedGUID + `/">A</a>` +
+		`<a href="https://www.evernote.com/shard/s1/nl/143/` + linkingGUID + `/">B</a>` +
+		`<a href="evernote:///view/143/s1/` + linkedGUID + `/` + linkedGUID + `/">Again</a>`
+	assert.Equal(t, []string{linkedGUID, linkingGUID}, LinkedNotes(content))
+}
+
+func TestBacklinks(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockBacklinkStore{mockStore: &mockStore{
+		getNotebookCache:  func() (*NotebookCacheList, error) { return &NotebookCacheList{}, nil },
+		storeNotebookList: func(*NotebookCacheList) error { return nil },
+	}}
+	linked := &Note{GUID: linkedGUID, Title: "Target"}
+	linking := &Note{GUID: linkingGUID, Title: "Source"}
+	contents := map[string]string{
+		linkedGUID:  XMLHeader + "<en-note><div>No links</div></en-note>",
+		linkingGUID: XMLHeader + "<en-note><div>Text</div></en-note>",
+	}
+	ns := &mockNS{
+		getNoteContent: func(guid string) (string, error) { return contents[guid], nil },
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			if f.Words == "Target" {
+				return []*Note{linked}, nil
+			}
+			return []*Note{linking}, nil
+		},
+		updateNote: func(n *Note) error { contents[n.GUID] = n.Body; return nil },
+	}
+
+	t.Run("Link note", func(t *testing.T) {
+		assert.NoError(LinkNote(db, ns, &Credential{Secret: "S=s1:U=8f"}, "Source", "Target"))
+		assert.Contains(contents[linkingGUID], `<div>Text</div><div><a href="evernote:///view/143/s1/`+linkedGUID+`/`+linkedGUID+`/">Target</a></div>`)
+		links, err := GetBacklinks(db, linkedGUID)
+		assert.NoError(err)
+		assert.Equal([]*Backlink{{GUID: linkingGUID, Title: "Source"}}, links)
+	})
+
+	t.Run("Build index", func(t *testing.T) {
+		db.index = nil
+		index, err := UpdateBacklinkIndex(db, ns, []*Note{linked, linking}, nil)
+		assert.NoError(err)
+		assert.Len(index, 1)
+		links, err := GetBacklinks(db, linkedGUID)
+		assert.NoError(err)
+		assert.Equal([]*Backlink{{GUID: linkingGUID, Title: "Source"}}, links)
+		links, err = GetBacklinks(db, linkingGUID)
+		assert.NoError(err)
+		assert.Empty(links)
+	})
+
+	t.Run("Update index", func(t *testing.T) {
+		const otherGUID = "0b6e2f4a-3c5d-4e7f-8a9b-1c2d3e4f5a6b"
+		db.index, db.state = nil, SyncState{}
+		linked.USN, linking.USN = 10, 11
+		_, err := UpdateBacklinkIndex(db, ns, []*Note{linked, linking}, nil)
+		assert.NoError(err)
+		assert.Equal(int32(11), db.state.BacklinkUSN)
+
+		var read []string
+		ns.getNoteContent = func(guid string) (string, error) {
+			read = append(read, guid)
+			return contents[guid], nil
+		}
+		other := &Note{GUID: otherGUID, Title: "Other", USN: 12}
+		contents[otherGUID] = XMLHeader + `<en-note><a href="evernote:///view/143/s1/` + linkedGUID + `/` + linkedGUID + `/">Target</a></en-note>`
+		_, err = UpdateBacklinkIndex(db, ns, []*Note{linked, other}, nil)
+
+		assert.NoError(err)
+		assert.Equal([]string{otherGUID}, read, "Only the changed note should be read")
+		links, err := GetBacklinks(db, linkedGUID)
+		assert.NoError(err)
+		assert.Equal([]*Backlink{{GUID: otherGUID, Title: "Other"}}, links, "Links from removed notes should be dropped")
+		assert.Equal(int32(12), db.state.BacklinkUSN)
+	})
+
+	t.Run("Storage without index", func(t *testing.T) {
+		_, err := GetBacklinks(db.mockStore, linkedGUID)
+		assert.Equal(ErrBacklinksNotSupported, err)
+	})
+}
@@ -0,0 +1,588 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// BackupVersion is the version of the backup format written by CLInote.
+	BackupVersion = 1
+	// DefaultBackupKeep is the number of backups kept if the user hasn't
+	// set a number.
+	DefaultBackupKeep = 7
+	// ENEXBackupFormat is the name of the ENEX backup format.
+	ENEXBackupFormat = "enex"
+	// SnapshotBackupFormat is the name of the snapshot backup format.
+	SnapshotBackupFormat = "snapshot"
+	// DefaultBackupFormat is the format used if the user hasn't set one.
+	DefaultBackupFormat = ENEXBackupFormat
+	// BackupFilePrefix is the start of the backup file names. The rest of
+	// the name is the creation time and the format's extension.
+	BackupFilePrefix   = "clinote-backup-"
+	backupTimeFormat   = "20060102T150405Z"
+	backupManifestENEX = "backup.json"
+)
+
+var (
+	// ErrUnknownBackupFormat is returned if the backup format is not known.
+	ErrUnknownBackupFormat = errors.New("unknown backup format")
+	// ErrUnsupportedBackupVersion is returned if the backup was written by
+	// a newer version of CLInote.
+	ErrUnsupportedBackupVersion = errors.New("unsupported backup version")
+	// ErrInvalidBackupInterval is returned if the backup interval isn't
+	// positive.
+	ErrInvalidBackupInterval = errors.New("the backup interval must be positive")
+	// ErrNoBackups is returned if the backup folder has no backups.
+	ErrNoBackups = errors.New("no backups found")
+	// ErrBackupNoteNotFound is returned if no note in the backup matches.
+	ErrBackupNoteNotFound = errors.New("no note in the backup matches")
+	// ErrAmbiguousBackupNote is returned if more than one note in the
+	// backup matches the title.
+	ErrAmbiguousBackupNote = errors.New("more than one note in the backup matches, use the GUID")
+)
+
+// BackupManifest describes the content of a backup.
+type BackupManifest struct {
+	// Version is the version of the backup format.
+	Version int
+	// Created is when the backup was created.
+	Created time.Time
+	// Format is the name of the backup format.
+	Format string
+	// Notebooks are the notebooks in the backup.
+	Notebooks []*BackupNotebook
+}
+
+// BackupNotebook is a notebook in a backup.
+type BackupNotebook struct {
+	// Name is the notebook name.
+	Name string
+	// Stack is the stack the notebook belongs to.
+	Stack string `json:",omitempty"`
+	// File is the file in the backup holding the notes, if the format
+	// stores each notebook in its own file.
+	File string `json:",omitempty"`
+	// GUIDs are the GUIDs of the notes, in the order they are stored.
+	GUIDs []string
+}
+
+// BackupWriter adds notes to a backup.
+type BackupWriter interface {
+	// Add writes the note with the ENML content in the body and the resources,
+	// including the data. The notes of a notebook are added together.
+	Add(n *Note) error
+	// Close writes the manifest and ends the backup. The underlying
+	// writer isn't closed.
+	Close() error
+}
+
+// BackupReader reads the notes in a backup.
+type BackupReader interface {
+	// Manifest returns the manifest of the backup.
+	Manifest() *BackupManifest
+	// Next returns the next note with the ENML content in the body and the
+	// resources. io.EOF is returned after the last note.
+	Next() (*Note, error)
+	// Close releases the backup.
+	Close() error
+}
+
+// BackupFormat is a format backups can be written in.
+type BackupFormat struct {
+	// Name is used to select the format.
+	Name string
+	// Ext is the file extension of the backups.
+	Ext string
+	// NewWriter returns a writer for a new backup written to w.
+	NewWriter func(w io.Writer, m *BackupManifest) (BackupWriter, error)
+	// Open opens the backup file.
+	Open func(file string) (BackupReader, error)
+}
+
+// ENEXBackup stores the backup as a zip file with an ENEX file for each
+// notebook, so the notebooks can be imported by other note apps.
+var ENEXBackup = &BackupFormat{Name: ENEXBackupFormat, Ext: ".zip", NewWriter: newENEXBackupWriter, Open: openENEXBackup}
+
+// FindBackupFormat returns the format with the name.
+func FindBackupFormat(formats []*BackupFormat, name string) (*BackupFormat, error) {
+	for _, f := range formats {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return nil, ErrUnknownBackupFormat
+}
+
+// ParseBackupFormat checks that the backup format name is known. An
+// empty name returns the default format.
+func ParseBackupFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return DefaultBackupFormat, nil
+	case ENEXBackupFormat:
+		return ENEXBackupFormat, nil
+	case SnapshotBackupFormat:
+		return SnapshotBackupFormat, nil
+	}
+	return "", ErrUnknownBackupFormat
+}
+
+// ParseBackupInterval parses the interval between scheduled backups, for
+// example 1d or 12h. Off, an empty string or zero turns the schedule off.
+func ParseBackupInterval(s string) (time.Duration, error) {
+	switch strings.ToLower(s) {
+	case "", "0", "off":
+		return 0, nil
+	}
+	d, err := ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, ErrInvalidBackupInterval
+	}
+	return d, nil
+}
+
+// BackupFile is a backup in the backup folder.
+type BackupFile struct {
+	// Path is the path of the backup file.
+	Path string
+	// Format is the format of the backup.
+	Format *BackupFormat
+	// Created is when the backup was created.
+	Created time.Time
+	// Size is the size of the file in bytes.
+	Size int64
+}
+
+// BackupDir returns the folder the backups are written to. The backups
+// folder in the config folder is used if the user hasn't set a folder.
+func BackupDir(cfg Configuration, settings *Settings) string {
+	if settings.BackupDir != "" {
+		return settings.BackupDir
+	}
+	return filepath.Join(cfg.GetConfigFolder(), "backups")
+}
+
+// BackupKeep returns the number of backups kept by the rotation.
+func BackupKeep(settings *Settings) int {
+	if settings.BackupKeep > 0 {
+		return settings.BackupKeep
+	}
+	return DefaultBackupKeep
+}
+
+// BackupDue returns true if a backup is scheduled and the interval has
+// passed since the last backup.
+func BackupDue(settings *Settings, now time.Time) bool {
+	return settings.BackupInterval > 0 && now.Sub(settings.LastBackup) >= settings.BackupInterval
+}
+
+// RunBackup writes all the notes, with their content and attachments, to
+// a new backup in the folder. The backup is written to a temporary file
+// that is renamed when done, so a failed run doesn't leave a broken
+// backup. Notes that fail to be fetched are skipped and reported in the
+// returned FetchError, the backup is still saved.
+func RunBackup(db Storager, ns NotestoreClient, dir string, format *BackupFormat, now time.Time, progress FetchProgress) (*BackupFile, error) {
+	books, err := GetNotebooks(db, ns, true)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := ns.GetAllTags()
+	if err != nil {
+		return nil, err
+	}
+	var notes []*Note
+	for _, b := range books {
+		list, err := FindAllNotes(ns, &NoteFilter{NotebookGUID: b.GUID, Order: NoteFilterOrderCreated}, DefaultBulkPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range list {
+			n.Notebook = b
+		}
+		notes = append(notes, list...)
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, BackupFilePrefix+now.UTC().Format(backupTimeFormat)+format.Ext)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fetchErr, err := writeBackup(f, ns, notes, tags, format, now, progress)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = RecordBackup(db, now); err != nil {
+		return nil, err
+	}
+	backup := &BackupFile{Path: path, Format: format, Created: now.UTC().Truncate(time.Second), Size: fi.Size()}
+	if len(fetchErr.Errors) != 0 {
+		return backup, fetchErr
+	}
+	return backup, nil
+}
+
+func writeBackup(w io.Writer, ns NotestoreClient, notes []*Note, tags []*Tag, format *BackupFormat, now time.Time, progress FetchProgress) (*FetchError, error) {
+	bw, err := format.NewWriter(w, &BackupManifest{Version: BackupVersion, Created: now, Format: format.Name})
+	if err != nil {
+		return nil, err
+	}
+	fetchErr := &FetchError{Errors: make(map[string]error), Total: len(notes)}
+	for i, n := range notes {
+		content, err := ns.GetNoteContent(n.GUID)
+		if err == nil {
+			n.Body = content
+			n.Resources, err = ns.GetNoteResources(n.GUID)
+		}
+		if err != nil {
+			fetchErr.Errors[n.GUID] = err
+		} else {
+			n.Tags = noteTagNames(n, tags)
+			if err = bw.Add(n); err != nil {
+				return nil, err
+			}
+			// The resources can be large, don't keep them around.
+			n.Resources = nil
+		}
+		if progress != nil {
+			progress(i+1, len(notes))
+		}
+	}
+	return fetchErr, bw.Close()
+}
+
+// ListBackups returns the backups in the folder, newest first. Files that
+// don't match a backup name of one of the formats are ignored.
+func ListBackups(dir string, formats []*BackupFormat) ([]*BackupFile, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*BackupFile
+	for _, fi := range infos {
+		if fi.IsDir() {
+			continue
+		}
+		if b := parseBackupFilename(filepath.Join(dir, fi.Name()), formats); b != nil {
+			b.Size = fi.Size()
+			list = append(list, b)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list, nil
+}
+
+// parseBackupFilename returns the backup if the file name is a backup name
+// of one of the formats. Otherwise nil is returned.
+func parseBackupFilename(path string, formats []*BackupFormat) *BackupFile {
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, BackupFilePrefix) {
+		return nil
+	}
+	for _, f := range formats {
+		if !strings.HasSuffix(name, f.Ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, BackupFilePrefix), f.Ext)
+		created, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		return &BackupFile{Path: path, Format: f, Created: created}
+	}
+	return nil
+}
+
+// OpenBackup opens the backup file. The format is given by the file name.
+func OpenBackup(path string, formats []*BackupFormat) (BackupReader, error) {
+	b := parseBackupFilename(path, formats)
+	if b == nil {
+		return nil, ErrUnknownBackupFormat
+	}
+	return b.Format.Open(path)
+}
+
+// RotateBackups removes the oldest backups so at most keep backups are
+// left in the folder. The removed backups are returned.
+func RotateBackups(dir string, keep int, formats []*BackupFormat) ([]*BackupFile, error) {
+	list, err := ListBackups(dir, formats)
+	if err != nil || len(list) <= keep {
+		return nil, err
+	}
+	removed := list[keep:]
+	for _, b := range removed {
+		if err = os.Remove(b.Path); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// FindBackupNote returns the note in the backup with the GUID or the
+// title. Titles are matched without regard to case.
+func FindBackupNote(r BackupReader, query string) (*Note, error) {
+	var found *Note
+	for {
+		n, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n.GUID == query {
+			return n, nil
+		}
+		if !strings.EqualFold(n.Title, query) {
+			continue
+		}
+		if found != nil {
+			return nil, ErrAmbiguousBackupNote
+		}
+		found = n
+	}
+	if found == nil {
+		return nil, ErrBackupNoteNotFound
+	}
+	return found, nil
+}
+
+// RestoreNote creates the note from a backup as a new note in the
+// notebook it was backed up from. The notebook is created if it doesn't
+// exist anymore.
+func RestoreNote(db Storager, ns NotestoreClient, n *Note) error {
+	if n.Notebook != nil && n.Notebook.Name != "" {
+		b, err := findNotebook(db, ns, n.Notebook.Name)
+		if err == ErrNoNotebookFound {
+			b = &Notebook{Name: n.Notebook.Name, Stack: n.Notebook.Stack}
+			if err = ns.CreateNotebook(b, false); err == nil {
+				_, err = GetNotebooks(db, ns, true)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		n.Notebook = b
+	}
+	n.GUID = ""
+	return ns.CreateNote(n)
+}
+
+// enexBackupWriter writes the notes of each notebook to an ENEX file in
+// a zip archive. The manifest is written last as backup.json.
+type enexBackupWriter struct {
+	zw       *zip.Writer
+	manifest *BackupManifest
+	current  *BackupNotebook
+	key      string
+	enex     *ENEXWriter
+	names    map[string]bool
+}
+
+func newENEXBackupWriter(w io.Writer, m *BackupManifest) (BackupWriter, error) {
+	return &enexBackupWriter{zw: zip.NewWriter(w), manifest: m, names: make(map[string]bool)}, nil
+}
+
+func (e *enexBackupWriter) Add(n *Note) error {
+	book := n.Notebook
+	if book == nil {
+		book = &Notebook{}
+	}
+	if e.current == nil || notebookKey(book) != e.key {
+		if err := e.endNotebook(); err != nil {
+			return err
+		}
+		name := safeFilename(book.Name)
+		if name == "" {
+			name = "untitled"
+		}
+		file := "notebooks/" + name + ".enex"
+		for i := 2; e.names[file]; i++ {
+			file = fmt.Sprintf("notebooks/%s (%d).enex", name, i)
+		}
+		e.names[file] = true
+		fw, err := e.zw.Create(file)
+		if err != nil {
+			return err
+		}
+		e.enex = NewENEXWriter(fw, "")
+		e.enex.Now = func() time.Time { return e.manifest.Created }
+		e.current = &BackupNotebook{Name: book.Name, Stack: book.Stack, File: file}
+		e.key = notebookKey(book)
+		e.manifest.Notebooks = append(e.manifest.Notebooks, e.current)
+	}
+	e.current.GUIDs = append(e.current.GUIDs, n.GUID)
+	return e.enex.WriteNote(n, n.Body, n.Resources)
+}
+
+func (e *enexBackupWriter) endNotebook() error {
+	if e.enex == nil {
+		return nil
+	}
+	err := e.enex.Close()
+	e.enex = nil
+	return err
+}
+
+func (e *enexBackupWriter) Close() error {
+	if err := e.endNotebook(); err != nil {
+		return err
+	}
+	fw, err := e.zw.Create(backupManifestENEX)
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(fw).Encode(e.manifest); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
+
+type enexBackupReader struct {
+	zr       *zip.ReadCloser
+	manifest *BackupManifest
+	files    map[string]*zip.File
+	book     int
+	index    int
+	rc       io.ReadCloser
+	enex     *ENEXReader
+}
+
+func openENEXBackup(file string) (BackupReader, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	r := &enexBackupReader{zr: zr, files: make(map[string]*zip.File), book: -1}
+	for _, f := range zr.File {
+		r.files[f.Name] = f
+	}
+	err = r.readManifest()
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *enexBackupReader) readManifest() error {
+	f, ok := r.files[backupManifestENEX]
+	if !ok {
+		return fmt.Errorf("%s is missing", backupManifestENEX)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err = json.NewDecoder(rc).Decode(&r.manifest); err != nil {
+		return err
+	}
+	if r.manifest.Version > BackupVersion {
+		return ErrUnsupportedBackupVersion
+	}
+	return nil
+}
+
+func (r *enexBackupReader) Manifest() *BackupManifest {
+	return r.manifest
+}
+
+func (r *enexBackupReader) Next() (*Note, error) {
+	for {
+		if r.enex == nil {
+			if err := r.nextNotebook(); err != nil {
+				return nil, err
+			}
+		}
+		n, err := r.enex.Next()
+		if err == io.EOF {
+			r.rc.Close()
+			r.enex = nil
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		book := r.manifest.Notebooks[r.book]
+		n.Notebook = &Notebook{Name: book.Name, Stack: book.Stack}
+		if r.index < len(book.GUIDs) {
+			n.GUID = book.GUIDs[r.index]
+		}
+		r.index++
+		return n, nil
+	}
+}
+
+func (r *enexBackupReader) nextNotebook() error {
+	r.book++
+	if r.book >= len(r.manifest.Notebooks) {
+		return io.EOF
+	}
+	book := r.manifest.Notebooks[r.book]
+	f, ok := r.files[book.File]
+	if !ok {
+		return fmt.Errorf("%s is missing", book.File)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	r.rc, r.enex, r.index = rc, NewENEXReader(rc), 0
+	return nil
+}
+
+func (r *enexBackupReader) Close() error {
+	if r.enex != nil {
+		r.rc.Close()
+	}
+	return r.zr.Close()
+}
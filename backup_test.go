@@ -0,0 +1,268 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestENEXBackup(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-backup")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "backup.zip")
+	f, err := os.Create(file)
+	assert.NoError(err)
+	created := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	w, err := ENEXBackup.NewWriter(f, &BackupManifest{Version: BackupVersion, Created: created, Format: ENEXBackupFormat})
+	assert.NoError(err)
+	data := []byte("attachment")
+	notes := []*Note{
+		{GUID: "n1", Title: "Plan", Body: XMLHeader + "<en-note>Plan</en-note>", Notebook: &Notebook{Name: "Work/Q3", Stack: "Jobs"},
+			Resources: []*Resource{{Mime: "text/plain", Filename: "a.txt", Data: data}}},
+		{GUID: "n2", Title: "Budget", Body: XMLHeader + "<en-note/>", Notebook: &Notebook{Name: "Work/Q3", Stack: "Jobs"}},
+		{GUID: "n3", Title: "Milk", Body: XMLHeader + "<en-note/>", Notebook: &Notebook{Name: "Home"}},
+	}
+	for _, n := range notes {
+		assert.NoError(w.Add(n))
+	}
+	assert.NoError(w.Close())
+	assert.NoError(f.Close())
+
+	r, err := ENEXBackup.Open(file)
+	assert.NoError(err)
+	defer r.Close()
+	m := r.Manifest()
+	assert.True(created.Equal(m.Created))
+	assert.Equal([]*BackupNotebook{
+		{Name: "Work/Q3", Stack: "Jobs", File: "notebooks/Work_Q3.enex", GUIDs: []string{"n1", "n2"}},
+		{Name: "Home", File: "notebooks/Home.enex", GUIDs: []string{"n3"}},
+	}, m.Notebooks)
+	for _, n := range notes {
+		read, err := r.Next()
+		assert.NoError(err)
+		assert.Equal(n.GUID, read.GUID)
+		assert.Equal(n.Title, read.Title)
+		assert.Equal(n.Body, read.Body)
+		assert.Equal(&Notebook{Name: n.Notebook.Name, Stack: n.Notebook.Stack}, read.Notebook)
+		assert.Len(read.Resources, len(n.Resources))
+	}
+	_, err = r.Next()
+	assert.Equal(io.EOF, err)
+}
+
+func TestRunBackup(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-backup")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	settings := new(Settings)
+	db := &mockStore{
+		getNotebookCache:  func() (*NotebookCacheList, error) { return new(NotebookCacheList), nil },
+		storeNotebookList: func(*NotebookCacheList) error { return nil },
+		getSettings:       func() (*Settings, error) { return settings, nil },
+		storeSettings:     func(s *Settings) error { settings = s; return nil },
+	}
+	ns := &mockNS{
+		getAllNotebooks: func() ([]*Notebook, error) {
+			return []*Notebook{{Name: "Work", GUID: "b1"}, {Name: "Home", GUID: "b2"}}, nil
+		},
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			if f.NotebookGUID == "b1" {
+				return []*Note{{GUID: "n1", Title: "Plan", TagGUIDs: []string{"t1"}}, {GUID: "n2", Title: "Broken"}}, nil
+			}
+			return []*Note{{GUID: "n3", Title: "Milk"}}, nil
+		},
+		getAllTags: func() ([]*Tag, error) { return []*Tag{{GUID: "t1", Name: "work"}}, nil },
+		getNoteContent: func(guid string) (string, error) {
+			if guid == "n2" {
+				return "", expectedError
+			}
+			return XMLHeader + "<en-note>" + guid + "</en-note>", nil
+		},
+		getResources: func(string) ([]*Resource, error) { return nil, nil },
+	}
+	now := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	var progress []int
+
+	b, err := RunBackup(db, ns, dir, ENEXBackup, now, func(done, total int) { progress = append(progress, done) })
+
+	fetchErr, ok := err.(*FetchError)
+	if assert.True(ok, "The failed note should be reported") {
+		assert.Equal(map[string]error{"n2": expectedError}, fetchErr.Errors)
+	}
+	assert.Equal([]int{1, 2, 3}, progress)
+	assert.Equal(filepath.Join(dir, "clinote-backup-20180301T100000Z.zip"), b.Path)
+	assert.Equal(now, settings.LastBackup)
+	assert.NotZero(b.Size)
+
+	r, err := OpenBackup(b.Path, []*BackupFormat{ENEXBackup})
+	assert.NoError(err)
+	defer r.Close()
+	n, err := FindBackupNote(r, "plan")
+	assert.NoError(err)
+	assert.Equal("n1", n.GUID)
+	assert.Equal([]string{"work"}, n.Tags)
+	assert.Equal(XMLHeader+"<en-note>n1</en-note>", n.Body)
+	assert.Equal("Work", n.Notebook.Name)
+}
+
+func TestListAndRotateBackups(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-backup")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	snapshot := &BackupFormat{Name: SnapshotBackupFormat, Ext: ".db.gz"}
+	formats := []*BackupFormat{ENEXBackup, snapshot}
+	for _, name := range []string{
+		"clinote-backup-20180301T100000Z.zip",
+		"clinote-backup-20180303T100000Z.db.gz",
+		"clinote-backup-20180302T100000Z.zip",
+		"clinote-backup-20180304T100000Z.zip.tmp",
+		"clinote-backup-latest.zip",
+		"notes.zip",
+	} {
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte("data"), 0600))
+	}
+
+	list, err := ListBackups(dir, formats)
+	assert.NoError(err)
+	if assert.Len(list, 3) {
+		assert.Equal(filepath.Join(dir, "clinote-backup-20180303T100000Z.db.gz"), list[0].Path)
+		assert.Equal(snapshot, list[0].Format)
+		assert.Equal(time.Date(2018, 3, 3, 10, 0, 0, 0, time.UTC), list[0].Created)
+		assert.Equal(int64(4), list[0].Size)
+		assert.Equal(filepath.Join(dir, "clinote-backup-20180301T100000Z.zip"), list[2].Path)
+	}
+
+	removed, err := RotateBackups(dir, 2, formats)
+	assert.NoError(err)
+	if assert.Len(removed, 1) {
+		assert.Equal(filepath.Join(dir, "clinote-backup-20180301T100000Z.zip"), removed[0].Path)
+	}
+	list, err = ListBackups(dir, formats)
+	assert.NoError(err)
+	assert.Len(list, 2)
+
+	list, err = ListBackups(filepath.Join(dir, "missing"), formats)
+	assert.NoError(err)
+	assert.Empty(list)
+}
+
+func TestFindBackupNote(t *testing.T) {
+	notes := []*Note{{GUID: "n1", Title: "Plan"}, {GUID: "n2", Title: "plan"}, {GUID: "n3", Title: "Milk"}}
+	tests := []struct {
+		query string
+		guid  string
+		err   error
+	}{
+		{"milk", "n3", nil},
+		{"n2", "n2", nil},
+		{"Plan", "", ErrAmbiguousBackupNote},
+		{"Eggs", "", ErrBackupNoteNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			n, err := FindBackupNote(&sliceBackupReader{notes: notes}, test.query)
+			assert.Equal(t, test.err, err)
+			if test.guid != "" {
+				assert.Equal(t, test.guid, n.GUID)
+			}
+		})
+	}
+}
+
+func TestRestoreNote(t *testing.T) {
+	assert := assert.New(t)
+	books := []*Notebook{{Name: "Work", GUID: "b1"}}
+	db := &mockStore{
+		getNotebookCache:  func() (*NotebookCacheList, error) { return new(NotebookCacheList), nil },
+		storeNotebookList: func(*NotebookCacheList) error { return nil },
+	}
+	var created []*Note
+	var createdBook *Notebook
+	ns := &mockNS{
+		getAllNotebooks: func() ([]*Notebook, error) { return books, nil },
+		createNotebook: func(b *Notebook, defaultNotebook bool) error {
+			b.GUID = "b2"
+			createdBook = b
+			books = append(books, b)
+			return nil
+		},
+		createNote: func(n *Note) error { created = append(created, n); return nil },
+	}
+
+	assert.NoError(RestoreNote(db, ns, &Note{GUID: "n1", Title: "Plan", Notebook: &Notebook{Name: "Work"}}))
+	assert.NoError(RestoreNote(db, ns, &Note{GUID: "n2", Title: "Milk", Notebook: &Notebook{Name: "Home", Stack: "Life"}}))
+
+	assert.Equal(&Notebook{Name: "Home", Stack: "Life", GUID: "b2"}, createdBook)
+	if assert.Len(created, 2) {
+		assert.Empty(created[0].GUID, "The note should be restored as a new note")
+		assert.Equal("b1", created[0].Notebook.GUID)
+		assert.Equal("b2", created[1].Notebook.GUID)
+	}
+}
+
+func TestParseBackupInterval(t *testing.T) {
+	assert := assert.New(t)
+	d, err := ParseBackupInterval("1d")
+	assert.NoError(err)
+	assert.Equal(24*time.Hour, d)
+	d, err = ParseBackupInterval("off")
+	assert.NoError(err)
+	assert.Zero(d)
+	_, err = ParseBackupInterval("-2h")
+	assert.Equal(ErrInvalidBackupInterval, err)
+
+	now := time.Date(2018, 3, 2, 10, 0, 0, 0, time.UTC)
+	settings := &Settings{BackupInterval: 24 * time.Hour, LastBackup: now.Add(-23 * time.Hour)}
+	assert.False(BackupDue(settings, now))
+	settings.LastBackup = now.Add(-25 * time.Hour)
+	assert.True(BackupDue(settings, now))
+	settings.BackupInterval = 0
+	assert.False(BackupDue(settings, now), "Backups are not due if not scheduled")
+}
+
+type sliceBackupReader struct {
+	notes []*Note
+}
+
+func (s *sliceBackupReader) Manifest() *BackupManifest {
+	return new(BackupManifest)
+}
+
+func (s *sliceBackupReader) Next() (*Note, error) {
+	if len(s.notes) == 0 {
+		return nil, io.EOF
+	}
+	n := s.notes[0]
+	s.notes = s.notes[1:]
+	return n, nil
+}
+
+func (s *sliceBackupReader) Close() error {
+	return nil
+}
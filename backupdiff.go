@@ -0,0 +1,348 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DiffStatus is how a note changed between two backups.
+type DiffStatus string
+
+const (
+	// DiffAdded is a note that is only in the new backup.
+	DiffAdded DiffStatus = "added"
+	// DiffRemoved is a note that is only in the old backup.
+	DiffRemoved DiffStatus = "removed"
+	// DiffModified is a note that has changed between the backups.
+	DiffModified DiffStatus = "modified"
+)
+
+// maxLineDiffSize is the largest number of line pairs compared when the
+// content diff is computed. Larger notes are shown as fully replaced.
+const maxLineDiffSize = 4000000
+
+var backupDiffHeader = []string{"Change", "Title", "Notebook", "Details"}
+
+// NoteDiff is a note that differs between two backups.
+type NoteDiff struct {
+	// Status is how the note changed.
+	Status DiffStatus
+	// GUID is the note's GUID.
+	GUID string
+	// Title is the note's title in the new backup, or in the old backup
+	// if the note was removed.
+	Title string
+	// Notebook is the name of the note's notebook.
+	Notebook string
+	// Changes are the modified parts of the note, for example "title" and
+	// "content".
+	Changes []string
+	// OldTitle is the note's title in the old backup if it was renamed.
+	OldTitle string
+	// Content is the line diff of the note's Markdown content. Only set if
+	// requested and the content changed.
+	Content []string
+}
+
+// BackupDiff is the difference between two backups.
+type BackupDiff struct {
+	// Old is the manifest of the old backup.
+	Old *VaultManifest
+	// New is the manifest of the new backup.
+	New *VaultManifest
+	// Notes are the added, removed and modified notes.
+	Notes []*NoteDiff
+}
+
+// Count returns the number of notes with the status.
+func (d *BackupDiff) Count(status DiffStatus) int {
+	count := 0
+	for _, n := range d.Notes {
+		if n.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// backupNote is the part of a note that is compared.
+type backupNote struct {
+	title       string
+	notebook    string
+	tags        string
+	content     string
+	resources   string
+	md          string
+	contentDiff bool
+}
+
+func newBackupNote(m *VaultManifest, n *Note, withContent bool) *backupNote {
+	tags := n.Tags
+	if tags == nil {
+		tags = n.TagGUIDs
+	}
+	tags = append([]string{}, tags...)
+	sort.Strings(tags)
+	hashes := make([]string, len(n.Resources))
+	for i, r := range n.Resources {
+		if hashes[i] = r.Hash; hashes[i] == "" {
+			hashes[i] = resourceHash(r.Data)
+		}
+	}
+	sort.Strings(hashes)
+	b := &backupNote{
+		title:     n.Title,
+		notebook:  vaultNotebookName(m, n),
+		tags:      strings.Join(tags, ","),
+		content:   resourceHash([]byte(n.Body)),
+		resources: strings.Join(hashes, ","),
+	}
+	if withContent {
+		b.md = n.MD
+		b.contentDiff = true
+	}
+	return b
+}
+
+// DiffVaults compares the notes in two vaults and returns the added,
+// removed and modified notes. The notes are matched by their GUID. If
+// withContent is true, the line diff of the content of modified notes is
+// included. The old vault's notes are kept in memory without their
+// attachments while the new vault is read.
+func DiffVaults(old, new *VaultReader, withContent bool) (*BackupDiff, error) {
+	oldNotes := make(map[string]*backupNote)
+	for {
+		n, err := old.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		oldNotes[n.GUID] = newBackupNote(old.Manifest, n, withContent)
+	}
+	d := &BackupDiff{Old: old.Manifest, New: new.Manifest}
+	for {
+		n, err := new.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b := newBackupNote(new.Manifest, n, withContent)
+		o, ok := oldNotes[n.GUID]
+		if !ok {
+			d.Notes = append(d.Notes, &NoteDiff{Status: DiffAdded, GUID: n.GUID, Title: b.title, Notebook: b.notebook})
+			continue
+		}
+		delete(oldNotes, n.GUID)
+		if nd := diffBackupNotes(n.GUID, o, b); nd != nil {
+			d.Notes = append(d.Notes, nd)
+		}
+	}
+	for guid, o := range oldNotes {
+		d.Notes = append(d.Notes, &NoteDiff{Status: DiffRemoved, GUID: guid, Title: o.title, Notebook: o.notebook})
+	}
+	order := map[DiffStatus]int{DiffAdded: 0, DiffRemoved: 1, DiffModified: 2}
+	sort.SliceStable(d.Notes, func(i, j int) bool {
+		a, b := d.Notes[i], d.Notes[j]
+		if a.Status != b.Status {
+			return order[a.Status] < order[b.Status]
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+	return d, nil
+}
+
+// diffBackupNotes returns the changes to the note. Nil is returned if the
+// note hasn't changed.
+func diffBackupNotes(guid string, old, new *backupNote) *NoteDiff {
+	d := &NoteDiff{Status: DiffModified, GUID: guid, Title: new.title, Notebook: new.notebook}
+	if old.title != new.title {
+		d.Changes = append(d.Changes, "title")
+		d.OldTitle = old.title
+	}
+	if old.notebook != new.notebook {
+		d.Changes = append(d.Changes, "notebook")
+	}
+	if old.tags != new.tags {
+		d.Changes = append(d.Changes, "tags")
+	}
+	if old.content != new.content {
+		d.Changes = append(d.Changes, "content")
+		if new.contentDiff {
+			d.Content = DiffLines(old.md, new.md, 2)
+		}
+	}
+	if old.resources != new.resources {
+		d.Changes = append(d.Changes, "attachments")
+	}
+	if len(d.Changes) == 0 {
+		return nil
+	}
+	return d
+}
+
+// DiffLines returns a line diff of the texts. Removed lines are prefixed
+// with "-", added lines with "+" and unchanged lines with a space. Only
+// the given number of unchanged lines around the changes are included,
+// other unchanged lines are replaced by a "@@" line.
+func DiffLines(old, new string, context int) []string {
+	a, b := splitLines(old), splitLines(new)
+	// Trim the common prefix and suffix to keep the compared part small.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var lines []string
+	for _, l := range a[:prefix] {
+		lines = append(lines, " "+l)
+	}
+	lines = append(lines, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, " "+l)
+	}
+	return trimDiffContext(lines, context)
+}
+
+// diffMiddle returns the line diff based on the longest common subsequence.
+func diffMiddle(a, b []string) []string {
+	var lines []string
+	if len(a)*len(b) > maxLineDiffSize {
+		for _, l := range a {
+			lines = append(lines, "-"+l)
+		}
+		for _, l := range b {
+			lines = append(lines, "+"+l)
+		}
+		return lines
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	return lines
+}
+
+// trimDiffContext removes the unchanged lines that are further than
+// context lines from a change.
+func trimDiffContext(lines []string, context int) []string {
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l[0] == ' ' {
+			continue
+		}
+		for k := i - context; k <= i+context; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+	var out []string
+	skipped := false
+	for i, l := range lines {
+		if !keep[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			out = append(out, "@@")
+		}
+		skipped = false
+		out = append(out, l)
+	}
+	if skipped && len(out) != 0 {
+		out = append(out, "@@")
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// WriteBackupDiff writes the changed notes as a table followed by a
+// summary. The content diffs, if any, are written after the table.
+func WriteBackupDiff(w io.Writer, d *BackupDiff, opts TableOption) {
+	if len(d.Notes) == 0 {
+		fmt.Fprintln(w, "No differences found.")
+		return
+	}
+	table := NewTable(backupDiffHeader, opts)
+	table.SetShrinkOrder(3, 2, 1)
+	for _, n := range d.Notes {
+		details := strings.Join(n.Changes, ", ")
+		if n.OldTitle != "" {
+			details += fmt.Sprintf(" (was %q)", n.OldTitle)
+		}
+		table.Append([]string{string(n.Status), n.Title, n.Notebook, details})
+	}
+	table.Render(w)
+	theme := colorTheme(w, opts)
+	for _, n := range d.Notes {
+		if len(n.Content) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\n", theme.Heading.Paint("--- "+n.Title))
+		for _, l := range n.Content {
+			fmt.Fprintln(w, paintDiffLine(l, theme))
+		}
+	}
+	fmt.Fprintf(w, "\n%d added, %d removed, %d modified\n", d.Count(DiffAdded), d.Count(DiffRemoved), d.Count(DiffModified))
+}
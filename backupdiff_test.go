@@ -0,0 +1,96 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffVaults(t *testing.T) {
+	assert := assert.New(t)
+	nb := &Notebook{GUID: "nb"}
+	old := writeTestVault(t,
+		&Note{GUID: "1", Title: "Unchanged", Body: "<div>Same</div>", MD: "Same", Notebook: nb, Tags: []string{"a", "b"}},
+		&Note{GUID: "2", Title: "Removed", Notebook: nb},
+		&Note{GUID: "3", Title: "Old title", Body: "<div>One</div>", MD: "One\nTwo\nThree", Notebook: nb,
+			Resources: []*Resource{{Hash: resourceHash([]byte("a")), Data: []byte("a")}}},
+	)
+	new := writeTestVault(t,
+		&Note{GUID: "1", Title: "Unchanged", Body: "<div>Same</div>", MD: "Same", Notebook: nb, Tags: []string{"b", "a"}},
+		&Note{GUID: "3", Title: "New title", Body: "<div>Two</div>", MD: "One\n2\nThree", Notebook: nb, Tags: []string{"c"},
+			Resources: []*Resource{{Hash: resourceHash([]byte("b")), Data: []byte("b")}}},
+		&Note{GUID: "4", Title: "Added"},
+	)
+	open := func(data []byte) *VaultReader {
+		v, err := OpenVault(bytes.NewReader(data), "secret")
+		if !assert.NoError(err) {
+			t.FailNow()
+		}
+		return v
+	}
+
+	d, err := DiffVaults(open(old), open(new), true)
+	assert.NoError(err)
+	if assert.Len(d.Notes, 3) {
+		assert.Equal(&NoteDiff{Status: DiffAdded, GUID: "4", Title: "Added"}, d.Notes[0])
+		assert.Equal(&NoteDiff{Status: DiffRemoved, GUID: "2", Title: "Removed", Notebook: "Work"}, d.Notes[1])
+		assert.Equal(&NoteDiff{
+			Status:   DiffModified,
+			GUID:     "3",
+			Title:    "New title",
+			Notebook: "Work",
+			OldTitle: "Old title",
+			Changes:  []string{"title", "tags", "content", "attachments"},
+			Content:  []string{" One", "-Two", "+2", " Three"},
+		}, d.Notes[2])
+	}
+	assert.Equal(1, d.Count(DiffModified))
+
+	t.Run("Without content", func(t *testing.T) {
+		d, err := DiffVaults(open(old), open(new), false)
+		assert.NoError(err)
+		for _, n := range d.Notes {
+			assert.Empty(n.Content)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		var buf bytes.Buffer
+		WriteBackupDiff(&buf, d, 0)
+		out := buf.String()
+		assert.Contains(out, `title, tags, content, attachments (was "Old title")`)
+		assert.Contains(out, "--- New title\n One\n-Two\n+2\n Three\n")
+		assert.Contains(out, "1 added, 1 removed, 1 modified")
+
+		buf.Reset()
+		WriteBackupDiff(&buf, &BackupDiff{}, 0)
+		assert.Equal("No differences found.\n", buf.String())
+	})
+}
+
+func TestDiffLines(t *testing.T) {
+	assert := assert.New(t)
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9"
+	assert.Equal([]string{"@@", " 3", " 4", "-5", "+five", " 6", " 7", "@@"}, DiffLines(old, "1\n2\n3\n4\nfive\n6\n7\n8\n9", 2))
+	assert.Equal([]string{"+0", " 1", "@@"}, DiffLines(old, "0\n"+old, 1))
+	assert.Equal([]string{"-a", "+b", "+c"}, DiffLines("a", "b\nc", 2))
+	assert.Empty(DiffLines(old, old, 2))
+}
@@ -0,0 +1,279 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrAPIBudgetUsed is returned by the notestore calls made after the
+	// API call budget has been used up.
+	ErrAPIBudgetUsed = errors.New("the API call budget has been used up")
+	// ErrCheckpointNotSupported is returned if the storage can't save
+	// checkpoints.
+	ErrCheckpointNotSupported = errors.New("the storage can't save checkpoints")
+)
+
+// apiBudget is the number of API calls left, or -1 if there's no limit.
+var apiBudget int64 = -1
+
+// apiCalls is the number of API calls made with a budget set.
+var apiCalls int64
+
+// SetAPIBudget limits the number of API calls made by the notestores
+// wrapped with WithAPIBudget. A negative value removes the limit.
+func SetAPIBudget(max int) {
+	atomic.StoreInt64(&apiBudget, int64(max))
+	atomic.StoreInt64(&apiCalls, 0)
+}
+
+// APICalls returns the number of API calls made since the budget was set.
+func APICalls() int {
+	return int(atomic.LoadInt64(&apiCalls))
+}
+
+// IsAPIBudgetUsed returns true if the error, or the errors of a failed
+// fetch, are caused by the API call budget being used up.
+func IsAPIBudgetUsed(err error) bool {
+	if e, ok := err.(*FetchError); ok {
+		for _, err := range e.Errors {
+			if err == ErrAPIBudgetUsed {
+				return true
+			}
+		}
+		return false
+	}
+	return err == ErrAPIBudgetUsed
+}
+
+// takeAPICall uses one call of the budget. ErrAPIBudgetUsed is returned if
+// none is left.
+func takeAPICall() error {
+	for {
+		left := atomic.LoadInt64(&apiBudget)
+		if left < 0 {
+			return nil
+		}
+		if left == 0 {
+			return ErrAPIBudgetUsed
+		}
+		if atomic.CompareAndSwapInt64(&apiBudget, left, left-1) {
+			atomic.AddInt64(&apiCalls, 1)
+			return nil
+		}
+	}
+}
+
+// WithAPIBudget returns a notestore that counts the calls made to ns
+// against the budget set by SetAPIBudget. Once it's used up, the calls
+// fail with ErrAPIBudgetUsed without contacting the server. If no budget
+// is set, ns is returned.
+func WithAPIBudget(ns NotestoreClient) NotestoreClient {
+	if atomic.LoadInt64(&apiBudget) < 0 {
+		return ns
+	}
+	return &budgetNotestore{ns: ns}
+}
+
+type budgetNotestore struct {
+	ns NotestoreClient
+}
+
+func (b *budgetNotestore) FindNotes(filter *NoteFilter, offset, count int) ([]*Note, error) {
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return b.ns.FindNotes(filter, offset, count)
+}
+
+func (b *budgetNotestore) FindNoteVersions(filter *NoteFilter, offset, count int) ([]*Note, error) {
+	if _, ok := b.ns.(NoteVersionFinder); !ok {
+		return nil, ErrNotSupported
+	}
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return findNoteVersions(b.ns, filter, offset, count)
+}
+
+func (b *budgetNotestore) GetNoteMetadata(guid string) (*Note, error) {
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return getNoteMetadata(b.ns, guid)
+}
+
+func (b *budgetNotestore) GetAllNotebooks() ([]*Notebook, error) {
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return b.ns.GetAllNotebooks()
+}
+
+func (b *budgetNotestore) GetNotebook(guid string) (*Notebook, error) {
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return b.ns.GetNotebook(guid)
+}
+
+func (b *budgetNotestore) CreateNotebook(book *Notebook, defaultNotebook bool) error {
+	if err := takeAPICall(); err != nil {
+		return err
+	}
+	return b.ns.CreateNotebook(book, defaultNotebook)
+}
+
+func (b *budgetNotestore) GetNoteContent(guid string) (string, error) {
+	if err := takeAPICall(); err != nil {
+		return "", err
+	}
+	return b.ns.GetNoteContent(guid)
+}
+
+func (b *budgetNotestore) UpdateNote(n *Note) error {
+	if err := takeAPICall(); err != nil {
+		return err
+	}
+	return b.ns.UpdateNote(n)
+}
+
+func (b *budgetNotestore) DeleteNote(guid string) error {
+	if err := takeAPICall(); err != nil {
+		return err
+	}
+	return b.ns.DeleteNote(guid)
+}
+
+func (b *budgetNotestore) CreateNote(n *Note) error {
+	if err := takeAPICall(); err != nil {
+		return err
+	}
+	return b.ns.CreateNote(n)
+}
+
+func (b *budgetNotestore) UpdateNotebook(book *Notebook) error {
+	if err := takeAPICall(); err != nil {
+		return err
+	}
+	return b.ns.UpdateNotebook(book)
+}
+
+func (b *budgetNotestore) GetSyncState() (*SyncState, error) {
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return b.ns.GetSyncState()
+}
+
+func (b *budgetNotestore) GetNoteResources(guid string) ([]*Resource, error) {
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return b.ns.GetNoteResources(guid)
+}
+
+func (b *budgetNotestore) GetNoteRecognition(guid string) ([]*Resource, error) {
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return GetNoteRecognition(b.ns, guid)
+}
+
+func (b *budgetNotestore) GetAllTags() ([]*Tag, error) {
+	if err := takeAPICall(); err != nil {
+		return nil, err
+	}
+	return b.ns.GetAllTags()
+}
+
+// Checkpoint records the progress of a long operation stopped before it
+// completed, so the next run can continue where it stopped.
+type Checkpoint struct {
+	// Name identifies the operation, including its arguments.
+	Name string
+	// Done are the GUIDs of the notes that have been handled.
+	Done []string
+	// Time is when the checkpoint was saved.
+	Time time.Time
+}
+
+// CheckpointStore is implemented by the storage backends that can save
+// checkpoints.
+type CheckpointStore interface {
+	// GetCheckpoint returns the checkpoint with the name. Nil is returned
+	// if there is none.
+	GetCheckpoint(name string) (*Checkpoint, error)
+	// SaveCheckpoint saves the checkpoint.
+	SaveCheckpoint(c *Checkpoint) error
+	// DeleteCheckpoint removes the checkpoint with the name.
+	DeleteCheckpoint(name string) error
+}
+
+// LoadCheckpoint returns the checkpoint with the name. An empty checkpoint
+// is returned if none is saved or the storage can't save checkpoints.
+func LoadCheckpoint(db Storager, name string) (*Checkpoint, error) {
+	if store, ok := db.(CheckpointStore); ok {
+		c, err := store.GetCheckpoint(name)
+		if err != nil || c != nil {
+			return c, err
+		}
+	}
+	return &Checkpoint{Name: name}, nil
+}
+
+// StoreCheckpoint saves the checkpoint.
+func StoreCheckpoint(db Storager, c *Checkpoint) error {
+	store, ok := db.(CheckpointStore)
+	if !ok {
+		return ErrCheckpointNotSupported
+	}
+	c.Time = time.Now()
+	return store.SaveCheckpoint(c)
+}
+
+// ClearCheckpoint removes the checkpoint once the operation is complete.
+func ClearCheckpoint(db Storager, name string) error {
+	if store, ok := db.(CheckpointStore); ok {
+		return store.DeleteCheckpoint(name)
+	}
+	return nil
+}
+
+// Pending returns the notes that haven't been handled.
+func (c *Checkpoint) Pending(notes []*Note) []*Note {
+	done := make(map[string]bool, len(c.Done))
+	for _, guid := range c.Done {
+		done[guid] = true
+	}
+	var pending []*Note
+	for _, n := range notes {
+		if !done[n.GUID] {
+			pending = append(pending, n)
+		}
+	}
+	return pending
+}
+
+// MarkDone records the notes as handled.
+func (c *Checkpoint) MarkDone(guids ...string) {
+	c.Done = append(c.Done, guids...)
+}
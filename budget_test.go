@@ -0,0 +1,105 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockCheckpointStore struct {
+	*mockStore
+	checkpoints map[string]*Checkpoint
+}
+
+func (m *mockCheckpointStore) GetCheckpoint(name string) (*Checkpoint, error) {
+	return m.checkpoints[name], nil
+}
+
+func (m *mockCheckpointStore) SaveCheckpoint(c *Checkpoint) error {
+	m.checkpoints[c.Name] = c
+	return nil
+}
+
+func (m *mockCheckpointStore) DeleteCheckpoint(name string) error {
+	delete(m.checkpoints, name)
+	return nil
+}
+
+func TestAPIBudget(t *testing.T) {
+	assert := assert.New(t)
+	defer SetAPIBudget(-1)
+	calls := 0
+	ns := &mockNS{getAllTags: func() ([]*Tag, error) { calls++; return nil, nil }}
+
+	assert.Equal(ns, WithAPIBudget(ns), "No budget should return the notestore")
+
+	SetAPIBudget(2)
+	budgeted := WithAPIBudget(ns)
+	_, err := budgeted.GetAllTags()
+	assert.NoError(err)
+	_, err = budgeted.GetAllTags()
+	assert.NoError(err)
+	_, err = budgeted.GetAllTags()
+	assert.Equal(ErrAPIBudgetUsed, err)
+	assert.Equal(2, calls, "The call over the budget should not be made")
+	assert.Equal(2, APICalls())
+
+	assert.True(IsAPIBudgetUsed(&FetchError{Errors: map[string]error{"a": ErrNoNoteFound, "b": ErrAPIBudgetUsed}}))
+	assert.False(IsAPIBudgetUsed(&FetchError{Errors: map[string]error{"a": ErrNoNoteFound}}))
+	assert.False(IsAPIBudgetUsed(nil))
+}
+
+func TestStampNotesBudget(t *testing.T) {
+	assert := assert.New(t)
+	defer SetAPIBudget(-1)
+	notes := []*Note{{GUID: "1", Title: "One"}, {GUID: "2", Title: "Two"}}
+	updated := 0
+	ns := &mockNS{
+		findNotes:  func(*NoteFilter, int, int) ([]*Note, error) { return notes, nil },
+		updateNote: func(*Note) error { updated++; return nil },
+	}
+	SetAPIBudget(2)
+	result, err := StampNotes(WithAPIBudget(ns), "query", &AttributeChanges{Author: "Me"}, 10, nil)
+	assert.Equal(ErrAPIBudgetUsed, err)
+	assert.Equal(1, updated)
+	assert.Len(result.Modified, 1)
+	assert.Empty(result.Failed, "The budget should stop the operation instead of failing notes")
+}
+
+func TestCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockCheckpointStore{mockStore: new(mockStore), checkpoints: make(map[string]*Checkpoint)}
+	notes := []*Note{{GUID: "1"}, {GUID: "2"}, {GUID: "3"}}
+
+	c, err := LoadCheckpoint(db, "export")
+	assert.NoError(err)
+	assert.Equal(notes, c.Pending(notes))
+	c.MarkDone("1", "3")
+	assert.NoError(StoreCheckpoint(db, c))
+	assert.False(db.checkpoints["export"].Time.IsZero())
+
+	c, err = LoadCheckpoint(db, "export")
+	assert.NoError(err)
+	assert.Equal([]*Note{notes[1]}, c.Pending(notes))
+
+	assert.NoError(ClearCheckpoint(db, "export"))
+	assert.Empty(db.checkpoints)
+	assert.Equal(ErrCheckpointNotSupported, StoreCheckpoint(new(mockStore), c))
+}
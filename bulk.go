@@ -0,0 +1,115 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import "errors"
+
+// DefaultBulkPageSize is the number of notes fetched per search request
+// by bulk operations.
+const DefaultBulkPageSize = 50
+
+// ErrNoAttributeChange is returned if no attribute changes were given.
+var ErrNoAttributeChange = errors.New("no attribute changes given")
+
+// AttributeChanges are the attributes set on notes by StampNotes. Empty
+// fields are left unchanged.
+type AttributeChanges struct {
+	// Author is the note author.
+	Author string
+	// Source describes how the note was created.
+	Source string
+	// SourceURL is the URL the note's content originates from.
+	SourceURL string
+}
+
+// IsEmpty returns true if no changes are set.
+func (c *AttributeChanges) IsEmpty() bool {
+	return c.Author == "" && c.Source == "" && c.SourceURL == ""
+}
+
+// apply sets the attributes on the note. It returns true if the note
+// was changed.
+func (c *AttributeChanges) apply(n *Note) bool {
+	changed := false
+	for _, f := range []struct {
+		val   string
+		field *string
+	}{
+		{c.Author, &n.Author},
+		{c.Source, &n.Source},
+		{c.SourceURL, &n.SourceURL},
+	} {
+		if f.val != "" && *f.field != f.val {
+			*f.field = f.val
+			changed = true
+		}
+	}
+	return changed
+}
+
+// BulkResult is the summary of a bulk operation.
+type BulkResult struct {
+	// Modified are the notes that were changed.
+	Modified []*Note
+	// Unchanged is the number of notes that already had the changes.
+	Unchanged int
+	// Failed are the notes that failed to be updated and the errors.
+	Failed map[*Note]error
+}
+
+// StampNotes sets the attributes on all the notes matching the search
+// query. The notes are fetched and updated one page at the time. Notes
+// that already have the attributes are not updated, so running the same
+// command again resumes an operation that was interrupted. A failed
+// update doesn't stop the other notes from being updated, but running out
+// of the API call budget does. The progress function is called after each
+// note, if not nil.
+func StampNotes(ns NotestoreClient, query string, changes *AttributeChanges, pageSize int, progress func(*Note)) (*BulkResult, error) {
+	if changes.IsEmpty() {
+		return nil, ErrNoAttributeChange
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultBulkPageSize
+	}
+	// Sort by created time since it's not changed by the updates.
+	filter := &NoteFilter{Words: query, Order: NoteFilterOrderCreated}
+	result := &BulkResult{Failed: make(map[*Note]error)}
+	for offset := 0; ; offset += pageSize {
+		notes, err := ns.FindNotes(filter, offset, pageSize)
+		if err != nil {
+			return result, err
+		}
+		for _, n := range notes {
+			if !changes.apply(n) {
+				result.Unchanged++
+			} else if err := saveChanges(ns, n, false, false); err == ErrAPIBudgetUsed {
+				return result, err
+			} else if err != nil {
+				result.Failed[n] = err
+			} else {
+				result.Modified = append(result.Modified, n)
+			}
+			if progress != nil {
+				progress(n)
+			}
+		}
+		if len(notes) < pageSize {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStampNotes(t *testing.T) {
+	assert := assert.New(t)
+	changes := &AttributeChanges{Author: "Jane", Source: "imported"}
+
+	t.Run("should update notes page by page", func(t *testing.T) {
+		var notes []*Note
+		for i := 0; i < 5; i++ {
+			notes = append(notes, &Note{Title: "Note", Notebook: &Notebook{}})
+		}
+		notes[1].Author, notes[1].Source = "Jane", "imported"
+		var offsets []int
+		var updated []*Note
+		ns := &mockNS{
+			findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+				assert.Equal("tag:imported", f.Words)
+				assert.Equal(NoteFilterOrderCreated, f.Order)
+				offsets = append(offsets, offset)
+				end := offset + count
+				if end > len(notes) {
+					end = len(notes)
+				}
+				return notes[offset:end], nil
+			},
+			updateNote: func(n *Note) error {
+				updated = append(updated, n)
+				if n == notes[3] {
+					return expectedError
+				}
+				return nil
+			},
+		}
+		processed := 0
+		result, err := StampNotes(ns, "tag:imported", changes, 2, func(*Note) { processed++ })
+		assert.NoError(err)
+		assert.Equal([]int{0, 2, 4}, offsets)
+		assert.Equal(5, processed)
+		assert.Len(updated, 4, "Notes with the attributes should not be updated")
+		assert.Equal([]*Note{notes[0], notes[2], notes[4]}, result.Modified)
+		assert.Equal(1, result.Unchanged)
+		assert.Equal(map[*Note]error{notes[3]: expectedError}, result.Failed)
+		assert.Equal("Jane", notes[0].Author)
+		assert.Equal("imported", notes[0].Source)
+	})
+	t.Run("should return search error", func(t *testing.T) {
+		ns := &mockNS{findNotes: func(*NoteFilter, int, int) ([]*Note, error) { return nil, expectedError }}
+		_, err := StampNotes(ns, "tag:imported", changes, 2, nil)
+		assert.Equal(expectedError, err)
+	})
+	t.Run("should require changes", func(t *testing.T) {
+		_, err := StampNotes(new(mockNS), "tag:imported", new(AttributeChanges), 2, nil)
+		assert.Equal(ErrNoAttributeChange, err)
+	})
+}
@@ -0,0 +1,248 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// maxCaptureTitleLength is the longest title, in characters, taken
+	// from the first line of a capture.
+	maxCaptureTitleLength = 60
+	// captureClaimTimeout is how long a capture claimed by a flush is
+	// left alone by other flushes. A flush that was stopped leaves its
+	// claims behind.
+	captureClaimTimeout = 5 * time.Minute
+)
+
+var (
+	// ErrOutboxNotSupported is returned if the storage can't hold the
+	// capture outbox.
+	ErrOutboxNotSupported = errors.New("storage can't save the capture outbox")
+	// ErrEmptyCapture is returned if the captured text is empty.
+	ErrEmptyCapture = errors.New("nothing to capture")
+)
+
+// Capture is text captured locally that is uploaded as a new note later.
+type Capture struct {
+	// ID identifies the capture in the outbox.
+	ID string
+	// Text is the captured text. It's saved as Markdown.
+	Text string
+	// Created is when the text was captured.
+	Created time.Time
+	// Error is the error from the last upload attempt.
+	Error string
+	// Flush identifies the flush uploading the capture. Empty if no flush
+	// is uploading it.
+	Flush string
+	// Claimed is when the flush started uploading the capture.
+	Claimed time.Time
+}
+
+// CaptureOutbox stores the captures waiting to be uploaded.
+type CaptureOutbox interface {
+	// GetCaptures returns the captures in the outbox.
+	GetCaptures() ([]*Capture, error)
+	// SaveCaptures replaces the captures in the outbox.
+	SaveCaptures([]*Capture) error
+}
+
+// AddCapture adds the text to the outbox. Nothing is sent to the server,
+// the capture is uploaded by FlushCaptures.
+func AddCapture(db Storager, text string) (*Capture, error) {
+	outbox, ok := db.(CaptureOutbox)
+	if !ok {
+		return nil, ErrOutboxNotSupported
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrEmptyCapture
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	c := &Capture{ID: id.String(), Text: text, Created: time.Now()}
+	captures, err := outbox.GetCaptures()
+	if err != nil {
+		return nil, err
+	}
+	if err = outbox.SaveCaptures(append(captures, c)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCaptures returns the captures waiting to be uploaded.
+func GetCaptures(db Storager) ([]*Capture, error) {
+	outbox, ok := db.(CaptureOutbox)
+	if !ok {
+		return nil, ErrOutboxNotSupported
+	}
+	return outbox.GetCaptures()
+}
+
+// FlushCaptures uploads the captures in the outbox as new notes in the
+// default notebook. The captures are claimed before they are uploaded so
+// flushes running at the same time, for example in the daemon and in a
+// background process, don't upload the same capture. Each uploaded
+// capture is removed from the outbox right away. The flush stops at the
+// first failed upload, the error is saved with the capture and returned.
+// The number of uploaded captures is returned.
+func FlushCaptures(db Storager, ns NotestoreClient) (int, error) {
+	outbox, ok := db.(CaptureOutbox)
+	if !ok {
+		return 0, ErrOutboxNotSupported
+	}
+	if IsDryRun(ns) {
+		return previewCaptures(db, outbox, ns)
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return 0, err
+	}
+	flush := id.String()
+	claimed, err := updateOutbox(outbox, func(c *Capture) bool {
+		if c.Flush == "" || time.Since(c.Claimed) > captureClaimTimeout {
+			c.Flush, c.Claimed = flush, time.Now()
+		}
+		return true
+	})
+	if err != nil || len(claimed) == 0 {
+		return 0, err
+	}
+	// Another flush may have claimed the captures at the same time, the
+	// last saved claim wins.
+	if claimed, err = outbox.GetCaptures(); err != nil {
+		return 0, err
+	}
+	notebook, err := captureNotebook(db, ns)
+	if err != nil {
+		updateOutbox(outbox, func(o *Capture) bool {
+			if o.Flush == flush {
+				o.Flush, o.Claimed = "", time.Time{}
+			}
+			return true
+		})
+		return 0, err
+	}
+	uploaded := 0
+	for _, c := range claimed {
+		if c.Flush != flush {
+			continue
+		}
+		n := &Note{Title: CaptureTitle(c), MD: c.Text, Notebook: notebook}
+		uploadErr := ApplyNotebookRules(db, ns, n)
+		if uploadErr == nil {
+			uploadErr = SaveNewNote(ns, n, false)
+		}
+		_, err = updateOutbox(outbox, func(o *Capture) bool {
+			if o.Flush != flush {
+				return true
+			}
+			if uploadErr == nil {
+				return o.ID != c.ID
+			}
+			// Release the claims so the next flush retries the captures.
+			o.Flush, o.Claimed = "", time.Time{}
+			if o.ID == c.ID {
+				o.Error = uploadErr.Error()
+			}
+			return true
+		})
+		if err != nil {
+			return uploaded, err
+		}
+		if uploadErr != nil {
+			return uploaded, uploadErr
+		}
+		uploaded++
+	}
+	return uploaded, nil
+}
+
+// captureNotebook returns the notebook set as the default for new notes.
+// Nil is returned if the account's default notebook should be used.
+func captureNotebook(db Storager, ns NotestoreClient) (*Notebook, error) {
+	settings, err := db.GetSettings()
+	if err != nil || settings.DefaultNotebook == "" {
+		return nil, err
+	}
+	return FindNotebook(db, ns, settings.DefaultNotebook)
+}
+
+// previewCaptures sends the captures to the dry run notestore without
+// claiming or removing them from the outbox.
+func previewCaptures(db Storager, outbox CaptureOutbox, ns NotestoreClient) (int, error) {
+	captures, err := outbox.GetCaptures()
+	if err != nil || len(captures) == 0 {
+		return 0, err
+	}
+	notebook, err := captureNotebook(db, ns)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range captures {
+		if err := SaveNewNote(ns, &Note{Title: CaptureTitle(c), MD: c.Text, Notebook: notebook}, false); err != nil {
+			return 0, err
+		}
+	}
+	return len(captures), nil
+}
+
+// updateOutbox calls the function for each capture in the outbox and saves
+// the captures it returns true for. The outbox is read right before it's
+// saved since captures may be added by other processes at any time.
+func updateOutbox(outbox CaptureOutbox, fn func(*Capture) bool) ([]*Capture, error) {
+	captures, err := outbox.GetCaptures()
+	if err != nil {
+		return nil, err
+	}
+	var kept []*Capture
+	for _, c := range captures {
+		if fn(c) {
+			kept = append(kept, c)
+		}
+	}
+	return kept, outbox.SaveCaptures(kept)
+}
+
+// CaptureTitle returns the title for the capture's note: the first line of
+// the text, shortened at a word boundary if it's too long.
+func CaptureTitle(c *Capture) string {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(c.Text), "\n", 2)[0])
+	line = strings.TrimLeft(line, "#*- ")
+	if line == "" {
+		return "Capture " + c.Created.Format(reminderTimeFormat)
+	}
+	if utf8.RuneCountInString(line) <= maxCaptureTitleLength {
+		return line
+	}
+	runes := []rune(line)[:maxCaptureTitleLength]
+	short := string(runes)
+	if i := strings.LastIndex(short, " "); i > maxCaptureTitleLength/2 {
+		short = short[:i]
+	}
+	return strings.TrimRight(short, " ,.;:") + "..."
+}
@@ -0,0 +1,121 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockOutboxStore struct {
+	*mockStore
+	captures []*Capture
+}
+
+func (m *mockOutboxStore) GetCaptures() ([]*Capture, error) {
+	// Return copies like a real storage so changes have to be saved.
+	var cpy []*Capture
+	for _, c := range m.captures {
+		c := *c
+		cpy = append(cpy, &c)
+	}
+	return cpy, nil
+}
+
+func (m *mockOutboxStore) SaveCaptures(captures []*Capture) error {
+	m.captures = captures
+	return nil
+}
+
+func TestCaptures(t *testing.T) {
+	assert := assert.New(t)
+	settings := new(Settings)
+	db := &mockOutboxStore{mockStore: &mockStore{getSettings: func() (*Settings, error) { return settings, nil }}}
+	var created []*Note
+	var createErr error
+	ns := &mockNS{createNote: func(n *Note) error {
+		if createErr != nil {
+			return createErr
+		}
+		created = append(created, n)
+		return nil
+	}}
+
+	t.Run("Not supported", func(t *testing.T) {
+		_, err := AddCapture(db.mockStore, "text")
+		assert.Equal(ErrOutboxNotSupported, err)
+	})
+
+	_, err := AddCapture(db, "  ")
+	assert.Equal(ErrEmptyCapture, err)
+	_, err = AddCapture(db, "Buy milk\nand bread")
+	assert.NoError(err)
+	_, err = AddCapture(db, "Call Bob")
+	assert.NoError(err)
+	assert.Len(db.captures, 2)
+	assert.Empty(created, "Capturing shouldn't upload")
+
+	t.Run("Failed upload", func(t *testing.T) {
+		createErr = errors.New("offline")
+		count, err := FlushCaptures(db, ns)
+		assert.Equal(createErr, err)
+		assert.Equal(0, count)
+		if assert.Len(db.captures, 2) {
+			assert.Equal("offline", db.captures[0].Error)
+			assert.Empty(db.captures[0].Flush, "Claims should be released")
+			assert.Empty(db.captures[1].Flush, "Claims should be released")
+		}
+		createErr = nil
+	})
+
+	t.Run("Claimed by another flush", func(t *testing.T) {
+		db.captures[1].Flush, db.captures[1].Claimed = "other", time.Now()
+		count, err := FlushCaptures(db, ns)
+		assert.NoError(err)
+		assert.Equal(1, count)
+		if assert.Len(created, 1) {
+			assert.Equal("Buy milk", created[0].Title)
+			assert.Contains(created[0].Body, "and bread")
+		}
+		assert.Len(db.captures, 1)
+	})
+
+	t.Run("Stale claim", func(t *testing.T) {
+		db.captures[0].Claimed = time.Now().Add(-2 * captureClaimTimeout)
+		count, err := FlushCaptures(db, ns)
+		assert.NoError(err)
+		assert.Equal(1, count)
+		assert.Empty(db.captures)
+		assert.Equal("Call Bob", created[1].Title)
+	})
+}
+
+func TestCaptureTitle(t *testing.T) {
+	assert := assert.New(t)
+	created := time.Date(2018, 3, 1, 12, 30, 0, 0, time.UTC)
+	assert.Equal("Idea", CaptureTitle(&Capture{Text: "\n# Idea\nMore text"}))
+	assert.Equal("Capture 2018-03-01 12:30", CaptureTitle(&Capture{Text: "---", Created: created}))
+	long := strings.Repeat("word ", 20)
+	title := CaptureTitle(&Capture{Text: long})
+	assert.True(strings.HasSuffix(title, "word..."), title)
+	assert.True(len(title) <= maxCaptureTitleLength+3, title)
+}
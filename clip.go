@@ -0,0 +1,375 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// maxClipPageSize is the largest page that is clipped.
+	maxClipPageSize = 10 << 20
+	// maxClipImageSize is the largest image added to a clipped note.
+	maxClipImageSize = 10 << 20
+	// maxClipImages is the maximum number of images added to a clipped note.
+	maxClipImages = 50
+)
+
+// ErrNoArticle is returned if no article content is found on the page.
+var ErrNoArticle = errors.New("no article content found on the page")
+
+var (
+	positiveClipPattern = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|story|text`)
+	negativeClipPattern = regexp.MustCompile(`(?i)ad-|banner|comment|footer|footnote|masthead|menu|meta|nav|promo|related|share|sidebar|social|sponsor|widget`)
+)
+
+// clipRemoved are the elements removed from the page before the article
+// is extracted.
+var clipRemoved = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Nav: true,
+	atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Iframe: true, atom.Svg: true, atom.Button: true, atom.Select: true,
+	atom.Input: true, atom.Textarea: true, atom.Object: true, atom.Embed: true,
+}
+
+// clipAllowed are the elements kept in the clipped note. Other elements
+// are replaced by their content.
+var clipAllowed = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Ul: true, atom.Ol: true,
+	atom.Li: true, atom.A: true, atom.B: true, atom.Strong: true, atom.I: true,
+	atom.Em: true, atom.U: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Code: true, atom.Br: true, atom.Hr: true, atom.Table: true,
+	atom.Thead: true, atom.Tbody: true, atom.Tr: true, atom.Td: true,
+	atom.Th: true, atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Sub: true,
+	atom.Sup: true,
+}
+
+// Clipper creates notes from web pages.
+type Clipper struct {
+	// Client is the HTTP client used to fetch the page and the images.
+	Client *http.Client
+	// NoImages skips the images on the page.
+	NoImages bool
+}
+
+// NewClipper returns a clipper using an HTTP client with a timeout.
+func NewClipper() *Clipper {
+	return &Clipper{Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Clip fetches the page and returns a note with the page's article. The
+// article is found with a readability heuristic that scores the elements by
+// the paragraphs they contain. Images in the article are downloaded and
+// added to the note as resources. The note's source URL is set to the page.
+// The note is not saved.
+func (c *Clipper) Clip(pageURL string) (*Note, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := c.fetch(pageURL, maxClipPageSize)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	title, article := ExtractArticle(doc)
+	if article == nil {
+		return nil, ErrNoArticle
+	}
+	if title == "" {
+		title = pageURL
+	}
+	n := &Note{Title: title, SourceURL: pageURL}
+	var body bytes.Buffer
+	c.writeENML(&body, article, base, n)
+	n.Body = body.String()
+	if strings.TrimSpace(n.Body) == "" {
+		return nil, ErrNoArticle
+	}
+	return n, nil
+}
+
+// SaveClip saves the clipped note in the notebook. If the notebook is nil,
+// the account's default notebook is used.
+func SaveClip(ns NotestoreClient, n *Note, notebook *Notebook, tags []string) error {
+	n.Notebook = notebook
+	if len(tags) != 0 {
+		n.Tags = tags
+	}
+	return SaveNewNote(ns, n, true)
+}
+
+func (c *Clipper) fetch(u string, limit int64) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "CLInote web clipper")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching %s failed: %s", u, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > limit {
+		return nil, "", fmt.Errorf("%s is larger than %s", u, FormatSize(limit))
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// ExtractArticle returns the title of the page and the element holding
+// the article. If no article is found, the body is returned.
+func ExtractArticle(doc *html.Node) (string, *html.Node) {
+	title := pageTitle(doc)
+	removeClipElements(doc)
+	scores := make(map[*html.Node]float64)
+	var body *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Body:
+				body = n
+			case atom.Article:
+				scores[n] += 10
+			case atom.P, atom.Pre, atom.Blockquote:
+				text := strings.TrimSpace(nodeText(n))
+				if len(text) >= 25 && n.Parent != nil {
+					score := 1 + float64(strings.Count(text, ",")) + minFloat(float64(len(text))/100, 3)
+					scores[n.Parent] += score
+					if n.Parent.Parent != nil {
+						scores[n.Parent.Parent] += score / 2
+					}
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	var best *html.Node
+	bestScore := 0.0
+	for n, score := range scores {
+		class := attr(n, "class") + " " + attr(n, "id")
+		if positiveClipPattern.MatchString(class) {
+			score += 25
+		}
+		if negativeClipPattern.MatchString(class) {
+			score -= 25
+		}
+		// Prefer elements with little link text, like the article
+		// content, over link lists.
+		if text := len(nodeText(n)); text > 0 {
+			score *= 1 - float64(linkTextLength(n))/float64(text)
+		}
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		best = body
+	}
+	return title, best
+}
+
+// pageTitle returns the og:title or the title element of the page.
+func pageTitle(doc *html.Node) string {
+	var title, ogTitle string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Title:
+				if title == "" {
+					title = strings.TrimSpace(nodeText(n))
+				}
+			case atom.Meta:
+				if attr(n, "property") == "og:title" {
+					ogTitle = strings.TrimSpace(attr(n, "content"))
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if ogTitle != "" {
+		return ogTitle
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+func removeClipElements(n *html.Node) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.CommentNode || (child.Type == html.ElementNode && clipRemoved[child.DataAtom]) {
+			n.RemoveChild(child)
+		} else {
+			removeClipElements(child)
+		}
+		child = next
+	}
+}
+
+// writeENML writes the content of the element as ENML. Images are added to
+// the note as resources.
+func (c *Clipper) writeENML(w *bytes.Buffer, n *html.Node, base *url.URL, note *Note) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			w.WriteString(escapeXML(child.Data))
+		case html.ElementNode:
+			c.writeElement(w, child, base, note)
+		}
+	}
+}
+
+func (c *Clipper) writeElement(w *bytes.Buffer, n *html.Node, base *url.URL, note *Note) {
+	switch {
+	case n.DataAtom == atom.Img:
+		c.writeImage(w, n, base, note)
+	case n.DataAtom == atom.Br || n.DataAtom == atom.Hr:
+		fmt.Fprintf(w, "<%s/>", n.Data)
+	case n.DataAtom == atom.A:
+		href, err := base.Parse(attr(n, "href"))
+		if err != nil || (href.Scheme != "http" && href.Scheme != "https") {
+			c.writeENML(w, n, base, note)
+			return
+		}
+		fmt.Fprintf(w, `<a href="%s">`, escapeXML(href.String()))
+		c.writeENML(w, n, base, note)
+		w.WriteString("</a>")
+	case clipAllowed[n.DataAtom]:
+		fmt.Fprintf(w, "<%s>", n.Data)
+		c.writeENML(w, n, base, note)
+		fmt.Fprintf(w, "</%s>", n.Data)
+	default:
+		c.writeENML(w, n, base, note)
+	}
+}
+
+// writeImage downloads the image and writes an en-media element for it.
+// Images that fail to download are left out.
+func (c *Clipper) writeImage(w *bytes.Buffer, n *html.Node, base *url.URL, note *Note) {
+	if c.NoImages || len(note.Resources) >= maxClipImages {
+		return
+	}
+	src, err := base.Parse(attr(n, "src"))
+	if err != nil || (src.Scheme != "http" && src.Scheme != "https") {
+		return
+	}
+	data, contentType, err := c.fetch(src.String(), maxClipImageSize)
+	if err != nil {
+		return
+	}
+	mimeType, _, _ := mime.ParseMediaType(contentType)
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+		if !strings.HasPrefix(mimeType, "image/") {
+			return
+		}
+	}
+	hash := resourceHash(data)
+	found := false
+	for _, r := range note.Resources {
+		found = found || r.Hash == hash
+	}
+	if !found {
+		note.Resources = append(note.Resources, &Resource{
+			Hash:     hash,
+			Mime:     mimeType,
+			Filename: path.Base(src.Path),
+			Size:     len(data),
+			Data:     data,
+		})
+	}
+	fmt.Fprintf(w, `<en-media type="%s" hash="%s"/>`, mimeType, hash)
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(nodeText(child))
+	}
+	return b.String()
+}
+
+func linkTextLength(n *html.Node) int {
+	if n.Type == html.ElementNode && n.DataAtom == atom.A {
+		return len(nodeText(n))
+	}
+	length := 0
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		length += linkTextLength(child)
+	}
+	return length
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	if err := xml.EscapeText(&b, []byte(s)); err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
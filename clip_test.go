@@ -0,0 +1,125 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const clipTestPage = `<html><head><title>Page title</title>
+<script>alert("x")</script></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<div class="sidebar"><p>Subscribe to our newsletter, it is really great.</p></div>
+<div class="post-content">
+<h2>Heading</h2>
+<p>This is the first paragraph of the article, with some text & more.</p>
+<p>The second paragraph has <a href="/other">a link</a> and an image.</p>
+<img src="/image.png">
+<img src="/missing.png">
+</div>
+<footer>Copyright</footer>
+</body></html>`
+
+var clipTestImage = []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a}
+
+func clipTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, clipTestPage)
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(clipTestImage)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClip(t *testing.T) {
+	assert := assert.New(t)
+	srv := clipTestServer()
+	defer srv.Close()
+
+	n, err := NewClipper().Clip(srv.URL + "/article")
+	assert.NoError(err)
+	assert.Equal("Page title", n.Title)
+	assert.Equal(srv.URL+"/article", n.SourceURL)
+	assert.Contains(n.Body, "<h2>Heading</h2>")
+	assert.Contains(n.Body, "with some text &amp; more.")
+	assert.Contains(n.Body, `<a href="`+srv.URL+`/other">a link</a>`)
+	assert.NotContains(n.Body, "alert")
+	assert.NotContains(n.Body, "newsletter")
+	assert.NotContains(n.Body, "Copyright")
+	assert.NotContains(n.Body, "<img")
+	if assert.Len(n.Resources, 1) {
+		r := n.Resources[0]
+		assert.Equal("image/png", r.Mime)
+		assert.Equal("image.png", r.Filename)
+		assert.Equal(clipTestImage, r.Data)
+		assert.Equal(resourceHash(clipTestImage), r.Hash)
+		assert.Contains(n.Body, `<en-media type="image/png" hash="`+r.Hash+`"/>`)
+	}
+
+	t.Run("no images", func(t *testing.T) {
+		c := NewClipper()
+		c.NoImages = true
+		n, err := c.Clip(srv.URL + "/article")
+		assert.NoError(err)
+		assert.Empty(n.Resources)
+		assert.NotContains(n.Body, "en-media")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := NewClipper().Clip(srv.URL + "/missing")
+		assert.Error(err)
+	})
+}
+
+func TestExtractArticleOGTitle(t *testing.T) {
+	assert := assert.New(t)
+	page := `<html><head><title>Site | Article</title>
+<meta property="og:title" content="Article"></head><body><p>Text</p></body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	assert.NoError(err)
+	title, article := ExtractArticle(doc)
+	assert.Equal("Article", title)
+	assert.NotNil(article)
+	assert.Equal("Text", strings.TrimSpace(nodeText(article)))
+}
+
+func TestSaveClip(t *testing.T) {
+	assert := assert.New(t)
+	var saved *Note
+	ns := &mockNS{createNote: func(n *Note) error {
+		saved = n
+		return nil
+	}}
+	nb := &Notebook{Name: "Clips"}
+	n := &Note{Title: "Page", Body: "<p>Text</p>"}
+	assert.NoError(SaveClip(ns, n, nb, []string{"web"}))
+	assert.Equal(nb, saved.Notebook)
+	assert.Equal([]string{"web"}, saved.Tags)
+	assert.Equal(XMLHeader+"<en-note><p>Text</p></en-note>", saved.Body)
+}
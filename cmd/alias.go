@@ -0,0 +1,165 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+const aliasSettingPrefix = "alias.cmd."
+
+// expandAlias returns the command line arguments with a user defined alias
+// replaced by the command it stands for. Built-in commands can't be
+// overridden.
+func expandAlias() []string {
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return args
+	}
+	if cmd, _, err := RootCmd.Find(args[:1]); err == nil && cmd != RootCmd {
+		return args
+	}
+	db, err := openStorage()
+	if err != nil {
+		return args
+	}
+	settings, err := db.GetSettings()
+	db.Close()
+	if err != nil || len(settings.Aliases) == 0 {
+		return args
+	}
+	expanded, err := clinote.ExpandAlias(settings.Aliases, args)
+	if err != nil {
+		fmt.Printf("Error when expanding the alias %s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+	return expanded
+}
+
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "List the command aliases.",
+	Long: `
+Alias lists the user defined shortcuts for command lines. The alias is
+replaced by its command before the command line is parsed, and any extra
+arguments are appended. For example:
+  clinote alias set wip "note find tag:wip"
+  clinote wip --count 5
+
+Built-in commands can't be used as aliases, and an alias can't refer to
+another alias. The aliases can also be set with "user set alias.cmd.NAME".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		settings, err := db.GetSettings()
+		if err != nil {
+			fmt.Println("Error when getting the settings:", err)
+			os.Exit(1)
+		}
+		clinote.WriteAliases(os.Stdout, settings, tableOptions(cmd))
+	},
+}
+
+var aliasSetCmd = &cobra.Command{
+	Use:   "set name \"command\"",
+	Short: "Add or change a command alias.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 || args[1] == "" {
+			cmd.Usage()
+			return
+		}
+		updateAlias(args[0], args[1])
+	},
+}
+
+var aliasRemoveCmd = &cobra.Command{
+	Use:   "remove name",
+	Short: "Remove a command alias.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		updateAlias(args[0], "")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(aliasSetCmd)
+	aliasCmd.AddCommand(aliasRemoveCmd)
+}
+
+// updateAlias sets the alias in the settings. An empty command removes it.
+func updateAlias(name, command string) {
+	db, err := openStorage()
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	if !setAlias(db, name, command) {
+		os.Exit(1)
+	}
+}
+
+// setAlias sets the alias in the settings and reports if it was saved.
+// An empty command removes the alias. Aliases shadowed by a command added
+// after they were set can still be removed.
+func setAlias(db clinote.Storager, name, command string) bool {
+	if err := clinote.ValidateAliasName(name); err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	if cmd, _, err := RootCmd.Find([]string{name}); command != "" && err == nil && cmd != RootCmd {
+		fmt.Printf("Error, %s is a command and can't be used as an alias\n", name)
+		return false
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return false
+	}
+	if settings.Aliases == nil {
+		settings.Aliases = make(map[string]string)
+	}
+	if _, ok := settings.Aliases[name]; !ok && command == "" {
+		fmt.Printf("Error, there's no alias named %s\n", name)
+		return false
+	}
+	if command == "" {
+		delete(settings.Aliases, name)
+	} else {
+		settings.Aliases[name] = command
+	}
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+		return false
+	}
+	return true
+}
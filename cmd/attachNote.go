@@ -0,0 +1,139 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var attachNoteCmd = &cobra.Command{
+	Use:   "attach",
+	Short: "List, remove and replace a note's attachments.",
+	Long: `
+Attach works with the attachments of a note. Attachments are given by
+their number in the list or by their hash.`,
+}
+
+var attachListCmd = &cobra.Command{
+	Use:   "list \"note title\"",
+	Short: "List the note's attachments.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note title has to be given.")
+			os.Exit(1)
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		db := client.GetConfig().Store()
+		_, resources, err := clinote.GetAttachments(db, ns, pickTitle(db, ns, args[0]))
+		if err != nil {
+			fmt.Println("Error when getting the attachments:", err)
+			os.Exit(1)
+		}
+		if len(resources) == 0 {
+			fmt.Println("The note has no attachments.")
+			return
+		}
+		clinote.WriteAttachmentListing(os.Stdout, resources, tableOptions(cmd))
+	},
+}
+
+var attachRemoveCmd = &cobra.Command{
+	Use:   "remove \"note title\" <number|hash>",
+	Short: "Remove an attachment from the note.",
+	Long: `
+Remove removes the attachment from the note. The attachment is also
+removed from the note content.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			fmt.Println("Error, a note title and an attachment have to be given.")
+			os.Exit(1)
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		db := client.GetConfig().Store()
+		r, err := clinote.RemoveAttachment(db, ns, pickTitle(db, ns, args[0]), args[1])
+		if err != nil && !reportQueued(err) {
+			fmt.Println("Error when removing the attachment:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Removed", attachmentName(r))
+	},
+}
+
+var attachReplaceCmd = &cobra.Command{
+	Use:   "replace \"note title\" <number|hash> file",
+	Short: "Replace an attachment with a file.",
+	Long: `
+Replace swaps the attachment for the file. The attachment keeps its
+place in the note content.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 3 {
+			fmt.Println("Error, a note title, an attachment and a file have to be given.")
+			os.Exit(1)
+		}
+		data, err := ioutil.ReadFile(args[2])
+		if err != nil {
+			fmt.Println("Error when reading the file:", err)
+			os.Exit(1)
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		db := client.GetConfig().Store()
+		r, err := clinote.ReplaceAttachment(db, ns, pickTitle(db, ns, args[0]), args[1], args[2], data)
+		if err != nil && !reportQueued(err) {
+			fmt.Println("Error when replacing the attachment:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Replaced with", attachmentName(r))
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(attachNoteCmd)
+	attachNoteCmd.AddCommand(attachListCmd)
+	attachNoteCmd.AddCommand(attachRemoveCmd)
+	attachNoteCmd.AddCommand(attachReplaceCmd)
+}
+
+func attachmentName(r *clinote.Resource) string {
+	if r.Filename != "" {
+		return r.Filename
+	}
+	return r.Hash
+}
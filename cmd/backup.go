@@ -0,0 +1,380 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/storage"
+	"github.com/spf13/cobra"
+)
+
+// backupFormats are the formats backups can be written in.
+var backupFormats = []*clinote.BackupFormat{clinote.ENEXBackup, storage.SnapshotBackup}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Work with backups.",
+	Long: `
+Backup run saves all the notes, with their attachments, to the backup
+folder and removes the oldest backups so only backup.keep backups are
+left. The backups are either a zip file with an ENEX file for each
+notebook or a compressed database snapshot, set by backup.format.
+
+Backups are made on a schedule with "backup schedule". The daemon makes
+the scheduled backups while it's running, otherwise run
+"backup run --if-due" from cron.
+
+Single notes are restored with "backup restore". Diff compares two
+vaults created with "vault create".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var backupDiffCmd = &cobra.Command{
+	Use:   "diff \"old vault\" \"new vault\"",
+	Short: "Show the notes that changed between two backups.",
+	Long: `
+Diff compares the notes in two vaults and lists the notes that were
+added, removed or modified. For modified notes, the changed parts are
+listed: the title, notebook, tags, content or attachments. Notes are
+matched by their GUID, so renamed notes are shown as modified.
+
+With --content, a line diff of the content of modified notes is written
+after the list. Both vaults are opened with the same passphrase.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		diffBackups(cmd, args[0], args[1])
+	},
+}
+
+var backupRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Make a backup of all notes.",
+	Long: `
+Run saves all the notes, with their content and attachments, to a new
+backup in the backup folder. The oldest backups are removed so at most
+backup.keep backups are kept.
+
+With --if-due, the backup is only made if it's scheduled and the
+interval has passed since the last backup, for example from cron:
+  0 * * * * clinote backup run --if-due`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runBackup(cmd)
+	},
+}
+
+var backupScheduleCmd = &cobra.Command{
+	Use:   "schedule [interval]",
+	Short: "Show or set how often backups are made.",
+	Long: `
+Schedule sets how often a backup is made, for example 1d for a daily
+backup or 12h. "off" turns the scheduled backups off. Without an
+interval, the schedule and the time of the last backup are shown.
+
+The daemon makes the scheduled backups while it's running.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			cmd.Usage()
+			return
+		}
+		scheduleBackup(args)
+	},
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list [backup]",
+	Short: "List the backups or the notes in a backup.",
+	Long: `
+List shows the backups in the backup folder, newest first. With a
+backup, given by its number in the list or its file, the notes in the
+backup are listed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			cmd.Usage()
+			return
+		}
+		listBackups(cmd, args)
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore \"note title\"",
+	Short: "Restore a note from a backup.",
+	Long: `
+Restore creates the note from the backup as a new note in the notebook
+it was backed up from. The notebook is created if it has been removed.
+The note is found by its title or GUID. If several notes in the backup
+have the title, use the GUID shown by "backup list".
+
+The newest backup is used unless another is given with --backup, by its
+number in "backup list" or its file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		restoreBackupNote(cmd, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupRunCmd)
+	backupCmd.AddCommand(backupScheduleCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupDiffCmd)
+	backupRunCmd.Flags().Bool("if-due", false, "Only make the backup if a scheduled backup is due.")
+	backupRunCmd.Flags().String("format", "", "Backup format, enex or snapshot.")
+	backupRunCmd.Flags().String("dir", "", "Folder to write the backup to.")
+	backupRestoreCmd.Flags().StringP("backup", "b", "", "Backup number or file to restore from.")
+	backupDiffCmd.Flags().Bool("passphrase-prompt", false, "Ask for the passphrase.")
+	backupDiffCmd.Flags().String("passphrase-file", "", "Read the passphrase from the file.")
+	backupDiffCmd.Flags().BoolP("content", "c", false, "Show the content changes of modified notes.")
+}
+
+func diffBackups(cmd *cobra.Command, oldFile, newFile string) {
+	withContent, _ := cmd.Flags().GetBool("content")
+	passphrase := vaultPassphrase(cmd, false)
+	var vaults []*clinote.VaultReader
+	for _, file := range []string{oldFile, newFile} {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Println("Error when opening the vault:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		v, err := clinote.OpenVault(f, passphrase)
+		if err != nil {
+			fmt.Printf("Error when opening %s: %s\n", file, err)
+			os.Exit(1)
+		}
+		vaults = append(vaults, v)
+	}
+	d, err := clinote.DiffVaults(vaults[0], vaults[1], withContent)
+	if err != nil {
+		fmt.Println("Error when reading the vaults:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Comparing %s with %s\n",
+		d.Old.Created.Format("2006-01-02 15:04"), d.New.Created.Format("2006-01-02 15:04"))
+	clinote.WriteBackupDiff(os.Stdout, d, tableOptions(cmd))
+}
+
+func runBackup(cmd *cobra.Command) {
+	ifDue, _ := cmd.Flags().GetBool("if-due")
+	formatName, _ := cmd.Flags().GetString("format")
+	dir, _ := cmd.Flags().GetString("dir")
+
+	client := defaultClient()
+	defer client.Close()
+	db := client.GetConfig().Store()
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		os.Exit(1)
+	}
+	if ifDue && !clinote.BackupDue(settings, time.Now()) {
+		return
+	}
+	if formatName != "" {
+		settings.BackupFormat = formatName
+	}
+	if dir != "" {
+		settings.BackupDir = dir
+	}
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	progress := func(done, total int) {
+		printProgress(fmt.Sprintf("Saved %d of %d notes", done, total), done == total)
+	}
+	if err = makeBackup(client.GetConfig(), db, ns, settings, progress); err != nil {
+		os.Exit(1)
+	}
+}
+
+// makeBackup writes a backup with the format and to the folder in the
+// settings and rotates the old backups. Errors are printed.
+func makeBackup(cfg clinote.Configuration, db clinote.Storager, ns clinote.NotestoreClient, settings *clinote.Settings, progress clinote.FetchProgress) error {
+	name, err := clinote.ParseBackupFormat(settings.BackupFormat)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return err
+	}
+	format, err := clinote.FindBackupFormat(backupFormats, name)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return err
+	}
+	dir := clinote.BackupDir(cfg, settings)
+	backup, err := clinote.RunBackup(db, ns, dir, format, time.Now(), progress)
+	if fetchErr, ok := err.(*clinote.FetchError); ok {
+		fmt.Printf("Failed to fetch %d of %d notes, they are not in the backup.\n", len(fetchErr.Errors), fetchErr.Total)
+	} else if err != nil {
+		fmt.Println("Error when making the backup:", err)
+		return err
+	}
+	fmt.Println("Backup saved to", backup.Path)
+	removed, err := clinote.RotateBackups(dir, clinote.BackupKeep(settings), backupFormats)
+	if err != nil {
+		fmt.Println("Error when removing old backups:", err)
+		return err
+	}
+	for _, b := range removed {
+		fmt.Println("Removed old backup", b.Path)
+	}
+	return nil
+}
+
+func scheduleBackup(args []string) {
+	db, err := openStorage()
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	if len(args) == 1 {
+		setSettingValue(db, "backup.interval", args[0])
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		os.Exit(1)
+	}
+	if settings.BackupInterval == 0 {
+		fmt.Println("Scheduled backups are off.")
+	} else {
+		fmt.Println("A backup is made every", settings.BackupInterval)
+	}
+	if !settings.LastBackup.IsZero() {
+		fmt.Println("Last backup:", settings.LastBackup.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+func listBackups(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		clinote.WriteBackupListing(os.Stdout, findBackups(), tableOptions(cmd))
+		return
+	}
+	r := openBackup(args[0])
+	defer r.Close()
+	var notes []*clinote.Note
+	for {
+		n, err := r.Next()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Println("Error when reading the backup:", err)
+				os.Exit(1)
+			}
+			break
+		}
+		// Only the title and notebook are listed.
+		n.Body, n.Resources = "", nil
+		notes = append(notes, n)
+	}
+	clinote.WriteBackupNoteListing(os.Stdout, notes, tableOptions(cmd))
+}
+
+func restoreBackupNote(cmd *cobra.Command, query string) {
+	from, _ := cmd.Flags().GetString("backup")
+	if from == "" {
+		from = "1"
+	}
+	r := openBackup(from)
+	n, err := clinote.FindBackupNote(r, query)
+	r.Close()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	if err = clinote.RestoreNote(client.GetConfig().Store(), ns, n); err != nil {
+		fmt.Println("Error when restoring the note:", err)
+		os.Exit(1)
+	}
+	if n.Notebook != nil {
+		fmt.Printf("Restored \"%s\" to %s.\n", n.Title, n.Notebook.Name)
+	} else {
+		fmt.Printf("Restored \"%s\".\n", n.Title)
+	}
+}
+
+// findBackups returns the backups in the backup folder.
+func findBackups() []*clinote.BackupFile {
+	cfg := new(clinote.DefaultConfig)
+	db, err := openStorage()
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	settings, err := db.GetSettings()
+	db.Close()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		os.Exit(1)
+	}
+	list, err := clinote.ListBackups(clinote.BackupDir(cfg, settings), backupFormats)
+	if err != nil {
+		fmt.Println("Error when listing the backups:", err)
+		os.Exit(1)
+	}
+	return list
+}
+
+// openBackup opens the backup given by its number in the backup list or
+// its file.
+func openBackup(arg string) clinote.BackupReader {
+	path := arg
+	if index, err := strconv.Atoi(arg); err == nil {
+		list := findBackups()
+		if len(list) == 0 {
+			fmt.Println("Error:", clinote.ErrNoBackups)
+			os.Exit(1)
+		}
+		if index < 1 || index > len(list) {
+			fmt.Println("Error index out-of-range")
+			os.Exit(1)
+		}
+		path = list[index-1].Path
+	}
+	r, err := clinote.OpenBackup(path, backupFormats)
+	if err != nil {
+		fmt.Println("Error when opening the backup:", err)
+		os.Exit(1)
+	}
+	return r
+}
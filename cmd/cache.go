@@ -0,0 +1,62 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Local cache functionality.",
+	Long:  `Local cache functionality.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache statistics.",
+	Long: `Shows the number of entries, the size and the number of evicted
+entries for each local cache. The size limit is set with
+"clinote user set cache.max-size".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		stats, err := db.GetCacheStats()
+		if err != nil {
+			fmt.Println("Error when getting the cache statistics:", err)
+			os.Exit(1)
+		}
+		clinote.WriteCacheStats(os.Stdout, stats, tableOptions(cmd))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+}
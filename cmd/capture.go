@@ -0,0 +1,187 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/daemon"
+	"github.com/TcM1911/clinote/storage"
+	"github.com/spf13/cobra"
+)
+
+var captureCmd = &cobra.Command{
+	Use:   "capture \"text\"",
+	Short: "Quickly capture text as a new note.",
+	Long: `
+Capture saves the text to a local outbox and returns right away, without
+waiting for the server. The captured text is uploaded as a new note in
+the background: by the daemon if it's running, otherwise by a clinote
+process started for it. Captures that fail to upload stay in the outbox
+and are uploaded by the next capture, "capture --flush" or "sync".
+
+The first line of the text is used as the title. Use - to read the text
+from stdin.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if flush, _ := cmd.Flags().GetBool("flush"); flush {
+			flushCaptures(true)
+			return
+		}
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			listCaptures(cmd)
+			return
+		}
+		if len(args) == 0 {
+			fmt.Println("Error, the text to capture has to be given.")
+			os.Exit(1)
+		}
+		text := strings.Join(args, " ")
+		if text == "-" {
+			data, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Println("Error when reading the text:", err)
+				os.Exit(1)
+			}
+			text = string(data)
+		}
+		capture(text)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(captureCmd)
+	captureCmd.Flags().Bool("flush", false, "Upload the captures in the outbox now.")
+	captureCmd.Flags().Bool("list", false, "List the captures waiting to be uploaded.")
+}
+
+// capture adds the text to the outbox. If the daemon isn't running, a
+// clinote process is started in the background to upload it.
+func capture(text string) {
+	if dryRunMode() {
+		// Show the note the capture would be uploaded as.
+		n := &clinote.Note{Title: clinote.CaptureTitle(&clinote.Capture{Text: text, Created: time.Now()}), MD: text}
+		clinote.SaveNewNote(clinote.NewDryRunNotestore(nil, os.Stdout), n, false)
+		return
+	}
+	if ephemeralMode() {
+		// The outbox is discarded when clinote exits.
+		uploadCapture(text)
+		return
+	}
+	cfgFolder := (new(clinote.DefaultConfig)).GetConfigFolder()
+	var db clinote.Storage
+	d, err := daemon.DialContext(cmdContext, daemon.SocketPath(cfgFolder))
+	if err == nil {
+		db = d.Storage(cfgFolder)
+	} else if db, err = storage.OpenBackend(cfgFolder); err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	_, err = clinote.AddCapture(db, text)
+	db.Close()
+	if err != nil {
+		fmt.Println("Error when capturing:", err)
+		os.Exit(1)
+	}
+	if d != nil {
+		// The daemon uploads the outbox.
+		return
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		args := []string{"capture", "--flush"}
+		if clinote.SafeMode {
+			// The upload runs the hooks unless it's in safe mode too.
+			args = append(args, "--safe")
+		}
+		flush := exec.Command(exe, args...)
+		if err = flush.Start(); err == nil {
+			err = flush.Process.Release()
+		}
+	}
+	if err != nil {
+		fmt.Println("Captured, but the upload couldn't be started:", err)
+		fmt.Println("Run \"clinote capture --flush\" to upload it.")
+	}
+}
+
+// uploadCapture adds the text to the outbox and uploads it right away.
+func uploadCapture(text string) {
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	db := client.GetConfig().Store()
+	if _, err = clinote.AddCapture(db, text); err != nil {
+		fmt.Println("Error when capturing:", err)
+		os.Exit(1)
+	}
+	if _, err = clinote.FlushCaptures(db, ns); err != nil {
+		fmt.Println("Error when uploading the capture:", err)
+		os.Exit(1)
+	}
+}
+
+// flushCaptures uploads the captures in the outbox.
+func flushCaptures(verbose bool) {
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	count, err := clinote.FlushCaptures(client.GetConfig().Store(), ns)
+	if dryRunMode() {
+		fmt.Printf("Would upload %d captures.\n", count)
+	} else if verbose || count != 0 {
+		fmt.Printf("Uploaded %d captures.\n", count)
+	}
+	if err != nil {
+		fmt.Println("Error when uploading the captures:", err)
+		os.Exit(1)
+	}
+}
+
+func listCaptures(cmd *cobra.Command) {
+	db, err := openStorage()
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	captures, err := clinote.GetCaptures(db)
+	if err != nil {
+		fmt.Println("Error when reading the outbox:", err)
+		os.Exit(1)
+	}
+	if len(captures) == 0 {
+		fmt.Println("The outbox is empty.")
+		return
+	}
+	clinote.WriteCaptureListing(os.Stdout, captures, tableOptions(cmd))
+}
@@ -0,0 +1,92 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var clipCmd = &cobra.Command{
+	Use:   "clip <url>",
+	Short: "Create a note from a web page.",
+	Long: `
+Clip fetches the web page, extracts the article and saves it as a new
+note. Navigation, ads and other page clutter are removed and the
+article's images are added to the note as attachments. The page's URL
+is saved as the note's source URL.
+
+The note is saved to the default notebook unless --notebook is given.
+Tags can be added with --tags as a comma separated list.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a URL has to be given.")
+			return
+		}
+		tags, _ := cmd.Flags().GetString("tags")
+		nbName, _ := cmd.Flags().GetString("notebook")
+		noImages, _ := cmd.Flags().GetBool("no-images")
+		title, _ := cmd.Flags().GetString("title")
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		var nb *clinote.Notebook
+		if nbName != "" {
+			nb, err = clinote.FindNotebook(client.GetConfig().Store(), ns, nbName)
+			if err != nil {
+				fmt.Println("Error when getting the notebook:", err)
+				os.Exit(1)
+			}
+		}
+		clipper := clinote.NewClipper()
+		clipper.NoImages = noImages
+		n, err := clipper.Clip(args[0])
+		if err != nil {
+			fmt.Println("Error when clipping the page:", err)
+			os.Exit(1)
+		}
+		if title != "" {
+			n.Title = title
+		}
+		n.Notebook, n.Tags = nb, clinote.ParseTagList(tags)
+		if err = clinote.ApplyNotebookRules(client.GetConfig().Store(), ns, n); err != nil {
+			fmt.Println("Error when applying the notebook rules:", err)
+			os.Exit(1)
+		}
+		if err = clinote.SaveClip(ns, n, nb, n.Tags); err != nil {
+			fmt.Println("Error when saving the note:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %q with %d images.\n", n.Title, len(n.Resources))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(clipCmd)
+	clipCmd.Flags().StringP("tags", "t", "", "Comma separated list of tags to add to the note.")
+	clipCmd.Flags().StringP("notebook", "b", "", "The notebook to save the note in.")
+	clipCmd.Flags().String("title", "", "Use this title instead of the page's title.")
+	clipCmd.Flags().Bool("no-images", false, "Don't add the page's images to the note.")
+}
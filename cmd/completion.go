@@ -0,0 +1,235 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const completeCmdName = "__complete"
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish",
+	Short: "Generate a shell completion script.",
+	Long: `
+Completion writes a completion script for the shell to the standard out.
+Commands, flags, notebook names, tags and the note titles from the last
+search are completed. The names are read from the local database.
+
+Bash:
+  source <(clinote completion bash)
+
+Zsh:
+  source <(clinote completion zsh)
+
+Fish:
+  clinote completion fish | source`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		script, ok := completionScripts[args[0]]
+		if !ok {
+			fmt.Printf("Error, %s is not a supported shell.\n", args[0])
+			os.Exit(1)
+		}
+		fmt.Print(script)
+	},
+}
+
+// completeCmd is called by the completion scripts with the words on the
+// command line. The last word is the word being completed.
+var completeCmd = &cobra.Command{
+	Use:                completeCmdName,
+	Hidden:             true,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, c := range completeArgs(args) {
+			fmt.Println(c)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(completionCmd)
+	RootCmd.AddCommand(completeCmd)
+}
+
+// argCompletions returns the kind of value the commands take as arguments.
+func argCompletions() map[*cobra.Command]clinote.CompletionKind {
+	return map[*cobra.Command]clinote.CompletionKind{
+		noteCmd:           clinote.CompleteNotes,
+		editNoteCmd:       clinote.CompleteNotes,
+		deleteNoteCmd:     clinote.CompleteNotes,
+		noteMetaCmd:       clinote.CompleteNotes,
+		redactNoteCmd:     clinote.CompleteNotes,
+		remindersDoneCmd:  clinote.CompleteNotes,
+		editNotebookCmd:   clinote.CompleteNotebooks,
+		notebookConfigCmd: clinote.CompleteNotebooks,
+	}
+}
+
+// flagCompletions holds the kind of value the flags take.
+var flagCompletions = map[string]clinote.CompletionKind{
+	"notebook": clinote.CompleteNotebooks,
+	"tags":     clinote.CompleteTags,
+	"auto-tag": clinote.CompleteTags,
+}
+
+// completeArgs returns the completion candidates for the last argument.
+func completeArgs(args []string) []string {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	toComplete := strings.TrimLeft(args[len(args)-1], `"'`)
+	words := args[:len(args)-1]
+	cmd, _, err := RootCmd.Find(words)
+	if err != nil {
+		return nil
+	}
+	// Complete the value of the previous flag.
+	if len(words) > 0 {
+		if f := lookupFlag(cmd, words[len(words)-1]); f != nil && f.Value.Type() != "bool" {
+			return flagValueCandidates(f.Name, "", toComplete)
+		}
+	}
+	if strings.HasPrefix(toComplete, "-") {
+		if i := strings.Index(toComplete, "="); i != -1 {
+			if f := lookupFlag(cmd, toComplete[:i]); f != nil {
+				return flagValueCandidates(f.Name, toComplete[:i+1], toComplete[i+1:])
+			}
+			return nil
+		}
+		return flagCandidates(cmd, toComplete)
+	}
+	var candidates []string
+	for _, c := range cmd.Commands() {
+		if c.IsAvailableCommand() && strings.HasPrefix(c.Name(), toComplete) {
+			candidates = append(candidates, c.Name())
+		}
+	}
+	sort.Strings(candidates)
+	if kind, ok := argCompletions()[cmd]; ok {
+		candidates = append(candidates, localCandidates(kind, toComplete)...)
+	}
+	return candidates
+}
+
+// lookupFlag returns the flag for the command line word, for example
+// "--notebook" or "-b".
+func lookupFlag(cmd *cobra.Command, word string) *pflag.Flag {
+	var found *pflag.Flag
+	visitFlags(cmd, func(f *pflag.Flag) {
+		if word == "--"+f.Name || (f.Shorthand != "" && word == "-"+f.Shorthand) {
+			found = f
+		}
+	})
+	return found
+}
+
+func flagCandidates(cmd *cobra.Command, prefix string) []string {
+	var candidates []string
+	visitFlags(cmd, func(f *pflag.Flag) {
+		if name := "--" + f.Name; !f.Hidden && strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, name)
+		}
+	})
+	sort.Strings(candidates)
+	return candidates
+}
+
+// visitFlags calls fn for each local and inherited flag of the command.
+func visitFlags(cmd *cobra.Command, fn func(*pflag.Flag)) {
+	seen := make(map[string]bool)
+	visit := func(f *pflag.Flag) {
+		if !seen[f.Name] {
+			seen[f.Name] = true
+			fn(f)
+		}
+	}
+	cmd.Flags().VisitAll(visit)
+	cmd.InheritedFlags().VisitAll(visit)
+}
+
+func flagValueCandidates(name, prepend, prefix string) []string {
+	kind, ok := flagCompletions[name]
+	if !ok {
+		return nil
+	}
+	candidates := localCandidates(kind, prefix)
+	for i, c := range candidates {
+		candidates[i] = prepend + c
+	}
+	return candidates
+}
+
+// localCandidates returns the candidates from the local database. Errors
+// are ignored since nothing can be reported to the user while completing.
+func localCandidates(kind clinote.CompletionKind, prefix string) []string {
+	db, err := openStorage()
+	if err != nil {
+		return nil
+	}
+	defer db.Close()
+	candidates, _ := clinote.CompletionCandidates(db, kind, prefix)
+	return candidates
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for clinote
+_clinote() {
+    local c
+    COMPREPLY=()
+    while IFS= read -r c; do
+        COMPREPLY+=("$(printf '%q' "$c")")
+    done < <(clinote ` + completeCmdName + ` "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)
+}
+complete -F _clinote clinote
+`,
+	"zsh": `#compdef clinote
+# zsh completion for clinote
+_clinote() {
+    local -a candidates
+    candidates=("${(@f)$(clinote ` + completeCmdName + ` "${(@)words[2,$CURRENT]}" 2>/dev/null)}")
+    candidates=(${candidates:#})
+    compadd -a candidates
+}
+if [ "$funcstack[1]" = "_clinote" ]; then
+    _clinote "$@"
+else
+    compdef _clinote clinote
+fi
+`,
+	"fish": `# fish completion for clinote
+function __clinote_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    set -l cur (commandline -ct)
+    clinote ` + completeCmdName + ` $args "$cur" 2>/dev/null
+end
+complete -c clinote -f -a '(__clinote_complete)'
+`,
+}
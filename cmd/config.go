@@ -0,0 +1,160 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get and set values in the config file.",
+	Long: `
+The config file, config.toml in the config folder, holds settings that
+can be edited by hand. Values in the config file take precedence over
+the settings saved with "user set".
+
+The following keys are supported:
+  notebook.default    Notebook used for new notes.
+  sync.include        List of notebooks to sync, export and mirror.
+  sync.exclude        List of notebooks to skip.
+  cache.max-size      Size limit for the local caches, for example "500MB".
+  output.hyperlinks   Terminal hyperlinks, "auto", "on" or "off".
+  summarize.command   Command used by "note summarize".
+  transcribe.command  Command used by "note transcribe".
+  alias.cmd.<name>    A command alias.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Print the value of a setting.",
+	Long: `
+Get prints the value of the setting. The value is the config file value
+if set, otherwise the saved setting. If no key is given, all settings
+are printed. List values are printed comma separated.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			cmd.Usage()
+			return
+		}
+		getConfigValues(args)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set key value",
+	Short: "Set a value in the config file.",
+	Long: `
+Set writes the value to the config file. List values are given comma
+separated, for example:
+  clinote config set sync.include "Work,Projects"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		setConfigValue(args[0], args[1])
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in the editor.",
+	Long:  `Edit opens the config file with the editor defined by $EDITOR.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		editConfigFile()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+}
+
+func configFilePath() string {
+	return filepath.Join(new(clinote.DefaultConfig).GetConfigFolder(), clinote.ConfigFileName)
+}
+
+func getConfigValues(args []string) {
+	db, err := openStorage()
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	settings, err := db.GetSettings()
+	db.Close()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		os.Exit(1)
+	}
+	if len(args) == 1 {
+		val, err := clinote.GetConfigValue(settings, args[0])
+		if err != nil {
+			fmt.Printf("Error, %s: %s\n", err, args[0])
+			os.Exit(1)
+		}
+		fmt.Println(val)
+		return
+	}
+	for _, key := range clinote.ConfigKeys(settings) {
+		val, _ := clinote.GetConfigValue(settings, key)
+		fmt.Printf("%s = %s\n", key, val)
+	}
+}
+
+func setConfigValue(key, value string) {
+	cfg, err := clinote.LoadConfigFile(configFilePath())
+	if err != nil {
+		fmt.Println("Error when reading the config file:", err)
+		os.Exit(1)
+	}
+	if err = cfg.Set(key, value); err != nil {
+		fmt.Printf("Error when setting %s: %s\n", key, err)
+		os.Exit(1)
+	}
+	if err = cfg.Save(); err != nil {
+		fmt.Println("Error when saving the config file:", err)
+		os.Exit(1)
+	}
+}
+
+func editConfigFile() {
+	path := configFilePath()
+	if err := clinote.EditFile(path); err != nil {
+		fmt.Println("Error when editing the config file:", err)
+		os.Exit(1)
+	}
+	cfg, err := clinote.LoadConfigFile(path)
+	if err == nil {
+		err = cfg.Apply(new(clinote.Settings))
+	}
+	if err != nil {
+		fmt.Println("The config file has errors:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var copyNoteCmd = &cobra.Command{
+	Use:   "copy \"note title\"",
+	Short: "Copy a note to another account.",
+	Long: `
+Copy downloads the note, with its tags and attachments, and creates it
+in the account of another saved credential. The credential is given by
+its name or label with the to-profile flag. The note is created in the
+target's notebook given by the notebook flag, or in its default
+notebook. For example:
+  clinote note copy 2 --to-profile personal --notebook Inbox
+
+The move flag deletes the note from the active account after it has
+been copied.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 || len(args) == 0 && !interactive() {
+			fmt.Println("Error, a note title has to be given")
+			return
+		}
+		profile, _ := cmd.Flags().GetString("to-profile")
+		if profile == "" {
+			fmt.Println("Error, the target has to be given with --to-profile.")
+			return
+		}
+		notebook, _ := cmd.Flags().GetString("notebook")
+		move, _ := cmd.Flags().GetBool("move")
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get the notestore:", err)
+			os.Exit(1)
+		}
+		dst, err := migrationBackend(client, profile, false)
+		if err != nil {
+			fmt.Println("Error with the target:", err)
+			os.Exit(1)
+		}
+		if dst == client {
+			fmt.Println("Error, the target is the active credential.")
+			os.Exit(1)
+		}
+		if dryRunMode() {
+			dst.SetDryRun(os.Stdout)
+		}
+		dstNS, err := dst.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get the target notestore:", err)
+			os.Exit(1)
+		}
+		title, err := noteTitle(client.GetConfig().Store(), ns, args)
+		if err != nil {
+			fmt.Println("Error when picking the note:", err)
+			os.Exit(1)
+		}
+		copied, err := clinote.CopyNoteTo(client.GetConfig().Store(), ns, dstNS, title, notebook, move)
+		if err != nil && !reportQueued(err) {
+			fmt.Println("Error when copying the note:", err)
+			os.Exit(1)
+		}
+		if dryRunMode() {
+			return
+		}
+		fmt.Printf("Copied %q with %d attachments to %s.\n", copied.Title, len(copied.Resources), profile)
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(copyNoteCmd)
+	copyNoteCmd.Flags().String("to-profile", "", "Name or label of the credential to copy the note to.")
+	copyNoteCmd.Flags().StringP("notebook", "b", "", "Notebook in the target account.")
+	copyNoteCmd.Flags().Bool("move", false, "Delete the note from the active account after copying it.")
+}
@@ -0,0 +1,112 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var credsCmd = &cobra.Command{
+	Use:   "creds",
+	Short: "Manage the stored credentials.",
+	Long: `
+Manage the stored credentials. The active credential is used to talk
+to the server. Credentials are referenced by their number in the list.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var credsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the credentials.",
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err.Error())
+			os.Exit(1)
+		}
+		defer db.Close()
+		listCredentials(db, cmd)
+	},
+}
+
+var credsUseCmd = &cobra.Command{
+	Use:   "use <n>",
+	Short: "Make a credential active.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err.Error())
+			os.Exit(1)
+		}
+		defer db.Close()
+		setCredential(db, db, args[0])
+	},
+}
+
+var credsRenameCmd = &cobra.Command{
+	Use:   "rename <n> <label>",
+	Short: "Give a credential a label.",
+	Long: `
+Rename gives a credential a label that is shown instead of its name.
+An empty label removes it:
+  clinote user creds rename 2 ""`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			cmd.Usage()
+			return
+		}
+		index, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Printf("%s is not a number\n", args[0])
+			return
+		}
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err.Error())
+			os.Exit(1)
+		}
+		defer db.Close()
+		// Index is a 1 based index for the user.
+		cred, err := clinote.RenameCredential(db, db, index-1, strings.Join(args[1:], " "))
+		if err != nil {
+			fmt.Println("Error when renaming the credential:", err)
+			return
+		}
+		fmt.Printf("Credential %d is now shown as %q.\n", index, cred.DisplayName())
+	},
+}
+
+func init() {
+	userCmd.AddCommand(credsCmd)
+	credsCmd.AddCommand(credsListCmd)
+	credsCmd.AddCommand(credsUseCmd)
+	credsCmd.AddCommand(credsRenameCmd)
+	credsListCmd.Flags().Bool("show-secret", false, "Include credential secret in the output")
+}
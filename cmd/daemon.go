@@ -0,0 +1,162 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/daemon"
+	"github.com/TcM1911/clinote/storage"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run CLInote in the background.",
+	Long: `
+Daemon keeps the database and the connection to the server open and
+serves other CLInote commands over a unix socket. When the daemon is
+running, commands are sent to the daemon instead of opening the
+database and connecting to the server for every command.
+
+The daemon also makes the backups scheduled with "backup schedule".
+
+The daemon is stopped with Ctrl-C or by sending it SIGTERM. Restart
+the daemon after changing the active credential.
+
+With the http flag, the daemon also serves a health check on /healthz
+and Prometheus metrics on /metrics at the address, for example:
+  clinote daemon --http 127.0.0.1:9464`,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("http")
+		runDaemon(addr)
+	},
+}
+
+// captureFlushInterval is how often the daemon checks the outbox for
+// captures to upload.
+const captureFlushInterval = 10 * time.Second
+
+// backupCheckInterval is how often the daemon checks if a scheduled
+// backup is due.
+const backupCheckInterval = time.Minute
+
+func init() {
+	RootCmd.AddCommand(daemonCmd)
+	daemonCmd.Flags().String("http", "", "Address to serve /healthz and /metrics on.")
+}
+
+func runDaemon(httpAddr string) {
+	cfg := new(clinote.DefaultConfig)
+	db, err := storage.OpenBackend(cfg.GetConfigFolder())
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	onShutdown(func() { db.Close() })
+	recoverJournal(db)
+	configureNetwork(db)
+	cfg.DB = db
+	cfg.UDB = db
+	factory := func() (clinote.NotestoreClient, error) {
+		// A new client is created so credentials added after the daemon
+		// was started are used. The notes are encrypted and decrypted by
+		// the clients, so the raw notestore is served.
+		ns, err := newBackend(cfg).GetNoteStore()
+		if err != nil {
+			return nil, err
+		}
+		return clinote.RawNotestore(ns), nil
+	}
+	srv, err := daemon.NewServer(db, factory)
+	if err != nil {
+		fmt.Println("Error when creating the daemon:", err)
+		os.Exit(1)
+	}
+	socket := daemon.SocketPath(cfg.GetConfigFolder())
+	if err = srv.Listen(socket); err != nil {
+		fmt.Println("Error when starting the daemon:", err)
+		os.Exit(1)
+	}
+	var httpSrv *http.Server
+	if httpAddr != "" {
+		l, err := net.Listen("tcp", httpAddr)
+		if err != nil {
+			srv.Close()
+			fmt.Println("Error when starting the HTTP server:", err)
+			os.Exit(1)
+		}
+		httpSrv = &http.Server{Handler: srv.HTTPHandler()}
+		go httpSrv.Serve(l)
+		fmt.Println("Serving /healthz and /metrics on", l.Addr())
+	}
+	onShutdown(func() {
+		if httpSrv != nil {
+			httpSrv.Close()
+		}
+		srv.Close()
+	})
+	go flushCapturesLoop(db, cfg)
+	go backupLoop(db, cfg)
+	fmt.Println("Daemon listening on", socket)
+	if err = srv.Serve(); err != nil {
+		fmt.Println("Error when serving:", err)
+	}
+}
+
+// flushCapturesLoop uploads the captures added to the outbox while the
+// daemon is running.
+func flushCapturesLoop(db clinote.Storage, cfg *clinote.DefaultConfig) {
+	for range time.Tick(captureFlushInterval) {
+		captures, err := clinote.GetCaptures(db)
+		if err != nil || len(captures) == 0 {
+			continue
+		}
+		// Unlike the served notestore, this one encrypts notes saved to
+		// encrypted notebooks.
+		ns, err := newBackend(cfg).GetNoteStore()
+		if err == nil {
+			_, err = clinote.FlushCaptures(db, ns)
+		}
+		if err != nil {
+			fmt.Println("Error when uploading the captures:", err)
+		}
+	}
+}
+
+// backupLoop makes the scheduled backups while the daemon is running.
+func backupLoop(db clinote.Storage, cfg *clinote.DefaultConfig) {
+	for range time.Tick(backupCheckInterval) {
+		settings, err := db.GetSettings()
+		if err != nil || !clinote.BackupDue(settings, time.Now()) {
+			continue
+		}
+		ns, err := newBackend(cfg).GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when making the scheduled backup:", err)
+			continue
+		}
+		makeBackup(cfg, db, ns, settings, nil)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/daemon"
+	"github.com/TcM1911/clinote/storage"
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Maintain the local database.",
+	Long: `
+The local database holds the settings, the credentials and the caches.
+Long-lived caches make the database file grow, compact reclaims the
+unused space and verify checks that the data can be read.
+
+The daemon has to be stopped before the database is maintained.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var dbCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Reclaim the unused space in the database file.",
+	Long: `
+Compact copies the data to a new database file, which replaces the old
+one, leaving out the unused space. A SQLite database is vacuumed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		compactDB()
+	},
+}
+
+var dbVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the integrity of the database.",
+	Long: `
+Verify checks the database file and walks all the buckets to check that
+the values can be decoded. Cache entries not tracked by the cache index,
+and index entries without data, are reported as orphaned. With --fix,
+the orphaned entries are removed. If the cache index can't be decoded,
+--fix rebuilds it from the cache entries. The command exits with status
+1 if problems are left.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fix, _ := cmd.Flags().GetBool("fix")
+		verifyDB(fix)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbCompactCmd)
+	dbCmd.AddCommand(dbVerifyCmd)
+	dbVerifyCmd.Flags().Bool("fix", false, "Remove the orphaned cache entries and rebuild a broken cache index.")
+}
+
+// dbConfigFolder returns the config folder. The command exits if the
+// daemon is running since it keeps the database open.
+func dbConfigFolder() string {
+	cfgFolder := (new(clinote.DefaultConfig)).GetConfigFolder()
+	if d, err := daemon.Dial(daemon.SocketPath(cfgFolder)); err == nil {
+		d.Close()
+		fmt.Println("The daemon has to be stopped before the database can be maintained.")
+		os.Exit(1)
+	}
+	return cfgFolder
+}
+
+func compactDB() {
+	result, err := storage.Compact(dbConfigFolder())
+	if err != nil {
+		fmt.Println("Error when compacting the database:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Compacted the database from %s to %s.\n",
+		clinote.FormatSize(result.Before), clinote.FormatSize(result.After))
+}
+
+func verifyDB(fix bool) {
+	db, err := storage.OpenBackend(dbConfigFolder())
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	report, err := storage.Verify(db, fix)
+	if err != nil {
+		fmt.Println("Error when verifying the database:", err)
+		os.Exit(1)
+	}
+	for _, p := range report.Problems {
+		fmt.Println(p)
+	}
+	fmt.Printf("Checked %d keys in %d buckets, found %d problems.\n", report.Keys, report.Buckets, len(report.Problems))
+	if fix {
+		if report.IndexRebuilt {
+			fmt.Println("Rebuilt the cache index from the cache entries.")
+		}
+		fmt.Printf("Fixed %d problems.\n", report.Fixed)
+	}
+	if len(report.Problems) > report.Fixed {
+		db.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Tools for bug reports.",
+	Long:  `Tools for bug reports.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var debugCaptureCmd = &cobra.Command{
+	Use:   "capture \"note title\"",
+	Short: "Capture a note as a test fixture.",
+	Long: `
+Capture writes the note's content, metadata and the Markdown it is
+converted to as a JSON fixture. Attach the fixture to bug reports about
+the conversion, after editing the markdown field to the expected
+output. The fixture can be dropped in testdata/fixtures to add it to
+the converter tests.
+
+The anonymize flag scrambles all text, names, links and attachment
+hashes while keeping the markup, so the fixture can be shared without
+revealing the note's content:
+
+  clinote debug capture "note title" --anonymize -o fixture.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note has to be given.")
+			return
+		}
+		anonymize, err := cmd.Flags().GetBool("anonymize")
+		if err != nil {
+			fmt.Println("Error when parsing anonymize flag:", err)
+			return
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			fmt.Println("Error when parsing output flag:", err)
+			return
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		f, err := clinote.CaptureFixture(client.GetConfig().Store(), ns, args[0], anonymize)
+		if err != nil {
+			fmt.Println("Error when capturing the note:", err)
+			os.Exit(1)
+		}
+		var w io.Writer = os.Stdout
+		if output != "" {
+			file, err := os.Create(output)
+			if err != nil {
+				fmt.Println("Error when creating the file:", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			w = file
+		}
+		if err = clinote.WriteFixture(w, f); err != nil {
+			fmt.Println("Error when writing the fixture:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(debugCmd)
+	debugCmd.AddCommand(debugCaptureCmd)
+	debugCaptureCmd.Flags().Bool("anonymize", false, "Scramble the content and metadata.")
+	debugCaptureCmd.Flags().StringP("output", "o", "", "Write the fixture to the file instead of stdout.")
+}
@@ -0,0 +1,178 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe [notebook]",
+	Short: "Find and merge duplicate notes.",
+	Long: `
+Dedupe scans the synced notes, or the notes in the notebook, for
+duplicates. Notes are duplicates if they have the same title and similar
+content. For each group of duplicates you are asked what to do:
+
+  m   merge the duplicates into the oldest note
+  d   keep the oldest note and move the others to the trash
+  s   skip the group
+  q   quit
+
+Add the number of a note to keep it instead of the oldest, for example
+"m2". Merging adds the tags and the differing content of the duplicates
+to the kept note, attachments are not copied. The duplicates are moved
+to the trash.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dedupe(cmd, args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dedupeCmd)
+	dedupeCmd.Flags().Float64("similarity", clinote.DefaultDuplicateSimilarity, "Content similarity, between 0 and 1, for notes to be duplicates.")
+	dedupeCmd.Flags().Bool("list", false, "Only list the duplicates.")
+}
+
+func dedupe(cmd *cobra.Command, args []string) {
+	similarity, _ := cmd.Flags().GetFloat64("similarity")
+	if similarity <= 0 || similarity > 1 {
+		fmt.Println("Error, the similarity has to be between 0 and 1.")
+		os.Exit(1)
+	}
+	listOnly, _ := cmd.Flags().GetBool("list")
+	client := defaultClient()
+	defer client.Close()
+	db := client.GetConfig().Store()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	filter := &clinote.NoteFilter{Order: clinote.NoteFilterOrderCreated}
+	if len(args) > 0 {
+		book, err := clinote.FindNotebook(db, ns, args[0])
+		if err != nil {
+			fmt.Println("Error when getting the notebook:", err)
+			os.Exit(1)
+		}
+		filter.NotebookGUID = book.GUID
+	}
+	notes, err := clinote.FindAllNotes(ns, filter, clinote.DefaultBulkPageSize)
+	if err == nil && len(args) == 0 {
+		notes, err = clinote.FilterSelectedNotes(db, ns, notes)
+	}
+	if err != nil {
+		fmt.Println("Error when searching for notes:", err)
+		os.Exit(1)
+	}
+	fetched := 0
+	progress := func(*clinote.Note) {
+		fetched++
+		printProgress(fmt.Sprintf("Compared %d notes", fetched), false)
+	}
+	groups, err := clinote.FindDuplicates(db, ns, notes, similarity, progress)
+	if fetched > 0 && !a11yMode() {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		fmt.Println("Error when comparing notes:", err)
+		os.Exit(1)
+	}
+	if len(groups) == 0 {
+		fmt.Println("No duplicates found.")
+		return
+	}
+	nbs, err := clinote.GetNotebooks(db, ns, false)
+	if err != nil {
+		fmt.Println("Error when getting the notebooks:", err)
+		os.Exit(1)
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for i, g := range groups {
+		fmt.Printf("\nDuplicates %d of %d: %s\n", i+1, len(groups), g.Title)
+		clinote.WriteNoteListing(os.Stdout, g.Notes, nbs, tableOptions(cmd))
+		if listOnly {
+			continue
+		}
+		for {
+			fmt.Print("[m]erge, [d]elete duplicates, [s]kip or [q]uit? ")
+			if !scanner.Scan() {
+				return
+			}
+			action, keep, err := parseDedupeAnswer(scanner.Text(), len(g.Notes))
+			if err != nil {
+				fmt.Println("Error,", err)
+				continue
+			}
+			if action == 'q' {
+				return
+			}
+			if action != 's' {
+				resolveDuplicates(ns, g, action, keep)
+			}
+			break
+		}
+	}
+}
+
+// parseDedupeAnswer parses the answer to the dedupe prompt. It returns the
+// action and the index of the note to keep.
+func parseDedupeAnswer(answer string, count int) (byte, int, error) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "" || !strings.ContainsRune("mdsq", rune(answer[0])) {
+		return 0, 0, fmt.Errorf("unknown answer %q", answer)
+	}
+	keep := 0
+	if num := strings.TrimSpace(answer[1:]); num != "" {
+		i, err := strconv.Atoi(num)
+		if err != nil || i < 1 || i > count {
+			return 0, 0, fmt.Errorf("invalid note number %q", num)
+		}
+		keep = i - 1
+	}
+	return answer[0], keep, nil
+}
+
+func resolveDuplicates(ns clinote.NotestoreClient, g *clinote.DuplicateGroup, action byte, keep int) {
+	kept := g.Notes[keep]
+	var duplicates []*clinote.Note
+	for i, n := range g.Notes {
+		if i != keep {
+			duplicates = append(duplicates, n)
+		}
+	}
+	var err error
+	if action == 'm' {
+		err = clinote.MergeDuplicates(ns, kept, duplicates)
+	} else {
+		err = clinote.DeleteDuplicates(ns, duplicates)
+	}
+	if err != nil {
+		fmt.Println("Error when resolving the duplicates:", err)
+		return
+	}
+	fmt.Printf("Kept note %d, moved %d notes to the trash.\n", keep+1, len(duplicates))
+}
@@ -29,9 +29,11 @@ var deleteNoteCmd = &cobra.Command{
 	Use:   "delete \"note title\"",
 	Short: "Delete note.",
 	Long: `Moves the note into the trash. The note may still be undeleted, unless it is expunged.
-To expunge the note you need to use the official client or the web client.`,
+To expunge the note you need to use the official client or the web client.
+Without a note title, the note is picked from the last search result, or
+the recently updated notes, with fzf if it's installed.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) != 1 {
+		if len(args) > 1 || len(args) == 0 && !interactive() {
 			fmt.Println("Error, a note title has to be given")
 			return
 		}
@@ -46,8 +48,13 @@ To expunge the note you need to use the official client or the web client.`,
 		if err != nil {
 			return
 		}
-		err = clinote.DeleteNote(client.Config.Store(), ns, args[0], nb)
+		title, err := noteTitle(client.GetConfig().Store(), ns, args)
 		if err != nil {
+			fmt.Println("Error when picking the note:", err)
+			os.Exit(1)
+		}
+		err = clinote.DeleteNote(client.GetConfig().Store(), ns, title, nb)
+		if err != nil && !reportQueued(err) {
 			fmt.Println("Error when deleting the note:", err)
 			os.Exit(1)
 		}
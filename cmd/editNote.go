@@ -38,7 +38,16 @@ the note content.
 To change to title, the title flag can be used.
 
 The note can be moved to another notebook by defining the new notebook
-with the notebook flag.`,
+with the notebook flag, or by picking it with the pick-notebook flag.
+
+Without a note title, the note is picked from the last search result, or
+the recently updated notes, with fzf if it's installed.
+
+The encrypt-section flag replaces the first occurrence of the text in
+the note with a section encrypted with a passphrase, in the same format
+as the Evernote clients use. The passphrase is asked for, or read from
+the file given by passphrase-file. The note is not opened in the editor.
+  clinote note edit "note title" --encrypt-section "secret text" --hint "pet"`,
 	Run: func(cmd *cobra.Command, args []string) {
 		raw, err := cmd.Flags().GetBool("raw")
 		if err != nil {
@@ -71,29 +80,51 @@ with the notebook flag.`,
 			opts = opts | clinote.RawNote
 		}
 		if recover {
-			c := clinote.NewClient(client.Config, client.Config.Store(), ns, clinote.DefaultClientOptions)
+			c := clinote.NewClient(client.GetConfig(), client.GetConfig().Store(), ns, clinote.DefaultClientOptions)
 			err := clinote.EditNote(c, "", opts|clinote.UseRecoveryPointNote)
-			if err != nil {
+			if err == clinote.ErrNoChanges {
+				fmt.Println("No changes.")
+			} else if err != nil && !reportQueued(err) {
 				fmt.Println("Error when edit recovery note:", err)
 				os.Exit(1)
 			}
 			return
 		}
-		if len(args) != 1 {
+		if len(args) > 1 || len(args) == 0 && !interactive() {
 			fmt.Println("Error, a note has to be given.")
 			return
 		}
+		db := client.GetConfig().Store()
+		name, err := noteTitle(db, ns, args)
+		if err != nil {
+			fmt.Println("Error when picking the note:", err)
+			os.Exit(1)
+		}
+		if section, _ := cmd.Flags().GetString("encrypt-section"); section != "" {
+			encryptNoteSection(cmd, db, ns, name, section)
+			return
+		}
+		if pick, _ := cmd.Flags().GetBool("pick-notebook"); pick {
+			nb, err := selectNotebook(db, ns)
+			if err != nil {
+				fmt.Println("Error when picking the notebook:", err)
+				os.Exit(1)
+			}
+			notebook = nb.Name
+		}
 		if title != "" {
-			clinote.ChangeTitle(client.Config.Store(), ns, args[0], title)
+			clinote.ChangeTitle(db, ns, name, title)
 		}
 		if notebook != "" {
-			clinote.MoveNote(client.Config.Store(), ns, args[0], notebook)
+			clinote.MoveNote(db, ns, name, notebook)
 		}
 
 		if title == "" && notebook == "" {
-			c := clinote.NewClient(client.Config, client.Config.Store(), ns, clinote.DefaultClientOptions)
-			err := clinote.EditNote(c, args[0], opts)
-			if err != nil {
+			c := clinote.NewClient(client.GetConfig(), db, ns, clinote.DefaultClientOptions)
+			err := clinote.EditNote(c, name, opts)
+			if err == clinote.ErrNoChanges {
+				fmt.Println("No changes.")
+			} else if err != nil && !reportQueued(err) {
 				fmt.Println("Error when editing the note:", err)
 				os.Exit(1)
 			}
@@ -105,6 +136,35 @@ func init() {
 	noteCmd.AddCommand(editNoteCmd)
 	editNoteCmd.Flags().StringP("title", "t", "", "Change the note title to.")
 	editNoteCmd.Flags().StringP("notebook", "b", "", "Move the note to notebook.")
+	editNoteCmd.Flags().Bool("pick-notebook", false, "Pick the notebook to move the note to.")
 	editNoteCmd.Flags().Bool("raw", false, "Use raw content instead of markdown version.")
 	editNoteCmd.Flags().Bool("recover", false, "Recover previous note that failed to save.")
+	editNoteCmd.Flags().String("encrypt-section", "", "Encrypt the text in the note.")
+	editNoteCmd.Flags().String("hint", "", "Passphrase hint for the encrypted section.")
+	editNoteCmd.Flags().String("passphrase-file", "", "Read the passphrase for the encrypted section from the file.")
+}
+
+func encryptNoteSection(cmd *cobra.Command, db clinote.Storager, ns clinote.NotestoreClient, title, section string) {
+	hint, _ := cmd.Flags().GetString("hint")
+	passphrase := passphraseFile(cmd)
+	n, err := clinote.GetNoteWithContent(db, ns, title)
+	if err != nil {
+		fmt.Println("Error when getting the note:", err)
+		os.Exit(1)
+	}
+	if passphrase == "" {
+		passphrase, err = clinote.ReadPassphrase("Passphrase for the encrypted section: ", true)
+		if err != nil {
+			fmt.Println("Error when reading the passphrase:", err)
+			os.Exit(1)
+		}
+	}
+	if err = clinote.EncryptNoteSection(n, section, passphrase, hint); err != nil {
+		fmt.Println("Error when encrypting the section:", err)
+		os.Exit(1)
+	}
+	if err = clinote.SaveChanges(ns, n, clinote.RawNote); err != nil {
+		fmt.Println("Error when saving the note:", err)
+		os.Exit(1)
+	}
 }
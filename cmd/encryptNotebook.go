@@ -0,0 +1,119 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var encryptBookCmd = &cobra.Command{
+	Use:   "encrypt \"notebook name\"",
+	Short: "Encrypt the notes in a notebook before they are uploaded.",
+	Long: `
+Encrypt turns on client side encryption for the notebook. The content
+of the notes in the notebook is encrypted with a key derived from the
+passphrase before it's sent to the server, and decrypted when it's
+read. The key is saved with the credentials.
+
+The notes already in the notebook are encrypted. Run the command with
+the same passphrase on other computers to read the notes there. The
+passphrase is asked for, or read from the file given by passphrase-file.
+Encrypted notes can't be searched or read in other Evernote clients.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a notebook has to be given.")
+			return
+		}
+		encryptNotebook(cmd, args[0])
+	},
+}
+
+var decryptBookCmd = &cobra.Command{
+	Use:   "decrypt \"notebook name\"",
+	Short: "Turn off the encryption of a notebook.",
+	Long: `
+Decrypt decrypts the notes in the notebook, saves them to the server
+and removes the notebook's key.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a notebook has to be given.")
+			return
+		}
+		decryptNotebook(args[0])
+	},
+}
+
+func init() {
+	notebookCmd.AddCommand(encryptBookCmd)
+	notebookCmd.AddCommand(decryptBookCmd)
+	encryptBookCmd.Flags().String("passphrase-file", "", "Read the passphrase the notebook key is derived from from the file.")
+}
+
+func encryptNotebook(cmd *cobra.Command, name string) {
+	passphrase := passphraseFile(cmd)
+	client := defaultClient()
+	defer client.Close()
+	keys, ok := client.GetConfig().UserStore().(clinote.NotebookKeyStore)
+	if !ok {
+		fmt.Println("Error:", clinote.ErrKeyStoreNotSupported)
+		os.Exit(1)
+	}
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Failed to get notestore:", err)
+		os.Exit(1)
+	}
+	if passphrase == "" {
+		passphrase, err = clinote.ReadPassphrase("Passphrase for the notebook: ", true)
+		if err != nil {
+			fmt.Println("Error when reading the passphrase:", err)
+			os.Exit(1)
+		}
+	}
+	count, err := clinote.EncryptNotebook(client.GetConfig().Store(), keys, ns, name, passphrase)
+	if err != nil {
+		fmt.Println("Error when encrypting the notebook:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Notebook is encrypted, %d note(s) were encrypted.\n", count)
+}
+
+func decryptNotebook(name string) {
+	client := defaultClient()
+	defer client.Close()
+	keys, ok := client.GetConfig().UserStore().(clinote.NotebookKeyStore)
+	if !ok {
+		fmt.Println("Error:", clinote.ErrKeyStoreNotSupported)
+		os.Exit(1)
+	}
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Failed to get notestore:", err)
+		os.Exit(1)
+	}
+	count, err := clinote.DecryptNotebook(client.GetConfig().Store(), keys, ns, name)
+	if err != nil {
+		fmt.Println("Error when decrypting the notebook:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Notebook encryption is turned off, %d note(s) were decrypted.\n", count)
+}
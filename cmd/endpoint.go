@@ -0,0 +1,171 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var configEndpointCmd = &cobra.Command{
+	Use:   "endpoint [evernote|sandbox|yinxiang|host]",
+	Short: "Show or change the server a credential talks to.",
+	Long: `
+Endpoint shows the server the active credential talks to. If a service
+name or a host name is given, the credential is changed to use it:
+  evernote    Evernote's production server, www.evernote.com.
+  sandbox     Evernote's sandbox server, sandbox.evernote.com.
+  yinxiang    Yinxiang Biji, Evernote's service in China, app.yinxiang.com.
+
+An API key registered for the service can be used instead of CLInote's
+own key with --consumer-key and --consumer-secret. Use --credential to
+change another credential than the active one, for example:
+  clinote config endpoint yinxiang --credential 2`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			cmd.Usage()
+			return
+		}
+		configEndpoint(cmd, args)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configEndpointCmd)
+	configEndpointCmd.Flags().Int("credential", 0, "Index of the credential to change, defaults to the active credential")
+	configEndpointCmd.Flags().Bool("reset", false, "Use the server of the credential type and CLInote's API key")
+	addEndpointKeyFlags(configEndpointCmd)
+	addEndpointFlags(userAddCmd)
+	addEndpointFlags(loginCmd)
+}
+
+func addEndpointFlags(cmd *cobra.Command) {
+	cmd.Flags().String("endpoint", "", "Server to use: evernote, sandbox, yinxiang or a host name")
+	addEndpointKeyFlags(cmd)
+}
+
+func addEndpointKeyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("consumer-key", "", "API consumer key to use instead of CLInote's")
+	cmd.Flags().String("consumer-secret", "", "API consumer secret to use instead of CLInote's")
+}
+
+// parseEndpointFlags returns the endpoint given by the flags. The host is
+// taken from the endpoint flag if the command has one.
+func parseEndpointFlags(cmd *cobra.Command) (clinote.Endpoint, error) {
+	var ep clinote.Endpoint
+	if f := cmd.Flags().Lookup("endpoint"); f != nil {
+		host, err := clinote.ParseEndpointHost(f.Value.String())
+		if err != nil {
+			return ep, err
+		}
+		ep.Host = host
+	}
+	ep.ConsumerKey, _ = cmd.Flags().GetString("consumer-key")
+	ep.ConsumerSecret, _ = cmd.Flags().GetString("consumer-secret")
+	if (ep.ConsumerKey == "") != (ep.ConsumerSecret == "") {
+		return ep, fmt.Errorf("both --consumer-key and --consumer-secret are needed")
+	}
+	return ep, nil
+}
+
+func configEndpoint(cmd *cobra.Command, args []string) {
+	db, err := openStorage()
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	index, err := endpointCredentialIndex(cmd, db)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	cred, err := clinote.GetCredential(db, index)
+	if err != nil {
+		fmt.Println("Error when getting the credential:", err)
+		return
+	}
+	ep, err := parseEndpointFlags(cmd)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	reset, _ := cmd.Flags().GetBool("reset")
+	if len(args) == 0 && ep.ConsumerKey == "" && !reset {
+		printEndpoint(cmd, cred)
+		return
+	}
+	if !reset {
+		if len(args) == 1 {
+			if ep.Host, err = clinote.ParseEndpointHost(args[0]); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+		} else {
+			ep.Host = cred.Host
+		}
+		if ep.ConsumerKey == "" {
+			ep.ConsumerKey, ep.ConsumerSecret = cred.ConsumerKey, cred.ConsumerSecret
+		}
+	}
+	cred, err = clinote.SetCredentialEndpoint(db, db, index, ep)
+	if err != nil {
+		fmt.Println("Error when saving the endpoint:", err)
+		return
+	}
+	printEndpoint(cmd, cred)
+}
+
+// endpointCredentialIndex returns the index of the credential given by
+// the credential flag, or of the active credential.
+func endpointCredentialIndex(cmd *cobra.Command, db clinote.Storage) (int, error) {
+	if index, _ := cmd.Flags().GetInt("credential"); index != 0 {
+		// Index is a 1 based index for the user.
+		return index - 1, nil
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		return 0, err
+	}
+	creds, err := clinote.GetAllCredentials(db)
+	if err != nil {
+		return 0, err
+	}
+	for i, c := range creds {
+		if settings.Credential != nil && c.SameIdentity(settings.Credential) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no active credential, use --credential to select one")
+}
+
+func printEndpoint(cmd *cobra.Command, cred *clinote.Credential) {
+	key := "CLInote's"
+	if cred.ConsumerKey != "" {
+		key = cred.ConsumerKey
+	}
+	clinote.WriteFields(os.Stdout, [][2]string{
+		{"Credential", cred.Name},
+		{"Service", cred.ServiceName()},
+		{"Host", cred.APIHost()},
+		{"Consumer key", key},
+	}, tableOptions(cmd))
+}
@@ -0,0 +1,142 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var exportNoteCmd = &cobra.Command{
+	Use:   "export \"folder\"",
+	Short: "Export notes to a folder.",
+	Long: `
+Export fetches the notes matching the search filter and writes
+each note to a file in the folder. The notes are fetched
+concurrently, the number of workers can be set with the
+concurrency flag. Notes in notebooks that are not selected by the
+sync.include and sync.exclude settings are skipped.
+
+The files are named after the note titles in lower case, with
+everything but letters and digits replaced by dashes. If two notes
+get the same name, the start of the GUID is added. The names are
+saved in .clinote-export.json in the folder, so exporting to the
+folder again updates the files, also if a note's title has changed.
+
+If no search term is given, a wild card search will be used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a folder has to be given")
+			return
+		}
+		exportNotes(cmd, args[0])
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(exportNoteCmd)
+	exportNoteCmd.Flags().IntP("count", "c", 100, "How many notes to export.")
+	exportNoteCmd.Flags().StringP("search", "s", "", "Search term.")
+	exportNoteCmd.Flags().StringP("notebook", "b", "", "Restrict search to notebook.")
+	exportNoteCmd.Flags().Int("concurrency", clinote.DefaultConcurrency, "Number of notes to fetch at the same time.")
+	exportNoteCmd.Flags().Bool("raw", false, "Export raw content instead of markdown encoded.")
+	exportNoteCmd.Flags().Bool("all", false, "Ignore the sync.include and sync.exclude settings.")
+}
+
+func exportNotes(cmd *cobra.Command, folder string) {
+	count, _ := cmd.Flags().GetInt("count")
+	search, _ := cmd.Flags().GetString("search")
+	searchBook, _ := cmd.Flags().GetString("notebook")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	raw, _ := cmd.Flags().GetBool("raw")
+	all, _ := cmd.Flags().GetBool("all")
+	opts := clinote.DefaultNoteOption
+	if raw {
+		opts |= clinote.RawNote
+	}
+
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	filter := &clinote.NoteFilter{Words: search, Order: clinote.NoteFilterOrderUpdated}
+	if searchBook != "" {
+		book, err := clinote.FindNotebook(client.GetConfig().Store(), ns, searchBook)
+		if err != nil {
+			fmt.Println("Error when trying to filter by notebook:", err)
+			os.Exit(1)
+		}
+		filter.NotebookGUID = book.GUID
+	}
+	notes, err := clinote.FindNotes(ns, filter, 0, count)
+	stopIfBudgetUsed(err, nil, nil)
+	if err != nil {
+		fmt.Println("Error when searching for notes:", err)
+		os.Exit(1)
+	}
+	if !all {
+		notes, err = clinote.FilterSelectedNotes(client.GetConfig().Store(), ns, notes)
+		if err != nil {
+			fmt.Println("Error when filtering the notes:", err)
+			os.Exit(1)
+		}
+	}
+
+	db := client.GetConfig().Store()
+	name := fmt.Sprintf("export %q %q %q %d %t", folder, search, searchBook, count, raw)
+	checkpoint, err := clinote.LoadCheckpoint(db, name)
+	if err != nil {
+		fmt.Println("Error when loading the checkpoint:", err)
+		os.Exit(1)
+	}
+	if len(checkpoint.Done) != 0 {
+		fmt.Printf("Continuing the export, %d notes already exported.\n", len(checkpoint.Done))
+		notes = checkpoint.Pending(notes)
+	}
+
+	progress := func(done, total int) {
+		printProgress(fmt.Sprintf("Fetched %d of %d notes", done, total), done == total)
+	}
+	files, err := clinote.ExportNotes(client.NewNoteStore, notes, folder, concurrency, progress, opts)
+	for _, f := range files {
+		checkpoint.MarkDone(f.Note.GUID)
+	}
+	if len(files) != 0 {
+		tableOpts, _ := linkOptions(cmd, client.GetConfig())
+		clinote.WriteExportReport(os.Stdout, files, tableOpts)
+	}
+	stopIfBudgetUsed(err, db, checkpoint)
+	clinote.ClearCheckpoint(db, name)
+	if fetchErr, ok := err.(*clinote.FetchError); ok {
+		for _, n := range notes {
+			if e, ok := fetchErr.Errors[n.GUID]; ok {
+				fmt.Printf("Failed to fetch \"%s\": %s\n", n.Title, e)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Println("Error when exporting the notes:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var exportNotebookCmd = &cobra.Command{
+	Use:   "export \"notebook\"",
+	Short: "Export a notebook to an ENEX file.",
+	Long: `
+Export writes all the notes in the notebook, with their attachments,
+tags and attributes, to an ENEX file. ENEX files can be imported by
+Evernote and other note apps. Give "-" to write the file to stdout.
+
+  clinote notebook export "Work" --enex work.enex`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a notebook has to be given.")
+			return
+		}
+		path, _ := cmd.Flags().GetString("enex")
+		if path == "" {
+			fmt.Println("Error, the file has to be given with --enex.")
+			return
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		var w io.Writer = os.Stdout
+		if path != "-" {
+			f, err := os.Create(path)
+			if err != nil {
+				fmt.Println("Error when creating the file:", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+		count, err := clinote.ExportNotebookENEX(client.GetConfig().Store(), ns, args[0], w, version, func(done, total int) {
+			printProgress(fmt.Sprintf("Exported %d of %d notes", done, total), done == total)
+		})
+		if err != nil {
+			fmt.Println("Error when exporting the notebook:", err)
+			os.Exit(1)
+		}
+		if path != "-" {
+			fmt.Printf("Exported %d notes to %s.\n", count, path)
+		}
+	},
+}
+
+func init() {
+	notebookCmd.AddCommand(exportNotebookCmd)
+	exportNotebookCmd.Flags().String("enex", "", "The ENEX file to write.")
+}
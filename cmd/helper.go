@@ -1,34 +1,223 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/daemon"
 	"github.com/TcM1911/clinote/evernote"
+	"github.com/TcM1911/clinote/folder"
+	"github.com/TcM1911/clinote/joplin"
 	"github.com/TcM1911/clinote/storage"
+	"github.com/spf13/cobra"
 )
 
-func defaultClient() *evernote.Client {
+func defaultClient() clinote.NoteBackend {
+	client := openClient()
+	if dryRunMode() {
+		client.SetDryRun(os.Stdout)
+	}
+	return client
+}
+
+// openClient returns a client using the daemon if it's running. Otherwise
+// the storage backend is opened. The daemon isn't used when the API calls
+// are recorded or replayed.
+func openClient() clinote.NoteBackend {
 	cfg := &clinote.DefaultConfig{}
-	db, err := storage.Open(cfg.GetConfigFolder())
+	if useDaemon() && !ephemeralMode() {
+		if d, err := daemon.DialContext(cmdContext, daemon.SocketPath(cfg.GetConfigFolder())); err == nil {
+			db := d.Storage(cfg.GetConfigFolder())
+			cfg.DB = db
+			cfg.UDB = db
+			configureOutput(db)
+			warnCredentialExpiry(db)
+			warnStale(db)
+			return evernote.NewClientWithNotestore(cfg, d.Notestore())
+		}
+	}
+	db, err := openBackend(cfg.GetConfigFolder())
+	if err == storage.ErrDatabaseInUse {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
 	if err != nil {
 		panic("Error when opening the database: " + err.Error())
 	}
+	onShutdown(func() { db.Close() })
+	recoverJournal(db)
+	configureNetwork(db)
+	configureOutput(db)
 	cfg.DB = db
 	cfg.UDB = db
+	warnCredentialExpiry(db)
+	warnStale(db)
+	return newBackend(cfg)
+}
+
+// configureOutput sets the color theme and the note format from the
+// settings. The theme isn't set if the --theme flag is set or accessible
+// mode is on, and the format isn't set if the --format flag is set.
+func configureOutput(db clinote.Storager) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return
+	}
+	if noteFormat != "" {
+		if err = clinote.SetNoteFormat(noteFormat); err != nil {
+			fmt.Println("Error when parsing format flag:", err)
+			os.Exit(1)
+		}
+	} else if settings.Format != "" {
+		if err = clinote.SetNoteFormat(settings.Format); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: error in the format setting:", err)
+		}
+	}
+	if RootCmd.PersistentFlags().Changed("theme") || a11yMode() || settings.Theme == "" {
+		return
+	}
+	if err = clinote.SetOutputTheme(settings.Theme); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: error in the theme setting:", err)
+	}
+}
+
+// configureNetwork applies the proxy and TLS settings to the Evernote API
+// calls.
+func configureNetwork(db clinote.Storager) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		os.Exit(1)
+	}
+	if err = evernote.ConfigureNetwork(settings); err != nil {
+		fmt.Println("Error in the network settings:", err)
+		os.Exit(1)
+	}
+}
+
+// newBackend returns the client for the note service of the active
+// credential.
+func newBackend(cfg clinote.Configuration) clinote.NoteBackend {
+	cred, err := clinote.ActiveCredential(cfg.UserStore())
+	if err == nil && cred != nil {
+		switch cred.CredType {
+		case clinote.JoplinCredential, clinote.FolderCredential:
+			return backendFor(cfg, cred)
+		}
+	}
 	return evernote.NewClient(cfg)
 }
 
-func newClient(opts clinote.ClientOption) *clinote.Client {
-	cfg := new(clinote.DefaultConfig)
-	db, err := storage.Open(cfg.GetConfigFolder())
+// backendFor returns the client for the note service of the credential.
+func backendFor(cfg clinote.Configuration, cred *clinote.Credential) clinote.NoteBackend {
+	switch cred.CredType {
+	case clinote.JoplinCredential:
+		return joplin.NewClient(cfg, cred)
+	case clinote.FolderCredential:
+		return folder.NewClient(cfg, cred)
+	}
+	return evernote.NewClientWithCredential(cfg, cred)
+}
+
+// warnCredentialExpiry writes a warning to stderr if the active credential
+// expires soon or has expired.
+func warnCredentialExpiry(db clinote.Storage) {
+	cred, err := clinote.SessionCredential(db, db)
+	if err != nil || cred == nil {
+		return
+	}
+	settings, err := db.GetSettings()
 	if err != nil {
-		panic("Error when opening the database: " + err.Error())
+		return
 	}
-	cfg.DB = db
-	cfg.UDB = db
-	ec := evernote.NewClient(cfg)
+	days := settings.ExpiryWarning
+	if days == 0 {
+		days = clinote.DefaultExpiryWarning
+	}
+	if msg := clinote.ExpiryWarning(cred, time.Now(), days); msg != "" {
+		fmt.Fprintln(os.Stderr, "Warning:", msg)
+	}
+}
+
+// warnStale writes a warning to stderr if the last sync or backup is too
+// old. The warning is only written when stderr is a terminal so scripts
+// and hooks aren't affected.
+func warnStale(db clinote.Storager) {
+	if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	warnings, err := clinote.StaleWarnings(db, time.Now())
+	if err != nil {
+		return
+	}
+	for _, msg := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", msg)
+	}
+}
+
+// reportQueued prints a notice and returns true if the change was queued
+// because the server couldn't be reached.
+func reportQueued(err error) bool {
+	if err != clinote.ErrChangeQueued {
+		return false
+	}
+	fmt.Println("Offline:", err)
+	return true
+}
+
+func newClient(opts clinote.ClientOption) *clinote.Client {
+	ec := defaultClient()
 	ns, err := ec.GetNoteStore()
 	if err != nil {
 		panic("Error when getting notestore: " + err.Error())
 	}
-	return clinote.NewClient(cfg, db, ns, opts)
+	return clinote.NewClient(ec.GetConfig(), ec.GetConfig().Store(), ns, opts)
+}
+
+// openStorage returns the storage served by the daemon if it's running.
+// Otherwise the storage backend is opened, and closed if the program is
+// stopped by a signal so the pending cache writes are saved.
+func openStorage() (clinote.Storage, error) {
+	cfgFolder := (new(clinote.DefaultConfig)).GetConfigFolder()
+	if !ephemeralMode() {
+		if d, err := daemon.DialContext(cmdContext, daemon.SocketPath(cfgFolder)); err == nil {
+			return d.Storage(cfgFolder), nil
+		}
+	}
+	db, err := openBackend(cfgFolder)
+	if err != nil {
+		return nil, err
+	}
+	onShutdown(func() { db.Close() })
+	return db, nil
+}
+
+// openBackend opens the storage backend of the config folder. In ephemeral
+// mode an in-memory storage is returned instead.
+func openBackend(cfgFolder string) (clinote.Storage, error) {
+	if ephemeralMode() {
+		return storage.OpenEphemeral(cfgFolder)
+	}
+	return storage.OpenBackend(cfgFolder)
+}
+
+// passphraseFile returns the passphrase read from the file given by the
+// passphrase-file flag. An empty string is returned if the flag isn't set.
+// Passphrases aren't taken as flag values since the command line can be
+// read by other users and is saved in the shell history.
+func passphraseFile(cmd *cobra.Command) string {
+	file, _ := cmd.Flags().GetString("passphrase-file")
+	if file == "" {
+		return ""
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		fmt.Println("Error when reading the passphrase:", err)
+		os.Exit(1)
+	}
+	return strings.TrimRight(string(data), "\r\n")
 }
@@ -0,0 +1,59 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "List the hooks run before and after note changes.",
+	Long: `
+Hooks are scripts in the hooks folder of the config folder, named after
+the event they are run for. The events are:
+  pre-note-create, post-note-create
+  pre-note-edit, post-note-edit
+  pre-note-delete, post-note-delete
+
+The note's metadata is written as JSON to the script's stdin and passed
+in the environment variables CLINOTE_HOOK, CLINOTE_NOTE_GUID,
+CLINOTE_NOTE_TITLE and CLINOTE_NOTEBOOK. If a pre hook exits with an
+error, the change isn't made. The scripts must be executable, on Windows
+they need an .exe, .bat or .cmd extension. Hooks aren't run in safe mode
+or with --dry-run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Hooks folder:", clinote.HooksDir())
+		if clinote.SafeMode {
+			fmt.Println("Safe mode, the hooks are disabled.")
+		}
+		hooks := make(map[string]string, len(clinote.HookEvents))
+		for _, event := range clinote.HookEvents {
+			hooks[event] = clinote.FindHook(event)
+		}
+		clinote.WriteHookListing(os.Stdout, hooks, tableOptions(cmd))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(hooksCmd)
+}
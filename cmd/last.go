@@ -0,0 +1,64 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var lastCmd = &cobra.Command{
+	Use:   "last",
+	Short: "List the notes found by the last search again.",
+	Long: `
+Last lists the notes found by the last search, or the last reminder
+listing, with the same numbers. The numbers can be used instead of the
+note title by the other commands, for example:
+  clinote last
+  clinote note show 3
+
+Each credential keeps its own search result, so the numbers are kept
+when switching between credentials. A number always refers to the last
+search result, to find a note with a number as its title search for it
+first. Use "clinote note find --refresh" to update the result.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := defaultClient()
+		defer client.Close()
+		notes, err := client.GetConfig().Store().GetSearch()
+		if err != nil {
+			fmt.Println("Error when getting the last search:", err)
+			os.Exit(1)
+		}
+		if len(notes) == 0 {
+			fmt.Println("No saved search, search for notes with clinote note find.")
+			return
+		}
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		writeNoteList(cmd, client, ns, notes)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lastCmd)
+}
@@ -0,0 +1,70 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var noteLinkCmd = &cobra.Command{
+	Use:   "link \"from note\" \"to note\"",
+	Short: "Add a link to another note.",
+	Long: `
+Link adds a link to the second note at the end of the first note. The
+link opens the note in the Evernote app.
+
+The notes linking to a note are shown with "note --backlinks". The
+backlink index is updated by "sync".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			fmt.Println("Error, two notes have to be given.")
+			return
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		db := client.GetConfig().Store()
+		to := pickTitle(db, ns, args[1])
+		from := pickTitle(db, ns, args[0])
+		if err = clinote.LinkNote(db, ns, activeCredential(client.GetConfig()), from, to); err != nil {
+			fmt.Println("Error when linking the notes:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(noteLinkCmd)
+}
+
+// activeCredential returns the credential used for the session.
+func activeCredential(cfg clinote.Configuration) *clinote.Credential {
+	cred, err := clinote.SessionCredential(cfg.UserStore(), cfg.Store())
+	if err != nil || cred == nil {
+		return new(clinote.Credential)
+	}
+	return cred
+}
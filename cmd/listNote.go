@@ -21,14 +21,16 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/TcM1911/clinote"
 	"github.com/spf13/cobra"
 )
 
 var listNoteCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List note based on a search filter.",
+	Use:     "list [search query]",
+	Aliases: []string{"find"},
+	Short:   "List note based on a search filter.",
 	Long: `
 List returns a list of notes based on a search filter.
 The search term flag can be used to define a search term
@@ -39,7 +41,31 @@ Count can be used to restrict the maximum number of notes
 returned.
 
 If no search term is given, a wild card search will be used.
-The notes will be sorted by the modified time.`,
+The notes will be sorted by the modified time.
+
+The search uses the Evernote search grammar, for example
+notebook:Work, tag:urgent, intitle:plan, created:week-1 and
+resource:image/*. Terms starting with a minus are excluded. The
+search can also be given after the command:
+
+  clinote note find 'tag:work -tag:done created:month'
+
+The tag, intitle and resource flags are added to the search as
+grammar terms. The tag flag can be given more than once.
+
+The near flag restricts the result to notes with a location
+within the radius, in kilometers, of the given coordinates.
+The filter is applied to the returned notes, so count limits
+the number of notes searched.
+
+The created-after and created-before flags restrict the result
+to notes created in the period. They accept dates like
+2024-06-15, 15/06/2024 in the locale's order, yesterday,
+"last monday" and "2 weeks ago".
+
+The refresh flag runs the last search again. Only the notes that
+have changed since the last search are downloaded, the others are
+taken from the saved result. The other flags are ignored.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		findNotes(cmd, args)
 	},
@@ -50,12 +76,24 @@ func init() {
 	listNoteCmd.Flags().IntP("count", "c", 20, "How many notes to show in the result.")
 	listNoteCmd.Flags().StringP("search", "s", "", "Search term.")
 	listNoteCmd.Flags().StringP("notebook", "b", "", "Restrict search to notebook.")
+	listNoteCmd.Flags().StringSlice("tag", nil, "Only show notes with the tag, end with * to match a prefix.")
+	listNoteCmd.Flags().String("intitle", "", "Only show notes with the text in the title.")
+	listNoteCmd.Flags().String("resource", "", "Only show notes with an attachment of the mime type, like image/*.")
+	listNoteCmd.Flags().String("near", "", "Only show notes within \"latitude,longitude,radius\", radius in km.")
+	listNoteCmd.Flags().String("created-after", "", "Only show notes created after, for example \"2 weeks ago\".")
+	listNoteCmd.Flags().String("created-before", "", "Only show notes created before, for example yesterday.")
+	listNoteCmd.Flags().Bool("refresh", false, "Refresh the result of the last search.")
 }
 
 func findNotes(cmd *cobra.Command, args []string) {
 	client := defaultClient()
 	defer client.Close()
 
+	if refresh, _ := cmd.Flags().GetBool("refresh"); refresh {
+		refreshSearch(cmd, client)
+		return
+	}
+
 	// Create filter
 	filter := &clinote.NoteFilter{}
 	filter.Order = clinote.NoteFilterOrderUpdated
@@ -75,16 +113,46 @@ func findNotes(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	if search != "" {
-		filter.Words = search
+	near, err := cmd.Flags().GetString("near")
+	if err != nil {
+		fmt.Println("Error when parsing near filter:", err)
+		return
+	}
+	var center *clinote.Location
+	var radius float64
+	if near != "" {
+		if center, radius, err = clinote.ParseNearFilter(near); err != nil {
+			fmt.Println("Error when parsing near filter:", err)
+			os.Exit(1)
+		}
+	}
+
+	createdAfter, err := parseTimeFlag(cmd, "created-after")
+	if err != nil {
+		fmt.Println("Error when parsing created-after flag:", err)
+		os.Exit(1)
+	}
+	createdBefore, err := parseTimeFlag(cmd, "created-before")
+	if err != nil {
+		fmt.Println("Error when parsing created-before flag:", err)
+		os.Exit(1)
 	}
+	query := &clinote.SearchQuery{
+		Words:         strings.TrimSpace(search + " " + strings.Join(args, " ")),
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+	}
+	query.Tags, _ = cmd.Flags().GetStringSlice("tag")
+	query.InTitle, _ = cmd.Flags().GetString("intitle")
+	query.Resource, _ = cmd.Flags().GetString("resource")
+	filter.Words = query.String()
 
 	ns, err := client.GetNoteStore()
 	if err != nil {
 		return
 	}
 	if searchBook != "" {
-		book, err := clinote.FindNotebook(client.Config.Store(), ns, searchBook)
+		book, err := clinote.FindNotebook(client.GetConfig().Store(), ns, searchBook)
 		if err != nil {
 			fmt.Println("Error when trying to filter by notebook: ", err)
 			os.Exit(1)
@@ -96,16 +164,43 @@ func findNotes(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = client.Config.Store().SaveSearch(list)
+	if center != nil {
+		list = clinote.FilterNotesNear(list, center, radius)
+	}
+	saved := &clinote.SavedSearch{Filter: *filter, Count: c, Near: center, Radius: radius}
+	err = clinote.SaveSearchResult(client.GetConfig().Store(), list, saved)
 	if err != nil {
 		log.Fatal(err)
 	}
+	writeNoteList(cmd, client, ns, list)
+}
+
+func refreshSearch(cmd *cobra.Command, client clinote.NoteBackend) {
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	result, err := clinote.RefreshSearch(client.GetConfig().Store(), ns)
+	if err != nil {
+		fmt.Println("Error when refreshing the search:", err)
+		os.Exit(1)
+	}
+	writeNoteList(cmd, client, ns, result.Notes)
+	if result.Unchanged {
+		fmt.Println("No changes since the last search.")
+	} else {
+		fmt.Printf("Downloaded %d of %d notes.\n", result.Fetched, len(result.Notes))
+	}
+}
 
-	nbs, err := clinote.GetNotebooks(client.Config.Store(), ns, false)
+func writeNoteList(cmd *cobra.Command, client clinote.NoteBackend, ns clinote.NotestoreClient, list []*clinote.Note) {
+	nbs, err := clinote.GetNotebooks(client.GetConfig().Store(), ns, false)
 	if err != nil {
 		fmt.Println("Failed to get all notebooks:", err)
 		return
 	}
 
-	clinote.WriteNoteListing(os.Stdout, list, nbs)
+	opts, cred := linkOptions(cmd, client.GetConfig())
+	clinote.WriteNoteListingWithLinks(os.Stdout, list, nbs, opts, cred)
 }
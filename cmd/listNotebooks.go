@@ -36,7 +36,7 @@ List notebooks returns all active notebooks.`,
 			fmt.Println(err)
 			return
 		}
-		listNotebooks(sync)
+		listNotebooks(sync, tableOptions(cmd))
 	},
 }
 
@@ -45,17 +45,17 @@ func init() {
 	listNotebooksCmd.Flags().BoolP("sync", "s", false, "Force a resync of notebooks from the server.")
 }
 
-func listNotebooks(sync bool) {
+func listNotebooks(sync bool, opts clinote.TableOption) {
 	client := defaultClient()
 	defer client.Close()
 	ns, err := client.GetNoteStore()
 	if err != nil {
 		return
 	}
-	bs, err := clinote.GetNotebooks(client.Config.Store(), ns, sync)
+	bs, err := clinote.GetNotebooks(client.GetConfig().Store(), ns, sync)
 	if err != nil {
 		fmt.Println("Error when getting notebooks:", err)
 		os.Exit(1)
 	}
-	clinote.WriteNotebookListing(os.Stdout, bs)
+	clinote.WriteNotebookListing(os.Stdout, bs, opts)
 }
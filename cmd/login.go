@@ -19,8 +19,13 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 
+	"github.com/TcM1911/clinote"
 	"github.com/TcM1911/clinote/evernote"
+	"github.com/TcM1911/clinote/folder"
+	"github.com/TcM1911/clinote/joplin"
 	"github.com/spf13/cobra"
 )
 
@@ -28,11 +33,55 @@ var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login user.",
 	Long: `
-Login authorizes CLInote to the server using OAuth.`,
+Login authorizes CLInote to the server using OAuth. The authorization
+page is opened in the browser and a temporary server on localhost
+receives the answer, so nothing has to be copied. If no browser can be
+opened, the address is printed instead. Open it on any machine and
+paste the address you are redirected to if the page doesn't load. Use
+--endpoint to log in to Evernote's sandbox server or to Yinxiang Biji
+instead.
+
+On servers and in CI where a browser can't be used, log in with a
+developer token instead. Give "-" to read the token from stdin, which
+keeps it out of the process list and the shell history:
+  echo "$EVERNOTE_TOKEN" | clinote user login --token -
+
+To use Joplin instead, enable the web clipper service in the Joplin
+desktop app and log in with the token shown in its options. The app
+has to be running while clinote is used. --endpoint sets the address
+if the service doesn't use the default port:
+  clinote user login --joplin --token "$JOPLIN_TOKEN"
+
+To work on a local folder of Markdown files, for example notes kept
+in a git repository, log in with the folder. The filenames are the
+note titles and the subfolders are notebooks:
+  clinote user login --folder ~/notes`,
 	Run: func(cmd *cobra.Command, args []string) {
-		client := defaultClient()
-		defer client.Close()
-		err := evernote.Login(client)
+		ep, err := parseEndpointFlags(cmd)
+		if err != nil {
+			fmt.Println("Error when parsing the endpoint:", err)
+			return
+		}
+		backend := defaultClient()
+		defer backend.Close()
+		if dir, _ := cmd.Flags().GetString("folder"); dir != "" {
+			loginFolder(backend.GetConfig(), dir)
+			return
+		}
+		token, _ := cmd.Flags().GetString("token")
+		if useJoplin, _ := cmd.Flags().GetBool("joplin"); useJoplin {
+			loginJoplin(backend.GetConfig(), token, ep.Host)
+			return
+		}
+		if token != "" {
+			loginWithToken(backend.GetConfig(), token, ep)
+			return
+		}
+		client, ok := backend.(*evernote.Client)
+		if !ok || ep != (clinote.Endpoint{}) {
+			client = evernote.NewClientWithEndpoint(backend.GetConfig(), ep)
+		}
+		err = evernote.Login(client)
 		if err == nil {
 			fmt.Println("Authentication successful!")
 		} else {
@@ -43,4 +92,50 @@ Login authorizes CLInote to the server using OAuth.`,
 
 func init() {
 	userCmd.AddCommand(loginCmd)
+	loginCmd.Flags().String("token", "", "Log in with a developer token, \"-\" reads it from stdin")
+	loginCmd.Flags().Bool("joplin", false, "Log in to the Joplin desktop app with its web clipper token")
+	loginCmd.Flags().String("folder", "", "Use a local folder of Markdown files as the note store")
+}
+
+func loginFolder(cfg clinote.Configuration, dir string) {
+	if err := folder.Login(cfg, dir); err != nil {
+		fmt.Println("Error when setting the notes folder:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Using the notes folder", dir)
+}
+
+func loginJoplin(cfg clinote.Configuration, token, host string) {
+	if token == "" {
+		fmt.Println("Error, the token from Joplin's web clipper options has to be given with --token.")
+		os.Exit(1)
+	}
+	token = readToken(token)
+	if err := joplin.Login(cfg, token, host); err != nil {
+		fmt.Println("Authentication failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Authentication successful!")
+}
+
+func loginWithToken(cfg clinote.Configuration, token string, ep clinote.Endpoint) {
+	token = readToken(token)
+	if err := evernote.LoginWithToken(cfg, token, ep); err != nil {
+		fmt.Println("Authentication failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Authentication successful!")
+}
+
+// readToken reads the token from stdin if it's "-".
+func readToken(token string) string {
+	if token != "-" {
+		return token
+	}
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Println("Error when reading the token:", err)
+		os.Exit(1)
+	}
+	return string(data)
 }
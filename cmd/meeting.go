@@ -0,0 +1,111 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var meetingCmd = &cobra.Command{
+	Use:   "meeting",
+	Short: "Meeting notes.",
+	Long:  `Meeting notes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var meetingNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Create a meeting note from a calendar event.",
+	Long: `
+New creates a meeting note from an event in an iCalendar file, for
+example a meeting invite. The note is filled in with the title, time,
+location and attendees of the event and has sections for the agenda,
+notes and action items.
+
+If the file has more than one event, the event in progress or the next
+one is used. The file can also be a calendar folder, like the ones
+used by khal and vdirsyncer:
+  clinote meeting new --from-ics ~/.calendars/work
+
+The note is saved to the notebook set with "user set meeting.notebook",
+or the default notebook.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		newMeeting(cmd)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(meetingCmd)
+	meetingCmd.AddCommand(meetingNewCmd)
+	meetingNewCmd.Flags().String("from-ics", "", "iCalendar file or calendar folder to read the event from.")
+	meetingNewCmd.Flags().StringP("notebook", "b", "", "The notebook to save the note to.")
+	meetingNewCmd.Flags().BoolP("edit", "e", false, "Open note in the editor.")
+}
+
+func newMeeting(cmd *cobra.Command) {
+	path, err := cmd.Flags().GetString("from-ics")
+	if err != nil {
+		fmt.Println("Error when parsing from-ics flag:", err)
+		return
+	}
+	if path == "" {
+		fmt.Println("Error, an iCalendar file has to be given with --from-ics.")
+		return
+	}
+	notebook, err := cmd.Flags().GetString("notebook")
+	if err != nil {
+		fmt.Println("Error when parsing notebook flag:", err)
+		return
+	}
+	edit, err := cmd.Flags().GetBool("edit")
+	if err != nil {
+		fmt.Println("Error when parsing edit flag:", err)
+		return
+	}
+	events, err := clinote.ReadCalendarEvents(path)
+	if err != nil {
+		fmt.Println("Error when reading the calendar:", err)
+		return
+	}
+	event, err := clinote.NextEvent(events, time.Now())
+	if err != nil {
+		fmt.Println("Error when selecting the event:", err)
+		return
+	}
+	if notebook == "" {
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err)
+			return
+		}
+		settings, err := db.GetSettings()
+		db.Close()
+		if err != nil {
+			fmt.Println("Error when getting the settings:", err)
+			return
+		}
+		notebook = settings.MeetingNotebook
+	}
+	createNote(clinote.NewMeetingNote(event), notebook, edit, false)
+}
@@ -0,0 +1,214 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy all notes to another note service.",
+	Long: `
+Migrate copies the notebooks, notes, tags and attachments from one
+note service to another. The services are given as:
+
+  active        the active credential
+  evernote      a saved Evernote credential
+  joplin        a saved Joplin credential
+  folder:PATH   a folder of Markdown files, created if needed
+  NAME          the saved credential with the name
+
+For example:
+
+  clinote migrate --from evernote --to folder:~/notes
+
+Notebooks that already exist in the target are used instead of
+being created. Each notebook, note and tag is listed with its GUID
+in the source and the target. The report flag also writes the list
+to a tab separated file.
+
+The copied notes are recorded after each note, so if the migration
+is stopped, running the same command again continues with the
+remaining notes. Notes that fail are copied again on the next run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		reportFile, _ := cmd.Flags().GetString("report")
+		if to == "" {
+			fmt.Println("Error, the target has to be given with --to.")
+			return
+		}
+		client := defaultClient()
+		defer client.Close()
+		cfg := client.GetConfig()
+		src, err := migrationBackend(client, from, false)
+		if err != nil {
+			fmt.Println("Error with the source:", err)
+			os.Exit(1)
+		}
+		dst, err := migrationBackend(client, to, true)
+		if err != nil {
+			fmt.Println("Error with the target:", err)
+			os.Exit(1)
+		}
+		if dryRunMode() {
+			dst.SetDryRun(os.Stdout)
+		}
+		srcNS, err := src.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get the source notestore:", err)
+			os.Exit(1)
+		}
+		dstNS, err := dst.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get the target notestore:", err)
+			os.Exit(1)
+		}
+
+		db := cfg.Store()
+		name := fmt.Sprintf("migrate %q %q", from, to)
+		cp, err := clinote.LoadCheckpoint(db, name)
+		if err != nil {
+			fmt.Println("Error when loading the checkpoint:", err)
+			os.Exit(1)
+		}
+		if len(cp.Done) != 0 {
+			fmt.Printf("Continuing the migration, %d notes already copied.\n", len(cp.Done))
+		}
+		var report io.Writer = os.Stdout
+		if reportFile != "" {
+			f, err := openReport(reportFile)
+			if err != nil {
+				fmt.Println("Error when opening the report:", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			report = io.MultiWriter(os.Stdout, f)
+		}
+		// saved is the checkpoint saved if the API budget is used up.
+		saved := cp
+		if dryRunMode() {
+			// Nothing is copied so the checkpoint isn't saved.
+			db, saved = nil, nil
+		}
+		summary, err := clinote.Migrate(db, srcNS, dstNS, cp, func(item *clinote.MigrationItem) {
+			writeMigrationItem(report, item)
+		})
+		stopIfBudgetUsed(err, db, saved)
+		if err != nil {
+			fmt.Println("Error when migrating:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Copied %d notes with %d attachments, %d already copied. Created %d notebooks and %d tags, %d notebooks already existed.\n",
+			summary.Notes, summary.Attachments, summary.Skipped, summary.NotebooksCreated, summary.TagsCreated, summary.NotebooksMapped)
+		if summary.Failed != 0 {
+			fmt.Printf("%d notes failed, run the command again to retry them.\n", summary.Failed)
+			os.Exit(1)
+		}
+		if db != nil {
+			clinote.ClearCheckpoint(db, name)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().String("from", "active", "Note service to copy the notes from.")
+	migrateCmd.Flags().String("to", "", "Note service to copy the notes to.")
+	migrateCmd.Flags().String("report", "", "Append the list of copied items to the file.")
+}
+
+// migrationBackend returns the client for the note service. The active
+// client is reused for the active credential.
+func migrationBackend(active clinote.NoteBackend, spec string, create bool) (clinote.NoteBackend, error) {
+	cfg := active.GetConfig()
+	if strings.HasPrefix(spec, "folder:") {
+		dir := strings.TrimPrefix(spec, "folder:")
+		// The shell doesn't expand ~ after the prefix.
+		if strings.HasPrefix(dir, "~/") && os.Getenv("HOME") != "" {
+			dir = filepath.Join(os.Getenv("HOME"), dir[2:])
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if create {
+			if err = os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+		}
+		return backendFor(cfg, &clinote.Credential{Name: filepath.Base(dir), Secret: dir, CredType: clinote.FolderCredential}), nil
+	}
+	if spec == "" || spec == "active" {
+		return active, nil
+	}
+	creds, err := clinote.GetAllCredentials(cfg.UserStore())
+	if err != nil {
+		return nil, err
+	}
+	var found *clinote.Credential
+	for _, c := range creds {
+		if strings.EqualFold(c.Name, spec) || c.Label != "" && strings.EqualFold(c.Label, spec) {
+			found = c
+			break
+		}
+	}
+	if found == nil {
+		for _, c := range creds {
+			if strings.EqualFold(c.CredType.String(), spec) && (found == nil || c.Active) {
+				found = c
+			}
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no saved credential for %q", spec)
+	}
+	if found.Active {
+		return active, nil
+	}
+	return backendFor(cfg, found), nil
+}
+
+// openReport opens the report file for appending. The header is written
+// to new files.
+func openReport(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		fmt.Fprintln(f, "kind\tstatus\tname\tfrom\tto\terror")
+	}
+	return f, nil
+}
+
+func writeMigrationItem(w io.Writer, item *clinote.MigrationItem) {
+	msg := ""
+	if item.Err != nil {
+		msg = item.Err.Error()
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", item.Kind, item.Status, item.Name, item.From, item.To, msg)
+}
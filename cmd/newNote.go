@@ -19,6 +19,9 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
 
 	"github.com/TcM1911/clinote"
 	"github.com/spf13/cobra"
@@ -28,13 +31,26 @@ var newNoteCmd = &cobra.Command{
 	Use:   "new",
 	Short: "Create a new note.",
 	Long: `
-New creates a new note. A title needs to be given for the
-note.
+New creates a new note. If no title is given, the content is read
+from stdin, or written in the editor with the edit flag, and the title
+is taken from the first heading or the first line of the content:
+  echo "# Groceries" | clinote note new
+Turn it off with "user set note.auto-title off" to use "Untitled note"
+instead.
 
-If no notebook is given, the default notebook will be used.
+If no notebook is given, the notebook set in a .clinote.toml file
+in the current directory, or one of its parents, is used. Otherwise
+the notebook set with "user set notebook.default" is used. If neither
+is set, the account's default notebook will be used.
+
+Tags listed in the .clinote.toml file are added to the note.
 
 The new note can be open in the $EDITOR by using the edit
-flag.`,
+flag.
+
+The created and updated flags set the note's dates, for example
+when historical content is added. Dates between 1000-01-01 and
+9999-12-31 are accepted.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		title, err := cmd.Flags().GetString("title")
 		if err != nil {
@@ -46,9 +62,18 @@ flag.`,
 			fmt.Println("Error when parsing edit flag:", err)
 			return
 		}
+		var content string
 		if title == "" && !edit {
-			fmt.Println("Note title has to be given")
-			return
+			if clinote.IsTerminal(os.Stdin) {
+				fmt.Println("Note title has to be given")
+				return
+			}
+			data, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Println("Error when reading the content:", err)
+				os.Exit(1)
+			}
+			content = string(data)
 		}
 		notebook, err := cmd.Flags().GetString("notebook")
 		if err != nil {
@@ -60,7 +85,39 @@ flag.`,
 			fmt.Println("Error when parsing raw parameter:", err)
 			return
 		}
-		createNote(title, notebook, edit, raw)
+		location, err := cmd.Flags().GetString("location")
+		if err != nil {
+			fmt.Println("Error when parsing location:", err)
+			return
+		}
+		var loc *clinote.Location
+		if location != "" {
+			if loc, err = clinote.ParseLocation(location); err != nil {
+				fmt.Println("Error when parsing location:", err)
+				os.Exit(1)
+			}
+		}
+		created, err := parseTimeFlag(cmd, "created")
+		if err != nil {
+			fmt.Println("Error when parsing created time:", err)
+			os.Exit(1)
+		}
+		updated, err := parseTimeFlag(cmd, "updated")
+		if err != nil {
+			fmt.Println("Error when parsing updated time:", err)
+			os.Exit(1)
+		}
+		note := &clinote.Note{Title: title, Location: loc}
+		if raw {
+			note.Body = content
+		} else {
+			note.MD = content
+		}
+		if err = clinote.SetNoteTimes(note, created, updated); err != nil {
+			fmt.Println("Error when setting the note times:", err)
+			os.Exit(1)
+		}
+		createNote(note, notebook, edit, raw)
 	},
 }
 
@@ -70,17 +127,49 @@ func init() {
 	newNoteCmd.Flags().StringP("notebook", "b", "", "The notebook to save note to, if not set the default notebook will be used.")
 	newNoteCmd.Flags().BoolP("edit", "e", false, "Open note in the editor.")
 	newNoteCmd.Flags().Bool("raw", false, "Edit the content in raw mode.")
+	newNoteCmd.Flags().String("location", "", "Location of the note as \"latitude,longitude[,altitude]\".")
+	newNoteCmd.Flags().String("created", "", "Created time of the note, for example 2006-01-02, \"2006-01-02 15:04\" or yesterday.")
+	newNoteCmd.Flags().String("updated", "", "Updated time of the note. Defaults to the created time if only this is set.")
+}
+
+// findDirConfig returns the directory config for the current directory.
+func findDirConfig() (*clinote.DirConfig, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return clinote.FindDirConfig(wd)
 }
 
-func createNote(title, notebook string, edit, raw bool) {
+// parseTimeFlag parses the time flag. A zero time is returned if the flag
+// isn't set.
+func parseTimeFlag(cmd *cobra.Command, name string) (time.Time, error) {
+	val, err := cmd.Flags().GetString(name)
+	if err != nil || val == "" {
+		return time.Time{}, err
+	}
+	return clinote.ParseTime(val)
+}
+
+func createNote(note *clinote.Note, notebook string, edit, raw bool) {
 	c := newClient(clinote.DefaultClientOptions)
 	defer c.Store.Close()
 
-	note := new(clinote.Note)
-	if title == "" {
-		note.Title = "Untitled note"
-	} else {
-		note.Title = title
+	dirCfg, err := findDirConfig()
+	if err != nil {
+		fmt.Println("Error when reading the directory config:", err)
+		return
+	}
+	if dirCfg != nil {
+		note.Tags = append(note.Tags, dirCfg.Tags...)
+	}
+	settings, err := c.Store.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	if notebook == "" {
+		notebook = clinote.DefaultNotebookName(settings, dirCfg)
 	}
 	if notebook != "" {
 		nb, err := clinote.FindNotebook(c.Store, c.NoteStore, notebook)
@@ -94,11 +183,21 @@ func createNote(title, notebook string, edit, raw bool) {
 	if raw {
 		opts |= clinote.RawNote
 	}
+	if err := clinote.ApplyNotebookDefaults(c.Store, c.NoteStore, c.Config.GetConfigFolder(), note, opts); err != nil {
+		fmt.Println("Error when applying the notebook defaults:", err)
+		return
+	}
 	if edit {
-		if err := clinote.CreateAndEditNewNote(c, note, opts); err != nil {
+		if err := clinote.CreateAndEditNewNote(c, note, opts); err != nil && !reportQueued(err) {
 			fmt.Println("Error when editing the note:", err)
 		}
 		return
 	}
-	clinote.SaveNewNote(c.NoteStore, note, raw)
+	clinote.SetNewNoteTitle(settings, note, opts)
+	if err := clinote.SaveNewNote(c.NoteStore, note, raw); err != nil {
+		err = clinote.QueueIfOffline(c.Store, clinote.ChangeCreate, note, err)
+		if !reportQueued(err) {
+			fmt.Println("Error when saving the note:", err)
+		}
+	}
 }
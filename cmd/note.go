@@ -20,17 +20,46 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/evernote"
 	"github.com/spf13/cobra"
 )
 
+// noteFormat is the format given by the --format flag of the note commands.
+var noteFormat string
+
 var noteCmd = &cobra.Command{
 	Use:   "note \"note title\"",
 	Short: "View, edit and create a note.",
-	Long:  `Displays the content of a note.`,
+	Long: `
+Displays the content of a note.
+
+If the note has encrypted sections, the passphrase is asked for, or
+read from the file given by passphrase-file, and the sections are shown
+decrypted. The note isn't changed.
+
+The browser flag opens the note as an HTML page in the browser given by
+$BROWSER or the system's default browser. Images and other attachments
+are included in the page.
+
+The render flag shows the note with terminal styling: headings are
+underlined, lists indented, code highlighted and paragraphs wrapped.
+
+The images flag shows the note's images in the terminal, with the
+iTerm2 or kitty image protocols or as sixel graphics. Without a value,
+the protocol is picked from the terminal. The save-to flag saves the
+note as a Markdown file in the folder instead, with its attachments in a
+folder next to it.
+
+The backlinks flag lists the notes linking to the note. The backlinks
+are read from the index updated by "sync".
+
+Without a note title, the note is picked from the last search result, or
+the recently updated notes, with fzf if it's installed.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) != 1 {
+		if len(args) > 1 || len(args) == 0 && !interactive() {
 			cmd.Usage()
 			return
 		}
@@ -41,10 +70,18 @@ var noteCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(noteCmd)
 	noteCmd.Flags().Bool("raw", false, "Display raw content instead of markdown encoded.")
+	noteCmd.Flags().Bool("browser", false, "Open the note, with its images, as an HTML page in the browser.")
+	noteCmd.Flags().Bool("render", false, "Display the note with terminal styling instead of the Markdown.")
+	noteCmd.Flags().String("images", "", "Show the images in the terminal: auto, iterm, kitty, sixel or none.")
+	noteCmd.Flags().Lookup("images").NoOptDefVal = string(clinote.ImagesAuto)
+	noteCmd.Flags().String("save-to", "", "Save the note as Markdown, with its attachments, in the folder.")
+	noteCmd.Flags().Bool("meta", false, "Display the note's metadata and the text recognized in its images before the content.")
+	noteCmd.Flags().String("passphrase-file", "", "Read the passphrase for the encrypted sections from the file.")
+	noteCmd.Flags().Bool("backlinks", false, "List the notes linking to the note.")
+	noteCmd.PersistentFlags().StringVar(&noteFormat, "format", "", "Show and edit the note as markdown, org or asciidoc. Overrides the note.format setting.")
 }
 
 func getNote(cmd *cobra.Command, args []string) {
-	name := args[0]
 	raw, err := cmd.Flags().GetBool("raw")
 	opts := clinote.DefaultNoteOption
 	if raw {
@@ -54,16 +91,128 @@ func getNote(cmd *cobra.Command, args []string) {
 		fmt.Println("Error when paring raw flag:", err)
 		return
 	}
+	if render, _ := cmd.Flags().GetBool("render"); render {
+		opts |= clinote.RenderNote
+	}
+	meta, err := cmd.Flags().GetBool("meta")
+	if err != nil {
+		fmt.Println("Error when parsing meta flag:", err)
+		return
+	}
+	var images clinote.ImageProtocol
+	if value, _ := cmd.Flags().GetString("images"); value != "" {
+		if images, err = clinote.ParseImageProtocol(value); err != nil {
+			fmt.Println("Error when parsing images flag:", err)
+			return
+		}
+	}
 	client := defaultClient()
 	defer client.Close()
 	ns, err := client.GetNoteStore()
 	if err != nil {
 		return
 	}
-	n, err := clinote.GetNoteWithContent(client.Config.Store(), ns, name)
+	name, err := noteTitle(client.GetConfig().Store(), ns, args)
+	if err != nil {
+		fmt.Println("Error when picking the note:", err)
+		os.Exit(1)
+	}
+	if browser, _ := cmd.Flags().GetBool("browser"); browser {
+		openPreview(client.GetConfig().Store(), ns, name)
+		return
+	}
+	n, err := clinote.GetNoteWithContent(client.GetConfig().Store(), ns, name)
 	if err != nil {
 		fmt.Println("Error when getting the note:", err.Error())
 		os.Exit(1)
 	}
-	clinote.WriteNote(os.Stdout, n, opts)
+	if !raw && clinote.HasEncryptedBlocks(n) {
+		decryptNote(cmd, n)
+	}
+	if dir, _ := cmd.Flags().GetString("save-to"); dir != "" {
+		path, err := clinote.SaveNoteMarkdown(ns, n, dir)
+		if err != nil {
+			fmt.Println("Error when saving the note:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Saved the note to", path)
+		return
+	}
+	if meta {
+		clinote.WriteNoteMeta(os.Stdout, n, tableOptions(cmd))
+		writeRecognizedText(cmd, ns, n)
+	}
+	if images != "" {
+		err = clinote.WriteNoteWithImages(os.Stdout, ns, n, images, opts)
+	} else {
+		err = clinote.WriteNote(os.Stdout, n, opts)
+	}
+	if err != nil {
+		fmt.Println("Error when writing the note:", err)
+		os.Exit(1)
+	}
+	if backlinks, _ := cmd.Flags().GetBool("backlinks"); backlinks {
+		writeBacklinks(client.GetConfig().Store(), n)
+	}
+}
+
+// openPreview writes the note as an HTML page and opens it in the browser.
+func openPreview(db clinote.Storager, ns clinote.NotestoreClient, title string) {
+	file, err := clinote.WriteNotePreview(db, ns, title)
+	if err != nil {
+		fmt.Println("Error when creating the preview:", err)
+		os.Exit(1)
+	}
+	if err = evernote.OpenURLInBrowser("file://" + filepath.ToSlash(file)); err != nil {
+		fmt.Println("Error when opening the browser:", err)
+		fmt.Println("Open this file instead:", file)
+		os.Exit(1)
+	}
+}
+
+// writeRecognizedText lists the text recognized in the note's attachments.
+func writeRecognizedText(cmd *cobra.Command, ns clinote.NotestoreClient, n *clinote.Note) {
+	resources, err := clinote.GetNoteRecognition(ns, n.GUID)
+	if err == clinote.ErrNotSupported {
+		return
+	}
+	if err != nil {
+		fmt.Println("Error when getting the attachments:", err)
+		os.Exit(1)
+	}
+	clinote.WriteRecognizedText(os.Stdout, resources, tableOptions(cmd))
+}
+
+func writeBacklinks(db clinote.Storager, n *clinote.Note) {
+	links, err := clinote.GetBacklinks(db, n.GUID)
+	if err != nil {
+		fmt.Println("Error when getting the backlinks:", err)
+		os.Exit(1)
+	}
+	fmt.Println("\nBacklinks:")
+	if len(links) == 0 {
+		fmt.Println("No notes link to this note.")
+	}
+	for _, l := range links {
+		fmt.Println(" ", l.Title)
+	}
+}
+
+// decryptNote decrypts the encrypted sections of the note. If it fails,
+// the note is shown with the sections encrypted.
+func decryptNote(cmd *cobra.Command, n *clinote.Note) {
+	file := passphraseFile(cmd)
+	passphrase := func(hint string) (string, error) {
+		if file != "" {
+			return file, nil
+		}
+		prompt := "Passphrase for the encrypted section: "
+		if hint != "" {
+			prompt = fmt.Sprintf("Passphrase for the encrypted section (hint: %s): ", hint)
+		}
+		return clinote.ReadPassphrase(prompt, false)
+	}
+	if _, err := clinote.DecryptNote(n, passphrase); err != nil {
+		fmt.Fprintln(os.Stderr, "Error when decrypting the note:", err)
+	}
 }
@@ -0,0 +1,85 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var noteMetaCmd = &cobra.Command{
+	Use:   "meta \"note title\"",
+	Short: "Update the note's metadata.",
+	Long: `
+Meta updates the metadata of a note without downloading and uploading
+the note content. Only the fields given by the flags are changed.
+
+The tags flag replaces all the tags of the note with a comma separated
+list of tag names. Tags that don't exist are created. An empty list
+removes all tags from the note.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note has to be given.")
+			return
+		}
+		meta := new(clinote.NoteMeta)
+		var err error
+		if meta.Title, err = cmd.Flags().GetString("title"); err != nil {
+			fmt.Println("Error parsing the title:", err)
+			return
+		}
+		if meta.Notebook, err = cmd.Flags().GetString("notebook"); err != nil {
+			fmt.Println("Error parsing the notebook name:", err)
+			return
+		}
+		if meta.SourceURL, err = cmd.Flags().GetString("source-url"); err != nil {
+			fmt.Println("Error parsing the source URL:", err)
+			return
+		}
+		if cmd.Flags().Changed("tags") {
+			tags, err := cmd.Flags().GetString("tags")
+			if err != nil {
+				fmt.Println("Error parsing the tags:", err)
+				return
+			}
+			meta.Tags = clinote.ParseTagList(tags)
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		if err := clinote.UpdateNoteMeta(client.GetConfig().Store(), ns, args[0], meta); err != nil && !reportQueued(err) {
+			fmt.Println("Error when updating the note:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(noteMetaCmd)
+	noteMetaCmd.Flags().StringP("title", "t", "", "Change the note title to.")
+	noteMetaCmd.Flags().StringP("notebook", "b", "", "Move the note to notebook.")
+	noteMetaCmd.Flags().String("tags", "", "Comma separated list of tags.")
+	noteMetaCmd.Flags().String("source-url", "", "Set the source URL.")
+}
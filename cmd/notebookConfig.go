@@ -0,0 +1,97 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var notebookConfigCmd = &cobra.Command{
+	Use:   "config \"notebook name\"",
+	Short: "Configure defaults for new notes in a notebook.",
+	Long: `
+Config sets the defaults applied to new notes created in the notebook.
+If no flags are given, the current defaults are shown.
+
+The template flag sets the template used as the content of new notes.
+Templates are stored as markdown files in the templates folder of the
+config folder, for example ~/.config/clinote/templates/meeting.md.
+Templates can use {{.Title}}, {{.Notebook}} and {{date "2006-01-02" .Time}}.
+
+The auto-tag flag sets a comma separated list of tags added to new notes.
+
+Setting a flag to an empty string removes the default.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		configNotebook(cmd, args[0])
+	},
+}
+
+func init() {
+	notebookCmd.AddCommand(notebookConfigCmd)
+	notebookConfigCmd.Flags().String("template", "", "Template used for new notes.")
+	notebookConfigCmd.Flags().String("auto-tag", "", "Comma separated list of tags added to new notes.")
+}
+
+func configNotebook(cmd *cobra.Command, name string) {
+	db, err := openStorage()
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		os.Exit(1)
+	}
+	d := new(clinote.NotebookDefaults)
+	if current := settings.GetNotebookDefaults(name); current != nil {
+		*d = *current
+	}
+	if !cmd.Flags().Changed("template") && !cmd.Flags().Changed("auto-tag") {
+		fmt.Println("Template:", d.Template)
+		fmt.Println("Auto tags:", strings.Join(d.Tags, ", "))
+		return
+	}
+	if cmd.Flags().Changed("template") {
+		d.Template, _ = cmd.Flags().GetString("template")
+		cfgFolder := new(clinote.DefaultConfig).GetConfigFolder()
+		if _, err := clinote.LoadTemplate(cfgFolder, d.Template); d.Template != "" && err != nil {
+			fmt.Printf("Error when loading the template %s: %s\n", clinote.TemplatePath(cfgFolder, d.Template), err)
+			os.Exit(1)
+		}
+	}
+	if cmd.Flags().Changed("auto-tag") {
+		tags, _ := cmd.Flags().GetString("auto-tag")
+		d.Tags = clinote.ParseTagList(tags)
+	}
+	settings.SetNotebookDefaults(name, d)
+	if err = db.StoreSettings(settings); err != nil {
+		fmt.Println("Error when saving the settings:", err)
+		os.Exit(1)
+	}
+}
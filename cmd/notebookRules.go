@@ -0,0 +1,105 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var notebookRulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "List the tags added to new notes in the notebooks.",
+	Long: `
+Rules lists the notebooks with tags that are added to new notes. The
+tags are added to notes created with "note new", web clips and flushed
+captures. For example, to tag new notes in the Receipts notebook with
+finance:
+  clinote notebook rules add Receipts finance
+
+The tags can also be set with "notebook config --auto-tag".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		settings, err := db.GetSettings()
+		if err != nil {
+			fmt.Println("Error when getting the settings:", err)
+			os.Exit(1)
+		}
+		clinote.WriteNotebookRules(os.Stdout, settings, tableOptions(cmd))
+	},
+}
+
+var notebookRulesAddCmd = &cobra.Command{
+	Use:   "add \"notebook name\" tag...",
+	Short: "Add tags to new notes in the notebook.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			cmd.Usage()
+			return
+		}
+		updateNotebookRules(func(s *clinote.Settings) { s.AddNotebookRule(args[0], args[1:]) })
+	},
+}
+
+var notebookRulesRemoveCmd = &cobra.Command{
+	Use:   "remove \"notebook name\" [tag...]",
+	Short: "Stop adding tags to new notes in the notebook.",
+	Long: `
+Remove stops adding the tags to new notes in the notebook. If no tags
+are given, all the notebook's tags are removed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			cmd.Usage()
+			return
+		}
+		updateNotebookRules(func(s *clinote.Settings) { s.RemoveNotebookRule(args[0], args[1:]) })
+	},
+}
+
+func init() {
+	notebookCmd.AddCommand(notebookRulesCmd)
+	notebookRulesCmd.AddCommand(notebookRulesAddCmd)
+	notebookRulesCmd.AddCommand(notebookRulesRemoveCmd)
+}
+
+func updateNotebookRules(update func(*clinote.Settings)) {
+	db, err := openStorage()
+	if err != nil {
+		fmt.Println("Error when opening the database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		os.Exit(1)
+	}
+	update(settings)
+	if err = db.StoreSettings(settings); err != nil {
+		fmt.Println("Error when saving the settings:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var notesCmd = &cobra.Command{
+	Use:   "notes",
+	Short: "Change multiple notes at once.",
+	Long:  `Change multiple notes at once.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var notesSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set attributes on all notes matching a query.",
+	Long: `
+Set changes the attributes of all the notes matching the query. The
+query uses the Evernote search grammar, for example 'tag:imported'.
+
+Notes that already have the attributes are skipped. If the command is
+interrupted, or some notes fail to update, running the same command
+again continues where it stopped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		stampNotes(cmd)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(notesCmd)
+	notesCmd.AddCommand(notesSetCmd)
+	notesSetCmd.Flags().StringP("query", "q", "", "Search query selecting the notes.")
+	notesSetCmd.Flags().String("author", "", "Set the author.")
+	notesSetCmd.Flags().String("source", "", "Set the source.")
+	notesSetCmd.Flags().String("source-url", "", "Set the source URL.")
+}
+
+func stampNotes(cmd *cobra.Command) {
+	query, _ := cmd.Flags().GetString("query")
+	if query == "" {
+		fmt.Println("Error, a query has to be given.")
+		os.Exit(1)
+	}
+	changes := new(clinote.AttributeChanges)
+	changes.Author, _ = cmd.Flags().GetString("author")
+	changes.Source, _ = cmd.Flags().GetString("source")
+	changes.SourceURL, _ = cmd.Flags().GetString("source-url")
+	if changes.IsEmpty() {
+		fmt.Println("Error, at least one attribute has to be given.")
+		os.Exit(1)
+	}
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Failed to get notestore:", err)
+		return
+	}
+	processed := 0
+	progress := func(*clinote.Note) {
+		processed++
+		printProgress(fmt.Sprintf("Processed %d notes", processed), false)
+	}
+	result, err := clinote.StampNotes(ns, query, changes, clinote.DefaultBulkPageSize, progress)
+	if processed > 0 && !a11yMode() {
+		fmt.Fprintln(os.Stderr)
+	}
+	if result != nil {
+		for n, err := range result.Failed {
+			fmt.Printf("Failed to update %s: %s\n", n.Title, err)
+		}
+		fmt.Printf("Modified %d notes, %d already up to date, %d failed.\n", len(result.Modified), result.Unchanged, len(result.Failed))
+	}
+	stopIfBudgetUsed(err, nil, nil)
+	if err != nil {
+		fmt.Println("Error when searching for notes:", err)
+		fmt.Println("Run the command again to continue.")
+		os.Exit(1)
+	}
+	if len(result.Failed) != 0 {
+		fmt.Println("Run the command again to retry the failed notes.")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/evernote"
+	"github.com/spf13/cobra"
+)
+
+var openWebNoteCmd = &cobra.Command{
+	Use:   "open-web \"note title\"",
+	Short: "Open the note in the web client.",
+	Long: `
+Open-web opens the note in the web client of the service the active
+credential is for. The URL includes the account's shard so it links
+directly to the note. The browser given by $BROWSER is used if it's set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note title has to be given.")
+			os.Exit(1)
+		}
+		nb, _ := cmd.Flags().GetString("notebook")
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		db := client.GetConfig().Store()
+		n, err := clinote.GetNote(db, ns, args[0], nb)
+		if err != nil {
+			fmt.Println("Error when getting the note:", err)
+			os.Exit(1)
+		}
+		u := clinote.NoteWebURL(activeCredential(client.GetConfig()), n.GUID)
+		if printOnly, _ := cmd.Flags().GetBool("print"); printOnly {
+			fmt.Println(u)
+			return
+		}
+		if err = evernote.OpenURLInBrowser(u); err != nil {
+			fmt.Println("Error when opening the browser:", err)
+			fmt.Println("Open this URL instead:", u)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(openWebNoteCmd)
+	openWebNoteCmd.Flags().StringP("notebook", "b", "", "The notebook of the note.")
+	openWebNoteCmd.Flags().BoolP("print", "p", false, "Print the URL instead of opening it.")
+}
@@ -0,0 +1,253 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TcM1911/clinote"
+)
+
+// pickerLimit is the number of candidates shown by the numbered picker.
+const pickerLimit = 20
+
+// pickedGUID is the note picked by selectNote. The note's title is given to
+// the commands, so the note picker returns this note if other notes have the
+// same title, even with --exact.
+var pickedGUID string
+
+// setNotePicker lets the user pick the note when a title doesn't match
+// exactly one note, unless the --exact flag is set.
+func setNotePicker() {
+	if exact, _ := RootCmd.PersistentFlags().GetBool("exact"); exact {
+		return
+	}
+	clinote.NotePicker = pickNote
+}
+
+// useFzf returns true if notes and notebooks should be picked with fzf. It's
+// used if the --fzf flag is set or if fzf is installed.
+func useFzf() bool {
+	if on, _ := RootCmd.PersistentFlags().GetBool("fzf"); on {
+		return true
+	}
+	return clinote.FzfAvailable()
+}
+
+// interactive returns true if the user can be asked to pick a note.
+func interactive() bool {
+	return clinote.IsTerminal(os.Stdin)
+}
+
+// pickNote asks the user to pick one of the notes. If stdin isn't a
+// terminal, the first exact match is used and no note is found if there
+// isn't one.
+func pickNote(title string, notes []*clinote.Note) (*clinote.Note, error) {
+	for _, n := range notes {
+		if pickedGUID != "" && n.GUID == pickedGUID {
+			return n, nil
+		}
+	}
+	if !interactive() {
+		if notes[0].Title == title {
+			return notes[0], nil
+		}
+		return nil, clinote.ErrNoNoteFound
+	}
+	if useFzf() {
+		return fzfNote(notes, title)
+	}
+	if notes[0].Title == title {
+		fmt.Fprintf(os.Stderr, "Several notes are titled %q:\n", title)
+	} else {
+		fmt.Fprintf(os.Stderr, "No note is titled %q, similar notes:\n", title)
+	}
+	return pickFromList(notes)
+}
+
+// selectNote lets the user pick a note when no title is given. The last
+// search result, or the recently updated notes, are offered. Without fzf,
+// the user is asked for a part of the title first.
+func selectNote(db clinote.Storager, ns clinote.NotestoreClient) (*clinote.Note, error) {
+	notes, err := clinote.PickerNotes(db, ns)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, clinote.ErrNoNoteFound
+	}
+	var n *clinote.Note
+	if useFzf() {
+		n, err = fzfNote(notes, "")
+	} else {
+		n, err = searchAndPick(notes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pickedGUID = n.GUID
+	clinote.NotePicker = pickNote
+	return n, nil
+}
+
+// pickTitle returns the title of the note the user means. If the title
+// doesn't match exactly one note, the user picks the note and the picked
+// note is used by the command. Numbers referring to the last search result
+// are returned as is.
+func pickTitle(db clinote.Storager, ns clinote.NotestoreClient, title string) string {
+	if _, err := strconv.Atoi(title); err == nil {
+		return title
+	}
+	n, err := clinote.GetNote(db, ns, title, "")
+	if err != nil {
+		fmt.Println("Error when getting the note:", err)
+		os.Exit(1)
+	}
+	pickedGUID = n.GUID
+	return n.Title
+}
+
+// noteTitle returns the note title given as the argument. Without an
+// argument, the user picks the note.
+func noteTitle(db clinote.Storager, ns clinote.NotestoreClient, args []string) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	n, err := selectNote(db, ns)
+	if err != nil {
+		return "", err
+	}
+	return n.Title, nil
+}
+
+// selectNotebook lets the user pick one of the notebooks.
+func selectNotebook(db clinote.Storager, ns clinote.NotestoreClient) (*clinote.Notebook, error) {
+	nbs, err := clinote.GetNotebooks(db, ns, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(nbs) == 0 {
+		return nil, clinote.ErrNoNotebookFound
+	}
+	if useFzf() {
+		names := make([]string, len(nbs))
+		for i, nb := range nbs {
+			names[i] = nb.Name
+		}
+		i, err := clinote.Fzf(names, "Notebook> ")
+		if err != nil {
+			return nil, err
+		}
+		return nbs[i], nil
+	}
+	fmt.Fprintln(os.Stderr, "Notebooks:")
+	for i, nb := range nbs {
+		fmt.Fprintf(os.Stderr, "%3d. %s\n", i+1, nb.Name)
+	}
+	i, err := askNumber(len(nbs), "notebook")
+	if err != nil {
+		return nil, err
+	}
+	return nbs[i], nil
+}
+
+// fzfNote lets the user pick one of the notes with fzf.
+func fzfNote(notes []*clinote.Note, query string) (*clinote.Note, error) {
+	items := make([]string, len(notes))
+	for i, n := range notes {
+		items[i] = n.Title + "\t" + formatUpdated(n)
+	}
+	prompt := "Note> "
+	if query != "" {
+		prompt = query + "> "
+	}
+	i, err := clinote.Fzf(items, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return notes[i], nil
+}
+
+// searchAndPick asks for a part of the title and lets the user pick one of
+// the matching notes.
+func searchAndPick(notes []*clinote.Note) (*clinote.Note, error) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "Note title, or press enter to list the notes: ")
+		if !scanner.Scan() {
+			return nil, clinote.ErrNothingPicked
+		}
+		query := strings.TrimSpace(scanner.Text())
+		matches := notes
+		if query != "" {
+			matches = clinote.FuzzyMatchNotes(query, notes)
+		}
+		if len(matches) == 0 {
+			fmt.Fprintln(os.Stderr, "No note matches", query)
+			continue
+		}
+		return pickFromList(matches)
+	}
+}
+
+// pickFromList shows a numbered list of the notes and asks the user to pick
+// one.
+func pickFromList(notes []*clinote.Note) (*clinote.Note, error) {
+	if len(notes) > pickerLimit {
+		notes = notes[:pickerLimit]
+	}
+	for i, n := range notes {
+		fmt.Fprintf(os.Stderr, "%3d. %s (updated %s)\n", i+1, n.Title, formatUpdated(n))
+	}
+	i, err := askNumber(len(notes), "note")
+	if err != nil {
+		return nil, err
+	}
+	return notes[i], nil
+}
+
+// askNumber asks the user for a number between 1 and count and returns it
+// as an index.
+func askNumber(count int, what string) (int, error) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, "Pick a %s [1-%d], or press enter to cancel: ", what, count)
+		if !scanner.Scan() {
+			return 0, clinote.ErrNothingPicked
+		}
+		answer := strings.TrimSpace(scanner.Text())
+		if answer == "" {
+			return 0, clinote.ErrNothingPicked
+		}
+		i, err := strconv.Atoi(answer)
+		if err != nil || i < 1 || i > count {
+			fmt.Fprintln(os.Stderr, "Error, unknown", what, answer)
+			continue
+		}
+		return i - 1, nil
+	}
+}
+
+func formatUpdated(n *clinote.Note) string {
+	return time.Unix(0, n.Updated*int64(time.Millisecond)).Format("2006-01-02 15:04")
+}
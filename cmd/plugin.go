@@ -0,0 +1,100 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/daemon"
+	"github.com/spf13/cobra"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List the plugins on the PATH.",
+	Long: `
+Plugins list the executables on the PATH that can be run as clinote
+commands. An executable named clinote-foo is run by "clinote foo", with
+the rest of the arguments passed to it. Built-in commands and aliases
+can't be overridden by plugins.
+
+The plugin gets the config folder in CLINOTE_HOME, the name of the
+active credential in CLINOTE_PROFILE and the path to clinote in
+CLINOTE_BIN. If the daemon is running, its socket is in
+CLINOTE_DAEMON_SOCKET. Go plugins can use the daemon package to access
+the storage and the notestore through it. Plugins aren't run in safe
+mode.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		plugins := clinote.ListPlugins()
+		if len(plugins) == 0 {
+			fmt.Println("No plugins found.")
+			return
+		}
+		clinote.WritePluginListing(os.Stdout, plugins, tableOptions(cmd))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pluginsCmd)
+}
+
+// runPlugin runs the plugin for the command if the command isn't a
+// built-in command. The process exits with the plugin's exit code once
+// the plugin has finished.
+func runPlugin(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return
+	}
+	if cmd, _, err := RootCmd.Find(args[:1]); err == nil && cmd != RootCmd {
+		return
+	}
+	path, err := clinote.FindPlugin(args[0])
+	if err != nil {
+		return
+	}
+	if os.Getenv(clinote.SafeEnv) != "" {
+		clinote.SafeMode = true
+	}
+	cfgFolder := (new(clinote.DefaultConfig)).GetConfigFolder()
+	var profile string
+	if db, err := openStorage(); err == nil {
+		if cred, err := clinote.SessionCredential(db, db); err == nil && cred != nil {
+			profile = cred.Name
+		}
+		db.Close()
+	}
+	socket := daemon.SocketPath(cfgFolder)
+	if d, err := daemon.Dial(socket); err == nil {
+		d.Close()
+	} else {
+		socket = ""
+	}
+	err = clinote.RunPlugin(path, args[1:], clinote.PluginEnv(cfgFolder, profile, socket))
+	if exit, ok := err.(*exec.ExitError); ok {
+		os.Exit(exit.ExitCode())
+	}
+	if err != nil {
+		fmt.Printf("Error when running the plugin %s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,99 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var recoverNoteCmd = &cobra.Command{
+	Use:   "recover [edit id]",
+	Short: "Resume an interrupted note edit.",
+	Long: `
+The content saved in the editor is recorded in a journal before the
+note is uploaded. If CLInote is stopped before the upload is done, for
+example by a crash or a lost connection, the edit is left in the
+journal.
+
+Without an id, the interrupted edits are listed. "in editor" means the
+editor wasn't closed, the content is read from the editor's temporary
+file. With an id, the edit is uploaded again. New notes are created.
+  clinote note recover 1a2b3c4d
+
+With --discard, the edit is removed from the journal without uploading
+it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			cmd.Usage()
+			return
+		}
+		discard, _ := cmd.Flags().GetBool("discard")
+		recoverEdit(cmd, args, discard)
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(recoverNoteCmd)
+	recoverNoteCmd.Flags().Bool("discard", false, "Remove the edit without uploading it.")
+}
+
+func recoverEdit(cmd *cobra.Command, args []string, discard bool) {
+	client := defaultClient()
+	defer client.Close()
+	db := client.GetConfig().Store()
+	if len(args) == 0 {
+		edits, err := clinote.GetInterruptedEdits(db)
+		if err != nil {
+			fmt.Println("Error when reading the journal:", err)
+			os.Exit(1)
+		}
+		if len(edits) == 0 {
+			fmt.Println("No interrupted edits.")
+			return
+		}
+		clinote.WriteEditListing(os.Stdout, edits, tableOptions(cmd))
+		return
+	}
+	edit, err := clinote.FindInterruptedEdit(db, args[0])
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if discard {
+		if err = clinote.DiscardEdit(db, edit); err != nil {
+			fmt.Println("Error when discarding the edit:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	n, err := clinote.ResumeEdit(db, ns, edit)
+	if err != nil {
+		fmt.Println("Error when uploading the edit:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved \"%s\".\n", n.Title)
+}
@@ -0,0 +1,156 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var redactNoteCmd = &cobra.Command{
+	Use:   "redact \"note title\"",
+	Short: "Redact content in a note.",
+	Long: `
+Redact replaces all content matching the pattern with a redaction
+marker. The pattern is a regular expression, for example:
+
+  clinote note redact "note title" --pattern '\b\d{16}\b'
+
+Before the note is updated, an encrypted copy of the original note
+is saved locally. The copy is encrypted with the passphrase, which is
+asked for or read from the file given by passphrase-file. If the note
+is redacted again, the first copy is kept and the same passphrase has
+to be used. The original content can be restored with the restore flag.
+
+Use the preview flag to see the redacted note without saving it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note has to be given.")
+			return
+		}
+		restore, err := cmd.Flags().GetBool("restore")
+		if err != nil {
+			fmt.Println("Error when parsing restore flag:", err)
+			return
+		}
+		if restore {
+			restoreRedactedNote(cmd, args[0])
+			return
+		}
+		redactNote(cmd, args[0])
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(redactNoteCmd)
+	redactNoteCmd.Flags().StringP("pattern", "p", "", "Regular expression matching the content to redact.")
+	redactNoteCmd.Flags().String("passphrase-file", "", "Read the passphrase used to encrypt the original note from the file.")
+	redactNoteCmd.Flags().Bool("preview", false, "Show the redacted note without saving it.")
+	redactNoteCmd.Flags().Bool("restore", false, "Restore the note to the content it had before it was redacted.")
+	redactNoteCmd.Flags().Bool("raw", false, "Redact the raw content instead of the markdown version.")
+}
+
+func redactNote(cmd *cobra.Command, title string) {
+	p, err := cmd.Flags().GetString("pattern")
+	if err != nil {
+		fmt.Println("Error when parsing the pattern:", err)
+		return
+	}
+	if p == "" {
+		fmt.Println("Error, a pattern has to be given.")
+		return
+	}
+	pattern, err := regexp.Compile(p)
+	if err != nil {
+		fmt.Println("Error when compiling the pattern:", err)
+		return
+	}
+	preview, err := cmd.Flags().GetBool("preview")
+	if err != nil {
+		fmt.Println("Error when parsing preview flag:", err)
+		return
+	}
+	raw, err := cmd.Flags().GetBool("raw")
+	if err != nil {
+		fmt.Println("Error when parsing raw flag:", err)
+		return
+	}
+	opts := clinote.DefaultNoteOption
+	if raw {
+		opts |= clinote.RawNote
+	}
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Failed to get notestore:", err)
+		return
+	}
+	if preview {
+		n, err := clinote.GetNoteWithContent(client.GetConfig().Store(), ns, title)
+		if err != nil {
+			fmt.Println("Error when getting the note:", err)
+			os.Exit(1)
+		}
+		count := clinote.RedactNote(n, pattern, opts)
+		clinote.WriteNote(os.Stdout, n, opts)
+		fmt.Printf("\n%d match(es) would be redacted.\n", count)
+		return
+	}
+	passphrase := redactPassphrase(cmd, true)
+	count, err := clinote.RedactAndSaveNote(client.GetConfig().Store(), ns, title, pattern, passphrase, opts)
+	if err != nil {
+		fmt.Println("Error when redacting the note:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Redacted %d match(es).\n", count)
+}
+
+func restoreRedactedNote(cmd *cobra.Command, title string) {
+	passphrase := redactPassphrase(cmd, false)
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Failed to get notestore:", err)
+		return
+	}
+	err = clinote.RestoreRedactedNote(client.GetConfig().Store(), ns, title, passphrase)
+	if err != nil {
+		fmt.Println("Error when restoring the note:", err)
+		os.Exit(1)
+	}
+}
+
+// redactPassphrase returns the passphrase for the original copy, read from
+// the passphrase-file flag or asked for.
+func redactPassphrase(cmd *cobra.Command, confirm bool) string {
+	if passphrase := passphraseFile(cmd); passphrase != "" {
+		return passphrase
+	}
+	passphrase, err := clinote.ReadPassphrase("Passphrase for the original copy: ", confirm)
+	if err != nil {
+		fmt.Println("Error when reading the passphrase:", err)
+		os.Exit(1)
+	}
+	return passphrase
+}
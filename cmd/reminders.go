@@ -0,0 +1,211 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var remindersCmd = &cobra.Command{
+	Use:   "reminders",
+	Short: "List and complete note reminders.",
+	Long:  `List and complete note reminders.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var remindersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List notes with reminders.",
+	Long: `
+List shows the notes with reminders sorted by the due date. Notes
+without a due date are listed last. Overdue reminders are highlighted.
+
+The overdue flag shows only reminders that are past their due date
+and not done. The due-within flag shows only reminders due within
+the duration, for example 3d, 2w or 12h. If both flags are given,
+reminders matching either flag are shown.
+
+The notes can be referenced by the number in the list by other
+commands.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listReminders(cmd)
+	},
+}
+
+var remindersDoneCmd = &cobra.Command{
+	Use:   "done \"note title\"",
+	Short: "Mark the note's reminder as done.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note has to be given.")
+			return
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		db := client.GetConfig().Store()
+		if err := clinote.CompleteReminder(db, ns, pickTitle(db, ns, args[0])); err != nil {
+			fmt.Println("Error when completing the reminder:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var remindersShiftCmd = &cobra.Command{
+	Use:   "shift",
+	Short: "Move the due date of reminders.",
+	Long: `
+Shift moves the due date of the reminders on the notes matching the
+query by the duration, for example 7d, 2w or -1d. Reminders that are
+done or don't have a due date are not changed.
+
+The query uses the Evernote search grammar, for example 'tag:followup'.
+If no query is given, all reminders are moved.
+
+Use the dry-run flag to see the changes without updating the notes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		shiftReminders(cmd)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(remindersCmd)
+	remindersCmd.AddCommand(remindersListCmd)
+	remindersCmd.AddCommand(remindersDoneCmd)
+	remindersCmd.AddCommand(remindersShiftCmd)
+	remindersShiftCmd.Flags().StringP("query", "q", "", "Search query selecting the notes.")
+	remindersShiftCmd.Flags().String("by", "", "Duration to move the reminders by, for example 7d.")
+	remindersShiftCmd.Flags().Bool("dry-run", false, "Show the changes without updating the notes.")
+	remindersShiftCmd.Flags().IntP("count", "c", 100, "Maximum number of notes to update.")
+	remindersListCmd.Flags().Bool("overdue", false, "Only show overdue reminders.")
+	remindersListCmd.Flags().String("due-within", "", "Only show reminders due within the duration, for example 3d.")
+	remindersListCmd.Flags().IntP("count", "c", 100, "Maximum number of reminders to fetch.")
+}
+
+func listReminders(cmd *cobra.Command) {
+	var filter clinote.ReminderFilter
+	var err error
+	if filter.Overdue, err = cmd.Flags().GetBool("overdue"); err != nil {
+		fmt.Println("Error when parsing overdue flag:", err)
+		return
+	}
+	within, err := cmd.Flags().GetString("due-within")
+	if err != nil {
+		fmt.Println("Error when parsing due-within flag:", err)
+		return
+	}
+	if within != "" {
+		if filter.DueWithin, err = clinote.ParseDuration(within); err != nil {
+			fmt.Println("Error when parsing due-within flag:", err)
+			os.Exit(1)
+		}
+	}
+	count, err := cmd.Flags().GetInt("count")
+	if err != nil {
+		fmt.Println("Error when parsing count value:", err)
+		return
+	}
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Failed to get notestore:", err)
+		return
+	}
+	notes, err := clinote.GetReminders(ns, count)
+	if err != nil {
+		fmt.Println("Error when getting the reminders:", err)
+		os.Exit(1)
+	}
+	now := time.Now()
+	notes = clinote.FilterReminders(notes, filter, now)
+	if err = client.GetConfig().Store().SaveSearch(notes); err != nil {
+		fmt.Println("Error when saving the reminder list:", err)
+	}
+	opts, cred := linkOptions(cmd, client.GetConfig())
+	clinote.WriteReminderListingWithLinks(os.Stdout, notes, now, opts, cred)
+}
+
+func shiftReminders(cmd *cobra.Command) {
+	query, err := cmd.Flags().GetString("query")
+	if err != nil {
+		fmt.Println("Error when parsing query:", err)
+		return
+	}
+	byStr, err := cmd.Flags().GetString("by")
+	if err != nil {
+		fmt.Println("Error when parsing by flag:", err)
+		return
+	}
+	if byStr == "" {
+		fmt.Println("Error, a duration has to be given with the by flag.")
+		os.Exit(1)
+	}
+	by, err := clinote.ParseDuration(byStr)
+	if err != nil {
+		fmt.Println("Error when parsing by flag:", err)
+		os.Exit(1)
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		fmt.Println("Error when parsing dry-run flag:", err)
+		return
+	}
+	count, err := cmd.Flags().GetInt("count")
+	if err != nil {
+		fmt.Println("Error when parsing count value:", err)
+		return
+	}
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Failed to get notestore:", err)
+		return
+	}
+	shifts, err := clinote.ShiftReminders(ns, query, count, by, dryRun)
+	if err != nil {
+		fmt.Println("Error when searching for reminders:", err)
+		os.Exit(1)
+	}
+	if len(shifts) == 0 {
+		fmt.Println("No reminders to move.")
+		return
+	}
+	clinote.WriteReminderShiftListing(os.Stdout, shifts, tableOptions(cmd))
+	if dryRun {
+		fmt.Println("Dry run, no reminders were changed.")
+		return
+	}
+	for _, s := range shifts {
+		if s.Err != nil {
+			os.Exit(1)
+		}
+	}
+}
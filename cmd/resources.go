@@ -0,0 +1,176 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var resourcesCmd = &cobra.Command{
+	Use:     "resources",
+	Aliases: []string{"attachment", "attachments"},
+	Short:   "Work with note attachments.",
+	Long: `
+Resources works with the attachments of many notes at once.`,
+}
+
+var resourcesPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Download the attachments of matching notes.",
+	Long: `
+Pull downloads the attachments of the notes matching the search query to
+a folder. Only the attachments are downloaded, not the note content.
+Attachments that have already been downloaded are skipped, so the same
+pull can be run again to fetch new attachments.
+
+The file names are given by a template. The fields are:
+
+  {{.Title}}     the note's title
+  {{.GUID}}      the note's GUID
+  {{.Filename}}  the attachment's file name
+  {{.Ext}}       the attachment's file extension
+  {{.Mime}}      the attachment's mime type
+  {{.Index}}     the attachment's position in the note
+  {{.Created}}   when the note was created
+  {{.Updated}}   when the note was last updated
+
+Dates are formatted with the date function, for example
+{{date "2006-01-02" .Created}}. Slashes in the template create folders.
+
+Example:
+
+  clinote resources pull --query 'tag:receipts created:year' --dir ./receipts \
+    --name '{{date "2006-01" .Created}}/{{.Title}}{{.Ext}}'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pullResources(cmd)
+	},
+}
+
+var resourcesSearchCmd = &cobra.Command{
+	Use:   "search \"text\"",
+	Short: "Find images by their recognized text.",
+	Long: `
+Search finds the image attachments with the text recognized in them by
+the note service, for example the text in a photo of a whiteboard or a
+receipt. All the words have to be recognized in the image. Each matching
+attachment is listed with its note, its position in the note and the
+recognized text that matched.
+
+Only Evernote recognizes text in images.
+
+Example:
+
+  clinote attachment search "quarterly goals"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, the text to search for has to be given.")
+			os.Exit(1)
+		}
+		searchResources(cmd, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(resourcesCmd)
+	resourcesCmd.AddCommand(resourcesPullCmd)
+	resourcesCmd.AddCommand(resourcesSearchCmd)
+	resourcesSearchCmd.Flags().StringP("notebook", "b", "", "Only search notes in the notebook.")
+	resourcesPullCmd.Flags().StringP("query", "q", "", "Search query.")
+	resourcesPullCmd.Flags().StringP("notebook", "b", "", "Only pull from notes in the notebook.")
+	resourcesPullCmd.Flags().StringP("dir", "d", ".", "Folder to save the attachments in.")
+	resourcesPullCmd.Flags().String("name", clinote.DefaultResourceName, "File name template.")
+	resourcesPullCmd.Flags().String("mime", "", "Only pull attachments of the mime type, end with / to match all subtypes.")
+}
+
+func pullResources(cmd *cobra.Command) {
+	query, _ := cmd.Flags().GetString("query")
+	notebook, _ := cmd.Flags().GetString("notebook")
+	opts := new(clinote.PullOptions)
+	opts.Dir, _ = cmd.Flags().GetString("dir")
+	opts.Name, _ = cmd.Flags().GetString("name")
+	opts.Mime, _ = cmd.Flags().GetString("mime")
+	if query == "" && notebook == "" {
+		fmt.Println("Error, a search query or a notebook is required.")
+		os.Exit(1)
+	}
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	filter := &clinote.NoteFilter{Words: query}
+	if notebook != "" {
+		book, err := clinote.FindNotebook(client.GetConfig().Store(), ns, notebook)
+		if err != nil {
+			fmt.Println("Error when getting the notebook:", err)
+			os.Exit(1)
+		}
+		filter.NotebookGUID = book.GUID
+	}
+	saved := 0
+	progress := func(string) {
+		saved++
+		printProgress(fmt.Sprintf("Downloaded %d attachments", saved), false)
+	}
+	result, err := clinote.PullResources(ns, filter, opts, progress)
+	if saved > 0 && !a11yMode() {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		fmt.Println("Error when pulling the attachments:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved %d attachments from %d notes to %s, %d already downloaded.\n",
+		len(result.Saved), result.Notes, opts.Dir, result.Skipped)
+}
+
+func searchResources(cmd *cobra.Command, text string) {
+	notebook, _ := cmd.Flags().GetString("notebook")
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	filter := new(clinote.NoteFilter)
+	if notebook != "" {
+		book, err := clinote.FindNotebook(client.GetConfig().Store(), ns, notebook)
+		if err != nil {
+			fmt.Println("Error when getting the notebook:", err)
+			os.Exit(1)
+		}
+		filter.NotebookGUID = book.GUID
+	}
+	matches, err := clinote.SearchAttachments(ns, filter, text)
+	if err != nil {
+		fmt.Println("Error when searching the attachments:", err)
+		os.Exit(1)
+	}
+	if len(matches) == 0 {
+		fmt.Println("No attachments found.")
+		return
+	}
+	clinote.WriteAttachmentMatches(os.Stdout, matches, tableOptions(cmd))
+}
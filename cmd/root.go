@@ -18,9 +18,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/evernote"
+	"github.com/TcM1911/clinote/joplin"
+	"github.com/TcM1911/clinote/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -46,6 +53,11 @@ CLInote is a cli client for Evernote. The note content can be formatted using Ma
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	setConfigDir()
+	args := expandAlias()
+	runPlugin(args)
+	RootCmd.SetArgs(args)
+	handleSignals()
 	if err := RootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
@@ -53,5 +65,329 @@ func Execute() {
 }
 
 func init() {
+	cobra.OnInitialize(setSafeMode, setLogging, setTrace, setRecordReplay, setAPIBudget, setLockTimeout, setDBLinger, setContext, setRetryPolicy, setNotePicker, setTheme)
 	RootCmd.Flags().Bool("version", false, "Show the version")
+	RootCmd.PersistentFlags().Bool("wide", false, "Don't limit listings to the terminal width.")
+	RootCmd.PersistentFlags().Bool("no-truncate", false, "Wrap long cells in listings instead of truncating them.")
+	RootCmd.PersistentFlags().Bool("a11y", false, "Accessible output for screen readers, also enabled by CLINOTE_A11Y.")
+	RootCmd.PersistentFlags().Bool("verbose", false, "Log API calls and sync decisions to stderr.")
+	RootCmd.PersistentFlags().Bool("debug", false, "Log API calls, sync decisions and storage operations to stderr.")
+	RootCmd.PersistentFlags().Bool("log-file", false, "Write the log to "+clinote.LogFileName+" in the config folder instead of stderr.")
+	RootCmd.PersistentFlags().String("trace", "", "Write the API requests and responses, with tokens redacted, to the file.")
+	RootCmd.PersistentFlags().String("record", "", "Record the API session, with tokens redacted, to the file for replaying.")
+	RootCmd.PersistentFlags().String("replay", "", "Answer the API calls from a recorded session instead of the server.")
+	RootCmd.PersistentFlags().Int("max-api-calls", -1, "Stop the command after the number of API calls, long operations continue on the next run.")
+	RootCmd.PersistentFlags().Bool("safe", false, "Disable hooks, template commands and other external commands, also enabled by "+clinote.SafeEnv+".")
+	RootCmd.PersistentFlags().Bool("dry-run", false, "Print the changes that would be sent to the server without sending them.")
+	RootCmd.PersistentFlags().String("config-dir", "", "Folder for the configuration and database, also set by "+clinote.HomeEnv+".")
+	RootCmd.PersistentFlags().Duration("lock-timeout", storage.LockTimeout, "How long to wait for another clinote process to release the database, 0 waits without limit.")
+	RootCmd.PersistentFlags().Bool("ephemeral", false, "Keep the database in memory, settings and credentials are copied from the config folder and changes are discarded.")
+	RootCmd.PersistentFlags().Duration("timeout", 0, "Stop waiting for the server and the database after the duration, for example 30s.")
+	RootCmd.PersistentFlags().Int("retry-attempts", 0, "How many times an API call is made when the network fails, overrides the retry.attempts setting.")
+	RootCmd.PersistentFlags().Duration("retry-delay", 0, "The wait before the first retry, overrides the retry.delay setting.")
+	RootCmd.PersistentFlags().Float64("retry-jitter", 0, "The fraction of the retry wait that is randomized, overrides the retry.jitter setting.")
+	RootCmd.PersistentFlags().Duration("db-linger", 0, "How long the database is held open after it was used, overrides the db.linger setting.")
+	RootCmd.PersistentFlags().String("theme", "", "Color theme of the output: default, light, mono or none. Overrides the output.theme setting.")
+	RootCmd.PersistentFlags().Bool("fzf", false, "Pick notes and notebooks with fzf, used by default when fzf is installed.")
+	RootCmd.PersistentFlags().Bool("exact", false, "Only use notes with exactly the given title, instead of asking which note was meant.")
+}
+
+// setTheme sets the color theme if the --theme flag is set. The colors
+// are turned off in accessible mode.
+func setTheme() {
+	if a11yMode() {
+		clinote.OutputTheme = clinote.Themes[clinote.ThemeNone]
+		return
+	}
+	name, _ := RootCmd.PersistentFlags().GetString("theme")
+	if name == "" {
+		return
+	}
+	if err := clinote.SetOutputTheme(name); err != nil {
+		fmt.Println("Error when setting the theme:", err)
+		os.Exit(1)
+	}
+}
+
+// setLockTimeout sets how long to wait for the database if it's used by
+// another process.
+func setLockTimeout() {
+	if d, err := RootCmd.PersistentFlags().GetDuration("lock-timeout"); err == nil {
+		storage.LockTimeout = d
+	}
+}
+
+// cmdContext is the context of the command. It's canceled when the
+// program is interrupted or the --timeout has passed.
+var cmdContext = context.Background()
+
+// setContext creates the context of the command and makes the API calls
+// use it. The wait for the database lock is limited by the timeout too.
+func setContext() {
+	timeout, _ := RootCmd.PersistentFlags().GetDuration("timeout")
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		if storage.LockTimeout == 0 || timeout < storage.LockTimeout {
+			storage.LockTimeout = timeout
+		}
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	onShutdown(cancel)
+	cmdContext = ctx
+	evernote.EnableContext(ctx)
+	joplin.EnableContext(ctx)
+}
+
+// setRetryPolicy overrides the retry policy in the settings with the
+// values given by the flags.
+func setRetryPolicy() {
+	flags := RootCmd.PersistentFlags()
+	attempts, _ := flags.GetInt("retry-attempts")
+	delay, _ := flags.GetDuration("retry-delay")
+	jitter, _ := flags.GetFloat64("retry-jitter")
+	if attempts < 0 {
+		fmt.Println("Error:", clinote.ErrInvalidRetryAttempts)
+		os.Exit(1)
+	}
+	if err := clinote.ValidateRetryJitter(jitter); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	evernote.RetryOverride = clinote.RetryPolicy{MaxAttempts: attempts, BaseDelay: delay, Jitter: jitter, JitterSet: flags.Changed("retry-jitter")}
+}
+
+// setDBLinger sets how long the database is held open after it was used.
+func setDBLinger() {
+	if d, err := RootCmd.PersistentFlags().GetDuration("db-linger"); err == nil {
+		storage.DBLinger = d
+	}
+}
+
+// setConfigDir points the configuration and database at the folder given by
+// the --config-dir flag or the CLINOTE_HOME environment variable. The flag
+// is read before the command line is parsed since aliases are expanded
+// using the folder's settings. The folder is exported in CLINOTE_HOME so
+// hooks and editors running clinote use the same folder.
+func setConfigDir() {
+	dir := configDirArg(os.Args[1:])
+	if dir == "" {
+		dir = os.Getenv(clinote.HomeEnv)
+	}
+	if dir == "" {
+		return
+	}
+	if err := clinote.SetHomeFolder(dir); err != nil {
+		fmt.Println("Error when setting the config folder:", err)
+		os.Exit(1)
+	}
+	os.Setenv(clinote.HomeEnv, dir)
+}
+
+// configDirArg returns the value of the --config-dir flag in the arguments.
+func configDirArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "--config-dir=") {
+			return strings.TrimPrefix(arg, "--config-dir=")
+		}
+		if arg == "--config-dir" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// a11yMode returns true if the output should be accessible for screen
+// readers: listings are written as labeled fields, one per line, and
+// progress isn't animated.
+func a11yMode() bool {
+	if on, _ := RootCmd.PersistentFlags().GetBool("a11y"); on {
+		return true
+	}
+	return os.Getenv("CLINOTE_A11Y") != ""
+}
+
+// setSafeMode enables safe mode if the --safe flag or CLINOTE_SAFE is set.
+// The variable is exported so clinote processes started by this one also
+// run in safe mode.
+func setSafeMode() {
+	on, _ := RootCmd.PersistentFlags().GetBool("safe")
+	if !on && os.Getenv(clinote.SafeEnv) == "" {
+		return
+	}
+	clinote.SafeMode = true
+	os.Setenv(clinote.SafeEnv, "1")
+}
+
+// setLogging enables logging if the --verbose or --debug flag or CLINOTE_LOG
+// is set. CLINOTE_LOG is the level, optionally followed by ":file" to log to
+// the rotated log file in the config folder.
+func setLogging() {
+	level := clinote.LogLevelOff
+	toFile, _ := RootCmd.PersistentFlags().GetBool("log-file")
+	if env := os.Getenv(clinote.LogEnv); env != "" {
+		name := env
+		if i := strings.Index(env, ":"); i != -1 {
+			name = env[:i]
+			toFile = toFile || env[i+1:] == "file"
+		}
+		l, err := clinote.ParseLogLevel(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error in "+clinote.LogEnv+":", err)
+		}
+		level = l
+	}
+	if on, _ := RootCmd.PersistentFlags().GetBool("verbose"); on && level < clinote.LogLevelVerbose {
+		level = clinote.LogLevelVerbose
+	}
+	if on, _ := RootCmd.PersistentFlags().GetBool("debug"); on {
+		level = clinote.LogLevelDebug
+	}
+	if level == clinote.LogLevelOff {
+		return
+	}
+	var w io.Writer = os.Stderr
+	if toFile {
+		f, err := clinote.DefaultLogFile((new(clinote.DefaultConfig)).GetConfigFolder())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error when opening the log file:", err)
+			return
+		}
+		onShutdown(func() { f.Close() })
+		w = f
+	}
+	clinote.SetLogOutput(w, level)
+	clinote.LogVerbose("started", "version", version)
+}
+
+// setTrace writes the API requests and responses to the file given by the
+// --trace flag. Calls made by a running daemon are not traced.
+func setTrace() {
+	path, _ := RootCmd.PersistentFlags().GetString("trace")
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error when opening the trace file:", err)
+		os.Exit(1)
+	}
+	onShutdown(func() { f.Close() })
+	evernote.EnableTrace(f)
+}
+
+// setRecordReplay records the API session to the file given by the --record
+// flag or replays the session in the file given by the --replay flag.
+func setRecordReplay() {
+	if path, _ := RootCmd.PersistentFlags().GetString("replay"); path != "" {
+		rec, err := evernote.LoadRecording(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error when loading the recording:", err)
+			os.Exit(1)
+		}
+		evernote.EnableReplay(rec)
+	}
+	if path, _ := RootCmd.PersistentFlags().GetString("record"); path != "" {
+		evernote.EnableRecord(path)
+	}
+}
+
+// useDaemon returns false if the API calls have to be made by this process
+// to be recorded or replayed.
+func useDaemon() bool {
+	record, _ := RootCmd.PersistentFlags().GetString("record")
+	return record == "" && !evernote.Replaying()
+}
+
+// exitBudgetUsed is the exit code when the API call budget was used up
+// before the command completed, so scripts can tell it should be run again.
+const exitBudgetUsed = 75
+
+// setAPIBudget limits the API calls to the number given by the
+// --max-api-calls flag. The calls made by a running daemon count too.
+func setAPIBudget() {
+	max, _ := RootCmd.PersistentFlags().GetInt("max-api-calls")
+	if max >= 0 {
+		clinote.SetAPIBudget(max)
+	}
+}
+
+// stopIfBudgetUsed exits if the error is caused by the API call budget
+// being used up. The checkpoint, if not nil, is saved so the next run
+// continues where this one stopped.
+func stopIfBudgetUsed(err error, db clinote.Storager, cp *clinote.Checkpoint) {
+	if !clinote.IsAPIBudgetUsed(err) {
+		return
+	}
+	fmt.Printf("Stopped after %d API calls.\n", clinote.APICalls())
+	if cp != nil {
+		if err := clinote.StoreCheckpoint(db, cp); err != nil {
+			fmt.Println("Error when saving the checkpoint:", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println("Run the command again to continue.")
+	os.Exit(exitBudgetUsed)
+}
+
+// ephemeralMode returns true if the database should be kept in memory for
+// the invocation. Nothing is written to the config folder's database.
+func ephemeralMode() bool {
+	on, _ := RootCmd.PersistentFlags().GetBool("ephemeral")
+	return on
+}
+
+// dryRunMode returns true if changes should be printed instead of being
+// sent to the server.
+func dryRunMode() bool {
+	on, _ := RootCmd.PersistentFlags().GetBool("dry-run")
+	return on
+}
+
+// printProgress writes the progress message to stderr. The line is
+// rewritten on each update. In accessible mode only the final message
+// is written.
+func printProgress(msg string, final bool) {
+	if a11yMode() {
+		if final {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r%s", msg)
+	if final {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// tableOptions returns the table options set by the command line flags.
+func tableOptions(cmd *cobra.Command) clinote.TableOption {
+	opts := clinote.DefaultTableOption
+	if wide, _ := cmd.Flags().GetBool("wide"); wide {
+		opts |= clinote.WideTable
+	}
+	if noTruncate, _ := cmd.Flags().GetBool("no-truncate"); noTruncate {
+		opts |= clinote.NoTruncate
+	}
+	if a11yMode() {
+		opts |= clinote.Accessible
+	}
+	return opts
+}
+
+// linkOptions returns the table options with the Hyperlinks option added
+// if hyperlinks are turned on for standard out. The credential is used for
+// the links to the web client.
+func linkOptions(cmd *cobra.Command, cfg clinote.Configuration) (clinote.TableOption, *clinote.Credential) {
+	opts := tableOptions(cmd)
+	settings, err := cfg.Store().GetSettings()
+	if err != nil || !clinote.UseHyperlinks(os.Stdout, settings.Hyperlinks) {
+		return opts, nil
+	}
+	return opts | clinote.Hyperlinks, activeCredential(cfg)
 }
@@ -0,0 +1,92 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/TcM1911/clinote"
+)
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownFuncs []func()
+)
+
+// onShutdown registers a function that is called when the program is
+// stopped by a signal. The functions are called in the reverse order they
+// were registered.
+func onShutdown(fn func()) {
+	shutdownMu.Lock()
+	shutdownFuncs = append(shutdownFuncs, fn)
+	shutdownMu.Unlock()
+}
+
+// handleSignals shuts the program down gracefully on SIGINT and SIGTERM.
+// The registered shutdown functions close the storage, which saves the
+// pending cache writes and waits for the in-flight transaction to
+// complete, before the program exits. A second signal exits immediately.
+func handleSignals() {
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		code := 130
+		if sig == syscall.SIGTERM {
+			code = 143
+		}
+		go func() {
+			<-sigChan
+			os.Exit(code)
+		}()
+		shutdownMu.Lock()
+		fns := shutdownFuncs
+		shutdownMu.Unlock()
+		for i := len(fns) - 1; i >= 0; i-- {
+			fns[i]()
+		}
+		os.Exit(code)
+	}()
+}
+
+// recoverJournal recovers the operations that were interrupted the last
+// time the storage was used and warns the user about what was rolled back.
+func recoverJournal(db clinote.Storager) {
+	intents, err := clinote.RecoverJournal(db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error when recovering interrupted operations:", err)
+		return
+	}
+	for _, i := range intents {
+		started := i.Started.Format("2006-01-02 15:04:05")
+		switch i.Operation {
+		case clinote.OpPushChange:
+			fmt.Fprintf(os.Stderr, "Warning: the push of queued change %s was interrupted at %s. It may already be on the server, check with \"sync status\" and retry or discard it.\n", i.ChangeID, started)
+		case clinote.OpUpdateNote:
+			fmt.Fprintf(os.Stderr, "Warning: a note update was interrupted at %s. The last note listing was cleared, list the notes again.\n", started)
+		}
+	}
+	edits, err := clinote.GetInterruptedEdits(db)
+	if err == nil && len(edits) != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d note edits were not saved. Unless they're open in an editor, resume them with \"clinote note recover\".\n", len(edits))
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [notebook]",
+	Short: "Show statistics of the notes.",
+	Long: `
+Stats counts the synced notes, or the notes in the notebook, per notebook
+and per tag with the number of words in them. The largest notes and the
+notes that haven't been updated the longest are listed.
+
+Words and characters are counted in the Markdown text of the notes. The
+note contents are read from the content cache when possible, so the
+first run can take a while. The all flag includes the notebooks excluded
+from sync.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			cmd.Usage()
+			return
+		}
+		noteStats(cmd, args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().Bool("all", false, "Include the notebooks excluded from sync.")
+	statsCmd.Flags().Int("days", clinote.DefaultStaleDays, "Days without updates before a note is stale.")
+	statsCmd.Flags().Int("top", clinote.DefaultStatsTop, "Number of largest and stale notes to list.")
+}
+
+func noteStats(cmd *cobra.Command, args []string) {
+	all, _ := cmd.Flags().GetBool("all")
+	opts := new(clinote.StatsOptions)
+	opts.StaleDays, _ = cmd.Flags().GetInt("days")
+	opts.Top, _ = cmd.Flags().GetInt("top")
+	if opts.StaleDays < 1 || opts.Top < 0 {
+		fmt.Println("Error, the days have to be positive and the top can't be negative.")
+		os.Exit(1)
+	}
+	client := defaultClient()
+	defer client.Close()
+	db := client.GetConfig().Store()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	filter := new(clinote.NoteFilter)
+	if len(args) > 0 {
+		book, err := clinote.FindNotebook(db, ns, args[0])
+		if err != nil {
+			fmt.Println("Error when getting the notebook:", err)
+			os.Exit(1)
+		}
+		filter.NotebookGUID = book.GUID
+	}
+	notes, err := clinote.FindAllNotes(ns, filter, clinote.DefaultBulkPageSize)
+	if err == nil && len(args) == 0 && !all {
+		notes, err = clinote.FilterSelectedNotes(db, ns, notes)
+	}
+	if err != nil {
+		fmt.Println("Error when searching for notes:", err)
+		os.Exit(1)
+	}
+	counted := 0
+	progress := func(*clinote.Note) {
+		counted++
+		printProgress(fmt.Sprintf("Counted %d of %d notes", counted, len(notes)), counted == len(notes))
+	}
+	stats, err := clinote.NoteStatistics(db, ns, notes, opts, progress)
+	if err != nil {
+		fmt.Println("Error when counting the notes:", err)
+		os.Exit(1)
+	}
+	clinote.WriteNoteStats(os.Stdout, stats, tableOptions(cmd))
+}
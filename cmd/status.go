@@ -0,0 +1,94 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the account, sync and backup status.",
+	Long: `
+Status shows the logged in account, when the notes were last synced and
+backed up, the changes waiting in the offline queue and when the
+credential expires. The server isn't contacted.
+
+A warning is shown before commands talk to the server if the last sync
+or backup is older than 7 days. Change the number of days with:
+  clinote user set sync.stale-warning 14`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err.Error())
+			os.Exit(1)
+		}
+		defer db.Close()
+		status, err := clinote.GetStatus(db, db)
+		if err != nil {
+			fmt.Println("Error when getting the status:", err)
+			os.Exit(1)
+		}
+		writeStatus(status, tableOptions(cmd))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(statusCmd)
+}
+
+func writeStatus(s *clinote.Status, opts clinote.TableOption) {
+	now := time.Now()
+	account := "Not logged in"
+	if s.Credential != nil {
+		account = fmt.Sprintf("%s (%s)", s.Credential.DisplayName(), s.Credential.ServiceName())
+	}
+	queue := fmt.Sprintf("%d changes", s.Pending)
+	if s.Failed != 0 {
+		queue += fmt.Sprintf(", %d failed", s.Failed)
+	}
+	expires := "Unknown"
+	if !s.Expires.IsZero() {
+		if left := s.Expires.Sub(now); left > 0 {
+			expires = fmt.Sprintf("%s, in %s", s.Expires.Format("2006-01-02 15:04"), clinote.FormatTimeLeft(left))
+		} else {
+			expires = s.Expires.Format("2006-01-02 15:04") + ", expired"
+		}
+	}
+	fields := [][2]string{
+		{"Account", account},
+		{"Last sync", formatLastTime(s.LastSync, now)},
+		{"Last backup", formatLastTime(s.LastBackup, now)},
+		{"Offline queue", queue},
+		{"Expires", expires},
+	}
+	clinote.WriteFields(os.Stdout, fields, opts)
+}
+
+// formatLastTime formats the time and how long ago it was.
+func formatLastTime(t, now time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return fmt.Sprintf("%s, %s ago", t.Local().Format("2006-01-02 15:04"), clinote.FormatTimeLeft(now.Sub(t)))
+}
@@ -0,0 +1,80 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var noteSuggestTagsCmd = &cobra.Command{
+	Use:   "suggest-tags \"note title\"",
+	Short: "Suggest existing tags for a note.",
+	Long: `
+Suggest-tags extracts the keywords from the note's title and content and
+suggests the existing tags that match them. Only tags that already exist
+are suggested and tags on the note are skipped.
+
+With the apply flag, all the suggested tags are added to the note.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note has to be given.")
+			return
+		}
+		count, _ := cmd.Flags().GetInt("count")
+		apply, _ := cmd.Flags().GetBool("apply")
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		db := client.GetConfig().Store()
+		n, suggestions, err := clinote.SuggestTags(db, ns, args[0], count)
+		if err != nil {
+			fmt.Println("Error when suggesting tags:", err)
+			os.Exit(1)
+		}
+		if len(suggestions) == 0 {
+			fmt.Println("No tags to suggest.")
+			return
+		}
+		for _, s := range suggestions {
+			fmt.Printf("%s (matches: %s)\n", s.Tag.Name, strings.Join(s.Keywords, ", "))
+		}
+		if !apply {
+			return
+		}
+		if err = clinote.ApplyTagSuggestions(db, ns, n, suggestions); err != nil {
+			fmt.Println("Error when tagging the note:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added %d tags to %s.\n", len(suggestions), n.Title)
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(noteSuggestTagsCmd)
+	noteSuggestTagsCmd.Flags().IntP("count", "c", clinote.DefaultTagSuggestions, "Maximum number of tags to suggest.")
+	noteSuggestTagsCmd.Flags().Bool("apply", false, "Add all the suggested tags to the note.")
+}
@@ -0,0 +1,83 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var noteSummarizeCmd = &cobra.Command{
+	Use:   "summarize \"note title\"",
+	Short: "Summarize a note with an external command.",
+	Long: `
+Summarize pipes the note's content, converted to Markdown, through the
+command set with "user set summarize.command" and prints the summary.
+The command can be any program that reads text from stdin and writes
+the summary to stdout, for example a local language model CLI. The note
+title and GUID are passed in CLINOTE_NOTE_TITLE and CLINOTE_NOTE_GUID.
+
+The summary can be written to the top of the note with --write top, or
+to a new note in the same notebook with --write note.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note has to be given.")
+			return
+		}
+		write, _ := cmd.Flags().GetString("write")
+		if write != "" && write != "top" && write != "note" {
+			fmt.Println("Error, --write has to be top or note.")
+			os.Exit(1)
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		db := client.GetConfig().Store()
+		n, summary, err := clinote.SummarizeNote(db, ns, pickTitle(db, ns, args[0]))
+		if err != nil {
+			fmt.Println("Error when summarizing the note:", err)
+			os.Exit(1)
+		}
+		fmt.Println(summary)
+		switch write {
+		case "top":
+			err = clinote.PrependSummary(ns, n, summary)
+		case "note":
+			var s *clinote.Note
+			if s, err = clinote.CreateSummaryNote(db, ns, n, summary); err == nil {
+				fmt.Println("Saved the summary as", s.Title)
+			}
+		}
+		if err != nil {
+			fmt.Println("Error when saving the summary:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(noteSummarizeCmd)
+	noteSummarizeCmd.Flags().String("write", "", "Write the summary to the top of the note (top) or to a new note (note).")
+}
@@ -0,0 +1,184 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Push queued changes to the server.",
+	Long: `Pushes all locally queued changes to the server. Changes are queued
+when a note fails to be saved to the server.
+
+Captures waiting in the outbox are uploaded as well.
+
+The index of the links between the notes is updated with the notes
+changed since the last sync. The first sync reads the content of every
+note, from the content cache when possible, so it can take a long time on
+a large account. The no-backlinks flag skips the index.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		if err := clinote.Sync(client.GetConfig().Store(), ns); err != nil {
+			fmt.Println("Error when syncing:", err)
+			fmt.Println("Run \"clinote sync status\" to view the failed changes.")
+			os.Exit(1)
+		}
+		if count, err := clinote.FlushCaptures(client.GetConfig().Store(), ns); err != nil {
+			fmt.Println("Error when uploading the captures:", err)
+		} else if count != 0 {
+			fmt.Printf("Uploaded %d captures.\n", count)
+		}
+		if skip, _ := cmd.Flags().GetBool("no-backlinks"); !skip {
+			indexBacklinks(client.GetConfig().Store(), ns)
+		}
+	},
+}
+
+// indexBacklinks updates the backlink index with the notes changed since
+// the last sync. The changes have already been pushed, so failures are
+// only warned about. Storages without a backlink index are skipped.
+func indexBacklinks(db clinote.Storager, ns clinote.NotestoreClient) {
+	if _, ok := db.(clinote.BacklinkStore); !ok {
+		return
+	}
+	notes, err := clinote.FindAllNotes(ns, new(clinote.NoteFilter), clinote.DefaultBulkPageSize)
+	if err == nil {
+		notes, err = clinote.FilterSelectedNotes(db, ns, notes)
+	}
+	var changed []*clinote.Note
+	if err == nil {
+		changed, err = clinote.ChangedBacklinkNotes(db, notes)
+	}
+	if err != nil {
+		fmt.Println("Warning: the backlink index wasn't updated:", err)
+		return
+	}
+	indexed := 0
+	progress := func(*clinote.Note) {
+		indexed++
+		printProgress(fmt.Sprintf("Indexed links in %d of %d notes", indexed, len(changed)), indexed == len(changed))
+	}
+	if _, err = clinote.UpdateBacklinkIndex(db, ns, notes, progress); err != nil {
+		fmt.Println("Warning: the backlink index wasn't updated:", err)
+	}
+}
+
+var syncStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the sync status.",
+	Long: `Shows the time of the last successful sync, the local and server update
+sequence numbers (USN), the number of queued changes and any changes that
+failed to be pushed to the server.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		status, err := clinote.GetSyncStatus(client.GetConfig().Store(), ns)
+		if err != nil {
+			fmt.Println("Error when getting the sync status:", err)
+			os.Exit(1)
+		}
+		writeSyncStatus(status, tableOptions(cmd))
+	},
+}
+
+var syncRetryCmd = &cobra.Command{
+	Use:   "retry \"change id\"",
+	Short: "Retry a failed change.",
+	Long:  `Tries to push a queued change to the server again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a change id has to be given")
+			return
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		if err := clinote.RetryChange(client.GetConfig().Store(), ns, args[0]); err != nil {
+			fmt.Println("Error when retrying the change:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var syncDiscardCmd = &cobra.Command{
+	Use:   "discard \"change id\"",
+	Short: "Discard a queued change.",
+	Long:  `Removes a queued change without pushing it to the server.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a change id has to be given")
+			return
+		}
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		if err := clinote.DiscardChange(db, args[0]); err != nil {
+			fmt.Println("Error when discarding the change:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(syncCmd)
+	syncCmd.AddCommand(syncStatusCmd)
+	syncCmd.AddCommand(syncRetryCmd)
+	syncCmd.AddCommand(syncDiscardCmd)
+	syncCmd.Flags().Bool("no-backlinks", false, "Don't update the backlink index.")
+}
+
+func writeSyncStatus(status *clinote.SyncStatus, opts clinote.TableOption) {
+	lastSync := "never"
+	if !status.LastSync.IsZero() {
+		lastSync = status.LastSync.Local().Format("2006-01-02 15:04:05")
+	}
+	fmt.Println("Last sync: ", lastSync)
+	fmt.Println("Local USN: ", status.LocalUSN)
+	fmt.Println("Server USN:", status.ServerUSN)
+	fmt.Printf("Queued:     %d created, %d edited, %d deleted\n", status.Creations, status.Edits, status.Deletions)
+	if len(status.Failed) == 0 {
+		return
+	}
+	fmt.Println("\nFailed changes:")
+	clinote.WritePendingChangeListing(os.Stdout, status.Failed, opts)
+	fmt.Println("\nUse \"clinote sync retry <id>\" or \"clinote sync discard <id>\" to resolve the failed changes.")
+}
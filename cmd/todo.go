@@ -0,0 +1,166 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var todoCmd = &cobra.Command{
+	Use:   "todo",
+	Short: "List, check and uncheck the todos in notes.",
+	Long:  `List, check and uncheck the todos in notes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var todoListCmd = &cobra.Command{
+	Use:   "list [notebook]",
+	Short: "List unchecked todos across notes.",
+	Long: `
+List shows the unchecked todos in the synced notes, or the notes in the
+notebook, with the title of the note and the number of the todo in the
+note. The query flag selects the notes with the Evernote search grammar,
+for example 'tag:project'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listTodos(cmd, args)
+	},
+}
+
+var todoCheckCmd = &cobra.Command{
+	Use:   "check \"note title\" number",
+	Short: "Check a todo in a note.",
+	Long: `
+Check marks the todo as done without opening the note in the editor.
+The number is the todo's position in the note, as shown by todo list.
+Only the todo is changed in the note's content.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setTodo(args, true)
+	},
+}
+
+var todoUncheckCmd = &cobra.Command{
+	Use:   "uncheck \"note title\" number",
+	Short: "Uncheck a todo in a note.",
+	Long: `
+Uncheck marks the todo as not done without opening the note in the
+editor. The number is the todo's position in the note, as shown by todo
+list. Only the todo is changed in the note's content.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setTodo(args, false)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(todoCmd)
+	todoCmd.AddCommand(todoListCmd)
+	todoCmd.AddCommand(todoCheckCmd)
+	todoCmd.AddCommand(todoUncheckCmd)
+	todoListCmd.Flags().StringP("query", "q", "", "Search query selecting the notes.")
+}
+
+func listTodos(cmd *cobra.Command, args []string) {
+	query, err := cmd.Flags().GetString("query")
+	if err != nil {
+		fmt.Println("Error when parsing query:", err)
+		return
+	}
+	client := defaultClient()
+	defer client.Close()
+	db := client.GetConfig().Store()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	filter := &clinote.NoteFilter{Words: query, Order: clinote.NoteFilterOrderUpdated}
+	if len(args) > 0 {
+		book, err := clinote.FindNotebook(db, ns, args[0])
+		if err != nil {
+			fmt.Println("Error when getting the notebook:", err)
+			os.Exit(1)
+		}
+		filter.NotebookGUID = book.GUID
+	}
+	notes, err := clinote.FindAllNotes(ns, filter, clinote.DefaultBulkPageSize)
+	if err == nil && len(args) == 0 {
+		notes, err = clinote.FilterSelectedNotes(db, ns, notes)
+	}
+	if err != nil {
+		fmt.Println("Error when searching for notes:", err)
+		os.Exit(1)
+	}
+	fetched := 0
+	progress := func(*clinote.Note) {
+		fetched++
+		printProgress(fmt.Sprintf("Read %d of %d notes", fetched, len(notes)), false)
+	}
+	todos, err := clinote.FindTodos(db, ns, notes, progress)
+	if fetched > 0 && !a11yMode() {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		fmt.Println("Error when reading the notes:", err)
+		os.Exit(1)
+	}
+	if len(todos) == 0 {
+		fmt.Println("No unchecked todos.")
+		return
+	}
+	clinote.WriteTodoListing(os.Stdout, todos, tableOptions(cmd))
+}
+
+func setTodo(args []string, checked bool) {
+	if len(args) != 2 {
+		fmt.Println("Error, a note and the number of the todo have to be given.")
+		os.Exit(1)
+	}
+	number, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Println("Error, invalid todo number:", args[1])
+		os.Exit(1)
+	}
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	db := client.GetConfig().Store()
+	todo, err := clinote.SetTodo(db, ns, pickTitle(db, ns, args[0]), number, checked)
+	if err != nil && !reportQueued(err) {
+		fmt.Println("Error when changing the todo:", err)
+		os.Exit(1)
+	}
+	state := "unchecked"
+	if checked {
+		state = "checked"
+	}
+	if todo.Checked == checked {
+		fmt.Printf("The todo %q is already %s.\n", todo.Text, state)
+		return
+	}
+	fmt.Printf("The todo %q is %s.\n", todo.Text, state)
+}
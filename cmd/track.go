@@ -0,0 +1,143 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var trackCmd = &cobra.Command{
+	Use:   "track",
+	Short: "Track the time spent on projects.",
+	Long: `
+Track keeps a timesheet in notes. Each project has a note titled
+"Time tracking: <project>" where the start and stop times are added.
+Only one timer runs at a time, starting a new project stops the
+running one.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var trackStartCmd = &cobra.Command{
+	Use:   "start \"project\"",
+	Short: "Start the timer for a project.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Println("Error, a project has to be given.")
+			return
+		}
+		notebook, err := cmd.Flags().GetString("notebook")
+		if err != nil {
+			fmt.Println("Error when parsing notebook flag:", err)
+			return
+		}
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		project := strings.Join(args, " ")
+		stopped, err := clinote.StartTracking(client.GetConfig().Store(), ns, project, notebook, time.Now())
+		if err != nil {
+			fmt.Println("Error when starting the timer:", err)
+			os.Exit(1)
+		}
+		if stopped != "" {
+			fmt.Printf("Stopped tracking %s.\n", stopped)
+		}
+		fmt.Printf("Tracking %s.\n", strings.TrimSpace(project))
+	},
+}
+
+var trackStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running timer.",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		project, d, err := clinote.StopTracking(client.GetConfig().Store(), ns, time.Now())
+		if err != nil {
+			fmt.Println("Error when stopping the timer:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Stopped tracking %s after %s.\n", project, clinote.FormatTrackedTime(d))
+	},
+}
+
+var trackReportCmd = &cobra.Command{
+	Use:   "report [\"project\"]",
+	Short: "Summarize the tracked time.",
+	Long: `
+Report shows the time tracked per project. If a project is given,
+only that project is included. A running timer is counted until now.
+
+Use --week for the current week, starting Monday, or --since to give
+the start of the period, for example 2018-03-01.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		trackReport(cmd, args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(trackCmd)
+	trackCmd.AddCommand(trackStartCmd)
+	trackCmd.AddCommand(trackStopCmd)
+	trackCmd.AddCommand(trackReportCmd)
+	trackStartCmd.Flags().StringP("notebook", "b", "", "Notebook for a new tracking note, if not set the default notebook will be used.")
+	trackReportCmd.Flags().Bool("week", false, "Only include the current week.")
+	trackReportCmd.Flags().String("since", "", "Only include time tracked since, for example 2018-03-01 or \"last monday\".")
+}
+
+func trackReport(cmd *cobra.Command, args []string) {
+	now := time.Now()
+	since, err := parseTimeFlag(cmd, "since")
+	if err != nil {
+		fmt.Println("Error when parsing since flag:", err)
+		return
+	}
+	if week, _ := cmd.Flags().GetBool("week"); week {
+		since = clinote.StartOfWeek(now)
+	}
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Failed to get notestore:", err)
+		return
+	}
+	summaries, err := clinote.TrackingReport(client.GetConfig().Store(), ns, strings.Join(args, " "), since, now)
+	if err != nil {
+		fmt.Println("Error when creating the report:", err)
+		os.Exit(1)
+	}
+	clinote.WriteTrackingReport(os.Stdout, summaries, tableOptions(cmd))
+}
@@ -0,0 +1,80 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var noteTranscribeCmd = &cobra.Command{
+	Use:   "transcribe \"note title\"",
+	Short: "Transcribe the note's audio attachments.",
+	Long: `
+Transcribe runs the note's audio attachments through the command set with
+"user set transcribe.command" and appends the transcripts to the note.
+The command can be any program that writes the transcript to stdout, for
+example whisper.cpp. The audio file's path is passed in
+CLINOTE_AUDIO_FILE and its mime type in CLINOTE_AUDIO_MIME.
+
+Lines starting with a whisper.cpp style timestamp, like
+"[00:00:01.000 --> 00:00:04.500]", are added to the note with their
+start time. The progress is saved after each line. If the transcription
+is interrupted, running the command again resumes it. The offset in
+milliseconds to resume from is passed in CLINOTE_AUDIO_OFFSET, for
+example:
+
+  clinote user set transcribe.command \
+    'whisper-cli -m ggml-base.en.bin -f "$CLINOTE_AUDIO_FILE" -ot "$CLINOTE_AUDIO_OFFSET"'
+
+If the command needs another audio format, it has to convert the file
+first, for example with ffmpeg.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a note has to be given.")
+			return
+		}
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Failed to get notestore:", err)
+			return
+		}
+		progress := func(r *clinote.Resource, s *clinote.TranscriptSegment) {
+			if !quiet {
+				fmt.Println(s.Text)
+			}
+		}
+		n, err := clinote.TranscribeNote(client.GetConfig().Store(), ns, args[0], progress)
+		if err != nil {
+			fmt.Println("Error when transcribing the note:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Added the transcript to", n.Title)
+	},
+}
+
+func init() {
+	noteCmd.AddCommand(noteTranscribeCmd)
+	noteTranscribeCmd.Flags().BoolP("quiet", "q", false, "Don't print the transcript while transcribing.")
+}
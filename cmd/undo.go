@@ -0,0 +1,54 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo the last change to a note.",
+	Long: `
+Undo reverts the last edit, move, metadata change or deletion of a note.
+The note is saved as it was before the change and deleted notes are restored
+from the trash. Repeat the command to undo earlier changes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := defaultClient()
+		defer client.Close()
+		ns, err := client.GetNoteStore()
+		if err != nil {
+			fmt.Println("Error when getting the notestore:", err)
+			os.Exit(1)
+		}
+		entry, err := clinote.Undo(client.GetConfig().Store(), ns)
+		if err != nil {
+			fmt.Println("Error when undoing the change:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Undid the %s of %q from %s.\n", entry.Op, entry.Note.Title, entry.Time.Format("2006-01-02 15:04"))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(undoCmd)
+}
@@ -23,8 +23,11 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/daemon"
 	"github.com/TcM1911/clinote/storage"
 	"github.com/spf13/cobra"
 )
@@ -44,6 +47,8 @@ func init() {
 	userCmd.AddCommand(userRmCmd)
 	userCmd.AddCommand(userListCmd)
 	userCmd.AddCommand(userSetCmd)
+	userCmd.AddCommand(userStatusCmd)
+	userCmd.AddCommand(userInfoCmd)
 	// Add flags
 	userAddCmd.Flags().StringP("name", "n", "", "Username")
 	userAddCmd.Flags().StringP("secret", "s", "", "Access token")
@@ -56,7 +61,7 @@ var userListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all credentials",
 	Run: func(cmd *cobra.Command, args []string) {
-		db, err := storage.Open((new(clinote.DefaultConfig)).GetConfigFolder())
+		db, err := openStorage()
 		if err != nil {
 			fmt.Println("Error when opening the database:", err.Error())
 		}
@@ -64,12 +69,65 @@ var userListCmd = &cobra.Command{
 	},
 }
 
+var userStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the active credential",
+	Long: `
+Status shows the active credential, the server it logs in to and when
+it expires. A warning is shown before commands talk to the server if
+the credential expires within 7 days. Change the number of days with:
+  clinote user set credential.expiry-warning 14`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := openStorage()
+		if err != nil {
+			fmt.Println("Error when opening the database:", err.Error())
+			os.Exit(1)
+		}
+		defer db.Close()
+		userStatus(db, cmd)
+	},
+}
+
+var userInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the account and upload quota",
+	Long: `
+Info shows the logged in user's account and how much can still be
+uploaded before the upload quota is reset.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := defaultClient()
+		defer client.Close()
+		accounts, ok := client.(clinote.AccountInfoBackend)
+		if !ok {
+			fmt.Println("Error, the note service doesn't have account info.")
+			os.Exit(1)
+		}
+		info, err := accounts.GetAccountInfo()
+		if err != nil {
+			fmt.Println("Error when getting the account info:", err)
+			os.Exit(1)
+		}
+		fields := [][2]string{
+			{"Username", info.Username},
+			{"Name", info.Name},
+			{"Account type", info.AccountType},
+			{"Upload limit", clinote.FormatSize(info.UploadLimit)},
+			{"Uploaded", clinote.FormatSize(info.Uploaded)},
+			{"Remaining", clinote.FormatSize(info.Remaining())},
+		}
+		if !info.CycleEnd.IsZero() {
+			fields = append(fields, [2]string{"Quota resets", info.CycleEnd.Format("2006-01-02 15:04")})
+		}
+		clinote.WriteFields(os.Stdout, fields, tableOptions(cmd))
+	},
+}
+
 var userAddCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add new credential",
 	Long:  "Add a new credential set for the user. Please follow the instructions on https://dev.evernote.com/doc/articles/dev_tokens.php to generate access tokens.",
 	Run: func(cmd *cobra.Command, args []string) {
-		db, err := storage.Open((new(clinote.DefaultConfig)).GetConfigFolder())
+		db, err := openStorage()
 		if err != nil {
 			fmt.Println("Error when opening the database:", err.Error())
 		}
@@ -81,7 +139,7 @@ var userRmCmd = &cobra.Command{
 	Use:   "remove",
 	Short: "Remove a credential",
 	Run: func(cmd *cobra.Command, args []string) {
-		db, err := storage.Open((new(clinote.DefaultConfig)).GetConfigFolder())
+		db, err := openStorage()
 		if err != nil {
 			fmt.Println("Error when opening the database:", err.Error())
 		}
@@ -93,7 +151,12 @@ var userSetCmd = &cobra.Command{
 	Use:   "set",
 	Short: "Set a user configuration",
 	Run: func(cmd *cobra.Command, args []string) {
-		db, err := storage.Open((new(clinote.DefaultConfig)).GetConfigFolder())
+		if len(args) == 2 && args[0] == "storage" {
+			// The storage backend can't be open when the backend is changed.
+			setStorageBackend(args[1])
+			return
+		}
+		db, err := openStorage()
 		if err != nil {
 			fmt.Println("Error when opening the database:", err.Error())
 		}
@@ -107,6 +170,33 @@ var setConfigOpts = []struct {
 	desc string
 }{
 	{"credential", "An index value.", "Set the active credential for the user."},
+	{"storage", "bolt or sqlite", "Set the storage backend."},
+	{"sync.include", "Notebook names, comma separated.", "Limit sync, export and mirror to the notebooks."},
+	{"sync.exclude", "Notebook names, comma separated.", "Skip the notebooks when syncing, exporting and mirroring."},
+	{"cache.max-size", "A size, for example 500MB.", "Set the size limit for the local caches. 0 removes the limit."},
+	{"credential.expiry-warning", "Days, 0 turns it off.", "Warn when the active credential expires within the days."},
+	{"sync.stale-warning", "Days, 0 turns it off.", "Warn when the last sync or backup is older than the days."},
+	{"notebook.default", "A notebook name.", "Set the notebook used for new notes. An empty name uses the account's default."},
+	{"meeting.notebook", "A notebook name.", "Set the notebook used for meeting notes. An empty name uses the default notebook."},
+	{"output.hyperlinks", "auto, on or off", "Link note titles and exported files in terminals that support it."},
+	{"output.theme", "default, light, mono or none", "Set the colors of listings, notes and diffs. NO_COLOR turns the colors off."},
+	{"summarize.command", "A shell command.", "Set the command used by \"note summarize\". It reads the note from stdin."},
+	{"transcribe.command", "A shell command.", "Set the command used by \"note transcribe\". The audio file is in $CLINOTE_AUDIO_FILE."},
+	{"backup.dir", "A folder.", "Set the folder backups are written to. An empty folder uses the config folder."},
+	{"backup.keep", "A number.", "Set the number of backups kept when rotating. Default is 7."},
+	{"backup.format", "enex or snapshot", "Set the format of the backups."},
+	{"backup.interval", "An interval, for example 1d. off turns it off.", "Set how often a scheduled backup is made."},
+	{"db.linger", "A duration, for example 30s. Empty uses 5s.", "Set how long the database is held open after it was used."},
+	{"network.proxy", "A URL, for example socks5://localhost:1080.", "Set the http, https or socks5 proxy used for the API calls."},
+	{"network.ca-bundle", "A PEM file.", "Trust the certificates in the file in addition to the system's."},
+	{"network.tls-min-version", "1.0, 1.1, 1.2 or 1.3", "Set the lowest TLS version accepted."},
+	{"retry.attempts", "A number, 1 turns retries off.", "Set how many times an API call is made when the network fails. Default is 3."},
+	{"retry.delay", "A duration, for example 1s.", "Set the wait before the first retry, it doubles for each retry. Default is 500ms."},
+	{"retry.jitter", "A fraction between 0 and 1.", "Randomize the retry wait by up to the fraction."},
+	{"note.auto-title", "on or off", "Use the first heading or line of the content as the title of new notes without one."},
+	{"note.format", "markdown, org or asciidoc", "Set the format notes are shown and edited in."},
+	{"history.git", "A folder.", "Mirror fetched and edited notes to a git repository in the folder. An empty folder turns it off."},
+	{"alias.cmd.<name>", "A command, for example \"note list --count 5\".", "Add a command alias. An empty command removes the alias."},
 }
 
 func setConfig(store clinote.UserCredentialStore, db clinote.Storager, args []string) {
@@ -117,7 +207,33 @@ func setConfig(store clinote.UserCredentialStore, db clinote.Storager, args []st
 	switch args[0] {
 	case "credential":
 		setCredential(store, db, args[1])
+	case "sync.include", "sync.exclude":
+		setSyncSelection(db, args[0], args[1])
+	case "cache.max-size":
+		setCacheMaxSize(db, args[1])
+	case "notebook.default":
+		setDefaultNotebook(db, args[1])
+	case "meeting.notebook":
+		setMeetingNotebook(db, args[1])
+	case "credential.expiry-warning":
+		setExpiryWarning(db, args[1])
+	case "sync.stale-warning":
+		setStaleWarning(db, args[1])
+	case "output.hyperlinks":
+		setHyperlinks(db, args[1])
+	case "summarize.command":
+		setSummarizeCommand(db, args[1])
+	case "transcribe.command":
+		setTranscribeCommand(db, args[1])
+	case "output.theme", "backup.dir", "backup.keep", "backup.format", "backup.interval", "db.linger",
+		"network.proxy", "network.ca-bundle", "network.tls-min-version",
+		"retry.attempts", "retry.delay", "retry.jitter", "note.auto-title", "note.format", "history.git":
+		setSettingValue(db, args[0], args[1])
 	default:
+		if strings.HasPrefix(args[0], aliasSettingPrefix) && len(args[0]) > len(aliasSettingPrefix) {
+			setAlias(db, strings.TrimPrefix(args[0], aliasSettingPrefix), args[1])
+			return
+		}
 		printConfigOptions()
 	}
 }
@@ -138,19 +254,227 @@ func setCredential(store clinote.UserCredentialStore, db clinote.Storager, strIn
 		fmt.Println("Error index out-of-range")
 		return
 	}
+	cred, err := clinote.ActivateCredential(store, db, index-1)
+	if err != nil {
+		fmt.Println("Error when activating the credential:", err)
+		return
+	}
+	fmt.Printf("Using credential %d, %s.\n", index, cred.DisplayName())
+}
+
+func setSyncSelection(db clinote.Storager, key, list string) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	if key == "sync.include" {
+		settings.SyncInclude = clinote.ParseNotebookList(list)
+	} else {
+		settings.SyncExclude = clinote.ParseNotebookList(list)
+	}
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func setCacheMaxSize(db clinote.Storager, value string) {
+	size, err := clinote.ParseSize(value)
+	if err != nil {
+		fmt.Printf("%s is not a valid size\n", value)
+		return
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	settings.CacheMaxSize = size
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func setDefaultNotebook(db clinote.Storager, name string) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	settings.DefaultNotebook = name
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func setExpiryWarning(db clinote.Storager, value string) {
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		fmt.Printf("%s is not a valid number of days\n", value)
+		return
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	// Zero is stored as negative since zero means the default.
+	if days == 0 {
+		days = -1
+	}
+	settings.ExpiryWarning = days
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func setHyperlinks(db clinote.Storager, value string) {
+	mode, err := clinote.ParseHyperlinkMode(value)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	settings.Hyperlinks = mode
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func setSummarizeCommand(db clinote.Storager, command string) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	settings.SummarizeCommand = command
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func setTranscribeCommand(db clinote.Storager, command string) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	settings.TranscribeCommand = command
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func userStatus(db clinote.Storage, cmd *cobra.Command) {
+	cred, err := clinote.SessionCredential(db, db)
+	if err != nil {
+		fmt.Println("Error when getting the credential:", err)
+		return
+	}
+	if cred == nil {
+		fmt.Println("Not logged in.")
+		return
+	}
+	info := clinote.ParseToken(cred.Secret)
+	fields := [][2]string{
+		{"Credential", cred.DisplayName()},
+		{"Service", cred.ServiceName()},
+		{"Host", cred.APIHost()},
+	}
+	if info.UserID != 0 {
+		fields = append(fields, [2]string{"User ID", strconv.FormatInt(info.UserID, 10)})
+	}
+	if info.Shard != "" {
+		fields = append(fields, [2]string{"Shard", info.Shard})
+	}
+	expires := "Unknown"
+	if t := cred.ExpiresAt(); !t.IsZero() {
+		left := t.Sub(time.Now())
+		if left > 0 {
+			expires = fmt.Sprintf("%s, in %s", t.Format("2006-01-02 15:04"), clinote.FormatTimeLeft(left))
+		} else {
+			expires = t.Format("2006-01-02 15:04") + ", expired"
+		}
+	}
+	fields = append(fields, [2]string{"Expires", expires})
+	clinote.WriteFields(os.Stdout, fields, tableOptions(cmd))
+}
+
+func setSettingValue(db clinote.Storager, key, value string) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	if err = clinote.SetConfigValue(settings, key, value); err != nil {
+		fmt.Printf("Invalid value for %s: %s\n", key, err)
+		return
+	}
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func setStaleWarning(db clinote.Storager, value string) {
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		fmt.Printf("%s is not a valid number of days\n", value)
+		return
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		fmt.Println("Error when getting the settings:", err)
+		return
+	}
+	// Zero is stored as negative since zero means the default.
+	if days == 0 {
+		days = -1
+	}
+	settings.StaleWarning = days
+	err = db.StoreSettings(settings)
+	if err != nil {
+		fmt.Println("Error when saving the settings:", err)
+	}
+}
+
+func setMeetingNotebook(db clinote.Storager, name string) {
 	settings, err := db.GetSettings()
 	if err != nil {
 		fmt.Println("Error when getting the settings:", err)
 		return
 	}
-	settings.APIKey = creds[index-1].Secret
-	settings.Credential = creds[index-1]
+	settings.MeetingNotebook = name
 	err = db.StoreSettings(settings)
 	if err != nil {
 		fmt.Println("Error when saving the settings:", err)
 	}
 }
 
+func setStorageBackend(name string) {
+	cfgFolder := (new(clinote.DefaultConfig)).GetConfigFolder()
+	if d, err := daemon.Dial(daemon.SocketPath(cfgFolder)); err == nil {
+		d.Close()
+		fmt.Println("The daemon has to be stopped before the storage backend can be changed.")
+		return
+	}
+	err := storage.SetBackend(cfgFolder, name)
+	if err != nil {
+		fmt.Println("Error when changing the storage backend:", err)
+	}
+}
+
 func printConfigOptions() {
 	n := len(setConfigOpts)
 	vals, args, descs := make([]string, n, n), make([]string, n, n), make([]string, n, n)
@@ -159,7 +483,11 @@ func printConfigOptions() {
 		args[i] = cfg.args
 		descs[i] = cfg.desc
 	}
-	clinote.WriteSettingsListing(os.Stdout, vals, args, descs)
+	opts := clinote.DefaultTableOption
+	if a11yMode() {
+		opts |= clinote.Accessible
+	}
+	clinote.WriteSettingsListing(os.Stdout, vals, args, descs, opts)
 }
 
 func listCredentials(store clinote.UserCredentialStore, cmd *cobra.Command) {
@@ -174,10 +502,10 @@ func listCredentials(store clinote.UserCredentialStore, cmd *cobra.Command) {
 		return
 	}
 	if includeToken {
-		clinote.WriteCredentialListingWithSecret(os.Stdout, list)
+		clinote.WriteCredentialListingWithSecret(os.Stdout, list, tableOptions(cmd))
 		return
 	}
-	clinote.WriteCredentialListing(os.Stdout, list)
+	clinote.WriteCredentialListing(os.Stdout, list, tableOptions(cmd))
 }
 
 func rmCredential(store clinote.UserCredentialStore, args []string) {
@@ -209,11 +537,16 @@ func addCredential(store clinote.UserCredentialStore, cmd *cobra.Command, args [
 		fmt.Println("Error when parsing the command flag:", err)
 		return
 	}
-	credType := clinote.EvernoteCredential
+	ep, err := parseEndpointFlags(cmd)
+	if err != nil {
+		fmt.Println("Error when parsing the endpoint:", err)
+		return
+	}
+	credType := ep.CredentialType()
 	if sandbox {
 		credType = clinote.EvernoteSandboxCredential
 	}
-	err = clinote.AddNewCredential(store, name, secret, credType)
+	err = clinote.AddNewCredentialWithEndpoint(store, name, secret, credType, ep)
 	if err != nil {
 		fmt.Println("Error when adding the new credentials:", err)
 	}
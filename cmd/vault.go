@@ -0,0 +1,251 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Encrypted backups of notes.",
+	Long: `
+A vault is a single encrypted file holding notes with their
+attachments, the notebooks and the settings. The credentials are not
+included. The vault is encrypted with a key derived from a passphrase.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var vaultCreateCmd = &cobra.Command{
+	Use:   "create \"file\"",
+	Short: "Create a vault.",
+	Long: `
+Create writes all notes, or the notes matching the search, to a new
+vault. The passphrase is either typed at a prompt or read from a file,
+for example:
+  clinote vault create backup.cvault --passphrase-prompt`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("Error, a file has to be given")
+			return
+		}
+		createVault(cmd, args[0])
+	},
+}
+
+var vaultOpenCmd = &cobra.Command{
+	Use:   "open \"file\" [title]",
+	Short: "List the notes in a vault or show a note.",
+	Long: `
+Open lists the notes in the vault. If a title is given, the note with
+the title is printed instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 && len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		openVault(cmd, args)
+	},
+}
+
+var vaultExtractCmd = &cobra.Command{
+	Use:   "extract \"file\" \"folder\"",
+	Short: "Restore the notes in a vault to a folder.",
+	Long: `
+Extract writes the notes in the vault to the folder. Each notebook gets
+its own folder and the attachments of a note are written to a folder
+named after the note. The notebooks and the settings are written to
+vault.json.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		extractVault(cmd, args[0], args[1])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(vaultCmd)
+	vaultCmd.AddCommand(vaultCreateCmd)
+	vaultCmd.AddCommand(vaultOpenCmd)
+	vaultCmd.AddCommand(vaultExtractCmd)
+	for _, c := range []*cobra.Command{vaultCreateCmd, vaultOpenCmd, vaultExtractCmd} {
+		c.Flags().Bool("passphrase-prompt", false, "Ask for the passphrase.")
+		c.Flags().String("passphrase-file", "", "Read the passphrase from the file.")
+	}
+	vaultCreateCmd.Flags().StringP("search", "s", "", "Search term.")
+	vaultCreateCmd.Flags().StringP("notebook", "b", "", "Restrict search to notebook.")
+	vaultOpenCmd.Flags().Bool("raw", false, "Print the raw content instead of markdown encoded.")
+	vaultExtractCmd.Flags().Bool("raw", false, "Extract raw content instead of markdown encoded.")
+}
+
+// vaultPassphrase returns the passphrase given by the flags. When a new
+// vault is created, the passphrase typed at the prompt has to be repeated.
+func vaultPassphrase(cmd *cobra.Command, confirm bool) string {
+	if passphrase := passphraseFile(cmd); passphrase != "" {
+		return passphrase
+	}
+	if prompt, _ := cmd.Flags().GetBool("passphrase-prompt"); !prompt {
+		fmt.Println("Error, use --passphrase-prompt or --passphrase-file to give the passphrase")
+		os.Exit(1)
+	}
+	passphrase, err := clinote.ReadPassphrase("Vault passphrase: ", confirm)
+	if err != nil {
+		fmt.Println("Error when reading the passphrase:", err)
+		os.Exit(1)
+	}
+	return passphrase
+}
+
+func createVault(cmd *cobra.Command, file string) {
+	search, _ := cmd.Flags().GetString("search")
+	searchBook, _ := cmd.Flags().GetString("notebook")
+	passphrase := vaultPassphrase(cmd, true)
+
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	filter := &clinote.NoteFilter{Words: search, Order: clinote.NoteFilterOrderCreated}
+	if searchBook != "" {
+		book, err := clinote.FindNotebook(client.GetConfig().Store(), ns, searchBook)
+		if err != nil {
+			fmt.Println("Error when trying to filter by notebook:", err)
+			os.Exit(1)
+		}
+		filter.NotebookGUID = book.GUID
+	}
+	notes, err := clinote.FindAllNotes(ns, filter, clinote.DefaultBulkPageSize)
+	if err != nil {
+		fmt.Println("Error when searching for notes:", err)
+		os.Exit(1)
+	}
+
+	// Write to a temporary file so a failed run doesn't leave a broken vault.
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Println("Error when creating the vault:", err)
+		os.Exit(1)
+	}
+	progress := func(done, total int) {
+		printProgress(fmt.Sprintf("Added %d of %d notes", done, total), done == total)
+	}
+	err = clinote.CreateVault(f, client.GetConfig().Store(), ns, notes, passphrase, progress)
+	if fetchErr, ok := err.(*clinote.FetchError); ok {
+		for _, n := range notes {
+			if e, ok := fetchErr.Errors[n.GUID]; ok {
+				fmt.Printf("Failed to fetch \"%s\": %s\n", n.Title, e)
+			}
+		}
+	} else if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		fmt.Println("Error when creating the vault:", err)
+		os.Exit(1)
+	}
+	if err = f.Close(); err == nil {
+		err = os.Rename(tmp, file)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		fmt.Println("Error when saving the vault:", err)
+		os.Exit(1)
+	}
+	if err = clinote.RecordBackup(client.GetConfig().Store(), time.Now()); err != nil {
+		fmt.Println("Error when saving the backup time:", err)
+	}
+}
+
+// readVault opens the vault file and decrypts it with the passphrase.
+func readVault(cmd *cobra.Command, file string) (*clinote.VaultReader, *os.File) {
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Println("Error when opening the vault:", err)
+		os.Exit(1)
+	}
+	v, err := clinote.OpenVault(f, vaultPassphrase(cmd, false))
+	if err != nil {
+		f.Close()
+		fmt.Println("Error when opening the vault:", err)
+		os.Exit(1)
+	}
+	return v, f
+}
+
+func openVault(cmd *cobra.Command, args []string) {
+	v, f := readVault(cmd, args[0])
+	defer f.Close()
+	raw, _ := cmd.Flags().GetBool("raw")
+	opts := clinote.DefaultNoteOption
+	if raw {
+		opts |= clinote.RawNote
+	}
+	var notes []*clinote.Note
+	for {
+		n, err := v.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println("Error when reading the vault:", err)
+			os.Exit(1)
+		}
+		if len(args) == 2 && strings.EqualFold(n.Title, args[1]) {
+			clinote.WriteNote(os.Stdout, n, opts)
+			return
+		}
+		n.Body, n.MD, n.Resources = "", "", nil
+		notes = append(notes, n)
+	}
+	if len(args) == 2 {
+		fmt.Println("Error, no note in the vault with the title", args[1])
+		os.Exit(1)
+	}
+	fmt.Printf("Vault created %s\n", v.Manifest.Created.Format("2006-01-02 15:04"))
+	clinote.WriteNoteListing(os.Stdout, notes, v.Manifest.Notebooks, tableOptions(cmd))
+}
+
+func extractVault(cmd *cobra.Command, file, folder string) {
+	v, f := readVault(cmd, file)
+	defer f.Close()
+	raw, _ := cmd.Flags().GetBool("raw")
+	opts := clinote.DefaultNoteOption
+	if raw {
+		opts |= clinote.RawNote
+	}
+	count, err := clinote.ExtractVault(v, folder, opts)
+	if err != nil {
+		fmt.Println("Error when extracting the vault:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Extracted %d notes to %s\n", count, folder)
+}
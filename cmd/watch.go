@@ -0,0 +1,195 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/TcM1911/clinote"
+	"github.com/spf13/cobra"
+)
+
+var errNotificationsNotSupported = errors.New("desktop notifications are not supported on " + runtime.GOOS)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [directory]",
+	Short: "Watch notes, or a directory of Markdown files, for changes.",
+	Long: `
+Watch polls the server for changes to the notes matching the search
+filter. When a note is added, updated or removed, the change is
+printed. A shell command can be executed for each change with the
+exec flag. The change is passed to the command in the environment
+variables CLINOTE_CHANGE, CLINOTE_NOTE_TITLE and CLINOTE_NOTE_GUID.
+A desktop notification can be shown with the notify flag.
+
+If a directory is given, the Markdown files in it are synced to notes
+in the notebook given by the notebook flag. New files are created as
+notes and changed files update their notes, titled with the file name.
+The directory is synced when a file changes, and checked every 2 seconds
+unless the interval flag is set. Files that fail to sync are retried. The notes of the files are saved in .clinote-watch.json in the
+directory. Changes to the notes aren't written back to the files and
+notes aren't deleted when their files are removed.
+
+Watch runs until it's stopped with Ctrl-C.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			cmd.Usage()
+			return
+		}
+		if interval, _ := cmd.Flags().GetDuration("interval"); interval <= 0 {
+			fmt.Println("Error, the interval has to be positive.")
+			os.Exit(1)
+		}
+		if len(args) == 1 {
+			watchDir(cmd, args[0])
+			return
+		}
+		watchNotes(cmd)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().StringP("search", "s", "", "Search term.")
+	watchCmd.Flags().StringP("notebook", "b", "", "Restrict search to notebook, or the notebook for the files of a directory.")
+	watchCmd.Flags().IntP("count", "c", 100, "How many notes to watch.")
+	watchCmd.Flags().Duration("interval", clinote.DefaultWatchInterval, "Time between checks for changes.")
+	watchCmd.Flags().String("exec", "", "Shell command to execute for each change.")
+	watchCmd.Flags().Bool("notify", false, "Show a desktop notification for each change.")
+}
+
+func watchNotes(cmd *cobra.Command) {
+	search, _ := cmd.Flags().GetString("search")
+	searchBook, _ := cmd.Flags().GetString("notebook")
+	count, _ := cmd.Flags().GetInt("count")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	handler := changeHandler(cmd)
+
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	filter := &clinote.NoteFilter{Words: search, Order: clinote.NoteFilterOrderUpdated}
+	if searchBook != "" {
+		book, err := clinote.FindNotebook(client.GetConfig().Store(), ns, searchBook)
+		if err != nil {
+			fmt.Println("Error when trying to filter by notebook:", err)
+			os.Exit(1)
+		}
+		filter.NotebookGUID = book.GUID
+	}
+	watcher := clinote.NewWatcher(ns, filter, count)
+	watcher.Interval = interval
+
+	done := make(chan struct{})
+	onShutdown(func() { close(done) })
+	if err := watcher.Watch(done, handler); err != nil {
+		fmt.Println("Error when watching for changes:", err)
+		os.Exit(1)
+	}
+}
+
+func watchDir(cmd *cobra.Command, dir string) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("Error, %s is not a directory.\n", dir)
+		os.Exit(1)
+	}
+	notebook, _ := cmd.Flags().GetString("notebook")
+	handler := changeHandler(cmd)
+
+	client := defaultClient()
+	defer client.Close()
+	ns, err := client.GetNoteStore()
+	if err != nil {
+		fmt.Println("Error when getting the notestore:", err)
+		os.Exit(1)
+	}
+	var book *clinote.Notebook
+	if notebook != "" {
+		if book, err = clinote.FindNotebook(client.GetConfig().Store(), ns, notebook); err != nil {
+			fmt.Println("Error when getting the notebook:", err)
+			os.Exit(1)
+		}
+	}
+	watcher, err := clinote.NewDirWatcher(client.GetConfig().Store(), ns, dir, book)
+	if err != nil {
+		fmt.Println("Error when reading the watch state:", err)
+		os.Exit(1)
+	}
+	watcher.OnError = func(err error) {
+		if !reportQueued(err) {
+			fmt.Println("Error when syncing the directory, retrying:", err)
+		}
+	}
+	if cmd.Flags().Changed("interval") {
+		watcher.Interval, _ = cmd.Flags().GetDuration("interval")
+	}
+
+	done := make(chan struct{})
+	onShutdown(func() { close(done) })
+	if err := watcher.Watch(done, handler); err != nil {
+		fmt.Println("Error when syncing the directory:", err)
+		os.Exit(1)
+	}
+}
+
+// changeHandler returns the handler that prints the changes and runs the
+// hook and notifications given by the flags.
+func changeHandler(cmd *cobra.Command) func([]*clinote.NoteChange) error {
+	hook, _ := cmd.Flags().GetString("exec")
+	notify, _ := cmd.Flags().GetBool("notify")
+	if clinote.SafeMode && (hook != "" || notify) {
+		fmt.Println("Safe mode, the hook and notifications are disabled.")
+		hook, notify = "", false
+	}
+	return func(changes []*clinote.NoteChange) error {
+		for _, c := range changes {
+			fmt.Printf("Note %s: %s\n", c.Type, c.Note.Title)
+			if hook != "" {
+				if err := clinote.RunHook(hook, c); err != nil {
+					fmt.Println("Error when executing the hook:", err)
+				}
+			}
+			if notify {
+				if err := desktopNotification("CLInote", fmt.Sprintf("Note %s: %s", c.Type, c.Note.Title)); err != nil {
+					fmt.Println("Error when showing the notification:", err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func desktopNotification(title, msg string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", msg, title)
+		return exec.Command("osascript", "-e", script).Run()
+	case "windows":
+		return errNotificationsNotSupported
+	default:
+		return exec.Command("notify-send", title, msg).Run()
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"sort"
+	"strings"
+)
+
+// CompletionKind is the kind of value completed.
+type CompletionKind int
+
+const (
+	// CompleteNotebooks completes notebook names.
+	CompleteNotebooks CompletionKind = iota
+	// CompleteTags completes tag names.
+	CompleteTags
+	// CompleteNotes completes the note titles from the last search.
+	CompleteNotes
+)
+
+// CompletionCandidates returns the values of the kind that start with the
+// prefix. Only the local storage is used so completion doesn't have to
+// wait for the server. The values are sorted and without duplicates.
+func CompletionCandidates(db Storager, kind CompletionKind, prefix string) ([]string, error) {
+	var vals []string
+	switch kind {
+	case CompleteNotebooks:
+		list, err := db.GetNotebookCache()
+		if err != nil {
+			return nil, err
+		}
+		for _, nb := range list.Notebooks {
+			vals = append(vals, nb.Name)
+		}
+	case CompleteTags:
+		notes, err := db.GetSearch()
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range notes {
+			vals = append(vals, n.Tags...)
+		}
+		settings, err := db.GetSettings()
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range settings.NotebookDefaults {
+			vals = append(vals, d.Tags...)
+		}
+	case CompleteNotes:
+		notes, err := db.GetSearch()
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range notes {
+			vals = append(vals, n.Title)
+		}
+	}
+	return filterCandidates(vals, prefix), nil
+}
+
+func filterCandidates(vals []string, prefix string) []string {
+	seen := make(map[string]bool, len(vals))
+	var matched []string
+	for _, v := range vals {
+		if v == "" || seen[v] || !strings.HasPrefix(v, prefix) {
+			continue
+		}
+		seen[v] = true
+		matched = append(matched, v)
+	}
+	sort.Strings(matched)
+	return matched
+}
@@ -0,0 +1,55 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionCandidates(t *testing.T) {
+	assert := assert.New(t)
+	settings := new(Settings)
+	settings.SetNotebookDefaults("Meetings", &NotebookDefaults{Tags: []string{"meetings"}})
+	db := &mockStore{
+		getNotebookCache: func() (*NotebookCacheList, error) {
+			return &NotebookCacheList{Notebooks: []*Notebook{{Name: "Work"}, {Name: "Home"}, {Name: "Workshop"}}}, nil
+		},
+		getSearch: func() ([]*Note, error) {
+			return []*Note{
+				{Title: "Meeting notes", Tags: []string{"work", "meetings"}},
+				{Title: "Groceries", Tags: []string{"home"}},
+				{Title: "Meeting notes"},
+			}, nil
+		},
+		getSettings: func() (*Settings, error) { return settings, nil },
+	}
+
+	notebooks, err := CompletionCandidates(db, CompleteNotebooks, "Work")
+	assert.NoError(err)
+	assert.Equal([]string{"Work", "Workshop"}, notebooks)
+
+	tags, err := CompletionCandidates(db, CompleteTags, "")
+	assert.NoError(err)
+	assert.Equal([]string{"home", "meetings", "work"}, tags)
+
+	notes, err := CompletionCandidates(db, CompleteNotes, "Meet")
+	assert.NoError(err)
+	assert.Equal([]string{"Meeting notes"}, notes)
+}
@@ -21,8 +21,27 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 )
 
+// HomeEnv is the environment variable that overrides the folder used for
+// the configuration, database and cache.
+const HomeEnv = "CLINOTE_HOME"
+
+// SetHomeFolder makes all instances of DefaultConfig use the folder for
+// the configuration and database. The cache is stored in the "cache"
+// subfolder. It's used to point an invocation at another account or a
+// shared database, instead of the user's default folders.
+func SetHomeFolder(folder string) error {
+	abs, err := filepath.Abs(folder)
+	if err != nil {
+		return err
+	}
+	configDir = abs
+	cacheDir = filepath.Join(abs, "cache")
+	return nil
+}
+
 // Configuration is the interface for a configuration struct.
 type Configuration interface {
 	io.Closer
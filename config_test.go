@@ -0,0 +1,44 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetHomeFolder(t *testing.T) {
+	assert := assert.New(t)
+	oldConfig, oldCache := configDir, cacheDir
+	defer func() { configDir, cacheDir = oldConfig, oldCache }()
+	dir, err := ioutil.TempDir("", "clinote-home")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	home := filepath.Join(dir, "workspace")
+	assert.NoError(SetHomeFolder(home))
+	cfg := new(DefaultConfig)
+	assert.Equal(home, cfg.GetConfigFolder())
+	assert.Equal(filepath.Join(home, "cache"), cfg.GetCacheFolder())
+	_, err = os.Stat(filepath.Join(home, "cache"))
+	assert.NoError(err, "Cache folder should be created")
+}
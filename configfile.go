@@ -0,0 +1,586 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigFileName is the name of the config file in the config folder.
+const ConfigFileName = "config.toml"
+
+// aliasConfigPrefix is the prefix of the command alias keys.
+const aliasConfigPrefix = "alias.cmd."
+
+var (
+	// ErrUnknownConfigKey is returned for keys that are not supported by the config.
+	ErrUnknownConfigKey = errors.New("unknown config key")
+	// ErrInvalidDBLinger is returned if the database linger time is negative.
+	ErrInvalidDBLinger = errors.New("the database linger time can't be negative")
+)
+
+// configKey is a setting that can be set in the config file.
+type configKey struct {
+	name string
+	// list is true if the value is a list of strings.
+	list bool
+	get  func(s *Settings) string
+	set  func(s *Settings, value string) error
+}
+
+var configKeys = []*configKey{
+	{
+		name: "notebook.default",
+		get:  func(s *Settings) string { return s.DefaultNotebook },
+		set:  func(s *Settings, v string) error { s.DefaultNotebook = v; return nil },
+	},
+	{
+		name: "sync.include",
+		list: true,
+		get:  func(s *Settings) string { return strings.Join(s.SyncInclude, ",") },
+		set:  func(s *Settings, v string) error { s.SyncInclude = ParseNotebookList(v); return nil },
+	},
+	{
+		name: "sync.exclude",
+		list: true,
+		get:  func(s *Settings) string { return strings.Join(s.SyncExclude, ",") },
+		set:  func(s *Settings, v string) error { s.SyncExclude = ParseNotebookList(v); return nil },
+	},
+	{
+		name: "cache.max-size",
+		get: func(s *Settings) string {
+			if s.CacheMaxSize == 0 {
+				return ""
+			}
+			return FormatSize(s.CacheMaxSize)
+		},
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.CacheMaxSize = 0
+				return nil
+			}
+			size, err := ParseSize(v)
+			if err != nil {
+				return err
+			}
+			s.CacheMaxSize = size
+			return nil
+		},
+	},
+	{
+		name: "output.hyperlinks",
+		get:  func(s *Settings) string { return s.Hyperlinks },
+		set: func(s *Settings, v string) error {
+			mode, err := ParseHyperlinkMode(v)
+			if err != nil {
+				return err
+			}
+			s.Hyperlinks = mode
+			return nil
+		},
+	},
+	{
+		name: "output.theme",
+		get:  func(s *Settings) string { return s.Theme },
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.Theme = ""
+				return nil
+			}
+			name, err := ParseTheme(v)
+			if err != nil {
+				return err
+			}
+			s.Theme = name
+			return nil
+		},
+	},
+	{
+		name: "summarize.command",
+		get:  func(s *Settings) string { return s.SummarizeCommand },
+		set:  func(s *Settings, v string) error { s.SummarizeCommand = v; return nil },
+	},
+	{
+		name: "transcribe.command",
+		get:  func(s *Settings) string { return s.TranscribeCommand },
+		set:  func(s *Settings, v string) error { s.TranscribeCommand = v; return nil },
+	},
+	{
+		name: "backup.dir",
+		get:  func(s *Settings) string { return s.BackupDir },
+		set:  func(s *Settings, v string) error { s.BackupDir = v; return nil },
+	},
+	{
+		name: "backup.keep",
+		get: func(s *Settings) string {
+			if s.BackupKeep == 0 {
+				return ""
+			}
+			return strconv.Itoa(s.BackupKeep)
+		},
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.BackupKeep = 0
+				return nil
+			}
+			keep, err := strconv.Atoi(v)
+			if err != nil || keep < 1 {
+				return errors.New("the number of backups must be at least 1")
+			}
+			s.BackupKeep = keep
+			return nil
+		},
+	},
+	{
+		name: "backup.format",
+		get:  func(s *Settings) string { return s.BackupFormat },
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.BackupFormat = ""
+				return nil
+			}
+			format, err := ParseBackupFormat(v)
+			if err != nil {
+				return err
+			}
+			s.BackupFormat = format
+			return nil
+		},
+	},
+	{
+		name: "backup.interval",
+		get: func(s *Settings) string {
+			if s.BackupInterval == 0 {
+				return ""
+			}
+			return s.BackupInterval.String()
+		},
+		set: func(s *Settings, v string) error {
+			d, err := ParseBackupInterval(v)
+			if err != nil {
+				return err
+			}
+			s.BackupInterval = d
+			return nil
+		},
+	},
+	{
+		name: "db.linger",
+		get: func(s *Settings) string {
+			if s.DBLinger == 0 {
+				return ""
+			}
+			return s.DBLinger.String()
+		},
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.DBLinger = 0
+				return nil
+			}
+			d, err := ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			if d < 0 {
+				return ErrInvalidDBLinger
+			}
+			s.DBLinger = d
+			return nil
+		},
+	},
+	{
+		name: "network.proxy",
+		get:  func(s *Settings) string { return s.Proxy },
+		set: func(s *Settings, v string) error {
+			if v != "" {
+				if _, err := ParseProxyURL(v); err != nil {
+					return err
+				}
+			}
+			s.Proxy = v
+			return nil
+		},
+	},
+	{
+		name: "network.ca-bundle",
+		get:  func(s *Settings) string { return s.CABundle },
+		set:  func(s *Settings, v string) error { s.CABundle = v; return nil },
+	},
+	{
+		name: "network.tls-min-version",
+		get:  func(s *Settings) string { return s.TLSMinVersion },
+		set: func(s *Settings, v string) error {
+			if v != "" {
+				if _, err := ParseTLSVersion(v); err != nil {
+					return err
+				}
+			}
+			s.TLSMinVersion = v
+			return nil
+		},
+	},
+	{
+		name: "retry.attempts",
+		get: func(s *Settings) string {
+			if s.RetryAttempts == 0 {
+				return ""
+			}
+			return strconv.Itoa(s.RetryAttempts)
+		},
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.RetryAttempts = 0
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return ErrInvalidRetryAttempts
+			}
+			s.RetryAttempts = n
+			return nil
+		},
+	},
+	{
+		name: "retry.delay",
+		get: func(s *Settings) string {
+			if s.RetryDelay == 0 {
+				return ""
+			}
+			return s.RetryDelay.String()
+		},
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.RetryDelay = 0
+				return nil
+			}
+			d, err := ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			s.RetryDelay = d
+			return nil
+		},
+	},
+	{
+		name: "retry.jitter",
+		get: func(s *Settings) string {
+			if s.RetryJitter == 0 {
+				return ""
+			}
+			return strconv.FormatFloat(s.RetryJitter, 'g', -1, 64)
+		},
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.RetryJitter = 0
+				return nil
+			}
+			j, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return err
+			}
+			if err = ValidateRetryJitter(j); err != nil {
+				return err
+			}
+			s.RetryJitter = j
+			return nil
+		},
+	},
+	{
+		name: "note.auto-title",
+		get: func(s *Settings) string {
+			if s.DisableAutoTitle {
+				return "off"
+			}
+			return "on"
+		},
+		set: func(s *Settings, v string) error {
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "", "on", "true", "yes":
+				s.DisableAutoTitle = false
+			case "off", "false", "no":
+				s.DisableAutoTitle = true
+			default:
+				return fmt.Errorf("%q is not on or off", v)
+			}
+			return nil
+		},
+	},
+	{
+		name: "note.format",
+		get:  func(s *Settings) string { return s.Format },
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.Format = ""
+				return nil
+			}
+			name, err := ParseFormat(v)
+			if err != nil {
+				return err
+			}
+			s.Format = name
+			return nil
+		},
+	},
+	{
+		name: "history.git",
+		get:  func(s *Settings) string { return s.HistoryRepo },
+		set:  func(s *Settings, v string) error { s.HistoryRepo = v; return nil },
+	},
+}
+
+// findConfigKey returns the config key with the name.
+func findConfigKey(name string) (*configKey, error) {
+	for _, k := range configKeys {
+		if k.name == name {
+			return k, nil
+		}
+	}
+	if strings.HasPrefix(name, aliasConfigPrefix) && len(name) > len(aliasConfigPrefix) {
+		alias := strings.TrimPrefix(name, aliasConfigPrefix)
+		return &configKey{
+			name: name,
+			get:  func(s *Settings) string { return s.Aliases[alias] },
+			set: func(s *Settings, v string) error {
+				if v == "" {
+					delete(s.Aliases, alias)
+					return nil
+				}
+				if s.Aliases == nil {
+					s.Aliases = make(map[string]string)
+				}
+				s.Aliases[alias] = v
+				return nil
+			},
+		}, nil
+	}
+	return nil, ErrUnknownConfigKey
+}
+
+// ConfigKeys returns the names of the keys that can be set in the config
+// file, including the aliases defined in the settings.
+func ConfigKeys(s *Settings) []string {
+	keys := make([]string, 0, len(configKeys)+len(s.Aliases))
+	for _, k := range configKeys {
+		keys = append(keys, k.name)
+	}
+	aliases := make([]string, 0, len(s.Aliases))
+	for a := range s.Aliases {
+		aliases = append(aliases, aliasConfigPrefix+a)
+	}
+	sort.Strings(aliases)
+	return append(keys, aliases...)
+}
+
+// GetConfigValue returns the value of the setting with the key. List
+// values are returned comma separated.
+func GetConfigValue(s *Settings, key string) (string, error) {
+	k, err := findConfigKey(key)
+	if err != nil {
+		return "", err
+	}
+	return k.get(s), nil
+}
+
+// SetConfigValue sets the value of the setting with the key. List values
+// are given comma separated.
+func SetConfigValue(s *Settings, key, value string) error {
+	k, err := findConfigKey(key)
+	if err != nil {
+		return err
+	}
+	return k.set(s, value)
+}
+
+// ConfigFile is the human editable config file. Values in the file take
+// precedence over the settings saved in the storage.
+type ConfigFile struct {
+	// Path is the path to the file.
+	Path string
+	// content is the raw content, kept so comments are preserved when
+	// values are changed.
+	content []byte
+	values  map[string]interface{}
+}
+
+// LoadConfigFile reads the config file. If the file doesn't exist, an empty
+// config is returned.
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	cfg := &ConfigFile{Path: path, values: make(map[string]interface{})}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.values, err = parseTOML(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	cfg.content = data
+	return cfg, nil
+}
+
+// Apply sets the values from the config file on the settings.
+func (c *ConfigFile) Apply(s *Settings) error {
+	for name, val := range c.values {
+		k, err := findConfigKey(name)
+		if err != nil {
+			return fmt.Errorf("%s: %s %s", c.Path, err, name)
+		}
+		str, ok := tomlValueString(val)
+		if !ok {
+			return fmt.Errorf("%s: invalid value for %s", c.Path, name)
+		}
+		if err = k.set(s, str); err != nil {
+			return fmt.Errorf("%s: invalid value for %s: %s", c.Path, name, err)
+		}
+	}
+	return nil
+}
+
+// Keep copies the values for the keys set in the config file from stored
+// to s. It's used before the settings are saved so values from the config
+// file are not written to the storage.
+func (c *ConfigFile) Keep(s, stored *Settings) {
+	for name := range c.values {
+		if k, err := findConfigKey(name); err == nil {
+			k.set(s, k.get(stored))
+		}
+	}
+}
+
+// Set sets the value of the key in the config file. List values are
+// given comma separated. The file is not saved until Save is called.
+func (c *ConfigFile) Set(key, value string) error {
+	k, err := findConfigKey(key)
+	if err != nil {
+		return err
+	}
+	// Validate the value before it's written.
+	if err = k.set(new(Settings), value); err != nil {
+		return err
+	}
+	var encoded string
+	if k.list {
+		items := ParseNotebookList(value)
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			quoted[i] = strconv.Quote(item)
+		}
+		encoded = "[" + strings.Join(quoted, ", ") + "]"
+		c.values[key] = stringsToValues(items)
+	} else {
+		encoded = strconv.Quote(value)
+		c.values[key] = value
+	}
+	c.content = setTOMLLine(c.content, key, key+" = "+encoded)
+	return nil
+}
+
+// Save writes the config file.
+func (c *ConfigFile) Save() error {
+	return ioutil.WriteFile(c.Path, c.content, 0600)
+}
+
+// setTOMLLine replaces the line assigning the key with the new line. If
+// the key isn't assigned, the line is added at the end of the table the key
+// belongs to, or before the first table if there's no such table.
+func setTOMLLine(content []byte, key, line string) []byte {
+	lines := strings.Split(string(content), "\n")
+	table := ""
+	insert, tableEnd, tableName := -1, -1, ""
+	for i, l := range lines {
+		trimmed := stripComment(strings.TrimSpace(l))
+		if strings.HasPrefix(trimmed, "[") && assignIndex(trimmed) == -1 {
+			if insert == -1 {
+				insert = i
+			}
+			table = strings.TrimSpace(strings.Trim(trimmed, "[]"))
+			if strings.HasPrefix(key, table+".") {
+				tableEnd, tableName = i+1, table
+			}
+			continue
+		}
+		if trimmed != "" && table != "" && table == tableName {
+			tableEnd = i + 1
+		}
+		eq := assignIndex(trimmed)
+		if eq < 1 {
+			continue
+		}
+		name := unquoteKey(strings.TrimSpace(trimmed[:eq]))
+		if table != "" {
+			name = table + "." + name
+		}
+		if name != key {
+			continue
+		}
+		if table != "" {
+			line = strings.TrimPrefix(line, table+".")
+		}
+		// Remove the continuation lines of a multi-line array.
+		end := i + 1
+		for open := openBrackets(trimmed); open > 0 && end < len(lines); end++ {
+			open += openBrackets(stripComment(strings.TrimSpace(lines[end])))
+		}
+		return []byte(strings.Join(append(append(lines[:i:i], line), lines[end:]...), "\n"))
+	}
+	if tableEnd != -1 {
+		// A dotted key at the top level can't add to a table defined
+		// with a header.
+		line = strings.TrimPrefix(line, tableName+".")
+		return []byte(strings.Join(append(append(lines[:tableEnd:tableEnd], line), lines[tableEnd:]...), "\n"))
+	}
+	if insert == -1 {
+		if len(content) != 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+		return append(content, []byte(line+"\n")...)
+	}
+	return []byte(strings.Join(append(append(lines[:insert:insert], line), lines[insert:]...), "\n"))
+}
+
+// tomlValueString converts a TOML value to the string format used by
+// the config keys.
+func tomlValueString(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case []interface{}:
+		list, ok := tomlStringList(v)
+		return strings.Join(list, ","), ok
+	}
+	return "", false
+}
+
+func stringsToValues(list []string) []interface{} {
+	vals := make([]interface{}, len(list))
+	for i, s := range list {
+		vals[i] = s
+	}
+	return vals
+}
@@ -0,0 +1,162 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFileApply(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ConfigFileName)
+	content := `notebook.default = "Inbox"
+cache.max-size = "1KB"
+
+[sync]
+include = ["Work", "Home"]
+
+[alias.cmd]
+ls = "note list"
+`
+	assert.NoError(ioutil.WriteFile(path, []byte(content), 0600))
+
+	cfg, err := LoadConfigFile(path)
+	assert.NoError(err)
+	s := &Settings{APIKey: "key", Aliases: map[string]string{"nl": "notebook list"}}
+	assert.NoError(cfg.Apply(s))
+	assert.Equal(&Settings{
+		APIKey:          "key",
+		DefaultNotebook: "Inbox",
+		CacheMaxSize:    1024,
+		SyncInclude:     []string{"Work", "Home"},
+		Aliases:         map[string]string{"nl": "notebook list", "ls": "note list"},
+	}, s)
+
+	stored := &Settings{DefaultNotebook: "Stored"}
+	cfg.Keep(s, stored)
+	assert.Equal("Stored", s.DefaultNotebook)
+	assert.Equal(int64(0), s.CacheMaxSize)
+	assert.Nil(s.SyncInclude)
+	assert.Equal(map[string]string{"nl": "notebook list"}, s.Aliases)
+	assert.Equal("key", s.APIKey, "Keys not in the file should not change")
+}
+
+func TestConfigFileApplyErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":   "unknown = \"value\"",
+		"invalid size":  "cache.max-size = \"big\"",
+		"invalid list":  "sync.include = [1, 2]",
+		"invalid value": "notebook.default = true",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "clinote-config")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, ConfigFileName)
+			assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+			cfg, err := LoadConfigFile(path)
+			assert.NoError(t, err)
+			assert.Error(t, cfg.Apply(new(Settings)))
+		})
+	}
+}
+
+func TestConfigFileSet(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ConfigFileName)
+
+	t.Run("new file", func(t *testing.T) {
+		cfg, err := LoadConfigFile(path)
+		assert.NoError(err)
+		assert.NoError(cfg.Set("notebook.default", "Inbox"))
+		assert.NoError(cfg.Set("sync.include", "Work, Home"))
+		assert.NoError(cfg.Save())
+		data, _ := ioutil.ReadFile(path)
+		assert.Equal("notebook.default = \"Inbox\"\nsync.include = [\"Work\", \"Home\"]\n", string(data))
+	})
+	t.Run("replace value and keep comments", func(t *testing.T) {
+		content := "# My config\nnotebook.default = \"Inbox\" # default\n\n[sync]\ninclude = [\n  \"Work\",\n]\n"
+		assert.NoError(ioutil.WriteFile(path, []byte(content), 0600))
+		cfg, err := LoadConfigFile(path)
+		assert.NoError(err)
+		assert.NoError(cfg.Set("sync.include", "Home"))
+		assert.NoError(cfg.Set("notebook.default", "Work"))
+		assert.NoError(cfg.Set("alias.cmd.ls", "note list"))
+		assert.NoError(cfg.Save())
+		data, _ := ioutil.ReadFile(path)
+		assert.Equal("# My config\nnotebook.default = \"Work\"\n\nalias.cmd.ls = \"note list\"\n[sync]\ninclude = [\"Home\"]\n", string(data))
+
+		cfg, err = LoadConfigFile(path)
+		assert.NoError(err)
+		s := new(Settings)
+		assert.NoError(cfg.Apply(s))
+		assert.Equal([]string{"Home"}, s.SyncInclude)
+		assert.Equal("note list", s.Aliases["ls"])
+	})
+	t.Run("add to table", func(t *testing.T) {
+		content := "[sync]\ninclude = [\"Work\"]\n\n[alias.cmd]\n"
+		assert.NoError(ioutil.WriteFile(path, []byte(content), 0600))
+		cfg, err := LoadConfigFile(path)
+		assert.NoError(err)
+		assert.NoError(cfg.Set("sync.exclude", "Archive"))
+		assert.NoError(cfg.Set("alias.cmd.ls", "note list"))
+		assert.NoError(cfg.Save())
+		data, _ := ioutil.ReadFile(path)
+		assert.Equal("[sync]\ninclude = [\"Work\"]\nexclude = [\"Archive\"]\n\n[alias.cmd]\nls = \"note list\"\n", string(data))
+
+		_, err = LoadConfigFile(path)
+		assert.NoError(err, "The keys should be added to the tables")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		cfg, err := LoadConfigFile(path)
+		assert.NoError(err)
+		assert.Equal(ErrUnknownConfigKey, cfg.Set("unknown", "value"))
+		assert.Error(cfg.Set("cache.max-size", "big"))
+	})
+}
+
+func TestGetConfigValue(t *testing.T) {
+	assert := assert.New(t)
+	s := &Settings{SyncExclude: []string{"A", "B"}, CacheMaxSize: 2048, Aliases: map[string]string{"ls": "note list"}}
+
+	val, err := GetConfigValue(s, "sync.exclude")
+	assert.NoError(err)
+	assert.Equal("A,B", val)
+	val, _ = GetConfigValue(s, "cache.max-size")
+	assert.Equal("2.0KB", val)
+	val, _ = GetConfigValue(s, "alias.cmd.ls")
+	assert.Equal("note list", val)
+	_, err = GetConfigValue(s, "unknown")
+	assert.Equal(ErrUnknownConfigKey, err)
+
+	assert.Equal([]string{"notebook.default", "sync.include", "sync.exclude", "cache.max-size", "output.hyperlinks", "output.theme", "summarize.command", "transcribe.command",
+		"backup.dir", "backup.keep", "backup.format", "backup.interval", "db.linger", "network.proxy", "network.ca-bundle", "network.tls-min-version",
+		"retry.attempts", "retry.delay", "retry.jitter", "note.auto-title", "note.format", "history.git", "alias.cmd.ls"}, ConfigKeys(s))
+}
@@ -0,0 +1,198 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContentCache is the cache holding note contents.
+	ContentCache = "content"
+	// ResourceCache is the cache holding note resources.
+	ResourceCache = "resource"
+)
+
+// Caches are the caches that entries can be evicted from to stay under
+// the size limit.
+var Caches = []string{ContentCache, ResourceCache}
+
+var (
+	// ErrInvalidSize is returned if a size can't be parsed.
+	ErrInvalidSize = errors.New("invalid size")
+)
+
+// CacheStats holds the statistics for the local caches.
+type CacheStats struct {
+	// MaxSize is the size limit for all the caches. Zero means no limit.
+	MaxSize int64
+	// Caches holds the statistics for each cache.
+	Caches map[string]*CacheBucketStats
+}
+
+// Size returns the total size of all the caches.
+func (s *CacheStats) Size() int64 {
+	var size int64
+	for _, c := range s.Caches {
+		size += c.Size
+	}
+	return size
+}
+
+// CacheBucketStats holds the statistics for a cache.
+type CacheBucketStats struct {
+	// Entries is the number of entries in the cache.
+	Entries int
+	// Size is the total size of the entries in bytes.
+	Size int64
+	// Evictions is the number of entries that have been evicted.
+	Evictions int64
+}
+
+// cacheVersion is the version of the note a cache entry was made from.
+type cacheVersion struct {
+	Updated int64
+	USN     int32 `json:",omitempty"`
+}
+
+// noteCacheVersion returns the version of the note, and false if the note
+// has no version the cache entries can be checked against.
+func noteCacheVersion(n *Note) (cacheVersion, bool) {
+	return cacheVersion{Updated: n.Updated, USN: n.USN}, n.USN != 0 || n.Updated != 0
+}
+
+// valid returns true if the cache entry is from the current version of the
+// note. The update sequence number is used if the note has one, since it
+// advances on every change on the server. Otherwise, the updated time is
+// compared.
+func (c *cacheVersion) valid(n *Note) bool {
+	if n.USN != 0 {
+		return c.USN == n.USN
+	}
+	return n.Updated != 0 && c.Updated == n.Updated
+}
+
+// cachedContent is the note content saved in the content cache.
+type cachedContent struct {
+	cacheVersion
+	Content string
+}
+
+// cachedResources are the note resources saved in the resource cache.
+type cachedResources struct {
+	cacheVersion
+	Resources []*Resource
+}
+
+// getCachedNoteContent returns the note content from the content cache if the
+// note hasn't changed since it was cached. Otherwise, the content is fetched
+// from the notestore and added to the cache.
+func getCachedNoteContent(db Storager, ns NotestoreClient, n *Note) (string, error) {
+	data, err := db.GetCacheEntry(ContentCache, n.GUID)
+	if err != nil {
+		return "", err
+	}
+	var cached cachedContent
+	if data != nil && json.Unmarshal(data, &cached) == nil && cached.valid(n) {
+		return cached.Content, nil
+	}
+	content, err := ns.GetNoteContent(n.GUID)
+	if err != nil {
+		return "", err
+	}
+	version, ok := noteCacheVersion(n)
+	if !ok {
+		return content, nil
+	}
+	data, err = json.Marshal(&cachedContent{cacheVersion: version, Content: content})
+	if err != nil {
+		return "", err
+	}
+	return content, db.PutCacheEntry(ContentCache, n.GUID, data)
+}
+
+// getCachedNoteResources returns the note resources, including the data,
+// from the resource cache if the note hasn't changed since they were cached.
+// Otherwise, the resources are fetched from the notestore and added to the
+// cache.
+func getCachedNoteResources(db Storager, ns NotestoreClient, n *Note) ([]*Resource, error) {
+	data, err := db.GetCacheEntry(ResourceCache, n.GUID)
+	if err != nil {
+		return nil, err
+	}
+	var cached cachedResources
+	if data != nil && json.Unmarshal(data, &cached) == nil && cached.valid(n) {
+		return cached.Resources, nil
+	}
+	resources, err := ns.GetNoteResources(n.GUID)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := noteCacheVersion(n)
+	if !ok {
+		return resources, nil
+	}
+	data, err = json.Marshal(&cachedResources{cacheVersion: version, Resources: resources})
+	if err != nil {
+		return nil, err
+	}
+	return resources, db.PutCacheEntry(ResourceCache, n.GUID, data)
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size like "500MB". The units B, KB, MB and GB are supported.
+// A number without a unit is parsed as bytes.
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			multiplier = u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, ErrInvalidSize
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatSize formats the size in bytes using the largest fitting unit.
+func FormatSize(size int64) string {
+	for _, u := range sizeUnits[:len(sizeUnits)-1] {
+		if size >= u.size {
+			return fmt.Sprintf("%.1f%s", float64(size)/float64(u.size), u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
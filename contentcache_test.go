@@ -0,0 +1,132 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedNoteContent(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockStore{}
+	calls := 0
+	ns := &mockNS{getNoteContent: func(string) (string, error) { calls++; return "content", nil }}
+	n := &Note{GUID: "GUID", Updated: 1}
+
+	content, err := getCachedNoteContent(db, ns, n)
+	assert.NoError(err)
+	assert.Equal("content", content)
+	content, err = getCachedNoteContent(db, ns, n)
+	assert.NoError(err)
+	assert.Equal("content", content)
+	assert.Equal(1, calls, "Second call should use the cache")
+
+	n.Updated = 2
+	_, err = getCachedNoteContent(db, ns, n)
+	assert.NoError(err)
+	assert.Equal(2, calls, "Updated note should be fetched")
+}
+
+func TestCachedNoteContentUSN(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockStore{}
+	calls := 0
+	ns := &mockNS{getNoteContent: func(string) (string, error) { calls++; return "content", nil }}
+	n := &Note{GUID: "GUID", Updated: 1, USN: 10}
+
+	_, err := getCachedNoteContent(db, ns, n)
+	assert.NoError(err)
+	_, err = getCachedNoteContent(db, ns, n)
+	assert.NoError(err)
+	assert.Equal(1, calls, "Second call should use the cache")
+
+	n.USN = 11
+	_, err = getCachedNoteContent(db, ns, n)
+	assert.NoError(err)
+	assert.Equal(2, calls, "Note should be fetched when the USN has advanced")
+
+	n.Updated = 2
+	n.USN = 0
+	_, err = getCachedNoteContent(db, ns, n)
+	assert.NoError(err)
+	assert.Equal(3, calls, "Note without USN should use the updated time")
+
+	uncached := &Note{GUID: "OTHER", USN: 5}
+	_, err = getCachedNoteContent(db, ns, uncached)
+	assert.NoError(err)
+	_, err = getCachedNoteContent(db, ns, uncached)
+	assert.NoError(err)
+	assert.Equal(4, calls, "Note with only a USN should be cached")
+}
+
+func TestCachedNoteResources(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockStore{}
+	calls := 0
+	ns := &mockNS{getResources: func(string) ([]*Resource, error) {
+		calls++
+		return []*Resource{{Hash: "hash", Data: []byte("data")}}, nil
+	}}
+	n := &Note{GUID: "GUID", USN: 10}
+
+	resources, err := getCachedNoteResources(db, ns, n)
+	assert.NoError(err)
+	assert.Equal([]byte("data"), resources[0].Data)
+	resources, err = getCachedNoteResources(db, ns, n)
+	assert.NoError(err)
+	assert.Equal([]byte("data"), resources[0].Data)
+	assert.Equal(1, calls, "Second call should use the cache")
+
+	n.USN = 11
+	_, err = getCachedNoteResources(db, ns, n)
+	assert.NoError(err)
+	assert.Equal(2, calls, "Note should be fetched when the USN has advanced")
+
+	ns.getResources = func(string) ([]*Resource, error) { return nil, ErrNotSupported }
+	_, err = getCachedNoteResources(db, ns, &Note{GUID: "OTHER", USN: 1})
+	assert.Equal(ErrNotSupported, err)
+}
+
+func TestParseAndFormatSize(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"500MB", 500 << 20},
+		{"1.5 gb", 3 << 29},
+		{"10KB", 10 << 10},
+		{"42", 42},
+		{"0", 0},
+	}
+	for _, test := range tests {
+		size, err := ParseSize(test.input)
+		assert.NoError(err, "Should parse %s", test.input)
+		assert.Equal(test.expected, size, "Wrong size for %s", test.input)
+	}
+	_, err := ParseSize("a lot")
+	assert.Equal(ErrInvalidSize, err)
+	_, err = ParseSize("-1MB")
+	assert.Equal(ErrInvalidSize, err)
+
+	assert.Equal("500.0MB", FormatSize(500<<20))
+	assert.Equal("1.5KB", FormatSize(1536))
+	assert.Equal("12B", FormatSize(12))
+}
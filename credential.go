@@ -17,7 +17,10 @@
 
 package clinote
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
 	// ErrNoMatchingCredentialFound is returned if no matching credential is found.
@@ -30,10 +33,17 @@ var (
 
 // AddNewCredential creates and add a new credential to the store.
 func AddNewCredential(store UserCredentialStore, name, secret string, credType CredentialType) error {
+	return AddNewCredentialWithEndpoint(store, name, secret, credType, Endpoint{})
+}
+
+// AddNewCredentialWithEndpoint creates and add a new credential for the
+// endpoint to the store.
+func AddNewCredentialWithEndpoint(store UserCredentialStore, name, secret string, credType CredentialType, ep Endpoint) error {
 	cred := &Credential{
 		Name:     name,
 		Secret:   secret,
 		CredType: credType,
+		Endpoint: ep,
 	}
 	return store.Add(cred)
 }
@@ -80,3 +90,128 @@ func GetCredential(store UserCredentialStore, index int) (*Credential, error) {
 	}
 	return creds[index], nil
 }
+
+// DisplayName returns the label of the credential, or its name if it
+// doesn't have a label.
+func (c *Credential) DisplayName() string {
+	if c.Label != "" {
+		return c.Label
+	}
+	return c.Name
+}
+
+// SameIdentity returns true if both credentials are for the same account.
+// The label, the secret and the active flag can change, so only the name,
+// the type and the endpoint host are compared.
+func (c *Credential) SameIdentity(o *Credential) bool {
+	return c.Name == o.Name && c.CredType == o.CredType && c.Host == o.Host
+}
+
+// ActiveCredential returns the credential marked as active. Nil is
+// returned if no credential is active.
+func ActiveCredential(store UserCredentialStore) (*Credential, error) {
+	creds, err := GetAllCredentials(store)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range creds {
+		if c.Active {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// SessionCredential returns the credential used for the session. It's the
+// active credential in the store. For sessions from before credentials were
+// marked as active, the credential in the settings is used, or an OAuth
+// credential for the session key. Nil is returned if not logged in.
+func SessionCredential(store UserCredentialStore, db Storager) (*Credential, error) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if settings.APIKey == "" {
+		return nil, nil
+	}
+	active, err := ActiveCredential(store)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return active, nil
+	}
+	if settings.Credential != nil {
+		return settings.Credential, nil
+	}
+	return &Credential{Name: "OAuth", Secret: settings.APIKey}, nil
+}
+
+// ActivateCredential marks the credential at the index as active and the
+// others as inactive. The session is switched to the credential.
+func ActivateCredential(store UserCredentialStore, db Storager, index int) (*Credential, error) {
+	if _, err := GetCredential(store, index); err != nil {
+		return nil, err
+	}
+	if err := setActiveCredential(store, index); err != nil {
+		return nil, err
+	}
+	cred, err := GetCredential(store, index)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	settings.APIKey = cred.Secret
+	settings.Credential = cred
+	return cred, db.StoreSettings(settings)
+}
+
+// DeactivateCredentials clears the active flag on all the credentials.
+func DeactivateCredentials(store UserCredentialStore) error {
+	return setActiveCredential(store, -1)
+}
+
+func setActiveCredential(store UserCredentialStore, index int) error {
+	creds, err := GetAllCredentials(store)
+	if err != nil {
+		return err
+	}
+	for i, c := range creds {
+		if c.Active == (i == index) {
+			continue
+		}
+		updated := *c
+		updated.Active = i == index
+		if err = store.Update(i, &updated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenameCredential sets the label of the credential at the index. An
+// empty label removes it. If it's the active credential, the settings are
+// updated too.
+func RenameCredential(store UserCredentialStore, db Storager, index int, label string) (*Credential, error) {
+	cred, err := GetCredential(store, index)
+	if err != nil {
+		return nil, err
+	}
+	updated := *cred
+	updated.Label = strings.TrimSpace(label)
+	if err = store.Update(index, &updated); err != nil {
+		return nil, err
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if settings.Credential == nil || !settings.Credential.SameIdentity(cred) {
+		return &updated, nil
+	}
+	settings.Credential = &updated
+	return &updated, db.StoreSettings(settings)
+}
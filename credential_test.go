@@ -94,3 +94,128 @@ func TestRemoveCredential(t *testing.T) {
 		assert.Equal(ErrIndexToBig, err)
 	})
 }
+
+func TestActivateCredential(t *testing.T) {
+	assert := assert.New(t)
+	list := []*Credential{
+		&Credential{Name: "Cred1", Secret: "secret1", Active: true},
+		&Credential{Name: "Cred2", Secret: "secret2"},
+	}
+	store := &mockCredentialStore{
+		getAll: func() ([]*Credential, error) { return list, nil },
+		update: func(i int, c *Credential) error { list[i] = c; return nil },
+	}
+	var saved *Settings
+	db := &mockStore{
+		getSettings:   func() (*Settings, error) { return &Settings{APIKey: "secret1"}, nil },
+		storeSettings: func(s *Settings) error { saved = s; return nil },
+	}
+
+	active, err := ActiveCredential(store)
+	assert.NoError(err)
+	assert.Equal("Cred1", active.Name)
+
+	cred, err := ActivateCredential(store, db, 1)
+	assert.NoError(err)
+	assert.Equal("Cred2", cred.Name)
+	assert.True(cred.Active)
+	assert.False(list[0].Active, "Previous credential should not be active")
+	if assert.NotNil(saved) {
+		assert.Equal("secret2", saved.APIKey)
+		assert.Equal(cred, saved.Credential)
+	}
+
+	_, err = ActivateCredential(store, db, 2)
+	assert.Equal(ErrIndexToBig, err)
+
+	assert.NoError(DeactivateCredentials(store))
+	active, err = ActiveCredential(store)
+	assert.NoError(err)
+	assert.Nil(active)
+}
+
+func TestRenameCredential(t *testing.T) {
+	assert := assert.New(t)
+	active := &Credential{Name: "OAuth", Secret: "secret", Active: true}
+	list := []*Credential{active}
+	store := &mockCredentialStore{
+		getAll: func() ([]*Credential, error) { return list, nil },
+		update: func(i int, c *Credential) error { list[i] = c; return nil },
+	}
+	var saved *Settings
+	db := &mockStore{
+		getSettings:   func() (*Settings, error) { return &Settings{Credential: active}, nil },
+		storeSettings: func(s *Settings) error { saved = s; return nil },
+	}
+
+	cred, err := RenameCredential(store, db, 0, " Work ")
+	assert.NoError(err)
+	assert.Equal("Work", cred.Label)
+	assert.Equal("Work", cred.DisplayName())
+	assert.Equal(cred, list[0])
+	if assert.NotNil(saved) {
+		assert.Equal(cred, saved.Credential)
+	}
+
+	cred, err = RenameCredential(store, db, 0, "")
+	assert.NoError(err)
+	assert.Equal("OAuth", cred.DisplayName())
+}
+
+func TestRenameRelabeledCredential(t *testing.T) {
+	assert := assert.New(t)
+	list := []*Credential{{Name: "OAuth", Secret: "secret", Label: "Old"}}
+	store := &mockCredentialStore{
+		getAll: func() ([]*Credential, error) { return list, nil },
+		update: func(i int, c *Credential) error { list[i] = c; return nil },
+	}
+	var saved *Settings
+	db := &mockStore{
+		// The settings still have the active flag set on the copy.
+		getSettings: func() (*Settings, error) {
+			return &Settings{Credential: &Credential{Name: "OAuth", Secret: "secret", Active: true}}, nil
+		},
+		storeSettings: func(s *Settings) error { saved = s; return nil },
+	}
+
+	cred, err := RenameCredential(store, db, 0, "Work")
+	assert.NoError(err)
+	if assert.NotNil(saved, "Settings should be updated") {
+		assert.Equal(cred, saved.Credential)
+	}
+}
+
+func TestSameIdentity(t *testing.T) {
+	assert := assert.New(t)
+	cred := &Credential{Name: "OAuth", Secret: "secret", Endpoint: Endpoint{Host: SandboxHost}}
+	assert.True(cred.SameIdentity(&Credential{Name: "OAuth", Secret: "new", Label: "Work", Active: true, Endpoint: Endpoint{Host: SandboxHost}}))
+	assert.False(cred.SameIdentity(&Credential{Name: "OAuth", Secret: "secret"}))
+	assert.False(cred.SameIdentity(&Credential{Name: "Other", Secret: "secret", Endpoint: Endpoint{Host: SandboxHost}}))
+}
+
+func TestSessionCredential(t *testing.T) {
+	assert := assert.New(t)
+	list := []*Credential{{Name: "Work", Secret: "work"}, {Name: "Home", Secret: "home", Active: true}}
+	store := &mockCredentialStore{getAll: func() ([]*Credential, error) { return list, nil }}
+	settings := &Settings{APIKey: "home", Credential: &Credential{Name: "Stale", Secret: "home"}}
+	db := &mockStore{getSettings: func() (*Settings, error) { return settings, nil }}
+
+	cred, err := SessionCredential(store, db)
+	assert.NoError(err)
+	assert.Equal(list[1], cred, "Should return the active credential")
+
+	list[1].Active = false
+	cred, err = SessionCredential(store, db)
+	assert.NoError(err)
+	assert.Equal(settings.Credential, cred, "Should fall back to the settings")
+
+	settings.Credential = nil
+	cred, err = SessionCredential(store, db)
+	assert.NoError(err)
+	assert.Equal(&Credential{Name: "OAuth", Secret: "home"}, cred)
+
+	settings.APIKey = ""
+	cred, err = SessionCredential(store, db)
+	assert.NoError(err)
+	assert.Nil(cred, "Should be nil when not logged in")
+}
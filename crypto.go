@@ -0,0 +1,168 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	saltSize        = 16
+	keySize         = 32
+	keyDerivingRuns = 100000
+)
+
+var (
+	// ErrDecryptionFailed is returned if the data can't be decrypted, for
+	// example when the wrong passphrase is used.
+	ErrDecryptionFailed = errors.New("decryption failed, wrong passphrase?")
+	// ErrEmptyPassphrase is returned if an empty passphrase is used.
+	ErrEmptyPassphrase = errors.New("passphrase can't be empty")
+	// ErrPassphraseMismatch is returned if the confirmation of a passphrase
+	// doesn't match.
+	ErrPassphraseMismatch = errors.New("passphrases don't match")
+)
+
+// ReadPassphrase prompts for a passphrase on stderr and reads it from stdin.
+// If stdin is a terminal, the typed characters are not shown. If confirm is
+// true, the passphrase has to be typed twice.
+func ReadPassphrase(prompt string, confirm bool) (string, error) {
+	tty := terminalWidth(os.Stdin) > 0
+	if tty {
+		if err := setEcho(os.Stdin, false); err == nil {
+			defer setEcho(os.Stdin, true)
+		}
+	}
+	r := bufio.NewReader(os.Stdin)
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		line, err := r.ReadString('\n')
+		fmt.Fprintln(os.Stderr)
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	passphrase, err := read(prompt)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", ErrEmptyPassphrase
+	}
+	if !confirm {
+		return passphrase, nil
+	}
+	again, err := read("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", ErrPassphraseMismatch
+	}
+	return passphrase, nil
+}
+
+// deriveKey derives an AES key from the passphrase and the salt.
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key([]byte(passphrase), salt, keyDerivingRuns, keySize, sha256.New), nil
+}
+
+// encryptWithPassphrase encrypts the data using AES-GCM with a key derived
+// from the passphrase. The returned data is the salt, the nonce and the
+// cipher text.
+func encryptWithPassphrase(passphrase string, plaintext []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := encryptWithKey(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return append(salt, sealed...), nil
+}
+
+// decryptWithPassphrase decrypts data encrypted by encryptWithPassphrase.
+func decryptWithPassphrase(passphrase string, data []byte) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, ErrDecryptionFailed
+	}
+	key, err := deriveKey(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return decryptWithKey(key, data[saltSize:])
+}
+
+// encryptWithKey encrypts the data using AES-GCM. The nonce is prepended
+// to the cipher text.
+func encryptWithKey(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decryptWithKey decrypts data encrypted by encryptWithKey.
+func decryptWithKey(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
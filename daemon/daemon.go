@@ -0,0 +1,208 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+// Package daemon implements a background process that keeps the storage
+// and the API client open. Other CLInote processes use the daemon over a
+// unix socket instead of opening the storage and connecting to the server
+// for every command.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/storage"
+)
+
+const (
+	socketFilename = "clinote.sock"
+	dialTimeout    = 500 * time.Millisecond
+)
+
+var (
+	// ErrAlreadyRunning is returned if another daemon is listening on the socket.
+	ErrAlreadyRunning = errors.New("daemon is already running")
+	// ErrStorageNotSupported is returned if the storage backend can't be served.
+	ErrStorageNotSupported = errors.New("storage backend can't be served by the daemon")
+)
+
+// SocketPath returns the path to the daemon's socket in the config folder.
+func SocketPath(cfgFolder string) string {
+	return filepath.Join(cfgFolder, socketFilename)
+}
+
+// NotestoreFactory returns a notestore client. It is called until a notestore
+// is returned without an error.
+type NotestoreFactory func() (clinote.NotestoreClient, error)
+
+// Server serves the storage and the notestore over a unix socket.
+type Server struct {
+	rpc      *rpc.Server
+	listener net.Listener
+	socket   string
+	wg       sync.WaitGroup
+	connMu   sync.Mutex
+	conns    map[net.Conn]struct{}
+	db       clinote.Storage
+	metrics  *Metrics
+}
+
+// NewServer creates a new server for the storage and the notestore.
+func NewServer(db clinote.Storage, ns NotestoreFactory) (*Server, error) {
+	kv, ok := storage.KeyValue(db)
+	if !ok {
+		return nil, ErrStorageNotSupported
+	}
+	s := &Server{rpc: rpc.NewServer(), conns: make(map[net.Conn]struct{}), db: db, metrics: NewMetrics()}
+	if err := s.rpc.RegisterName(storageService, &StorageService{kv: kv}); err != nil {
+		return nil, err
+	}
+	if err := s.rpc.RegisterName(notestoreService, &NotestoreService{factory: ns, metrics: s.metrics}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Listen starts listening on the socket. If a stale socket file exists,
+// it is removed.
+func (s *Server) Listen(socket string) error {
+	if _, err := os.Stat(socket); err == nil {
+		if c, err := Dial(socket); err == nil {
+			c.Close()
+			return ErrAlreadyRunning
+		}
+		if err = os.Remove(socket); err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	s.listener = l
+	s.socket = socket
+	return nil
+}
+
+// Serve accepts connections until the server is closed.
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.wg.Wait()
+			return nil
+		}
+		s.connMu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connMu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.rpc.ServeCodec(jsonrpc.NewServerCodec(conn))
+			s.connMu.Lock()
+			delete(s.conns, conn)
+			s.connMu.Unlock()
+		}()
+	}
+}
+
+// Close stops the server, closes all open connections and removes the socket.
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.connMu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connMu.Unlock()
+	os.Remove(s.socket)
+	return err
+}
+
+// Client is a connection to the daemon.
+type Client struct {
+	rpc *rpc.Client
+	// done is closed when the client is closed.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial connects to the daemon listening on the socket.
+func Dial(socket string) (*Client, error) {
+	return DialContext(context.Background(), socket)
+}
+
+// DialContext connects to the daemon listening on the socket. When the
+// context is done, the connection is closed and the calls in flight fail.
+func DialContext(ctx context.Context, socket string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{rpc: jsonrpc.NewClient(conn), done: make(chan struct{})}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+			case <-c.done:
+			}
+		}()
+	}
+	return c, nil
+}
+
+// Storage returns a storage served by the daemon. The settings are merged
+// with the config file in the config folder, like for a storage opened
+// without the daemon. Closing the storage closes the connection to the
+// daemon.
+func (c *Client) Storage(cfgFolder string) clinote.Storage {
+	return storage.NewKeyValueStorage(&remoteKV{client: c}, c, cfgFolder)
+}
+
+// Notestore returns a notestore served by the daemon.
+func (c *Client) Notestore() clinote.NotestoreClient {
+	return &remoteNotestore{client: c}
+}
+
+// Close closes the connection to the daemon.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.rpc.Close()
+}
+
+func (c *Client) call(method string, args interface{}, reply interface{}) error {
+	err := c.rpc.Call(method, args, reply)
+	if serverErr, ok := err.(rpc.ServerError); ok {
+		return knownError(string(serverErr))
+	}
+	return err
+}
@@ -0,0 +1,242 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemon(t *testing.T) {
+	assert := assert.New(t)
+	tmpDir, err := ioutil.TempDir("", "clinote-test")
+	if err != nil {
+		t.Fatalf("Problem with creating temp folder: %s\n", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	db, err := storage.Open(tmpDir)
+	if err != nil {
+		t.Fatalf("No db: %s\n", err)
+	}
+	defer db.Close()
+
+	ns := &mockNS{notes: []*clinote.Note{&clinote.Note{Title: "Note", GUID: "GUID"}}}
+	calls := 0
+	factory := func() (clinote.NotestoreClient, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("not logged in")
+		}
+		return ns, nil
+	}
+	srv, err := NewServer(db, factory)
+	if err != nil {
+		t.Fatalf("Failed to create server: %s\n", err)
+	}
+	socket := SocketPath(tmpDir)
+	if err = srv.Listen(socket); err != nil {
+		t.Fatalf("Failed to listen: %s\n", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		srv.Serve()
+		close(done)
+	}()
+
+	client, err := Dial(socket)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s\n", err)
+	}
+	defer client.Close()
+
+	t.Run("only one daemon", func(t *testing.T) {
+		other, _ := NewServer(db, factory)
+		assert.Equal(ErrAlreadyRunning, other.Listen(socket))
+	})
+
+	t.Run("storage", func(t *testing.T) {
+		remote := client.Storage("")
+		expected := &clinote.Settings{APIKey: "key"}
+		assert.NoError(remote.StoreSettings(expected))
+		actual, err := db.GetSettings()
+		assert.NoError(err)
+		assert.Equal(expected, actual, "Settings should be saved in the daemon's storage")
+		actual, err = remote.GetSettings()
+		assert.NoError(err)
+		assert.Equal(expected, actual)
+	})
+
+	t.Run("storage with config file", func(t *testing.T) {
+		cfgFolder, err := ioutil.TempDir("", "clinote-test")
+		if !assert.NoError(err) {
+			return
+		}
+		defer os.RemoveAll(cfgFolder)
+		cfg := "notebook.default = \"File\"\n"
+		assert.NoError(ioutil.WriteFile(filepath.Join(cfgFolder, clinote.ConfigFileName), []byte(cfg), 0600))
+		remote := client.Storage(cfgFolder)
+		assert.NoError(remote.StoreSettings(&clinote.Settings{APIKey: "key", DefaultNotebook: "Stored"}))
+		actual, err := remote.GetSettings()
+		assert.NoError(err)
+		assert.Equal("File", actual.DefaultNotebook, "Config file should take precedence")
+		stored, err := db.GetSettings()
+		assert.NoError(err)
+		assert.NotEqual("File", stored.DefaultNotebook, "Config file values should not be saved")
+	})
+
+	t.Run("notestore error from factory", func(t *testing.T) {
+		_, err := client.Notestore().FindNotes(&clinote.NoteFilter{}, 0, 20)
+		assert.EqualError(err, "not logged in")
+	})
+
+	t.Run("notestore", func(t *testing.T) {
+		notes, err := client.Notestore().FindNotes(&clinote.NoteFilter{Words: "Note"}, 0, 20)
+		assert.NoError(err)
+		assert.Equal(ns.notes, notes)
+		assert.Equal("Note", ns.filter.Words, "Filter should be sent to the notestore")
+	})
+
+	t.Run("known errors", func(t *testing.T) {
+		_, err := client.Notestore().GetNoteContent("missing")
+		assert.Equal(clinote.ErrNoNoteFound, err)
+	})
+
+	t.Run("no return values", func(t *testing.T) {
+		err := client.Notestore().DeleteNote("GUID")
+		assert.NoError(err)
+		assert.Equal("GUID", ns.deleted)
+	})
+
+	t.Run("health and metrics", func(t *testing.T) {
+		assert.NoError(db.SaveSyncState(&clinote.SyncState{UpdateCount: 42, Time: time.Now()}))
+		web := httptest.NewServer(srv.HTTPHandler())
+		defer web.Close()
+
+		resp, err := http.Get(web.URL + "/healthz")
+		if assert.NoError(err) {
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			resp.Body.Close()
+		}
+
+		resp, err = http.Get(web.URL + "/metrics")
+		if !assert.NoError(err) {
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(err)
+		metrics := string(body)
+		assert.Contains(metrics, "clinote_up 1\n")
+		assert.Contains(metrics, "clinote_sync_update_count 42\n")
+		assert.Contains(metrics, "# TYPE clinote_sync_lag_seconds gauge\n")
+		assert.Contains(metrics, `clinote_sync_pending_changes{type="edit"} 0`)
+		assert.Contains(metrics, `clinote_api_calls_total{method="FindNotes"} 2`)
+		assert.Contains(metrics, `clinote_api_errors_total{method="FindNotes"} 1`)
+		assert.Contains(metrics, `clinote_api_errors_total{method="GetNoteContent"} 1`)
+		assert.Contains(metrics, `clinote_api_errors_total{method="DeleteNote"} 0`)
+		assert.Contains(metrics, "clinote_api_rate_limit_hits_total 0\n")
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		c, err := DialContext(ctx, socket)
+		if !assert.NoError(err) {
+			return
+		}
+		defer c.Close()
+		_, err = c.Storage("").GetSettings()
+		assert.NoError(err)
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		_, err = c.Storage("").GetSettings()
+		assert.Error(err, "Calls should fail after the context is canceled")
+	})
+
+	srv.Close()
+	<-done
+	_, err = os.Stat(socket)
+	assert.True(os.IsNotExist(err), "Socket should be removed")
+}
+
+type mockNS struct {
+	notes   []*clinote.Note
+	filter  *clinote.NoteFilter
+	deleted string
+}
+
+func (m *mockNS) FindNotes(filter *clinote.NoteFilter, offset, count int) ([]*clinote.Note, error) {
+	m.filter = filter
+	return m.notes, nil
+}
+
+func (m *mockNS) GetAllNotebooks() ([]*clinote.Notebook, error) {
+	panic("not implemented")
+}
+
+func (m *mockNS) GetAllTags() ([]*clinote.Tag, error) {
+	panic("not implemented")
+}
+
+func (m *mockNS) GetNotebook(guid string) (*clinote.Notebook, error) {
+	panic("not implemented")
+}
+
+func (m *mockNS) CreateNotebook(b *clinote.Notebook, defaultNotebook bool) error {
+	panic("not implemented")
+}
+
+func (m *mockNS) GetNoteContent(guid string) (string, error) {
+	return "", clinote.ErrNoNoteFound
+}
+
+func (m *mockNS) UpdateNote(note *clinote.Note) error {
+	panic("not implemented")
+}
+
+func (m *mockNS) DeleteNote(guid string) error {
+	m.deleted = guid
+	return nil
+}
+
+func (m *mockNS) CreateNote(note *clinote.Note) error {
+	panic("not implemented")
+}
+
+func (m *mockNS) UpdateNotebook(book *clinote.Notebook) error {
+	panic("not implemented")
+}
+
+func (m *mockNS) GetSyncState() (*clinote.SyncState, error) {
+	panic("not implemented")
+}
+
+func (m *mockNS) GetNoteResources(guid string) ([]*clinote.Resource, error) {
+	panic("not implemented")
+}
@@ -0,0 +1,179 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/evernote"
+)
+
+// Metrics counts the notestore calls served by the daemon.
+type Metrics struct {
+	mu     sync.Mutex
+	calls  map[string]int64
+	errors map[string]int64
+}
+
+// NewMetrics creates a new metrics counter.
+func NewMetrics() *Metrics {
+	return &Metrics{calls: make(map[string]int64), errors: make(map[string]int64)}
+}
+
+// RecordCall counts a call to the notestore method. If err is not nil, the
+// call is counted as failed.
+func (m *Metrics) RecordCall(method string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls[method]++
+	if err != nil {
+		m.errors[method]++
+	}
+}
+
+// snapshot returns a copy of the counters.
+func (m *Metrics) snapshot() (map[string]int64, map[string]int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make(map[string]int64, len(m.calls))
+	errs := make(map[string]int64, len(m.errors))
+	for k, v := range m.calls {
+		calls[k] = v
+	}
+	for k, v := range m.errors {
+		errs[k] = v
+	}
+	return calls, errs
+}
+
+// WriteMetrics writes the metrics in the Prometheus text format. The values
+// are read from the storage, no calls are made to the server.
+func WriteMetrics(w io.Writer, db clinote.Storager, m *Metrics, now time.Time) error {
+	state, err := db.GetSyncState()
+	if err != nil {
+		return err
+	}
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return err
+	}
+	stats, err := db.GetCacheStats()
+	if err != nil {
+		return err
+	}
+
+	writeMetricHeader(w, "clinote_up", "gauge", "Whether the daemon is running.")
+	fmt.Fprintln(w, "clinote_up 1")
+
+	writeMetricHeader(w, "clinote_sync_last_success_timestamp_seconds", "gauge", "Time of the last successful sync. Zero if never synced.")
+	var last int64
+	var lag float64
+	if !state.Time.IsZero() {
+		last = state.Time.Unix()
+		lag = now.Sub(state.Time).Seconds()
+	}
+	fmt.Fprintf(w, "clinote_sync_last_success_timestamp_seconds %d\n", last)
+	writeMetricHeader(w, "clinote_sync_lag_seconds", "gauge", "Seconds since the last successful sync. Zero if never synced.")
+	fmt.Fprintf(w, "clinote_sync_lag_seconds %.3f\n", lag)
+	writeMetricHeader(w, "clinote_sync_update_count", "gauge", "Update sequence number at the last successful sync.")
+	fmt.Fprintf(w, "clinote_sync_update_count %d\n", state.UpdateCount)
+
+	pending := map[clinote.ChangeType]int{}
+	failed := 0
+	for _, c := range changes {
+		pending[c.Type]++
+		if c.Error != "" {
+			failed++
+		}
+	}
+	writeMetricHeader(w, "clinote_sync_pending_changes", "gauge", "Queued changes waiting to be pushed to the server.")
+	for _, t := range []clinote.ChangeType{clinote.ChangeCreate, clinote.ChangeEdit, clinote.ChangeDelete} {
+		fmt.Fprintf(w, "clinote_sync_pending_changes{type=%q} %d\n", t.String(), pending[t])
+	}
+	writeMetricHeader(w, "clinote_sync_failed_changes", "gauge", "Queued changes that failed to be pushed.")
+	fmt.Fprintf(w, "clinote_sync_failed_changes %d\n", failed)
+
+	writeMetricHeader(w, "clinote_cache_entries", "gauge", "Number of entries in the cache.")
+	for _, name := range clinote.Caches {
+		if c, ok := stats.Caches[name]; ok {
+			fmt.Fprintf(w, "clinote_cache_entries{cache=%q} %d\n", name, c.Entries)
+		}
+	}
+	writeMetricHeader(w, "clinote_cache_size_bytes", "gauge", "Size of the cache entries in bytes.")
+	for _, name := range clinote.Caches {
+		if c, ok := stats.Caches[name]; ok {
+			fmt.Fprintf(w, "clinote_cache_size_bytes{cache=%q} %d\n", name, c.Size)
+		}
+	}
+	writeMetricHeader(w, "clinote_cache_evictions_total", "counter", "Number of entries evicted from the cache.")
+	for _, name := range clinote.Caches {
+		if c, ok := stats.Caches[name]; ok {
+			fmt.Fprintf(w, "clinote_cache_evictions_total{cache=%q} %d\n", name, c.Evictions)
+		}
+	}
+	writeMetricHeader(w, "clinote_cache_max_size_bytes", "gauge", "Size limit for the caches. Zero means no limit.")
+	fmt.Fprintf(w, "clinote_cache_max_size_bytes %d\n", stats.MaxSize)
+
+	calls, errs := m.snapshot()
+	methods := make([]string, 0, len(calls))
+	for method := range calls {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	writeMetricHeader(w, "clinote_api_calls_total", "counter", "Notestore calls served by the daemon.")
+	for _, method := range methods {
+		fmt.Fprintf(w, "clinote_api_calls_total{method=%q} %d\n", method, calls[method])
+	}
+	writeMetricHeader(w, "clinote_api_errors_total", "counter", "Notestore calls that returned an error.")
+	for _, method := range methods {
+		fmt.Fprintf(w, "clinote_api_errors_total{method=%q} %d\n", method, errs[method])
+	}
+	writeMetricHeader(w, "clinote_api_rate_limit_hits_total", "counter", "Calls rejected by the server's rate limit, including retried calls.")
+	_, err = fmt.Fprintf(w, "clinote_api_rate_limit_hits_total %d\n", evernote.RateLimitHits())
+	return err
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// HTTPHandler returns a handler for the /healthz and /metrics endpoints.
+// The health check fails if the storage can't be read.
+func (s *Server) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := s.db.GetSyncState(); err != nil {
+			http.Error(w, "storage: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := WriteMetrics(w, s.db, s.metrics, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	return mux
+}
@@ -0,0 +1,236 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/evernote"
+)
+
+const notestoreService = "Notestore"
+
+var (
+	// ErrUnknownMethod is returned if the method is not part of the notestore interface.
+	ErrUnknownMethod = errors.New("unknown notestore method")
+	// ErrWrongNumberOfArgs is returned if the call has the wrong number of arguments.
+	ErrWrongNumberOfArgs = errors.New("wrong number of arguments")
+)
+
+var notestoreInterface = reflect.TypeOf((*clinote.NotestoreClient)(nil)).Elem()
+
+// Errors that are returned to the caller as the same error value instead of
+// a new error with the same message.
+var knownErrors = []error{
+	clinote.ErrNoNoteFound,
+	clinote.ErrNoNotebookFound,
+	evernote.ErrNotLoggedIn,
+	evernote.ErrNoGUIDSet,
+	evernote.ErrNoTitleSet,
+	ErrUnknownMethod,
+	ErrWrongNumberOfArgs,
+}
+
+func knownError(msg string) error {
+	for _, err := range knownErrors {
+		if err.Error() == msg {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
+// CallArgs are the arguments for a notestore call. The arguments are JSON encoded.
+type CallArgs struct {
+	Method string
+	Args   []json.RawMessage
+}
+
+// CallReply holds the JSON encoded return values of a notestore call, not
+// including the error.
+type CallReply struct {
+	Results []json.RawMessage
+}
+
+// NotestoreService serves the notestore methods.
+type NotestoreService struct {
+	factory NotestoreFactory
+	ns      clinote.NotestoreClient
+	// metrics counts the calls. It can be nil.
+	metrics *Metrics
+	// The notestore client is not safe for concurrent use.
+	mu sync.Mutex
+}
+
+// Call calls the notestore method with the arguments.
+func (s *NotestoreService) Call(args *CallArgs, reply *CallReply) error {
+	if _, ok := notestoreInterface.MethodByName(args.Method); !ok {
+		return ErrUnknownMethod
+	}
+	err := s.call(args, reply)
+	if s.metrics != nil {
+		s.metrics.RecordCall(args.Method, err)
+	}
+	return err
+}
+
+func (s *NotestoreService) call(args *CallArgs, reply *CallReply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ns == nil {
+		ns, err := s.factory()
+		if err != nil {
+			return err
+		}
+		s.ns = ns
+	}
+	m := reflect.ValueOf(s.ns).MethodByName(args.Method)
+	t := m.Type()
+	if t.NumIn() != len(args.Args) {
+		return ErrWrongNumberOfArgs
+	}
+	in := make([]reflect.Value, t.NumIn())
+	for i := range in {
+		v := reflect.New(t.In(i))
+		if err := json.Unmarshal(args.Args[i], v.Interface()); err != nil {
+			return err
+		}
+		in[i] = v.Elem()
+	}
+	out := m.Call(in)
+	// The last return value is always the error.
+	if err, _ := out[len(out)-1].Interface().(error); err != nil {
+		return err
+	}
+	for _, v := range out[:len(out)-1] {
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		reply.Results = append(reply.Results, data)
+	}
+	return nil
+}
+
+// remoteNotestore implements the clinote.NotestoreClient interface by
+// calling the notestore served by the daemon.
+type remoteNotestore struct {
+	client *Client
+}
+
+func (r *remoteNotestore) call(method string, args []interface{}, results ...interface{}) error {
+	callArgs := &CallArgs{Method: method, Args: make([]json.RawMessage, len(args))}
+	for i, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return err
+		}
+		callArgs.Args[i] = data
+	}
+	reply := new(CallReply)
+	if err := r.client.call(notestoreService+".Call", callArgs, reply); err != nil {
+		return err
+	}
+	if len(reply.Results) != len(results) {
+		return ErrWrongNumberOfArgs
+	}
+	for i, result := range results {
+		if err := json.Unmarshal(reply.Results[i], result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindNotes searches for the notes based on the filter.
+func (r *remoteNotestore) FindNotes(filter *clinote.NoteFilter, offset, count int) ([]*clinote.Note, error) {
+	var notes []*clinote.Note
+	err := r.call("FindNotes", []interface{}{filter, offset, count}, &notes)
+	return notes, err
+}
+
+// GetAllNotebooks returns all the of users notebooks.
+func (r *remoteNotestore) GetAllNotebooks() ([]*clinote.Notebook, error) {
+	var books []*clinote.Notebook
+	err := r.call("GetAllNotebooks", nil, &books)
+	return books, err
+}
+
+// GetNotebook returns the notebook with the GUID.
+func (r *remoteNotestore) GetNotebook(guid string) (*clinote.Notebook, error) {
+	var book *clinote.Notebook
+	err := r.call("GetNotebook", []interface{}{guid}, &book)
+	return book, err
+}
+
+// CreateNotebook creates a new notebook.
+func (r *remoteNotestore) CreateNotebook(b *clinote.Notebook, defaultNotebook bool) error {
+	return r.call("CreateNotebook", []interface{}{b, defaultNotebook})
+}
+
+// GetNoteContent gets the note's content from the notestore.
+func (r *remoteNotestore) GetNoteContent(guid string) (string, error) {
+	var content string
+	err := r.call("GetNoteContent", []interface{}{guid}, &content)
+	return content, err
+}
+
+// UpdateNote update's the note.
+func (r *remoteNotestore) UpdateNote(note *clinote.Note) error {
+	return r.call("UpdateNote", []interface{}{note})
+}
+
+// DeleteNote removes a note from the user's notebook.
+func (r *remoteNotestore) DeleteNote(guid string) error {
+	return r.call("DeleteNote", []interface{}{guid})
+}
+
+// CreateNote creates a new note on the server.
+func (r *remoteNotestore) CreateNote(note *clinote.Note) error {
+	return r.call("CreateNote", []interface{}{note})
+}
+
+// UpdateNotebook updates the notebook on the server.
+func (r *remoteNotestore) UpdateNotebook(book *clinote.Notebook) error {
+	return r.call("UpdateNotebook", []interface{}{book})
+}
+
+// GetSyncState returns the current state of the user's account on the server.
+func (r *remoteNotestore) GetSyncState() (*clinote.SyncState, error) {
+	var state *clinote.SyncState
+	err := r.call("GetSyncState", nil, &state)
+	return state, err
+}
+
+// GetAllTags returns all the user's tags.
+func (r *remoteNotestore) GetAllTags() ([]*clinote.Tag, error) {
+	var tags []*clinote.Tag
+	err := r.call("GetAllTags", nil, &tags)
+	return tags, err
+}
+
+// GetNoteResources returns the note's resources, including the data.
+func (r *remoteNotestore) GetNoteResources(guid string) ([]*clinote.Resource, error) {
+	var resources []*clinote.Resource
+	err := r.call("GetNoteResources", []interface{}{guid}, &resources)
+	return resources, err
+}
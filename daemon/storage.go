@@ -0,0 +1,80 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package daemon
+
+import (
+	"github.com/TcM1911/clinote/storage"
+)
+
+const storageService = "Storage"
+
+// DataArgs are the arguments for the storage service.
+type DataArgs struct {
+	Bucket []byte
+	Key    []byte
+	Data   []byte
+}
+
+// StorageService serves the key-value data of the storage.
+type StorageService struct {
+	kv storage.KeyValueStore
+}
+
+// GetData returns the value for the key in the bucket.
+func (s *StorageService) GetData(args *DataArgs, reply *[]byte) error {
+	data, err := s.kv.GetData(args.Bucket, args.Key)
+	if err != nil {
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+// StoreData saves the value for the key in the bucket.
+func (s *StorageService) StoreData(args *DataArgs, reply *bool) error {
+	err := s.kv.StoreData(args.Bucket, args.Key, args.Data)
+	*reply = err == nil
+	return err
+}
+
+// DeleteData removes the key from the bucket.
+func (s *StorageService) DeleteData(args *DataArgs, reply *bool) error {
+	err := s.kv.DeleteData(args.Bucket, args.Key)
+	*reply = err == nil
+	return err
+}
+
+type remoteKV struct {
+	client *Client
+}
+
+func (r *remoteKV) GetData(bucket, key []byte) ([]byte, error) {
+	var data []byte
+	err := r.client.call(storageService+".GetData", &DataArgs{Bucket: bucket, Key: key}, &data)
+	return data, err
+}
+
+func (r *remoteKV) StoreData(bucket, key, data []byte) error {
+	var ok bool
+	return r.client.call(storageService+".StoreData", &DataArgs{Bucket: bucket, Key: key, Data: data}, &ok)
+}
+
+func (r *remoteKV) DeleteData(bucket, key []byte) error {
+	var ok bool
+	return r.client.call(storageService+".DeleteData", &DataArgs{Bucket: bucket, Key: key}, &ok)
+}
@@ -0,0 +1,309 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTimeOutOfRange is returned if a note time is outside of the range
+	// accepted by the server.
+	ErrTimeOutOfRange = errors.New("time must be between 1000-01-01 and 9999-12-31")
+	// ErrUpdatedBeforeCreated is returned if a note's updated time is
+	// before its created time.
+	ErrUpdatedBeforeCreated = errors.New("updated time can't be before the created time")
+)
+
+var (
+	// MinNoteTime is the earliest time accepted for notes.
+	MinNoteTime = time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)
+	// MaxNoteTime is the latest time accepted for notes.
+	MaxNoteTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+)
+
+// timeLayouts are the layouts accepted by ParseTime. Layouts without a
+// time zone are parsed in the local time zone.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// DateOrder is the order of the day and the month in numeric dates like
+// 04/03/2018.
+type DateOrder int
+
+const (
+	// DayFirst dates are written day, month, year.
+	DayFirst DateOrder = iota
+	// MonthFirst dates are written month, day, year.
+	MonthFirst
+)
+
+// monthFirstLocales are the locales writing the month before the day.
+var monthFirstLocales = []string{"en_US", "es_US", "en_PH", "en_AS", "en_GU", "en_MP", "en_PR", "en_UM", "en_VI"}
+
+var (
+	clockPattern    = regexp.MustCompile(`^(.*?)\s*\b(\d{1,2}):(\d{2})(?::(\d{2}))?$`)
+	numericPattern  = regexp.MustCompile(`^(\d{1,4})([/.-])(\d{1,2})([/.-])(\d{1,4})$`)
+	relativePattern = regexp.MustCompile(`^(?:(in) )?(\d+|a|an|one) (minute|hour|day|week|month|year)s?(?: (ago))?$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday,
+	"sun":      time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// InvalidTimeError is returned if a time can't be parsed. The message lists
+// the accepted forms.
+type InvalidTimeError struct {
+	// Input is the text that couldn't be parsed.
+	Input string
+	// Order is the order used for numeric dates.
+	Order DateOrder
+}
+
+func (e *InvalidTimeError) Error() string {
+	numeric := "15/06/2024"
+	if e.Order == MonthFirst {
+		numeric = "06/15/2024"
+	}
+	return fmt.Sprintf("invalid date %q, use for example 2024-06-15, \"2024-06-15 14:30\", "+
+		"2024-06-15T14:30:00Z, %s, 15.06.2024, today, yesterday, \"yesterday 14:30\", "+
+		"monday, \"last monday\", \"next friday\", \"2 weeks ago\" or \"in 3 days\"", e.Input, numeric)
+}
+
+// LocaleDateOrder returns the order of the day and the month in the user's
+// locale, read from LC_ALL, LC_TIME or LANG.
+func LocaleDateOrder() DateOrder {
+	for _, env := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		locale := os.Getenv(env)
+		if locale == "" {
+			continue
+		}
+		for _, l := range monthFirstLocales {
+			if strings.HasPrefix(locale, l) {
+				return MonthFirst
+			}
+		}
+		return DayFirst
+	}
+	return DayFirst
+}
+
+// ParseTime parses a date or a date and time. Besides ISO 8601 dates,
+// numeric dates in the locale's order, like 15/06/2024, and natural
+// forms, like yesterday, "last monday" and "2 weeks ago", are accepted.
+// Times without a time zone are in the local time zone.
+func ParseTime(s string) (time.Time, error) {
+	return ParseTimeAt(s, time.Now(), LocaleDateOrder())
+}
+
+// ParseTimeAt parses the time like ParseTime. Relative dates are relative
+// to now and numeric dates where the day and the month can't be told
+// apart are read in the order.
+func ParseTimeAt(s string, now time.Time, order DateOrder) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	invalid := &InvalidTimeError{Input: s, Order: order}
+	if s == "" {
+		return time.Time{}, invalid
+	}
+	now = now.In(time.Local)
+	text := strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	if text == "now" {
+		return now, nil
+	}
+	// A time of day can follow the date, for example "yesterday 14:30".
+	clock := -1 * time.Second
+	if m := clockPattern.FindStringSubmatch(text); m != nil {
+		h, _ := strconv.Atoi(m[2])
+		min, _ := strconv.Atoi(m[3])
+		sec, _ := strconv.Atoi(m[4])
+		if h > 23 || min > 59 || sec > 59 {
+			return time.Time{}, invalid
+		}
+		clock = time.Duration(h)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+		text = m[1]
+	}
+	t, ok := parseDate(text, now, order)
+	if !ok {
+		return time.Time{}, invalid
+	}
+	if clock >= 0 {
+		t = startOfDay(t).Add(clock)
+	}
+	return t, nil
+}
+
+// parseDate parses the natural and numeric date forms.
+func parseDate(s string, now time.Time, order DateOrder) (time.Time, bool) {
+	today := startOfDay(now)
+	switch s {
+	case "", "today":
+		// Empty if only a time of day was given.
+		return today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	}
+	words := strings.Fields(s)
+	if len(words) == 2 && (words[0] == "last" || words[0] == "next") {
+		if _, ok := weekdays[words[1]]; !ok {
+			if words[0] == "last" {
+				s = "1 " + words[1] + " ago"
+			} else {
+				s = "in 1 " + words[1]
+			}
+			words = nil
+		}
+	}
+	if len(words) == 1 || (len(words) == 2 && (words[0] == "last" || words[0] == "next" || words[0] == "this")) {
+		if day, ok := weekdays[words[len(words)-1]]; ok {
+			diff := int(today.Weekday() - day)
+			switch {
+			case len(words) == 1 || words[0] == "this":
+				// The day this week, today included.
+				diff = (diff + 7) % 7
+				return today.AddDate(0, 0, -diff), true
+			case words[0] == "last":
+				diff = (diff+6)%7 + 1
+				return today.AddDate(0, 0, -diff), true
+			default:
+				diff = (-diff+6)%7 + 1
+				return today.AddDate(0, 0, diff), true
+			}
+		}
+	}
+	if m := relativePattern.FindStringSubmatch(s); m != nil && (m[1] == "") != (m[4] == "") {
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			n = 1
+		}
+		if m[4] != "" {
+			n = -n
+		}
+		switch m[3] {
+		case "minute":
+			return now.Add(time.Duration(n) * time.Minute), true
+		case "hour":
+			return now.Add(time.Duration(n) * time.Hour), true
+		case "day":
+			return now.AddDate(0, 0, n), true
+		case "week":
+			return now.AddDate(0, 0, 7*n), true
+		case "month":
+			return now.AddDate(0, n, 0), true
+		default:
+			return now.AddDate(n, 0, 0), true
+		}
+	}
+	return parseNumericDate(s, order)
+}
+
+// parseNumericDate parses dates like 15/06/2024, 06/15/2024, 15.06.2024
+// and 2024/06/15. The order is only used if the day and the month can't be
+// told apart. Dates with dots are always day first.
+func parseNumericDate(s string, order DateOrder) (time.Time, bool) {
+	m := numericPattern.FindStringSubmatch(s)
+	if m == nil || m[2] != m[4] {
+		return time.Time{}, false
+	}
+	a, _ := strconv.Atoi(m[1])
+	b, _ := strconv.Atoi(m[3])
+	c, _ := strconv.Atoi(m[5])
+	var year, month, day int
+	switch {
+	case len(m[1]) == 4:
+		year, month, day = a, b, c
+	case len(m[5]) != 4 && len(m[5]) != 2:
+		return time.Time{}, false
+	case m[2] == "." || a > 12 || (order == DayFirst && b <= 12):
+		day, month, year = a, b, c
+	default:
+		month, day, year = a, b, c
+	}
+	if len(m[5]) == 2 && len(m[1]) != 4 {
+		year += 2000
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// CreatedSearch returns the search grammar terms for notes created after
+// and before the times. Zero times are left out.
+func CreatedSearch(after, before time.Time) string {
+	const layout = "20060102T150405Z"
+	var terms []string
+	if !after.IsZero() {
+		terms = append(terms, "created:"+after.UTC().Format(layout))
+	}
+	if !before.IsZero() {
+		terms = append(terms, "-created:"+before.UTC().Format(layout))
+	}
+	return strings.Join(terms, " ")
+}
+
+// SetNoteTimes sets the created and updated time of the note. Zero times
+// are not set. If only the updated time is given, it's also used as the
+// created time so the note isn't updated before it's created.
+func SetNoteTimes(n *Note, created, updated time.Time) error {
+	for _, t := range []time.Time{created, updated} {
+		if !t.IsZero() && (t.Before(MinNoteTime) || t.After(MaxNoteTime)) {
+			return ErrTimeOutOfRange
+		}
+	}
+	if created.IsZero() {
+		created = updated
+	}
+	if !updated.IsZero() && updated.Before(created) {
+		return ErrUpdatedBeforeCreated
+	}
+	// The server only stores the time with second precision.
+	if !created.IsZero() {
+		n.Created = created.Unix() * 1000
+	}
+	if !updated.IsZero() {
+		n.Updated = updated.Unix() * 1000
+	}
+	return nil
+}
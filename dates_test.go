@@ -0,0 +1,150 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Time
+		err      error
+	}{
+		{"2018-03-04", time.Date(2018, 3, 4, 0, 0, 0, 0, time.Local), nil},
+		{"2018-03-04 10:30", time.Date(2018, 3, 4, 10, 30, 0, 0, time.Local), nil},
+		{"2018-03-04 10:30:15", time.Date(2018, 3, 4, 10, 30, 15, 0, time.Local), nil},
+		{"2018-03-04T10:30:15Z", time.Date(2018, 3, 4, 10, 30, 15, 0, time.UTC), nil},
+		{"2018-02-30", time.Time{}, &InvalidTimeError{Input: "2018-02-30", Order: LocaleDateOrder()}},
+		{"", time.Time{}, &InvalidTimeError{Order: LocaleDateOrder()}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := ParseTime(test.input)
+			assert.Equal(t, test.err, err)
+			assert.True(t, test.expected.Equal(actual), "Expected %s, got %s", test.expected, actual)
+		})
+	}
+}
+
+func TestParseTimeAt(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2024, 6, 19, 15, 30, 0, 0, time.Local)
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 0, 0, 0, 0, time.Local)
+	}
+	tests := []struct {
+		input    string
+		order    DateOrder
+		expected time.Time
+	}{
+		{"now", DayFirst, now},
+		{"Today", DayFirst, day(6, 19)},
+		{"yesterday", DayFirst, day(6, 18)},
+		{"tomorrow", DayFirst, day(6, 20)},
+		{"yesterday 14:30", DayFirst, day(6, 18).Add(14*time.Hour + 30*time.Minute)},
+		{"9:05", DayFirst, day(6, 19).Add(9*time.Hour + 5*time.Minute)},
+		{"monday", DayFirst, day(6, 17)},
+		{"wednesday", DayFirst, day(6, 19)},
+		{"this fri", DayFirst, day(6, 14)},
+		{"last monday", DayFirst, day(6, 17)},
+		{"last wednesday", DayFirst, day(6, 12)},
+		{"next monday", DayFirst, day(6, 24)},
+		{"next wednesday", DayFirst, day(6, 26)},
+		{"2 weeks ago", DayFirst, now.AddDate(0, 0, -14)},
+		{"a day ago", DayFirst, now.AddDate(0, 0, -1)},
+		{"3 hours ago", DayFirst, now.Add(-3 * time.Hour)},
+		{"in 3 days", DayFirst, now.AddDate(0, 0, 3)},
+		{"last month", DayFirst, now.AddDate(0, -1, 0)},
+		{"next year", DayFirst, now.AddDate(1, 0, 0)},
+		{"15/06/2024", DayFirst, day(6, 15)},
+		{"15/06/2024", MonthFirst, day(6, 15)},
+		{"06/15/2024", DayFirst, day(6, 15)},
+		{"04/03/2024", DayFirst, day(3, 4)},
+		{"04/03/2024", MonthFirst, day(4, 3)},
+		{"04.03.2024", MonthFirst, day(3, 4)},
+		{"4/3/24", DayFirst, day(3, 4)},
+		{"2024/06/15", MonthFirst, day(6, 15)},
+		{"15/06/2024 08:00", DayFirst, day(6, 15).Add(8 * time.Hour)},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := ParseTimeAt(test.input, now, test.order)
+			assert.NoError(t, err)
+			assert.True(t, test.expected.Equal(actual), "Expected %s, got %s", test.expected, actual)
+		})
+	}
+
+	for _, input := range []string{"31/02/2024", "13/13/2024", "15/06-2024", "2 weeks", "in 2 weeks ago", "someday", "25:00", "yesterday 12:75"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseTimeAt(input, now, DayFirst)
+			assert.Equal(t, &InvalidTimeError{Input: input, Order: DayFirst}, err)
+		})
+	}
+
+	err := &InvalidTimeError{Input: "someday", Order: MonthFirst}
+	assert.Contains(t, err.Error(), `invalid date "someday"`)
+	assert.Contains(t, err.Error(), "06/15/2024")
+}
+
+func TestLocaleDateOrder(t *testing.T) {
+	for _, env := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	assert.Equal(t, DayFirst, LocaleDateOrder())
+	os.Setenv("LANG", "en_US.UTF-8")
+	assert.Equal(t, MonthFirst, LocaleDateOrder())
+	os.Setenv("LC_TIME", "en_GB.UTF-8")
+	assert.Equal(t, DayFirst, LocaleDateOrder())
+}
+
+func TestCreatedSearch(t *testing.T) {
+	after := time.Date(2024, 6, 15, 8, 0, 0, 0, time.UTC)
+	assert.Equal(t, "created:20240615T080000Z", CreatedSearch(after, time.Time{}))
+	assert.Equal(t, "created:20240615T080000Z -created:20240616T080000Z", CreatedSearch(after, after.AddDate(0, 0, 1)))
+	assert.Equal(t, "", CreatedSearch(time.Time{}, time.Time{}))
+}
+
+func TestSetNoteTimes(t *testing.T) {
+	assert := assert.New(t)
+	created := time.Date(2010, 5, 1, 8, 0, 0, 500, time.UTC)
+	updated := created.Add(time.Hour)
+
+	n := new(Note)
+	assert.NoError(SetNoteTimes(n, created, updated))
+	assert.Equal(created.Unix()*1000, n.Created)
+	assert.Equal(updated.Unix()*1000, n.Updated)
+
+	n = new(Note)
+	assert.NoError(SetNoteTimes(n, time.Time{}, updated))
+	assert.Equal(n.Updated, n.Created, "Created should default to the updated time")
+
+	n = new(Note)
+	assert.NoError(SetNoteTimes(n, created, time.Time{}))
+	assert.Equal(int64(0), n.Updated)
+
+	assert.Equal(ErrUpdatedBeforeCreated, SetNoteTimes(new(Note), updated, created))
+	assert.Equal(ErrTimeOutOfRange, SetNoteTimes(new(Note), time.Date(999, 12, 31, 0, 0, 0, 0, time.UTC), time.Time{}))
+	assert.Equal(ErrTimeOutOfRange, SetNoteTimes(new(Note), time.Time{}, time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,184 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultDuplicateSimilarity is the content similarity, between 0 and 1,
+// above which notes with the same title are considered duplicates.
+const DefaultDuplicateSimilarity = 0.9
+
+// ErrNoDuplicates is returned if there are no duplicates to merge.
+var ErrNoDuplicates = errors.New("no duplicates given")
+
+// shingleSize is the number of words hashed together when the contents
+// are compared.
+const shingleSize = 3
+
+var enMediaPattern = regexp.MustCompile(`(?s)<en-media[^>]*/>|<en-media[^>]*>.*?</en-media>`)
+
+// DuplicateGroup is a group of notes with the same title and similar
+// content. The notes are ordered by their creation time, oldest first.
+type DuplicateGroup struct {
+	// Title is the notes' title.
+	Title string
+	// Notes are the duplicates. The notes' content is set.
+	Notes []*Note
+}
+
+// FindDuplicates finds the duplicates among the notes. Notes are
+// duplicates if they have the same title, ignoring case and surrounding
+// whitespace, and the similarity of their content is at least the given
+// value. The content is only fetched for notes sharing a title, using
+// the content cache. The progress function is called after each fetched
+// note, if not nil.
+func FindDuplicates(db Storager, ns NotestoreClient, notes []*Note, similarity float64, progress func(*Note)) ([]*DuplicateGroup, error) {
+	byTitle := make(map[string][]*Note)
+	var titles []string
+	for _, n := range notes {
+		key := strings.ToLower(strings.TrimSpace(n.Title))
+		if _, ok := byTitle[key]; !ok {
+			titles = append(titles, key)
+		}
+		byTitle[key] = append(byTitle[key], n)
+	}
+	var groups []*DuplicateGroup
+	for _, title := range titles {
+		candidates := byTitle[title]
+		if len(candidates) < 2 {
+			continue
+		}
+		shingles := make(map[*Note]map[uint64]struct{}, len(candidates))
+		for _, n := range candidates {
+			content, err := getCachedNoteContent(db, ns, n)
+			if err != nil {
+				return nil, err
+			}
+			if err = parseNoteContent(content, n); err != nil {
+				return nil, err
+			}
+			shingles[n] = contentShingles(n.MD)
+			if progress != nil {
+				progress(n)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Created < candidates[j].Created })
+		var found []*DuplicateGroup
+		for _, n := range candidates {
+			added := false
+			for _, g := range found {
+				if contentSimilarity(shingles[g.Notes[0]], shingles[n]) >= similarity {
+					g.Notes = append(g.Notes, n)
+					added = true
+					break
+				}
+			}
+			if !added {
+				found = append(found, &DuplicateGroup{Title: n.Title, Notes: []*Note{n}})
+			}
+		}
+		for _, g := range found {
+			if len(g.Notes) > 1 {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// contentShingles returns the hashes of the overlapping word sequences in
+// the text. The text is compared case insensitive and whitespace is ignored.
+func contentShingles(text string) map[uint64]struct{} {
+	words := strings.Fields(strings.ToLower(text))
+	set := make(map[uint64]struct{})
+	for i := 0; i == 0 || i+shingleSize <= len(words); i++ {
+		end := i + shingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:end], " ")))
+		set[h.Sum64()] = struct{}{}
+	}
+	return set
+}
+
+// contentSimilarity returns the Jaccard similarity of the two sets of
+// content hashes. Identical content has the similarity 1.
+func contentSimilarity(a, b map[uint64]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for h := range a {
+		if _, ok := b[h]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// MergeDuplicates merges the duplicates into the note that is kept and
+// moves the duplicates to the trash. The tags of the duplicates are added
+// to the kept note and the content of duplicates that isn't identical is
+// appended to it. Attachments of the duplicates are not copied. The notes
+// must have their content set, as returned by FindDuplicates.
+func MergeDuplicates(ns NotestoreClient, keep *Note, duplicates []*Note) error {
+	if len(duplicates) == 0 {
+		return ErrNoDuplicates
+	}
+	changed := false
+	shingles := contentShingles(keep.MD)
+	body := keep.Body
+	for _, d := range duplicates {
+		for _, t := range d.Tags {
+			if !containsName(keep.Tags, t) {
+				keep.Tags = append(keep.Tags, t)
+				changed = true
+			}
+		}
+		if contentSimilarity(shingles, contentShingles(d.MD)) < 1 {
+			body += fmt.Sprintf("<hr/>%s", enMediaPattern.ReplaceAllString(d.Body, ""))
+			changed = true
+		}
+	}
+	if changed {
+		keep.Body = body
+		if err := saveChanges(ns, keep, true, true); err != nil {
+			return err
+		}
+	}
+	return DeleteDuplicates(ns, duplicates)
+}
+
+// DeleteDuplicates moves the duplicates to the trash.
+func DeleteDuplicates(ns NotestoreClient, duplicates []*Note) error {
+	for _, d := range duplicates {
+		if err := ns.DeleteNote(d.GUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	assert := assert.New(t)
+	contents := map[string]string{
+		"1": XMLHeader + "<en-note><div>The quick brown fox jumps over the lazy dog.</div></en-note>",
+		"2": XMLHeader + "<en-note><div>The quick brown fox jumps over the lazy dog.</div></en-note>",
+		"3": XMLHeader + "<en-note><div>Something completely different.</div></en-note>",
+		"4": XMLHeader + "<en-note><div>Unique title.</div></en-note>",
+	}
+	fetched := 0
+	ns := &mockNS{getNoteContent: func(guid string) (string, error) { fetched++; return contents[guid], nil }}
+	notes := []*Note{
+		{GUID: "2", Title: "Shopping ", Created: 2},
+		{GUID: "1", Title: "shopping", Created: 1},
+		{GUID: "3", Title: "Shopping", Created: 3},
+		{GUID: "4", Title: "Other", Created: 4},
+	}
+	groups, err := FindDuplicates(&mockStore{}, ns, notes, DefaultDuplicateSimilarity, nil)
+	assert.NoError(err)
+	assert.Equal(3, fetched, "Only notes sharing a title should be fetched")
+	if assert.Len(groups, 1) {
+		assert.Equal([]*Note{notes[1], notes[0]}, groups[0].Notes, "Oldest note should be first")
+	}
+}
+
+func TestContentSimilarity(t *testing.T) {
+	assert := assert.New(t)
+	a := contentShingles("one two three four five six")
+	assert.Equal(1.0, contentSimilarity(a, contentShingles("One  two three four five six")))
+	assert.InDelta(0.75, contentSimilarity(a, contentShingles("one two three four five")), 0.01)
+	assert.Equal(0.0, contentSimilarity(a, contentShingles("seven eight nine")))
+	assert.Equal(1.0, contentSimilarity(contentShingles(""), contentShingles("")))
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	assert := assert.New(t)
+	var updated *Note
+	var deleted []string
+	ns := &mockNS{
+		updateNote: func(n *Note) error { updated = n; return nil },
+		deleteNote: func(guid string) error { deleted = append(deleted, guid); return nil },
+	}
+	keep := &Note{GUID: "1", Body: "<div>Milk</div>", MD: "Milk", Tags: []string{"home"}}
+	same := &Note{GUID: "2", Body: "<div>Milk</div>", MD: "Milk", Tags: []string{"Home"}}
+	other := &Note{GUID: "3", Body: `<div>Milk and eggs</div><en-media hash="abc" type="image/png"/>`, MD: "Milk and eggs", Tags: []string{"shop"}}
+
+	t.Run("Identical duplicates", func(t *testing.T) {
+		assert.NoError(MergeDuplicates(ns, keep, []*Note{same}))
+		assert.Nil(updated, "Note should not be updated")
+		assert.Equal([]string{"2"}, deleted)
+	})
+
+	t.Run("Different duplicates", func(t *testing.T) {
+		deleted = nil
+		assert.NoError(MergeDuplicates(ns, keep, []*Note{same, other}))
+		if assert.NotNil(updated) {
+			assert.Equal([]string{"home", "shop"}, updated.Tags)
+			assert.Contains(updated.Body, "<div>Milk</div><hr/><div>Milk and eggs</div></en-note>")
+			assert.NotContains(updated.Body, "en-media")
+		}
+		assert.Equal([]string{"2", "3"}, deleted)
+	})
+
+	t.Run("No duplicates", func(t *testing.T) {
+		assert.Equal(ErrNoDuplicates, MergeDuplicates(ns, keep, nil))
+	})
+}
@@ -0,0 +1,96 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DirConfigFile is the name of the file with directory specific defaults.
+const DirConfigFile = ".clinote.toml"
+
+// DirConfig holds the defaults defined for a directory.
+type DirConfig struct {
+	// Path is the path to the config file.
+	Path string
+	// Notebook is the notebook used for new notes.
+	Notebook string
+	// Tags are added to new notes.
+	Tags []string
+}
+
+// FindDirConfig looks for a directory config file in the directory and its
+// parents. The closest file is used. Nil is returned if no file is found.
+func FindDirConfig(dir string) (*DirConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, DirConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return LoadDirConfig(path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadDirConfig reads the directory config file.
+func LoadDirConfig(path string) (*DirConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	vals, err := parseTOML(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	cfg := &DirConfig{Path: path}
+	for k, v := range vals {
+		var ok bool
+		switch k {
+		case "notebook":
+			cfg.Notebook, ok = v.(string)
+		case "tags":
+			cfg.Tags, ok = tomlStringList(v)
+		default:
+			return nil, fmt.Errorf("%s: unknown key %s", path, k)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid value for %s", path, k)
+		}
+	}
+	return cfg, nil
+}
+
+// DefaultNotebookName returns the notebook used for new notes when no
+// notebook is given. The directory config takes precedence over the
+// settings. An empty string means the account's default notebook is used.
+func DefaultNotebookName(settings *Settings, dirCfg *DirConfig) string {
+	if dirCfg != nil && dirCfg.Notebook != "" {
+		return dirCfg.Notebook
+	}
+	return settings.DefaultNotebook
+}
@@ -0,0 +1,70 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDirConfig(t *testing.T) {
+	assert := assert.New(t)
+	root, err := ioutil.TempDir("", "clinote-dirconfig")
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+	sub := filepath.Join(root, "project", "src")
+	assert.NoError(os.MkdirAll(sub, 0700))
+	cfgPath := filepath.Join(root, "project", DirConfigFile)
+	assert.NoError(ioutil.WriteFile(cfgPath, []byte("notebook = \"Project\"\ntags = [\"project\", \"dev\"]\n"), 0600))
+
+	t.Run("found in parent", func(t *testing.T) {
+		cfg, err := FindDirConfig(sub)
+		assert.NoError(err)
+		assert.Equal(&DirConfig{Path: cfgPath, Notebook: "Project", Tags: []string{"project", "dev"}}, cfg)
+	})
+	t.Run("not found", func(t *testing.T) {
+		cfg, err := FindDirConfig(root)
+		assert.NoError(err)
+		assert.Nil(cfg)
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		path := filepath.Join(root, "bad.toml")
+		assert.NoError(ioutil.WriteFile(path, []byte("notebok = \"Project\"\n"), 0600))
+		_, err := LoadDirConfig(path)
+		assert.Error(err)
+	})
+	t.Run("invalid value", func(t *testing.T) {
+		path := filepath.Join(root, "bad.toml")
+		assert.NoError(ioutil.WriteFile(path, []byte("tags = [1, 2]\n"), 0600))
+		_, err := LoadDirConfig(path)
+		assert.Error(err)
+	})
+}
+
+func TestDefaultNotebookName(t *testing.T) {
+	assert := assert.New(t)
+	settings := &Settings{DefaultNotebook: "Inbox"}
+	assert.Equal("Inbox", DefaultNotebookName(settings, nil))
+	assert.Equal("Inbox", DefaultNotebookName(settings, &DirConfig{Tags: []string{"a"}}))
+	assert.Equal("Project", DefaultNotebookName(settings, &DirConfig{Notebook: "Project"}))
+	assert.Equal("", DefaultNotebookName(new(Settings), nil))
+}
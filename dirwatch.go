@@ -0,0 +1,324 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// DefaultDirWatchInterval is the default time between checks of a
+	// watched directory.
+	DefaultDirWatchInterval = 2 * time.Second
+	// DirWatchStateName is the name of the file in a watched directory
+	// that maps the Markdown files to their notes.
+	DirWatchStateName = ".clinote-watch.json"
+	// dirWatchSettle is the time to wait after a file system event before
+	// the directory is synced, so files written in several steps are
+	// synced once.
+	dirWatchSettle = 200 * time.Millisecond
+)
+
+// WatchedFile is a Markdown file synced to a note by a DirWatcher.
+type WatchedFile struct {
+	// GUID is the GUID of the note.
+	GUID string
+	// Hash is the MD5 hash of the file content last uploaded.
+	Hash string
+}
+
+// fileStamp is the modification time and size of a file, used to skip
+// reading files that haven't changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// DirWatcher syncs the Markdown files in a directory to notes. New files
+// are created as notes in the notebook and changed files update their
+// notes. The note title is the file name without the extension. Files
+// are only synced to the notes, changes to the notes aren't written back
+// and notes aren't deleted when the files are removed.
+//
+// The directory is synced when the file system reports a change and is
+// polled in case a change isn't reported, for example on network file
+// systems. The notes of the files are saved in DirWatchStateName in the
+// directory so the files are matched with their notes the next time the
+// directory is watched.
+type DirWatcher struct {
+	// Dir is the watched directory.
+	Dir string
+	// Notebook is the notebook new notes are created in. If nil, the
+	// default notebook is used.
+	Notebook *Notebook
+	// Interval is the time between polls.
+	Interval time.Duration
+	// OnError is called with the error when syncing the directory fails.
+	// The files that failed are synced again on the next poll.
+	OnError func(error)
+
+	db     Storager
+	ns     NotestoreClient
+	files  map[string]*WatchedFile
+	stamps map[string]fileStamp
+}
+
+// NewDirWatcher creates a watcher for the directory. The saved state is
+// read from the directory.
+func NewDirWatcher(db Storager, ns NotestoreClient, dir string, notebook *Notebook) (*DirWatcher, error) {
+	w := &DirWatcher{Dir: dir, Notebook: notebook, Interval: DefaultDirWatchInterval, db: db, ns: ns,
+		files: make(map[string]*WatchedFile), stamps: make(map[string]fileStamp)}
+	data, err := ioutil.ReadFile(filepath.Join(dir, DirWatchStateName))
+	if os.IsNotExist(err) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, json.Unmarshal(data, &w.files)
+}
+
+// Watch syncs the directory, and syncs it again when it changes, until
+// the done channel is closed. The handler is called with the notes that
+// have been created or updated. If the handler returns an error, the watch
+// is stopped. Errors when syncing the directory are passed to OnError and
+// the files are synced again on the next poll.
+func (w *DirWatcher) Watch(done <-chan struct{}, handler func([]*NoteChange) error) error {
+	events, stop := w.watchEvents()
+	defer stop()
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		changes, err := w.Poll()
+		if len(changes) != 0 {
+			if herr := handler(changes); herr != nil {
+				return herr
+			}
+		}
+		if err != nil {
+			LogVerbose("syncing the watched directory failed", "dir", w.Dir, "err", err)
+			if w.OnError != nil {
+				w.OnError(err)
+			}
+		}
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+		case <-events:
+			select {
+			case <-done:
+				return nil
+			case <-time.After(dirWatchSettle):
+			}
+		}
+	}
+}
+
+// watchEvents returns a channel that receives a value when the file system
+// reports a change in the directory, and a function that stops the watch.
+// The channel is nil if the file system can't report changes.
+func (w *DirWatcher) watchEvents() (<-chan struct{}, func()) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		LogVerbose("file system events not available, polling", "dir", w.Dir, "err", err)
+		return nil, func() {}
+	}
+	w.addDirs(fw, w.Dir)
+	changed := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case ev, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				if strings.HasPrefix(filepath.Base(ev.Name), ".") {
+					// The state file and hidden files aren't synced.
+					continue
+				}
+				if ev.Op&fsnotify.Create != 0 {
+					if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+						w.addDirs(fw, ev.Name)
+					}
+				}
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			case err, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+				LogVerbose("file system watch error", "dir", w.Dir, "err", err)
+			}
+		}
+	}()
+	return changed, func() { fw.Close() }
+}
+
+// addDirs adds the directory and its subdirectories, except hidden ones,
+// to the file system watch.
+func (w *DirWatcher) addDirs(fw *fsnotify.Watcher, dir string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if path != w.Dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err = fw.Add(path); err != nil {
+			LogVerbose("watching a directory failed", "dir", path, "err", err)
+		}
+		return nil
+	})
+}
+
+// Poll syncs the files that have changed since the last poll. The first
+// poll syncs the files that have changed since the directory was last
+// watched. A file that fails to sync doesn't stop the poll, it's synced
+// again on the next poll and the first error is returned. The created and
+// updated notes are returned, also when an error is returned.
+func (w *DirWatcher) Poll() ([]*NoteChange, error) {
+	stamps, err := w.changedFiles()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(stamps))
+	for rel := range stamps {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	var changes []*NoteChange
+	for _, rel := range paths {
+		change, syncErr := w.syncFile(rel)
+		if change != nil {
+			changes = append(changes, change)
+		}
+		if syncErr != nil {
+			if err == nil {
+				err = syncErr
+			}
+			continue
+		}
+		w.stamps[rel] = stamps[rel]
+	}
+	if len(changes) != 0 {
+		if saveErr := w.saveState(); err == nil {
+			err = saveErr
+		}
+	}
+	return changes, err
+}
+
+// changedFiles returns the Markdown files in the directory, relative to
+// it, that have changed since the last poll. Hidden files and directories
+// are skipped.
+func (w *DirWatcher) changedFiles() (map[string]fileStamp, error) {
+	changed := make(map[string]fileStamp)
+	err := filepath.Walk(w.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != w.Dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(w.Dir, path)
+		if err != nil {
+			return err
+		}
+		stamp := fileStamp{modTime: info.ModTime(), size: info.Size()}
+		if old, ok := w.stamps[rel]; !ok || old != stamp {
+			changed[rel] = stamp
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// syncFile creates or updates the note of the file if its content has
+// changed. Nil is returned if the note is up to date or the file has been
+// removed. If the update is queued, the change is returned with
+// ErrChangeQueued.
+func (w *DirWatcher) syncFile(rel string) (*NoteChange, error) {
+	data, err := ioutil.ReadFile(filepath.Join(w.Dir, rel))
+	if os.IsNotExist(err) {
+		// The file was removed after the directory was read.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(data)
+	hash := hex.EncodeToString(sum[:])
+	file, ok := w.files[rel]
+	if ok && file.Hash == hash {
+		return nil, nil
+	}
+	title := strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))
+	if !ok {
+		n := &Note{Title: title, MD: string(data), Notebook: w.Notebook}
+		if err = SaveNewNote(w.ns, n, false); err != nil {
+			return nil, err
+		}
+		w.files[rel] = &WatchedFile{GUID: n.GUID, Hash: hash}
+		return &NoteChange{Type: NoteAdded, Note: n}, nil
+	}
+	n, err := getNoteMetadata(w.ns, file.GUID)
+	if err == ErrNotSupported {
+		// The note service can't tell the note's notebook. The note is
+		// moved to the watcher's notebook if one is given. Otherwise the
+		// notebook is nil, which keeps the note in its notebook.
+		n, err = &Note{GUID: file.GUID, Notebook: w.Notebook}, nil
+	}
+	if err == nil {
+		n.Title, n.MD = title, string(data)
+		// The file is the source of the note's content, so the update
+		// isn't added to the undo log.
+		err = updateNoteContent(w.db, w.ns, nil, n, 0)
+	}
+	if err != nil && err != ErrChangeQueued {
+		return nil, err
+	}
+	file.Hash = hash
+	return &NoteChange{Type: NoteUpdated, Note: n}, err
+}
+
+func (w *DirWatcher) saveState() error {
+	data, err := json.MarshalIndent(w.files, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(w.Dir, DirWatchStateName), data, 0600)
+}
@@ -0,0 +1,175 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirWatcherPoll(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-watch")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "Plan.md"), []byte("# Plan"), 0600))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "sub", "Ideas.md"), []byte("Ideas"), 0600))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "image.png"), []byte("png"), 0600))
+
+	var created, updated []*Note
+	ns := &mockNS{
+		createNote: func(n *Note) error {
+			n.GUID = "guid-" + n.Title
+			created = append(created, n)
+			return nil
+		},
+		updateNote: func(n *Note) error {
+			updated = append(updated, n)
+			return nil
+		},
+	}
+	book := &Notebook{GUID: "book", Name: "Files"}
+	w, err := NewDirWatcher(new(mockStore), ns, dir, book)
+	assert.NoError(err)
+
+	changes, err := w.Poll()
+	assert.NoError(err)
+	if assert.Len(changes, 2) {
+		assert.Equal(NoteAdded, changes[0].Type)
+		assert.Equal("Plan", changes[0].Note.Title)
+		assert.Equal("Ideas", changes[1].Note.Title)
+		assert.Equal(book, changes[1].Note.Notebook)
+	}
+	changes, err = w.Poll()
+	assert.NoError(err)
+	assert.Empty(changes, "Unchanged files should not be synced")
+
+	// A new watcher reads the state and only updates the changed file.
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "Plan.md"), []byte("# Plan\nUpdated"), 0600))
+	w, err = NewDirWatcher(new(mockStore), ns, dir, book)
+	assert.NoError(err)
+	changes, err = w.Poll()
+	assert.NoError(err)
+	if assert.Len(changes, 1) && assert.Len(updated, 1) {
+		assert.Equal(NoteUpdated, changes[0].Type)
+		assert.Equal("guid-Plan", updated[0].GUID)
+		assert.Contains(updated[0].Body, "Updated")
+	}
+	assert.Len(created, 2)
+
+	// Files that fail are synced on the next poll.
+	ns.createNote = func(n *Note) error { return expectedError }
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "New.md"), []byte("New"), 0600))
+	_, err = w.Poll()
+	assert.Equal(expectedError, err)
+	ns.createNote = func(n *Note) error { n.GUID = "new"; return nil }
+	changes, err = w.Poll()
+	assert.NoError(err)
+	assert.Len(changes, 1)
+}
+
+func TestDirWatcherWithoutNotebook(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-watch")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "Plan.md"), []byte("# Plan"), 0600))
+
+	var updated []*Note
+	ns := &mockNS{
+		createNote: func(n *Note) error { n.GUID = "guid"; return nil },
+		updateNote: func(n *Note) error { updated = append(updated, n); return nil },
+	}
+	w, err := NewDirWatcher(new(mockStore), ns, dir, nil)
+	assert.NoError(err)
+	_, err = w.Poll()
+	assert.NoError(err)
+
+	// The mock notestore has no note metadata, so the note's notebook is
+	// unknown when the file is updated.
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "Plan.md"), []byte("# Plan\nUpdated"), 0600))
+	w, err = NewDirWatcher(new(mockStore), ns, dir, nil)
+	assert.NoError(err)
+	_, err = w.Poll()
+	assert.NoError(err)
+	if assert.Len(updated, 1) {
+		assert.Equal("guid", updated[0].GUID)
+		assert.Nil(updated[0].Notebook, "Note should be kept in its notebook")
+	}
+}
+
+func TestDirWatcherWatch(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-watch")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "Broken.md"), []byte("Broken"), 0600))
+
+	ns := &mockNS{
+		createNote: func(n *Note) error {
+			if n.Title == "Broken" {
+				return expectedError
+			}
+			n.GUID = "guid-" + n.Title
+			return nil
+		},
+	}
+	w, err := NewDirWatcher(new(mockStore), ns, dir, nil)
+	assert.NoError(err)
+	// Only file system events sync the directory during the test.
+	w.Interval = time.Hour
+	errs := make(chan error, 10)
+	w.OnError = func(err error) { errs <- err }
+	synced := make(chan []*NoteChange, 10)
+	done := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Watch(done, func(changes []*NoteChange) error {
+			synced <- changes
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-errs:
+		assert.Equal(expectedError, err, "The error should be reported")
+	case <-time.After(5 * time.Second):
+		t.Fatal("The error wasn't reported")
+	}
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "Plan.md"), []byte("# Plan"), 0600))
+	select {
+	case changes := <-synced:
+		if assert.Len(changes, 1) {
+			assert.Equal("Plan", changes[0].Note.Title)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The new file wasn't synced")
+	}
+	close(done)
+	assert.NoError(<-result, "The watch should not be stopped by the error")
+
+	change, err := w.syncFile("Removed.md")
+	assert.NoError(err, "A removed file should be skipped")
+	assert.Nil(change)
+}
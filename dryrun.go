@@ -0,0 +1,116 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NewDryRunNotestore returns a notestore that writes the calls that would
+// change the user's account to w instead of sending them to the server.
+// Calls that only read from the server are passed on to ns.
+func NewDryRunNotestore(ns NotestoreClient, w io.Writer) NotestoreClient {
+	return &dryRunNotestore{NotestoreClient: ns, out: w}
+}
+
+// IsDryRun returns true if the notestore doesn't send changes to the server.
+// Local state that mirrors the server, like the queue of pending changes,
+// should be left untouched when it returns true.
+func IsDryRun(ns NotestoreClient) bool {
+	_, ok := ns.(*dryRunNotestore)
+	return ok
+}
+
+type dryRunNotestore struct {
+	NotestoreClient
+	out io.Writer
+}
+
+func (d *dryRunNotestore) FindNoteVersions(filter *NoteFilter, offset, count int) ([]*Note, error) {
+	return findNoteVersions(d.NotestoreClient, filter, offset, count)
+}
+
+func (d *dryRunNotestore) GetNoteMetadata(guid string) (*Note, error) {
+	return getNoteMetadata(d.NotestoreClient, guid)
+}
+
+func (d *dryRunNotestore) GetNoteRecognition(guid string) ([]*Resource, error) {
+	return GetNoteRecognition(d.NotestoreClient, guid)
+}
+
+func (d *dryRunNotestore) CreateNote(n *Note) error {
+	fmt.Fprintln(d.out, "CreateNote")
+	d.writeNote(n)
+	return nil
+}
+
+func (d *dryRunNotestore) UpdateNote(n *Note) error {
+	fmt.Fprintln(d.out, "UpdateNote")
+	fmt.Fprintf(d.out, "  GUID: %s\n", n.GUID)
+	d.writeNote(n)
+	return nil
+}
+
+func (d *dryRunNotestore) DeleteNote(guid string) error {
+	fmt.Fprintln(d.out, "DeleteNote")
+	fmt.Fprintf(d.out, "  GUID: %s\n", guid)
+	return nil
+}
+
+func (d *dryRunNotestore) CreateNotebook(b *Notebook, defaultNotebook bool) error {
+	fmt.Fprintln(d.out, "CreateNotebook")
+	d.writeNotebook(b)
+	fmt.Fprintf(d.out, "  Default: %t\n", defaultNotebook)
+	return nil
+}
+
+func (d *dryRunNotestore) UpdateNotebook(b *Notebook) error {
+	fmt.Fprintln(d.out, "UpdateNotebook")
+	fmt.Fprintf(d.out, "  GUID: %s\n", b.GUID)
+	d.writeNotebook(b)
+	return nil
+}
+
+func (d *dryRunNotestore) writeNote(n *Note) {
+	fmt.Fprintf(d.out, "  Title: %s\n", n.Title)
+	if n.Notebook != nil {
+		fmt.Fprintf(d.out, "  Notebook: %s\n", n.Notebook.Name)
+	}
+	if n.Tags != nil {
+		fmt.Fprintf(d.out, "  Tags: %s\n", strings.Join(n.Tags, ", "))
+	}
+	if n.SourceURL != "" {
+		fmt.Fprintf(d.out, "  Source URL: %s\n", n.SourceURL)
+	}
+	for _, r := range n.Resources {
+		fmt.Fprintf(d.out, "  Resource: %s (%s, %d bytes)\n", r.Filename, r.Mime, len(r.Data))
+	}
+	// An empty body leaves the content on the server unchanged.
+	if n.Body != "" {
+		fmt.Fprintf(d.out, "  Content:\n%s\n", n.Body)
+	}
+}
+
+func (d *dryRunNotestore) writeNotebook(b *Notebook) {
+	fmt.Fprintf(d.out, "  Name: %s\n", b.Name)
+	if b.Stack != "" {
+		fmt.Fprintf(d.out, "  Stack: %s\n", b.Stack)
+	}
+}
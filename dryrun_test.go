@@ -0,0 +1,60 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunNotestore(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	var updated bool
+	ns := NewDryRunNotestore(&mockNS{updateNote: func(*Note) error { updated = true; return nil }}, &buf)
+	assert.True(IsDryRun(ns))
+	assert.False(IsDryRun(&mockNS{}))
+
+	n := &Note{Title: "Title", MD: "Body", Notebook: &Notebook{Name: "Book"}, Tags: []string{"a", "b"}}
+	assert.NoError(SaveNewNote(ns, n, false))
+	assert.Equal("CreateNote\n  Title: Title\n  Notebook: Book\n  Tags: a, b\n  Content:\n"+n.Body+"\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(ns.UpdateNote(&Note{GUID: "GUID", Title: "New title"}))
+	assert.False(updated, "Update should not be sent to the notestore")
+	assert.Equal("UpdateNote\n  GUID: GUID\n  Title: New title\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(ns.DeleteNote("GUID"))
+	assert.Equal("DeleteNote\n  GUID: GUID\n", buf.String())
+}
+
+func TestDryRunSync(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	var saved *SyncState
+	db := &mockStore{saveSyncState: func(s *SyncState) error { saved = s; return nil }}
+	assert.NoError(QueueChange(db, ChangeDelete, &Note{GUID: "GUID"}, nil))
+
+	assert.NoError(Sync(db, NewDryRunNotestore(&mockNS{}, &buf)))
+	assert.Equal("DeleteNote\n  GUID: GUID\n", buf.String())
+	assert.Len(db.pendingChanges, 1, "The queue should be left untouched")
+	assert.Nil(saved, "Sync state should not be saved")
+}
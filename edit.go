@@ -69,6 +69,15 @@ func (e *EnvEditor) Edit(file CacheFile) error {
 	return executeEditorViaCommand(editor, file.FilePath())
 }
 
+// EditFile opens the file with the editor defined in $EDITOR.
+func EditFile(path string) error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		return ErrNoEditorFound
+	}
+	return executeEditorViaCommand(editor, path)
+}
+
 func executeEditorViaCommand(editor, filepath string) error {
 	cmd := exec.Command(editor, filepath)
 	cmd.Stdin = os.Stdin
@@ -0,0 +1,295 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TcM1911/clinote/markdown"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// encryptedBlockMagic is the start of the AES encrypted blocks.
+	encryptedBlockMagic = "ENC0"
+	// encryptedBlockKeyRuns is the number of PBKDF2 iterations used by
+	// Evernote for the AES encrypted blocks.
+	encryptedBlockKeyRuns = 50000
+	encryptedBlockKeySize = 16
+	encryptedBlockHMACLen = sha256.Size
+	// rc2DefaultKeyBits is the effective key length of RC2 blocks if the
+	// length attribute isn't set.
+	rc2DefaultKeyBits = 64
+)
+
+var (
+	// ErrUnsupportedCipher is returned if an encrypted block uses a cipher
+	// that isn't supported.
+	ErrUnsupportedCipher = errors.New("unsupported cipher")
+	// ErrSectionNotFound is returned if the text to encrypt isn't found in
+	// the note.
+	ErrSectionNotFound = errors.New("text not found in the note")
+)
+
+var encryptedBlockPattern = regexp.MustCompile(`(?s)<en-crypt\b([^>]*)>(.*?)</en-crypt>`)
+
+var encryptedBlockAttrPattern = regexp.MustCompile(`([a-zA-Z-]+)\s*=\s*"([^"]*)"`)
+
+// textEscaper escapes text the way it's written in the note content.
+// Quotes are only escaped in attributes.
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// EncryptedBlock is an encrypted section of a note, stored in an
+// <en-crypt> element.
+type EncryptedBlock struct {
+	// Cipher is AES or RC2.
+	Cipher string
+	// Length is the key length in bits.
+	Length int
+	// Hint is the passphrase hint given by the user.
+	Hint string
+	// Data is the base64 encoded cipher text.
+	Data string
+}
+
+// FindEncryptedBlocks returns the encrypted blocks in the note body.
+func FindEncryptedBlocks(body string) []*EncryptedBlock {
+	var blocks []*EncryptedBlock
+	for _, m := range encryptedBlockPattern.FindAllStringSubmatch(body, -1) {
+		blocks = append(blocks, parseEncryptedBlock(m[1], m[2]))
+	}
+	return blocks
+}
+
+func parseEncryptedBlock(attrs, data string) *EncryptedBlock {
+	b := &EncryptedBlock{Cipher: "RC2", Length: rc2DefaultKeyBits, Data: strings.TrimSpace(data)}
+	for _, a := range encryptedBlockAttrPattern.FindAllStringSubmatch(attrs, -1) {
+		switch strings.ToLower(a[1]) {
+		case "cipher":
+			b.Cipher = strings.ToUpper(a[2])
+		case "length":
+			if n, err := strconv.Atoi(a[2]); err == nil {
+				b.Length = n
+			}
+		case "hint":
+			b.Hint = html.UnescapeString(a[2])
+		}
+	}
+	return b
+}
+
+// Decrypt decrypts the block with the passphrase. The decrypted content
+// is returned as ENML.
+func (b *EncryptedBlock) Decrypt(passphrase string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(b.Data), ""))
+	if err != nil {
+		return "", ErrDecryptionFailed
+	}
+	switch b.Cipher {
+	case "AES":
+		return decryptAESBlock(passphrase, data)
+	case "RC2":
+		return decryptRC2Block(passphrase, data, b.Length)
+	}
+	return "", ErrUnsupportedCipher
+}
+
+// EncryptSection encrypts the ENML content with the passphrase and returns
+// an <en-crypt> element that can replace it in a note. New blocks are
+// always encrypted with AES.
+func EncryptSection(content, passphrase, hint string) (string, error) {
+	if passphrase == "" {
+		return "", ErrEmptyPassphrase
+	}
+	var salt, saltHMAC, iv [16]byte
+	for _, b := range [][]byte{salt[:], saltHMAC[:], iv[:]} {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return "", err
+		}
+	}
+	key := pbkdf2.Key([]byte(passphrase), salt[:], encryptedBlockKeyRuns, encryptedBlockKeySize, sha256.New)
+	hmacKey := pbkdf2.Key([]byte(passphrase), saltHMAC[:], encryptedBlockKeyRuns, encryptedBlockKeySize, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	// PKCS#7 padding.
+	pad := aes.BlockSize - len(content)%aes.BlockSize
+	plaintext := append([]byte(content), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv[:]).CryptBlocks(ciphertext, plaintext)
+
+	data := []byte(encryptedBlockMagic)
+	data = append(data, salt[:]...)
+	data = append(data, saltHMAC[:]...)
+	data = append(data, iv[:]...)
+	data = append(data, ciphertext...)
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(data)
+	data = mac.Sum(data)
+
+	hintAttr := ""
+	if hint != "" {
+		hintAttr = fmt.Sprintf(` hint="%s"`, html.EscapeString(hint))
+	}
+	return fmt.Sprintf(`<en-crypt cipher="AES" length="128"%s>%s</en-crypt>`, hintAttr, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// decryptAESBlock decrypts the blocks used by current Evernote clients. The
+// data is the magic "ENC0", the key salt, the HMAC salt, the IV, the AES-CBC
+// cipher text and an HMAC-SHA256 of everything before it.
+func decryptAESBlock(passphrase string, data []byte) (string, error) {
+	header := len(encryptedBlockMagic) + 3*16
+	if len(data) < header+aes.BlockSize+encryptedBlockHMACLen || string(data[:4]) != encryptedBlockMagic {
+		return "", ErrDecryptionFailed
+	}
+	salt, saltHMAC, iv := data[4:20], data[20:36], data[36:52]
+	signed, sum := data[:len(data)-encryptedBlockHMACLen], data[len(data)-encryptedBlockHMACLen:]
+	hmacKey := pbkdf2.Key([]byte(passphrase), saltHMAC, encryptedBlockKeyRuns, encryptedBlockKeySize, sha256.New)
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(signed)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return "", ErrDecryptionFailed
+	}
+	ciphertext := signed[header:]
+	if len(ciphertext)%aes.BlockSize != 0 {
+		return "", ErrDecryptionFailed
+	}
+	key := pbkdf2.Key([]byte(passphrase), salt, encryptedBlockKeyRuns, encryptedBlockKeySize, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return "", ErrDecryptionFailed
+	}
+	return string(plaintext[:len(plaintext)-pad]), nil
+}
+
+// decryptRC2Block decrypts the blocks created by older Evernote clients.
+// The key is the MD5 hash of the passphrase and the blocks are encrypted in
+// ECB mode. The plain text starts with the first four characters of the
+// hex encoded CRC32 checksum of the content and is padded with zeros. Some
+// clients used the hex encoded hash as the key, so both are tried.
+func decryptRC2Block(passphrase string, data []byte, bits int) (string, error) {
+	if len(data) == 0 || len(data)%rc2BlockSize != 0 {
+		return "", ErrDecryptionFailed
+	}
+	sum := md5.Sum([]byte(passphrase))
+	for _, key := range [][]byte{sum[:], []byte(hex.EncodeToString(sum[:]))} {
+		c := newRC2Cipher(key, bits)
+		plaintext := make([]byte, len(data))
+		for i := 0; i < len(data); i += rc2BlockSize {
+			c.Decrypt(plaintext[i:], data[i:])
+		}
+		content := bytes.TrimRight(plaintext[4:], "\x00")
+		checksum := fmt.Sprintf("%08X", crc32.ChecksumIEEE(content))
+		if strings.EqualFold(string(plaintext[:4]), checksum[:4]) {
+			return string(content), nil
+		}
+	}
+	return "", ErrDecryptionFailed
+}
+
+// PassphraseFunc returns the passphrase for an encrypted block. The hint
+// is empty if the block doesn't have one.
+type PassphraseFunc func(hint string) (string, error)
+
+// DecryptNote replaces the encrypted blocks in the note's content with
+// the decrypted content. Passphrases that decrypted earlier blocks are
+// tried first, so the user is only asked once if all the blocks use the
+// same passphrase. The note is left unchanged if a block can't be
+// decrypted. The number of decrypted blocks is returned.
+func DecryptNote(n *Note, passphrase PassphraseFunc) (int, error) {
+	var known []string
+	count := 0
+	var err error
+	body := encryptedBlockPattern.ReplaceAllStringFunc(n.Body, func(m string) string {
+		if err != nil {
+			return m
+		}
+		sub := encryptedBlockPattern.FindStringSubmatch(m)
+		b := parseEncryptedBlock(sub[1], sub[2])
+		for _, p := range known {
+			if content, e := b.Decrypt(p); e == nil {
+				count++
+				return content
+			}
+		}
+		var p, content string
+		if p, err = passphrase(b.Hint); err != nil {
+			return m
+		}
+		if content, err = b.Decrypt(p); err != nil {
+			return m
+		}
+		known = append(known, p)
+		count++
+		return content
+	})
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	md, err := markdown.FromHTML(body)
+	if err != nil {
+		return 0, err
+	}
+	n.Body = body
+	n.MD = md
+	return count, nil
+}
+
+// HasEncryptedBlocks returns true if the note's content has encrypted blocks.
+func HasEncryptedBlocks(n *Note) bool {
+	return encryptedBlockPattern.MatchString(n.Body)
+}
+
+// EncryptNoteSection replaces the first occurrence of the text in the
+// note's content with an encrypted block.
+func EncryptNoteSection(n *Note, text, passphrase, hint string) error {
+	escaped := textEscaper.Replace(text)
+	i := strings.Index(n.Body, escaped)
+	if text == "" || i < 0 {
+		return ErrSectionNotFound
+	}
+	block, err := EncryptSection(escaped, passphrase, hint)
+	if err != nil {
+		return err
+	}
+	n.Body = n.Body[:i] + block + n.Body[i+len(escaped):]
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRC2(t *testing.T) {
+	assert := assert.New(t)
+	// Test vectors from RFC 2268.
+	tests := []struct {
+		key, plaintext, ciphertext string
+		bits                       int
+	}{
+		{"0000000000000000", "0000000000000000", "ebb773f993278eff", 63},
+		{"ffffffffffffffff", "ffffffffffffffff", "278b27e42e2f0d49", 64},
+		{"3000000000000000", "1000000000000001", "30649edf9be7d2c2", 64},
+		{"88", "0000000000000000", "61a8a244adacccf0", 64},
+		{"88bca90e90875a7f0f79c384627bafb2", "0000000000000000", "2269552ab0f85ca6", 128},
+	}
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		plaintext, _ := hex.DecodeString(test.plaintext)
+		c := newRC2Cipher(key, test.bits)
+		dst := make([]byte, rc2BlockSize)
+		c.Encrypt(dst, plaintext)
+		assert.Equal(test.ciphertext, hex.EncodeToString(dst), test.key)
+		c.Decrypt(dst, dst)
+		assert.Equal(test.plaintext, hex.EncodeToString(dst), test.key)
+	}
+}
+
+func TestEncryptedBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("AES", func(t *testing.T) {
+		enc, err := EncryptSection("<div>secret &amp; more</div>", "pass", `"pet"`)
+		assert.NoError(err)
+		blocks := FindEncryptedBlocks("<div>before</div>" + enc)
+		if !assert.Len(blocks, 1) {
+			return
+		}
+		assert.Equal("AES", blocks[0].Cipher)
+		assert.Equal(128, blocks[0].Length)
+		assert.Equal(`"pet"`, blocks[0].Hint)
+		content, err := blocks[0].Decrypt("pass")
+		assert.NoError(err)
+		assert.Equal("<div>secret &amp; more</div>", content)
+		_, err = blocks[0].Decrypt("wrong")
+		assert.Equal(ErrDecryptionFailed, err)
+	})
+
+	t.Run("RC2", func(t *testing.T) {
+		block := &EncryptedBlock{Cipher: "RC2", Length: 64, Data: rc2Block("pass", "secret")}
+		content, err := block.Decrypt("pass")
+		assert.NoError(err)
+		assert.Equal("secret", content)
+		_, err = block.Decrypt("wrong")
+		assert.Equal(ErrDecryptionFailed, err)
+	})
+
+	t.Run("RC2 is the default cipher", func(t *testing.T) {
+		blocks := FindEncryptedBlocks(fmt.Sprintf(`<en-crypt hint="h">%s</en-crypt>`, rc2Block("pass", "secret")))
+		if assert.Len(blocks, 1) {
+			assert.Equal("RC2", blocks[0].Cipher)
+			assert.Equal(64, blocks[0].Length)
+		}
+	})
+
+	t.Run("unsupported cipher", func(t *testing.T) {
+		_, err := (&EncryptedBlock{Cipher: "DES"}).Decrypt("pass")
+		assert.Equal(ErrUnsupportedCipher, err)
+	})
+}
+
+func TestDecryptNote(t *testing.T) {
+	assert := assert.New(t)
+	first, _ := EncryptSection("first", "pass", "")
+	second, _ := EncryptSection("second", "pass", "")
+	n := &Note{Body: "<div>" + first + "</div><div>" + second + "</div>"}
+	asked := 0
+	count, err := DecryptNote(n, func(string) (string, error) {
+		asked++
+		return "pass", nil
+	})
+	assert.NoError(err)
+	assert.Equal(2, count)
+	assert.Equal(1, asked, "Passphrase should only be asked for once")
+	assert.Equal("<div>first</div><div>second</div>", n.Body)
+	assert.Equal("first\n\nsecond", n.MD)
+
+	body := "<div>" + first + "</div>"
+	n = &Note{Body: body}
+	_, err = DecryptNote(n, func(string) (string, error) { return "wrong", nil })
+	assert.Equal(ErrDecryptionFailed, err)
+	assert.Equal(body, n.Body, "Note should not be changed")
+}
+
+func TestEncryptNoteSection(t *testing.T) {
+	assert := assert.New(t)
+	n := &Note{Body: "<div>Code: a &amp; b</div>"}
+	assert.NoError(EncryptNoteSection(n, "a & b", "pass", "hint"))
+	assert.True(HasEncryptedBlocks(n))
+	assert.NotContains(n.Body, "a &amp; b")
+	_, err := DecryptNote(n, func(hint string) (string, error) {
+		assert.Equal("hint", hint)
+		return "pass", nil
+	})
+	assert.NoError(err)
+	assert.Equal("<div>Code: a &amp; b</div>", n.Body)
+
+	assert.Equal(ErrSectionNotFound, EncryptNoteSection(n, "missing", "pass", ""))
+}
+
+// rc2Block encrypts the content like the old Evernote clients.
+func rc2Block(passphrase, content string) string {
+	checksum := fmt.Sprintf("%08X", crc32.ChecksumIEEE([]byte(content)))
+	data := []byte(checksum[:4] + content)
+	for len(data)%rc2BlockSize != 0 {
+		data = append(data, 0)
+	}
+	key := md5.Sum([]byte(passphrase))
+	c := newRC2Cipher(key[:], 64)
+	for i := 0; i < len(data); i += rc2BlockSize {
+		c.Encrypt(data[i:], data[i:])
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
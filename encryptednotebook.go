@@ -0,0 +1,371 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// encryptedNoteMarker identifies the content of notes in client encrypted
+// notebooks. It's followed by the notebook GUID and the encrypted content.
+const encryptedNoteMarker = "clinote-encrypted:v1"
+
+var (
+	// ErrNoNotebookKey is returned if a note is encrypted with the key of a
+	// notebook that isn't set up on this computer.
+	ErrNoNotebookKey = errors.New("notebook key not found, run \"notebook encrypt\" with the notebook's passphrase")
+	// ErrNotebookNotEncrypted is returned if the notebook isn't client encrypted.
+	ErrNotebookNotEncrypted = errors.New("notebook is not encrypted")
+	// ErrKeyStoreNotSupported is returned if the storage can't hold notebook keys.
+	ErrKeyStoreNotSupported = errors.New("storage can't save notebook keys")
+)
+
+var encryptedNotePattern = regexp.MustCompile(encryptedNoteMarker + `:([^:<\s]+):([A-Za-z0-9+/=]+)`)
+
+// NotebookKeyStore stores the keys of the client encrypted notebooks.
+type NotebookKeyStore interface {
+	// GetNotebookKeys returns the keys, keyed by notebook GUID.
+	GetNotebookKeys() (map[string][]byte, error)
+	// SaveNotebookKey saves the key for the notebook. A nil key removes it.
+	SaveNotebookKey(guid string, key []byte) error
+}
+
+// deriveNotebookKey derives the notebook's key from the passphrase. The
+// notebook GUID is used as the salt so the same key is derived on all
+// computers.
+func deriveNotebookKey(passphrase, guid string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	return deriveKey(passphrase, []byte("clinote-notebook:"+guid))
+}
+
+// encryptNoteContent returns the ENML content that replaces the note's
+// content on the server.
+func encryptNoteContent(key []byte, guid, content string) (string, error) {
+	sealed, err := encryptWithKey(key, []byte(content))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s<en-note><div>This note is encrypted by CLInote.</div><div>%s:%s:%s</div></en-note>",
+		XMLHeader, encryptedNoteMarker, guid, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// encryptedNoteNotebook returns the GUID of the notebook the content was
+// encrypted for. False is returned if the content isn't encrypted.
+func encryptedNoteNotebook(content string) (string, []byte, bool) {
+	m := encryptedNotePattern.FindStringSubmatch(content)
+	if m == nil {
+		return "", nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(m[2])
+	if err != nil {
+		return "", nil, false
+	}
+	return m[1], data, true
+}
+
+// NewEncryptedNotestore returns a notestore that encrypts the content of
+// notes in the client encrypted notebooks before they are sent to the
+// server and decrypts it when it's read. The keys are read from the key
+// store for every call so keys added after the notestore is created are
+// used. Notes in encrypted notebooks without a key in the key store are
+// not uploaded, ErrNoNotebookKey is returned instead.
+func NewEncryptedNotestore(ns NotestoreClient, keys NotebookKeyStore) NotestoreClient {
+	return &encryptedNotestore{NotestoreClient: ns, keys: keys, encrypted: make(map[string]bool)}
+}
+
+type encryptedNotestore struct {
+	NotestoreClient
+	keys NotebookKeyStore
+	// encryptedMu guards encrypted.
+	encryptedMu sync.Mutex
+	// encrypted caches if the notebooks without a key are encrypted.
+	encrypted map[string]bool
+}
+
+func (s *encryptedNotestore) GetNoteContent(guid string) (string, error) {
+	content, err := s.NotestoreClient.GetNoteContent(guid)
+	if err != nil {
+		return "", err
+	}
+	notebook, data, ok := encryptedNoteNotebook(content)
+	if !ok {
+		return content, nil
+	}
+	keys, err := s.keys.GetNotebookKeys()
+	if err != nil {
+		return "", err
+	}
+	key, ok := keys[notebook]
+	if !ok {
+		return "", ErrNoNotebookKey
+	}
+	plaintext, err := decryptWithKey(key, data)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (s *encryptedNotestore) FindNoteVersions(filter *NoteFilter, offset, count int) ([]*Note, error) {
+	return findNoteVersions(s.NotestoreClient, filter, offset, count)
+}
+
+func (s *encryptedNotestore) GetNoteMetadata(guid string) (*Note, error) {
+	return getNoteMetadata(s.NotestoreClient, guid)
+}
+
+func (s *encryptedNotestore) GetNoteRecognition(guid string) ([]*Resource, error) {
+	return GetNoteRecognition(s.NotestoreClient, guid)
+}
+
+func (s *encryptedNotestore) CreateNote(n *Note) error {
+	return s.withEncryptedBody(n, false, s.NotestoreClient.CreateNote)
+}
+
+func (s *encryptedNotestore) UpdateNote(n *Note) error {
+	return s.withEncryptedBody(n, true, s.NotestoreClient.UpdateNote)
+}
+
+// withEncryptedBody calls the function with the note's content encrypted
+// if the note is in an encrypted notebook. The note's content is restored
+// afterwards.
+func (s *encryptedNotestore) withEncryptedBody(n *Note, update bool, call func(*Note) error) error {
+	if n.Body == "" || encryptedNotePattern.MatchString(n.Body) {
+		return call(n)
+	}
+	key, guid, err := s.noteKey(n, update)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return call(n)
+	}
+	body := n.Body
+	if n.Body, err = encryptNoteContent(key, guid, body); err != nil {
+		n.Body = body
+		return err
+	}
+	err = call(n)
+	n.Body = body
+	return err
+}
+
+// noteKey returns the key and the GUID of the notebook the note's content
+// is encrypted for. A nil key is returned if the note isn't in an
+// encrypted notebook. If the notebook is encrypted but its key isn't in
+// the key store, for example on another computer, ErrNoNotebookKey is
+// returned so the content isn't uploaded in plaintext.
+func (s *encryptedNotestore) noteKey(n *Note, update bool) ([]byte, string, error) {
+	keys, err := s.keys.GetNotebookKeys()
+	if err != nil {
+		return nil, "", err
+	}
+	if n.Notebook == nil || n.Notebook.GUID == "" {
+		if !update {
+			return nil, "", nil
+		}
+		// The note stays in its notebook. The server copy tells if
+		// the notebook is encrypted.
+		content, err := s.NotestoreClient.GetNoteContent(n.GUID)
+		if err != nil {
+			return nil, "", err
+		}
+		guid, _, ok := encryptedNoteNotebook(content)
+		if !ok {
+			return nil, "", nil
+		}
+		if key, ok := keys[guid]; ok {
+			return key, guid, nil
+		}
+		return nil, "", ErrNoNotebookKey
+	}
+	guid := n.Notebook.GUID
+	if key, ok := keys[guid]; ok {
+		return key, guid, nil
+	}
+	encrypted, err := s.notebookEncrypted(guid)
+	if err != nil {
+		return nil, "", err
+	}
+	if encrypted {
+		return nil, "", ErrNoNotebookKey
+	}
+	return nil, "", nil
+}
+
+// notebookEncrypted returns true if the notes in the notebook are
+// encrypted. All notes are encrypted when the notebook is, so only the
+// first note is checked.
+func (s *encryptedNotestore) notebookEncrypted(guid string) (bool, error) {
+	s.encryptedMu.Lock()
+	encrypted, ok := s.encrypted[guid]
+	s.encryptedMu.Unlock()
+	if ok {
+		return encrypted, nil
+	}
+	notes, err := s.NotestoreClient.FindNotes(&NoteFilter{NotebookGUID: guid}, 0, 1)
+	if err != nil {
+		return false, err
+	}
+	if len(notes) != 0 {
+		content, err := s.NotestoreClient.GetNoteContent(notes[0].GUID)
+		if err != nil {
+			return false, err
+		}
+		book, _, ok := encryptedNoteNotebook(content)
+		encrypted = ok && book == guid
+	}
+	s.encryptedMu.Lock()
+	s.encrypted[guid] = encrypted
+	s.encryptedMu.Unlock()
+	return encrypted, nil
+}
+
+// storedNotebookKey returns the key the storage has for the notebook. Nil is
+// returned if the notebook isn't client encrypted.
+func storedNotebookKey(db Storager, guid string) ([]byte, error) {
+	keys, ok := db.(NotebookKeyStore)
+	if !ok || guid == "" {
+		return nil, nil
+	}
+	stored, err := keys.GetNotebookKeys()
+	if err != nil {
+		return nil, err
+	}
+	return stored[guid], nil
+}
+
+// RawNotestore returns the notestore wrapped by NewEncryptedNotestore. If
+// the notestore isn't wrapped, it's returned as is.
+func RawNotestore(ns NotestoreClient) NotestoreClient {
+	if e, ok := ns.(*encryptedNotestore); ok {
+		return e.NotestoreClient
+	}
+	return ns
+}
+
+// EncryptNotebook sets up client encryption for the notebook with a key
+// derived from the passphrase. The key is saved in the key store and the
+// notes in the notebook that aren't encrypted are encrypted. If notes in
+// the notebook are already encrypted, for example from another computer,
+// the passphrase is checked against them. The number of encrypted notes
+// is returned.
+func EncryptNotebook(db Storager, keys NotebookKeyStore, ns NotestoreClient, name, passphrase string) (int, error) {
+	ns = RawNotestore(ns)
+	nb, err := FindNotebook(db, ns, name)
+	if err != nil {
+		return 0, err
+	}
+	key, err := deriveNotebookKey(passphrase, nb.GUID)
+	if err != nil {
+		return 0, err
+	}
+	notes, err := FindAllNotes(ns, &NoteFilter{NotebookGUID: nb.GUID}, 100)
+	if err != nil {
+		return 0, err
+	}
+	contents := make(map[string]string, len(notes))
+	for _, n := range notes {
+		content, err := ns.GetNoteContent(n.GUID)
+		if err != nil {
+			return 0, err
+		}
+		if guid, data, ok := encryptedNoteNotebook(content); ok {
+			if guid == nb.GUID {
+				if _, err = decryptWithKey(key, data); err != nil {
+					return 0, err
+				}
+			}
+			continue
+		}
+		contents[n.GUID] = content
+	}
+	if err = keys.SaveNotebookKey(nb.GUID, key); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, n := range notes {
+		content, ok := contents[n.GUID]
+		if !ok {
+			continue
+		}
+		if n.Body, err = encryptNoteContent(key, nb.GUID, content); err != nil {
+			return count, err
+		}
+		n.Notebook = nb
+		if err = ns.UpdateNote(n); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// DecryptNotebook turns off client encryption for the notebook. The notes
+// are decrypted and saved to the server before the key is removed from
+// the key store. The number of decrypted notes is returned.
+func DecryptNotebook(db Storager, keys NotebookKeyStore, ns NotestoreClient, name string) (int, error) {
+	ns = RawNotestore(ns)
+	nb, err := FindNotebook(db, ns, name)
+	if err != nil {
+		return 0, err
+	}
+	stored, err := keys.GetNotebookKeys()
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := stored[nb.GUID]; !ok {
+		return 0, ErrNotebookNotEncrypted
+	}
+	notes, err := FindAllNotes(ns, &NoteFilter{NotebookGUID: nb.GUID}, 100)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, n := range notes {
+		content, err := ns.GetNoteContent(n.GUID)
+		if err != nil {
+			return count, err
+		}
+		guid, data, ok := encryptedNoteNotebook(content)
+		if !ok {
+			continue
+		}
+		key, ok := stored[guid]
+		if !ok {
+			return count, ErrNoNotebookKey
+		}
+		plaintext, err := decryptWithKey(key, data)
+		if err != nil {
+			return count, err
+		}
+		n.Body = string(plaintext)
+		n.Notebook = nb
+		if err = ns.UpdateNote(n); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, keys.SaveNotebookKey(nb.GUID, nil)
+}
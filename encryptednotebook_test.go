@@ -0,0 +1,179 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockKeyStore map[string][]byte
+
+func (m mockKeyStore) GetNotebookKeys() (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for k, v := range m {
+		keys[k] = v
+	}
+	return keys, nil
+}
+
+func (m mockKeyStore) SaveNotebookKey(guid string, key []byte) error {
+	if key == nil {
+		delete(m, guid)
+	} else {
+		m[guid] = key
+	}
+	return nil
+}
+
+func TestEncryptedNotestore(t *testing.T) {
+	assert := assert.New(t)
+	key, err := deriveNotebookKey("pass", "secret-guid")
+	assert.NoError(err)
+	keys := mockKeyStore{"secret-guid": key}
+	server := make(map[string]string)
+	books := make(map[string]string)
+	inner := &mockNS{
+		createNote: func(n *Note) error {
+			server[n.GUID] = n.Body
+			if n.Notebook != nil {
+				books[n.GUID] = n.Notebook.GUID
+			}
+			return nil
+		},
+		updateNote:     func(n *Note) error { server[n.GUID] = n.Body; return nil },
+		getNoteContent: func(guid string) (string, error) { return server[guid], nil },
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			var notes []*Note
+			for guid, book := range books {
+				if book == f.NotebookGUID {
+					notes = append(notes, &Note{GUID: guid})
+				}
+			}
+			return notes, nil
+		},
+	}
+	ns := NewEncryptedNotestore(inner, keys)
+	body := XMLHeader + "<en-note><div>Secret</div></en-note>"
+
+	t.Run("encrypted notebook", func(t *testing.T) {
+		n := &Note{GUID: "1", Body: body, Notebook: &Notebook{GUID: "secret-guid"}}
+		assert.NoError(ns.CreateNote(n))
+		assert.Equal(body, n.Body, "Note's body should be restored")
+		assert.NotContains(server["1"], "Secret")
+		assert.Contains(server["1"], encryptedNoteMarker+":secret-guid:")
+		content, err := ns.GetNoteContent("1")
+		assert.NoError(err)
+		assert.Equal(body, content)
+	})
+
+	t.Run("not encrypted notebook", func(t *testing.T) {
+		n := &Note{GUID: "2", Body: body, Notebook: &Notebook{GUID: "other"}}
+		assert.NoError(ns.UpdateNote(n))
+		assert.Equal(body, server["2"])
+	})
+
+	t.Run("not encrypted twice", func(t *testing.T) {
+		n := &Note{GUID: "3", Body: server["1"], Notebook: &Notebook{GUID: "secret-guid"}}
+		assert.NoError(ns.UpdateNote(n))
+		assert.Equal(server["1"], server["3"])
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := NewEncryptedNotestore(inner, mockKeyStore{}).GetNoteContent("1")
+		assert.Equal(ErrNoNotebookKey, err)
+	})
+
+	t.Run("no plaintext upload without key", func(t *testing.T) {
+		noKey := NewEncryptedNotestore(inner, mockKeyStore{})
+		encrypted := server["1"]
+		n := &Note{GUID: "1", Body: body, Notebook: &Notebook{GUID: "secret-guid"}}
+		assert.Equal(ErrNoNotebookKey, noKey.UpdateNote(n))
+		n = &Note{GUID: "1", Body: body}
+		assert.Equal(ErrNoNotebookKey, noKey.UpdateNote(n), "Note kept in its notebook")
+		n = &Note{GUID: "4", Body: body, Notebook: &Notebook{GUID: "secret-guid"}}
+		assert.Equal(ErrNoNotebookKey, noKey.CreateNote(n))
+		assert.Equal(encrypted, server["1"], "Server copy should not change")
+		assert.Empty(server["4"])
+	})
+
+	t.Run("note kept in its notebook", func(t *testing.T) {
+		n := &Note{GUID: "1", Body: body}
+		assert.NoError(ns.UpdateNote(n))
+		assert.Contains(server["1"], encryptedNoteMarker+":secret-guid:")
+	})
+
+	t.Run("raw notestore", func(t *testing.T) {
+		assert.Equal(inner, RawNotestore(ns))
+		assert.Equal(inner, RawNotestore(inner))
+	})
+}
+
+func TestEncryptNotebook(t *testing.T) {
+	assert := assert.New(t)
+	nb := &Notebook{GUID: "guid", Name: "Secret"}
+	db := &mockStore{
+		getNotebookCache: func() (*NotebookCacheList, error) { return NewNotebookCacheList([]*Notebook{nb}), nil },
+	}
+	body := XMLHeader + "<en-note><div>Secret</div></en-note>"
+	server := map[string]string{"1": body, "2": body}
+	ns := &mockNS{
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			assert.Equal("guid", f.NotebookGUID)
+			if offset > 0 {
+				return nil, nil
+			}
+			return []*Note{{GUID: "1", Title: "One"}, {GUID: "2", Title: "Two"}}, nil
+		},
+		updateNote:     func(n *Note) error { server[n.GUID] = n.Body; return nil },
+		getNoteContent: func(guid string) (string, error) { return server[guid], nil },
+	}
+	keys := mockKeyStore{}
+
+	count, err := EncryptNotebook(db, keys, ns, "Secret", "pass")
+	assert.NoError(err)
+	assert.Equal(2, count)
+	assert.Contains(keys, "guid")
+	assert.NotContains(server["1"], "<div>Secret</div>")
+
+	t.Run("same passphrase on another computer", func(t *testing.T) {
+		other := mockKeyStore{}
+		count, err := EncryptNotebook(db, other, ns, "Secret", "pass")
+		assert.NoError(err)
+		assert.Equal(0, count, "Encrypted notes should not be encrypted again")
+		assert.Equal(keys["guid"], other["guid"])
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		other := mockKeyStore{}
+		_, err := EncryptNotebook(db, other, ns, "Secret", "wrong")
+		assert.Equal(ErrDecryptionFailed, err)
+		assert.Empty(other)
+	})
+
+	t.Run("decrypt", func(t *testing.T) {
+		count, err := DecryptNotebook(db, keys, ns, "Secret")
+		assert.NoError(err)
+		assert.Equal(2, count)
+		assert.Equal(body, server["1"])
+		assert.Empty(keys)
+		_, err = DecryptNotebook(db, keys, ns, "Secret")
+		assert.Equal(ErrNotebookNotEncrypted, err)
+	})
+}
@@ -0,0 +1,146 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Hosts of the services CLInote can talk to.
+const (
+	// EvernoteHost is Evernote's production server.
+	EvernoteHost = "www.evernote.com"
+	// SandboxHost is Evernote's sandbox server used for development.
+	SandboxHost = "sandbox.evernote.com"
+	// YinxiangHost is the server of Yinxiang Biji, Evernote's service in China.
+	YinxiangHost = "app.yinxiang.com"
+	// JoplinHost is the default address of the Joplin desktop app's data API.
+	JoplinHost = "localhost:41184"
+)
+
+// ErrInvalidEndpoint is returned if an endpoint is neither a known service
+// nor a host name.
+var ErrInvalidEndpoint = errors.New("invalid endpoint, use evernote, sandbox, yinxiang or a host name")
+
+var endpointHosts = map[string]string{
+	"evernote":   EvernoteHost,
+	"production": EvernoteHost,
+	"sandbox":    SandboxHost,
+	"yinxiang":   YinxiangHost,
+	"china":      YinxiangHost,
+}
+
+var endpointNames = map[string]string{
+	EvernoteHost: "Evernote",
+	SandboxHost:  "Evernote Sandbox",
+	YinxiangHost: "Yinxiang",
+}
+
+// Endpoint is the server a credential authenticates against. Empty fields
+// use the defaults: the host of the credential type and CLInote's own API key.
+type Endpoint struct {
+	// Host is the API host, for example app.yinxiang.com.
+	Host string `json:",omitempty"`
+	// ConsumerKey overrides the API consumer key.
+	ConsumerKey string `json:",omitempty"`
+	// ConsumerSecret overrides the API consumer secret.
+	ConsumerSecret string `json:",omitempty"`
+}
+
+// CredentialType returns the credential type for credentials using the endpoint.
+func (e Endpoint) CredentialType() CredentialType {
+	if e.Host == SandboxHost {
+		return EvernoteSandboxCredential
+	}
+	return EvernoteCredential
+}
+
+// ParseEndpointHost returns the API host for a service name, evernote,
+// sandbox or yinxiang, or for a host name. An empty string returns an
+// empty host which means the default is used.
+func ParseEndpointHost(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return "", nil
+	}
+	if host, ok := endpointHosts[s]; ok {
+		return host, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", ErrInvalidEndpoint
+	}
+	return u.Host, nil
+}
+
+// APIHost returns the API host the credential authenticates against.
+func (c *Credential) APIHost() string {
+	if c.Host != "" {
+		return c.Host
+	}
+	switch c.CredType {
+	case EvernoteSandboxCredential:
+		return SandboxHost
+	case JoplinCredential:
+		return JoplinHost
+	}
+	return EvernoteHost
+}
+
+// ServiceName returns the name of the service the credential is for. Hosts
+// not known to CLInote are returned as is.
+func (c *Credential) ServiceName() string {
+	if c.Host == "" || c.CredType == JoplinCredential || c.CredType == FolderCredential {
+		return c.CredType.String()
+	}
+	if name, ok := endpointNames[c.Host]; ok {
+		return name
+	}
+	return c.Host
+}
+
+// SetCredentialEndpoint changes the endpoint of the credential at the index.
+// If it's the active credential, the settings are updated too.
+func SetCredentialEndpoint(store UserCredentialStore, db Storager, index int, ep Endpoint) (*Credential, error) {
+	cred, err := GetCredential(store, index)
+	if err != nil {
+		return nil, err
+	}
+	updated := *cred
+	updated.Endpoint = ep
+	if ep.Host != "" && cred.CredType != JoplinCredential && cred.CredType != FolderCredential {
+		updated.CredType = ep.CredentialType()
+	}
+	if err = store.Update(index, &updated); err != nil {
+		return nil, err
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if settings.Credential == nil || !settings.Credential.SameIdentity(cred) {
+		return &updated, nil
+	}
+	settings.Credential = &updated
+	return &updated, db.StoreSettings(settings)
+}
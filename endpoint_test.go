@@ -0,0 +1,128 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEndpointHost(t *testing.T) {
+	tests := []struct {
+		in   string
+		host string
+		err  error
+	}{
+		{"", "", nil},
+		{"evernote", EvernoteHost, nil},
+		{"Sandbox", SandboxHost, nil},
+		{"yinxiang", YinxiangHost, nil},
+		{"china", YinxiangHost, nil},
+		{"proxy.example.com", "proxy.example.com", nil},
+		{"https://proxy.example.com:8443/", "proxy.example.com:8443", nil},
+		{"https://proxy.example.com/edam", "", ErrInvalidEndpoint},
+		{"https://", "", ErrInvalidEndpoint},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			host, err := ParseEndpointHost(test.in)
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.host, host)
+		})
+	}
+}
+
+func TestCredentialEndpoint(t *testing.T) {
+	assert := assert.New(t)
+	cred := &Credential{CredType: EvernoteCredential}
+	assert.Equal(EvernoteHost, cred.APIHost())
+	assert.Equal("Evernote", cred.ServiceName())
+
+	cred.CredType = EvernoteSandboxCredential
+	assert.Equal(SandboxHost, cred.APIHost())
+	assert.Equal("Evernote Sandbox", cred.ServiceName())
+
+	cred = &Credential{Endpoint: Endpoint{Host: YinxiangHost}}
+	assert.Equal(YinxiangHost, cred.APIHost())
+	assert.Equal("Yinxiang", cred.ServiceName())
+
+	cred.Host = "proxy.example.com"
+	assert.Equal("proxy.example.com", cred.ServiceName())
+
+	cred = &Credential{CredType: JoplinCredential}
+	assert.Equal(JoplinHost, cred.APIHost())
+	assert.Equal("Joplin", cred.ServiceName())
+	cred.Host = "localhost:8080"
+	assert.Equal("localhost:8080", cred.APIHost())
+	assert.Equal("Joplin", cred.ServiceName())
+
+	cred = &Credential{Secret: "/home/joe/notes", CredType: FolderCredential}
+	assert.Equal("Folder", cred.ServiceName())
+}
+
+func TestSetCredentialEndpoint(t *testing.T) {
+	active := &Credential{Name: "active", Secret: "secret"}
+	other := &Credential{Name: "other", Secret: "other secret"}
+	ep := Endpoint{Host: SandboxHost, ConsumerKey: "key", ConsumerSecret: "secret"}
+	setup := func() (*mockCredentialStore, *mockStore, map[int]*Credential) {
+		updated := make(map[int]*Credential)
+		creds := &mockCredentialStore{
+			getAll: func() ([]*Credential, error) { return []*Credential{active, other}, nil },
+			update: func(i int, c *Credential) error { updated[i] = c; return nil },
+		}
+		db := &mockStore{
+			getSettings: func() (*Settings, error) { return &Settings{Credential: active}, nil },
+		}
+		return creds, db, updated
+	}
+
+	t.Run("active credential", func(t *testing.T) {
+		assert := assert.New(t)
+		creds, db, updated := setup()
+		var saved *Settings
+		db.storeSettings = func(s *Settings) error { saved = s; return nil }
+		cred, err := SetCredentialEndpoint(creds, db, 0, ep)
+		assert.NoError(err)
+		assert.Equal(ep, cred.Endpoint)
+		assert.Equal(EvernoteSandboxCredential, cred.CredType)
+		assert.Equal(cred, updated[0])
+		if assert.NotNil(saved) {
+			assert.Equal(cred, saved.Credential)
+		}
+	})
+
+	t.Run("other credential", func(t *testing.T) {
+		assert := assert.New(t)
+		creds, db, updated := setup()
+		db.storeSettings = func(s *Settings) error {
+			t.Error("Settings should not be saved")
+			return nil
+		}
+		cred, err := SetCredentialEndpoint(creds, db, 1, ep)
+		assert.NoError(err)
+		assert.Equal("other", cred.Name)
+		assert.Equal(cred, updated[1])
+	})
+
+	t.Run("index out of range", func(t *testing.T) {
+		creds, db, _ := setup()
+		_, err := SetCredentialEndpoint(creds, db, 2, ep)
+		assert.Equal(t, ErrIndexToBig, err)
+	})
+}
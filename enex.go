@@ -0,0 +1,312 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	enexHeader     = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export4.dtd">` + "\n"
+	enexTimeFormat = "20060102T150405Z"
+	// enexLineLength is the length of the lines of base64 encoded data.
+	enexLineLength = 76
+)
+
+type enexNote struct {
+	XMLName    xml.Name            `xml:"note"`
+	Title      string              `xml:"title"`
+	Content    enexContent         `xml:"content"`
+	Created    string              `xml:"created,omitempty"`
+	Updated    string              `xml:"updated,omitempty"`
+	Tags       []string            `xml:"tag"`
+	Attributes *enexNoteAttributes `xml:"note-attributes,omitempty"`
+	Resources  []*enexResource     `xml:"resource"`
+}
+
+type enexContent struct {
+	Text string `xml:",cdata"`
+}
+
+// enexNoteAttributes are the note attributes in the order of the DTD.
+type enexNoteAttributes struct {
+	Latitude         *float64 `xml:"latitude,omitempty"`
+	Longitude        *float64 `xml:"longitude,omitempty"`
+	Altitude         *float64 `xml:"altitude,omitempty"`
+	Author           string   `xml:"author,omitempty"`
+	Source           string   `xml:"source,omitempty"`
+	SourceURL        string   `xml:"source-url,omitempty"`
+	ReminderOrder    int64    `xml:"reminder-order,omitempty"`
+	ReminderTime     string   `xml:"reminder-time,omitempty"`
+	ReminderDoneTime string   `xml:"reminder-done-time,omitempty"`
+}
+
+type enexResource struct {
+	Data       enexData                `xml:"data"`
+	Mime       string                  `xml:"mime"`
+	Attributes *enexResourceAttributes `xml:"resource-attributes,omitempty"`
+}
+
+type enexData struct {
+	Encoding string `xml:"encoding,attr"`
+	Text     string `xml:",chardata"`
+}
+
+type enexResourceAttributes struct {
+	FileName string `xml:"file-name,omitempty"`
+}
+
+// ENEXWriter writes notes in Evernote's export format, which can be
+// imported by Evernote and other note apps.
+type ENEXWriter struct {
+	w       io.Writer
+	enc     *xml.Encoder
+	started bool
+	// Application is the name of the exporting application.
+	Application string
+	// Version is the version of the exporting application.
+	Version string
+	// Now returns the export time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewENEXWriter returns a writer that writes the ENEX file to w.
+func NewENEXWriter(w io.Writer, version string) *ENEXWriter {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &ENEXWriter{w: w, enc: enc, Application: "CLInote", Version: version, Now: time.Now}
+}
+
+func (e *ENEXWriter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if _, err := io.WriteString(e.w, enexHeader); err != nil {
+		return err
+	}
+	root := xml.StartElement{Name: xml.Name{Local: "en-export"}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "export-date"}, Value: enexTime(e.Now().UnixNano() / int64(time.Millisecond))},
+		{Name: xml.Name{Local: "application"}, Value: e.Application},
+		{Name: xml.Name{Local: "version"}, Value: e.Version},
+	}}
+	if err := e.enc.EncodeToken(root); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
+// WriteNote writes the note with its ENML content and resources. The
+// resources must include the data.
+func (e *ENEXWriter) WriteNote(n *Note, content string, resources []*Resource) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	note := &enexNote{
+		Title:   n.Title,
+		Content: enexContent{Text: content},
+		Created: enexTime(n.Created),
+		Updated: enexTime(n.Updated),
+		Tags:    n.Tags,
+	}
+	attr := &enexNoteAttributes{Author: n.Author, Source: n.Source, SourceURL: n.SourceURL}
+	if l := n.Location; l != nil {
+		attr.Latitude, attr.Longitude = &l.Latitude, &l.Longitude
+		if l.Altitude != 0 {
+			attr.Altitude = &l.Altitude
+		}
+	}
+	if r := n.Reminder; r != nil {
+		attr.ReminderOrder = r.Order
+		attr.ReminderTime = enexTime(r.Time)
+		attr.ReminderDoneTime = enexTime(r.Done)
+	}
+	if *attr != (enexNoteAttributes{}) {
+		note.Attributes = attr
+	}
+	for _, r := range resources {
+		res := &enexResource{
+			Data: enexData{Encoding: "base64", Text: wrapBase64(r.Data)},
+			Mime: r.Mime,
+		}
+		if r.Filename != "" {
+			res.Attributes = &enexResourceAttributes{FileName: r.Filename}
+		}
+		note.Resources = append(note.Resources, res)
+	}
+	if err := e.enc.Encode(note); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
+// Close ends the export. The writer isn't closed.
+func (e *ENEXWriter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if err := e.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "en-export"}}); err != nil {
+		return err
+	}
+	if err := e.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "\n")
+	return err
+}
+
+// enexTime formats the time, in milliseconds since the epoch. Zero returns
+// an empty string.
+func enexTime(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC().Format(enexTimeFormat)
+}
+
+func wrapBase64(data []byte) string {
+	s := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(s) > enexLineLength {
+		b.WriteString(s[:enexLineLength])
+		b.WriteString("\n")
+		s = s[enexLineLength:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// ExportNotebookENEX writes all the notes in the notebook to w as an ENEX
+// file. Progress is called after each note with the number of exported
+// notes and the total. The number of exported notes is returned.
+func ExportNotebookENEX(db Storager, ns NotestoreClient, name string, w io.Writer, version string, progress func(done, total int)) (int, error) {
+	b, err := findNotebook(db, ns, name)
+	if err != nil {
+		return 0, err
+	}
+	notes, err := FindAllNotes(ns, &NoteFilter{NotebookGUID: b.GUID}, 100)
+	if err != nil {
+		return 0, err
+	}
+	tags, err := ns.GetAllTags()
+	if err != nil {
+		return 0, err
+	}
+	enex := NewENEXWriter(w, version)
+	for i, n := range notes {
+		content, err := ns.GetNoteContent(n.GUID)
+		if err != nil {
+			return i, err
+		}
+		resources, err := ns.GetNoteResources(n.GUID)
+		if err != nil {
+			return i, err
+		}
+		n.Tags = noteTagNames(n, tags)
+		if err = enex.WriteNote(n, content, resources); err != nil {
+			return i, err
+		}
+		if progress != nil {
+			progress(i+1, len(notes))
+		}
+	}
+	return len(notes), enex.Close()
+}
+
+// ENEXReader reads the notes from an ENEX file.
+type ENEXReader struct {
+	dec *xml.Decoder
+}
+
+// NewENEXReader returns a reader that reads the notes in the ENEX file.
+func NewENEXReader(r io.Reader) *ENEXReader {
+	return &ENEXReader{dec: xml.NewDecoder(r)}
+}
+
+// Next returns the next note with the ENML content in the body and its
+// resources, including the data. io.EOF is returned after the last note.
+func (e *ENEXReader) Next() (*Note, error) {
+	for {
+		tok, err := e.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+		var note enexNote
+		if err = e.dec.DecodeElement(&note, &start); err != nil {
+			return nil, err
+		}
+		return noteFromENEX(&note)
+	}
+}
+
+func noteFromENEX(e *enexNote) (*Note, error) {
+	n := &Note{
+		Title:   e.Title,
+		Body:    e.Content.Text,
+		Created: parseENEXTime(e.Created),
+		Updated: parseENEXTime(e.Updated),
+		Tags:    e.Tags,
+	}
+	if a := e.Attributes; a != nil {
+		n.Author, n.Source, n.SourceURL = a.Author, a.Source, a.SourceURL
+		if a.Latitude != nil && a.Longitude != nil {
+			n.Location = &Location{Latitude: *a.Latitude, Longitude: *a.Longitude}
+			if a.Altitude != nil {
+				n.Location.Altitude = *a.Altitude
+			}
+		}
+		if a.ReminderOrder != 0 || a.ReminderTime != "" || a.ReminderDoneTime != "" {
+			n.Reminder = &Reminder{
+				Order: a.ReminderOrder,
+				Time:  parseENEXTime(a.ReminderTime),
+				Done:  parseENEXTime(a.ReminderDoneTime),
+			}
+		}
+	}
+	for _, r := range e.Resources {
+		// The decoder skips the line breaks.
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(r.Data.Text))
+		if err != nil {
+			return nil, err
+		}
+		res := &Resource{Hash: resourceHash(data), Mime: r.Mime, Size: len(data), Data: data}
+		if r.Attributes != nil {
+			res.Filename = r.Attributes.FileName
+		}
+		n.Resources = append(n.Resources, res)
+	}
+	return n, nil
+}
+
+// parseENEXTime returns the time in milliseconds since the epoch. Zero is
+// returned if the time can't be parsed.
+func parseENEXTime(s string) int64 {
+	t, err := time.Parse(enexTimeFormat, s)
+	if err != nil {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,154 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestENEXWriter(t *testing.T) {
+	assert := assert.New(t)
+	buf := new(bytes.Buffer)
+	enex := NewENEXWriter(buf, "1.0")
+	enex.Now = func() time.Time { return time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC) }
+	content := XMLHeader + "<en-note><div>Tricky ]]> content</div></en-note>"
+	n := &Note{
+		Title:     "Plan & budget",
+		Created:   1519898400000,
+		Updated:   1519902000000,
+		Tags:      []string{"work", "q3"},
+		Location:  &Location{Latitude: 59.33, Longitude: 18.07},
+		SourceURL: "https://example.com",
+		Reminder:  &Reminder{Order: 5, Time: 1519902000000},
+	}
+	data := bytes.Repeat([]byte("x"), 100)
+
+	assert.NoError(enex.WriteNote(n, content, []*Resource{{Mime: "image/png", Filename: "chart.png", Data: data}}))
+	assert.NoError(enex.WriteNote(&Note{Title: "Empty"}, XMLHeader+"<en-note/>", nil))
+	assert.NoError(enex.Close())
+
+	out := buf.String()
+	assert.True(strings.HasPrefix(out, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export4.dtd">`+"\n"+
+		`<en-export export-date="20180301T100000Z" application="CLInote" version="1.0">`))
+	assert.Contains(out, "<title>Plan &amp; budget</title>")
+	assert.Contains(out, "<created>20180301T100000Z</created>\n    <updated>20180301T110000Z</updated>\n    <tag>work</tag>\n    <tag>q3</tag>\n    <note-attributes>")
+	assert.Contains(out, "<latitude>59.33</latitude>\n      <longitude>18.07</longitude>\n      <source-url>https://example.com</source-url>\n      <reminder-order>5</reminder-order>\n      <reminder-time>20180301T110000Z</reminder-time>\n    </note-attributes>")
+	assert.NotContains(out, "<altitude>")
+	assert.Contains(out, "<resource-attributes>\n        <file-name>chart.png</file-name>")
+	assert.True(strings.HasSuffix(out, "</en-export>\n"))
+
+	var parsed struct {
+		Notes []struct {
+			Title     string `xml:"title"`
+			Content   string `xml:"content"`
+			Resources []struct {
+				Data string `xml:"data"`
+				Mime string `xml:"mime"`
+			} `xml:"resource"`
+		} `xml:"note"`
+	}
+	assert.NoError(xml.Unmarshal(buf.Bytes(), &parsed))
+	if assert.Len(parsed.Notes, 2) {
+		assert.Equal(content, parsed.Notes[0].Content, "The content should survive the CDATA section")
+		if assert.Len(parsed.Notes[0].Resources, 1) {
+			lines := strings.Split(parsed.Notes[0].Resources[0].Data, "\n")
+			assert.Len(lines, 2)
+			assert.Len(lines[0], enexLineLength)
+		}
+		assert.Empty(parsed.Notes[1].Resources)
+	}
+}
+
+func TestExportNotebookENEX(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockStore{getNotebookCache: func() (*NotebookCacheList, error) {
+		return &NotebookCacheList{Notebooks: []*Notebook{{Name: "Work", GUID: "book"}}, Timestamp: time.Now(), Limit: time.Hour}, nil
+	}}
+	var filter *NoteFilter
+	ns := &mockNS{
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			filter = f
+			return []*Note{{GUID: "n1", Title: "Plan", TagGUIDs: []string{"t1"}}}, nil
+		},
+		getAllTags:     func() ([]*Tag, error) { return []*Tag{{GUID: "t1", Name: "work"}}, nil },
+		getNoteContent: func(string) (string, error) { return XMLHeader + "<en-note/>", nil },
+		getResources:   func(string) ([]*Resource, error) { return nil, nil },
+	}
+	buf := new(bytes.Buffer)
+	var progress []int
+
+	count, err := ExportNotebookENEX(db, ns, "Work", buf, "1.0", func(done, total int) { progress = append(progress, done, total) })
+
+	assert.NoError(err)
+	assert.Equal(1, count)
+	assert.Equal("book", filter.NotebookGUID)
+	assert.Equal([]int{1, 1}, progress)
+	assert.Contains(buf.String(), "<tag>work</tag>")
+
+	_, err = ExportNotebookENEX(db, ns, "Missing", buf, "1.0", nil)
+	assert.Equal(ErrNoNotebookFound, err)
+}
+
+func TestENEXReader(t *testing.T) {
+	assert := assert.New(t)
+	buf := new(bytes.Buffer)
+	enex := NewENEXWriter(buf, "1.0")
+	content := XMLHeader + "<en-note><div>Tricky ]]> content</div></en-note>"
+	n := &Note{
+		Title:     "Plan & budget",
+		Created:   1519898400000,
+		Updated:   1519902000000,
+		Tags:      []string{"work", "q3"},
+		Location:  &Location{Latitude: 59.33, Longitude: 18.07, Altitude: 12},
+		SourceURL: "https://example.com",
+		Author:    "Jane",
+		Reminder:  &Reminder{Order: 5, Time: 1519902000000},
+	}
+	data := bytes.Repeat([]byte("x"), 100)
+	assert.NoError(enex.WriteNote(n, content, []*Resource{{Mime: "image/png", Filename: "chart.png", Data: data}}))
+	assert.NoError(enex.WriteNote(&Note{Title: "Empty"}, XMLHeader+"<en-note/>", nil))
+	assert.NoError(enex.Close())
+
+	r := NewENEXReader(buf)
+	read, err := r.Next()
+	assert.NoError(err)
+	assert.Equal("Plan & budget", read.Title)
+	assert.Equal(content, read.Body)
+	assert.Equal(n.Created, read.Created)
+	assert.Equal(n.Updated, read.Updated)
+	assert.Equal(n.Tags, read.Tags)
+	assert.Equal(n.Location, read.Location)
+	assert.Equal(n.Reminder, read.Reminder)
+	assert.Equal("https://example.com", read.SourceURL)
+	assert.Equal("Jane", read.Author)
+	assert.Equal([]*Resource{{Hash: resourceHash(data), Mime: "image/png", Filename: "chart.png", Size: 100, Data: data}}, read.Resources)
+
+	read, err = r.Next()
+	assert.NoError(err)
+	assert.Equal(&Note{Title: "Empty", Body: XMLHeader + "<en-note/>"}, read)
+
+	_, err = r.Next()
+	assert.Equal(io.EOF, err)
+}
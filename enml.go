@@ -0,0 +1,221 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// enmlElements are the elements allowed in ENML. Other elements are
+// rejected by the server.
+var enmlElements = toSet(
+	"a", "abbr", "acronym", "address", "area", "b", "bdo", "big", "blockquote",
+	"br", "caption", "center", "cite", "code", "col", "colgroup", "dd", "del",
+	"dfn", "div", "dl", "dt", "em", "font", "h1", "h2", "h3", "h4", "h5", "h6",
+	"hr", "i", "img", "ins", "kbd", "li", "map", "ol", "p", "pre", "q", "s",
+	"samp", "small", "span", "strike", "strong", "sub", "sup", "table", "tbody",
+	"td", "tfoot", "th", "thead", "title", "tr", "tt", "u", "ul", "var", "xmp",
+	"en-note", "en-media", "en-crypt", "en-todo",
+)
+
+// enmlProhibitedAttrs are the attributes ENML doesn't allow on any
+// element. Event handlers, the attributes starting with "on", aren't
+// allowed either.
+var enmlProhibitedAttrs = toSet("id", "class", "accesskey", "data", "dynsrc", "tabindex")
+
+func toSet(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// ENMLError is a problem found in ENML content. The line and column are
+// where the element with the problem starts, counted from 1.
+type ENMLError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ENMLError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// ENMLErrors are the problems found by ValidateENML.
+type ENMLErrors []*ENMLError
+
+func (e ENMLErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid ENML content:\n  " + strings.Join(msgs, "\n  ")
+}
+
+// ValidateENML checks the content against the constraints of Evernote's
+// ENML DTD: the content must be well-formed XML with a single en-note root
+// element, only the elements allowed in ENML, and none of the prohibited
+// attributes. The en-media elements must have a type and a MD5 hash. If
+// the resources aren't nil, the hash must reference one of them. All the
+// problems found are returned as ENMLErrors.
+func ValidateENML(content string, resources []*Resource) error {
+	var hashes map[string]bool
+	if resources != nil {
+		hashes = make(map[string]bool, len(resources))
+		for _, r := range resources {
+			hashes[strings.ToLower(r.Hash)] = true
+		}
+	}
+	var errs ENMLErrors
+	pos := newTextPosition(content)
+	d := xml.NewDecoder(strings.NewReader(content))
+	d.Entity = xml.HTMLEntity
+	depth, roots := 0, 0
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, col := pos.at(d.InputOffset())
+			msg := err.Error()
+			if se, ok := err.(*xml.SyntaxError); ok {
+				msg = se.Msg
+			}
+			return append(errs, &ENMLError{Line: line, Column: col, Msg: msg})
+		}
+		report := func(format string, args ...interface{}) {
+			line, col := pos.at(offset)
+			errs = append(errs, &ENMLError{Line: line, Column: col, Msg: fmt.Sprintf(format, args...)})
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			validateENMLElement(t, depth, &roots, hashes, report)
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && strings.TrimSpace(string(t)) != "" {
+				report("text outside the en-note element")
+			}
+		}
+	}
+	if roots == 0 {
+		errs = append(errs, &ENMLError{Line: 1, Column: 1, Msg: "the content has no en-note element"})
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateENMLElement reports the problems with the element at the depth.
+// The number of root elements seen is counted in roots.
+func validateENMLElement(t xml.StartElement, depth int, roots *int, hashes map[string]bool, report func(string, ...interface{})) {
+	name := t.Name.Local
+	if depth == 1 {
+		*roots++
+		if name != "en-note" {
+			report("the root element is <%s>, it must be <en-note>", name)
+		} else if *roots > 1 {
+			report("more than one <en-note> element")
+		}
+	} else if name == "en-note" {
+		report("<en-note> inside another element")
+	}
+	if !enmlElements[strings.ToLower(name)] {
+		report("<%s> isn't allowed in ENML", name)
+	}
+	for _, a := range t.Attr {
+		attr := strings.ToLower(a.Name.Local)
+		if enmlProhibitedAttrs[attr] || strings.HasPrefix(attr, "on") {
+			report("the %s attribute isn't allowed on <%s>", a.Name.Local, name)
+		}
+	}
+	if name != "en-media" {
+		return
+	}
+	var mime, hash string
+	for _, a := range t.Attr {
+		switch a.Name.Local {
+		case "type":
+			mime = a.Value
+		case "hash":
+			hash = strings.ToLower(a.Value)
+		}
+	}
+	if mime == "" {
+		report("<en-media> has no type attribute")
+	}
+	switch {
+	case hash == "":
+		report("<en-media> has no hash attribute")
+	case !isMD5Hash(hash):
+		report("<en-media> hash %q isn't a MD5 hash", hash)
+	case hashes != nil && !hashes[hash]:
+		report("<en-media> hash %s doesn't match any of the note's attachments", hash)
+	}
+}
+
+func isMD5Hash(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// textPosition converts byte offsets in a text to lines and columns.
+type textPosition struct {
+	// lines are the offsets where the lines start.
+	lines []int64
+	text  string
+}
+
+func newTextPosition(text string) *textPosition {
+	p := &textPosition{lines: []int64{0}, text: text}
+	for i, r := range text {
+		if r == '\n' {
+			p.lines = append(p.lines, int64(i+1))
+		}
+	}
+	return p
+}
+
+// at returns the line and column, counted in characters, of the offset.
+func (p *textPosition) at(offset int64) (int, int) {
+	line := 0
+	for line+1 < len(p.lines) && p.lines[line+1] <= offset {
+		line++
+	}
+	start := p.lines[line]
+	if offset > int64(len(p.text)) {
+		offset = int64(len(p.text))
+	}
+	return line + 1, len([]rune(p.text[start:offset])) + 1
+}
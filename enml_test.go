@@ -0,0 +1,76 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateENML(t *testing.T) {
+	hash := "0123456789abcdef0123456789abcdef"
+	resources := []*Resource{{Hash: hash}}
+	tests := []struct {
+		name    string
+		content string
+		errs    []string
+	}{
+		{"Valid", XMLHeader + `<en-note><div style="color:red">A&nbsp;b</div><en-todo checked="true"/><en-media type="image/png" hash="` + hash + `"/></en-note>`, nil},
+		{"Markdown", toXML("# Title\n\n* item\n\n| a | b |\n|---|---|\n| 1 | 2 |\n"), nil},
+		{"Markdown code block", toXML("# Title {#id}\n\n```go\nfunc main() {}\n```\n"), nil},
+		{"Not XML", "Note body", []string{"line 1, column 1: text outside the en-note element", "line 1, column 1: the content has no en-note element"}},
+		{"Unclosed", XMLHeader + "<en-note>\n<div>text</en-note>", []string{"line 2, column 20: element <div> closed by </en-note>"}},
+		{"Wrong root", "<div></div>", []string{"line 1, column 1: the root element is <div>, it must be <en-note>"}},
+		{"Prohibited element", "<en-note>\n  <script>x</script><iframe/></en-note>", []string{
+			"line 2, column 3: <script> isn't allowed in ENML",
+			"line 2, column 21: <iframe> isn't allowed in ENML",
+		}},
+		{"Prohibited attributes", `<en-note><p id="a" onClick="x()" title="ok">t</p></en-note>`, []string{
+			"line 1, column 10: the id attribute isn't allowed on <p>",
+			"line 1, column 10: the onClick attribute isn't allowed on <p>",
+		}},
+		{"Media without hash", `<en-note><en-media type="image/png"/></en-note>`, []string{"line 1, column 10: <en-media> has no hash attribute"}},
+		{"Media with bad hash", `<en-note><en-media type="image/png" hash="abcd"/></en-note>`, []string{`line 1, column 10: <en-media> hash "abcd" isn't a MD5 hash`}},
+		{"Media without resource", `<en-note><en-media hash="ffffffffffffffffffffffffffffffff"/></en-note>`, []string{
+			"line 1, column 10: <en-media> has no type attribute",
+			"line 1, column 10: <en-media> hash ffffffffffffffffffffffffffffffff doesn't match any of the note's attachments",
+		}},
+		{"Nested en-note", "<en-note><en-note/></en-note>", []string{"line 1, column 10: <en-note> inside another element"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateENML(test.content, resources)
+			if test.errs == nil {
+				assert.NoError(t, err)
+				return
+			}
+			errs, ok := err.(ENMLErrors)
+			if !assert.True(t, ok, "Wrong error type: %v", err) {
+				return
+			}
+			var msgs []string
+			for _, e := range errs {
+				msgs = append(msgs, e.Error())
+			}
+			assert.Equal(t, test.errs, msgs)
+		})
+	}
+	// The hashes aren't checked if the resources are unknown.
+	assert.NoError(t, ValidateENML(`<en-note><en-media type="image/png" hash="ffffffffffffffffffffffffffffffff"/></en-note>`, nil))
+}
@@ -40,7 +40,17 @@ type Notestore interface {
 	UpdateNote(authenticationToken string, note *types.Note) (r *types.Note, err error)
 	// FindNotes searches the server and returns notes matching the filter.
 	FindNotes(apiKey string, filter *notestore.NoteFilter, offset int32, maxNumNotes int32) (r *notestore.NoteList, err error)
+	// FindNotesMetadata searches the server and returns the metadata
+	// fields given by the result spec for the notes matching the filter.
+	FindNotesMetadata(apiKey string, filter *notestore.NoteFilter, offset int32, maxNotes int32, resultSpec *notestore.NotesMetadataResultSpec) (r *notestore.NotesMetadataList, err error)
 	// GetNoteContent returns XHTML contents of the note with the provided GUID.
 	// If the Note is found in a public notebook, the authenticationToken will be ignored (so it could be an empty string).
 	GetNoteContent(authenticationToken string, guid types.GUID) (r string, err error)
+	// GetSyncState returns the current state of the user's account.
+	GetSyncState(authenticationToken string) (r *notestore.SyncState, err error)
+	// GetNote returns the note with the provided GUID. The content and the
+	// resources are only included if requested.
+	GetNote(authenticationToken string, guid types.GUID, withContent bool, withResourcesData bool, withResourcesRecognition bool, withResourcesAlternateData bool) (r *types.Note, err error)
+	// ListTags returns all the user's tags.
+	ListTags(authenticationToken string) (r []*types.Tag, err error)
 }
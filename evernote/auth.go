@@ -18,12 +18,18 @@
 package evernote
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/TcM1911/clinote"
@@ -35,7 +41,19 @@ type callbackValues struct {
 	SandboxLnb bool
 }
 
-// Logout removes the session stored.
+var (
+	// loginTimeout is how long Login waits for the user to authorize CLInote.
+	loginTimeout = 10 * time.Minute
+	// loginInput is read for the redirect URL when the browser can't be opened.
+	loginInput io.Reader = os.Stdin
+	// openBrowser opens the URL in the user's browser.
+	openBrowser = OpenURLInBrowser
+	// errNoBrowser is returned if there is no browser to open the URL in.
+	errNoBrowser = errors.New("no browser available")
+)
+
+// Logout removes the session stored. The credential is kept but is no
+// longer active.
 func Logout(cfg clinote.Configuration) error {
 	s, err := cfg.Store().GetSettings()
 	if err != nil {
@@ -44,11 +62,18 @@ func Logout(cfg clinote.Configuration) error {
 	if s.APIKey == "" {
 		return ErrNotLoggedIn
 	}
+	if err = clinote.DeactivateCredentials(cfg.UserStore()); err != nil {
+		return err
+	}
 	s.APIKey = ""
+	s.Credential = nil
 	return cfg.Store().StoreSettings(s)
 }
 
-// Login logs the user in to the server.
+// Login logs the user in to the server. A temporary callback server on
+// localhost receives the verifier when the user has given CLInote access
+// in the browser. If the browser can't be opened, the user can paste the
+// URL the browser was redirected to, or the verifier, instead.
 func Login(client APIClient) error {
 	s, err := client.GetConfig().Store().GetSettings()
 	if err != nil {
@@ -57,24 +82,36 @@ func Login(client APIClient) error {
 	if s.APIKey != "" {
 		return ErrAlreadyLoggedIn
 	}
-	c := make(chan *callbackValues)
+	// Buffered so neither the callback server nor the manual entry blocks
+	// if the other one completes the login first.
+	c := make(chan *callbackValues, 2)
 	path := fmt.Sprintf("/%d/", time.Now().Unix())
-	http.HandleFunc(path, oathCallbackHandler(c))
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, oathCallbackHandler(c))
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
-	defer listener.Close()
-	fmt.Println("Starting callback listener on", listener.Addr().String())
 	if err != nil {
 		return err
 	}
-	go http.Serve(listener, nil)
+	server := &http.Server{Handler: mux}
+	defer server.Close()
+	go server.Serve(listener)
 	callbackURL := fmt.Sprintf("http://%s%s", listener.Addr().String(), path)
 	tmpToken, loginURL, err := client.GetRequestToken(callbackURL)
 	if err != nil {
 		return err
 	}
-	go tryOpenLoginInBrowser(loginURL)
+	if err := openBrowser(loginURL); err != nil {
+		fmt.Printf("Open %s in your browser to give CLInote access to Evernote.\n", loginURL)
+		fmt.Println("If the page doesn't load after giving access, paste its address here:")
+		go readManualCallback(loginInput, tmpToken.Token, c)
+	}
 	fmt.Println("Waiting for access...")
-	callback := <-c
+	var callback *callbackValues
+	select {
+	case callback = <-c:
+	case <-time.After(loginTimeout):
+		return ErrLoginTimeout
+	}
 	if callback.TempToken != tmpToken.Token {
 		return ErrTempTokenMismatch
 	}
@@ -85,39 +122,125 @@ func Login(client APIClient) error {
 	if err != nil {
 		return err
 	}
-	s.APIKey = token
-	if err := clinote.AddNewCredential(client.GetConfig().UserStore(), "OAuth", token, clinote.EvernoteCredential); err != nil {
+	return saveLogin(client.GetConfig(), "OAuth", token, client.GetEndpoint())
+}
+
+// LoginWithToken logs the user in with a developer token instead of using
+// OAuth. The token is saved as a new credential and made active. Use it
+// where a browser isn't available, for example on servers and in CI.
+func LoginWithToken(cfg clinote.Configuration, token string, ep clinote.Endpoint) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrEmptyToken
+	}
+	s, err := cfg.Store().GetSettings()
+	if err != nil {
 		return err
 	}
-	s.Credential = &clinote.Credential{Name: "OAuth", Secret: token, CredType: clinote.EvernoteCredential}
-	return client.GetConfig().Store().StoreSettings(s)
+	if s.APIKey != "" {
+		return ErrAlreadyLoggedIn
+	}
+	return saveLogin(cfg, "Developer token", token, ep)
 }
 
-func tryOpenLoginInBrowser(url string) {
-	browser := os.Getenv("BROWSER")
-	if browser == "" {
-		fmt.Printf("Open %s in your browser to give CLInote access to Evernote.\n", url)
-		return
-	}
-	cmd := exec.Command(browser, url)
-	fmt.Printf("Opening %s in %s\n", url, browser)
-	cmd.Run()
+// saveLogin saves the token as a new credential and makes it active.
+func saveLogin(cfg clinote.Configuration, name, token string, ep clinote.Endpoint) error {
+	cred := &clinote.Credential{Name: name, Secret: token, CredType: ep.CredentialType(), Endpoint: ep}
+	if expires := clinote.ParseToken(token).Expires; !expires.IsZero() {
+		cred.Expires = expires.UnixNano() / int64(time.Millisecond)
+	}
+	if err := cfg.UserStore().Add(cred); err != nil {
+		return err
+	}
+	creds, err := clinote.GetAllCredentials(cfg.UserStore())
+	if err != nil {
+		return err
+	}
+	_, err = clinote.ActivateCredential(cfg.UserStore(), cfg.Store(), len(creds)-1)
+	return err
 }
 
-func oathCallbackHandler(returnChan chan *callbackValues) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vals := new(callbackValues)
-		requestVals := r.URL.Query()
-		vals.TempToken = requestVals.Get("oauth_token")
-		vals.Verifier = requestVals.Get("oauth_verifier")
-		sandboxBool := requestVals.Get("sandbox_lnb")
-		if sandboxBool != "" {
-			sandbox, err := strconv.ParseBool(sandboxBool)
-			if err != nil {
-				fmt.Println("Error when parsing OAuth callback request:", err)
+// OpenURLInBrowser opens the URL in the browser given by $BROWSER or the
+// system's default browser. An error is returned if no browser could be
+// started, for example over SSH.
+func OpenURLInBrowser(u string) error {
+	var cmd *exec.Cmd
+	if browser := os.Getenv("BROWSER"); browser != "" {
+		cmd = exec.Command(browser, u)
+	} else {
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", u)
+		case "windows":
+			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+		default:
+			if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+				return errNoBrowser
 			}
-			vals.SandboxLnb = sandbox
+			cmd = exec.Command("xdg-open", u)
 		}
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	fmt.Printf("Opening %s in your browser.\n", u)
+	go cmd.Wait()
+	return nil
+}
+
+// readManualCallback reads lines from r until one can be parsed as the
+// redirect URL or the verifier and sends it on the channel.
+func readManualCallback(r io.Reader, tempToken string, c chan<- *callbackValues) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		vals, err := parseManualCallback(scanner.Text(), tempToken)
+		if err != nil {
+			fmt.Println("Error when parsing the address:", err)
+			continue
+		}
+		if vals != nil {
+			c <- vals
+			return
+		}
+	}
+}
+
+// parseManualCallback parses the redirect URL pasted by the user. A value
+// without a query is taken as the verifier for the temporary token. Nil is
+// returned for an empty line.
+func parseManualCallback(line, tempToken string) (*callbackValues, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, nil
+	}
+	if !strings.Contains(line, "oauth_verifier=") {
+		return &callbackValues{TempToken: tempToken, Verifier: line}, nil
+	}
+	u, err := url.Parse(line)
+	if err != nil {
+		return nil, err
+	}
+	return parseCallbackValues(u.Query()), nil
+}
+
+func parseCallbackValues(requestVals url.Values) *callbackValues {
+	vals := new(callbackValues)
+	vals.TempToken = requestVals.Get("oauth_token")
+	vals.Verifier = requestVals.Get("oauth_verifier")
+	sandboxBool := requestVals.Get("sandbox_lnb")
+	if sandboxBool != "" {
+		sandbox, err := strconv.ParseBool(sandboxBool)
+		if err != nil {
+			fmt.Println("Error when parsing OAuth callback request:", err)
+		}
+		vals.SandboxLnb = sandbox
+	}
+	return vals
+}
+
+func oathCallbackHandler(returnChan chan *callbackValues) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vals := parseCallbackValues(r.URL.Query())
 		w.Write([]byte("You can now close this tab"))
 		returnChan <- vals
 	}
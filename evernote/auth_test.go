@@ -19,9 +19,11 @@ package evernote
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,10 +49,14 @@ func TestLogout(t *testing.T) {
 		settings := new(clinote.Settings)
 		settings.APIKey = "test session"
 		store.settings = settings
+		ustore := &mockUserStore{added: []*clinote.Credential{{Name: "OAuth", Secret: "test session", Active: true}}}
 		cfg.getStore = func() clinote.Storager { return store }
+		cfg.getUserStore = func() clinote.UserCredentialStore { return ustore }
 		err := Logout(cfg)
 		assert.Nil(err, "Should not return an error. Returned:", err)
 		assert.Equal("", settings.APIKey, "Session key should be empty")
+		assert.False(ustore.added[0].Active, "Credential should not be active")
+		assert.Nil(settings.Credential, "Credential should be cleared from the settings")
 	})
 }
 
@@ -104,6 +110,8 @@ func TestCallbackHandler(t *testing.T) {
 
 func TestLogin(t *testing.T) {
 	os.Unsetenv("BROWSER")
+	defer func(f func(string) error) { openBrowser = f }(openBrowser)
+	openBrowser = func(string) error { return nil }
 	assert := assert.New(t)
 	t.Run("should login", func(t *testing.T) {
 		settings := new(clinote.Settings)
@@ -129,6 +137,116 @@ func TestLogin(t *testing.T) {
 	})
 }
 
+func TestLoginWithToken(t *testing.T) {
+	setup := func(settings *clinote.Settings) (*cfgMock, *mockUserStore) {
+		cfg := new(cfgMock)
+		store := &mockStore{settings: settings}
+		ustore := new(mockUserStore)
+		cfg.getStore = func() clinote.Storager { return store }
+		cfg.getUserStore = func() clinote.UserCredentialStore { return ustore }
+		return cfg, ustore
+	}
+	t.Run("should login", func(t *testing.T) {
+		assert := assert.New(t)
+		settings := new(clinote.Settings)
+		cfg, ustore := setup(settings)
+		ep := clinote.Endpoint{Host: clinote.SandboxHost}
+		err := LoginWithToken(cfg, " S=s1:U=1:E=dev \n", ep)
+		assert.NoError(err)
+		assert.Equal("S=s1:U=1:E=dev", settings.APIKey, "Session key not set")
+		if assert.Len(ustore.added, 1) {
+			cred := ustore.added[0]
+			assert.Equal("S=s1:U=1:E=dev", cred.Secret)
+			assert.Equal(clinote.EvernoteSandboxCredential, cred.CredType)
+			assert.Equal(ep, cred.Endpoint)
+			assert.True(cred.Active, "Credential should be active")
+			assert.Equal(cred, settings.Credential)
+		}
+	})
+	t.Run("should store expiry", func(t *testing.T) {
+		cfg, ustore := setup(new(clinote.Settings))
+		assert.NoError(t, LoginWithToken(cfg, "S=s1:U=1:E=16200e15a28:C=1", clinote.Endpoint{}))
+		if assert.Len(t, ustore.added, 1) {
+			assert.Equal(t, int64(0x16200e15a28), ustore.added[0].Expires)
+		}
+	})
+	t.Run("error when token is empty", func(t *testing.T) {
+		cfg, _ := setup(new(clinote.Settings))
+		assert.Equal(t, ErrEmptyToken, LoginWithToken(cfg, " ", clinote.Endpoint{}))
+	})
+	t.Run("error when already logged in", func(t *testing.T) {
+		cfg, ustore := setup(&clinote.Settings{APIKey: "test token"})
+		assert.Equal(t, ErrAlreadyLoggedIn, LoginWithToken(cfg, "token", clinote.Endpoint{}))
+		assert.Empty(t, ustore.added)
+	})
+}
+
+func TestLoginManualEntry(t *testing.T) {
+	defer func(f func(string) error, r io.Reader, d time.Duration) {
+		openBrowser, loginInput, loginTimeout = f, r, d
+	}(openBrowser, loginInput, loginTimeout)
+	openBrowser = func(string) error { return errNoBrowser }
+	tmpToken := &oauth.RequestToken{Token: "testToken"}
+
+	setup := func(input string) (*mockClient, *clinote.Settings) {
+		loginInput = strings.NewReader(input)
+		settings := new(clinote.Settings)
+		store := &mockStore{settings: settings}
+		cfg := new(cfgMock)
+		ustore := new(mockUserStore)
+		cfg.getStore = func() clinote.Storager { return store }
+		cfg.getUserStore = func() clinote.UserCredentialStore { return ustore }
+		client := new(mockClient)
+		client.getConfig = func() clinote.Configuration { return cfg }
+		client.getRequestToken = func(string) (*oauth.RequestToken, string, error) {
+			return tmpToken, "http://test", nil
+		}
+		client.getAuthorizedToken = func(tmp *oauth.RequestToken, verifier string) (string, error) {
+			assert.Equal(t, "verifier", verifier)
+			return "oauth_token", nil
+		}
+		return client, settings
+	}
+
+	t.Run("pasted redirect URL", func(t *testing.T) {
+		client, settings := setup("\nhttp://127.0.0.1:1234/1/?oauth_token=testToken&oauth_verifier=verifier&sandbox_lnb=false\n")
+		assert.NoError(t, Login(client))
+		assert.Equal(t, "oauth_token", settings.APIKey)
+	})
+
+	t.Run("pasted verifier", func(t *testing.T) {
+		client, settings := setup("verifier\n")
+		assert.NoError(t, Login(client))
+		assert.Equal(t, "oauth_token", settings.APIKey)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		loginTimeout = 10 * time.Millisecond
+		client, settings := setup("")
+		assert.Equal(t, ErrLoginTimeout, Login(client))
+		assert.Empty(t, settings.APIKey)
+	})
+}
+
+func TestParseManualCallback(t *testing.T) {
+	assert := assert.New(t)
+
+	vals, err := parseManualCallback("  ", "tmp")
+	assert.NoError(err)
+	assert.Nil(vals)
+
+	vals, err = parseManualCallback("http://127.0.0.1/1/?oauth_token=other&oauth_verifier=abc&sandbox_lnb=true", "tmp")
+	assert.NoError(err)
+	assert.Equal(&callbackValues{TempToken: "other", Verifier: "abc", SandboxLnb: true}, vals)
+
+	vals, err = parseManualCallback(" abc\r", "tmp")
+	assert.NoError(err)
+	assert.Equal(&callbackValues{TempToken: "tmp", Verifier: "abc"}, vals)
+
+	_, err = parseManualCallback("http://%zz/?oauth_verifier=abc", "tmp")
+	assert.Error(err)
+}
+
 func loginHelperFunction(t *testing.T, settings *clinote.Settings, verify, tokenMismatch bool) error {
 	tmpToken := &oauth.RequestToken{Token: "testToken"}
 	client := new(mockClient)
@@ -161,8 +279,8 @@ func loginHelperFunction(t *testing.T, settings *clinote.Settings, verify, token
 		fmt.Println("Sending request to", url)
 		r, err := http.Get(url)
 		if err != nil {
-			fmt.Println(err)
-			t.FailNow()
+			t.Error(err)
+			return
 		}
 		r.Body.Close()
 	}()
@@ -176,6 +294,7 @@ type mockClient struct {
 	getConfig          func() clinote.Configuration
 	apiToken           string
 	getNotestore       func() (clinote.NotestoreClient, error)
+	endpoint           clinote.Endpoint
 }
 
 func (c *mockClient) GetNoteStore() (clinote.NotestoreClient, error) {
@@ -194,13 +313,20 @@ func (c *mockClient) GetConfig() clinote.Configuration {
 	return c.getConfig()
 }
 
+func (c *mockClient) GetEndpoint() clinote.Endpoint {
+	return c.endpoint
+}
+
 func (c *mockClient) GetAPIToken() string {
 	return c.apiToken
 }
 
-type mockUserStore struct{}
+type mockUserStore struct {
+	added []*clinote.Credential
+}
 
-func (m *mockUserStore) Add(*clinote.Credential) error {
+func (m *mockUserStore) Add(c *clinote.Credential) error {
+	m.added = append(m.added, c)
 	return nil
 }
 
@@ -209,9 +335,14 @@ func (m *mockUserStore) Remove(*clinote.Credential) error {
 }
 
 func (m *mockUserStore) GetAll() ([]*clinote.Credential, error) {
-	panic("not implemented")
+	return m.added, nil
 }
 
 func (m *mockUserStore) GetByIndex(index int) (*clinote.Credential, error) {
 	panic("not implemented")
 }
+
+func (m *mockUserStore) Update(index int, c *clinote.Credential) error {
+	m.added[index] = c
+	return nil
+}
@@ -36,6 +36,8 @@ type APIClient interface {
 	GetRequestToken(callbackURL string) (token *oauth.RequestToken, url string, err error)
 	// GetConfig returns the client's configuration.
 	GetConfig() clinote.Configuration
+	// GetEndpoint returns the server the client talks to.
+	GetEndpoint() clinote.Endpoint
 }
 
 func migrateOldSession(cfg clinote.Configuration) string {
@@ -1,8 +1,11 @@
 package evernote
 
 import (
+	"fmt"
+	"io"
+	"os"
+
 	"github.com/TcM1911/clinote"
-	ec "github.com/TcM1911/evernote-sdk-golang/client"
 	"github.com/TcM1911/evernote-sdk-golang/notestore"
 	"github.com/mrjones/oauth"
 )
@@ -16,10 +19,27 @@ type Client struct {
 	// Config holds all the configurations.
 	Config clinote.Configuration
 	// APIToken is the access token for the user's account.
-	apiToken   string
-	ns         clinote.NotestoreClient
-	evernote   *ec.EvernoteClient
+	apiToken string
+	ns       clinote.NotestoreClient
+	evernote sdkClient
+	// endpoint is the server the client talks to.
+	endpoint   clinote.Endpoint
 	evernoteNS *notestore.NoteStoreClient
+	// sharedNS is true if the notestore was provided when the client was
+	// created. It is returned by NewNoteStore instead of a new notestore.
+	sharedNS bool
+	// dryRun is where the changes are written instead of being sent to
+	// the server. Nil unless dry run mode is enabled.
+	dryRun io.Writer
+}
+
+// SetDryRun enables dry run mode. The calls that would change the user's
+// account are written to w instead of being sent to the server.
+func (c *Client) SetDryRun(w io.Writer) {
+	c.dryRun = w
+	if c.ns != nil {
+		c.ns = clinote.NewDryRunNotestore(c.ns, w)
+	}
 }
 
 // Close shuts down the client.
@@ -37,7 +57,15 @@ func (c *Client) GetConfig() clinote.Configuration {
 	return c.Config
 }
 
-// GetNoteStore returns a notestore client for the user.
+// GetEndpoint returns the server the client talks to.
+func (c *Client) GetEndpoint() clinote.Endpoint {
+	return c.endpoint
+}
+
+// GetNoteStore returns a notestore client for the user. If the server can't
+// be reached, a notestore that fails all calls with the connection error is
+// returned so changes can be queued. Once connected, the changes queued
+// while offline are pushed to the server.
 func (c *Client) GetNoteStore() (clinote.NotestoreClient, error) {
 	if c.ns != nil {
 		return c.ns, nil
@@ -46,13 +74,92 @@ func (c *Client) GetNoteStore() (clinote.NotestoreClient, error) {
 		return nil, ErrNotLoggedIn
 	}
 	ns, err := c.evernote.GetNoteStore(c.apiToken)
+	if clinote.IsOffline(err) {
+		clinote.LogVerbose("server unreachable", "error", err)
+		return c.withDryRun(&offlineNotestore{err: err}), nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	clinote.LogVerbose("connected to the notestore")
 	c.evernoteNS = ns
-	store := &Notestore{apiToken: c.apiToken, evernoteNS: ns}
-	c.ns = store
-	return store, nil
+	c.ns = c.withDryRun(c.wrapNotestore(c.newNotestore(ns)))
+	c.replayChanges()
+	return c.ns, nil
+}
+
+// replayChanges pushes the changes queued while the server couldn't be
+// reached. Changes that fail are kept in the queue for clinote sync.
+func (c *Client) replayChanges() {
+	db := c.Config.Store()
+	if db == nil {
+		return
+	}
+	n, err := clinote.ReplayChanges(db, c.ns)
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "Pushed %d change(s) queued while offline\n", n)
+	}
+	if err != nil && !clinote.IsOffline(err) {
+		fmt.Fprintln(os.Stderr, "Error when pushing the queued changes:", err)
+	}
+}
+
+// NewNoteStore returns a new notestore client for the user. The notestore
+// clients are not safe for concurrent use so each goroutine should use its
+// own client. If the client was created with a notestore, that notestore
+// is returned instead.
+func (c *Client) NewNoteStore() (clinote.NotestoreClient, error) {
+	if c.sharedNS {
+		return c.ns, nil
+	}
+	if c.apiToken == "" {
+		return nil, ErrNotLoggedIn
+	}
+	ns, err := c.evernote.GetNoteStore(c.apiToken)
+	if err != nil {
+		return nil, err
+	}
+	return c.withDryRun(c.wrapNotestore(c.newNotestore(ns))), nil
+}
+
+// newNotestore returns a notestore for the SDK client that retries rate
+// limited calls and calls that fail because of the network. The calls are
+// logged if logging is enabled and counted against the API call budget if
+// one is set.
+func (c *Client) newNotestore(ns *notestore.NoteStoreClient) clinote.NotestoreClient {
+	var s clinote.NotestoreClient = &Notestore{apiToken: c.apiToken, evernoteNS: newRetryNotestore(ns, c.retryPolicy())}
+	if clinote.LogEnabled(clinote.LogLevelVerbose) {
+		s = clinote.NewLoggingNotestore(s)
+	}
+	return clinote.WithAPIBudget(s)
+}
+
+// retryPolicy returns the retry policy from the settings, with the fields
+// set in RetryOverride replaced.
+func (c *Client) retryPolicy() clinote.RetryPolicy {
+	var settings *clinote.Settings
+	if c.Config != nil && c.Config.Store() != nil {
+		settings, _ = c.Config.Store().GetSettings()
+	}
+	return clinote.GetRetryPolicy(settings, RetryOverride)
+}
+
+// withDryRun wraps the notestore so changes aren't sent to the server if
+// dry run mode is enabled.
+func (c *Client) withDryRun(ns clinote.NotestoreClient) clinote.NotestoreClient {
+	if c.dryRun == nil {
+		return ns
+	}
+	return clinote.NewDryRunNotestore(ns, c.dryRun)
+}
+
+// wrapNotestore adds the encryption of the client encrypted notebooks to
+// the notestore if the credential store can hold the notebook keys.
+func (c *Client) wrapNotestore(ns clinote.NotestoreClient) clinote.NotestoreClient {
+	if keys, ok := c.Config.UserStore().(clinote.NotebookKeyStore); ok {
+		return clinote.NewEncryptedNotestore(ns, keys)
+	}
+	return ns
 }
 
 // GetAuthorizedToken gets the authorized token from the server.
@@ -69,12 +176,49 @@ func (c *Client) GetRequestToken(callback string) (*oauth.RequestToken, string,
 	return c.evernote.GetRequestToken(callback)
 }
 
-// NewClient creates a new Evernote client.
+// NewClient creates a new Evernote client for the active credential.
 func NewClient(cfg clinote.Configuration) *Client {
-	client := new(Client)
-	client.Config = cfg
-	env := ec.PRODUCTION
+	key, cred := loadSession(cfg)
+	var ep clinote.Endpoint
+	if cred != nil {
+		ep = cred.Endpoint
+		ep.Host = cred.APIHost()
+	}
+	return newClient(cfg, key, ep)
+}
+
+// NewClientWithEndpoint creates a new Evernote client that talks to the
+// endpoint instead of the one of the active credential.
+func NewClientWithEndpoint(cfg clinote.Configuration, ep clinote.Endpoint) *Client {
+	key, _ := loadSession(cfg)
+	return newClient(cfg, key, ep)
+}
 
+// NewClientWithCredential creates a new Evernote client for the credential
+// instead of the active one.
+func NewClientWithCredential(cfg clinote.Configuration, cred *clinote.Credential) *Client {
+	ep := cred.Endpoint
+	ep.Host = cred.APIHost()
+	return newClient(cfg, cred.Secret, ep)
+}
+
+func newClient(cfg clinote.Configuration, key string, ep clinote.Endpoint) *Client {
+	if ep.Host == "" {
+		ep.Host = clinote.EvernoteHost
+	}
+	if key == "" && replaying {
+		key = replayToken
+	}
+	return &Client{
+		Config:   cfg,
+		apiToken: key,
+		endpoint: ep,
+		evernote: newSDKClient(ep),
+	}
+}
+
+// loadSession returns the access token and the active credential.
+func loadSession(cfg clinote.Configuration) (string, *clinote.Credential) {
 	key := migrateOldSession(cfg)
 	if key != "" {
 		// Migrate an old session.
@@ -90,18 +234,29 @@ func NewClient(cfg clinote.Configuration) *Client {
 		if err := cfg.Store().StoreSettings(settings); err != nil {
 			panic(err.Error())
 		}
-	} else {
-		settings, err := cfg.Store().GetSettings()
-		if err != nil {
-			panic(err.Error())
-		}
-		key = settings.APIKey
-		if settings.Credential != nil && settings.Credential.CredType == clinote.EvernoteSandboxCredential {
-			env = ec.SANDBOX
-		}
+		return key, settings.Credential
+	}
+	settings, err := cfg.Store().GetSettings()
+	if err != nil {
+		panic(err.Error())
 	}
-	client.evernote = ec.NewClient(apiConsumer, apiSecret, env)
-	client.apiToken = key
+	active, err := clinote.ActiveCredential(cfg.UserStore())
+	if err != nil {
+		panic(err.Error())
+	}
+	if active == nil {
+		// Sessions from before credentials were marked as active.
+		return settings.APIKey, settings.Credential
+	}
+	return active.Secret, active
+}
 
+// NewClientWithNotestore creates a new Evernote client that uses the
+// provided notestore instead of connecting to the server. The notestore
+// has to be safe for concurrent use.
+func NewClientWithNotestore(cfg clinote.Configuration, ns clinote.NotestoreClient) *Client {
+	client := NewClient(cfg)
+	client.ns = client.wrapNotestore(clinote.WithAPIBudget(ns))
+	client.sharedNS = true
 	return client
 }
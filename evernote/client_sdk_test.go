@@ -63,11 +63,14 @@ func TestClient(t *testing.T) {
 	settings := &clinote.Settings{}
 	store := &mockStore{settings: settings}
 
+	ustore := new(mockUserStore)
+
 	// Setup config mock
 	cfg := &cfgMock{
 		getConfFolder:  func() string { return configDir },
 		getCacheFolder: func() string { return cacheDir },
 		getStore:       func() clinote.Storager { return store },
+		getUserStore:   func() clinote.UserCredentialStore { return ustore },
 	}
 
 	// Tests
@@ -91,6 +94,16 @@ func TestClient(t *testing.T) {
 		assert.NotNil(client)
 		assert.Equal(expectedSession, client.apiToken)
 	})
+	t.Run("active_credential", func(t *testing.T) {
+		ep := clinote.Endpoint{Host: clinote.SandboxHost}
+		ustore.added = []*clinote.Credential{
+			{Name: "First", Secret: "first"},
+			{Name: "Second", Secret: "second", Endpoint: ep, Active: true},
+		}
+		client := NewClient(cfg)
+		assert.Equal("second", client.apiToken)
+		assert.Equal(ep, client.GetEndpoint())
+	})
 }
 
 type mockStore struct {
@@ -105,6 +118,42 @@ func (m *mockStore) GetNoteRecoveryPoint() (*clinote.Note, error) {
 	panic("not implemented")
 }
 
+func (m *mockStore) SaveRedactedNote(string, []byte) error {
+	panic("not implemented")
+}
+
+func (m *mockStore) GetRedactedNote(string) ([]byte, error) {
+	panic("not implemented")
+}
+
+func (m *mockStore) GetSyncState() (*clinote.SyncState, error) {
+	panic("not implemented")
+}
+
+func (m *mockStore) SaveSyncState(*clinote.SyncState) error {
+	panic("not implemented")
+}
+
+func (m *mockStore) GetPendingChanges() ([]*clinote.PendingChange, error) {
+	panic("not implemented")
+}
+
+func (m *mockStore) SavePendingChanges([]*clinote.PendingChange) error {
+	panic("not implemented")
+}
+
+func (m *mockStore) GetCacheEntry(string, string) ([]byte, error) {
+	panic("not implemented")
+}
+
+func (m *mockStore) PutCacheEntry(string, string, []byte) error {
+	panic("not implemented")
+}
+
+func (m *mockStore) GetCacheStats() (*clinote.CacheStats, error) {
+	panic("not implemented")
+}
+
 func (m *mockStore) SaveSearch([]*clinote.Note) error {
 	panic("not implemented")
 }
@@ -133,3 +182,16 @@ func (m *mockStore) StoreSettings(s *clinote.Settings) error {
 	m.settings.APIKey = s.APIKey
 	return nil
 }
+
+func TestNewSDKClient(t *testing.T) {
+	assert := assert.New(t)
+	c := newSDKClient(clinote.Endpoint{}).(*hostClient)
+	assert.Equal(clinote.EvernoteHost, c.host, "Should default to Evernote")
+	assert.Equal(apiClient, c.consumer.HttpClient, "Should log in with the API client")
+	c = newSDKClient(clinote.Endpoint{Host: clinote.SandboxHost}).(*hostClient)
+	assert.Equal(clinote.SandboxHost, c.host)
+	c, ok := newSDKClient(clinote.Endpoint{Host: "proxy.example.com", ConsumerKey: "key", ConsumerSecret: "secret"}).(*hostClient)
+	if assert.True(ok, "Should use a host client") {
+		assert.Equal("proxy.example.com", c.host)
+	}
+}
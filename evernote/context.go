@@ -19,6 +19,11 @@ package evernote
 
 import (
 	"context"
+	"net/http"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/apache/thrift/lib/go/thrift"
 )
 
 const (
@@ -40,3 +45,94 @@ func GetUseRawContentFromContext(ctx context.Context) bool {
 	}
 	return val
 }
+
+// apiClient is the HTTP client used for the API calls. It's separate from
+// http.DefaultClient so the transports added for the API calls don't change
+// the other HTTP requests made by the program.
+var apiClient = &http.Client{Transport: baseTransport{}}
+
+// apiTransport is the transport the API calls are sent with, under the
+// transports added by the Enable functions. It's set by ConfigureNetwork.
+var apiTransport http.RoundTripper = http.DefaultTransport
+
+// apiContext is the context of the API calls made by apiClient. It's set
+// by EnableContext.
+var apiContext = context.Background()
+
+func init() {
+	// The SDK's thrift transports use thrift's default client.
+	thrift.DefaultHttpClient = apiClient
+}
+
+// wrapAPITransport replaces the transport of the API client with the one
+// returned by wrap for the current transport.
+func wrapAPITransport(wrap func(next http.RoundTripper) http.RoundTripper) {
+	next := apiClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	apiClient.Transport = wrap(next)
+}
+
+// ConfigureNetwork applies the proxy and TLS settings to the API calls.
+// Other HTTP requests, for example to a Joplin server, aren't affected.
+func ConfigureNetwork(s *clinote.Settings) error {
+	t := new(http.Transport)
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = dt.Clone()
+	}
+	if err := clinote.ConfigureTransport(t, s); err != nil {
+		return err
+	}
+	apiTransport = t
+	return nil
+}
+
+// baseTransport sends the requests with apiTransport. The transports added
+// by the Enable functions wrap it, so the network settings can be applied
+// after them.
+type baseTransport struct{}
+
+func (baseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return apiTransport.RoundTrip(req)
+}
+
+// NewContextTransport returns an HTTP transport that sends the requests
+// through next with the context. When the context is canceled or its
+// deadline has passed, the requests in flight are aborted and new requests
+// fail.
+func NewContextTransport(ctx context.Context, next http.RoundTripper) http.RoundTripper {
+	return &contextTransport{ctx: ctx, next: next}
+}
+
+// EnableContext makes the API calls honor the context. Waits between
+// retried calls end when the context is done. Other HTTP requests aren't
+// affected.
+func EnableContext(ctx context.Context) {
+	wrapAPITransport(func(next http.RoundTripper) http.RoundTripper {
+		return NewContextTransport(ctx, next)
+	})
+	apiContext = ctx
+}
+
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
+
+// sleepContext waits for the duration or until the API context is done.
+func sleepContext(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-apiContext.Done():
+	}
+}
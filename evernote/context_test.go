@@ -19,8 +19,14 @@ package evernote
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/TcM1911/clinote"
+	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -39,3 +45,66 @@ func TestContext(t *testing.T) {
 		assert.True(val, "Should return set true value")
 	})
 }
+
+func TestContextTransport(t *testing.T) {
+	assert := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		client := &http.Client{Transport: NewContextTransport(ctx, http.DefaultTransport)}
+		start := time.Now()
+		_, err := client.Get(srv.URL)
+		assert.Error(err)
+		assert.True(time.Since(start) < time.Second, "The request should be aborted at the deadline")
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client := &http.Client{Transport: NewContextTransport(ctx, http.DefaultTransport)}
+		_, err := client.Get(srv.URL)
+		assert.Error(err)
+	})
+}
+
+func TestEnableContext(t *testing.T) {
+	assert := assert.New(t)
+	defer func(tr http.RoundTripper, ctx context.Context) { apiClient.Transport, apiContext = tr, ctx }(apiClient.Transport, apiContext)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	EnableContext(ctx)
+
+	assert.Nil(http.DefaultClient.Transport, "The default client shouldn't be changed")
+	assert.Equal(apiClient, thrift.DefaultHttpClient, "The SDK should use the API client")
+	_, err := apiClient.Get("http://localhost")
+	assert.Error(err, "The API calls should use the context")
+}
+
+func TestConfigureNetwork(t *testing.T) {
+	assert := assert.New(t)
+	defer func(tr http.RoundTripper) { apiTransport = tr }(apiTransport)
+	defer func(tr http.RoundTripper) { apiClient.Transport = tr }(apiClient.Transport)
+	// Transports added before the network settings are applied are kept.
+	EnableContext(context.Background())
+
+	assert.NoError(ConfigureNetwork(&clinote.Settings{Proxy: "http://proxy.corp:3128"}))
+
+	assert.NotEqual(http.DefaultTransport, apiTransport, "The default transport shouldn't be changed")
+	tr, ok := apiTransport.(*http.Transport)
+	if assert.True(ok) {
+		u, err := tr.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "www.evernote.com"}})
+		assert.NoError(err)
+		assert.Equal("proxy.corp:3128", u.Host, "The API calls should use the proxy")
+	}
+	_, ok = apiClient.Transport.(*contextTransport)
+	assert.True(ok, "The context transport should be kept")
+}
@@ -0,0 +1,102 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"fmt"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/evernote-sdk-golang/notestore"
+	"github.com/TcM1911/evernote-sdk-golang/userstore"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/mrjones/oauth"
+)
+
+// sdkClient is the part of the SDK's client used to authenticate and to
+// find the user's notestore.
+type sdkClient interface {
+	GetUserStore() (*userstore.UserStoreClient, error)
+	GetNoteStore(authenticationToken string) (*notestore.NoteStoreClient, error)
+	GetRequestToken(callBackURL string) (*oauth.RequestToken, string, error)
+	GetAuthorizedToken(requestToken *oauth.RequestToken, oauthVerifier string) (*oauth.AccessToken, error)
+}
+
+// newSDKClient returns a client for the endpoint. Evernote is used if the
+// endpoint doesn't have a host.
+func newSDKClient(ep clinote.Endpoint) sdkClient {
+	key, secret := apiConsumer, apiSecret
+	if ep.ConsumerKey != "" {
+		key, secret = ep.ConsumerKey, ep.ConsumerSecret
+	}
+	host := ep.Host
+	if host == "" {
+		host = clinote.EvernoteHost
+	}
+	return newHostClient(key, secret, host)
+}
+
+// hostClient talks to the server of the endpoint. It's used instead of the
+// SDK's client so all the requests, including the ones to log in, are made
+// with the API client and its network settings.
+type hostClient struct {
+	host     string
+	consumer *oauth.Consumer
+}
+
+func newHostClient(key, secret, host string) *hostClient {
+	return &hostClient{
+		host: host,
+		consumer: oauth.NewCustomHttpClientConsumer(key, secret, oauth.ServiceProvider{
+			RequestTokenUrl:   fmt.Sprintf("https://%s/oauth", host),
+			AuthorizeTokenUrl: fmt.Sprintf("https://%s/OAuth.action", host),
+			AccessTokenUrl:    fmt.Sprintf("https://%s/oauth", host),
+		}, apiClient),
+	}
+}
+
+func (c *hostClient) GetRequestToken(callBackURL string) (*oauth.RequestToken, string, error) {
+	return c.consumer.GetRequestTokenAndUrl(callBackURL)
+}
+
+func (c *hostClient) GetAuthorizedToken(requestToken *oauth.RequestToken, oauthVerifier string) (*oauth.AccessToken, error) {
+	return c.consumer.AuthorizeToken(requestToken, oauthVerifier)
+}
+
+func (c *hostClient) GetUserStore() (*userstore.UserStoreClient, error) {
+	trans, err := thrift.NewTHttpPostClientWithOptions(fmt.Sprintf("https://%s/edam/user", c.host), thrift.THttpClientOptions{Client: apiClient})
+	if err != nil {
+		return nil, err
+	}
+	return userstore.NewUserStoreClientFactory(trans, thrift.NewTBinaryProtocolFactoryDefault()), nil
+}
+
+func (c *hostClient) GetNoteStore(authenticationToken string) (*notestore.NoteStoreClient, error) {
+	us, err := c.GetUserStore()
+	if err != nil {
+		return nil, err
+	}
+	notestoreURL, err := us.GetNoteStoreUrl(authenticationToken)
+	if err != nil {
+		return nil, err
+	}
+	trans, err := thrift.NewTHttpPostClientWithOptions(notestoreURL, thrift.THttpClientOptions{Client: apiClient})
+	if err != nil {
+		return nil, err
+	}
+	return notestore.NewNoteStoreClientFactory(trans, thrift.NewTBinaryProtocolFactoryDefault()), nil
+}
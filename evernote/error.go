@@ -14,6 +14,11 @@ var (
 	ErrTempTokenMismatch = errors.New("temporary token mismatch")
 	// ErrAccessRevoked is returned if the user decline access.
 	ErrAccessRevoked = errors.New("access revoked")
+	// ErrLoginTimeout is returned if the user doesn't give CLInote access
+	// in time.
+	ErrLoginTimeout = errors.New("timed out waiting for access")
+	// ErrEmptyToken is returned if an empty developer token is given.
+	ErrEmptyToken = errors.New("empty developer token")
 	// ErrNoGUIDSet is returned if the note does not have a GUID.
 	ErrNoGUIDSet = errors.New("no GUID set.")
 	// ErrNoTitleSet is returned if the not does not have a title.
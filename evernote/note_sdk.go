@@ -18,6 +18,7 @@
 package evernote
 
 import (
+	"encoding/hex"
 	"errors"
 	"sync"
 
@@ -46,9 +47,78 @@ func convert(note *types.Note) *clinote.Note {
 	n.Notebook.GUID = notebookGUID
 	n.Created = int64(note.GetCreated())
 	n.Updated = int64(note.GetUpdated())
+	n.USN = note.GetUpdateSequenceNum()
+	if attr := note.GetAttributes(); attr != nil && attr.IsSetLatitude() && attr.IsSetLongitude() {
+		n.Location = &clinote.Location{
+			Latitude:  attr.GetLatitude(),
+			Longitude: attr.GetLongitude(),
+			Altitude:  attr.GetAltitude(),
+		}
+	}
+	if attr := note.GetAttributes(); attr != nil {
+		n.SourceURL = attr.GetSourceURL()
+		n.Author = attr.GetAuthor()
+		n.Source = attr.GetSource()
+		if attr.IsSetReminderOrder() {
+			n.Reminder = &clinote.Reminder{
+				Order: attr.GetReminderOrder(),
+				Time:  int64(attr.GetReminderTime()),
+				Done:  int64(attr.GetReminderDoneTime()),
+			}
+		}
+	}
+	if note.IsSetTagNames() {
+		n.Tags = note.GetTagNames()
+	}
+	n.TagGUIDs = note.GetTagGuids()
 	return n
 }
 
+func convertResources(resources []*types.Resource) []*clinote.Resource {
+	a := make([]*clinote.Resource, len(resources))
+	for i, r := range resources {
+		res := &clinote.Resource{
+			GUID: string(r.GetGUID()),
+			Mime: r.GetMime(),
+		}
+		if data := r.GetData(); data != nil {
+			res.Hash = hex.EncodeToString(data.GetBodyHash())
+			res.Size = int(data.GetSize())
+			res.Data = data.GetBody()
+		}
+		if attr := r.GetAttributes(); attr != nil {
+			res.Filename = attr.GetFileName()
+		}
+		if reco := r.GetRecognition(); reco != nil && reco.GetBody() != nil {
+			res.Recognition, _ = clinote.ParseRecognition(reco.GetBody())
+		}
+		a[i] = res
+	}
+	return a
+}
+
+// createResources converts the resources uploaded with a note. Existing
+// resources keep their GUID.
+func createResources(resources []*clinote.Resource) []*types.Resource {
+	a := make([]*types.Resource, len(resources))
+	for i, r := range resources {
+		hash, _ := hex.DecodeString(r.Hash)
+		size := int32(len(r.Data))
+		mime := r.Mime
+		res := &types.Resource{Mime: &mime, Data: &types.Data{BodyHash: hash, Size: &size, Body: r.Data}}
+		if r.GUID != "" {
+			guid := types.GUID(r.GUID)
+			res.GUID = &guid
+		}
+		if r.Filename != "" {
+			filename := r.Filename
+			res.Attributes = &types.ResourceAttributes{FileName: &filename}
+		}
+		a[i] = res
+	}
+	return a
+}
+
 func convertNotes(notes []*types.Note) []*clinote.Note {
 	a := make([]*clinote.Note, len(notes))
 	for i, n := range notes {
@@ -30,7 +30,7 @@ var cachedNotebooks map[types.GUID]*types.Notebook
 func convertNotebooks(bs []*types.Notebook) []*clinote.Notebook {
 	a := make([]*clinote.Notebook, len(bs), len(bs))
 	for i, b := range bs {
-		a[i] = &clinote.Notebook{GUID: string(b.GetGUID()), Name: b.GetName(), Stack: b.GetStack()}
+		a[i] = &clinote.Notebook{GUID: string(b.GetGUID()), Name: b.GetName(), Stack: b.GetStack(), Default: b.GetDefaultNotebook()}
 	}
 	return a
 }
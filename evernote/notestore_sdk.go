@@ -40,7 +40,10 @@ func (s *Notestore) CreateNotebook(b *clinote.Notebook, defaultNotebook bool) er
 	nb := types.NewNotebook()
 	nb.DefaultNotebook = &defaultNotebook
 	transferNotebookData(b, nb)
-	_, err := s.evernoteNS.CreateNotebook(s.apiToken, nb)
+	created, err := s.evernoteNS.CreateNotebook(s.apiToken, nb)
+	if err == nil && created != nil && created.GUID != nil {
+		b.GUID = string(*created.GUID)
+	}
 	return err
 }
 
@@ -55,9 +58,21 @@ func (s *Notestore) GetNotebook(guid string) (*clinote.Notebook, error) {
 
 // CreateNote creates a new note and saves it to the server.
 func (s *Notestore) CreateNote(n *clinote.Note) error {
+	if n.Body != "" {
+		if err := clinote.ValidateENML(n.Body, n.Resources); err != nil {
+			return err
+		}
+	}
 	note := types.NewNote()
-	now := types.Timestamp(time.Now().Unix() * 1000)
-	note.Created = &now
+	created := types.Timestamp(time.Now().Unix() * 1000)
+	if n.Created != 0 {
+		created = types.Timestamp(n.Created)
+	}
+	note.Created = &created
+	if n.Updated != 0 {
+		updated := types.Timestamp(n.Updated)
+		note.Updated = &updated
+	}
 	note.Title = &n.Title
 	if n.Body != "" {
 		note.Content = &n.Body
@@ -66,10 +81,35 @@ func (s *Notestore) CreateNote(n *clinote.Note) error {
 		guid := string(n.Notebook.GUID)
 		note.NotebookGuid = &guid
 	}
-	_, err := s.evernoteNS.CreateNote(s.apiToken, note)
+	if hasNoteAttributes(n) {
+		note.Attributes = noteAttributes(n)
+	}
+	if len(n.Tags) != 0 {
+		note.TagNames = n.Tags
+	}
+	if len(n.Resources) != 0 {
+		note.Resources = createResources(n.Resources)
+	}
+	saved, err := s.evernoteNS.CreateNote(s.apiToken, note)
+	if err == nil && saved != nil && saved.GUID != nil {
+		n.GUID = string(*saved.GUID)
+	}
 	return err
 }
 
+// GetSyncState returns the current state of the user's account on the server.
+func (s *Notestore) GetSyncState() (*clinote.SyncState, error) {
+	state, err := s.evernoteNS.GetSyncState(s.apiToken)
+	if err != nil {
+		return nil, err
+	}
+	return &clinote.SyncState{
+		UpdateCount: state.UpdateCount,
+		Time:        time.Unix(0, int64(state.CurrentTime)*int64(time.Millisecond)),
+		Uploaded:    state.GetUploaded(),
+	}, nil
+}
+
 // DeleteNote removes a note from the user's notebook.
 func (s *Notestore) DeleteNote(guid string) error {
 	_, err := s.evernoteNS.DeleteNote(s.apiToken, types.GUID(guid))
@@ -84,6 +124,13 @@ func (s *Notestore) UpdateNote(note *clinote.Note) error {
 	if note.Title == "" {
 		return ErrNoTitleSet
 	}
+	// The server keeps the resources if they aren't sent, so the hashes are
+	// only checked against the resources if they are.
+	if note.Body != "" {
+		if err := clinote.ValidateENML(note.Body, note.Resources); err != nil {
+			return err
+		}
+	}
 	n := types.NewNote()
 	n.Title = &note.Title
 	guid := types.GUID(note.GUID)
@@ -91,11 +138,81 @@ func (s *Notestore) UpdateNote(note *clinote.Note) error {
 	if note.Body != "" {
 		n.Content = &note.Body
 	}
-	n.NotebookGuid = &note.Notebook.GUID
+	if note.Notebook != nil {
+		n.NotebookGuid = &note.Notebook.GUID
+	}
+	if !note.Deleted {
+		// Updating a note in the trash as active restores it.
+		active := true
+		n.Active = &active
+	}
+	if hasNoteAttributes(note) {
+		n.Attributes = noteAttributes(note)
+	}
+	if note.Tags != nil {
+		// Tags are set by name, the server creates any missing tags.
+		// An empty guid list ensures the old tags are removed.
+		n.TagNames = note.Tags
+		n.TagGuids = []string{}
+	}
+	if note.Resources != nil {
+		// The list replaces the note's resources. Resources without a
+		// GUID are added, existing resources missing from it are removed.
+		n.Resources = createResources(note.Resources)
+	}
 	_, err := s.evernoteNS.UpdateNote(s.apiToken, n)
 	return err
 }
 
+// hasNoteAttributes returns true if any of the note's attributes are set.
+func hasNoteAttributes(note *clinote.Note) bool {
+	return note.Location != nil || note.SourceURL != "" || note.Author != "" || note.Source != "" || note.Reminder != nil
+}
+
+// noteAttributes returns the attributes of the cached note so fields not
+// handled by clinote are not lost when the attributes are updated.
+func noteAttributes(note *clinote.Note) *types.NoteAttributes {
+	attr := types.NewNoteAttributes()
+	noteMu.Lock()
+	cached, ok := cache[types.GUID(note.GUID)]
+	noteMu.Unlock()
+	if ok && cached.IsSetAttributes() {
+		a := *cached.Attributes
+		attr = &a
+	}
+	if note.Location != nil {
+		attr.Latitude = &note.Location.Latitude
+		attr.Longitude = &note.Location.Longitude
+		if note.Location.Altitude != 0 {
+			attr.Altitude = &note.Location.Altitude
+		}
+	}
+	if note.SourceURL != "" {
+		attr.SourceURL = &note.SourceURL
+	}
+	if note.Author != "" {
+		attr.Author = &note.Author
+	}
+	if note.Source != "" {
+		attr.Source = &note.Source
+	}
+	if r := note.Reminder; r != nil {
+		attr.ReminderOrder = &r.Order
+		attr.ReminderTime = optionalTimestamp(r.Time)
+		attr.ReminderDoneTime = optionalTimestamp(r.Done)
+	}
+	return attr
+}
+
+// optionalTimestamp returns nil for a zero timestamp so the field is unset.
+func optionalTimestamp(t int64) *types.Timestamp {
+	if t == 0 {
+		return nil
+	}
+	ts := types.Timestamp(t)
+	return &ts
+}
+
 // FindNotes searches for the notes based on the filter.
 func (s *Notestore) FindNotes(filter *clinote.NoteFilter, offset, count int) ([]*clinote.Note, error) {
 	r, err := s.evernoteNS.FindNotes(s.apiToken, createFilter(filter), int32(offset), int32(count))
@@ -105,6 +222,64 @@ func (s *Notestore) FindNotes(filter *clinote.NoteFilter, offset, count int) ([]
 	return convertNotes(r.GetNotes()), nil
 }
 
+// FindNoteVersions returns the notes matching the filter with only the GUID
+// and the USN set.
+func (s *Notestore) FindNoteVersions(filter *clinote.NoteFilter, offset, count int) ([]*clinote.Note, error) {
+	includeUSN := true
+	spec := &notestore.NotesMetadataResultSpec{IncludeUpdateSequenceNum: &includeUSN}
+	r, err := s.evernoteNS.FindNotesMetadata(s.apiToken, createFilter(filter), int32(offset), int32(count), spec)
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]*clinote.Note, len(r.GetNotes()))
+	for i, m := range r.GetNotes() {
+		notes[i] = &clinote.Note{GUID: string(m.GetGUID()), USN: m.GetUpdateSequenceNum()}
+	}
+	return notes, nil
+}
+
+// GetNoteMetadata returns the note's metadata, without the content.
+func (s *Notestore) GetNoteMetadata(guid string) (*clinote.Note, error) {
+	note, err := s.evernoteNS.GetNote(s.apiToken, types.GUID(guid), false, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return convert(note), nil
+}
+
+// GetNoteResources returns the note's resources, including the data and
+// the recognized text.
+func (s *Notestore) GetNoteResources(guid string) ([]*clinote.Resource, error) {
+	note, err := s.evernoteNS.GetNote(s.apiToken, types.GUID(guid), false, true, true, false)
+	if err != nil {
+		return nil, err
+	}
+	return convertResources(note.GetResources()), nil
+}
+
+// GetNoteRecognition returns the note's resources with the recognized text
+// but without the data.
+func (s *Notestore) GetNoteRecognition(guid string) ([]*clinote.Resource, error) {
+	note, err := s.evernoteNS.GetNote(s.apiToken, types.GUID(guid), false, false, true, false)
+	if err != nil {
+		return nil, err
+	}
+	return convertResources(note.GetResources()), nil
+}
+
+// GetAllTags returns all the user's tags.
+func (s *Notestore) GetAllTags() ([]*clinote.Tag, error) {
+	tags, err := s.evernoteNS.ListTags(s.apiToken)
+	if err != nil {
+		return nil, err
+	}
+	a := make([]*clinote.Tag, len(tags))
+	for i, t := range tags {
+		a[i] = &clinote.Tag{GUID: string(t.GetGUID()), Name: t.GetName()}
+	}
+	return a, nil
+}
+
 // GetNoteContent gets the note's content from the notestore.
 func (s *Notestore) GetNoteContent(guid string) (string, error) {
 	return s.evernoteNS.GetNoteContent(s.apiToken, types.GUID(guid))
@@ -119,5 +294,9 @@ func createFilter(filter *clinote.NoteFilter) *notestore.NoteFilter {
 	if filter.Words != "" {
 		searchFilter.Words = &(filter.Words)
 	}
+	if filter.Order != 0 {
+		order := filter.Order
+		searchFilter.Order = &order
+	}
 	return searchFilter
 }
@@ -103,7 +103,11 @@ func TestCreateNoteSDK(t *testing.T) {
 	note := &clinote.Note{
 		Notebook: &clinote.Notebook{GUID: notebookGUID, Name: "Name"},
 		Title:    "Note title",
-		Body:     "Note body",
+		Body:     clinote.XMLHeader + "<en-note>Note body</en-note>",
+		Location: &clinote.Location{Latitude: 59.33, Longitude: 18.07},
+		Tags:     []string{"tag"},
+		Created:  1000,
+		Updated:  2000,
 	}
 	ns := &Notestore{
 		apiToken:   token,
@@ -114,6 +118,74 @@ func TestCreateNoteSDK(t *testing.T) {
 	assert.Equal(&note.Body, saved.Content, "Body not saved")
 	assert.Equal(&note.Title, saved.Title, "Title not saved")
 	assert.Equal(notebookGUID, *saved.NotebookGuid, "Notebook GUID doesn't match")
+	assert.Equal(59.33, saved.Attributes.GetLatitude(), "Latitude not saved")
+	assert.Equal(18.07, saved.Attributes.GetLongitude(), "Longitude not saved")
+	assert.False(saved.Attributes.IsSetAltitude(), "Altitude should not be set")
+	assert.Equal([]string{"tag"}, saved.TagNames, "Tags not saved")
+	assert.Equal(types.Timestamp(1000), saved.GetCreated(), "Created time not saved")
+	assert.Equal(types.Timestamp(2000), saved.GetUpdated(), "Updated time not saved")
+}
+
+func TestCreateNoteAttributesSDK(t *testing.T) {
+	assert := assert.New(t)
+	var saved *types.Note
+	note := &clinote.Note{
+		Title:    "Restored",
+		Author:   "Author",
+		Source:   "mobile.android",
+		Reminder: &clinote.Reminder{Order: 10, Time: 2000},
+	}
+	ns := &Notestore{
+		apiToken:   "token",
+		evernoteNS: &mockAPI{createNote: func(k string, n *types.Note) (*types.Note, error) { saved = n; return n, nil }},
+	}
+	assert.NoError(ns.CreateNote(note))
+	actual := convert(saved)
+	assert.Equal("Author", actual.Author, "Author not saved")
+	assert.Equal("mobile.android", actual.Source, "Source not saved")
+	assert.Equal(note.Reminder, actual.Reminder, "Reminder not saved")
+}
+
+func TestCreateNoteWithResourcesSDK(t *testing.T) {
+	assert := assert.New(t)
+	var saved *types.Note
+	note := &clinote.Note{
+		Title:     "Clipped",
+		Body:      clinote.XMLHeader + "<en-note>Note body</en-note>",
+		SourceURL: "https://example.com/article",
+		Resources: []*clinote.Resource{{Hash: "abcd", Mime: "image/png", Filename: "image.png", Data: []byte("png")}},
+	}
+	created := types.GUID("created GUID")
+	ns := &Notestore{
+		apiToken:   "token",
+		evernoteNS: &mockAPI{createNote: func(k string, n *types.Note) (*types.Note, error) { saved = n; return &types.Note{GUID: &created}, nil }},
+	}
+	assert.NoError(ns.CreateNote(note))
+	assert.Equal("created GUID", note.GUID, "The GUID of the created note should be set")
+	assert.Equal("https://example.com/article", saved.Attributes.GetSourceURL(), "Source URL not saved")
+	if assert.Len(saved.Resources, 1) {
+		r := saved.Resources[0]
+		assert.Equal("image/png", r.GetMime())
+		assert.Equal([]byte{0xab, 0xcd}, r.Data.BodyHash)
+		assert.Equal(int32(3), r.Data.GetSize())
+		assert.Equal([]byte("png"), r.Data.Body)
+		assert.Equal("image.png", r.Attributes.GetFileName())
+	}
+}
+
+func TestCreateNoteInvalidENMLSDK(t *testing.T) {
+	assert := assert.New(t)
+	ns := &Notestore{
+		apiToken: "token",
+		evernoteNS: &mockAPI{createNote: func(k string, n *types.Note) (*types.Note, error) {
+			t.Fatal("Invalid note sent to the server")
+			return nil, nil
+		}},
+	}
+	err := ns.CreateNote(&clinote.Note{Title: "Title", Body: clinote.XMLHeader + `<en-note><div class="x">Text</div></en-note>`})
+	if assert.IsType(clinote.ENMLErrors{}, err) {
+		assert.Len(err, 1)
+	}
 }
 
 func TestDeleteNoteSDK(t *testing.T) {
@@ -160,13 +232,14 @@ func TestUpdateNoteSDK(t *testing.T) {
 		assert.Equal(expectedGUID, string(expectedNote.GetGUID()), "Wrong GUID")
 		assert.Equal(expectedTitle, expectedNote.GetTitle(), "Wrong Title")
 		assert.Equal("", expectedNote.GetContent(), "Content should be empty")
+		assert.True(expectedNote.GetActive(), "Note should be active")
 	})
 
 	t.Run("Include body if set", func(t *testing.T) {
 		var expectedNote *types.Note
 		expectedGUID := "Expected GUID"
 		expectedTitle := "Expected Title"
-		expectedContent := "This is note content"
+		expectedContent := clinote.XMLHeader + "<en-note>This is note content</en-note>"
 		ns.evernoteNS = &mockAPI{updateNote: func(api string, n *types.Note) (*types.Note, error) { expectedNote = n; return nil, nil }}
 		err := ns.UpdateNote(&clinote.Note{
 			Title:    expectedTitle,
@@ -178,7 +251,87 @@ func TestUpdateNoteSDK(t *testing.T) {
 		assert.Equal(expectedGUID, string(expectedNote.GetGUID()), "Wrong GUID")
 		assert.Equal(expectedTitle, expectedNote.GetTitle(), "Wrong Title")
 		assert.Equal(expectedContent, expectedNote.GetContent(), "Content should be empty")
+		assert.Nil(expectedNote.Resources, "Resources should not be sent")
 	})
+
+	t.Run("Keep notebook if not set", func(t *testing.T) {
+		var expectedNote *types.Note
+		ns.evernoteNS = &mockAPI{updateNote: func(api string, n *types.Note) (*types.Note, error) { expectedNote = n; return nil, nil }}
+		err := ns.UpdateNote(&clinote.Note{Title: "Title", GUID: "GUID"})
+		assert.NoError(err, "No error should be returned")
+		assert.False(expectedNote.IsSetNotebookGuid(), "Notebook should not be sent")
+	})
+
+	t.Run("Include resources if set", func(t *testing.T) {
+		var expectedNote *types.Note
+		ns.evernoteNS = &mockAPI{updateNote: func(api string, n *types.Note) (*types.Note, error) { expectedNote = n; return nil, nil }}
+		err := ns.UpdateNote(&clinote.Note{
+			Title:    "Title",
+			GUID:     "GUID",
+			Notebook: new(clinote.Notebook),
+			Resources: []*clinote.Resource{
+				{GUID: "Resource GUID", Hash: "abcd", Mime: "image/png", Data: []byte("png")},
+				{Hash: "ef01", Mime: "application/pdf", Filename: "new.pdf", Data: []byte("pdf")},
+			},
+		})
+		assert.NoError(err, "No error should be returned")
+		if assert.Len(expectedNote.Resources, 2) {
+			assert.Equal("Resource GUID", string(expectedNote.Resources[0].GetGUID()), "Existing resource should keep its GUID")
+			assert.False(expectedNote.Resources[1].IsSetGUID(), "New resource should not have a GUID")
+			assert.Equal("new.pdf", expectedNote.Resources[1].GetAttributes().GetFileName(), "Wrong file name")
+		}
+	})
+}
+
+func TestUpdateNoteMetaSDK(t *testing.T) {
+	assert := assert.New(t)
+	var saved *types.Note
+	ns := &Notestore{
+		apiToken:   "token",
+		evernoteNS: &mockAPI{updateNote: func(k string, n *types.Note) (*types.Note, error) { saved = n; return n, nil }},
+	}
+	author := "Author"
+	guid := types.GUID("Meta GUID")
+	noteMu.Lock()
+	cache[guid] = &types.Note{GUID: &guid, Attributes: &types.NoteAttributes{Author: &author}}
+	noteMu.Unlock()
+	note := &clinote.Note{
+		GUID:      string(guid),
+		Title:     "Title",
+		Notebook:  &clinote.Notebook{GUID: "Notebook GUID"},
+		Tags:      []string{},
+		SourceURL: "https://example.com",
+	}
+
+	err := ns.UpdateNote(note)
+	assert.NoError(err, "Should not return an error")
+	assert.Nil(saved.Content, "Content should not be sent")
+	assert.Equal("https://example.com", saved.Attributes.GetSourceURL(), "Wrong source URL")
+	assert.Equal(author, saved.Attributes.GetAuthor(), "Cached attributes should be kept")
+	assert.True(saved.IsSetTagGuids(), "Tags should be cleared")
+	assert.Empty(saved.TagGuids, "Tags should be cleared")
+}
+
+func TestUpdateNoteReminderSDK(t *testing.T) {
+	assert := assert.New(t)
+	var saved *types.Note
+	ns := &Notestore{
+		apiToken:   "token",
+		evernoteNS: &mockAPI{updateNote: func(k string, n *types.Note) (*types.Note, error) { saved = n; return n, nil }},
+	}
+	note := &clinote.Note{
+		GUID:     "Reminder GUID",
+		Title:    "Title",
+		Notebook: &clinote.Notebook{GUID: "Notebook GUID"},
+		Reminder: &clinote.Reminder{Order: 1, Done: 2000},
+	}
+
+	err := ns.UpdateNote(note)
+	assert.NoError(err, "Should not return an error")
+	assert.Equal(int64(1), saved.Attributes.GetReminderOrder(), "Wrong reminder order")
+	assert.Equal(types.Timestamp(2000), saved.Attributes.GetReminderDoneTime(), "Wrong done time")
+	assert.False(saved.Attributes.IsSetReminderTime(), "Reminder time should not be set")
+	assert.Equal(note.Reminder, convert(saved).Reminder, "Reminder should convert back")
 }
 
 func TestFindNotes(t *testing.T) {
@@ -188,6 +341,8 @@ func TestFindNotes(t *testing.T) {
 	title := "Note title"
 	expectedNote.GUID = &GUID
 	expectedNote.Title = &title
+	lat, lon := 59.33, 18.07
+	expectedNote.Attributes = &types.NoteAttributes{Latitude: &lat, Longitude: &lon}
 	nl := &notestore.NoteList{Notes: []*types.Note{expectedNote}}
 	token := "token"
 	ns := &Notestore{
@@ -201,6 +356,18 @@ func TestFindNotes(t *testing.T) {
 		assert.Len(notes, 1, "Wrong number of notes returned.")
 		assert.Equal(title, notes[0].Title, "Wrong title")
 		assert.Equal(string(GUID), notes[0].GUID, "Wrong GUID")
+		assert.Equal(&clinote.Location{Latitude: lat, Longitude: lon}, notes[0].Location, "Wrong location")
+	})
+
+	t.Run("sort order", func(t *testing.T) {
+		var searched *notestore.NoteFilter
+		ns.evernoteNS = &mockAPI{findNote: func(k string, f *notestore.NoteFilter, o, c int32) (*notestore.NoteList, error) {
+			searched = f
+			return nl, nil
+		}}
+		_, err := ns.FindNotes(&clinote.NoteFilter{Order: clinote.NoteFilterOrderCreated}, 0, 20)
+		assert.NoError(err, "Should not return an error")
+		assert.Equal(clinote.NoteFilterOrderCreated, searched.GetOrder(), "Wrong sort order")
 	})
 
 	t.Run("one notebook", func(t *testing.T) {
@@ -225,6 +392,36 @@ func TestFindNotes(t *testing.T) {
 	})
 }
 
+func TestFindNoteVersionsSDK(t *testing.T) {
+	assert := assert.New(t)
+	guid := types.GUID("GUID")
+	usn := int32(42)
+	title := "Title"
+	api := &mockAPI{
+		findMetadata: func(token string, filter *notestore.NoteFilter, offset, count int32, spec *notestore.NotesMetadataResultSpec) (*notestore.NotesMetadataList, error) {
+			assert.Equal("tag:work", filter.GetWords())
+			assert.Equal(int32(10), count)
+			assert.True(spec.GetIncludeUpdateSequenceNum(), "The USN should be requested")
+			assert.False(spec.GetIncludeTitle(), "The title should not be requested")
+			return &notestore.NotesMetadataList{Notes: []*notestore.NoteMetadata{{GUID: guid, UpdateSequenceNum: &usn}}}, nil
+		},
+		getNote: func(_ string, noteGUID types.GUID, content, data, recognition, _ bool) (*types.Note, error) {
+			assert.False(content || data || recognition, "Only the metadata should be requested")
+			return &types.Note{GUID: &noteGUID, Title: &title, UpdateSequenceNum: &usn}, nil
+		},
+	}
+	ns := &Notestore{apiToken: "token", evernoteNS: api}
+
+	notes, err := ns.FindNoteVersions(&clinote.NoteFilter{Words: "tag:work"}, 0, 10)
+	assert.NoError(err)
+	assert.Equal([]*clinote.Note{{GUID: "GUID", USN: 42}}, notes)
+
+	n, err := ns.GetNoteMetadata("GUID")
+	assert.NoError(err)
+	assert.Equal("Title", n.Title)
+	assert.Equal(int32(42), n.USN)
+}
+
 func TestGetNoteContentSDK(t *testing.T) {
 	assert := assert.New(t)
 	expectedContent := "Note content"
@@ -238,6 +435,75 @@ func TestGetNoteContentSDK(t *testing.T) {
 	assert.Equal(expectedContent, content, "Wrong content")
 }
 
+func TestGetNoteResourcesSDK(t *testing.T) {
+	assert := assert.New(t)
+	guid := types.GUID("res")
+	mime := "image/png"
+	size := int32(3)
+	filename := "image.png"
+	reco := []byte(`<recoIndex objType="image"><item x="1" y="2" w="3" h="4"><t w="40">CLINOTE</t><t w="80">clinote</t></item></recoIndex>`)
+	api := &mockAPI{getNote: func(_ string, noteGUID types.GUID, content, data, recognition, _ bool) (*types.Note, error) {
+		assert.Equal(types.GUID("GUID"), noteGUID)
+		assert.False(content, "Content should not be requested")
+		assert.True(data, "Resource data should be requested")
+		assert.True(recognition, "Recognition data should be requested")
+		return &types.Note{Resources: []*types.Resource{&types.Resource{
+			GUID:        &guid,
+			Mime:        &mime,
+			Data:        &types.Data{BodyHash: []byte{0xab, 0xcd}, Size: &size, Body: []byte("png")},
+			Attributes:  &types.ResourceAttributes{FileName: &filename},
+			Recognition: &types.Data{Body: reco},
+		}}}, nil
+	}}
+	ns := &Notestore{apiToken: "token", evernoteNS: api}
+
+	resources, err := ns.GetNoteResources("GUID")
+
+	assert.NoError(err)
+	if assert.Len(resources, 1) {
+		assert.Equal(&clinote.Resource{GUID: "res", Hash: "abcd", Mime: mime, Filename: filename, Size: 3, Data: []byte("png"),
+			Recognition: []clinote.RecognizedText{{Text: "clinote", Weight: 80, Alternatives: []string{"CLINOTE"}}}}, resources[0])
+	}
+}
+
+func TestGetNoteRecognitionSDK(t *testing.T) {
+	assert := assert.New(t)
+	guid := types.GUID("res")
+	reco := []byte(`<recoIndex objType="image"><item x="1" y="2" w="3" h="4"><t w="80">clinote</t></item></recoIndex>`)
+	api := &mockAPI{getNote: func(_ string, noteGUID types.GUID, content, data, recognition, _ bool) (*types.Note, error) {
+		assert.Equal(types.GUID("GUID"), noteGUID)
+		assert.False(content, "Content should not be requested")
+		assert.False(data, "Resource data should not be requested")
+		assert.True(recognition, "Recognition data should be requested")
+		return &types.Note{Resources: []*types.Resource{&types.Resource{GUID: &guid, Recognition: &types.Data{Body: reco}}}}, nil
+	}}
+	ns := &Notestore{apiToken: "token", evernoteNS: api}
+
+	resources, err := ns.GetNoteRecognition("GUID")
+
+	assert.NoError(err)
+	if assert.Len(resources, 1) {
+		assert.Nil(resources[0].Data)
+		assert.Equal([]clinote.RecognizedText{{Text: "clinote", Weight: 80}}, resources[0].Recognition)
+	}
+}
+
+func TestGetAllTagsSDK(t *testing.T) {
+	assert := assert.New(t)
+	guid := types.GUID("tag")
+	name := "Travel"
+	api := &mockAPI{listTags: func(token string) ([]*types.Tag, error) {
+		assert.Equal("token", token)
+		return []*types.Tag{&types.Tag{GUID: &guid, Name: &name}}, nil
+	}}
+	ns := &Notestore{apiToken: "token", evernoteNS: api}
+
+	tags, err := ns.GetAllTags()
+
+	assert.NoError(err)
+	assert.Equal([]*clinote.Tag{{GUID: "tag", Name: "Travel"}}, tags)
+}
+
 type mockAPI struct {
 	listNotebooks  func(string) ([]*types.Notebook, error)
 	updateNotebook func(string, *types.Notebook) (int32, error)
@@ -246,7 +512,15 @@ type mockAPI struct {
 	deleteNote     func(string, types.GUID) (int32, error)
 	updateNote     func(string, *types.Note) (*types.Note, error)
 	findNote       func(string, *notestore.NoteFilter, int32, int32) (*notestore.NoteList, error)
+	findMetadata   func(string, *notestore.NoteFilter, int32, int32, *notestore.NotesMetadataResultSpec) (*notestore.NotesMetadataList, error)
 	getNoteContent func(string, types.GUID) (string, error)
+	getSyncState   func(string) (*notestore.SyncState, error)
+	getNote        func(string, types.GUID, bool, bool, bool, bool) (*types.Note, error)
+	listTags       func(string) ([]*types.Tag, error)
+}
+
+func (a *mockAPI) ListTags(apiKey string) (r []*types.Tag, err error) {
+	return a.listTags(apiKey)
 }
 
 func (a *mockAPI) ListNotebooks(apiKey string) (r []*types.Notebook, err error) {
@@ -269,6 +543,10 @@ func (a *mockAPI) FindNotes(apiKey string, filter *notestore.NoteFilter, offset
 	return a.findNote(apiKey, filter, offset, maxNumNotes)
 }
 
+func (a *mockAPI) FindNotesMetadata(apiKey string, filter *notestore.NoteFilter, offset int32, maxNotes int32, spec *notestore.NotesMetadataResultSpec) (*notestore.NotesMetadataList, error) {
+	return a.findMetadata(apiKey, filter, offset, maxNotes, spec)
+}
+
 func (a *mockAPI) DeleteNote(apiKey string, guid types.GUID) (int32, error) {
 	return a.deleteNote(apiKey, guid)
 }
@@ -284,3 +562,11 @@ func (a *mockAPI) GetNoteContent(authenticationToken string, guid types.GUID) (r
 func (a *mockAPI) GetNotebook(authenticationToken string, guid types.GUID) (r *types.Notebook, err error) {
 	panic("not implemented")
 }
+
+func (a *mockAPI) GetSyncState(authenticationToken string) (r *notestore.SyncState, err error) {
+	return a.getSyncState(authenticationToken)
+}
+
+func (a *mockAPI) GetNote(authenticationToken string, guid types.GUID, withContent bool, withResourcesData bool, withResourcesRecognition bool, withResourcesAlternateData bool) (r *types.Note, err error) {
+	return a.getNote(authenticationToken, guid, withContent, withResourcesData, withResourcesRecognition, withResourcesAlternateData)
+}
@@ -0,0 +1,75 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import "github.com/TcM1911/clinote"
+
+// offlineNotestore is used when the server can't be reached. All calls
+// fail with the connection error, so changes to notes are queued and
+// pushed once the server is reachable again.
+type offlineNotestore struct {
+	err error
+}
+
+func (o *offlineNotestore) FindNotes(filter *clinote.NoteFilter, offset, count int) ([]*clinote.Note, error) {
+	return nil, o.err
+}
+
+func (o *offlineNotestore) GetAllNotebooks() ([]*clinote.Notebook, error) {
+	return nil, o.err
+}
+
+func (o *offlineNotestore) GetNotebook(guid string) (*clinote.Notebook, error) {
+	return nil, o.err
+}
+
+func (o *offlineNotestore) CreateNotebook(b *clinote.Notebook, defaultNotebook bool) error {
+	return o.err
+}
+
+func (o *offlineNotestore) GetNoteContent(guid string) (string, error) {
+	return "", o.err
+}
+
+func (o *offlineNotestore) UpdateNote(note *clinote.Note) error {
+	return o.err
+}
+
+func (o *offlineNotestore) DeleteNote(guid string) error {
+	return o.err
+}
+
+func (o *offlineNotestore) CreateNote(note *clinote.Note) error {
+	return o.err
+}
+
+func (o *offlineNotestore) UpdateNotebook(book *clinote.Notebook) error {
+	return o.err
+}
+
+func (o *offlineNotestore) GetSyncState() (*clinote.SyncState, error) {
+	return nil, o.err
+}
+
+func (o *offlineNotestore) GetNoteResources(guid string) ([]*clinote.Resource, error) {
+	return nil, o.err
+}
+
+func (o *offlineNotestore) GetAllTags() ([]*clinote.Tag, error) {
+	return nil, o.err
+}
@@ -0,0 +1,250 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/TcM1911/clinote"
+)
+
+// replayToken is the access token used when replaying a session without
+// being logged in. It has the form of an Evernote token so it's redacted
+// like the recorded token.
+const replayToken = "S=s0:U=0:E=0:C=0:P=0:A=clinote:V=2:H=0"
+
+// replaying is true if the API calls are replayed from a recording.
+var replaying bool
+
+// Interaction is an API request and its response in a recording.
+// Authentication tokens, OAuth parameters and cookies are redacted.
+type Interaction struct {
+	Method         string
+	URL            string
+	RequestHeader  http.Header `json:",omitempty"`
+	RequestBody    []byte      `json:",omitempty"`
+	Status         int
+	ResponseHeader http.Header `json:",omitempty"`
+	ResponseBody   []byte      `json:",omitempty"`
+}
+
+// Recording is a recorded API session.
+type Recording struct {
+	Interactions []*Interaction
+}
+
+// LoadRecording reads a recording saved by a record transport.
+func LoadRecording(path string) (*Recording, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Recording)
+	if err = json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewRecordTransport returns an HTTP transport that saves the requests and
+// responses sent through next to the file. The file is rewritten after
+// each request so an interrupted session is still recorded.
+func NewRecordTransport(path string, next http.RoundTripper) http.RoundTripper {
+	return &recordTransport{path: path, next: next, rec: new(Recording)}
+}
+
+// NewReplayTransport returns an HTTP transport that answers the requests
+// with the responses in the recording, without contacting the server. A
+// request is answered by the first unused interaction with the same
+// method, URL and body. If none has the same body, the first unused
+// interaction with the same method and URL is used. Requests that aren't
+// in the recording get a 404 response.
+func NewReplayTransport(rec *Recording) http.RoundTripper {
+	return &replayTransport{rec: rec, used: make([]bool, len(rec.Interactions))}
+}
+
+// EnableRecord records the requests and responses of the API calls to the
+// file.
+func EnableRecord(path string) {
+	wrapAPITransport(func(next http.RoundTripper) http.RoundTripper {
+		return NewRecordTransport(path, next)
+	})
+}
+
+// EnableReplay answers the API calls from the recording. Clients created
+// afterwards don't need to be logged in.
+func EnableReplay(rec *Recording) {
+	apiClient.Transport = NewReplayTransport(rec)
+	replaying = true
+}
+
+// Replaying returns true if the API calls are replayed from a recording.
+func Replaying() bool {
+	return replaying
+}
+
+type recordTransport struct {
+	mu   sync.Mutex
+	path string
+	next http.RoundTripper
+	rec  *Recording
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rec.Interactions = append(t.rec.Interactions, &Interaction{
+		Method:         req.Method,
+		URL:            redactURL(req.URL),
+		RequestHeader:  redactHeaders(req.Header),
+		RequestBody:    redactBody(reqBody),
+		Status:         resp.StatusCode,
+		ResponseHeader: redactHeaders(resp.Header),
+		ResponseBody:   redactBody(respBody),
+	})
+	data, err := json.MarshalIndent(t.rec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(t.path, data, 0600); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayTransport struct {
+	mu   sync.Mutex
+	rec  *Recording
+	used []bool
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	i := t.match(req.Method, redactURL(req.URL), redactBody(body))
+	if i == -1 {
+		clinote.LogVerbose("no recorded response", "method", req.Method, "url", redactURL(req.URL))
+		fmt.Fprintf(os.Stderr, "No recorded response for %s %s\n", req.Method, redactURL(req.URL))
+		return &http.Response{
+			Status:        "404 Not Recorded",
+			StatusCode:    http.StatusNotFound,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        make(http.Header),
+			Body:          ioutil.NopCloser(bytes.NewReader(nil)),
+			ContentLength: 0,
+			Request:       req,
+		}, nil
+	}
+	in := t.rec.Interactions[i]
+	header := in.ResponseHeader
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(in.ResponseBody)),
+		ContentLength: int64(len(in.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// match returns the index of the interaction answering the request and
+// marks it as used. It returns -1 if none is found.
+func (t *replayTransport) match(method, u string, body []byte) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	found := -1
+	for i, in := range t.rec.Interactions {
+		if t.used[i] || in.Method != method || in.URL != u {
+			continue
+		}
+		if bytes.Equal(in.RequestBody, body) {
+			found = i
+			break
+		}
+		if found == -1 {
+			found = i
+		}
+	}
+	if found != -1 {
+		t.used[found] = true
+	}
+	return found
+}
+
+// readRequestBody reads the request body and replaces it so it can be
+// sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// redactHeaders returns a copy of the headers with the secret headers
+// redacted.
+func redactHeaders(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	cpy := make(http.Header, len(h))
+	for k, v := range h {
+		cpy[k] = v
+	}
+	for _, secret := range secretHeaders {
+		if cpy.Get(secret) != "" {
+			cpy.Set(secret, redacted)
+		}
+	}
+	return cpy
+}
@@ -0,0 +1,75 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-replay")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+	token := "S=s1:U=8f:E=1234:C=abc:P=1cd:A=en-devtoken:V=2:H=deadbeef"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte("response to " + strings.TrimPrefix(string(body), token)))
+	}))
+	post := func(client *http.Client, body string) (int, string) {
+		resp, err := client.Post(server.URL+"/notestore", "application/x-thrift", strings.NewReader(body))
+		if !assert.NoError(err) {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	recorder := &http.Client{Transport: NewRecordTransport(path, http.DefaultTransport)}
+	post(recorder, token+"getNote")
+	post(recorder, token+"getSyncState")
+	post(recorder, token+"getNote")
+	server.Close()
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.NotContains(string(data), "deadbeef", "The token should be redacted")
+
+	rec, err := LoadRecording(path)
+	assert.NoError(err)
+	assert.Len(rec.Interactions, 3)
+	replayer := &http.Client{Transport: NewReplayTransport(rec)}
+	status, body := post(replayer, replayToken+"getSyncState")
+	assert.Equal(200, status)
+	assert.Equal("response to getSyncState", body, "Should match the request body")
+	_, body = post(replayer, replayToken+"getNote")
+	assert.Equal("response to getNote", body)
+	_, body = post(replayer, replayToken+"getTag")
+	assert.Equal("response to getNote", body, "Should use the next interaction for the URL")
+	status, _ = post(replayer, replayToken+"getNote")
+	assert.Equal(http.StatusNotFound, status, "All interactions should be used")
+}
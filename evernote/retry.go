@@ -0,0 +1,264 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/evernote/api"
+	edam "github.com/TcM1911/evernote-sdk-golang/errors"
+	"github.com/TcM1911/evernote-sdk-golang/notestore"
+	"github.com/TcM1911/evernote-sdk-golang/types"
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+var (
+	// MaxRateLimitRetries is how many times a rate limited call is retried.
+	MaxRateLimitRetries = 3
+	// MaxRateLimitWait is the longest time to wait before a rate limited call
+	// is retried. If the server asks for a longer wait, a RateLimitError is
+	// returned instead.
+	MaxRateLimitWait = 5 * time.Minute
+)
+
+// rateLimitHits is the number of calls that have been rate limited.
+var rateLimitHits int64
+
+// RateLimitHits returns how many calls have been rate limited by the
+// server since the program was started, including calls that succeeded
+// when they were retried.
+func RateLimitHits() int64 {
+	return atomic.LoadInt64(&rateLimitHits)
+}
+
+// RateLimitError is returned if the API rate limit has been reached.
+type RateLimitError struct {
+	// Duration is how long to wait before trying again.
+	Duration time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, try again in %ds", int(e.Duration.Seconds()))
+}
+
+// rateLimitDuration returns how long to wait before the call can be retried
+// if the error is a rate limit error.
+func rateLimitDuration(err error) (time.Duration, bool) {
+	e, ok := err.(*edam.EDAMSystemException)
+	if !ok || e.GetErrorCode() != edam.EDAMErrorCode_RATE_LIMIT_REACHED {
+		return 0, false
+	}
+	return time.Duration(e.GetRateLimitDuration()) * time.Second, true
+}
+
+// RetryOverride overrides the retry policy in the settings. The fields
+// that are zero are taken from the settings.
+var RetryOverride clinote.RetryPolicy
+
+// retryNotestore wraps a notestore and retries calls that fail because
+// the rate limit has been reached or because of a transient network error.
+type retryNotestore struct {
+	ns     api.Notestore
+	out    io.Writer
+	sleep  func(time.Duration)
+	policy clinote.RetryPolicy
+	random func() float64
+}
+
+func newRetryNotestore(ns api.Notestore, policy clinote.RetryPolicy) *retryNotestore {
+	return &retryNotestore{ns: ns, out: os.Stderr, sleep: sleepContext, policy: policy, random: rand.Float64}
+}
+
+// retry makes a call that can safely be repeated. It's retried on all
+// transient network errors.
+func (r *retryNotestore) retry(call func() error) error {
+	return r.do(call, isTransient)
+}
+
+// retryWrite makes a call that changes the account. If the request may
+// have reached the server, it's not retried since the change could be
+// made twice.
+func (r *retryNotestore) retryWrite(call func() error) error {
+	return r.do(call, isDialError)
+}
+
+func (r *retryNotestore) do(call func() error, retryable func(error) bool) error {
+	limited, failed := 0, 0
+	for {
+		err := call()
+		if d, ok := rateLimitDuration(err); ok {
+			atomic.AddInt64(&rateLimitHits, 1)
+			if limited >= MaxRateLimitRetries || d > MaxRateLimitWait {
+				return &RateLimitError{Duration: d}
+			}
+			limited++
+			clinote.LogVerbose("rate limited", "attempt", limited, "wait", d)
+			fmt.Fprintf(r.out, "Rate limited, retrying in %ds\n", int(d.Seconds()))
+			r.sleep(d)
+			continue
+		}
+		if err == nil || !retryable(err) || failed+1 >= r.policy.MaxAttempts {
+			return err
+		}
+		d := r.policy.Delay(failed, r.random())
+		failed++
+		clinote.LogVerbose("network error, retrying", "attempt", failed, "wait", d, "error", err)
+		r.sleep(d)
+	}
+}
+
+// isTransient returns true if the error is a network error or the server
+// responded with a server error.
+func isTransient(err error) bool {
+	if clinote.IsOffline(err) {
+		return true
+	}
+	e, ok := err.(thrift.TTransportException)
+	return ok && strings.HasPrefix(e.Error(), "HTTP Response code: 5")
+}
+
+// isDialError returns true if the connection to the server couldn't be
+// made, so the request wasn't sent.
+func isDialError(err error) bool {
+	for err != nil {
+		if e, ok := err.(*net.OpError); ok {
+			return e.Op == "dial"
+		}
+		switch e := err.(type) {
+		case interface{ Err() error }:
+			err = e.Err()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func (r *retryNotestore) ListNotebooks(apiKey string) (books []*types.Notebook, err error) {
+	err = r.retry(func() error {
+		books, err = r.ns.ListNotebooks(apiKey)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) ListTags(apiKey string) (tags []*types.Tag, err error) {
+	err = r.retry(func() error {
+		tags, err = r.ns.ListTags(apiKey)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) CreateNotebook(apiKey string, notebook *types.Notebook) (book *types.Notebook, err error) {
+	err = r.retryWrite(func() error {
+		book, err = r.ns.CreateNotebook(apiKey, notebook)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) UpdateNotebook(apiKey string, notebook *types.Notebook) (usn int32, err error) {
+	err = r.retryWrite(func() error {
+		usn, err = r.ns.UpdateNotebook(apiKey, notebook)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) GetNotebook(apiKey string, guid types.GUID) (book *types.Notebook, err error) {
+	err = r.retry(func() error {
+		book, err = r.ns.GetNotebook(apiKey, guid)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) CreateNote(apiKey string, note *types.Note) (n *types.Note, err error) {
+	err = r.retryWrite(func() error {
+		n, err = r.ns.CreateNote(apiKey, note)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) DeleteNote(apiKey string, guid types.GUID) (usn int32, err error) {
+	err = r.retryWrite(func() error {
+		usn, err = r.ns.DeleteNote(apiKey, guid)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) UpdateNote(apiKey string, note *types.Note) (n *types.Note, err error) {
+	err = r.retryWrite(func() error {
+		n, err = r.ns.UpdateNote(apiKey, note)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) FindNotes(apiKey string, filter *notestore.NoteFilter, offset int32, maxNumNotes int32) (list *notestore.NoteList, err error) {
+	err = r.retry(func() error {
+		list, err = r.ns.FindNotes(apiKey, filter, offset, maxNumNotes)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) FindNotesMetadata(apiKey string, filter *notestore.NoteFilter, offset int32, maxNotes int32, resultSpec *notestore.NotesMetadataResultSpec) (list *notestore.NotesMetadataList, err error) {
+	err = r.retry(func() error {
+		list, err = r.ns.FindNotesMetadata(apiKey, filter, offset, maxNotes, resultSpec)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) GetSyncState(apiKey string) (state *notestore.SyncState, err error) {
+	err = r.retry(func() error {
+		state, err = r.ns.GetSyncState(apiKey)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) GetNote(apiKey string, guid types.GUID, withContent, withResourcesData, withResourcesRecognition, withResourcesAlternateData bool) (note *types.Note, err error) {
+	err = r.retry(func() error {
+		note, err = r.ns.GetNote(apiKey, guid, withContent, withResourcesData, withResourcesRecognition, withResourcesAlternateData)
+		return err
+	})
+	return
+}
+
+func (r *retryNotestore) GetNoteContent(apiKey string, guid types.GUID) (content string, err error) {
+	err = r.retry(func() error {
+		content, err = r.ns.GetNoteContent(apiKey, guid)
+		return err
+	})
+	return
+}
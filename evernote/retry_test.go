@@ -0,0 +1,142 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	edam "github.com/TcM1911/evernote-sdk-golang/errors"
+	"github.com/TcM1911/evernote-sdk-golang/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func rateLimitError(seconds int32) error {
+	e := edam.NewEDAMSystemException()
+	e.ErrorCode = edam.EDAMErrorCode_RATE_LIMIT_REACHED
+	e.RateLimitDuration = &seconds
+	return e
+}
+
+func TestRetryNotestore(t *testing.T) {
+	assert := assert.New(t)
+	title := "Notebook"
+	books := []*types.Notebook{&types.Notebook{Name: &title}}
+
+	setup := func(errs ...error) (*retryNotestore, *[]time.Duration, *int) {
+		calls := 0
+		var slept []time.Duration
+		api := &mockAPI{listNotebooks: func(string) ([]*types.Notebook, error) {
+			calls++
+			if calls <= len(errs) {
+				return nil, errs[calls-1]
+			}
+			return books, nil
+		}}
+		r := &retryNotestore{ns: api, out: new(bytes.Buffer), sleep: func(d time.Duration) { slept = append(slept, d) }}
+		return r, &slept, &calls
+	}
+
+	t.Run("retry after rate limit", func(t *testing.T) {
+		r, slept, calls := setup(rateLimitError(2))
+		bs, err := r.ListNotebooks("token")
+		assert.NoError(err)
+		assert.Equal(books, bs)
+		assert.Equal(2, *calls)
+		assert.Equal([]time.Duration{2 * time.Second}, *slept)
+		assert.Contains(r.out.(*bytes.Buffer).String(), "Rate limited, retrying in 2s")
+	})
+
+	t.Run("give up after max retries", func(t *testing.T) {
+		r, _, calls := setup(rateLimitError(1), rateLimitError(1), rateLimitError(1), rateLimitError(1))
+		_, err := r.ListNotebooks("token")
+		assert.Equal(&RateLimitError{Duration: time.Second}, err)
+		assert.Equal(MaxRateLimitRetries+1, *calls)
+	})
+
+	t.Run("do not wait too long", func(t *testing.T) {
+		r, slept, _ := setup(rateLimitError(3600))
+		_, err := r.ListNotebooks("token")
+		assert.EqualError(err, "rate limited, try again in 3600s")
+		assert.Empty(*slept)
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		r, _, calls := setup(errExpected, errors.New("not returned"))
+		_, err := r.ListNotebooks("token")
+		assert.Equal(errExpected, err)
+		assert.Equal(1, *calls)
+	})
+
+	policy := clinote.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, Jitter: 0.5}
+
+	t.Run("retry network errors with backoff", func(t *testing.T) {
+		r, slept, calls := setup(resetError, resetError)
+		r.policy = policy
+		r.random = func() float64 { return 0.5 }
+		bs, err := r.ListNotebooks("token")
+		assert.NoError(err)
+		assert.Equal(books, bs)
+		assert.Equal(3, *calls)
+		assert.Equal([]time.Duration{time.Second, 2 * time.Second}, *slept)
+	})
+
+	t.Run("give up after max attempts", func(t *testing.T) {
+		r, slept, calls := setup(resetError, resetError, resetError)
+		r.policy = policy
+		r.random = func() float64 { return 0 }
+		_, err := r.ListNotebooks("token")
+		assert.Equal(resetError, err)
+		assert.Equal(3, *calls)
+		assert.Equal([]time.Duration{500 * time.Millisecond, time.Second}, *slept, "Jitter should shorten the wait")
+	})
+
+	t.Run("writes only retried if not sent", func(t *testing.T) {
+		calls := 0
+		var errs []error
+		api := &mockAPI{createNote: func(string, *types.Note) (*types.Note, error) {
+			calls++
+			if calls <= len(errs) {
+				return nil, errs[calls-1]
+			}
+			return new(types.Note), nil
+		}}
+		r := &retryNotestore{ns: api, out: new(bytes.Buffer), sleep: func(time.Duration) {}, policy: policy, random: func() float64 { return 0.5 }}
+
+		errs = []error{dialError}
+		_, err := r.CreateNote("token", new(types.Note))
+		assert.NoError(err)
+		assert.Equal(2, calls)
+
+		calls = 0
+		errs = []error{resetError}
+		_, err = r.CreateNote("token", new(types.Note))
+		assert.Equal(resetError, err, "The note may already have been created")
+		assert.Equal(1, calls)
+	})
+}
+
+var (
+	dialError  = &url.Error{Op: "Post", URL: "https://www.evernote.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	resetError = &url.Error{Op: "Post", URL: "https://www.evernote.com", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+)
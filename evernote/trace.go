@@ -0,0 +1,165 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxTraceBody is the number of bytes of a request or response body that
+// is written to the trace.
+const maxTraceBody = 64 << 10
+
+const redacted = "[REDACTED]"
+
+var (
+	// tokenPattern matches Evernote authentication tokens, which are sent
+	// in the Thrift request bodies.
+	tokenPattern = regexp.MustCompile(`S=s\d+:U=[0-9a-f]+:[!-~]*?H=[0-9a-f]+`)
+	// secretParams are the OAuth parameters and headers that are redacted.
+	secretParams  = []string{"oauth_token", "oauth_signature", "oauth_verifier", "oauth_consumer_key"}
+	secretHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+)
+
+// NewTraceTransport returns an HTTP transport that writes the requests and
+// responses sent through next to w. Authentication tokens, OAuth parameters
+// and cookies are redacted.
+func NewTraceTransport(w io.Writer, next http.RoundTripper) http.RoundTripper {
+	return &traceTransport{out: w, next: next}
+}
+
+// EnableTrace writes the requests and responses of the API calls to w.
+func EnableTrace(w io.Writer) {
+	wrapAPITransport(func(next http.RoundTripper) http.RoundTripper {
+		return NewTraceTransport(w, next)
+	})
+}
+
+type traceTransport struct {
+	mu   sync.Mutex
+	out  io.Writer
+	next http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--> %s %s %s\n", req.Method, redactURL(req.URL), start.Format(time.RFC3339))
+	writeHeaders(&b, req.Header)
+	writeBody(&b, reqBody)
+	if err != nil {
+		fmt.Fprintf(&b, "<-- error after %s: %s\n\n", time.Since(start).Round(time.Millisecond), err)
+		t.write(b.Bytes())
+		return nil, err
+	}
+	respBody, readErr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	fmt.Fprintf(&b, "<-- %s (%s)\n", resp.Status, time.Since(start).Round(time.Millisecond))
+	writeHeaders(&b, resp.Header)
+	writeBody(&b, respBody)
+	b.WriteString("\n")
+	t.write(b.Bytes())
+	if readErr != nil {
+		return nil, readErr
+	}
+	return resp, nil
+}
+
+func (t *traceTransport) write(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.out.Write(p)
+}
+
+func redactURL(u *url.URL) string {
+	cpy := *u
+	if q := cpy.Query(); redactParams(q) {
+		cpy.RawQuery = q.Encode()
+	}
+	return cpy.String()
+}
+
+// redactParams redacts the OAuth secrets in the parameters. It returns
+// true if any were found.
+func redactParams(q url.Values) bool {
+	found := false
+	for _, p := range secretParams {
+		if q.Get(p) != "" {
+			q.Set(p, redacted)
+			found = true
+		}
+	}
+	return found
+}
+
+// redactBody redacts the authentication tokens in a Thrift body and the
+// OAuth secrets in a form encoded body.
+func redactBody(body []byte) []byte {
+	body = tokenPattern.ReplaceAll(body, []byte(redacted))
+	if q, err := url.ParseQuery(string(body)); err == nil && redactParams(q) {
+		return []byte(q.Encode())
+	}
+	return body
+}
+
+func writeHeaders(w io.Writer, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := h.Get(k)
+		for _, secret := range secretHeaders {
+			if http.CanonicalHeaderKey(secret) == k {
+				v = redacted
+			}
+		}
+		fmt.Fprintf(w, "%s: %s\n", k, v)
+	}
+}
+
+// writeBody writes the body as a quoted string since Thrift bodies are
+// binary. Long bodies are truncated.
+func writeBody(w io.Writer, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	body = redactBody(body)
+	if len(body) > maxTraceBody {
+		fmt.Fprintf(w, "%s... (%d bytes not shown)\n", strconv.Quote(string(body[:maxTraceBody])), len(body)-maxTraceBody)
+		return
+	}
+	fmt.Fprintln(w, strconv.Quote(string(body)))
+}
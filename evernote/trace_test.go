@@ -0,0 +1,59 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceTransport(t *testing.T) {
+	assert := assert.New(t)
+	token := "S=s1:U=8f:E=1234:C=abc:P=1cd:A=en-devtoken:V=2:H=deadbeef"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Contains(string(body), token, "The request should not be changed")
+		w.Write([]byte("oauth_token=secret&edam_shard=s1"))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := &http.Client{Transport: NewTraceTransport(&buf, http.DefaultTransport)}
+	req, err := http.NewRequest("POST", server.URL+"/notestore?oauth_signature=sig", strings.NewReader("\x00\x01getNote\x0b"+token+"\x00"))
+	assert.NoError(err)
+	req.Header.Set("Authorization", "OAuth secret")
+	resp, err := client.Do(req)
+	assert.NoError(err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal("oauth_token=secret&edam_shard=s1", string(body), "The response should not be changed")
+
+	trace := buf.String()
+	assert.Contains(trace, "--> POST "+server.URL+"/notestore?oauth_signature=%5BREDACTED%5D")
+	assert.Contains(trace, "Authorization: [REDACTED]")
+	assert.Contains(trace, `getNote\v[REDACTED]`)
+	assert.Contains(trace, "<-- 200 OK")
+	assert.Contains(trace, "edam_shard=s1&oauth_token=%5BREDACTED%5D")
+	assert.NotContains(trace, "secret")
+	assert.NotContains(trace, "deadbeef")
+}
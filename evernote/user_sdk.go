@@ -0,0 +1,88 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"strings"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/evernote-sdk-golang/types"
+)
+
+// GetAccountInfo returns the user's account and upload quota from the
+// server. The calls are retried like the notestore calls.
+func (c *Client) GetAccountInfo() (*clinote.AccountInfo, error) {
+	if c.apiToken == "" {
+		return nil, ErrNotLoggedIn
+	}
+	var user *types.User
+	err := newRetryNotestore(nil, c.retryPolicy()).retry(func() error {
+		us, err := c.evernote.GetUserStore()
+		if err != nil {
+			return err
+		}
+		user, err = us.GetUser(c.apiToken)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	ns, err := c.GetNoteStore()
+	if err != nil {
+		return nil, err
+	}
+	state, err := ns.GetSyncState()
+	if err != nil {
+		return nil, err
+	}
+	info := convertUser(user)
+	info.Uploaded = state.Uploaded
+	return info, nil
+}
+
+func convertUser(u *types.User) *clinote.AccountInfo {
+	info := &clinote.AccountInfo{
+		Username:    u.GetUsername(),
+		Name:        u.GetName(),
+		AccountType: accountType(u),
+	}
+	if a := u.GetAccounting(); a != nil {
+		info.UploadLimit = a.GetUploadLimit()
+		if a.IsSetUploadLimitEnd() {
+			info.CycleEnd = time.Unix(0, int64(a.GetUploadLimitEnd())*int64(time.Millisecond))
+		}
+	}
+	return info
+}
+
+func accountType(u *types.User) string {
+	if a := u.GetAccounting(); a != nil && a.GetBusinessName() != "" {
+		return "Business, " + a.GetBusinessName()
+	}
+	if !u.IsSetPrivilege() {
+		return "Unknown"
+	}
+	switch u.GetPrivilege() {
+	case types.PrivilegeLevel_NORMAL:
+		return "Basic"
+	default:
+		s := u.GetPrivilege().String()
+		return s[:1] + strings.ToLower(s[1:])
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package evernote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/evernote-sdk-golang/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertUser(t *testing.T) {
+	assert := assert.New(t)
+	username, name := "jdoe", "John Doe"
+	limit := int64(60 << 20)
+	end := types.Timestamp(1520845200000)
+	premium := types.PrivilegeLevel_PREMIUM
+	user := &types.User{
+		Username:   &username,
+		Name:       &name,
+		Privilege:  &premium,
+		Accounting: &types.Accounting{UploadLimit: &limit, UploadLimitEnd: &end},
+	}
+	assert.Equal(&clinote.AccountInfo{
+		Username:    "jdoe",
+		Name:        "John Doe",
+		AccountType: "Premium",
+		UploadLimit: limit,
+		CycleEnd:    time.Unix(1520845200, 0),
+	}, convertUser(user))
+
+	normal := types.PrivilegeLevel_NORMAL
+	assert.Equal("Basic", accountType(&types.User{Privilege: &normal}))
+	business := "ACME"
+	assert.Equal("Business, ACME", accountType(&types.User{Privilege: &normal, Accounting: &types.Accounting{BusinessName: &business}}))
+	assert.Equal("Unknown", accountType(&types.User{}))
+}
@@ -0,0 +1,184 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ExportManifestName is the name of the file in the export folder that
+	// maps the exported notes to their files.
+	ExportManifestName = ".clinote-export.json"
+	// maxSlugLength is the longest slug, in characters, used for file
+	// names.
+	maxSlugLength = 80
+	// slugGUIDLength is the length of the GUID prefix added to the slug
+	// when another note uses it.
+	slugGUIDLength = 8
+)
+
+// ErrInvalidExportManifest is returned if a file name in the export
+// manifest isn't a plain file name, for example if it has a path in it.
+var ErrInvalidExportManifest = errors.New("the export manifest has an invalid file name")
+
+// windowsReservedNames can't be used as file names on Windows, with or
+// without an extension.
+var windowsReservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// Slugify returns a file name safe slug for the title. Letters and digits
+// are kept in lower case, everything else is replaced by a single dash.
+// An empty title gives "untitled".
+func Slugify(title string) string {
+	var b strings.Builder
+	// dash is true if a dash is written before the next letter or digit.
+	dash := false
+	n := 0
+	for _, r := range strings.ToLower(title) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = b.Len() != 0
+			continue
+		}
+		size := 1
+		if dash {
+			size++
+		}
+		if n+size > maxSlugLength {
+			break
+		}
+		if dash {
+			b.WriteRune('-')
+		}
+		b.WriteRune(r)
+		n += size
+		dash = false
+	}
+	slug := b.String()
+	if slug == "" {
+		return "untitled"
+	}
+	if windowsReservedNames[slug] {
+		slug += "-note"
+	}
+	return slug
+}
+
+// ExportManifest maps the GUIDs of the exported notes to their file names,
+// without the extension. It's saved in the export folder so exporting to
+// the folder again updates the files in place.
+type ExportManifest struct {
+	Files map[string]string
+	// used are the names in use, in lower case for case insensitive file
+	// systems.
+	used map[string]string
+	// existing are the names of the files in the folder, without the
+	// extension and in lower case.
+	existing map[string]bool
+}
+
+// LoadExportManifest reads the manifest in the folder. An empty manifest
+// is returned if the folder has no manifest. The names of the files in
+// the folder are read so new notes don't overwrite files that aren't in
+// the manifest.
+func LoadExportManifest(folder string) (*ExportManifest, error) {
+	m := &ExportManifest{Files: make(map[string]string), existing: make(map[string]bool)}
+	files, err := ioutil.ReadDir(folder)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, f := range files {
+		name := strings.ToLower(f.Name())
+		m.existing[strings.TrimSuffix(name, filepath.Ext(name))] = true
+	}
+	data, err := ioutil.ReadFile(filepath.Join(folder, ExportManifestName))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]string)
+	}
+	for _, name := range m.Files {
+		if !validExportName(name) {
+			return nil, ErrInvalidExportManifest
+		}
+	}
+	return m, nil
+}
+
+// validExportName returns true if the name is a file name without a path.
+func validExportName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
+
+// Save writes the manifest to the folder.
+func (m *ExportManifest) Save(folder string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(folder, ExportManifestName), data, 0600)
+}
+
+// FileName returns the file name, without the extension, for the note. A
+// note in the manifest keeps its name. Other notes get the slug of the
+// title, with the start of the GUID added if another note or a file in the
+// folder uses the slug.
+func (m *ExportManifest) FileName(n *Note) string {
+	if m.used == nil {
+		m.used = make(map[string]string, len(m.Files))
+		for guid, name := range m.Files {
+			m.used[strings.ToLower(name)] = guid
+		}
+	}
+	if name, ok := m.Files[n.GUID]; ok {
+		return name
+	}
+	name := Slugify(n.Title)
+	for _, candidate := range []string{name, name + "-" + shortGUID(n.GUID), name + "-" + n.GUID} {
+		if _, ok := m.used[strings.ToLower(candidate)]; !ok && !m.existing[strings.ToLower(candidate)] {
+			name = candidate
+			break
+		}
+	}
+	m.Files[n.GUID] = name
+	m.used[strings.ToLower(name)] = n.GUID
+	return name
+}
+
+func shortGUID(guid string) string {
+	if len(guid) > slugGUIDLength {
+		return guid[:slugGUIDLength]
+	}
+	return guid
+}
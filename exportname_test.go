@@ -0,0 +1,68 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		slug  string
+	}{
+		{"Meeting notes: Q3/Q4 plan!", "meeting-notes-q3-q4-plan"},
+		{"  Über café  ", "über-café"},
+		{"../..", "untitled"},
+		{"", "untitled"},
+		{"CON", "con-note"},
+		{"日本語 メモ", "日本語-メモ"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.slug, Slugify(test.title), test.title)
+	}
+	long := Slugify(strings.Repeat("a", 50) + " " + strings.Repeat("b", 50))
+	assert.Len(t, []rune(long), maxSlugLength)
+	assert.Equal(t, strings.Repeat("a", maxSlugLength-1), Slugify(strings.Repeat("a", maxSlugLength-1)+" b"))
+}
+
+func TestExportManifestFileName(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-export")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "Plan.md"), []byte("Not exported"), 0600))
+
+	m, err := LoadExportManifest(dir)
+	assert.NoError(err)
+	assert.Equal("plan-0123abcd", m.FileName(&Note{GUID: "0123abcd-guid", Title: "Plan"}), "Files in the folder should not be overwritten")
+	assert.Equal("notes", m.FileName(&Note{GUID: "4567efgh-guid", Title: "Notes"}))
+
+	for _, name := range []string{"../plan", `sub\plan`, ".."} {
+		m = &ExportManifest{Files: map[string]string{"guid": name}}
+		assert.NoError(m.Save(dir))
+		_, err = LoadExportManifest(dir)
+		assert.Equal(ErrInvalidExportManifest, err, name)
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultConcurrency is the default number of workers used to fetch notes.
+const DefaultConcurrency = 4
+
+// NotestoreFactory returns a new notestore client. It is used to give
+// each worker its own client since the clients are not safe for
+// concurrent use.
+type NotestoreFactory func() (NotestoreClient, error)
+
+// FetchProgress is called each time a note has been fetched, successfully
+// or not.
+type FetchProgress func(done, total int)
+
+// FetchError is returned if one or more notes failed to be fetched.
+type FetchError struct {
+	// Errors holds the error for each failed note, keyed by the note's GUID.
+	Errors map[string]error
+	// Total is the number of notes that was fetched.
+	Total int
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("failed to fetch %d of %d notes", len(e.Errors), e.Total)
+}
+
+// FetchNoteContents fetches the content of the notes using a pool of workers.
+// If some of the notes failed to be fetched, the rest are still fetched and
+// a FetchError is returned.
+func FetchNoteContents(factory NotestoreFactory, notes []*Note, concurrency int, progress FetchProgress) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(notes) {
+		concurrency = len(notes)
+	}
+	clients := make([]NotestoreClient, concurrency)
+	for i := range clients {
+		ns, err := factory()
+		if err != nil {
+			return err
+		}
+		clients[i] = ns
+	}
+
+	jobs := make(chan *Note)
+	fetchErr := &FetchError{Errors: make(map[string]error), Total: len(notes)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	done := 0
+	for _, ns := range clients {
+		wg.Add(1)
+		go func(ns NotestoreClient) {
+			defer wg.Done()
+			for n := range jobs {
+				err := getNoteContent(ns, n)
+				mu.Lock()
+				if err != nil {
+					fetchErr.Errors[n.GUID] = err
+				}
+				done++
+				if progress != nil {
+					progress(done, len(notes))
+				}
+				mu.Unlock()
+			}
+		}(ns)
+	}
+	for _, n := range notes {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(fetchErr.Errors) != 0 {
+		return fetchErr
+	}
+	return nil
+}
+
+// ExportedFile is a note written to a file by ExportNotes.
+type ExportedFile struct {
+	Note *Note
+	Path string
+}
+
+// ExportNotes fetches the notes and writes each note to a file in the folder.
+// The file names are slugs of the titles, see Slugify, and are recorded in
+// the folder's ExportManifest so notes exported again overwrite their
+// files. The written files are returned. Notes that failed to be fetched
+// are skipped and reported in the returned FetchError.
+func ExportNotes(factory NotestoreFactory, notes []*Note, folder string, concurrency int, progress FetchProgress, opts NoteOption) ([]*ExportedFile, error) {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, err
+	}
+	manifest, err := LoadExportManifest(folder)
+	if err != nil {
+		return nil, err
+	}
+	fetchErr := FetchNoteContents(factory, notes, concurrency, progress)
+	failed := make(map[string]error)
+	if e, ok := fetchErr.(*FetchError); ok {
+		failed = e.Errors
+	} else if fetchErr != nil {
+		return nil, fetchErr
+	}
+	var files []*ExportedFile
+	ext := NoteFormat.Extension()
+	if opts&RawNote != 0 {
+		ext = ".xml"
+	}
+	for _, n := range notes {
+		if _, ok := failed[n.GUID]; ok {
+			continue
+		}
+		path := filepath.Join(folder, manifest.FileName(n)+ext)
+		if err := writeNoteFile(path, n, opts); err != nil {
+			manifest.Save(folder)
+			return files, err
+		}
+		files = append(files, &ExportedFile{Note: n, Path: path})
+	}
+	if err = manifest.Save(folder); err != nil {
+		return files, err
+	}
+	return files, fetchErr
+}
+
+func exportFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, title)
+	if name == "" || name == "." || name == ".." {
+		name = "untitled"
+	}
+	return name
+}
+
+func writeNoteFile(path string, n *Note, opts NoteOption) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = WriteNote(f, n, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
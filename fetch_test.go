@@ -0,0 +1,106 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchNoteContents(t *testing.T) {
+	assert := assert.New(t)
+	content := XMLHeader + "<en-note><div>Content</div></en-note>"
+	notes := []*Note{&Note{GUID: "1", Title: "One"}, &Note{GUID: "2", Title: "Two"}, &Note{GUID: "3", Title: "Three"}}
+	var mu sync.Mutex
+	clients := 0
+	factory := func() (NotestoreClient, error) {
+		mu.Lock()
+		clients++
+		mu.Unlock()
+		return &mockNS{getNoteContent: func(guid string) (string, error) {
+			if guid == "2" {
+				return "", expectedError
+			}
+			return content, nil
+		}}, nil
+	}
+	var calls []int
+
+	err := FetchNoteContents(factory, notes, 2, func(done, total int) {
+		assert.Equal(3, total)
+		calls = append(calls, done)
+	})
+
+	assert.Equal(2, clients, "Should create a client per worker")
+	assert.Equal([]int{1, 2, 3}, calls, "Progress should be reported for each note")
+	if assert.IsType(&FetchError{}, err) {
+		fetchErr := err.(*FetchError)
+		assert.Equal(map[string]error{"2": expectedError}, fetchErr.Errors)
+		assert.EqualError(err, "failed to fetch 1 of 3 notes")
+	}
+	assert.Equal("Content", notes[0].MD)
+	assert.Equal("Content", notes[2].MD)
+}
+
+func TestExportNotes(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-export")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	content := XMLHeader + "<en-note><div>Content</div></en-note>"
+	factory := func() (NotestoreClient, error) {
+		return &mockNS{getNoteContent: func(string) (string, error) { return content, nil }}, nil
+	}
+	notes := []*Note{&Note{GUID: "1", Title: "a/b"}, &Note{GUID: "2d4f6a8c-1234", Title: "A b"}}
+
+	exported, err := ExportNotes(factory, notes, dir, 4, nil, DefaultNoteOption)
+	assert.NoError(err)
+	if assert.Len(exported, 2) {
+		assert.Equal(filepath.Join(dir, "a-b.md"), exported[0].Path)
+		assert.Equal(notes[1], exported[1].Note)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	if assert.Len(files, 3) {
+		assert.Equal(ExportManifestName, files[0].Name())
+		assert.Equal("a-b-2d4f6a8c.md", files[1].Name())
+		assert.Equal("a-b.md", files[2].Name())
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "a-b.md"))
+	assert.NoError(err)
+	assert.Contains(string(data), "Content")
+
+	// Exporting again updates the files in place, also after a title
+	// change, and new notes don't take the names of the exported ones.
+	notes = []*Note{&Note{GUID: "3", Title: "A-B"}, &Note{GUID: "2d4f6a8c-1234", Title: "Renamed"}}
+	exported, err = ExportNotes(factory, notes, dir, 4, nil, DefaultNoteOption)
+	assert.NoError(err)
+	if assert.Len(exported, 2) {
+		assert.Equal(filepath.Join(dir, "a-b-3.md"), exported[0].Path)
+		assert.Equal(filepath.Join(dir, "a-b-2d4f6a8c.md"), exported[1].Path)
+	}
+	manifest, err := LoadExportManifest(dir)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"1": "a-b", "2d4f6a8c-1234": "a-b-2d4f6a8c", "3": "a-b-3"}, manifest.Files)
+}
@@ -0,0 +1,241 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// FixtureVersion is the version of the fixture format.
+const FixtureVersion = 1
+
+// Fixture is a captured note used to reproduce conversion bugs. The
+// converter tests run all fixtures in testdata/fixtures.
+type Fixture struct {
+	// Version is the fixture format version.
+	Version int `json:"version"`
+	// Anonymized is true if the content has been scrambled.
+	Anonymized bool `json:"anonymized"`
+	// Title is the note's title.
+	Title string `json:"title"`
+	// Notebook is the name of the note's notebook.
+	Notebook string `json:"notebook,omitempty"`
+	// Tags is the names of the note's tags.
+	Tags []string `json:"tags,omitempty"`
+	// Created is when the note was created.
+	Created int64 `json:"created"`
+	// Updated is when the note was last updated.
+	Updated int64 `json:"updated"`
+	// Resources is the metadata of the note's attachments.
+	Resources []FixtureResource `json:"resources,omitempty"`
+	// ENML is the note's content as returned by the server.
+	ENML string `json:"enml"`
+	// Markdown is the Markdown the content was converted to when the
+	// fixture was captured. Edit it to the expected output when reporting
+	// a bug.
+	Markdown string `json:"markdown"`
+}
+
+// FixtureResource is the metadata of an attachment in a fixture. The data is
+// not included.
+type FixtureResource struct {
+	Hash     string `json:"hash"`
+	Mime     string `json:"mime"`
+	Filename string `json:"filename,omitempty"`
+	Size     int    `json:"size"`
+}
+
+// CaptureFixture creates a fixture from the note. The note's content is
+// fetched from the notestore. If anonymize is true, the text, the metadata
+// and the resource hashes are scrambled while the markup is preserved.
+func CaptureFixture(db Storager, ns NotestoreClient, title string, anonymize bool) (*Fixture, error) {
+	n, err := GetNote(db, ns, title, "")
+	if err != nil {
+		return nil, err
+	}
+	content, err := ns.GetNoteContent(n.GUID)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := ns.GetNoteResources(n.GUID)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{
+		Version: FixtureVersion,
+		Title:   n.Title,
+		Tags:    append([]string(nil), n.Tags...),
+		Created: n.Created,
+		Updated: n.Updated,
+		ENML:    content,
+	}
+	if n.Notebook != nil {
+		f.Notebook = n.Notebook.Name
+	}
+	for _, r := range resources {
+		f.Resources = append(f.Resources, FixtureResource{Hash: r.Hash, Mime: r.Mime, Filename: r.Filename, Size: r.Size})
+	}
+	if anonymize {
+		AnonymizeFixture(f)
+	}
+	md, err := f.Convert()
+	if err != nil {
+		return nil, err
+	}
+	f.Markdown = md
+	return f, nil
+}
+
+// Convert converts the fixture's ENML to Markdown the same way note content
+// is converted.
+func (f *Fixture) Convert() (string, error) {
+	n := new(Note)
+	if err := parseNoteContent(f.ENML, n); err != nil {
+		return "", err
+	}
+	return n.MD, nil
+}
+
+// WriteFixture writes the fixture as indented JSON.
+func WriteFixture(w io.Writer, f *Fixture) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(f)
+}
+
+// ReadFixture reads a fixture written by WriteFixture.
+func ReadFixture(r io.Reader) (*Fixture, error) {
+	f := new(Fixture)
+	if err := json.NewDecoder(r).Decode(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+var (
+	fixtureTagPattern  = regexp.MustCompile(`<[^>]*>`)
+	fixtureAttrPattern = regexp.MustCompile(`(\s(?:href|title|alt|src|name)\s*=\s*")([^"]*)(")`)
+	fixtureHashPattern = regexp.MustCompile(`(\shash\s*=\s*")([0-9a-fA-F]+)(")`)
+	fixtureEntity      = regexp.MustCompile(`&#?[0-9A-Za-z]+;`)
+	fixtureScheme      = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:(//)?`)
+)
+
+// AnonymizeFixture scrambles the fixture's content. Letters are replaced by
+// random ASCII letters of the same case and digits by random digits, while
+// whitespace, punctuation, entities and the markup are kept. Link targets
+// keep their scheme. Resource hashes are replaced consistently so en-media
+// elements still reference the resources. The scrambling is deterministic,
+// the same fixture is always scrambled the same way.
+func AnonymizeFixture(f *Fixture) {
+	s := &scrambler{rnd: rand.New(rand.NewSource(1)), hashes: make(map[string]string)}
+	f.Title = s.text(f.Title)
+	f.Notebook = s.text(f.Notebook)
+	for i, t := range f.Tags {
+		f.Tags[i] = s.text(t)
+	}
+	for i, r := range f.Resources {
+		f.Resources[i].Hash = s.hash(r.Hash)
+		if r.Filename != "" {
+			ext := filepath.Ext(r.Filename)
+			f.Resources[i].Filename = s.text(strings.TrimSuffix(r.Filename, ext)) + ext
+		}
+	}
+	f.ENML = s.enml(f.ENML)
+	f.Markdown = ""
+	f.Anonymized = true
+}
+
+type scrambler struct {
+	rnd    *rand.Rand
+	hashes map[string]string
+}
+
+func (s *scrambler) enml(content string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range fixtureTagPattern.FindAllStringIndex(content, -1) {
+		b.WriteString(s.text(content[last:loc[0]]))
+		b.WriteString(s.tag(content[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	b.WriteString(s.text(content[last:]))
+	return b.String()
+}
+
+func (s *scrambler) tag(t string) string {
+	if strings.HasPrefix(t, "<?") || strings.HasPrefix(t, "<!") {
+		return t
+	}
+	t = fixtureHashPattern.ReplaceAllStringFunc(t, func(m string) string {
+		p := fixtureHashPattern.FindStringSubmatch(m)
+		return p[1] + s.hash(p[2]) + p[3]
+	})
+	return fixtureAttrPattern.ReplaceAllStringFunc(t, func(m string) string {
+		p := fixtureAttrPattern.FindStringSubmatch(m)
+		v := p[2]
+		scheme := fixtureScheme.FindString(v)
+		return p[1] + scheme + s.text(v[len(scheme):]) + p[3]
+	})
+}
+
+// text scrambles the text but keeps entities.
+func (s *scrambler) text(t string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range fixtureEntity.FindAllStringIndex(t, -1) {
+		b.WriteString(s.chars(t[last:loc[0]]))
+		b.WriteString(t[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(s.chars(t[last:]))
+	return b.String()
+}
+
+func (s *scrambler) chars(t string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsUpper(r):
+			return rune('A' + s.rnd.Intn(26))
+		case unicode.IsLetter(r):
+			return rune('a' + s.rnd.Intn(26))
+		case unicode.IsDigit(r):
+			return rune('0' + s.rnd.Intn(10))
+		}
+		return r
+	}, t)
+}
+
+func (s *scrambler) hash(h string) string {
+	key := strings.ToLower(h)
+	if v, ok := s.hashes[key]; ok {
+		return v
+	}
+	sum := md5.Sum([]byte("clinote fixture " + key))
+	v := hex.EncodeToString(sum[:])
+	s.hashes[key] = v
+	return v
+}
@@ -0,0 +1,113 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureFixture(t *testing.T) {
+	const hash = "0123456789abcdef0123456789abcdef"
+	body := `<div>Meet Alice at 10:30 &amp; bring <a href="https://example.com/Secret">the Plan</a></div><en-media type="image/png" hash="` + hash + `"/>`
+	setup := func() (NotestoreClient, *Note) {
+		note := &Note{GUID: "GUID", Title: "Private note", Notebook: &Notebook{Name: "Work"}, Tags: []string{"secret"}, Created: 1, Updated: 2}
+		ns := nsWithNote(note)
+		ns.getNoteContent = func(string) (string, error) {
+			return XMLHeader + "<en-note>" + body + "</en-note>", nil
+		}
+		ns.getResources = func(string) ([]*Resource, error) {
+			return []*Resource{{Hash: hash, Mime: "image/png", Filename: "photo.png", Size: 3}}, nil
+		}
+		return ns, note
+	}
+
+	t.Run("raw", func(t *testing.T) {
+		assert := assert.New(t)
+		ns, _ := setup()
+		f, err := CaptureFixture(new(mockStore), ns, "Private note", false)
+		assert.NoError(err)
+		assert.False(f.Anonymized)
+		assert.Equal("Private note", f.Title)
+		assert.Equal("Work", f.Notebook)
+		assert.Contains(f.ENML, body)
+		assert.Contains(f.Markdown, "Meet Alice")
+		assert.Equal([]FixtureResource{{Hash: hash, Mime: "image/png", Filename: "photo.png", Size: 3}}, f.Resources)
+	})
+
+	t.Run("anonymized", func(t *testing.T) {
+		assert := assert.New(t)
+		ns, note := setup()
+		f, err := CaptureFixture(new(mockStore), ns, "Private note", true)
+		assert.NoError(err)
+		assert.True(f.Anonymized)
+		assert.Len(f.Title, len("Private note"))
+		assert.NotEqual("Private note", f.Title)
+		assert.NotEqual("Work", f.Notebook)
+		assert.Len(f.Tags[0], len("secret"))
+		assert.Equal([]string{"secret"}, note.Tags, "The note's tags shouldn't be changed")
+		for _, s := range []string{"Alice", "Plan", "Secret", "example", "10:30", hash, "photo"} {
+			assert.NotContains(f.ENML+f.Markdown+f.Resources[0].Filename, s)
+		}
+		assert.True(strings.HasSuffix(f.Resources[0].Filename, ".png"))
+		assert.Contains(f.ENML, `<en-media type="image/png" hash="`+f.Resources[0].Hash+`"/>`)
+		assert.Contains(f.ENML, ` &amp; `)
+		assert.Contains(f.ENML, `<a href="https://`)
+		assert.Contains(f.ENML, XMLHeader)
+
+		ns, _ = setup()
+		again, err := CaptureFixture(new(mockStore), ns, "Private note", true)
+		assert.NoError(err)
+		assert.Equal(f, again, "Scrambling should be deterministic")
+
+		buf := new(bytes.Buffer)
+		assert.NoError(WriteFixture(buf, f))
+		read, err := ReadFixture(buf)
+		assert.NoError(err)
+		assert.Equal(f, read)
+	})
+}
+
+// TestFixtures converts all fixtures in testdata/fixtures and compares the
+// result with the fixture's expected Markdown.
+func TestFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "fixtures", "*.json"))
+	assert.NoError(t, err)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			assert := assert.New(t)
+			r, err := os.Open(file)
+			if !assert.NoError(err) {
+				return
+			}
+			defer r.Close()
+			f, err := ReadFixture(r)
+			if !assert.NoError(err) {
+				return
+			}
+			md, err := f.Convert()
+			assert.NoError(err)
+			assert.Equal(f.Markdown, md)
+		})
+	}
+}
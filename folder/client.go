@@ -0,0 +1,141 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package folder
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/TcM1911/clinote"
+)
+
+var (
+	// ErrNotLoggedIn is returned if no notes folder has been set.
+	ErrNotLoggedIn = errors.New("no notes folder set, use \"clinote user login --folder\"")
+	// ErrNotDirectory is returned if the path isn't a folder.
+	ErrNotDirectory = errors.New("not a folder")
+)
+
+// Client is a note backend for a local folder of Markdown files.
+type Client struct {
+	// Config holds all the configurations.
+	Config clinote.Configuration
+	root   string
+	ns     clinote.NotestoreClient
+	// dryRun is where the changes are written instead of being made.
+	// Nil unless dry run mode is enabled.
+	dryRun io.Writer
+}
+
+// NewClient creates a folder client for the credential. The credential's
+// secret is the path to the notes folder.
+func NewClient(cfg clinote.Configuration, cred *clinote.Credential) *Client {
+	return &Client{Config: cfg, root: cred.Secret}
+}
+
+// GetConfig returns the configuration.
+func (c *Client) GetConfig() clinote.Configuration {
+	return c.Config
+}
+
+// GetNoteStore returns the notestore.
+func (c *Client) GetNoteStore() (clinote.NotestoreClient, error) {
+	if c.ns != nil {
+		return c.ns, nil
+	}
+	ns, err := c.NewNoteStore()
+	if err != nil {
+		return nil, err
+	}
+	c.ns = ns
+	return ns, nil
+}
+
+// NewNoteStore returns a new notestore.
+func (c *Client) NewNoteStore() (clinote.NotestoreClient, error) {
+	if c.root == "" {
+		return nil, ErrNotLoggedIn
+	}
+	if err := checkFolder(c.root); err != nil {
+		return nil, err
+	}
+	var ns clinote.NotestoreClient = NewNotestore(c.root)
+	if clinote.LogEnabled(clinote.LogLevelVerbose) {
+		ns = clinote.NewLoggingNotestore(ns)
+	}
+	ns = clinote.WithAPIBudget(ns)
+	if keys, ok := c.Config.UserStore().(clinote.NotebookKeyStore); ok {
+		ns = clinote.NewEncryptedNotestore(ns, keys)
+	}
+	if c.dryRun != nil {
+		ns = clinote.NewDryRunNotestore(ns, c.dryRun)
+	}
+	return ns, nil
+}
+
+// SetDryRun enables dry run mode. The calls that would change the notes
+// are written to w instead of changing the files.
+func (c *Client) SetDryRun(w io.Writer) {
+	c.dryRun = w
+	if c.ns != nil {
+		c.ns = clinote.NewDryRunNotestore(c.ns, w)
+	}
+}
+
+// Close shuts down the client.
+func (c *Client) Close() error {
+	return c.Config.Close()
+}
+
+func checkFolder(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrNotDirectory
+	}
+	return nil
+}
+
+// Login saves the folder as a new credential that is made active.
+func Login(cfg clinote.Configuration, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err = checkFolder(dir); err != nil {
+		return err
+	}
+	cred := &clinote.Credential{
+		Name:     filepath.Base(dir),
+		Secret:   dir,
+		CredType: clinote.FolderCredential,
+	}
+	if err = cfg.UserStore().Add(cred); err != nil {
+		return err
+	}
+	creds, err := clinote.GetAllCredentials(cfg.UserStore())
+	if err != nil {
+		return err
+	}
+	_, err = clinote.ActivateCredential(cfg.UserStore(), cfg.Store(), len(creds)-1)
+	return err
+}
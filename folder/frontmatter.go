@@ -0,0 +1,202 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package folder
+
+import (
+	"bufio"
+	"strings"
+	"time"
+)
+
+const frontMatterDelim = "---"
+
+// frontMatter is the metadata in the YAML front matter of a note file.
+// Only simple "key: value" entries are parsed. Entries that aren't used by
+// CLInote are kept as is when the file is written.
+type frontMatter struct {
+	// Title is set if the title can't be used as the filename.
+	Title  string
+	Tags   []string
+	Source string
+	Author string
+	// Created is when the note was created. The date entry is used if
+	// there's no created entry.
+	Created time.Time
+	// hasDate is true if Created is read from the date entry.
+	hasDate bool
+	// extra is the lines of the other entries.
+	extra []string
+}
+
+// splitFrontMatter returns the front matter and the Markdown content of
+// the file.
+func splitFrontMatter(data string) (*frontMatter, string) {
+	fm := new(frontMatter)
+	if !strings.HasPrefix(data, frontMatterDelim+"\n") && !strings.HasPrefix(data, frontMatterDelim+"\r\n") {
+		return fm, data
+	}
+	rest := data[strings.Index(data, "\n")+1:]
+	offset := 0
+	for _, line := range strings.SplitAfter(rest, "\n") {
+		if strings.TrimRight(line, "\r\n") == frontMatterDelim {
+			fm.parse(rest[:offset])
+			return fm, strings.TrimLeft(rest[offset+len(line):], "\r\n")
+		}
+		offset += len(line)
+	}
+	return fm, data
+}
+
+func (fm *frontMatter) parse(s string) {
+	var key string
+	var lines []string
+	flush := func() {
+		switch key {
+		case "":
+		case "title":
+			fm.Title = unquote(value(lines[0]))
+		case "tags":
+			fm.Tags = parseList(lines)
+		case "source", "source_url":
+			fm.Source = unquote(value(lines[0]))
+		case "author":
+			fm.Author = unquote(value(lines[0]))
+		case "created":
+			fm.Created = parseTime(unquote(value(lines[0])))
+		default:
+			if key == "date" && fm.Created.IsZero() {
+				fm.Created = parseTime(unquote(value(lines[0])))
+				fm.hasDate = !fm.Created.IsZero()
+			}
+			fm.extra = append(fm.extra, lines...)
+		}
+		key, lines = "", nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' && line[0] != '-' && line[0] != '#' {
+			flush()
+			if i := strings.Index(line, ":"); i > 0 {
+				key = strings.ToLower(strings.TrimSpace(line[:i]))
+			} else {
+				key = line
+			}
+		}
+		if key == "" {
+			fm.extra = append(fm.extra, line)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+}
+
+// value returns the value of the "key: value" line.
+func value(line string) string {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(line[i+1:])
+}
+
+// parseList parses a list written as "[a, b]", "a, b" or as "- a" lines.
+func parseList(lines []string) []string {
+	var items []string
+	add := func(s string) {
+		if s = unquote(strings.TrimSpace(s)); s != "" {
+			items = append(items, s)
+		}
+	}
+	v := strings.TrimSuffix(strings.TrimPrefix(value(lines[0]), "["), "]")
+	for _, s := range strings.Split(v, ",") {
+		add(s)
+	}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-") {
+			add(line[1:])
+		}
+	}
+	return items
+}
+
+func unquote(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	switch {
+	case s[0] == '"' && s[len(s)-1] == '"':
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[1 : len(s)-1])
+	case s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1)
+	}
+	return s
+}
+
+func parseTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// quote quotes the value if it could be read as something other than a
+// string.
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, `:#[]{},"'&*!|>%@`+"`") || strings.TrimSpace(s) != s || strings.HasPrefix(s, "-") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	return s
+}
+
+// String returns the front matter with the delimiters, or an empty string
+// if there's no metadata.
+func (fm *frontMatter) String() string {
+	var lines []string
+	if fm.Title != "" {
+		lines = append(lines, "title: "+quote(fm.Title))
+	}
+	if !fm.Created.IsZero() && !fm.hasDate {
+		lines = append(lines, "created: "+fm.Created.Format(time.RFC3339))
+	}
+	if len(fm.Tags) != 0 {
+		tags := make([]string, len(fm.Tags))
+		for i, t := range fm.Tags {
+			tags[i] = quote(t)
+		}
+		lines = append(lines, "tags: ["+strings.Join(tags, ", ")+"]")
+	}
+	if fm.Source != "" {
+		lines = append(lines, "source: "+quote(fm.Source))
+	}
+	if fm.Author != "" {
+		lines = append(lines, "author: "+quote(fm.Author))
+	}
+	lines = append(lines, fm.extra...)
+	if len(lines) == 0 {
+		return ""
+	}
+	return frontMatterDelim + "\n" + strings.Join(lines, "\n") + "\n" + frontMatterDelim + "\n"
+}
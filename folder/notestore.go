@@ -0,0 +1,728 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package folder
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/markdown"
+)
+
+const (
+	// NoteExt is the file extension of note files.
+	NoteExt = ".md"
+	// TrashDir is the folder deleted notes are moved to.
+	TrashDir = ".trash"
+	// rootNotebook is the GUID of the notebook for the notes in the
+	// top folder.
+	rootNotebook = "."
+)
+
+var (
+	// ErrNotebookNotFound is returned if the notebook's folder doesn't exist.
+	ErrNotebookNotFound = errors.New("notebook folder not found")
+	// ErrOutsideFolder is returned for paths outside of the notes folder.
+	ErrOutsideFolder = errors.New("path is outside of the notes folder")
+
+	enNotePattern  = regexp.MustCompile(`(?s)<en-note[^>]*>(.*)</en-note>`)
+	enMediaPattern = regexp.MustCompile(`(?s)<en-media[^>]*/>|<en-media[^>]*>.*?</en-media>`)
+	hashPattern    = regexp.MustCompile(`hash="([^"]*)"`)
+	linkPattern    = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	unsafeChars    = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]`)
+)
+
+// Notestore is a notestore for a folder of Markdown files. Each file is a
+// note with the filename as the title and the metadata in the front
+// matter. The subfolders are notebooks, and the top level folders of
+// nested folders are stacks. The notes in the top folder are in a notebook
+// named after the folder. Note and notebook GUIDs are the paths relative
+// to the top folder, so they change when a note or notebook is renamed.
+// Hidden files and folders are ignored.
+type Notestore struct {
+	root string
+}
+
+// NewNotestore returns a notestore for the folder.
+func NewNotestore(root string) *Notestore {
+	return &Notestore{root: root}
+}
+
+// path returns the file path for the GUID.
+func (s *Notestore) path(guid string) (string, error) {
+	p := path.Clean(guid)
+	if p == ".." || strings.HasPrefix(p, "../") || path.IsAbs(p) {
+		return "", ErrOutsideFolder
+	}
+	return filepath.Join(s.root, filepath.FromSlash(p)), nil
+}
+
+// walk returns the GUIDs of all notes and notebooks.
+func (s *Notestore) walk() (notes, books []string, err error) {
+	books = []string{rootNotebook}
+	err = filepath.Walk(s.root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == s.root {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			books = append(books, rel)
+		} else if strings.EqualFold(filepath.Ext(p), NoteExt) {
+			notes = append(notes, rel)
+		}
+		return nil
+	})
+	return notes, books, err
+}
+
+// readNote reads the note file.
+func (s *Notestore) readNote(guid string) (*clinote.Note, *frontMatter, string, error) {
+	p, err := s.path(guid)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	fm, md := splitFrontMatter(string(data))
+	title := fm.Title
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(guid), path.Ext(guid))
+	}
+	n := &clinote.Note{
+		Title:     title,
+		GUID:      guid,
+		Notebook:  s.notebook(path.Dir(guid)),
+		Updated:   toMillis(info.ModTime()),
+		Tags:      fm.Tags,
+		SourceURL: fm.Source,
+		Author:    fm.Author,
+	}
+	n.Created = n.Updated
+	if !fm.Created.IsZero() {
+		n.Created = toMillis(fm.Created)
+	}
+	return n, fm, md, nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func (s *Notestore) notebook(guid string) *clinote.Notebook {
+	b := &clinote.Notebook{GUID: guid, Name: path.Base(guid)}
+	if guid == rootNotebook {
+		b.Name = filepath.Base(s.root)
+	}
+	if i := strings.Index(guid, "/"); i > 0 {
+		b.Stack = guid[:i]
+	}
+	return b
+}
+
+// FindNotes searches for notes. All the search words have to be in the
+// title or the content. Quoted phrases, "-" to exclude words and the
+// intitle: and tag: operators are supported.
+func (s *Notestore) FindNotes(filter *clinote.NoteFilter, offset, count int) ([]*clinote.Note, error) {
+	guids, _, err := s.walk()
+	if err != nil {
+		return nil, err
+	}
+	terms := parseQuery(filter.Words)
+	var notes []*clinote.Note
+	for _, guid := range guids {
+		if filter.NotebookGUID != "" && path.Dir(guid) != filter.NotebookGUID {
+			continue
+		}
+		n, _, md, err := s.readNote(guid)
+		if err != nil {
+			return nil, err
+		}
+		if matchTerms(terms, n, md) {
+			notes = append(notes, n)
+		}
+	}
+	sortNotes(notes, filter.Order)
+	if offset >= len(notes) {
+		return nil, nil
+	}
+	notes = notes[offset:]
+	if len(notes) > count {
+		notes = notes[:count]
+	}
+	return notes, nil
+}
+
+func sortNotes(notes []*clinote.Note, order int32) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		switch order {
+		case clinote.NoteFilterOrderCreated:
+			return a.Created > b.Created
+		case clinote.NoteFilterOrderTitle:
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		}
+		return a.Updated > b.Updated
+	})
+}
+
+type term struct {
+	op      string
+	value   string
+	exclude bool
+}
+
+// parseQuery splits the search string into terms.
+func parseQuery(q string) []term {
+	var terms []term
+	for q = strings.TrimSpace(q); q != ""; q = strings.TrimSpace(q) {
+		var t term
+		if q[0] == '-' {
+			t.exclude = true
+			q = q[1:]
+		}
+		if i := strings.IndexAny(q, ": \""); i > 0 && q[i] == ':' {
+			switch op := strings.ToLower(q[:i]); op {
+			case "intitle", "tag", "notebook", "created", "updated", "resource":
+				t.op = op
+				q = q[i+1:]
+			}
+		}
+		if strings.HasPrefix(q, `"`) {
+			end := strings.Index(q[1:], `"`)
+			if end < 0 {
+				end = len(q) - 1
+			}
+			t.value, q = q[1:end+1], q[min(end+2, len(q)):]
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			t.value, q = q[:end], q[end:]
+		}
+		if t.value != "" {
+			t.value = strings.ToLower(t.value)
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func matchTerms(terms []term, n *clinote.Note, md string) bool {
+	title, content := strings.ToLower(n.Title), strings.ToLower(md)
+	for _, t := range terms {
+		var found bool
+		switch t.op {
+		case "intitle":
+			found = strings.Contains(title, t.value)
+		case "tag":
+			for _, tag := range n.Tags {
+				if matchWildcard(t.value, strings.ToLower(tag)) {
+					found = true
+				}
+			}
+		case "notebook":
+			found = n.Notebook != nil && (strings.ToLower(n.Notebook.Name) == t.value || strings.ToLower(n.Notebook.GUID) == t.value)
+		case "created", "updated":
+			when := n.Created
+			if t.op == "updated" {
+				when = n.Updated
+			}
+			date, err := clinote.ParseSearchDate(t.value, time.Now())
+			if err != nil {
+				return false
+			}
+			found = when >= toMillis(date)
+		case "resource":
+			for _, mimeType := range linkedMimeTypes(md) {
+				if matchWildcard(t.value, mimeType) {
+					found = true
+				}
+			}
+		default:
+			found = strings.Contains(title, t.value) || strings.Contains(content, t.value)
+		}
+		if found == t.exclude {
+			return false
+		}
+	}
+	return true
+}
+
+// linkedMimeTypes returns the mime types of the files linked from the note,
+// from their extensions.
+func linkedMimeTypes(md string) []string {
+	var types []string
+	for _, m := range linkPattern.FindAllStringSubmatch(md, -1) {
+		u, err := url.Parse(m[1])
+		if err != nil || u.Scheme != "" || u.Host != "" || strings.EqualFold(path.Ext(u.Path), NoteExt) {
+			continue
+		}
+		if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+			types = append(types, strings.ToLower(strings.SplitN(t, ";", 2)[0]))
+		}
+	}
+	return types
+}
+
+// matchWildcard returns true if the value equals the pattern, or starts
+// with the pattern if it ends with *.
+func matchWildcard(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+	return value == pattern
+}
+
+// GetNoteContent returns the note's content as ENML.
+func (s *Notestore) GetNoteContent(guid string) (string, error) {
+	_, _, md, err := s.readNote(guid)
+	if err != nil {
+		return "", err
+	}
+	return clinote.XMLHeader + "<en-note>" + string(markdown.ToXML(md)) + "</en-note>", nil
+}
+
+// CreateNote creates a note file in the notebook's folder. The resources
+// are saved next to the note and linked from the content.
+func (s *Notestore) CreateNote(n *clinote.Note) error {
+	dir := rootNotebook
+	if n.Notebook != nil && n.Notebook.GUID != "" {
+		dir = n.Notebook.GUID
+	}
+	if err := s.checkNotebook(dir); err != nil {
+		return err
+	}
+	body := n.Body
+	for _, r := range n.Resources {
+		name, err := s.saveResource(dir, r)
+		if err != nil {
+			return err
+		}
+		body = linkResource(body, r, name)
+	}
+	md, err := toMarkdown(body)
+	if err != nil {
+		return err
+	}
+	fm := &frontMatter{Tags: n.Tags, Source: n.SourceURL, Author: n.Author, Created: time.Now()}
+	if n.Created != 0 {
+		fm.Created = time.Unix(0, n.Created*int64(time.Millisecond))
+	}
+	guid, err := s.notePath(dir, n.Title, "", fm)
+	if err != nil {
+		return err
+	}
+	if err = s.writeNote(guid, fm, md); err != nil {
+		return err
+	}
+	n.GUID = guid
+	return nil
+}
+
+// UpdateNote updates the note file. An empty body leaves the content
+// unchanged. The file is renamed if the title is changed and moved if the
+// notebook is changed. Changing the resources isn't supported.
+func (s *Notestore) UpdateNote(n *clinote.Note) error {
+	if n.Resources != nil {
+		return clinote.ErrNotSupported
+	}
+	_, fm, md, err := s.readNote(n.GUID)
+	if err != nil {
+		return err
+	}
+	if n.Body != "" {
+		if md, err = toMarkdown(n.Body); err != nil {
+			return err
+		}
+	}
+	dir := path.Dir(n.GUID)
+	if n.Notebook != nil && n.Notebook.GUID != "" && n.Notebook.GUID != dir {
+		dir = n.Notebook.GUID
+		if err = s.checkNotebook(dir); err != nil {
+			return err
+		}
+	}
+	if n.Tags != nil {
+		fm.Tags = n.Tags
+	}
+	fm.Source, fm.Author = n.SourceURL, n.Author
+	if n.Created != 0 && toMillis(fm.Created) != n.Created {
+		fm.Created = time.Unix(0, n.Created*int64(time.Millisecond))
+		fm.hasDate = false
+	}
+	guid, err := s.notePath(dir, n.Title, n.GUID, fm)
+	if err != nil {
+		return err
+	}
+	if err = s.writeNote(guid, fm, md); err != nil {
+		return err
+	}
+	if guid != n.GUID {
+		old, _ := s.path(n.GUID)
+		if err = os.Remove(old); err != nil {
+			return err
+		}
+		n.GUID = guid
+	}
+	return nil
+}
+
+func (s *Notestore) checkNotebook(guid string) error {
+	p, err := s.path(guid)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(p); err != nil || !info.IsDir() {
+		return ErrNotebookNotFound
+	}
+	return nil
+}
+
+// notePath returns the GUID for a note with the title in the folder. A
+// number is added to the name if another file has it. The title is saved
+// in the front matter if it isn't the filename.
+func (s *Notestore) notePath(dir, title, current string, fm *frontMatter) (string, error) {
+	name := safeName(title)
+	if name == "" {
+		name = "Untitled"
+	}
+	for i := 1; ; i++ {
+		base := name
+		if i > 1 {
+			base = fmt.Sprintf("%s (%d)", name, i)
+		}
+		guid := path.Join(dir, base+NoteExt)
+		p, err := s.path(guid)
+		if err != nil {
+			return "", err
+		}
+		if _, err = os.Stat(p); guid == current || os.IsNotExist(err) {
+			fm.Title = ""
+			if base != title {
+				fm.Title = title
+			}
+			return guid, nil
+		}
+	}
+}
+
+// safeName returns the name with the characters that can't be used in
+// filenames replaced.
+func safeName(name string) string {
+	name = unsafeChars.ReplaceAllString(name, "-")
+	return strings.TrimLeft(strings.TrimSpace(strings.TrimRight(name, ". ")), ".")
+}
+
+func (s *Notestore) writeNote(guid string, fm *frontMatter, md string) error {
+	p, err := s.path(guid)
+	if err != nil {
+		return err
+	}
+	md = strings.TrimRight(md, "\n") + "\n"
+	return ioutil.WriteFile(p, []byte(fm.String()+md), 0644)
+}
+
+// toMarkdown converts the ENML body to Markdown.
+func toMarkdown(body string) (string, error) {
+	if m := enNotePattern.FindStringSubmatch(body); m != nil {
+		body = m[1]
+	}
+	return markdown.FromHTML(body)
+}
+
+// saveResource saves the resource's data in the folder and returns the
+// filename. A file with the same content is reused.
+func (s *Notestore) saveResource(dir string, r *clinote.Resource) (string, error) {
+	name := safeName(r.Filename)
+	if name == "" {
+		name = "attachment"
+		if exts, _ := mime.ExtensionsByType(r.Mime); len(exts) != 0 {
+			name += exts[0]
+		}
+	}
+	ext := filepath.Ext(name)
+	for i := 1; ; i++ {
+		candidate := name
+		if i > 1 {
+			candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+		}
+		p, err := s.path(path.Join(dir, candidate))
+		if err != nil {
+			return "", err
+		}
+		existing, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			return candidate, ioutil.WriteFile(p, r.Data, 0644)
+		}
+		if err == nil && bytes.Equal(existing, r.Data) {
+			return candidate, nil
+		}
+	}
+}
+
+// linkResource replaces the en-media elements referencing the resource
+// with links to the file.
+func linkResource(body string, r *clinote.Resource, name string) string {
+	target := (&url.URL{Path: name}).String()
+	return enMediaPattern.ReplaceAllStringFunc(body, func(elem string) string {
+		m := hashPattern.FindStringSubmatch(elem)
+		if m == nil || !strings.EqualFold(m[1], r.Hash) {
+			return elem
+		}
+		if strings.HasPrefix(r.Mime, "image/") {
+			return fmt.Sprintf(`<img src="%s" alt="%s"/>`, escapeAttr(target), escapeAttr(name))
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, escapeAttr(target), escapeAttr(name))
+	})
+}
+
+func escapeAttr(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+// DeleteNote moves the note file to the trash folder.
+func (s *Notestore) DeleteNote(guid string) error {
+	p, err := s.path(guid)
+	if err != nil {
+		return err
+	}
+	trashed := filepath.Join(s.root, TrashDir, filepath.FromSlash(path.Clean(guid)))
+	if _, err = os.Stat(trashed); err == nil {
+		trashed = strings.TrimSuffix(trashed, NoteExt) + " " + strconv.FormatInt(time.Now().Unix(), 10) + NoteExt
+	}
+	if err = os.MkdirAll(filepath.Dir(trashed), 0755); err != nil {
+		return err
+	}
+	return os.Rename(p, trashed)
+}
+
+// GetAllNotebooks returns the top folder and all subfolders as notebooks.
+func (s *Notestore) GetAllNotebooks() ([]*clinote.Notebook, error) {
+	_, guids, err := s.walk()
+	if err != nil {
+		return nil, err
+	}
+	books := make([]*clinote.Notebook, len(guids))
+	for i, guid := range guids {
+		books[i] = s.notebook(guid)
+	}
+	return books, nil
+}
+
+// GetNotebook returns the folder as a notebook.
+func (s *Notestore) GetNotebook(guid string) (*clinote.Notebook, error) {
+	if err := s.checkNotebook(guid); err != nil {
+		return nil, err
+	}
+	return s.notebook(path.Clean(guid)), nil
+}
+
+// CreateNotebook creates a folder for the notebook, in the stack's folder
+// if the notebook is in a stack. There's no default notebook so
+// defaultNotebook is ignored.
+func (s *Notestore) CreateNotebook(b *clinote.Notebook, defaultNotebook bool) error {
+	guid := notebookPath(b)
+	p, err := s.path(guid)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	if err = os.Mkdir(p, 0755); err != nil {
+		return err
+	}
+	b.GUID = guid
+	return nil
+}
+
+// UpdateNotebook renames the notebook's folder and moves it to the stack's
+// folder.
+func (s *Notestore) UpdateNotebook(b *clinote.Notebook) error {
+	if path.Clean(b.GUID) == rootNotebook {
+		return clinote.ErrNotSupported
+	}
+	old, err := s.path(b.GUID)
+	if err != nil {
+		return err
+	}
+	guid := notebookPath(b)
+	if guid == b.GUID {
+		return nil
+	}
+	p, err := s.path(guid)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	if err = os.Rename(old, p); err != nil {
+		return err
+	}
+	b.GUID = guid
+	return nil
+}
+
+func notebookPath(b *clinote.Notebook) string {
+	name := safeName(b.Name)
+	if b.Stack == "" {
+		return name
+	}
+	return path.Join(safeName(b.Stack), name)
+}
+
+// GetSyncState returns the state of the folder. There's no update count so
+// a checksum of the file names and modification times is used instead.
+func (s *Notestore) GetSyncState() (*clinote.SyncState, error) {
+	notes, books, err := s.walk()
+	if err != nil {
+		return nil, err
+	}
+	h := fnv.New32a()
+	for _, guid := range append(books, notes...) {
+		p, _ := s.path(guid)
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", guid, info.ModTime().UnixNano(), info.Size())
+	}
+	return &clinote.SyncState{UpdateCount: int32(h.Sum32() & 0x7fffffff), Time: time.Now()}, nil
+}
+
+// GetNoteResources returns the files in the notes folder that the note
+// links to, including the data.
+func (s *Notestore) GetNoteResources(guid string) ([]*clinote.Resource, error) {
+	_, _, md, err := s.readNote(guid)
+	if err != nil {
+		return nil, err
+	}
+	var resources []*clinote.Resource
+	seen := make(map[string]bool)
+	for _, m := range linkPattern.FindAllStringSubmatch(md, -1) {
+		u, err := url.Parse(m[1])
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+			continue
+		}
+		rel := path.Join(path.Dir(guid), u.Path)
+		if seen[rel] || strings.EqualFold(path.Ext(rel), NoteExt) {
+			continue
+		}
+		seen[rel] = true
+		p, err := s.path(rel)
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		sum := md5.Sum(data)
+		resources = append(resources, &clinote.Resource{
+			GUID:     rel,
+			Hash:     hex.EncodeToString(sum[:]),
+			Mime:     mimeType(rel, data),
+			Filename: path.Base(rel),
+			Size:     len(data),
+			Data:     data,
+		})
+	}
+	return resources, nil
+}
+
+func mimeType(name string, data []byte) string {
+	t := mime.TypeByExtension(path.Ext(name))
+	if t == "" {
+		t = http.DetectContentType(data)
+	}
+	if mediaType, _, err := mime.ParseMediaType(t); err == nil {
+		return mediaType
+	}
+	return t
+}
+
+// GetAllTags returns the tags used by the notes. The tag names are used as
+// the GUIDs.
+func (s *Notestore) GetAllTags() ([]*clinote.Tag, error) {
+	guids, _, err := s.walk()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var tags []*clinote.Tag
+	for _, guid := range guids {
+		n, _, _, err := s.readNote(guid)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range n.Tags {
+			if !seen[strings.ToLower(t)] {
+				seen[strings.ToLower(t)] = true
+				tags = append(tags, &clinote.Tag{GUID: t, Name: t})
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name) })
+	return tags, nil
+}
@@ -0,0 +1,233 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package folder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupFolder(t *testing.T, files map[string]string) (*Notestore, func()) {
+	dir, err := ioutil.TempDir("", "clinote-folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewNotestore(dir), func() { os.RemoveAll(dir) }
+}
+
+func TestFrontMatter(t *testing.T) {
+	assert := assert.New(t)
+	data := "---\ntitle: \"Plan: Q3\"\ntags:\n  - work\n  - 'q3'\nlayout: post\ndate: 2018-03-01\nauthor: Joe\n---\n\n# Plan\n"
+
+	fm, md := splitFrontMatter(data)
+
+	assert.Equal("# Plan\n", md)
+	assert.Equal("Plan: Q3", fm.Title)
+	assert.Equal([]string{"work", "q3"}, fm.Tags)
+	assert.Equal("Joe", fm.Author)
+	assert.Equal(time.Date(2018, 3, 1, 0, 0, 0, 0, time.Local), fm.Created)
+	assert.Equal("---\ntitle: \"Plan: Q3\"\ntags: [work, q3]\nauthor: Joe\nlayout: post\ndate: 2018-03-01\n---\n", fm.String(), "Other entries should be kept and the date not duplicated")
+
+	fm, md = splitFrontMatter("---\nNo end\n")
+	assert.Equal("---\nNo end\n", md)
+	assert.Equal("", fm.String())
+}
+
+func TestFindNotes(t *testing.T) {
+	ns, cleanup := setupFolder(t, map[string]string{
+		"Inbox.md":             "Buy milk",
+		"Work/Plan.md":         "---\ntags: [work, urgent]\ncreated: 2018-03-01T10:00:00Z\n---\nMeet Bob on Monday",
+		"Work/Notes.md":        "Monday standup ![board](image.png)",
+		"Work/image.png":       "PNG",
+		".git/HEAD.md":         "hidden",
+		"Projects/Alpha/A.md":  "Spec",
+		"Projects/Alpha/b.txt": "Not a note",
+	})
+	defer cleanup()
+
+	t.Run("all notes", func(t *testing.T) {
+		assert := assert.New(t)
+		notes, err := ns.FindNotes(&clinote.NoteFilter{Order: clinote.NoteFilterOrderTitle}, 0, 10)
+		assert.NoError(err)
+		var titles []string
+		for _, n := range notes {
+			titles = append(titles, n.Title)
+		}
+		assert.Equal([]string{"A", "Inbox", "Notes", "Plan"}, titles)
+		assert.Equal("Projects/Alpha", notes[0].Notebook.GUID)
+		assert.Equal("Projects", notes[0].Notebook.Stack)
+		assert.Equal("Work/Plan.md", notes[3].GUID)
+		assert.Equal([]string{"work", "urgent"}, notes[3].Tags)
+		assert.Equal(int64(1519898400000), notes[3].Created)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		assert := assert.New(t)
+		for query, expected := range map[string]int{
+			"monday":                    2,
+			"monday -bob":               1,
+			`"meet bob"`:                1,
+			"tag:work":                  1,
+			"tag:wor*":                  1,
+			"intitle:plan":              1,
+			"intitle:monday":            0,
+			"milk":                      1,
+			"-monday -milk":             1,
+			"monday tag:work":           1,
+			"notebook:work":             2,
+			"-notebook:Work":            2,
+			"created:20180101":          4,
+			"-created:20180401":         1,
+			"-created:20180301T090000Z": 0,
+			"updated:day-1":             4,
+			"resource:image/*":          1,
+			"resource:image/png":        1,
+			"resource:application/pdf":  0,
+			"created:someday":           0,
+		} {
+			notes, err := ns.FindNotes(&clinote.NoteFilter{Words: query}, 0, 10)
+			assert.NoError(err)
+			assert.Len(notes, expected, query)
+		}
+	})
+
+	t.Run("notebook and paging", func(t *testing.T) {
+		assert := assert.New(t)
+		notes, err := ns.FindNotes(&clinote.NoteFilter{NotebookGUID: "Work", Order: clinote.NoteFilterOrderTitle}, 1, 10)
+		assert.NoError(err)
+		if assert.Len(notes, 1) {
+			assert.Equal("Plan", notes[0].Title)
+		}
+	})
+
+	t.Run("notebooks and tags", func(t *testing.T) {
+		assert := assert.New(t)
+		books, err := ns.GetAllNotebooks()
+		assert.NoError(err)
+		assert.Len(books, 4)
+		assert.Equal(rootNotebook, books[0].GUID)
+		assert.Equal(filepath.Base(ns.root), books[0].Name)
+		tags, err := ns.GetAllTags()
+		assert.NoError(err)
+		assert.Equal([]*clinote.Tag{{GUID: "urgent", Name: "urgent"}, {GUID: "work", Name: "work"}}, tags)
+	})
+
+	t.Run("outside of the folder", func(t *testing.T) {
+		_, err := ns.GetNoteContent("../secret.md")
+		assert.Equal(t, ErrOutsideFolder, err)
+	})
+}
+
+func TestCreateAndUpdateNote(t *testing.T) {
+	assert := assert.New(t)
+	ns, cleanup := setupFolder(t, map[string]string{
+		"Work/Plan.md": "---\nlayout: post\n---\nOld",
+	})
+	defer cleanup()
+	read := func(guid string) string {
+		data, _ := ioutil.ReadFile(filepath.Join(ns.root, filepath.FromSlash(guid)))
+		return string(data)
+	}
+
+	n := &clinote.Note{
+		Title:     "Plan",
+		Notebook:  &clinote.Notebook{GUID: "Work"},
+		Body:      clinote.XMLHeader + `<en-note><p>New <b>plan</b></p><en-media type="image/png" hash="` + "7f0c16fdc4c2cc3ed7df3e3a9b1ff35b" + `"/></en-note>`,
+		Tags:      []string{"work"},
+		Created:   1519898400000,
+		Resources: []*clinote.Resource{{Hash: "7f0c16fdc4c2cc3ed7df3e3a9b1ff35b", Mime: "image/png", Filename: "chart 1.png", Data: []byte("PNG")}},
+	}
+	assert.NoError(ns.CreateNote(n))
+	assert.Equal("Work/Plan (2).md", n.GUID, "The existing note shouldn't be overwritten")
+	created := time.Unix(1519898400, 0).Format(time.RFC3339)
+	assert.Equal("---\ntitle: Plan\ncreated: "+created+"\ntags: [work]\n---\nNew **plan**\n\n![chart 1.png](chart%201.png)\n", read(n.GUID))
+	assert.Equal("PNG", read("Work/chart 1.png"))
+
+	resources, err := ns.GetNoteResources(n.GUID)
+	assert.NoError(err)
+	if assert.Len(resources, 1) {
+		assert.Equal("Work/chart 1.png", resources[0].GUID)
+		assert.Equal("image/png", resources[0].Mime)
+		assert.Equal([]byte("PNG"), resources[0].Data)
+	}
+
+	content, err := ns.GetNoteContent("Work/Plan.md")
+	assert.NoError(err)
+	assert.Contains(content, "<p>Old</p>")
+
+	update := &clinote.Note{GUID: "Work/Plan.md", Title: "Plan/Q3", Notebook: &clinote.Notebook{GUID: rootNotebook}, Tags: []string{"q3"}}
+	assert.NoError(ns.UpdateNote(update))
+	assert.Equal("Plan-Q3.md", update.GUID)
+	assert.Equal("---\ntitle: Plan/Q3\ntags: [q3]\nlayout: post\n---\nOld\n", read(update.GUID), "The content should be kept when no body is given")
+	_, err = os.Stat(filepath.Join(ns.root, "Work", "Plan.md"))
+	assert.True(os.IsNotExist(err), "The old file should be removed")
+
+	update.Resources = []*clinote.Resource{}
+	assert.Equal(clinote.ErrNotSupported, ns.UpdateNote(update))
+
+	assert.Equal(ErrNotebookNotFound, ns.CreateNote(&clinote.Note{Title: "X", Notebook: &clinote.Notebook{GUID: "Missing"}}))
+
+	assert.NoError(ns.DeleteNote(update.GUID))
+	assert.Equal("---\ntitle: Plan/Q3\ntags: [q3]\nlayout: post\n---\nOld\n", read(TrashDir+"/Plan-Q3.md"))
+}
+
+func TestNotebooksAndSyncState(t *testing.T) {
+	assert := assert.New(t)
+	ns, cleanup := setupFolder(t, map[string]string{"Work/Plan.md": "Plan"})
+	defer cleanup()
+
+	before, err := ns.GetSyncState()
+	assert.NoError(err)
+
+	b := &clinote.Notebook{Name: "Alpha", Stack: "Projects"}
+	assert.NoError(ns.CreateNotebook(b, false))
+	assert.Equal("Projects/Alpha", b.GUID)
+	got, err := ns.GetNotebook("Projects/Alpha")
+	assert.NoError(err)
+	assert.Equal(&clinote.Notebook{GUID: "Projects/Alpha", Name: "Alpha", Stack: "Projects"}, got)
+
+	after, err := ns.GetSyncState()
+	assert.NoError(err)
+	assert.NotEqual(before.UpdateCount, after.UpdateCount)
+
+	work := &clinote.Notebook{GUID: "Work", Name: "Job"}
+	assert.NoError(ns.UpdateNotebook(work))
+	assert.Equal("Job", work.GUID)
+	notes, err := ns.FindNotes(&clinote.NoteFilter{NotebookGUID: "Job"}, 0, 10)
+	assert.NoError(err)
+	assert.Len(notes, 1)
+
+	assert.Equal(clinote.ErrNotSupported, ns.UpdateNotebook(&clinote.Notebook{GUID: rootNotebook, Name: "Other"}))
+	_, err = ns.GetNotebook("Missing")
+	assert.Equal(ErrNotebookNotFound, err)
+}
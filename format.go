@@ -0,0 +1,249 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Formatter converts the content of notes between Markdown and the text
+// format notes are shown and edited in. The content is converted between
+// ENML and Markdown by the markdown package, so formatters only convert
+// the Markdown.
+type Formatter interface {
+	// Name is the name of the format used by the settings and the
+	// --format flag.
+	Name() string
+	// Extension is the extension, with the dot, of files in the format.
+	Extension() string
+	// FromMarkdown converts Markdown to the format.
+	FromMarkdown(md string) string
+	// ToMarkdown converts text in the format to Markdown.
+	ToMarkdown(text string) string
+}
+
+// The names of the formats.
+const (
+	FormatMarkdown = "markdown"
+	FormatOrg      = "org"
+	FormatAsciiDoc = "asciidoc"
+)
+
+// ErrUnknownFormat is returned if there's no formatter with the name.
+var ErrUnknownFormat = errors.New("unknown format, the formats are markdown, org and asciidoc")
+
+// Formatters are the formats notes can be shown and edited in.
+var Formatters = map[string]Formatter{
+	FormatMarkdown: markdownFormatter{},
+	FormatOrg:      orgFormatter{},
+	FormatAsciiDoc: asciidocFormatter{},
+}
+
+var formatAliases = map[string]string{"md": FormatMarkdown, "adoc": FormatAsciiDoc}
+
+// NoteFormat is the format notes are shown and edited in. It's set from
+// the note.format setting or the --format flag.
+var NoteFormat Formatter = Formatters[FormatMarkdown]
+
+// ParseFormat validates the format name. The file extensions md and adoc
+// can be used as names. An empty name is Markdown.
+func ParseFormat(s string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return FormatMarkdown, nil
+	}
+	if alias, ok := formatAliases[name]; ok {
+		name = alias
+	}
+	if _, ok := Formatters[name]; !ok {
+		return "", ErrUnknownFormat
+	}
+	return name, nil
+}
+
+// SetNoteFormat sets the format notes are shown and edited in.
+func SetNoteFormat(name string) error {
+	name, err := ParseFormat(name)
+	if err != nil {
+		return err
+	}
+	NoteFormat = Formatters[name]
+	return nil
+}
+
+// isMarkdownFormat returns true if notes are shown and edited as Markdown.
+func isMarkdownFormat() bool {
+	return NoteFormat == nil || NoteFormat.Name() == FormatMarkdown
+}
+
+// formatContent converts the Markdown to the note format.
+func formatContent(md string) string {
+	if isMarkdownFormat() {
+		return md
+	}
+	return NoteFormat.FromMarkdown(md)
+}
+
+// parseFormattedContent converts text in the note format to Markdown.
+func parseFormattedContent(text string) string {
+	if isMarkdownFormat() {
+		return text
+	}
+	return NoteFormat.ToMarkdown(text)
+}
+
+type markdownFormatter struct{}
+
+func (markdownFormatter) Name() string                  { return FormatMarkdown }
+func (markdownFormatter) Extension() string             { return ".md" }
+func (markdownFormatter) FromMarkdown(md string) string { return md }
+func (markdownFormatter) ToMarkdown(text string) string { return text }
+
+// The Markdown syntax shared by the formatters.
+var (
+	mdHeading      = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	mdFence        = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([^\\s`]*)")
+	mdBullet       = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdNumbered     = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdRule         = regexp.MustCompile(`^\s{0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdQuote        = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	mdTableRow     = regexp.MustCompile(`^\s*\|`)
+	mdTableSep     = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdCodeSpan     = regexp.MustCompile("`+[^`]+`+")
+	mdImage        = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	mdLink         = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdAutoLink     = regexp.MustCompile(`<((?:https?|mailto|ftp):[^>\s]+)>`)
+	mdBold         = regexp.MustCompile(`\*\*([^*]+?)\*\*|__([^_]+?)__`)
+	mdStrike       = regexp.MustCompile(`~~([^~]+?)~~`)
+	mdItalic       = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	imageExtension = regexp.MustCompile(`(?i)\.(?:png|jpe?g|gif|svg|webp|bmp)$`)
+)
+
+// boldMarker stands in for bold markup while the italic markup is
+// converted, since the formats use the same characters for them.
+const boldMarker = "\x01"
+
+// inlineRule converts the matches of the pattern.
+type inlineRule struct {
+	re   *regexp.Regexp
+	repl func(m []string) string
+}
+
+// convertInline converts the inline markup of the text. The protected
+// rules are applied first and their output isn't changed by the other
+// rules, which is used for code spans and links. The rules are applied in
+// order.
+func convertInline(s string, protected, rules []inlineRule) string {
+	var kept []string
+	for _, r := range protected {
+		s = r.re.ReplaceAllStringFunc(s, func(match string) string {
+			kept = append(kept, r.repl(r.re.FindStringSubmatch(match)))
+			return "\x00" + strconv.Itoa(len(kept)-1) + "\x00"
+		})
+	}
+	for _, r := range rules {
+		// A match can consume the space before the next match, so the
+		// rule is applied until nothing changes.
+		for i := 0; i < 3; i++ {
+			converted := r.re.ReplaceAllStringFunc(s, func(match string) string {
+				return r.repl(r.re.FindStringSubmatch(match))
+			})
+			if converted == s {
+				break
+			}
+			s = converted
+		}
+	}
+	s = strings.Replace(s, boldMarker, "*", -1)
+	for i := len(kept) - 1; i >= 0; i-- {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", kept[i], 1)
+	}
+	return s
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// markupRule returns a rule for the markup around text with the delimiter,
+// like *bold*. The markup has to start after white space or punctuation
+// and end before it, so the delimiter inside words and URLs isn't matched.
+func markupRule(delim string, repl func(text string) string) inlineRule {
+	d := regexp.QuoteMeta(delim)
+	re := regexp.MustCompile(`(^|[\s(\[{"'])` + d + `([^\s` + d + `](?:[^` + d + `]*?[^\s` + d + `])?)` + d + `($|[\s)\]}"'.,;:!?-])`)
+	return inlineRule{re, func(m []string) string { return m[1] + repl(m[2]) + m[3] }}
+}
+
+// splitTableRow returns the cells of the table row. Escaped pipes, \|,
+// are kept in the cell.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	start := 0
+	for i := 0; i < len(line); i++ {
+		if line[i] == '|' && (i == 0 || line[i-1] != '\\') {
+			cells = append(cells, strings.TrimSpace(line[start:i]))
+			start = i + 1
+		}
+	}
+	return append(cells, strings.TrimSpace(line[start:]))
+}
+
+// markdownTable returns the rows as a Markdown table. The first row is
+// the header.
+func markdownTable(rows [][]string) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	lines := []string{"| " + strings.Join(rows[0], " | ") + " |"}
+	sep := make([]string, len(rows[0]))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	lines = append(lines, "| "+strings.Join(sep, " | ")+" |")
+	for _, row := range rows[1:] {
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+	}
+	return lines
+}
+
+// listLevel returns the nesting level, starting at 1, of a Markdown list
+// item with the indentation.
+func listLevel(indent string) int {
+	width := len(strings.Replace(indent, "\t", "    ", -1))
+	return width/2 + 1
+}
+
+// closingFence returns true if the line closes the fenced code block.
+func closingFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
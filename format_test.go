@@ -0,0 +1,104 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const formatTestMarkdown = "# Title\n\n" +
+	"Some **bold**, *italic*, ~~gone~~ and `code *x*` with [a link](http://x.com/a_b/c).\n\n" +
+	"- [ ] open\n- [x] done\n  - nested\n\n" +
+	"1. one\n2. two\n\n" +
+	"> quoted\n\n" +
+	"```go\nfunc main() { *x* }\n```\n\n" +
+	"---\n\n" +
+	"| a | b |\n|---|:-:|\n| **1** | 2 |"
+
+func TestParseFormat(t *testing.T) {
+	assert := assert.New(t)
+	for input, expected := range map[string]string{"": FormatMarkdown, "md": FormatMarkdown, " Org ": FormatOrg, "adoc": FormatAsciiDoc, "asciidoc": FormatAsciiDoc} {
+		name, err := ParseFormat(input)
+		assert.NoError(err, input)
+		assert.Equal(expected, name, input)
+	}
+	_, err := ParseFormat("rst")
+	assert.Equal(ErrUnknownFormat, err)
+}
+
+func TestOrgFormatter(t *testing.T) {
+	assert := assert.New(t)
+	org := "* Title\n\n" +
+		"Some *bold*, /italic/, +gone+ and ~code *x*~ with [[http://x.com/a_b/c][a link]].\n\n" +
+		"- [ ] open\n- [X] done\n  - nested\n\n" +
+		"1. one\n2. two\n\n" +
+		"#+BEGIN_QUOTE\nquoted\n#+END_QUOTE\n\n" +
+		"#+BEGIN_SRC go\nfunc main() { *x* }\n#+END_SRC\n\n" +
+		"-----\n\n" +
+		"| a | b |\n|---+---|\n| *1* | 2 |"
+	f := Formatters[FormatOrg]
+	assert.Equal(".org", f.Extension())
+	assert.Equal(org, f.FromMarkdown(formatTestMarkdown))
+	md := f.ToMarkdown(org)
+	assert.Equal("Some **bold**, _italic_, ~~gone~~ and `code *x*` with [a link](http://x.com/a_b/c).", splitLines(md)[2])
+	assert.Equal(org, f.FromMarkdown(md), "The conversion should be stable")
+	assert.Equal("Links like [[https://a/b/c]] and ~code~ stay.", f.FromMarkdown(f.ToMarkdown("Links like [[https://a/b/c]] and ~code~ stay.")))
+}
+
+func TestAsciiDocFormatter(t *testing.T) {
+	assert := assert.New(t)
+	adoc := "= Title\n\n" +
+		"Some *bold*, _italic_, [.line-through]#gone# and `code *x*` with http://x.com/a_b/c[a link].\n\n" +
+		"* [ ] open\n* [x] done\n** nested\n\n" +
+		". one\n. two\n\n" +
+		"____\nquoted\n____\n\n" +
+		"[source,go]\n----\nfunc main() { *x* }\n----\n\n" +
+		"'''\n\n" +
+		"|===\n| a | b\n\n| *1* | 2\n|==="
+	f := Formatters[FormatAsciiDoc]
+	assert.Equal(".adoc", f.Extension())
+	assert.Equal(adoc, f.FromMarkdown(formatTestMarkdown))
+	md := f.ToMarkdown(adoc)
+	assert.Equal("Some **bold**, _italic_, ~~gone~~ and `code *x*` with [a link](http://x.com/a_b/c).", splitLines(md)[2])
+	assert.Equal(adoc, f.FromMarkdown(md), "The conversion should be stable")
+	assert.Equal("![A cat](cat.png) and [docs](docs/index.html)", f.ToMarkdown("image::cat.png[A cat] and link:docs/index.html[docs]"))
+}
+
+func TestWriteAndParseNoteInFormat(t *testing.T) {
+	assert := assert.New(t)
+	defer func() { NoteFormat = Formatters[FormatMarkdown] }()
+	assert.NoError(SetNoteFormat(FormatOrg))
+	n := &Note{Title: "Title", MD: "## Heading\n\n- **item**"}
+	var buf bytes.Buffer
+	assert.NoError(WriteNote(&buf, n, DefaultNoteOption))
+	assert.Contains(buf.String(), "** Heading\n\n- *item*")
+	parsed := new(Note)
+	assert.NoError(parseNote(&buf, parsed, DefaultNoteOption))
+	assert.Equal("Title", parsed.Title)
+	assert.Equal(n.MD, parsed.MD)
+}
+
+func TestSplitTableRow(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"a", "b"}, splitTableRow("| a | b |"))
+	assert.Equal([]string{`a \| b`, "c"}, splitTableRow(`| a \| b | c |`))
+	assert.Equal([]string{"a", `b \|`}, splitTableRow(`a | b \|`))
+}
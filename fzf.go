@@ -0,0 +1,105 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// FzfCommand is the fuzzy finder used to pick notes and notebooks. A
+// compatible finder, like sk, can be used instead.
+var FzfCommand = "fzf"
+
+// pickerNoteCount is the number of recently updated notes offered by
+// PickerNotes if there isn't a saved search.
+const pickerNoteCount = 250
+
+var (
+	// ErrNothingPicked is returned if the finder was closed without picking
+	// an item.
+	ErrNothingPicked = errors.New("nothing picked")
+	// ErrFzfNotFound is returned if the fuzzy finder isn't installed.
+	ErrFzfNotFound = errors.New("fzf not found in PATH")
+)
+
+// FzfAvailable returns true if the fuzzy finder is installed and external
+// commands are allowed.
+func FzfAvailable() bool {
+	if SafeMode {
+		return false
+	}
+	_, err := exec.LookPath(FzfCommand)
+	return err == nil
+}
+
+// Fzf lets the user pick one of the items with the fuzzy finder and returns
+// the index of the picked item. Tabs in the items separate the columns
+// shown by the finder. The finder draws on the terminal, so stdout isn't
+// used.
+func Fzf(items []string, prompt string) (int, error) {
+	if SafeMode {
+		return 0, ErrSafeMode
+	}
+	if _, err := exec.LookPath(FzfCommand); err != nil {
+		return 0, ErrFzfNotFound
+	}
+	var in, out bytes.Buffer
+	for i, item := range items {
+		fmt.Fprintf(&in, "%d\t%s\n", i, strings.Replace(item, "\n", " ", -1))
+	}
+	cmd := exec.Command(FzfCommand, "--delimiter=\t", "--with-nth=2..", "--no-multi", "--prompt="+prompt)
+	cmd.Stdin = &in
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// fzf exits with 1 if nothing matched and 130 if it was canceled.
+		if exit, ok := err.(*exec.ExitError); ok && (exit.ExitCode() == 1 || exit.ExitCode() == 130) {
+			return 0, ErrNothingPicked
+		}
+		return 0, fmt.Errorf("%s failed: %s", FzfCommand, err)
+	}
+	line := strings.TrimSpace(out.String())
+	if i := strings.Index(line, "\t"); i != -1 {
+		line = line[:i]
+	}
+	index, err := strconv.Atoi(line)
+	if err != nil || index < 0 || index >= len(items) {
+		return 0, ErrNothingPicked
+	}
+	return index, nil
+}
+
+// PickerNotes returns the notes to pick from when no title is given. The
+// result of the last search is used if there is one. Otherwise, the
+// recently updated notes are fetched.
+func PickerNotes(db Storager, ns NotestoreClient) ([]*Note, error) {
+	notes, err := db.GetSearch()
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) != 0 {
+		return notes, nil
+	}
+	return ns.FindNotes(&NoteFilter{Order: NoteFilterOrderUpdated}, 0, pickerNoteCount)
+}
@@ -0,0 +1,85 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFzf(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-fzf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(cmd string) { FzfCommand = cmd }(FzfCommand)
+	finder := func(script string) {
+		FzfCommand = filepath.Join(dir, "fzf")
+		assert.NoError(ioutil.WriteFile(FzfCommand, []byte("#!/bin/sh\n"+script+"\n"), 0700))
+	}
+
+	finder(`sed -n 2p`)
+	i, err := Fzf([]string{"First\t2018-01-01", "Second\t2018-01-02"}, "Note> ")
+	assert.NoError(err)
+	assert.Equal(1, i, "Should return the index of the picked line")
+
+	finder(`cat > /dev/null; exit 130`)
+	_, err = Fzf([]string{"First"}, "Note> ")
+	assert.Equal(ErrNothingPicked, err, "Canceled finder")
+
+	finder(`cat > /dev/null; exit 2`)
+	_, err = Fzf([]string{"First"}, "Note> ")
+	assert.Error(err)
+
+	FzfCommand = filepath.Join(dir, "missing")
+	assert.False(FzfAvailable())
+	_, err = Fzf([]string{"First"}, "Note> ")
+	assert.Equal(ErrFzfNotFound, err)
+
+	finder(`sed -n 1p`)
+	SafeMode = true
+	defer func() { SafeMode = false }()
+	assert.False(FzfAvailable(), "Fzf should not be used in safe mode")
+	_, err = Fzf([]string{"First"}, "Note> ")
+	assert.Equal(ErrSafeMode, err)
+}
+
+func TestPickerNotes(t *testing.T) {
+	assert := assert.New(t)
+	recent := []*Note{{Title: "Recent"}}
+	var filter *NoteFilter
+	ns := &mockNS{findNotes: func(f *NoteFilter, o, c int) ([]*Note, error) { filter = f; return recent, nil }}
+
+	notes, err := PickerNotes(&mockStore{}, ns)
+	assert.NoError(err)
+	assert.Equal(recent, notes)
+	if assert.NotNil(filter) {
+		assert.Equal(NoteFilterOrderUpdated, filter.Order, "Recently updated notes should be used without a search")
+	}
+
+	searched := []*Note{{Title: "Searched"}}
+	notes, err = PickerNotes(&mockStore{savedSearch: searched}, ns)
+	assert.NoError(err)
+	assert.Equal(searched, notes, "The saved search should be used")
+}
@@ -0,0 +1,165 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"strings"
+	"unicode"
+)
+
+// codeKeywords are the keywords highlighted in code blocks. The code
+// block's language isn't known, so the keywords of common languages are
+// used.
+var codeKeywords = map[string]bool{
+	"break": true, "case": true, "class": true, "const": true, "continue": true,
+	"def": true, "default": true, "defer": true, "do": true, "elif": true,
+	"else": true, "false": true, "fi": true, "fn": true, "for": true,
+	"from": true, "func": true, "function": true, "go": true, "if": true,
+	"import": true, "in": true, "interface": true, "let": true, "map": true,
+	"new": true, "nil": true, "None": true, "null": true, "package": true,
+	"pub": true, "range": true, "return": true, "select": true, "self": true,
+	"static": true, "struct": true, "switch": true, "then": true, "this": true,
+	"true": true, "True": true, "False": true, "type": true, "use": true,
+	"var": true, "while": true, "with": true, "yield": true,
+}
+
+// paintMarkdown returns the Markdown with the headings, inline code and
+// code blocks styled by the theme.
+func paintMarkdown(md string, t *Theme) string {
+	lines := strings.Split(md, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+				lines[i] = t.Code.Paint(line)
+			} else {
+				lines[i] = highlightCode(line, t)
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			lines[i] = t.Code.Paint(line)
+		case isHeading(trimmed):
+			lines[i] = t.Heading.Paint(line)
+		default:
+			lines[i] = paintInlineCode(line, t)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isHeading returns true if the line is a Markdown ATX heading.
+func isHeading(line string) bool {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	return level > 0 && level <= 6 && (level == len(line) || line[level] == ' ')
+}
+
+// paintInlineCode styles the code spans in the line.
+func paintInlineCode(line string, t *Theme) string {
+	if len(t.Code) == 0 || !strings.Contains(line, "`") {
+		return line
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(line, "`")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(line[start+1:], "`")
+		if end == -1 {
+			break
+		}
+		end += start + 2
+		b.WriteString(line[:start])
+		b.WriteString(t.Code.Paint(line[start:end]))
+		line = line[end:]
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// highlightCode returns the line of code with the keywords, string
+// literals, numbers and comments styled by the theme.
+func highlightCode(line string, t *Theme) string {
+	var b strings.Builder
+	r := []rune(line)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case c == '/' && i+1 < len(r) && r[i+1] == '/',
+			c == '#' && (i == 0 || unicode.IsSpace(r[i-1])):
+			b.WriteString(t.Comment.Paint(string(r[i:])))
+			return b.String()
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(r) && r[j] != c {
+				if r[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(r) {
+				j++
+			} else {
+				j = len(r)
+			}
+			b.WriteString(t.String.Paint(string(r[i:j])))
+			i = j
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(r) && (unicode.IsDigit(r[j]) || unicode.IsLetter(r[j]) || r[j] == '.' || r[j] == '_') {
+				j++
+			}
+			b.WriteString(t.Number.Paint(string(r[i:j])))
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(r) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_') {
+				j++
+			}
+			word := string(r[i:j])
+			if codeKeywords[word] {
+				word = t.Keyword.Paint(word)
+			}
+			b.WriteString(word)
+			i = j
+		default:
+			b.WriteRune(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// paintDiffLine styles a line from DiffLines by the theme.
+func paintDiffLine(line string, t *Theme) string {
+	switch {
+	case strings.HasPrefix(line, "@@"):
+		return t.DiffHunk.Paint(line)
+	case strings.HasPrefix(line, "+"):
+		return t.DiffAdded.Paint(line)
+	case strings.HasPrefix(line, "-"):
+		return t.DiffRemoved.Paint(line)
+	}
+	return line
+}
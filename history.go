@@ -0,0 +1,179 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/TcM1911/clinote/markdown"
+)
+
+// HistoryGit is the git executable used for the note history.
+var HistoryGit = "git"
+
+// History actions, used in the commit messages.
+const (
+	historyFetch  = "Fetch"
+	historyEdit   = "Edit"
+	historyCreate = "Create"
+)
+
+// MirrorNote writes the note to a Markdown file in the git repository in the
+// folder and commits it if it changed. The repository is created if the folder
+// isn't one. The files are named like exported files, so a note keeps its file
+// when the title changes and "git log -p" shows the note's full history.
+func MirrorNote(folder string, n *Note, action string) error {
+	if err := initHistory(folder); err != nil {
+		return err
+	}
+	manifest, err := LoadExportManifest(folder)
+	if err != nil {
+		return err
+	}
+	// The history is kept in Markdown, whatever format the notes are
+	// edited in, so the diffs don't change with the format.
+	var content bytes.Buffer
+	writeNoteHeader(&content, n)
+	content.WriteString(n.MD + "\n")
+	name := manifest.FileName(n) + ".md"
+	if err = ioutil.WriteFile(filepath.Join(folder, name), content.Bytes(), 0600); err != nil {
+		return err
+	}
+	if err = manifest.Save(folder); err != nil {
+		return err
+	}
+	if _, err = historyGit(folder, "add", "--", name, ExportManifestName); err != nil {
+		return err
+	}
+	// diff exits with 1 if there are staged changes.
+	if _, err = historyGit(folder, "diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	_, err = historyGit(folder, "commit", "--quiet", "-m", action+" "+n.Title)
+	return err
+}
+
+// initHistory creates the git repository in the folder if it doesn't exist.
+// An identity is set for the repository if git doesn't have one, since the
+// commits would fail without it.
+func initHistory(folder string) error {
+	if _, err := os.Stat(filepath.Join(folder, ".git")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return err
+	}
+	if _, err := historyGit(folder, "init", "--quiet"); err != nil {
+		return err
+	}
+	if email, err := historyGit(folder, "config", "user.email"); err == nil && email != "" {
+		return nil
+	}
+	if _, err := historyGit(folder, "config", "user.name", "clinote"); err != nil {
+		return err
+	}
+	_, err := historyGit(folder, "config", "user.email", "clinote@localhost")
+	return err
+}
+
+// historyGit runs git in the folder and returns what it wrote to stdout.
+func historyGit(folder string, args ...string) (string, error) {
+	cmd := exec.Command(HistoryGit, args...)
+	cmd.Dir = folder
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// mirrorNote adds the note to the history if the user has set a history
+// repository. Nothing is mirrored in safe mode, since git runs the hooks in
+// the repository. For raw notes the Markdown is converted from the body.
+// The notebook name is looked up so the file doesn't change depending on
+// if the note was fetched with or without its notebook. Notes in notebooks
+// that aren't selected by the sync settings aren't mirrored. Neither are
+// encrypted notes, so their content isn't written to the repository.
+func mirrorNote(db Storager, ns NotestoreClient, n *Note, action string, raw bool) error {
+	if SafeMode || HasEncryptedBlocks(n) {
+		return nil
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		return err
+	}
+	if settings.HistoryRepo == "" {
+		return nil
+	}
+	note := *n
+	if raw {
+		if note.MD, err = markdown.FromHTML(n.Body); err != nil {
+			return err
+		}
+	}
+	if note.Notebook != nil && note.Notebook.Name == "" {
+		notebooks, err := GetNotebooks(db, ns, false)
+		if err != nil {
+			return err
+		}
+		for _, nb := range notebooks {
+			if nb.GUID == note.Notebook.GUID {
+				note.Notebook = nb
+				break
+			}
+		}
+	}
+	if note.Notebook != nil {
+		if note.Notebook.Name != "" && !settings.NotebookSelected(note.Notebook.Name) {
+			return nil
+		}
+		key, err := storedNotebookKey(db, note.Notebook.GUID)
+		if err != nil {
+			return err
+		}
+		if key != nil {
+			return nil
+		}
+	}
+	return MirrorNote(settings.HistoryRepo, &note, action)
+}
+
+// historyWarnings is where failures to update the history are reported.
+var historyWarnings io.Writer = os.Stderr
+
+// warnMirrorNote mirrors the note and only warns if it fails. The history
+// is a side effect, so a missing or failing git shouldn't fail a command
+// whose changes already are on the server.
+func warnMirrorNote(db Storager, ns NotestoreClient, n *Note, action string, raw bool) {
+	if err := mirrorNote(db, ns, n, action, raw); err != nil {
+		LogVerbose("history mirror failed", "guid", n.GUID, "action", action, "error", err)
+		fmt.Fprintf(historyWarnings, "Warning: the note history wasn't updated: %s\n", err)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirrorNote(t *testing.T) {
+	if _, err := exec.LookPath(HistoryGit); err != nil {
+		t.Skip("git is not installed")
+	}
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-history")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "history")
+	commits := func() string {
+		out, err := historyGit(repo, "log", "--format=%s")
+		assert.NoError(err)
+		return out
+	}
+
+	n := &Note{GUID: "guid1", Title: "Plan", MD: "First", Notebook: &Notebook{Name: "Work"}}
+	assert.NoError(MirrorNote(repo, n, historyFetch))
+	data, err := ioutil.ReadFile(filepath.Join(repo, "plan.md"))
+	assert.NoError(err)
+	assert.Contains(string(data), "Work")
+	assert.Contains(string(data), "First")
+	assert.Equal("Fetch Plan", commits())
+
+	// Nothing is committed if the note hasn't changed.
+	assert.NoError(MirrorNote(repo, n, historyFetch))
+	assert.Equal("Fetch Plan", commits())
+
+	// The note keeps its file when the title changes.
+	n.Title, n.MD = "New plan", "Second"
+	assert.NoError(MirrorNote(repo, n, historyEdit))
+	assert.Equal("Edit New plan\nFetch Plan", commits())
+	data, err = ioutil.ReadFile(filepath.Join(repo, "plan.md"))
+	assert.NoError(err)
+	assert.Contains(string(data), "Second")
+	_, err = os.Stat(filepath.Join(repo, "new-plan.md"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestMirrorNoteSkipsNotes(t *testing.T) {
+	assert := assert.New(t)
+	defer func(git string) { HistoryGit = git }(HistoryGit)
+	HistoryGit = "false"
+	db := &mockKeyedStore{
+		mockStore: &mockStore{getSettings: func() (*Settings, error) {
+			return &Settings{HistoryRepo: filepath.Join(os.TempDir(), "clinote-no-history"), SyncExclude: []string{"Private"}}, nil
+		}},
+		mockKeyStore: mockKeyStore{"SECRET": []byte("key")},
+	}
+
+	tests := []struct {
+		name string
+		note *Note
+	}{
+		{"excluded notebook", &Note{Title: "Diary", MD: "Text", Notebook: &Notebook{GUID: "PRIVATE", Name: "private"}}},
+		{"encrypted notebook", &Note{Title: "Keys", MD: "Text", Notebook: &Notebook{GUID: "SECRET", Name: "Secret"}}},
+		{"encrypted block", &Note{Title: "Login", Body: `<en-note><en-crypt cipher="AES">data</en-crypt></en-note>`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.NoError(mirrorNote(db, nil, test.note, historyFetch, false), "The note should not be mirrored")
+		})
+	}
+
+	err := mirrorNote(db, nil, &Note{Title: "Plan", MD: "Text", Notebook: &Notebook{GUID: "WORK", Name: "Work"}}, historyFetch, false)
+	assert.Error(err, "The selected note should be mirrored")
+}
+
+type mockKeyedStore struct {
+	*mockStore
+	mockKeyStore
+}
+
+func TestEditNoteWithFailingHistory(t *testing.T) {
+	assert := assert.New(t)
+	defer func(git string, w io.Writer) { HistoryGit, historyWarnings = git, w }(HistoryGit, historyWarnings)
+	HistoryGit = "false"
+	warnings := new(bytes.Buffer)
+	historyWarnings = warnings
+
+	n := &Note{
+		Title:    "Note Title",
+		Body:     "<en-note><p>Body content</p></en-note>",
+		GUID:     "NOTEGUID",
+		Notebook: &Notebook{GUID: "NOTEBOOKGUID", Name: "Work"},
+	}
+	ns := nsWithNote(n)
+	ns.getNoteContent = func(string) (string, error) { return n.Body, nil }
+	ns.getNotebook = func(string) (*Notebook, error) { return n.Notebook, nil }
+	updated := false
+	ns.updateNote = func(*Note) error {
+		updated = true
+		return nil
+	}
+	db := &mockUndoStore{mockStore: &mockStore{getSettings: func() (*Settings, error) {
+		return &Settings{HistoryRepo: filepath.Join(os.TempDir(), "clinote-no-history")}, nil
+	}}}
+	c := &Client{
+		Store:     db,
+		Config:    new(DefaultConfig),
+		NoteStore: ns,
+		Editor: &mockEditor{edit: func(file CacheFile) error {
+			_, err := file.(*mockCacheFile).buffer.WriteString("\n\nNew content\n")
+			return err
+		}},
+	}
+	c.newCacheFile = func(*Client, string) (CacheFile, error) {
+		return &mockCacheFile{buffer: new(bytes.Buffer)}, nil
+	}
+
+	err := EditNote(c, n.Title, DefaultNoteOption)
+
+	assert.NoError(err, "A failing history shouldn't fail the edit")
+	assert.True(updated)
+	if assert.Len(db.entries, 1, "The edit should be recorded for undo") {
+		assert.Equal(UndoEdit, db.entries[0].Op)
+	}
+	assert.Contains(warnings.String(), "Warning: the note history wasn't updated")
+}
@@ -0,0 +1,135 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// HooksFolder is the folder in the config folder with the hook scripts.
+const HooksFolder = "hooks"
+
+// The events hooks are run for. The hook is the script in the hooks folder
+// with the event's name. A pre hook that fails stops the operation.
+const (
+	HookPreNoteCreate  = "pre-note-create"
+	HookPostNoteCreate = "post-note-create"
+	HookPreNoteEdit    = "pre-note-edit"
+	HookPostNoteEdit   = "post-note-edit"
+	HookPreNoteDelete  = "pre-note-delete"
+	HookPostNoteDelete = "post-note-delete"
+)
+
+// HookEvents are the events hooks can be added for.
+var HookEvents = []string{
+	HookPreNoteCreate, HookPostNoteCreate,
+	HookPreNoteEdit, HookPostNoteEdit,
+	HookPreNoteDelete, HookPostNoteDelete,
+}
+
+// HookNote is the note metadata written as JSON to the hook's stdin.
+type HookNote struct {
+	Event    string   `json:"event"`
+	GUID     string   `json:"guid,omitempty"`
+	Title    string   `json:"title"`
+	Notebook string   `json:"notebook,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Created  int64    `json:"created,omitempty"`
+	Updated  int64    `json:"updated,omitempty"`
+}
+
+// HooksDir returns the folder with the hook scripts.
+func HooksDir() string {
+	return filepath.Join(configDir, HooksFolder)
+}
+
+// FindHook returns the path to the hook script for the event. An empty
+// string is returned if there's no hook. On Windows, the script needs
+// an .exe, .bat or .cmd extension.
+func FindHook(event string) string {
+	names := []string{event}
+	if runtime.GOOS == "windows" {
+		names = []string{event + ".exe", event + ".bat", event + ".cmd"}
+	}
+	for _, name := range names {
+		path := filepath.Join(HooksDir(), name)
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// runNoteHook runs the hook for the event unless the notestore doesn't send
+// the changes to the server.
+func runNoteHook(ns NotestoreClient, event string, n *Note) error {
+	if IsDryRun(ns) {
+		return nil
+	}
+	return RunNoteHook(event, n)
+}
+
+// RunNoteHook runs the hook for the event, if the user has added one. The
+// note's metadata is written as JSON to the hook's stdin and passed in the
+// environment variables CLINOTE_HOOK, CLINOTE_NOTE_GUID, CLINOTE_NOTE_TITLE
+// and CLINOTE_NOTEBOOK. The hook's output is written to stderr so it isn't
+// mixed with the output of the command. Hooks aren't run in safe mode.
+func RunNoteHook(event string, n *Note) error {
+	if SafeMode {
+		return nil
+	}
+	path := FindHook(event)
+	if path == "" {
+		return nil
+	}
+	payload := &HookNote{
+		Event:   event,
+		GUID:    n.GUID,
+		Title:   n.Title,
+		Tags:    n.Tags,
+		Created: n.Created,
+		Updated: n.Updated,
+	}
+	if n.Notebook != nil {
+		payload.Notebook = n.Notebook.Name
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(),
+		"CLINOTE_HOOK="+event,
+		"CLINOTE_NOTE_GUID="+n.GUID,
+		"CLINOTE_NOTE_TITLE="+n.Title,
+		"CLINOTE_NOTEBOOK="+payload.Notebook,
+	)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %s", event, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunNoteHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-hooks")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	oldConfigDir := configDir
+	configDir = dir
+	defer func() { configDir = oldConfigDir }()
+	assert.NoError(os.Mkdir(HooksDir(), 0700))
+
+	n := &Note{GUID: "GUID", Title: "Title", Notebook: &Notebook{Name: "Work"}, Tags: []string{"a"}}
+	assert.NoError(RunNoteHook(HookPostNoteCreate, n), "No hook")
+
+	out := filepath.Join(dir, "out")
+	script := "#!/bin/sh\ncat > " + out + "\necho \"$CLINOTE_HOOK $CLINOTE_NOTE_TITLE $CLINOTE_NOTEBOOK\" >> " + out + "\n"
+	assert.NoError(ioutil.WriteFile(filepath.Join(HooksDir(), HookPostNoteCreate), []byte(script), 0700))
+	assert.Equal(filepath.Join(HooksDir(), HookPostNoteCreate), FindHook(HookPostNoteCreate))
+	assert.NoError(RunNoteHook(HookPostNoteCreate, n))
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(lines, 2)
+	var payload HookNote
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &payload))
+	assert.Equal(HookNote{Event: HookPostNoteCreate, GUID: "GUID", Title: "Title", Notebook: "Work", Tags: []string{"a"}}, payload)
+	assert.Equal("post-note-create Title Work", lines[1])
+
+	// A failing pre hook stops the note from being created.
+	assert.NoError(ioutil.WriteFile(filepath.Join(HooksDir(), HookPreNoteCreate), []byte("#!/bin/sh\nexit 1\n"), 0700))
+	ns := new(mockNS)
+	ns.createNote = func(n *Note) error {
+		t.Fatal("note created")
+		return nil
+	}
+	assert.Error(SaveNewNote(ns, &Note{Title: "Title"}, false))
+
+	// The edit hooks are run for changes that aren't made in the editor.
+	assert.NoError(ioutil.WriteFile(filepath.Join(HooksDir(), HookPreNoteEdit), []byte("#!/bin/sh\nexit 1\n"), 0700))
+	ns = nsWithNote(&Note{GUID: "GUID", Title: "Title"})
+	ns.updateNote = func(n *Note) error {
+		t.Fatal("note updated")
+		return nil
+	}
+	assert.Error(ChangeTitle(new(mockStore), ns, "Title", "New title"))
+
+	SafeMode = true
+	defer func() { SafeMode = false }()
+	assert.NoError(RunNoteHook(HookPreNoteCreate, n), "Safe mode")
+}
@@ -0,0 +1,140 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HyperlinksAuto turns hyperlinks on if the terminal supports them.
+	HyperlinksAuto = "auto"
+	// HyperlinksOn always writes hyperlinks to terminals.
+	HyperlinksOn = "on"
+	// HyperlinksOff never writes hyperlinks.
+	HyperlinksOff = "off"
+)
+
+// hyperlinkTerminals are the TERM_PROGRAM values of terminals known to
+// support OSC 8 hyperlinks.
+var hyperlinkTerminals = []string{"iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty", "Tabby"}
+
+// hyperlinkTerms are substrings of TERM values for terminals known to
+// support OSC 8 hyperlinks.
+var hyperlinkTerms = []string{"kitty", "foot", "alacritty", "wezterm", "ghostty", "contour"}
+
+// ParseHyperlinkMode validates the hyperlink setting. An empty value is
+// the same as auto.
+func ParseHyperlinkMode(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", HyperlinksAuto:
+		return HyperlinksAuto, nil
+	case HyperlinksOn, "true", "yes":
+		return HyperlinksOn, nil
+	case HyperlinksOff, "false", "no":
+		return HyperlinksOff, nil
+	}
+	return "", fmt.Errorf("%q is not auto, on or off", s)
+}
+
+// UseHyperlinks returns true if hyperlinks should be written to the writer
+// with the hyperlink setting. Hyperlinks are never written if the writer
+// isn't a terminal.
+func UseHyperlinks(w io.Writer, mode string) bool {
+	f, ok := w.(*os.File)
+	if !ok || terminalWidth(f) == 0 {
+		return false
+	}
+	switch mode {
+	case HyperlinksOn:
+		return true
+	case HyperlinksOff:
+		return false
+	}
+	return HyperlinksSupported()
+}
+
+// HyperlinksSupported guesses from the environment if the terminal
+// supports OSC 8 hyperlinks. Terminals that don't support them may print
+// the escape codes, so only known terminals are included.
+func HyperlinksSupported() bool {
+	term := os.Getenv("TERM")
+	if term == "dumb" || os.Getenv("TMUX") != "" || strings.HasPrefix(term, "screen") {
+		return false
+	}
+	program := os.Getenv("TERM_PROGRAM")
+	for _, t := range hyperlinkTerminals {
+		if program == t {
+			return true
+		}
+	}
+	for _, t := range hyperlinkTerms {
+		if strings.Contains(term, t) {
+			return true
+		}
+	}
+	// VTE based terminals, like GNOME Terminal, support them from 0.50.
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	return os.Getenv("WT_SESSION") != "" || os.Getenv("KONSOLE_VERSION") != "" || os.Getenv("DOMTERM") != ""
+}
+
+// Hyperlink returns the text as an OSC 8 hyperlink to the URL.
+func Hyperlink(link, text string) string {
+	return "\x1b]8;;" + link + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// FileURL returns the file:// URL for the path.
+func FileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	// Windows paths, like C:/notes, need a leading slash.
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Path: path}
+	return u.String()
+}
+
+// NoteWebURL returns the URL of the note in the web client of the service
+// the credential is for. If the shard and user ID can't be read from the
+// token, a link to the note in the web client's home page is returned.
+func NoteWebURL(cred *Credential, guid string) string {
+	if cred.CredType == JoplinCredential {
+		// Joplin has no web client, the link opens the note in the app.
+		return "joplin://x-callback-url/openNote?id=" + guid
+	}
+	if cred.CredType == FolderCredential {
+		return FileURL(filepath.Join(cred.Secret, filepath.FromSlash(guid)))
+	}
+	host := cred.APIHost()
+	info := ParseToken(cred.Secret)
+	if info.Shard == "" || info.UserID == 0 {
+		return "https://" + host + "/Home.action#n=" + guid
+	}
+	return fmt.Sprintf("https://%s/shard/%s/nl/%d/%s/", host, info.Shard, info.UserID, guid)
+}
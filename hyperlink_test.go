@@ -0,0 +1,67 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHyperlinkMode(t *testing.T) {
+	assert := assert.New(t)
+	for in, expected := range map[string]string{"": HyperlinksAuto, "Auto": HyperlinksAuto, "on": HyperlinksOn, "no": HyperlinksOff} {
+		mode, err := ParseHyperlinkMode(in)
+		assert.NoError(err)
+		assert.Equal(expected, mode, in)
+	}
+	_, err := ParseHyperlinkMode("sometimes")
+	assert.Error(err)
+}
+
+func TestUseHyperlinks(t *testing.T) {
+	assert := assert.New(t)
+	assert.False(UseHyperlinks(new(bytes.Buffer), HyperlinksOn), "Buffer is not a terminal")
+	f, err := os.Open(os.DevNull)
+	assert.NoError(err)
+	defer f.Close()
+	assert.False(UseHyperlinks(f, HyperlinksOn), "Null device is not a terminal")
+}
+
+func TestHyperlink(t *testing.T) {
+	assert.Equal(t, "\x1b]8;;https://example.com\x1b\\text\x1b]8;;\x1b\\", Hyperlink("https://example.com", "text"))
+}
+
+func TestFileURL(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("file:///tmp/notes/a%20b.md", FileURL("/tmp/notes/a b.md"))
+}
+
+func TestNoteWebURL(t *testing.T) {
+	assert := assert.New(t)
+	cred := &Credential{Secret: "S=s1:U=8f:E=15e8f7f9e23:C=1"}
+	assert.Equal("https://www.evernote.com/shard/s1/nl/143/guid/", NoteWebURL(cred, "guid"))
+	cred = &Credential{Secret: "secret", CredType: EvernoteSandboxCredential}
+	assert.Equal("https://sandbox.evernote.com/Home.action#n=guid", NoteWebURL(cred, "guid"))
+	cred = &Credential{Secret: "token", CredType: JoplinCredential}
+	assert.Equal("joplin://x-callback-url/openNote?id=guid", NoteWebURL(cred, "guid"))
+	cred = &Credential{Secret: "/home/joe/notes", CredType: FolderCredential}
+	assert.Equal("file:///home/joe/notes/Work/My%20note.md", NoteWebURL(cred, "Work/My note.md"))
+}
@@ -0,0 +1,271 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoEvent is returned if no calendar event is found.
+	ErrNoEvent = errors.New("no calendar event found")
+	// ErrInvalidEventTime is returned if an event time can't be parsed.
+	ErrInvalidEventTime = errors.New("invalid event time")
+)
+
+// CalendarEvent is an event read from an iCalendar file.
+type CalendarEvent struct {
+	Summary     string
+	Description string
+	Location    string
+	Organizer   string
+	Attendees   []string
+	Start       time.Time
+	End         time.Time
+	// AllDay is true if the event has a date but no time.
+	AllDay bool
+}
+
+// ParseICS parses the events in the iCalendar data. Recurring events are
+// only included once, at their first occurrence.
+func ParseICS(r io.Reader) ([]*CalendarEvent, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []*CalendarEvent
+	var e *CalendarEvent
+	// Nested components, like alarms, are skipped.
+	depth := 0
+	for _, line := range lines {
+		name, params, value := parseICSProperty(line)
+		switch name {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") && e == nil {
+				e = new(CalendarEvent)
+			} else if e != nil {
+				depth++
+			}
+			continue
+		case "END":
+			if e == nil {
+				continue
+			}
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if strings.EqualFold(value, "VEVENT") {
+				if e.End.IsZero() {
+					e.End = e.Start
+				}
+				events = append(events, e)
+				e = nil
+			}
+			continue
+		}
+		if e == nil || depth > 0 {
+			continue
+		}
+		switch name {
+		case "SUMMARY":
+			e.Summary = unescapeICSText(value)
+		case "DESCRIPTION":
+			e.Description = unescapeICSText(value)
+		case "LOCATION":
+			e.Location = unescapeICSText(value)
+		case "ORGANIZER":
+			e.Organizer = icsPerson(params, value)
+		case "ATTENDEE":
+			e.Attendees = append(e.Attendees, icsPerson(params, value))
+		case "DTSTART":
+			if e.Start, e.AllDay, err = parseICSTime(params, value); err != nil {
+				return nil, err
+			}
+		case "DTEND":
+			if e.End, _, err = parseICSTime(params, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return events, nil
+}
+
+// ReadCalendarEvents reads the events from the iCalendar file. If the path
+// is a folder, like the calendars used by khal and vdirsyncer, the events
+// from all the .ics files in it are returned.
+func ReadCalendarEvents(path string) ([]*CalendarEvent, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() && strings.EqualFold(filepath.Ext(p), ".ics") {
+				files = append(files, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var events []*CalendarEvent
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		found, err := ParseICS(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, found...)
+	}
+	return events, nil
+}
+
+// NextEvent returns the event that is in progress or starts next after
+// the time. If there is only one event, it's returned even if it's over.
+func NextEvent(events []*CalendarEvent, now time.Time) (*CalendarEvent, error) {
+	if len(events) == 1 {
+		return events[0], nil
+	}
+	var upcoming []*CalendarEvent
+	for _, e := range events {
+		if e.End.After(now) || e.Start.After(now) {
+			upcoming = append(upcoming, e)
+		}
+	}
+	if len(upcoming) == 0 {
+		return nil, ErrNoEvent
+	}
+	sort.Slice(upcoming, func(i, j int) bool {
+		return upcoming[i].Start.Before(upcoming[j].Start)
+	})
+	return upcoming[0], nil
+}
+
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseICSProperty splits a content line into its name, parameters and value.
+func parseICSProperty(line string) (string, map[string]string, string) {
+	inQuote := false
+	sep := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuote = !inQuote
+		} else if c == ':' && !inQuote {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 {
+		return "", nil, ""
+	}
+	parts := splitICSParams(line[:sep])
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], "\"")
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[sep+1:]
+}
+
+func splitICSParams(s string) []string {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i, c := range s {
+		if c == '"' {
+			inQuote = !inQuote
+		} else if c == ';' && !inQuote {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescapeICSText(s string) string {
+	r := strings.NewReplacer("\\n", "\n", "\\N", "\n", "\\,", ",", "\\;", ";", "\\\\", "\\")
+	return r.Replace(s)
+}
+
+// icsPerson returns the common name of an attendee or organizer, or the
+// address if the name isn't set.
+func icsPerson(params map[string]string, value string) string {
+	if cn := params["CN"]; cn != "" {
+		return cn
+	}
+	if strings.HasPrefix(strings.ToLower(value), "mailto:") {
+		return value[len("mailto:"):]
+	}
+	return value
+}
+
+func parseICSTime(params map[string]string, value string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		if err != nil {
+			return time.Time{}, false, ErrInvalidEventTime
+		}
+		return t, true, nil
+	}
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	} else if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, ErrInvalidEventTime
+	}
+	return t, false, nil
+}
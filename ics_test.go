@@ -0,0 +1,123 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Weekly sync\\, team\r\n" +
+	"DTSTART;TZID=UTC:20180312T090000\r\n" +
+	"DTEND:20180312T100000Z\r\n" +
+	"LOCATION:Room 1\r\n" +
+	"DESCRIPTION:Status\\nRoadmap\r\n" +
+	"ORGANIZER;CN=\"Doe, Jane\":mailto:jane@example.com\r\n" +
+	"ATTENDEE;CN=John Smith;ROLE=REQ-PARTICIPANT:mailto:john@example.com\r\n" +
+	"ATTENDEE;ROLE=OPT-PARTICIPANT:mailto:anna@exam\r\n" +
+	" ple.com\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"DESCRIPTION:Reminder\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Offsite\r\n" +
+	"DTSTART;VALUE=DATE:20180320\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	assert := assert.New(t)
+	events, err := ParseICS(strings.NewReader(testICS))
+	assert.NoError(err)
+	if !assert.Len(events, 2) {
+		return
+	}
+	e := events[0]
+	assert.Equal("Weekly sync, team", e.Summary)
+	assert.Equal("Status\nRoadmap", e.Description)
+	assert.Equal("Room 1", e.Location)
+	assert.Equal("Doe, Jane", e.Organizer)
+	assert.Equal([]string{"John Smith", "anna@example.com"}, e.Attendees)
+	assert.True(time.Date(2018, 3, 12, 9, 0, 0, 0, time.UTC).Equal(e.Start))
+	assert.True(time.Date(2018, 3, 12, 10, 0, 0, 0, time.UTC).Equal(e.End))
+	assert.False(e.AllDay)
+
+	assert.Equal("Offsite", events[1].Summary)
+	assert.True(events[1].AllDay)
+	assert.Equal(time.Date(2018, 3, 20, 0, 0, 0, 0, time.Local), events[1].Start)
+
+	_, err = ParseICS(strings.NewReader("BEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\n"))
+	assert.Equal(ErrInvalidEventTime, err)
+}
+
+func TestNextEvent(t *testing.T) {
+	assert := assert.New(t)
+	events, err := ParseICS(strings.NewReader(testICS))
+	assert.NoError(err)
+
+	e, err := NextEvent(events, time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(err)
+	assert.Equal("Weekly sync, team", e.Summary)
+
+	e, err = NextEvent(events, time.Date(2018, 3, 12, 9, 30, 0, 0, time.UTC))
+	assert.NoError(err)
+	assert.Equal("Weekly sync, team", e.Summary, "Meeting in progress")
+
+	e, err = NextEvent(events, time.Date(2018, 3, 13, 0, 0, 0, 0, time.UTC))
+	assert.NoError(err)
+	assert.Equal("Offsite", e.Summary)
+
+	_, err = NextEvent(events, time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(ErrNoEvent, err)
+
+	e, err = NextEvent(events[:1], time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(err)
+	assert.Equal("Weekly sync, team", e.Summary, "A single event is always used")
+}
+
+func TestReadCalendarEvents(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-ics")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "work"), 0700))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "work", "a.ics"), []byte(testICS), 0600))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "b.ics"), []byte(testICS), 0600))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("BEGIN:VEVENT"), 0600))
+
+	events, err := ReadCalendarEvents(dir)
+	assert.NoError(err)
+	assert.Len(events, 4)
+
+	events, err = ReadCalendarEvents(filepath.Join(dir, "b.ics"))
+	assert.NoError(err)
+	assert.Len(events, 2)
+
+	_, err = ReadCalendarEvents(filepath.Join(dir, "missing.ics"))
+	assert.Error(err)
+}
@@ -0,0 +1,375 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif"  // Decode GIF images.
+	_ "image/jpeg" // Decode JPEG images.
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TcM1911/clinote/markdown"
+)
+
+// ImageProtocol is the terminal graphics protocol used to show the images
+// in a note.
+type ImageProtocol string
+
+const (
+	// ImagesAuto uses the protocol supported by the terminal.
+	ImagesAuto ImageProtocol = "auto"
+	// ImagesITerm is the inline images protocol of iTerm2, also supported
+	// by WezTerm.
+	ImagesITerm ImageProtocol = "iterm"
+	// ImagesKitty is the kitty graphics protocol.
+	ImagesKitty ImageProtocol = "kitty"
+	// ImagesSixel is the sixel graphics format, supported by xterm, foot
+	// and mlterm among others.
+	ImagesSixel ImageProtocol = "sixel"
+	// ImagesNone shows the image's name instead of the image.
+	ImagesNone ImageProtocol = "none"
+)
+
+// ErrUnknownImageProtocol is returned for an image protocol that isn't
+// supported.
+var ErrUnknownImageProtocol = errors.New("unknown image protocol, use auto, iterm, kitty, sixel or none")
+
+const (
+	// maxSixelWidth and maxSixelHeight are the largest size, in pixels, of
+	// an image shown with sixel. Larger images are scaled down.
+	maxSixelWidth  = 800
+	maxSixelHeight = 600
+	// kittyChunkSize is the largest payload of a kitty graphics command.
+	kittyChunkSize = 4096
+)
+
+// imageTokenPattern matches the placeholders for the images in the note
+// content. The placeholders use private use characters so they are left
+// as is by the Markdown conversion and styling.
+var imageTokenPattern = regexp.MustCompile("\uE000(\\d+)\uE001")
+
+// ParseImageProtocol returns the image protocol with the name.
+func ParseImageProtocol(s string) (ImageProtocol, error) {
+	switch p := ImageProtocol(strings.ToLower(s)); p {
+	case ImagesAuto, ImagesITerm, ImagesKitty, ImagesSixel, ImagesNone:
+		return p, nil
+	}
+	return "", ErrUnknownImageProtocol
+}
+
+// DetectImageProtocol returns the image protocol supported by the terminal,
+// based on the environment. ImagesNone is returned if the terminal isn't
+// known to show images.
+func DetectImageProtocol() ImageProtocol {
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || program == "ghostty":
+		return ImagesKitty
+	case program == "iTerm.app" || program == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return ImagesITerm
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm"):
+		return ImagesSixel
+	}
+	return ImagesNone
+}
+
+// WriteNoteWithImages writes the note like WriteNote, with the note's
+// images shown in the terminal with the protocol. The images are
+// downloaded from the note service. Images that can't be shown are
+// replaced by their name. The raw note is written without images.
+func WriteNoteWithImages(w io.Writer, ns NotestoreClient, n *Note, p ImageProtocol, opts NoteOption) error {
+	if opts&RawNote != 0 {
+		return WriteNote(w, n, opts)
+	}
+	if p == ImagesAuto {
+		p = DetectImageProtocol()
+	}
+	resources, err := noteResources(ns, n.GUID)
+	if err != nil {
+		return err
+	}
+	var images []*Resource
+	body := enMediaPattern.ReplaceAllStringFunc(n.Body, func(elem string) string {
+		r := mediaResource(elem, resources)
+		if r == nil || !strings.HasPrefix(r.Mime, "image/") {
+			return elem
+		}
+		images = append(images, r)
+		return fmt.Sprintf("<div>\uE000%d\uE001</div>", len(images)-1)
+	})
+	shown := *n
+	if shown.MD, err = markdown.FromHTML(body); err != nil {
+		return err
+	}
+	theme := colorTheme(w, DefaultTableOption)
+	var buf bytes.Buffer
+	if err = writeNote(&buf, &shown, opts, theme, TerminalWidth(w)); err != nil {
+		return err
+	}
+	out := imageTokenPattern.ReplaceAllStringFunc(buf.String(), func(token string) string {
+		i, _ := strconv.Atoi(imageTokenPattern.FindStringSubmatch(token)[1])
+		r := images[i]
+		if len(r.Data) != 0 {
+			if seq, err := imageSequence(r, p); err == nil {
+				return seq
+			}
+		}
+		return theme.Link.Paint("[image: " + resourceFilename(r) + "]")
+	})
+	_, err = io.WriteString(w, out)
+	return err
+}
+
+// imageSequence returns the escape sequence showing the image with the
+// protocol.
+func imageSequence(r *Resource, p ImageProtocol) (string, error) {
+	switch p {
+	case ImagesITerm:
+		return iTermImage(r), nil
+	case ImagesKitty:
+		return kittyImage(r)
+	case ImagesSixel:
+		return sixelImage(r.Data)
+	}
+	return "", ErrUnknownImageProtocol
+}
+
+// iTermImage returns the image as an iTerm2 inline image. The terminal
+// decodes the image, so all formats it supports can be shown.
+func iTermImage(r *Resource) string {
+	name := base64.StdEncoding.EncodeToString([]byte(resourceFilename(r)))
+	return fmt.Sprintf("\x1b]1337;File=name=%s;size=%d;inline=1;preserveAspectRatio=1:%s\a",
+		name, len(r.Data), base64.StdEncoding.EncodeToString(r.Data))
+}
+
+// kittyImage returns the image as kitty graphics commands. Images that
+// aren't PNG are converted to PNG, since it's the only compressed format
+// kitty reads. The data is sent in chunks.
+func kittyImage(r *Resource) (string, error) {
+	data := r.Data
+	if r.Mime != "image/png" {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err = png.Encode(&buf, img); err != nil {
+			return "", err
+		}
+		data = buf.Bytes()
+	}
+	enc := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for i := 0; i < len(enc); i += kittyChunkSize {
+		end, more := i+kittyChunkSize, 1
+		if end >= len(enc) {
+			end, more = len(enc), 0
+		}
+		if i == 0 {
+			fmt.Fprintf(&b, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, enc[i:end])
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, enc[i:end])
+		}
+	}
+	return b.String(), nil
+}
+
+// sixelImage returns the image in the sixel format. The image is scaled
+// down to at most maxSixelWidth by maxSixelHeight pixels and dithered to
+// the web safe palette. Transparent pixels are left unpainted.
+func sixelImage(data []byte) (string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	src = scaleImage(src, maxSixelWidth, maxSixelHeight)
+	bounds := src.Bounds()
+	img := image.NewPaletted(bounds, palette.WebSafe)
+	draw.FloydSteinberg.Draw(img, bounds, src, bounds.Min)
+	var b strings.Builder
+	fmt.Fprintf(&b, "\x1bP0;1q\"1;1;%d;%d", bounds.Dx(), bounds.Dy())
+	for i, c := range img.Palette {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+	opaque := func(x, y int) bool {
+		_, _, _, a := src.At(x, y).RGBA()
+		return a >= 0x8000
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 6 {
+		// Each color in the band of six rows is painted in its own pass.
+		var colors []uint8
+		used := make(map[uint8]bool)
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			for dy := 0; dy < 6 && y+dy < bounds.Max.Y; dy++ {
+				if i := img.ColorIndexAt(x, y+dy); opaque(x, y+dy) && !used[i] {
+					used[i] = true
+					colors = append(colors, i)
+				}
+			}
+		}
+		for pass, i := range colors {
+			if pass > 0 {
+				b.WriteByte('$')
+			}
+			fmt.Fprintf(&b, "#%d", i)
+			var last byte
+			count := 0
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && y+dy < bounds.Max.Y; dy++ {
+					if img.ColorIndexAt(x, y+dy) == i && opaque(x, y+dy) {
+						bits |= 1 << uint(dy)
+					}
+				}
+				if c := '?' + bits; c == last {
+					count++
+				} else {
+					writeSixelRun(&b, last, count)
+					last, count = c, 1
+				}
+			}
+			writeSixelRun(&b, last, count)
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+	return b.String(), nil
+}
+
+// writeSixelRun writes the sixel repeated count times, using the repeat
+// introducer for longer runs.
+func writeSixelRun(b *strings.Builder, sixel byte, count int) {
+	if count > 3 {
+		fmt.Fprintf(b, "!%d%c", count, sixel)
+		return
+	}
+	for ; count > 0; count-- {
+		b.WriteByte(sixel)
+	}
+}
+
+// scaleImage scales the image down to fit in the size, keeping the aspect
+// ratio. Smaller images are returned as is.
+func scaleImage(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxWidth && h <= maxHeight {
+		return img
+	}
+	nw, nh := maxWidth, h*maxWidth/w
+	if nh > maxHeight {
+		nw, nh = w*maxHeight/h, maxHeight
+	}
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		for x := 0; x < nw; x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x*w/nw, bounds.Min.Y+y*h/nh))
+		}
+	}
+	return scaled
+}
+
+// SaveNoteMarkdown saves the note as a Markdown file in the folder, with
+// its attachments in a folder next to it named after the note. The
+// attachments are referenced with relative links, so images are shown by
+// Markdown viewers. Attachments already saved with the same content are
+// reused. The path of the Markdown file is returned.
+func SaveNoteMarkdown(ns NotestoreClient, n *Note, dir string) (string, error) {
+	resources, err := noteResources(ns, n.GUID)
+	if err != nil {
+		return "", err
+	}
+	name := safeFilename(n.Title)
+	if name == "" {
+		name = n.GUID
+	}
+	filesDir := name + "_files"
+	links := make(map[*Resource]string)
+	body := enMediaPattern.ReplaceAllStringFunc(n.Body, func(elem string) string {
+		r := mediaResource(elem, resources)
+		if r == nil || len(r.Data) == 0 || err != nil {
+			return elem
+		}
+		link, ok := links[r]
+		if !ok {
+			if link, err = saveLinkedResource(dir, filesDir, r); err != nil {
+				return elem
+			}
+			links[r] = link
+		}
+		filename := html.EscapeString(resourceFilename(r))
+		if strings.HasPrefix(r.Mime, "image/") {
+			return fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(link), filename)
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(link), filename)
+	})
+	if err != nil {
+		return "", err
+	}
+	md, err := markdown.FromHTML(body)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+".md")
+	content := "# " + n.Title + "\n\n" + md + "\n"
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return path, ioutil.WriteFile(path, []byte(content), 0600)
+}
+
+// saveLinkedResource saves the resource in the attachment folder and
+// returns the link to it, relative to the parent folder.
+func saveLinkedResource(dir, filesDir string, r *Resource) (string, error) {
+	path, exists, err := uniqueResourcePath(filepath.Join(dir, filesDir, resourceFilename(r)), r.Data)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", err
+		}
+		if err = ioutil.WriteFile(path, r.Data, 0600); err != nil {
+			return "", err
+		}
+	}
+	return (&url.URL{Path: filesDir + "/" + filepath.Base(path)}).String(), nil
+}
@@ -0,0 +1,160 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPNG(w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestParseImageProtocol(t *testing.T) {
+	assert := assert.New(t)
+	p, err := ParseImageProtocol("Kitty")
+	assert.NoError(err)
+	assert.Equal(ImagesKitty, p)
+	_, err = ParseImageProtocol("ascii")
+	assert.Equal(ErrUnknownImageProtocol, err)
+}
+
+func TestDetectImageProtocol(t *testing.T) {
+	vars := []string{"TERM", "TERM_PROGRAM", "KITTY_WINDOW_ID", "LC_TERMINAL"}
+	for _, v := range vars {
+		old, ok := os.LookupEnv(v)
+		os.Unsetenv(v)
+		if ok {
+			defer os.Setenv(v, old)
+		}
+	}
+	tests := []struct {
+		env      map[string]string
+		expected ImageProtocol
+	}{
+		{map[string]string{"TERM": "xterm-256color"}, ImagesNone},
+		{map[string]string{"TERM": "xterm-kitty"}, ImagesKitty},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, ImagesITerm},
+		{map[string]string{"LC_TERMINAL": "iTerm2"}, ImagesITerm},
+		{map[string]string{"TERM": "foot"}, ImagesSixel},
+	}
+	for _, test := range tests {
+		for k, v := range test.env {
+			os.Setenv(k, v)
+		}
+		assert.Equal(t, test.expected, DetectImageProtocol(), "%v", test.env)
+		for k := range test.env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestWriteNoteWithImages(t *testing.T) {
+	image := &Resource{Hash: "aabb", Mime: "image/png", Data: testPNG(8, 8), Filename: "dot.png"}
+	broken := &Resource{Hash: "ccdd", Mime: "image/jpeg", Data: []byte("not a jpeg"), Filename: "broken.jpg"}
+	n := &Note{
+		Title: "Note",
+		GUID:  "GUID",
+		Body: `<en-note><p>Before</p><en-media type="image/png" hash="aabb"/>` +
+			`<en-media type="image/jpeg" hash="ccdd"/><p>After</p></en-note>`,
+	}
+	ns := new(mockNS)
+	ns.getResources = func(guid string) ([]*Resource, error) { return []*Resource{image, broken}, nil }
+
+	tests := []struct {
+		protocol ImageProtocol
+		image    string
+	}{
+		{ImagesITerm, "\x1b]1337;File=name=ZG90LnBuZw==;size="},
+		{ImagesKitty, "\x1b_Ga=T,f=100,m=0;"},
+		{ImagesSixel, "\x1bP0;1q\"1;1;8;8"},
+		{ImagesNone, "[image: dot.png]"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := WriteNoteWithImages(&buf, ns, n, test.protocol, DefaultNoteOption)
+		assert.NoError(t, err)
+		out := buf.String()
+		assert.Contains(t, out, "Before", test.protocol)
+		assert.Contains(t, out, test.image, test.protocol)
+		assert.True(t, strings.Index(out, "Before") < strings.Index(out, test.image), "The image should be in place")
+		assert.Contains(t, out, "After", test.protocol)
+		if test.protocol != ImagesITerm {
+			assert.Contains(t, out, "[image: broken.jpg]", "Images that can't be decoded should be named")
+		}
+	}
+}
+
+func TestSixelImageScaled(t *testing.T) {
+	assert := assert.New(t)
+	sixel, err := sixelImage(testPNG(1600, 300))
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(sixel, "\x1bP0;1q\"1;1;800;150"), "The image should be scaled down")
+	assert.True(strings.HasSuffix(sixel, "-\x1b\\"))
+	assert.Contains(sixel, "!800", "Runs should be repeated")
+}
+
+func TestSaveNoteMarkdown(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-markdown")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	image := &Resource{Hash: "aabb", Mime: "image/png", Data: []byte("png"), Filename: "my cat.png"}
+	file := &Resource{Hash: "ccdd", Mime: "application/pdf", Data: []byte("pdf"), Filename: "report.pdf"}
+	n := &Note{
+		Title: "Pets: cats",
+		GUID:  "GUID",
+		Body: `<en-note><p>Look</p><en-media type="image/png" hash="aabb"/>` +
+			`<en-media type="application/pdf" hash="ccdd"/></en-note>`,
+	}
+	ns := new(mockNS)
+	ns.getResources = func(guid string) ([]*Resource, error) { return []*Resource{image, file}, nil }
+
+	path, err := SaveNoteMarkdown(ns, n, dir)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dir, "Pets_ cats.md"), path)
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.Contains(string(data), "# Pets: cats\n")
+	assert.Contains(string(data), "![my cat.png](Pets_%20cats_files/my%20cat.png)")
+	assert.Contains(string(data), "[report.pdf](Pets_%20cats_files/report.pdf)")
+	saved, err := ioutil.ReadFile(filepath.Join(dir, "Pets_ cats_files", "my cat.png"))
+	assert.NoError(err)
+	assert.Equal("png", string(saved))
+
+	_, err = SaveNoteMarkdown(ns, n, dir)
+	assert.NoError(err)
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "Pets_ cats_files"))
+	assert.Len(files, 2, "Saved attachments should be reused")
+}
@@ -0,0 +1,231 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package joplin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxPageSize is the largest page the data API returns.
+const maxPageSize = 100
+
+var (
+	// ErrNotLoggedIn is returned if no token is set.
+	ErrNotLoggedIn = errors.New("not logged in")
+	// ErrEmptyToken is returned if an empty token is given.
+	ErrEmptyToken = errors.New("empty token")
+	// ErrNotJoplin is returned if the server doesn't answer like Joplin's
+	// data API.
+	ErrNotJoplin = errors.New("the server is not the Joplin data API")
+)
+
+// APIError is an error returned by the data API.
+type APIError struct {
+	// StatusCode is the HTTP status code.
+	StatusCode int
+	// Message is the error message from Joplin.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("joplin: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("joplin: %d %s", e.StatusCode, e.Message)
+}
+
+// apiContext is the context of the requests to the data API. It's set by
+// EnableContext.
+var apiContext = context.Background()
+
+// EnableContext makes the requests to the data API honor the context. The
+// requests in flight are aborted when the context is done.
+func EnableContext(ctx context.Context) {
+	apiContext = ctx
+}
+
+// api makes requests to the data API.
+type api struct {
+	base  string
+	token string
+}
+
+func newAPI(host, token string) *api {
+	base := host
+	if !strings.Contains(base, "://") {
+		// The data API is served by the desktop app on localhost.
+		base = "http://" + base
+	}
+	return &api{base: strings.TrimSuffix(base, "/"), token: token}
+}
+
+// page is a page of items returned by the list endpoints.
+type page struct {
+	Items   json.RawMessage `json:"items"`
+	HasMore bool            `json:"has_more"`
+}
+
+func (a *api) url(path string, query url.Values) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("token", a.token)
+	return a.base + path + "?" + q.Encode()
+}
+
+// do sends the request. The body, if not nil, is sent as JSON and the
+// response is decoded into out, if not nil.
+func (a *api) do(method, path string, query url.Values, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, a.url(path, query), r)
+	if err != nil {
+		return err
+	}
+	return a.send(req, out)
+}
+
+// upload sends the file as a multipart form, the way the resources are
+// created.
+func (a *api) upload(path, filename string, data []byte, props, out interface{}) error {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("data", filename)
+	if err != nil {
+		return err
+	}
+	part.Write(data)
+	p, err := json.Marshal(props)
+	if err != nil {
+		return err
+	}
+	w.WriteField("props", string(p))
+	if err = w.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", a.url(path, nil), &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return a.send(req, out)
+}
+
+func (a *api) send(req *http.Request, out interface{}) error {
+	resp, err := http.DefaultClient.Do(req.WithContext(apiContext))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &msg) == nil {
+			apiErr.Message = msg.Error
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// list gets count items, starting at offset, from a list endpoint. A
+// negative count gets all the items. The items are decoded into out, which
+// has to be a pointer to a slice.
+func (a *api) list(path string, query url.Values, offset, count int, out interface{}) error {
+	var items []json.RawMessage
+	pageNum := offset/maxPageSize + 1
+	skip := offset % maxPageSize
+	for count < 0 || len(items) < count {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", fmt.Sprint(pageNum))
+		q.Set("limit", fmt.Sprint(maxPageSize))
+		var p page
+		if err := a.do("GET", path, q, nil, &p); err != nil {
+			return err
+		}
+		var pageItems []json.RawMessage
+		if err := json.Unmarshal(p.Items, &pageItems); err != nil {
+			return err
+		}
+		if skip < len(pageItems) {
+			items = append(items, pageItems[skip:]...)
+		}
+		skip = 0
+		if !p.HasMore {
+			break
+		}
+		pageNum++
+	}
+	if count >= 0 && len(items) > count {
+		items = items[:count]
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// ping checks that the server is Joplin's data API.
+func (a *api) ping() error {
+	var data []byte
+	req, err := http.NewRequest("GET", a.base+"/ping", nil)
+	if err != nil {
+		return err
+	}
+	if err = a.send(req, &data); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(string(data), "JoplinClipperServer") {
+		return ErrNotJoplin
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package joplin
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+)
+
+// Client is a note backend for Joplin. It talks to the data API served by
+// the Joplin desktop app's web clipper service.
+type Client struct {
+	// Config holds all the configurations.
+	Config clinote.Configuration
+	api    *api
+	ns     clinote.NotestoreClient
+	// dryRun is where the changes are written instead of being sent.
+	// Nil unless dry run mode is enabled.
+	dryRun io.Writer
+}
+
+// NewClient creates a Joplin client for the credential. The credential's
+// secret is the data API token and its host the address of the API.
+func NewClient(cfg clinote.Configuration, cred *clinote.Credential) *Client {
+	return &Client{Config: cfg, api: newAPI(cred.APIHost(), cred.Secret)}
+}
+
+// GetConfig returns the configuration.
+func (c *Client) GetConfig() clinote.Configuration {
+	return c.Config
+}
+
+// GetNoteStore returns the notestore.
+func (c *Client) GetNoteStore() (clinote.NotestoreClient, error) {
+	if c.ns != nil {
+		return c.ns, nil
+	}
+	ns, err := c.NewNoteStore()
+	if err != nil {
+		return nil, err
+	}
+	c.ns = ns
+	return ns, nil
+}
+
+// NewNoteStore returns a new notestore.
+func (c *Client) NewNoteStore() (clinote.NotestoreClient, error) {
+	if c.api.token == "" {
+		return nil, ErrNotLoggedIn
+	}
+	var ns clinote.NotestoreClient = &Notestore{api: c.api}
+	if clinote.LogEnabled(clinote.LogLevelVerbose) {
+		ns = clinote.NewLoggingNotestore(ns)
+	}
+	ns = clinote.WithAPIBudget(ns)
+	if keys, ok := c.Config.UserStore().(clinote.NotebookKeyStore); ok {
+		ns = clinote.NewEncryptedNotestore(ns, keys)
+	}
+	if c.dryRun != nil {
+		ns = clinote.NewDryRunNotestore(ns, c.dryRun)
+	}
+	return ns, nil
+}
+
+// SetDryRun enables dry run mode. The calls that would change the notes
+// are written to w instead of being sent to Joplin.
+func (c *Client) SetDryRun(w io.Writer) {
+	c.dryRun = w
+	if c.ns != nil {
+		c.ns = clinote.NewDryRunNotestore(c.ns, w)
+	}
+}
+
+// Close shuts down the client.
+func (c *Client) Close() error {
+	return c.Config.Close()
+}
+
+// Login checks the data API token and saves it as a new credential that
+// is made active. The token is shown in Joplin's web clipper options. An
+// empty host uses the default address of the data API.
+func Login(cfg clinote.Configuration, token, host string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrEmptyToken
+	}
+	cred := &clinote.Credential{
+		Name:     "Joplin",
+		Secret:   token,
+		CredType: clinote.JoplinCredential,
+		Endpoint: clinote.Endpoint{Host: host},
+	}
+	a := newAPI(cred.APIHost(), token)
+	if err := a.ping(); err != nil {
+		return err
+	}
+	// Check the token with a request that needs it.
+	var p page
+	if err := a.do("GET", "/folders", url.Values{"limit": {"1"}}, nil, &p); err != nil {
+		return err
+	}
+	if err := cfg.UserStore().Add(cred); err != nil {
+		return err
+	}
+	creds, err := clinote.GetAllCredentials(cfg.UserStore())
+	if err != nil {
+		return err
+	}
+	_, err = clinote.ActivateCredential(cfg.UserStore(), cfg.Store(), len(creds)-1)
+	return err
+}
@@ -0,0 +1,484 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package joplin
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/TcM1911/clinote/markdown"
+)
+
+const noteFields = "id,parent_id,title,created_time,updated_time,source_url,author,latitude,longitude,altitude"
+
+var (
+	enNotePattern  = regexp.MustCompile(`(?s)<en-note[^>]*>(.*)</en-note>`)
+	enMediaPattern = regexp.MustCompile(`(?s)<en-media[^>]*/>|<en-media[^>]*>.*?</en-media>`)
+	hashPattern    = regexp.MustCompile(`hash="([^"]*)"`)
+)
+
+// Notestore is a notestore for Joplin's data API. Joplin's folders are
+// notebooks and the top level folders of nested folders are stacks. The
+// note content is Markdown in Joplin and converted to and from ENML.
+type Notestore struct {
+	api *api
+}
+
+type note struct {
+	ID          string      `json:"id,omitempty"`
+	ParentID    string      `json:"parent_id,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Body        *string     `json:"body,omitempty"`
+	CreatedTime int64       `json:"created_time,omitempty"`
+	UpdatedTime int64       `json:"updated_time,omitempty"`
+	SourceURL   string      `json:"source_url,omitempty"`
+	Author      string      `json:"author,omitempty"`
+	Latitude    json.Number `json:"latitude,omitempty"`
+	Longitude   json.Number `json:"longitude,omitempty"`
+	Altitude    json.Number `json:"altitude,omitempty"`
+}
+
+type folder struct {
+	ID       string `json:"id,omitempty"`
+	ParentID string `json:"parent_id"`
+	Title    string `json:"title"`
+}
+
+type tag struct {
+	ID    string `json:"id,omitempty"`
+	Title string `json:"title"`
+}
+
+type resource struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Mime     string `json:"mime"`
+	Filename string `json:"filename"`
+	Size     int    `json:"size"`
+}
+
+// FindNotes searches for notes. Joplin's search syntax is used for the
+// search words.
+func (s *Notestore) FindNotes(filter *clinote.NoteFilter, offset, count int) ([]*clinote.Note, error) {
+	query := url.Values{"fields": {noteFields}}
+	var notes []*note
+	var err error
+	switch {
+	case filter.Words != "" && filter.NotebookGUID != "":
+		// The search can't be limited to a folder by its ID.
+		query.Set("query", filter.Words)
+		query.Set("type", "note")
+		var all []*note
+		if err = s.api.list("/search", query, 0, -1, &all); err != nil {
+			return nil, err
+		}
+		for _, n := range all {
+			if n.ParentID == filter.NotebookGUID {
+				notes = append(notes, n)
+			}
+		}
+		if offset >= len(notes) {
+			notes = nil
+		} else {
+			notes = notes[offset:]
+		}
+		if len(notes) > count {
+			notes = notes[:count]
+		}
+	case filter.Words != "":
+		query.Set("query", filter.Words)
+		query.Set("type", "note")
+		err = s.api.list("/search", query, offset, count, &notes)
+	case filter.NotebookGUID != "":
+		setOrder(query, filter.Order)
+		err = s.api.list("/folders/"+url.PathEscape(filter.NotebookGUID)+"/notes", query, offset, count, &notes)
+	default:
+		setOrder(query, filter.Order)
+		err = s.api.list("/notes", query, offset, count, &notes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a := make([]*clinote.Note, len(notes))
+	for i, n := range notes {
+		a[i] = convertNote(n)
+	}
+	return a, nil
+}
+
+func setOrder(query url.Values, order int32) {
+	switch order {
+	case clinote.NoteFilterOrderCreated:
+		query.Set("order_by", "created_time")
+		query.Set("order_dir", "DESC")
+	case clinote.NoteFilterOrderTitle:
+		query.Set("order_by", "title")
+		query.Set("order_dir", "ASC")
+	default:
+		query.Set("order_by", "updated_time")
+		query.Set("order_dir", "DESC")
+	}
+}
+
+func convertNote(n *note) *clinote.Note {
+	cn := &clinote.Note{
+		Title:     n.Title,
+		GUID:      n.ID,
+		Notebook:  &clinote.Notebook{GUID: n.ParentID},
+		Created:   n.CreatedTime,
+		Updated:   n.UpdatedTime,
+		SourceURL: n.SourceURL,
+		Author:    n.Author,
+	}
+	lat, _ := n.Latitude.Float64()
+	lon, _ := n.Longitude.Float64()
+	if lat != 0 || lon != 0 {
+		alt, _ := n.Altitude.Float64()
+		cn.Location = &clinote.Location{Latitude: lat, Longitude: lon, Altitude: alt}
+	}
+	return cn
+}
+
+// GetNoteContent returns the note's content as ENML.
+func (s *Notestore) GetNoteContent(guid string) (string, error) {
+	var n note
+	if err := s.api.do("GET", "/notes/"+url.PathEscape(guid), url.Values{"fields": {"body"}}, nil, &n); err != nil {
+		return "", err
+	}
+	var body string
+	if n.Body != nil {
+		body = *n.Body
+	}
+	return clinote.XMLHeader + "<en-note>" + string(markdown.ToXML(body)) + "</en-note>", nil
+}
+
+// CreateNote creates a new note. The resources are uploaded and linked
+// from the content where the note references them.
+func (s *Notestore) CreateNote(n *clinote.Note) error {
+	body := n.Body
+	for _, r := range n.Resources {
+		id, err := s.uploadResource(r)
+		if err != nil {
+			return err
+		}
+		body = linkResource(body, r, id)
+	}
+	jn, err := newNote(n, body)
+	if err != nil {
+		return err
+	}
+	var created note
+	if err = s.api.do("POST", "/notes", nil, jn, &created); err != nil {
+		return err
+	}
+	n.GUID = created.ID
+	if len(n.Tags) == 0 {
+		return nil
+	}
+	return s.setTags(created.ID, n.Tags)
+}
+
+// UpdateNote updates the note. An empty body leaves the content unchanged.
+// Changing the resources isn't supported.
+func (s *Notestore) UpdateNote(n *clinote.Note) error {
+	if n.Resources != nil {
+		return clinote.ErrNotSupported
+	}
+	jn, err := newNote(n, n.Body)
+	if err != nil {
+		return err
+	}
+	if err = s.api.do("PUT", "/notes/"+url.PathEscape(n.GUID), nil, jn, nil); err != nil {
+		return err
+	}
+	if n.Tags == nil {
+		return nil
+	}
+	return s.setTags(n.GUID, n.Tags)
+}
+
+// newNote converts the note for the API, with the ENML body converted to
+// Markdown.
+func newNote(n *clinote.Note, body string) (*note, error) {
+	jn := &note{Title: n.Title, SourceURL: n.SourceURL, Author: n.Author}
+	if n.Notebook != nil {
+		jn.ParentID = n.Notebook.GUID
+	}
+	if n.Location != nil {
+		jn.Latitude = json.Number(fmt.Sprint(n.Location.Latitude))
+		jn.Longitude = json.Number(fmt.Sprint(n.Location.Longitude))
+		jn.Altitude = json.Number(fmt.Sprint(n.Location.Altitude))
+	}
+	if body == "" {
+		return jn, nil
+	}
+	if m := enNotePattern.FindStringSubmatch(body); m != nil {
+		body = m[1]
+	}
+	md, err := markdown.FromHTML(body)
+	if err != nil {
+		return nil, err
+	}
+	jn.Body = &md
+	return jn, nil
+}
+
+// linkResource replaces the en-media elements referencing the resource
+// with links to the uploaded resource.
+func linkResource(body string, r *clinote.Resource, id string) string {
+	return enMediaPattern.ReplaceAllStringFunc(body, func(elem string) string {
+		m := hashPattern.FindStringSubmatch(elem)
+		if m == nil || !strings.EqualFold(m[1], r.Hash) {
+			return elem
+		}
+		if strings.HasPrefix(r.Mime, "image/") {
+			return fmt.Sprintf(`<img src=":/%s" alt="%s"/>`, id, escapeAttr(r.Filename))
+		}
+		name := r.Filename
+		if name == "" {
+			name = id
+		}
+		return fmt.Sprintf(`<a href=":/%s">%s</a>`, id, escapeAttr(name))
+	})
+}
+
+func escapeAttr(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+func (s *Notestore) uploadResource(r *clinote.Resource) (string, error) {
+	filename := r.Filename
+	if filename == "" {
+		filename = "attachment"
+	}
+	var created resource
+	props := map[string]string{"title": filename}
+	if err := s.api.upload("/resources", filename, r.Data, props, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// setTags sets the note's tags by name. Missing tags are created.
+func (s *Notestore) setTags(noteID string, names []string) error {
+	var current []*tag
+	if err := s.api.list("/notes/"+url.PathEscape(noteID)+"/tags", nil, 0, -1, &current); err != nil {
+		return err
+	}
+	var all []*tag
+	if err := s.api.list("/tags", nil, 0, -1, &all); err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, name := range names {
+		keep[strings.ToLower(name)] = true
+	}
+	has := make(map[string]bool)
+	for _, t := range current {
+		has[strings.ToLower(t.Title)] = true
+		if !keep[strings.ToLower(t.Title)] {
+			if err := s.api.do("DELETE", "/tags/"+url.PathEscape(t.ID)+"/notes/"+url.PathEscape(noteID), nil, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range names {
+		if has[strings.ToLower(name)] {
+			continue
+		}
+		id := ""
+		for _, t := range all {
+			if strings.EqualFold(t.Title, name) {
+				id = t.ID
+			}
+		}
+		if id == "" {
+			var created tag
+			if err := s.api.do("POST", "/tags", nil, &tag{Title: name}, &created); err != nil {
+				return err
+			}
+			id = created.ID
+			all = append(all, &created)
+		}
+		if err := s.api.do("POST", "/tags/"+url.PathEscape(id)+"/notes", nil, map[string]string{"id": noteID}, nil); err != nil {
+			return err
+		}
+		has[strings.ToLower(name)] = true
+	}
+	return nil
+}
+
+// DeleteNote moves the note to the trash.
+func (s *Notestore) DeleteNote(guid string) error {
+	return s.api.do("DELETE", "/notes/"+url.PathEscape(guid), nil, nil, nil)
+}
+
+// GetAllNotebooks returns all the folders as notebooks.
+func (s *Notestore) GetAllNotebooks() ([]*clinote.Notebook, error) {
+	folders, err := s.folders()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*folder, len(folders))
+	for _, f := range folders {
+		byID[f.ID] = f
+	}
+	books := make([]*clinote.Notebook, len(folders))
+	for i, f := range folders {
+		books[i] = &clinote.Notebook{GUID: f.ID, Name: f.Title, Stack: stackName(f, byID)}
+	}
+	return books, nil
+}
+
+func (s *Notestore) folders() ([]*folder, error) {
+	var folders []*folder
+	err := s.api.list("/folders", url.Values{"fields": {"id,parent_id,title"}}, 0, -1, &folders)
+	return folders, err
+}
+
+// stackName returns the title of the folder's top level folder, or an
+// empty string for top level folders.
+func stackName(f *folder, byID map[string]*folder) string {
+	stack := ""
+	for parent := byID[f.ParentID]; parent != nil; parent = byID[parent.ParentID] {
+		stack = parent.Title
+	}
+	return stack
+}
+
+// GetNotebook returns the folder as a notebook.
+func (s *Notestore) GetNotebook(guid string) (*clinote.Notebook, error) {
+	books, err := s.GetAllNotebooks()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range books {
+		if b.GUID == guid {
+			return b, nil
+		}
+	}
+	return nil, &APIError{StatusCode: 404, Message: "folder not found"}
+}
+
+// CreateNotebook creates a folder for the notebook. The folder is created
+// in the stack's folder, which is created if it doesn't exist. Joplin has
+// no default notebook so defaultNotebook is ignored.
+func (s *Notestore) CreateNotebook(b *clinote.Notebook, defaultNotebook bool) error {
+	parent, err := s.stackFolder(b.Stack)
+	if err != nil {
+		return err
+	}
+	var created folder
+	if err = s.api.do("POST", "/folders", nil, &folder{Title: b.Name, ParentID: parent}, &created); err != nil {
+		return err
+	}
+	b.GUID = created.ID
+	return nil
+}
+
+// UpdateNotebook renames the folder and moves it to the stack's folder.
+func (s *Notestore) UpdateNotebook(b *clinote.Notebook) error {
+	parent, err := s.stackFolder(b.Stack)
+	if err != nil {
+		return err
+	}
+	return s.api.do("PUT", "/folders/"+url.PathEscape(b.GUID), nil, &folder{Title: b.Name, ParentID: parent}, nil)
+}
+
+// stackFolder returns the ID of the top level folder for the stack,
+// creating it if needed.
+func (s *Notestore) stackFolder(stack string) (string, error) {
+	if stack == "" {
+		return "", nil
+	}
+	folders, err := s.folders()
+	if err != nil {
+		return "", err
+	}
+	for _, f := range folders {
+		if f.ParentID == "" && f.Title == stack {
+			return f.ID, nil
+		}
+	}
+	var created folder
+	err = s.api.do("POST", "/folders", nil, &folder{Title: stack}, &created)
+	return created.ID, err
+}
+
+// GetSyncState returns the state of the notes. Joplin has no update count
+// so the cursor of the change events is used instead.
+func (s *Notestore) GetSyncState() (*clinote.SyncState, error) {
+	var events struct {
+		Cursor json.Number `json:"cursor"`
+	}
+	if err := s.api.do("GET", "/events", nil, nil, &events); err != nil {
+		return nil, err
+	}
+	cursor, _ := events.Cursor.Int64()
+	return &clinote.SyncState{UpdateCount: int32(cursor), Time: time.Now()}, nil
+}
+
+// GetNoteResources returns the note's resources, including the data.
+func (s *Notestore) GetNoteResources(guid string) ([]*clinote.Resource, error) {
+	var resources []*resource
+	query := url.Values{"fields": {"id,title,mime,filename,size"}}
+	if err := s.api.list("/notes/"+url.PathEscape(guid)+"/resources", query, 0, -1, &resources); err != nil {
+		return nil, err
+	}
+	a := make([]*clinote.Resource, len(resources))
+	for i, r := range resources {
+		var data []byte
+		if err := s.api.do("GET", "/resources/"+url.PathEscape(r.ID)+"/file", nil, nil, &data); err != nil {
+			return nil, err
+		}
+		sum := md5.Sum(data)
+		filename := r.Filename
+		if filename == "" {
+			filename = r.Title
+		}
+		a[i] = &clinote.Resource{
+			GUID:     r.ID,
+			Hash:     hex.EncodeToString(sum[:]),
+			Mime:     r.Mime,
+			Filename: filename,
+			Size:     len(data),
+			Data:     data,
+		}
+	}
+	return a, nil
+}
+
+// GetAllTags returns all the tags.
+func (s *Notestore) GetAllTags() ([]*clinote.Tag, error) {
+	var tags []*tag
+	if err := s.api.list("/tags", nil, 0, -1, &tags); err != nil {
+		return nil, err
+	}
+	a := make([]*clinote.Tag, len(tags))
+	for i, t := range tags {
+		a[i] = &clinote.Tag{GUID: t.ID, Name: t.Title}
+	}
+	return a, nil
+}
@@ -0,0 +1,193 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package joplin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/TcM1911/clinote"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestFindNotes(t *testing.T) {
+	assert := assert.New(t)
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("secret", r.URL.Query().Get("token"))
+		assert.Equal("/notes", r.URL.Path)
+		assert.Equal("updated_time", r.URL.Query().Get("order_by"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pages = append(pages, r.URL.Query().Get("page"))
+		var items []*note
+		for i := 0; i < maxPageSize && (page-1)*maxPageSize+i < 150; i++ {
+			id := (page-1)*maxPageSize + i
+			items = append(items, &note{ID: fmt.Sprint(id), ParentID: "folder", Title: "Note", UpdatedTime: 1000})
+		}
+		writeJSON(w, map[string]interface{}{"items": items, "has_more": page*maxPageSize < 150})
+	}))
+	defer server.Close()
+	ns := &Notestore{api: newAPI(server.URL, "secret")}
+
+	notes, err := ns.FindNotes(&clinote.NoteFilter{}, 90, 20)
+	assert.NoError(err)
+	if assert.Len(notes, 20) {
+		assert.Equal("90", notes[0].GUID)
+		assert.Equal("109", notes[19].GUID)
+		assert.Equal("folder", notes[0].Notebook.GUID)
+		assert.Equal(int64(1000), notes[0].Updated)
+	}
+	assert.Equal([]string{"1", "2"}, pages)
+
+	notes, err = ns.FindNotes(&clinote.NoteFilter{}, 140, 50)
+	assert.NoError(err)
+	assert.Len(notes, 10, "Should stop at the last page")
+}
+
+func TestNoteContent(t *testing.T) {
+	assert := assert.New(t)
+	var saved map[string]interface{}
+	var tagged []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/notes/abc":
+			writeJSON(w, map[string]string{"body": "# Title\n\nText"})
+		case r.Method == "POST" && r.URL.Path == "/resources":
+			file, header, err := r.FormFile("data")
+			if assert.NoError(err) {
+				data, _ := ioutil.ReadAll(file)
+				assert.Equal("png", string(data))
+				assert.Equal("image.png", header.Filename)
+			}
+			writeJSON(w, map[string]string{"id": "res"})
+		case r.Method == "POST" && r.URL.Path == "/notes":
+			json.NewDecoder(r.Body).Decode(&saved)
+			writeJSON(w, map[string]string{"id": "new"})
+		case r.URL.Path == "/notes/new/tags":
+			writeJSON(w, map[string]interface{}{"items": []*tag{}})
+		case r.URL.Path == "/tags":
+			if r.Method == "POST" {
+				writeJSON(w, map[string]string{"id": "created"})
+				return
+			}
+			writeJSON(w, map[string]interface{}{"items": []*tag{{ID: "t1", Title: "work"}}})
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/notes"):
+			tagged = append(tagged, r.URL.Path)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	ns := &Notestore{api: newAPI(server.URL, "secret")}
+
+	content, err := ns.GetNoteContent("abc")
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(content, clinote.XMLHeader+"<en-note>"))
+	assert.Contains(content, "<h1>Title</h1>")
+
+	n := &clinote.Note{
+		Title:     "New",
+		Body:      clinote.XMLHeader + `<en-note><p>Text</p><en-media type="image/png" hash="abcd"/></en-note>`,
+		Notebook:  &clinote.Notebook{GUID: "folder"},
+		Tags:      []string{"Work", "home"},
+		Resources: []*clinote.Resource{{Hash: "abcd", Mime: "image/png", Filename: "image.png", Data: []byte("png")}},
+	}
+	assert.NoError(ns.CreateNote(n))
+	assert.Equal("new", n.GUID)
+	assert.Equal("New", saved["title"])
+	assert.Equal("folder", saved["parent_id"])
+	assert.Contains(saved["body"], "Text")
+	assert.Contains(saved["body"], "](:/res)")
+	assert.Equal([]string{"/tags/t1/notes", "/tags/created/notes"}, tagged)
+
+	assert.Equal(clinote.ErrNotSupported, ns.UpdateNote(&clinote.Note{GUID: "abc", Resources: []*clinote.Resource{}}))
+}
+
+func TestNotebooksAndSyncState(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/folders":
+			writeJSON(w, map[string]interface{}{"items": []*folder{
+				{ID: "1", Title: "Work"},
+				{ID: "2", ParentID: "1", Title: "Projects"},
+				{ID: "3", ParentID: "2", Title: "Clinote"},
+			}})
+		case "/events":
+			w.Write([]byte(`{"items":[],"has_more":false,"cursor":"42"}`))
+		}
+	}))
+	defer server.Close()
+	ns := &Notestore{api: newAPI(server.URL, "secret")}
+
+	books, err := ns.GetAllNotebooks()
+	assert.NoError(err)
+	assert.Equal([]*clinote.Notebook{
+		{GUID: "1", Name: "Work"},
+		{GUID: "2", Name: "Projects", Stack: "Work"},
+		{GUID: "3", Name: "Clinote", Stack: "Work"},
+	}, books)
+
+	state, err := ns.GetSyncState()
+	assert.NoError(err)
+	assert.Equal(int32(42), state.UpdateCount)
+}
+
+func TestAPIError(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			w.Write([]byte("JoplinClipperServer"))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"Invalid "token" parameter"}`))
+	}))
+	defer server.Close()
+	a := newAPI(server.URL, "wrong")
+	assert.NoError(a.ping())
+	err := a.do("GET", "/folders", nil, nil, nil)
+	assert.Equal(&APIError{StatusCode: http.StatusForbidden}, err, "Invalid JSON should leave the message empty")
+}
+
+func TestEnableContext(t *testing.T) {
+	assert := assert.New(t)
+	defer EnableContext(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("JoplinClipperServer"))
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	EnableContext(ctx)
+
+	err := newAPI(server.URL, "secret").ping()
+	assert.Error(err, "The request should use the context")
+}
@@ -0,0 +1,476 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Operations recorded in the write journal.
+const (
+	// OpPushChange is a queued change being pushed to the server. The
+	// change is removed from the queue when the push has completed.
+	OpPushChange = "push-change"
+	// OpUpdateNote is a note update on the server followed by an update
+	// of the note in the saved search.
+	OpUpdateNote = "update-note"
+)
+
+var (
+	// ErrInterruptedPush is recorded on a queued change if the program
+	// stopped while the change was pushed.
+	ErrInterruptedPush = errors.New("interrupted while pushing, the change may already be on the server")
+	// ErrEditNotFound is returned if no interrupted edit matches the ID.
+	ErrEditNotFound = errors.New("no interrupted edit found")
+	// ErrAmbiguousEdit is returned if more than one interrupted edit
+	// matches the ID.
+	ErrAmbiguousEdit = errors.New("more than one interrupted edit matches the ID")
+)
+
+// Intent is a multi-step operation recorded in the journal before it's
+// started. It's removed when all the steps have completed, so an intent
+// left in the journal means the operation was interrupted.
+type Intent struct {
+	// ID identifies the intent in the journal.
+	ID string
+	// Operation is the kind of operation, one of the Op constants.
+	Operation string
+	// NoteGUID is the note changed by the operation.
+	NoteGUID string
+	// ChangeID is the queued change pushed by the operation.
+	ChangeID string
+	// Started is the time the operation was started.
+	Started time.Time
+}
+
+// IntentJournal stores the intents of the operations in progress.
+type IntentJournal interface {
+	// GetIntents returns the intents in the journal.
+	GetIntents() ([]*Intent, error)
+	// SaveIntents replaces the intents in the journal.
+	SaveIntents([]*Intent) error
+}
+
+// EditJournal stores the note edits in progress with the content saved in
+// the editor, so an edit interrupted before the note was uploaded can be
+// resumed.
+type EditJournal interface {
+	// GetEdits returns the edits in the journal.
+	GetEdits() ([]*JournalEdit, error)
+	// SaveEdits replaces the edits in the journal.
+	SaveEdits([]*JournalEdit) error
+}
+
+// JournalEdit is a note edit recorded in the journal. It's recorded before
+// the editor is opened and updated with the content when the editor is
+// closed. The edit is removed when the note has been uploaded or queued.
+type JournalEdit struct {
+	// ID identifies the edit in the journal.
+	ID string
+	// Note is the note opened in the editor, without the content. The GUID
+	// is empty for new notes.
+	Note *Note
+	// File is the temporary file opened in the editor.
+	File string
+	// Content is the content of the file when the editor was closed. Empty
+	// if the editor hasn't been closed. For notes in encrypted notebooks
+	// the content is encrypted with the notebook's key.
+	Content string
+	// Sealed is true if the content is encrypted.
+	Sealed bool
+	// Raw is true if the content is ENML instead of Markdown.
+	Raw bool
+	// Started is when the editor was opened.
+	Started time.Time
+}
+
+// EditorContent returns the content saved in the editor. If the editor
+// wasn't closed, the temporary file is read. Encrypted content is decrypted
+// with the notebook key in the storage.
+func (e *JournalEdit) EditorContent(db Storager) (string, error) {
+	if e.Sealed {
+		return e.openContent(db)
+	}
+	if e.Content != "" {
+		return e.Content, nil
+	}
+	data, err := ioutil.ReadFile(e.File)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// beginEdit records the edit of the note in the journal. If the storage
+// doesn't have an edit journal, nothing is recorded and nil is returned.
+func beginEdit(db Storager, n *Note, opts NoteOption) (*JournalEdit, error) {
+	if _, ok := db.(EditJournal); !ok {
+		return nil, nil
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	cpy := *n
+	cpy.Body, cpy.MD, cpy.Resources = "", "", nil
+	e := &JournalEdit{ID: id.String(), Note: &cpy, Raw: opts&RawNote != 0, Started: time.Now()}
+	return e, saveEdit(db, e)
+}
+
+// setContent sets the content saved in the editor. If the note is in an
+// encrypted notebook, the content is encrypted with the notebook's key so
+// the journal doesn't hold the note in plain text.
+func (e *JournalEdit) setContent(db Storager, content string) error {
+	key, err := storedNotebookKey(db, e.notebookGUID())
+	if err != nil || key == nil {
+		e.Content, e.Sealed = content, false
+		return err
+	}
+	sealed, err := encryptWithKey(key, []byte(content))
+	if err != nil {
+		return err
+	}
+	e.Content, e.Sealed = base64.StdEncoding.EncodeToString(sealed), true
+	return nil
+}
+
+func (e *JournalEdit) openContent(db Storager) (string, error) {
+	key, err := storedNotebookKey(db, e.notebookGUID())
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		return "", ErrNoNotebookKey
+	}
+	data, err := base64.StdEncoding.DecodeString(e.Content)
+	if err != nil {
+		return "", err
+	}
+	content, err := decryptWithKey(key, data)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (e *JournalEdit) notebookGUID() string {
+	if e.Note == nil || e.Note.Notebook == nil {
+		return ""
+	}
+	return e.Note.Notebook.GUID
+}
+
+// saveEdit adds or replaces the edit in the journal.
+func saveEdit(db Storager, e *JournalEdit) error {
+	j, ok := db.(EditJournal)
+	if !ok || e == nil {
+		return nil
+	}
+	edits, err := j.GetEdits()
+	if err != nil {
+		return err
+	}
+	for i, in := range edits {
+		if in.ID == e.ID {
+			edits[i] = e
+			return j.SaveEdits(edits)
+		}
+	}
+	return j.SaveEdits(append(edits, e))
+}
+
+// endEdit removes the edit from the journal.
+func endEdit(db Storager, e *JournalEdit) error {
+	j, ok := db.(EditJournal)
+	if !ok || e == nil {
+		return nil
+	}
+	edits, err := j.GetEdits()
+	if err != nil {
+		return err
+	}
+	var remaining []*JournalEdit
+	for _, in := range edits {
+		if in.ID != e.ID {
+			remaining = append(remaining, in)
+		}
+	}
+	return j.SaveEdits(remaining)
+}
+
+// GetInterruptedEdits returns the edits left in the journal, oldest first.
+// An edit is left if the program stopped before the note was uploaded, or
+// if the note is still open in an editor.
+func GetInterruptedEdits(db Storager) ([]*JournalEdit, error) {
+	j, ok := db.(EditJournal)
+	if !ok {
+		return nil, nil
+	}
+	return j.GetEdits()
+}
+
+// FindInterruptedEdit returns the interrupted edit with the ID. The ID can
+// be shortened to a unique prefix.
+func FindInterruptedEdit(db Storager, id string) (*JournalEdit, error) {
+	edits, err := GetInterruptedEdits(db)
+	if err != nil {
+		return nil, err
+	}
+	var found *JournalEdit
+	for _, e := range edits {
+		if !strings.HasPrefix(e.ID, id) {
+			continue
+		}
+		if found != nil {
+			return nil, ErrAmbiguousEdit
+		}
+		found = e
+	}
+	if found == nil {
+		return nil, ErrEditNotFound
+	}
+	return found, nil
+}
+
+// ResumeEdit uploads the content of the interrupted edit and removes the
+// edit from the journal. The note is created if the edit was of a new note.
+// The saved note is returned.
+func ResumeEdit(db Storager, ns NotestoreClient, e *JournalEdit) (*Note, error) {
+	content, err := e.EditorContent(db)
+	if err != nil {
+		return nil, err
+	}
+	n := *e.Note
+	if n.Notebook != nil {
+		// The notebook name is replaced when the content is parsed.
+		b := *n.Notebook
+		n.Notebook = &b
+	}
+	initialNotebook := getNotebookName(&n)
+	opts := DefaultNoteOption
+	if e.Raw {
+		opts |= RawNote
+	}
+	if err = parseNote(strings.NewReader(content), &n, opts); err != nil {
+		return nil, err
+	}
+	if name := getNotebookName(&n); name != initialNotebook {
+		if n.Notebook, err = FindNotebook(db, ns, name); err != nil {
+			return nil, err
+		}
+	}
+	if n.GUID == "" {
+		err = SaveNewNote(ns, &n, e.Raw)
+	} else if err = SaveChanges(ns, &n, opts); err == nil && !IsDryRun(ns) {
+		// The queued edits are older than the resumed one.
+		err = discardQueuedEdits(db, n.GUID)
+	}
+	if err != nil || IsDryRun(ns) {
+		return &n, err
+	}
+	return &n, endEdit(db, e)
+}
+
+// DiscardEdit removes the interrupted edit from the journal without
+// uploading it.
+func DiscardEdit(db Storager, e *JournalEdit) error {
+	return endEdit(db, e)
+}
+
+// beginIntent records the operation in the journal. If the storage doesn't
+// have a journal, nothing is recorded and nil is returned.
+func beginIntent(db Storager, op, guid, changeID string) (*Intent, error) {
+	j, ok := db.(IntentJournal)
+	if !ok {
+		return nil, nil
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	intents, err := j.GetIntents()
+	if err != nil {
+		return nil, err
+	}
+	i := &Intent{ID: id.String(), Operation: op, NoteGUID: guid, ChangeID: changeID, Started: time.Now()}
+	return i, j.SaveIntents(append(intents, i))
+}
+
+// endIntent removes the completed operation from the journal.
+func endIntent(db Storager, i *Intent) error {
+	j, ok := db.(IntentJournal)
+	if !ok || i == nil {
+		return nil
+	}
+	intents, err := j.GetIntents()
+	if err != nil {
+		return err
+	}
+	var remaining []*Intent
+	for _, in := range intents {
+		if in.ID != i.ID {
+			remaining = append(remaining, in)
+		}
+	}
+	return j.SaveIntents(remaining)
+}
+
+// RecoverJournal recovers the operations that were interrupted, for example
+// by a crash, and removes them from the journal. The local state is rolled
+// back to something consistent with the server:
+//
+// - Queued changes that were being pushed are marked as interrupted so they
+// aren't pushed twice. They have to be retried or discarded.
+//
+// - The saved search is cleared if it holds a note that was being updated,
+// since the copy may not match the note on the server.
+//
+// The recovered intents are returned.
+func RecoverJournal(db Storager) ([]*Intent, error) {
+	j, ok := db.(IntentJournal)
+	if !ok {
+		return nil, nil
+	}
+	intents, err := j.GetIntents()
+	if err != nil || len(intents) == 0 {
+		return nil, err
+	}
+	for _, i := range intents {
+		switch i.Operation {
+		case OpPushChange:
+			err = recoverPush(db, i)
+		case OpUpdateNote:
+			err = recoverNoteUpdate(db, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return intents, j.SaveIntents(nil)
+}
+
+func recoverPush(db Storager, i *Intent) error {
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if c.ID == i.ChangeID {
+			c.Interrupted = true
+			c.Error = ErrInterruptedPush.Error()
+			return db.SavePendingChanges(changes)
+		}
+	}
+	// The change was removed from the queue so the push completed.
+	return nil
+}
+
+func recoverNoteUpdate(db Storager, i *Intent) error {
+	notes, err := db.GetSearch()
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		if n.GUID == i.NoteGUID {
+			return db.SaveSearch(nil)
+		}
+	}
+	return nil
+}
+
+// updateNote pushes the note's changes to the server and replaces the note
+// in the saved search, so notes opened by their index in the last listing
+// get the new values. Both steps are recorded in the journal. If the server
+// can't be reached, the change is queued and ErrChangeQueued is returned.
+// The note as it was before the change, prev, is added to the undo log.
+// The pre and post note edit hooks are run around the change.
+func updateNote(db Storager, ns NotestoreClient, prev, n *Note) error {
+	return pushNoteUpdate(db, ns, prev, n, false, 0)
+}
+
+// updateNoteContent is updateNote for a change to the note's content. The
+// content is uploaded from the note's Markdown, or from its body with the
+// RawNote option, and undoing the change restores the content of prev. If
+// prev is nil, the change isn't added to the undo log.
+func updateNoteContent(db Storager, ns NotestoreClient, prev, n *Note, opts NoteOption) error {
+	return pushNoteUpdate(db, ns, prev, n, true, opts)
+}
+
+func pushNoteUpdate(db Storager, ns NotestoreClient, prev, n *Note, content bool, opts NoteOption) error {
+	if _, ok := db.(UndoLog); ok && prev != nil && prev.Tags == nil && n.Tags != nil {
+		// The tag names are needed to restore the tags.
+		tags, err := ns.GetAllTags()
+		if err != nil {
+			return err
+		}
+		prev.Tags = noteTagNames(prev, tags)
+	}
+	kind := UndoEdit
+	if !content && prev != nil {
+		kind = UndoUpdate
+		prev.Body = ""
+	}
+	if err := runNoteHook(ns, HookPreNoteEdit, n); err != nil {
+		return err
+	}
+	intent, err := beginIntent(db, OpUpdateNote, n.GUID, "")
+	if err != nil {
+		return err
+	}
+	if err = saveChanges(ns, n, content, opts&RawNote != 0); err == nil && !IsDryRun(ns) {
+		if err = updateSavedSearch(db, n); err == nil && prev != nil {
+			err = recordUndo(db, ns, kind, prev)
+		}
+	} else if err != nil {
+		err = QueueIfOffline(db, ChangeEdit, n, err)
+	}
+	if endErr := endIntent(db, intent); err == nil {
+		err = endErr
+	}
+	if err != nil {
+		return err
+	}
+	return runNoteHook(ns, HookPostNoteEdit, n)
+}
+
+func updateSavedSearch(db Storager, n *Note) error {
+	if n.GUID == "" {
+		return nil
+	}
+	notes, err := db.GetSearch()
+	if err != nil {
+		return err
+	}
+	for i, s := range notes {
+		if s.GUID != n.GUID {
+			continue
+		}
+		updated := *n
+		updated.Body = ""
+		updated.MD = ""
+		notes[i] = &updated
+		return db.SaveSearch(notes)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockJournalStore struct {
+	*mockStore
+	intents []*Intent
+	edits   []*JournalEdit
+}
+
+func (m *mockJournalStore) GetEdits() ([]*JournalEdit, error) {
+	return m.edits, nil
+}
+
+func (m *mockJournalStore) SaveEdits(edits []*JournalEdit) error {
+	m.edits = edits
+	return nil
+}
+
+func (m *mockJournalStore) GetIntents() ([]*Intent, error) {
+	return m.intents, nil
+}
+
+func (m *mockJournalStore) SaveIntents(intents []*Intent) error {
+	m.intents = intents
+	return nil
+}
+
+func TestJournalSync(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockJournalStore{mockStore: &mockStore{saveSyncState: func(*SyncState) error { return nil }}}
+	ns := &mockNS{getSyncState: func() (*SyncState, error) { return new(SyncState), nil }}
+	assert.NoError(QueueChange(db, ChangeCreate, &Note{Title: "New"}, nil))
+	id := db.pendingChanges[0].ID
+
+	t.Run("Push is recorded", func(t *testing.T) {
+		var recorded []*Intent
+		ns.createNote = func(*Note) error { recorded = db.intents; return expectedError }
+		assert.Equal(ErrSyncFailed, Sync(db, ns))
+		if assert.Len(recorded, 1) {
+			assert.Equal(OpPushChange, recorded[0].Operation)
+			assert.Equal(id, recorded[0].ChangeID)
+		}
+		assert.Empty(db.intents, "Intent should be removed when the push is done")
+	})
+
+	t.Run("Interrupted push", func(t *testing.T) {
+		db.intents = []*Intent{{ID: "1", Operation: OpPushChange, ChangeID: id}}
+		recovered, err := RecoverJournal(db)
+		assert.NoError(err)
+		assert.Len(recovered, 1)
+		assert.Empty(db.intents)
+		if assert.Len(db.pendingChanges, 1) {
+			assert.True(db.pendingChanges[0].Interrupted)
+			assert.Equal(ErrInterruptedPush.Error(), db.pendingChanges[0].Error)
+		}
+
+		pushed := false
+		ns.createNote = func(*Note) error { pushed = true; return nil }
+		assert.Equal(ErrSyncFailed, Sync(db, ns))
+		assert.False(pushed, "Interrupted change should not be pushed by sync")
+
+		assert.NoError(RetryChange(db, ns, id))
+		assert.True(pushed)
+		assert.Empty(db.pendingChanges)
+	})
+}
+
+func TestJournalNoteUpdate(t *testing.T) {
+	assert := assert.New(t)
+	note := &Note{GUID: "GUID", Title: "Old", Notebook: &Notebook{Name: "Old"}}
+	other := &Note{GUID: "Other", Title: "Other"}
+	notebook := &Notebook{Name: "New", GUID: "Notebook GUID"}
+	db := &mockJournalStore{mockStore: &mockStore{
+		getNotebookCache:  func() (*NotebookCacheList, error) { return &NotebookCacheList{}, nil },
+		storeNotebookList: func(*NotebookCacheList) error { return nil },
+		savedSearch:       []*Note{note, other},
+	}}
+	ns := &mockNS{getAllNotebooks: func() ([]*Notebook, error) { return []*Notebook{notebook}, nil }}
+
+	t.Run("Saved search is updated", func(t *testing.T) {
+		var recorded []*Intent
+		ns.updateNote = func(*Note) error { recorded = db.intents; return nil }
+		assert.NoError(MoveNote(db, ns, "1", "New"))
+		if assert.Len(recorded, 1) {
+			assert.Equal(OpUpdateNote, recorded[0].Operation)
+			assert.Equal("GUID", recorded[0].NoteGUID)
+		}
+		assert.Empty(db.intents)
+		assert.Equal(notebook, db.savedSearch[0].Notebook)
+		assert.Equal(other, db.savedSearch[1])
+	})
+
+	t.Run("Interrupted update", func(t *testing.T) {
+		db.intents = []*Intent{{ID: "1", Operation: OpUpdateNote, NoteGUID: "GUID"}}
+		_, err := RecoverJournal(db)
+		assert.NoError(err)
+		assert.Empty(db.savedSearch, "Saved search should be cleared")
+		assert.Empty(db.intents)
+	})
+
+	t.Run("Storage without journal", func(t *testing.T) {
+		recovered, err := RecoverJournal(db.mockStore)
+		assert.NoError(err)
+		assert.Empty(recovered)
+	})
+}
+
+func TestResumeEdit(t *testing.T) {
+	assert := assert.New(t)
+	work := &Notebook{Name: "Work", GUID: "b1"}
+	home := &Notebook{Name: "Home", GUID: "b2"}
+	db := &mockJournalStore{mockStore: &mockStore{getNotebookCache: func() (*NotebookCacheList, error) {
+		return &NotebookCacheList{Notebooks: []*Notebook{work, home}, Timestamp: time.Now(), Limit: time.Hour}, nil
+	}}}
+	var updated, created *Note
+	ns := &mockNS{
+		updateNote: func(n *Note) error { updated = n; return nil },
+		createNote: func(n *Note) error { created = n; return nil },
+	}
+	edit := &JournalEdit{ID: "1a2b", Note: &Note{GUID: "n1", Title: "Plan", Notebook: work},
+		Content: "---\ntitle: Plan B\nnotebook: Home\n---\nNew content\n"}
+	newNote := &JournalEdit{ID: "3c4d", Note: &Note{Title: "Draft", Notebook: work}, Content: "---\ntitle: Draft\nnotebook: Work\n---\nDraft\n"}
+	db.edits = []*JournalEdit{edit, newNote}
+	db.pendingChanges = []*PendingChange{{ID: "c1", Type: ChangeEdit, Note: &Note{GUID: "n1"}}}
+
+	found, err := FindInterruptedEdit(db, "1a")
+	assert.NoError(err)
+	n, err := ResumeEdit(db, ns, found)
+
+	assert.NoError(err)
+	assert.Equal(updated, n)
+	assert.Equal("Plan B", n.Title)
+	assert.Equal(home, n.Notebook)
+	assert.Equal("Work", work.Name, "The journaled notebook should not be changed")
+	assert.Contains(n.Body, "New content")
+	assert.Empty(db.pendingChanges, "The older queued edit should be discarded")
+	assert.Equal([]*JournalEdit{newNote}, db.edits)
+
+	_, err = ResumeEdit(db, ns, newNote)
+	assert.NoError(err)
+	if assert.NotNil(created, "The new note should be created") {
+		assert.Equal("Draft", created.Title)
+	}
+	assert.Empty(db.edits)
+
+	_, err = FindInterruptedEdit(db, "1a")
+	assert.Equal(ErrEditNotFound, err)
+}
+
+func TestResumeEditFromEditorFile(t *testing.T) {
+	assert := assert.New(t)
+	f, err := ioutil.TempFile("", "clinote-edit")
+	assert.NoError(err)
+	defer os.Remove(f.Name())
+	f.WriteString("---\ntitle: Plan\n---\nWritten before the crash\n")
+	f.Close()
+	db := &mockJournalStore{mockStore: new(mockStore)}
+	edit := &JournalEdit{ID: "1", Note: &Note{GUID: "n1", Title: "Plan"}, File: f.Name()}
+	db.edits = []*JournalEdit{edit}
+	var updated *Note
+	ns := &mockNS{updateNote: func(n *Note) error { updated = n; return expectedError }}
+
+	_, err = ResumeEdit(db, ns, edit)
+
+	assert.Equal(expectedError, err)
+	if assert.NotNil(updated) {
+		assert.Contains(updated.Body, "Written before the crash")
+	}
+	assert.Len(db.edits, 1, "A failed upload should be kept in the journal")
+	assert.NoError(DiscardEdit(db, edit))
+	assert.Empty(db.edits)
+}
+
+func TestEncryptedNotebookEditIsSealed(t *testing.T) {
+	assert := assert.New(t)
+	key, err := deriveNotebookKey("secret", "b1")
+	assert.NoError(err)
+	db := &struct {
+		*mockJournalStore
+		mockKeyStore
+	}{&mockJournalStore{mockStore: new(mockStore)}, mockKeyStore{"b1": key}}
+	note := &Note{GUID: "n1", Title: "Plan", Notebook: &Notebook{Name: "Private", GUID: "b1"}}
+	edit, err := beginEdit(db, note, DefaultNoteOption)
+	assert.NoError(err)
+
+	content := "---\ntitle: Plan\n---\nThe secret plan\n"
+	assert.NoError(parseEditedNote(db, strings.NewReader(content), note, DefaultNoteOption, edit))
+
+	if assert.Len(db.edits, 1) {
+		assert.True(db.edits[0].Sealed)
+		assert.NotContains(db.edits[0].Content, "secret plan", "The journal should not hold the content in plain text")
+	}
+	saved, err := edit.EditorContent(db)
+	assert.NoError(err)
+	assert.Equal(content, saved)
+
+	_, err = edit.EditorContent(db.mockJournalStore)
+	assert.Equal(ErrNoNotebookKey, err, "The content can't be read without the key")
+}
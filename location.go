@@ -0,0 +1,116 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// earthRadius is the mean radius of the earth in kilometers.
+const earthRadius = 6371.0
+
+var (
+	// ErrInvalidLocation is returned if a location can't be parsed.
+	ErrInvalidLocation = errors.New("invalid location, expected \"latitude,longitude\"")
+	// ErrInvalidNearFilter is returned if a near filter can't be parsed.
+	ErrInvalidNearFilter = errors.New("invalid filter, expected \"latitude,longitude,radius\"")
+)
+
+// Location is the geographic location of a note.
+type Location struct {
+	// Latitude in degrees.
+	Latitude float64
+	// Longitude in degrees.
+	Longitude float64
+	// Altitude in meters. Zero if not known.
+	Altitude float64
+}
+
+func (l *Location) String() string {
+	s := strconv.FormatFloat(l.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(l.Longitude, 'f', -1, 64)
+	if l.Altitude != 0 {
+		s += fmt.Sprintf(" (%sm)", strconv.FormatFloat(l.Altitude, 'f', -1, 64))
+	}
+	return s
+}
+
+// Distance returns the great-circle distance in kilometers between the locations.
+func (l *Location) Distance(o *Location) float64 {
+	lat1, lat2 := l.Latitude*math.Pi/180, o.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (o.Longitude - l.Longitude) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// ParseLocation parses a location in the format "latitude,longitude" or
+// "latitude,longitude,altitude".
+func ParseLocation(s string) (*Location, error) {
+	vals, err := parseFloats(s)
+	if err != nil || len(vals) < 2 || len(vals) > 3 || !validCoordinates(vals[0], vals[1]) {
+		return nil, ErrInvalidLocation
+	}
+	loc := &Location{Latitude: vals[0], Longitude: vals[1]}
+	if len(vals) == 3 {
+		loc.Altitude = vals[2]
+	}
+	return loc, nil
+}
+
+// ParseNearFilter parses a filter in the format "latitude,longitude,radius".
+// The radius is in kilometers.
+func ParseNearFilter(s string) (*Location, float64, error) {
+	vals, err := parseFloats(s)
+	if err != nil || len(vals) != 3 || !validCoordinates(vals[0], vals[1]) || vals[2] < 0 {
+		return nil, 0, ErrInvalidNearFilter
+	}
+	return &Location{Latitude: vals[0], Longitude: vals[1]}, vals[2], nil
+}
+
+// FilterNotesNear returns the notes with a location within the radius,
+// in kilometers, of the center. Notes without a location are excluded.
+func FilterNotesNear(notes []*Note, center *Location, radius float64) []*Note {
+	var near []*Note
+	for _, n := range notes {
+		if n.Location != nil && center.Distance(n.Location) <= radius {
+			near = append(near, n)
+		}
+	}
+	return near
+}
+
+func parseFloats(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	vals := make([]float64, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+func validCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
@@ -0,0 +1,96 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected *Location
+		err      error
+	}{
+		{"59.33,18.07", &Location{Latitude: 59.33, Longitude: 18.07}, nil},
+		{"59.33, 18.07, 28", &Location{Latitude: 59.33, Longitude: 18.07, Altitude: 28}, nil},
+		{"-33.87,151.21", &Location{Latitude: -33.87, Longitude: 151.21}, nil},
+		{"59.33", nil, ErrInvalidLocation},
+		{"91,18", nil, ErrInvalidLocation},
+		{"59,181", nil, ErrInvalidLocation},
+		{"a,b", nil, ErrInvalidLocation},
+		{"1,2,3,4", nil, ErrInvalidLocation},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			loc, err := ParseLocation(test.input)
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.expected, loc)
+		})
+	}
+}
+
+func TestParseNearFilter(t *testing.T) {
+	assert := assert.New(t)
+	loc, radius, err := ParseNearFilter("59.33,18.07,5")
+	assert.NoError(err)
+	assert.Equal(&Location{Latitude: 59.33, Longitude: 18.07}, loc)
+	assert.Equal(5.0, radius)
+
+	_, _, err = ParseNearFilter("59.33,18.07")
+	assert.Equal(ErrInvalidNearFilter, err)
+	_, _, err = ParseNearFilter("59.33,18.07,-1")
+	assert.Equal(ErrInvalidNearFilter, err)
+}
+
+func TestLocationDistance(t *testing.T) {
+	stockholm := &Location{Latitude: 59.3293, Longitude: 18.0686}
+	gothenburg := &Location{Latitude: 57.7089, Longitude: 11.9746}
+	assert.InDelta(t, 398, stockholm.Distance(gothenburg), 2)
+	assert.Equal(t, 0.0, stockholm.Distance(stockholm))
+}
+
+func TestFilterNotesNear(t *testing.T) {
+	assert := assert.New(t)
+	near := &Note{Title: "Near", Location: &Location{Latitude: 59.34, Longitude: 18.06}}
+	far := &Note{Title: "Far", Location: &Location{Latitude: 57.7089, Longitude: 11.9746}}
+	none := &Note{Title: "No location"}
+	center := &Location{Latitude: 59.3293, Longitude: 18.0686}
+
+	notes := FilterNotesNear([]*Note{near, far, none}, center, 10)
+
+	assert.Equal([]*Note{near}, notes)
+}
+
+func TestWriteNoteMeta(t *testing.T) {
+	buf := new(bytes.Buffer)
+	n := &Note{
+		GUID:     "GUID",
+		Notebook: &Notebook{Name: "Notebook"},
+		Location: &Location{Latitude: 59.33, Longitude: 18.07, Altitude: 28},
+	}
+
+	WriteNoteMeta(buf, n, DefaultTableOption)
+
+	assert.Contains(t, buf.String(), "GUID:     GUID\n")
+	assert.Contains(t, buf.String(), "Notebook: Notebook\n")
+	assert.Contains(t, buf.String(), "Location: 59.33,18.07 (28m)\n")
+}
@@ -0,0 +1,217 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogEnv is the environment variable that enables logging. The value is the
+// level, "verbose" or "debug", optionally followed by ":file" to write the
+// log to the log file in the config folder instead of stderr.
+const LogEnv = "CLINOTE_LOG"
+
+// LogFileName is the name of the log file in the config folder.
+const LogFileName = "clinote.log"
+
+// LogLevel is the amount of detail that is logged.
+type LogLevel uint8
+
+const (
+	// LogLevelOff disables logging.
+	LogLevelOff LogLevel = iota
+	// LogLevelVerbose logs API calls and sync decisions.
+	LogLevelVerbose
+	// LogLevelDebug also logs the storage operations and call details.
+	LogLevelDebug
+)
+
+var logLevelStringMapper = []string{"off", "verbose", "debug"}
+
+func (l LogLevel) String() string {
+	return logLevelStringMapper[l]
+}
+
+// ErrInvalidLogLevel is returned if the log level isn't known.
+var ErrInvalidLogLevel = errors.New("invalid log level, use off, verbose or debug")
+
+// ParseLogLevel returns the log level with the name.
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, name := range logLevelStringMapper {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return LogLevelOff, ErrInvalidLogLevel
+}
+
+var (
+	logMu    sync.Mutex
+	logOut   io.Writer
+	logLevel LogLevel
+)
+
+// SetLogOutput writes log entries up to the level to w. LogLevelOff disables
+// logging.
+func SetLogOutput(w io.Writer, level LogLevel) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logOut, logLevel = w, level
+	if w == nil {
+		logLevel = LogLevelOff
+	}
+}
+
+// LogEnabled returns true if entries at the level are logged.
+func LogEnabled(level LogLevel) bool {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return level != LogLevelOff && level <= logLevel
+}
+
+// LogVerbose logs the message with the key-value pairs at the verbose level.
+func LogVerbose(msg string, kv ...interface{}) {
+	writeLog(LogLevelVerbose, msg, kv)
+}
+
+// LogDebug logs the message with the key-value pairs at the debug level.
+func LogDebug(msg string, kv ...interface{}) {
+	writeLog(LogLevelDebug, msg, kv)
+}
+
+// writeLog writes the entry as a line of space separated key=value pairs.
+// Pairs with a nil value, like a nil error, are left out.
+func writeLog(level LogLevel, msg string, kv []interface{}) {
+	if !LogEnabled(level) {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("time=" + time.Now().Format(time.RFC3339))
+	b.WriteString(" level=" + level.String())
+	b.WriteString(" msg=" + logValue(msg))
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == nil {
+			continue
+		}
+		fmt.Fprintf(&b, " %v=%s", kv[i], logValue(fmt.Sprint(kv[i+1])))
+	}
+	b.WriteString("\n")
+	logMu.Lock()
+	defer logMu.Unlock()
+	io.WriteString(logOut, b.String())
+}
+
+// logValue quotes the value if it contains spaces, quotes or an equals sign.
+func logValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// RotatingFile is a log file that is rotated when it grows too big. The
+// rotated files get the suffixes .1, .2 and so on, .1 being the newest.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+}
+
+// OpenRotatingFile opens the log file at the path for appending. The file
+// is rotated once it's bigger than maxSize bytes and at most backups old
+// files are kept.
+func OpenRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// DefaultLogFile opens the log file in the config folder. It's rotated at
+// 1 MB and three old files are kept.
+func DefaultLogFile(cfgFolder string) (*RotatingFile, error) {
+	return OpenRotatingFile(filepath.Join(cfgFolder, LogFileName), 1<<20, 3)
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, fi.Size()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old files one step and starts a new log file.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.backups))
+	for i := f.backups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	if f.backups > 0 {
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// Close closes the log file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,79 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogging(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	SetLogOutput(&buf, LogLevelVerbose)
+	defer SetLogOutput(nil, LogLevelOff)
+
+	LogVerbose("pushed change", "id", "1", "title", "Two words", "error", nil)
+	LogDebug("storage get", "key", "settings")
+	line := buf.String()
+	assert.Contains(line, `level=verbose msg="pushed change" id=1 title="Two words"`)
+	assert.NotContains(line, "error=", "Nil values should be left out")
+	assert.NotContains(line, "storage get", "Debug entries should not be logged")
+	assert.Equal(1, strings.Count(line, "\n"))
+
+	SetLogOutput(&buf, LogLevelDebug)
+	assert.True(LogEnabled(LogLevelDebug))
+	LogDebug("storage get", "key", "settings")
+	assert.Contains(buf.String(), `level=debug msg="storage get" key=settings`)
+
+	level, err := ParseLogLevel("Debug")
+	assert.NoError(err)
+	assert.Equal(LogLevelDebug, level)
+	_, err = ParseLogLevel("loud")
+	assert.Equal(ErrInvalidLogLevel, err)
+}
+
+func TestRotatingFile(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-log")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, LogFileName)
+
+	f, err := OpenRotatingFile(path, 10, 2)
+	assert.NoError(err)
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = f.Write([]byte(line))
+		assert.NoError(err)
+	}
+	assert.NoError(f.Close())
+
+	for file, expected := range map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"} {
+		data, err := ioutil.ReadFile(file)
+		assert.NoError(err)
+		assert.Equal(expected, string(data))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(os.IsNotExist(err), "Only two old files should be kept")
+}
@@ -19,12 +19,50 @@ package markdown
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
 
 	"github.com/mattn/godown"
+	"golang.org/x/net/html"
 )
 
 func FromHTML(body string) (string, error) {
+	if !strings.Contains(body, "<table") && !strings.Contains(body, "<en-todo") {
+		return convertHTML(body)
+	}
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	markTodos(doc)
+	tables, err := convertTables(doc)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err = html.Render(buf, doc); err != nil {
+		return "", err
+	}
+	md, err := convertHTML(buf.String())
+	if err != nil {
+		return "", err
+	}
+	buf.Reset()
+	last := 0
+	for _, m := range tablePlaceholder.FindAllStringSubmatchIndex(md, -1) {
+		buf.WriteString(md[last:m[0]])
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+			buf.WriteString("\n\n")
+		}
+		i, _ := strconv.Atoi(md[m[2]:m[3]])
+		buf.WriteString(tables[i] + "\n\n")
+		last = m[1]
+	}
+	buf.WriteString(md[last:])
+	return strings.Trim(taskList(buf.String()), "\n"), nil
+}
+
+func convertHTML(body string) (string, error) {
 	buf := new(bytes.Buffer)
 	err := godown.Convert(buf, strings.NewReader(body), new(godown.Option))
 	if err != nil {
@@ -17,9 +17,29 @@
 
 package markdown
 
-import "github.com/russross/blackfriday"
+import (
+	"regexp"
 
-// ToXML converts the markdown body to Evernote's xml body style.
+	"github.com/russross/blackfriday"
+)
+
+// Blackfriday sets attributes ENML doesn't allow: the language of a fenced
+// code block as a class and explicit header IDs as an id. It also writes an
+// empty tbody for a table with only a header row, which ENML doesn't allow
+// either.
+var (
+	codeLanguage = regexp.MustCompile(`<code class="language-[^"]*">`)
+	headerID     = regexp.MustCompile(`<(h[1-6]) id="[^"]*">`)
+	emptyTbody   = regexp.MustCompile(`\s*<tbody>\s*</tbody>`)
+)
+
+// ToXML converts the markdown body to Evernote's xml body style. Task
+// list items are written as en-todo elements. The attributes ENML doesn't
+// allow are removed from the elements blackfriday writes.
 func ToXML(mdBody string) []byte {
-	return blackfriday.MarkdownCommon([]byte(mdBody))
+	body := blackfriday.MarkdownCommon([]byte(mdBody))
+	body = codeLanguage.ReplaceAll(body, []byte("<code>"))
+	body = headerID.ReplaceAll(body, []byte("<$1>"))
+	body = emptyTbody.ReplaceAll(body, nil)
+	return todoItems(body)
 }
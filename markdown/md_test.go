@@ -0,0 +1,34 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToXMLAttributes(t *testing.T) {
+	assert := assert.New(t)
+
+	actual := string(ToXML("# Title {#title}\n\n```go\nfmt.Println(\"class=\\\"x\\\"\")\n```\n"))
+
+	assert.Contains(actual, "<h1>Title</h1>", "The header ID should be removed")
+	assert.Contains(actual, "<pre><code>fmt.Println(&quot;class=\\&quot;x\\&quot;&quot;)\n</code></pre>", "The language class should be removed")
+	assert.NotContains(actual, "language-go")
+}
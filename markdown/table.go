@@ -0,0 +1,247 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/mattn/godown"
+	"golang.org/x/net/html"
+)
+
+// Tables are replaced with a placeholder before the rest of the document
+// is converted, and the placeholders are replaced with the converted
+// tables afterwards.
+var tablePlaceholder = regexp.MustCompile("\n*\uE000([0-9]+)\uE001\n*")
+
+// textAlign matches the alignment set with the style attribute.
+var textAlign = regexp.MustCompile(`text-align:\s*(left|right|center)`)
+
+// convertTables replaces the tables in the document with placeholders and
+// returns the tables converted to Markdown. Tables that can't be written
+// as a pipe table are kept as HTML.
+func convertTables(doc *html.Node) ([]string, error) {
+	var nodes []*html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "table" {
+				nodes = append(nodes, c)
+				continue
+			}
+			find(c)
+		}
+	}
+	find(doc)
+	tables := make([]string, len(nodes))
+	for i, n := range nodes {
+		md, err := tableToMarkdown(n)
+		if err != nil {
+			return nil, err
+		}
+		if md == "" {
+			buf := new(bytes.Buffer)
+			if err = html.Render(buf, n); err != nil {
+				return nil, err
+			}
+			md = todoElements.Replace(buf.String())
+		}
+		tables[i] = md
+		placeholder := &html.Node{Type: html.ElementNode, Data: "div"}
+		placeholder.AppendChild(&html.Node{Type: html.TextNode, Data: fmt.Sprintf("\uE000%d\uE001", i)})
+		n.Parent.InsertBefore(placeholder, n)
+		n.Parent.RemoveChild(n)
+	}
+	return tables, nil
+}
+
+// tableCell is a converted table cell.
+type tableCell struct {
+	text  string
+	align string
+}
+
+// tableToMarkdown returns the table as a Markdown pipe table. The first
+// row is used as the header, so its cells are th cells when the table is
+// converted back. An empty string is returned if the table has only one
+// row, cells spanning multiple rows or columns, cells with more than one
+// line, todos or nested tables. A table with only a header row would be
+// converted back without its row in the body.
+func tableToMarkdown(table *html.Node) (string, error) {
+	var rows [][]tableCell
+	var collect func(n *html.Node) (bool, error)
+	collect = func(n *html.Node) (bool, error) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "thead", "tbody", "tfoot":
+				if ok, err := collect(c); !ok || err != nil {
+					return ok, err
+				}
+			case "tr":
+				row, ok, err := tableRow(c)
+				if !ok || err != nil {
+					return ok, err
+				}
+				rows = append(rows, row)
+			}
+		}
+		return true, nil
+	}
+	ok, err := collect(table)
+	if !ok || err != nil || len(rows) < 2 {
+		return "", err
+	}
+
+	cols := 0
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	aligns := make([]string, cols)
+	widths := make([]int, cols)
+	for i := range rows {
+		for len(rows[i]) < cols {
+			rows[i] = append(rows[i], tableCell{})
+		}
+		for j, cell := range rows[i] {
+			if aligns[j] == "" {
+				aligns[j] = cell.align
+			}
+			if w := runewidth.StringWidth(cell.text); w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+	for j := range widths {
+		if widths[j] < 3 {
+			widths[j] = 3
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	writeRow := func(cells []string) {
+		for j, text := range cells {
+			buf.WriteString("| " + text + strings.Repeat(" ", widths[j]-runewidth.StringWidth(text)) + " ")
+		}
+		buf.WriteString("|\n")
+	}
+	cells := make([]string, cols)
+	for i, row := range rows {
+		for j, cell := range row {
+			cells[j] = cell.text
+		}
+		writeRow(cells)
+		if i == 0 {
+			for j := range cells {
+				cells[j] = alignmentMarker(aligns[j], widths[j])
+			}
+			writeRow(cells)
+		}
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// tableRow returns the converted cells of the row. False is returned if
+// the row can't be written as a pipe table row.
+func tableRow(tr *html.Node) ([]tableCell, bool, error) {
+	var row []tableCell
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || (c.Data != "td" && c.Data != "th") {
+			continue
+		}
+		if span(c, "colspan") > 1 || span(c, "rowspan") > 1 || hasTable(c) {
+			return nil, false, nil
+		}
+		buf := new(bytes.Buffer)
+		for n := c.FirstChild; n != nil; n = n.NextSibling {
+			if err := html.Render(buf, n); err != nil {
+				return nil, false, err
+			}
+		}
+		out := new(bytes.Buffer)
+		if err := godown.Convert(out, buf, new(godown.Option)); err != nil {
+			return nil, false, err
+		}
+		text := strings.TrimSpace(out.String())
+		if strings.Contains(text, "\n") || strings.ContainsAny(text, todoUnchecked+todoChecked) {
+			return nil, false, nil
+		}
+		row = append(row, tableCell{text: strings.Replace(text, "|", `\|`, -1), align: cellAlign(c)})
+	}
+	return row, true, nil
+}
+
+// alignmentMarker returns the delimiter row cell for the alignment.
+func alignmentMarker(align string, width int) string {
+	switch align {
+	case "left":
+		return ":" + strings.Repeat("-", width-1)
+	case "right":
+		return strings.Repeat("-", width-1) + ":"
+	case "center":
+		return ":" + strings.Repeat("-", width-2) + ":"
+	}
+	return strings.Repeat("-", width)
+}
+
+// cellAlign returns the alignment of the cell, set with the align
+// attribute or the text-align style.
+func cellAlign(n *html.Node) string {
+	if a := strings.ToLower(attr(n, "align")); a == "left" || a == "right" || a == "center" {
+		return a
+	}
+	if m := textAlign.FindStringSubmatch(strings.ToLower(attr(n, "style"))); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func span(n *html.Node, key string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(attr(n, key)))
+	if err != nil {
+		return 1
+	}
+	return v
+}
+
+func hasTable(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if (c.Type == html.ElementNode && c.Data == "table") || hasTable(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
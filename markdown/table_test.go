@@ -0,0 +1,99 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromHTMLTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		expected string
+	}{
+		{
+			"header and alignment",
+			`<table><thead><tr><th align="left">Name</th><th style="text-align: right;">Qty</th><th align="center">Note</th></tr></thead>` +
+				`<tbody><tr><td>Apple</td><td>12</td><td><b>fresh</b> | ripe</td></tr></tbody></table>`,
+			"| Name  | Qty | Note              |\n" +
+				"| :---- | --: | :---------------: |\n" +
+				"| Apple | 12  | **fresh** \\| ripe |",
+		},
+		{
+			"first row as header",
+			`<div>Before</div><table><tr><td><div>a</div></td><td>b</td></tr><tr><td>1</td></tr></table><div>After</div>`,
+			"Before\n\n| a   | b   |\n| --- | --- |\n| 1   |     |\n\nAfter",
+		},
+		{
+			"multi-line cell",
+			`<table><tr><td><div>a</div><div>b</div></td><td>c</td></tr></table>`,
+			`<table><tbody><tr><td><div>a</div><div>b</div></td><td>c</td></tr></tbody></table>`,
+		},
+		{
+			"single row",
+			`<table><tr><td>a</td><td>b</td></tr></table>`,
+			`<table><tbody><tr><td>a</td><td>b</td></tr></tbody></table>`,
+		},
+		{
+			"spanning cell",
+			`<table><tr><td colspan="2">a</td></tr><tr><td>b</td><td>c</td></tr></table>`,
+			`<table><tbody><tr><td colspan="2">a</td></tr><tr><td>b</td><td>c</td></tr></tbody></table>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := FromHTML(test.doc)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestTableRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	md := "Text\n\n" +
+		"| Name  | Qty | Note     |\n" +
+		"| :---- | --: | :------: |\n" +
+		"| Apple | 12  | a \\| b   |\n" +
+		"| Pear  | 3   | **ripe** |\n\n" +
+		"<table><tbody><tr><td><div>a</div><div>b</div></td></tr></tbody></table>\n\n" +
+		"More text"
+
+	actual, err := FromHTML(string(ToXML(md)))
+	assert.NoError(err)
+	assert.Equal(md, actual)
+}
+
+func TestSingleRowTableRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	doc := `<table><tbody><tr><td>a</td><td>b</td></tr></tbody></table>`
+	md, err := FromHTML(doc)
+	assert.NoError(err)
+	assert.Equal(doc, md)
+
+	actual, err := FromHTML(string(ToXML(md)))
+	assert.NoError(err)
+	assert.Equal(md, actual)
+
+	header := string(ToXML("| a   | b   |\n| --- | --- |"))
+	assert.NotContains(header, "<tbody>", "Should not write an empty tbody")
+	assert.Contains(header, "<th>a</th>")
+}
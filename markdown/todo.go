@@ -0,0 +1,248 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The en-todo elements are replaced with markers before the document is
+// converted, and the markers are replaced with task list items afterwards.
+const (
+	todoUnchecked = "\uE002"
+	todoChecked   = "\uE003"
+)
+
+var (
+	// todoLine matches a converted line starting with a todo marker. The
+	// list marker godown adds to todos in lists is dropped.
+	todoLine = regexp.MustCompile(`^(\s*)(?:(?:[*+-]|\d+\.)\s+)?([` + todoUnchecked + todoChecked + `])\s*(.*)$`)
+	// listLine matches a Markdown list item.
+	listLine = regexp.MustCompile(`^\s*(?:[*+-]|\d+[.)])\s`)
+	// taskItem matches the start of a task list item in the HTML.
+	taskItem = regexp.MustCompile(`<li>(?:<p>)?\[[ xX]\]`)
+	// todoElements replaces the markers in HTML, and the markers that
+	// aren't at the start of a line. Those todos can't be written as task
+	// list items, so they are kept as HTML.
+	todoElements = strings.NewReplacer(todoUnchecked, `<en-todo checked="false"/>`, todoChecked, `<en-todo checked="true"/>`)
+)
+
+// markTodos replaces the en-todo elements in the document with markers.
+// The HTML parser doesn't know en-todo is an empty element, so the content
+// it puts in the element is moved after it.
+func markTodos(doc *html.Node) {
+	var todos []*html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "en-todo" {
+				todos = append(todos, c)
+			}
+			find(c)
+		}
+	}
+	find(doc)
+	for _, n := range todos {
+		emptyTodo(n)
+		marker := todoUnchecked
+		if strings.EqualFold(attr(n, "checked"), "true") {
+			marker = todoChecked
+		}
+		n.Parent.InsertBefore(&html.Node{Type: html.TextNode, Data: marker}, n)
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// emptyTodo moves the content the HTML parser put in the en-todo element
+// after it.
+func emptyTodo(n *html.Node) {
+	for c := n.LastChild; c != nil; c = n.LastChild {
+		n.RemoveChild(c)
+		n.Parent.InsertBefore(c, n.NextSibling)
+	}
+}
+
+// taskList replaces the lines starting with a todo marker with task list
+// items. Blank lines are added around the items so they aren't read as
+// part of a paragraph, and removed between the items.
+func taskList(md string) string {
+	var out []string
+	task := false
+	lastTask := -1
+	for _, line := range strings.Split(md, "\n") {
+		m := todoLine.FindStringSubmatch(line)
+		if m == nil {
+			if task && strings.TrimSpace(line) != "" && !listLine.MatchString(line) && !strings.HasPrefix(line, " ") {
+				out = append(out, "")
+			}
+			out = append(out, todoElements.Replace(line))
+			task = false
+			continue
+		}
+		if lastTask >= 0 && strings.TrimSpace(strings.Join(out[lastTask+1:], "")) == "" {
+			out = out[:lastTask+1]
+		} else if last := len(out) - 1; last >= 0 && strings.TrimSpace(out[last]) != "" && !listLine.MatchString(out[last]) {
+			out = append(out, "")
+		}
+		box := "[ ]"
+		if m[2] == todoChecked {
+			box = "[x]"
+		}
+		out = append(out, strings.TrimRight(m[1]+"- "+box+" "+todoElements.Replace(m[3]), " "))
+		task = true
+		lastTask = len(out) - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// todoItems replaces the task list items in the HTML with en-todo
+// elements. Lists with only task items and no nested lists are written as
+// one div per item, the way Evernote writes checklists.
+func todoItems(body []byte) []byte {
+	if !taskItem.Match(body) {
+		return body
+	}
+	nodes, err := html.ParseFragment(bytes.NewReader(body), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return body
+	}
+	var lists []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type != html.ElementNode {
+			return
+		}
+		switch n.Data {
+		case "en-todo":
+			// Todos kept as HTML are parsed as elements with content.
+			emptyTodo(n)
+		case "li":
+			addTodo(n)
+		case "ul":
+			lists = append(lists, n)
+		}
+	}
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	walk(root)
+	for _, ul := range lists {
+		if checklist(ul) {
+			listToDivs(ul)
+		}
+	}
+	buf := new(bytes.Buffer)
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err = html.Render(buf, c); err != nil {
+			return body
+		}
+	}
+	return bytes.Replace(buf.Bytes(), []byte("></en-todo>"), []byte("/>"), -1)
+}
+
+// addTodo replaces the task marker at the start of the list item with an
+// en-todo element.
+func addTodo(li *html.Node) {
+	text := li.FirstChild
+	if text != nil && text.Type == html.ElementNode && text.Data == "p" {
+		text = text.FirstChild
+	}
+	if text == nil || text.Type != html.TextNode || len(text.Data) < 3 {
+		return
+	}
+	box := text.Data[:3]
+	if box != "[ ]" && box != "[x]" && box != "[X]" {
+		return
+	}
+	rest := text.Data[3:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\n' {
+		return
+	}
+	checked := "false"
+	if box != "[ ]" {
+		checked = "true"
+	}
+	text.Data = strings.TrimLeft(rest, " ")
+	todo := &html.Node{Type: html.ElementNode, Data: "en-todo", Attr: []html.Attribute{{Key: "checked", Val: checked}}}
+	text.Parent.InsertBefore(todo, text)
+}
+
+// checklist returns true if all items in the list are todos without
+// nested lists.
+func checklist(ul *html.Node) bool {
+	items := 0
+	for li := ul.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		if li.Data != "li" || !isTodo(li) || hasList(li) {
+			return false
+		}
+		items++
+	}
+	return items > 0
+}
+
+func isTodo(li *html.Node) bool {
+	first := li.FirstChild
+	if first != nil && first.Type == html.ElementNode && first.Data == "p" {
+		first = first.FirstChild
+	}
+	return first != nil && first.Type == html.ElementNode && first.Data == "en-todo"
+}
+
+func hasList(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if (c.Type == html.ElementNode && (c.Data == "ul" || c.Data == "ol")) || hasList(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// listToDivs replaces the list with a div for each item.
+func listToDivs(ul *html.Node) {
+	for li := ul.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		div := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+		for c := li.FirstChild; c != nil; c = li.FirstChild {
+			li.RemoveChild(c)
+			if c.Type == html.ElementNode && c.Data == "p" {
+				for p := c.FirstChild; p != nil; p = c.FirstChild {
+					c.RemoveChild(p)
+					div.AppendChild(p)
+				}
+				continue
+			}
+			div.AppendChild(c)
+		}
+		ul.Parent.InsertBefore(div, ul)
+	}
+	ul.Parent.RemoveChild(ul)
+}
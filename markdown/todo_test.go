@@ -0,0 +1,91 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromHTMLTodo(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		expected string
+	}{
+		{
+			"checklist",
+			`<div>Shopping</div><div><en-todo checked="false"/>Milk</div><div><en-todo checked="true"/><b>Bread</b></div><div>After</div>`,
+			"Shopping\n\n- [ ] Milk\n- [x] **Bread**\n\nAfter",
+		},
+		{
+			"list items",
+			`<ul><li><en-todo/>Call</li><li><en-todo checked="true"></en-todo>Write</li></ul>`,
+			"- [ ] Call\n- [x] Write",
+		},
+		{
+			"inside text",
+			`<div>Done <en-todo checked="true"/> here</div>`,
+			`Done <en-todo checked="true"/> here`,
+		},
+		{
+			"table cell",
+			`<table><tr><td><en-todo/>x</td></tr></table>`,
+			`<table><tbody><tr><td><en-todo checked="false"/>x</td></tr></tbody></table>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := FromHTML(test.doc)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestToXMLTodo(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("<p>Shopping</p>\n\n<div><en-todo checked=\"false\"/>Milk</div><div><en-todo checked=\"true\"/><strong>Bread</strong></div>\n",
+		string(ToXML("Shopping\n\n- [ ] Milk\n- [x] **Bread**\n")))
+	assert.Equal("<ul>\n<li><en-todo checked=\"false\"/>Call</li>\n<li>Plain</li>\n</ul>\n",
+		string(ToXML("- [ ] Call\n- Plain\n")), "Lists with other items should be kept")
+	assert.Equal("<ul>\n<li>[xy] z</li>\n</ul>\n", string(ToXML("- [xy] z\n")))
+}
+
+func TestTodoRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	md := "Shopping\n\n- [ ] Milk\n- [x] **Bread**\n- [ ] Eggs\n\nAfter"
+	actual, err := FromHTML(string(ToXML(md)))
+	assert.NoError(err)
+	assert.Equal(md, actual)
+}
+
+func TestInlineTodoRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	md, err := FromHTML(`<p>Text with <en-todo checked="false"/> in middle</p>`)
+	assert.NoError(err)
+	assert.Equal(`Text with <en-todo checked="false"/> in middle`, md, "The todo should be kept as HTML")
+
+	md = "Text with <en-todo checked=\"false\"/> in middle\n\n- [x] Done"
+	xml := string(ToXML(md))
+	assert.Equal("<p>Text with <en-todo checked=\"false\"/> in middle</p>\n\n<div><en-todo checked=\"true\"/>Done</div>\n", xml)
+	actual, err := FromHTML(xml)
+	assert.NoError(err)
+	assert.Equal(md, actual)
+}
@@ -0,0 +1,75 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"strings"
+)
+
+const meetingTimeFormat = "2006-01-02 15:04"
+
+// NewMeetingNote creates a meeting note for the event. The note is titled
+// with the date and the summary of the event and has sections for the
+// attendees, the agenda, notes and action items.
+func NewMeetingNote(e *CalendarEvent) *Note {
+	summary := e.Summary
+	if summary == "" {
+		summary = "Meeting"
+	}
+	var md []string
+	md = append(md, "**When:** "+meetingTime(e))
+	if e.Location != "" {
+		md = append(md, "", "**Where:** "+e.Location)
+	}
+	if e.Organizer != "" {
+		md = append(md, "", "**Organizer:** "+e.Organizer)
+	}
+	md = append(md, "", "## Attendees", "")
+	if len(e.Attendees) == 0 {
+		md = append(md, "* ")
+	}
+	for _, a := range e.Attendees {
+		md = append(md, "* "+a)
+	}
+	md = append(md, "", "## Agenda", "")
+	if desc := strings.TrimSpace(e.Description); desc != "" {
+		md = append(md, desc)
+	} else {
+		md = append(md, "* ")
+	}
+	md = append(md, "", "## Notes", "", "", "## Action items", "", "* ")
+	return &Note{
+		Title: e.Start.Local().Format(timeFormat) + " " + summary,
+		MD:    strings.Join(md, "\n") + "\n",
+	}
+}
+
+func meetingTime(e *CalendarEvent) string {
+	if e.AllDay {
+		return e.Start.Format(timeFormat)
+	}
+	start := e.Start.Local()
+	end := e.End.Local()
+	if !end.After(start) {
+		return start.Format(meetingTimeFormat)
+	}
+	if end.Format(timeFormat) == start.Format(timeFormat) {
+		return start.Format(meetingTimeFormat) + " - " + end.Format("15:04")
+	}
+	return start.Format(meetingTimeFormat) + " - " + end.Format(meetingTimeFormat)
+}
@@ -0,0 +1,67 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMeetingNote(t *testing.T) {
+	assert := assert.New(t)
+	e := &CalendarEvent{
+		Summary:     "Planning",
+		Description: "* Budget\n* Hiring",
+		Location:    "Room 1",
+		Attendees:   []string{"John", "Anna"},
+		Start:       time.Date(2018, 3, 12, 9, 0, 0, 0, time.Local),
+		End:         time.Date(2018, 3, 12, 10, 30, 0, 0, time.Local),
+	}
+	n := NewMeetingNote(e)
+	assert.Equal("2018-03-12 Planning", n.Title)
+	assert.Equal(expectedMeetingNote, n.MD)
+
+	n = NewMeetingNote(&CalendarEvent{Start: e.Start, AllDay: true})
+	assert.Equal("2018-03-12 Meeting", n.Title)
+	assert.True(strings.HasPrefix(n.MD, "**When:** 2018-03-12\n\n## Attendees\n\n* \n"))
+}
+
+const expectedMeetingNote = `**When:** 2018-03-12 09:00 - 10:30
+
+**Where:** Room 1
+
+## Attendees
+
+* John
+* Anna
+
+## Agenda
+
+* Budget
+* Hiring
+
+## Notes
+
+
+## Action items
+
+* 
+`
@@ -0,0 +1,329 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrCopyMismatch is returned if a moved note's copy is missing
+	// attributes of the original. The original note is kept.
+	ErrCopyMismatch = errors.New("the copy is missing attributes of the note, the note was not moved")
+	// ErrNoteWithoutNotebook is returned if a notebook is given for the
+	// copy but the note's notebook is unknown.
+	ErrNoteWithoutNotebook = errors.New("the note's notebook is unknown")
+)
+
+// Migration item kinds.
+const (
+	MigrationNotebook = "notebook"
+	MigrationTag      = "tag"
+	MigrationNote     = "note"
+)
+
+// Migration item statuses.
+const (
+	// MigrationCreated is used for items created in the target.
+	MigrationCreated = "created"
+	// MigrationExisting is used for notebooks and tags that already exist
+	// in the target.
+	MigrationExisting = "existing"
+	// MigrationSkipped is used for items that aren't copied, like tags
+	// without notes.
+	MigrationSkipped = "skipped"
+	// MigrationFailed is used for notes that couldn't be copied. They are
+	// copied again on the next run.
+	MigrationFailed = "failed"
+)
+
+// MigrationItem is a notebook, tag or note handled by a migration.
+type MigrationItem struct {
+	// Kind is the kind of item.
+	Kind string
+	// Name is the name of the notebook or tag or the note's title.
+	Name string
+	// From is the item's GUID in the source.
+	From string
+	// To is the item's GUID in the target. Empty if not known.
+	To string
+	// Status is what happened to the item.
+	Status string
+	// Err is the error for failed items.
+	Err error
+}
+
+// MigrationSummary is the number of items handled by a migration.
+type MigrationSummary struct {
+	NotebooksCreated int
+	NotebooksMapped  int
+	TagsCreated      int
+	Notes            int
+	Attachments      int
+	// Skipped is the number of notes copied by earlier runs.
+	Skipped int
+	Failed  int
+}
+
+// MigrationPageSize is the number of notes fetched at a time from the source.
+const MigrationPageSize = 100
+
+// Migrate copies the notebooks, notes, tags and attachments from the
+// source to the target. Notebooks are matched by stack and name and only
+// missing notebooks are created. Tags are created with the notes. Each
+// handled item is passed to report.
+//
+// The copied notes are recorded in the checkpoint, which is saved after
+// each note, so a stopped migration continues with the remaining notes.
+// Notes that fail are reported and left for the next run. If the API call
+// budget is used up, the migration stops and the error is returned.
+func Migrate(db Storager, src, dst NotestoreClient, cp *Checkpoint, report func(*MigrationItem)) (*MigrationSummary, error) {
+	summary := new(MigrationSummary)
+	books, err := migrateNotebooks(src, dst, summary, report)
+	if err != nil {
+		return summary, err
+	}
+	srcTags, err := src.GetAllTags()
+	if err != nil {
+		return summary, err
+	}
+	dstTags, err := dst.GetAllTags()
+	if err != nil {
+		return summary, err
+	}
+	notes, err := FindAllNotes(src, new(NoteFilter), MigrationPageSize)
+	if err != nil {
+		return summary, err
+	}
+	pending := cp.Pending(notes)
+	summary.Skipped = len(notes) - len(pending)
+	for _, n := range pending {
+		item := &MigrationItem{Kind: MigrationNote, Name: n.Title, From: n.GUID}
+		copied, err := migrateNote(src, dst, n, books, srcTags)
+		if IsAPIBudgetUsed(err) {
+			return summary, err
+		}
+		if err != nil {
+			item.Status, item.Err = MigrationFailed, err
+			summary.Failed++
+			report(item)
+			continue
+		}
+		item.To, item.Status = copied.GUID, MigrationCreated
+		summary.Notes++
+		summary.Attachments += len(copied.Resources)
+		report(item)
+		cp.MarkDone(n.GUID)
+		if err = StoreCheckpoint(db, cp); err != nil && err != ErrCheckpointNotSupported {
+			return summary, err
+		}
+	}
+	return summary, migrateTags(dst, srcTags, dstTags, summary, report)
+}
+
+// CopyNoteTo copies the note, with its content, tags and attachments, to
+// another account. The copy is created in the target's notebook with the
+// name, or in the target's default notebook if the name is empty. If move
+// is true, the note is deleted from the source after it has been copied.
+// The source note is only deleted if the copy has the note's attributes,
+// like the author and the reminder.
+func CopyNoteTo(db Storager, src, dst NotestoreClient, title, notebook string, move bool) (*Note, error) {
+	n, err := GetNote(db, src, title, "")
+	if err != nil {
+		return nil, err
+	}
+	books := make(map[string]*Notebook)
+	if notebook != "" {
+		if n.Notebook == nil {
+			return nil, ErrNoteWithoutNotebook
+		}
+		target, err := findNotebookByName(dst, notebook)
+		if err != nil {
+			return nil, err
+		}
+		books[n.Notebook.GUID] = target
+	}
+	var tags []*Tag
+	if n.Tags == nil && len(n.TagGUIDs) != 0 {
+		if tags, err = src.GetAllTags(); err != nil {
+			return nil, err
+		}
+	}
+	copied, err := migrateNote(src, dst, n, books, tags)
+	if err != nil || !move {
+		return copied, err
+	}
+	if err = verifyCopy(dst, n, copied); err != nil {
+		return copied, err
+	}
+	if err = src.DeleteNote(n.GUID); err != nil {
+		return copied, QueueIfOffline(db, ChangeDelete, n, err)
+	}
+	return copied, recordUndo(db, src, UndoDelete, n)
+}
+
+// verifyCopy checks that the created copy has the note's attributes. The
+// copy is fetched from the target so attributes dropped by the target are
+// found. If the target can't return the copy, only notes without
+// attributes are accepted.
+func verifyCopy(dst NotestoreClient, n, copied *Note) error {
+	if !hasAttributes(n) {
+		return nil
+	}
+	saved, err := getNoteMetadata(dst, copied.GUID)
+	if err == ErrNotSupported {
+		return ErrCopyMismatch
+	}
+	if err != nil {
+		return err
+	}
+	if saved.Author != n.Author || saved.Source != n.Source || saved.SourceURL != n.SourceURL || !sameReminder(saved.Reminder, n.Reminder) {
+		return ErrCopyMismatch
+	}
+	return nil
+}
+
+// hasAttributes returns true if the note has attributes that a copy could
+// lose.
+func hasAttributes(n *Note) bool {
+	return n.Author != "" || n.Source != "" || n.SourceURL != "" || n.Reminder != nil
+}
+
+func sameReminder(a, b *Reminder) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// findNotebookByName returns the notebook with the name, ignoring the case,
+// without using the notebook cache.
+func findNotebookByName(ns NotestoreClient, name string) (*Notebook, error) {
+	books, err := ns.GetAllNotebooks()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range books {
+		if strings.EqualFold(b.Name, name) {
+			return b, nil
+		}
+	}
+	return nil, ErrNoNotebookFound
+}
+
+func notebookKey(b *Notebook) string {
+	return strings.ToLower(b.Stack) + "\x00" + strings.ToLower(b.Name)
+}
+
+// migrateNotebooks creates the missing notebooks in the target and returns
+// the target notebooks by the source GUIDs.
+func migrateNotebooks(src, dst NotestoreClient, summary *MigrationSummary, report func(*MigrationItem)) (map[string]*Notebook, error) {
+	srcBooks, err := src.GetAllNotebooks()
+	if err != nil {
+		return nil, err
+	}
+	dstBooks, err := dst.GetAllNotebooks()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]*Notebook, len(dstBooks))
+	for _, b := range dstBooks {
+		existing[notebookKey(b)] = b
+	}
+	books := make(map[string]*Notebook, len(srcBooks))
+	for _, b := range srcBooks {
+		item := &MigrationItem{Kind: MigrationNotebook, Name: b.Name, From: b.GUID}
+		target, ok := existing[notebookKey(b)]
+		if ok {
+			item.Status = MigrationExisting
+			summary.NotebooksMapped++
+		} else {
+			target = &Notebook{Name: b.Name, Stack: b.Stack}
+			if err = dst.CreateNotebook(target, false); err != nil {
+				return nil, err
+			}
+			existing[notebookKey(target)] = target
+			item.Status = MigrationCreated
+			summary.NotebooksCreated++
+		}
+		item.To = target.GUID
+		books[b.GUID] = target
+		report(item)
+	}
+	return books, nil
+}
+
+// migrateNote copies the note with its content and attachments.
+func migrateNote(src, dst NotestoreClient, n *Note, books map[string]*Notebook, tags []*Tag) (*Note, error) {
+	content, err := src.GetNoteContent(n.GUID)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := src.GetNoteResources(n.GUID)
+	if err != nil {
+		return nil, err
+	}
+	copied := &Note{
+		Title:     n.Title,
+		Body:      content,
+		Created:   n.Created,
+		Updated:   n.Updated,
+		Location:  n.Location,
+		Tags:      noteTagNames(n, tags),
+		SourceURL: n.SourceURL,
+		Author:    n.Author,
+		Source:    n.Source,
+		Reminder:  n.Reminder,
+		Resources: resources,
+	}
+	if n.Notebook != nil {
+		copied.Notebook = books[n.Notebook.GUID]
+	}
+	return copied, dst.CreateNote(copied)
+}
+
+// migrateTags reports the tags. The tags are created with the notes so
+// the tags without notes are skipped.
+func migrateTags(dst NotestoreClient, srcTags, before []*Tag, summary *MigrationSummary, report func(*MigrationItem)) error {
+	after, err := dst.GetAllTags()
+	if err != nil {
+		return err
+	}
+	find := func(tags []*Tag, name string) *Tag {
+		for _, t := range tags {
+			if strings.EqualFold(t.Name, name) {
+				return t
+			}
+		}
+		return nil
+	}
+	for _, t := range srcTags {
+		item := &MigrationItem{Kind: MigrationTag, Name: t.Name, From: t.GUID, Status: MigrationSkipped}
+		if target := find(after, t.Name); target != nil {
+			item.To, item.Status = target.GUID, MigrationCreated
+			if find(before, t.Name) != nil {
+				item.Status = MigrationExisting
+			} else {
+				summary.TagsCreated++
+			}
+		}
+		report(item)
+	}
+	return nil
+}
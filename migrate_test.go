@@ -0,0 +1,214 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	assert := assert.New(t)
+	src := &mockNS{
+		getAllNotebooks: func() ([]*Notebook, error) {
+			return []*Notebook{{GUID: "s1", Name: "Work"}, {GUID: "s2", Name: "Alpha", Stack: "Projects"}}, nil
+		},
+		getAllTags: func() ([]*Tag, error) {
+			return []*Tag{{GUID: "t1", Name: "urgent"}, {GUID: "t2", Name: "unused"}}, nil
+		},
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			return []*Note{
+				{GUID: "n1", Title: "Plan", Notebook: &Notebook{GUID: "s1"}, TagGUIDs: []string{"t1"}, Created: 1000},
+				{GUID: "n2", Title: "Spec", Notebook: &Notebook{GUID: "s2"}},
+				{GUID: "n3", Title: "Broken", Notebook: &Notebook{GUID: "s2"}},
+			}, nil
+		},
+		getNoteContent: func(guid string) (string, error) {
+			if guid == "n3" {
+				return "", expectedError
+			}
+			return "content " + guid, nil
+		},
+		getResources: func(guid string) ([]*Resource, error) {
+			if guid == "n1" {
+				return []*Resource{{Hash: "h", Data: []byte("d")}}, nil
+			}
+			return nil, nil
+		},
+	}
+	var created []*Note
+	var tags []*Tag
+	dst := &mockNS{
+		getAllNotebooks: func() ([]*Notebook, error) { return []*Notebook{{GUID: "d1", Name: "work"}}, nil },
+		getAllTags:      func() ([]*Tag, error) { return tags, nil },
+		createNotebook: func(b *Notebook, d bool) error {
+			b.GUID = "new " + b.Name
+			return nil
+		},
+		createNote: func(n *Note) error {
+			n.GUID = "copy " + n.Title
+			created = append(created, n)
+			for _, name := range n.Tags {
+				tags = append(tags, &Tag{GUID: "tag " + name, Name: name})
+			}
+			return nil
+		},
+	}
+	db := &mockCheckpointStore{mockStore: new(mockStore), checkpoints: make(map[string]*Checkpoint)}
+	var items []*MigrationItem
+	report := func(item *MigrationItem) { items = append(items, item) }
+
+	summary, err := Migrate(db, src, dst, &Checkpoint{Name: "migrate"}, report)
+
+	assert.NoError(err)
+	assert.Equal(&MigrationSummary{NotebooksCreated: 1, NotebooksMapped: 1, TagsCreated: 1, Notes: 2, Attachments: 1, Failed: 1}, summary)
+	if assert.Len(created, 2) {
+		assert.Equal("content n1", created[0].Body)
+		assert.Equal("d1", created[0].Notebook.GUID, "Existing notebooks should be matched by name")
+		assert.Equal([]string{"urgent"}, created[0].Tags)
+		assert.Equal(int64(1000), created[0].Created)
+		assert.Len(created[0].Resources, 1)
+		assert.Equal(&Notebook{GUID: "new Alpha", Name: "Alpha", Stack: "Projects"}, created[1].Notebook)
+	}
+	assert.Equal([]string{"n1", "n2"}, db.checkpoints["migrate"].Done)
+	assert.Equal(&MigrationItem{Kind: MigrationNotebook, Name: "Work", From: "s1", To: "d1", Status: MigrationExisting}, items[0])
+	assert.Equal(&MigrationItem{Kind: MigrationNote, Name: "Broken", From: "n3", Status: MigrationFailed, Err: expectedError}, items[4])
+	assert.Equal(&MigrationItem{Kind: MigrationTag, Name: "unused", From: "t2", Status: MigrationSkipped}, items[6])
+
+	// The next run only copies the failed note.
+	created = nil
+	src.getNoteContent = func(guid string) (string, error) { return "content " + guid, nil }
+	summary, err = Migrate(db, src, dst, db.checkpoints["migrate"], report)
+	assert.NoError(err)
+	assert.Equal(2, summary.Skipped)
+	if assert.Len(created, 1) {
+		assert.Equal("Broken", created[0].Title)
+	}
+
+	src.getNoteContent = func(string) (string, error) { return "", ErrAPIBudgetUsed }
+	_, err = Migrate(db, src, dst, &Checkpoint{Name: "budget"}, report)
+	assert.Equal(ErrAPIBudgetUsed, err, "The migration should stop when the budget is used")
+}
+
+func TestCopyNoteTo(t *testing.T) {
+	newSource := func(deleted *[]string) *mockNS {
+		return &mockNS{
+			findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+				return []*Note{{GUID: "n1", Title: "Plan", Notebook: &Notebook{GUID: "s1"}, TagGUIDs: []string{"t1"}}}, nil
+			},
+			getAllTags:     func() ([]*Tag, error) { return []*Tag{{GUID: "t1", Name: "urgent"}}, nil },
+			getNoteContent: func(guid string) (string, error) { return "content", nil },
+			getResources: func(guid string) ([]*Resource, error) {
+				return []*Resource{{Hash: "h", Data: []byte("d")}}, nil
+			},
+			deleteNote: func(guid string) error {
+				*deleted = append(*deleted, guid)
+				return nil
+			},
+		}
+	}
+	var created *Note
+	dst := &mockNS{
+		getAllNotebooks: func() ([]*Notebook, error) { return []*Notebook{{GUID: "d1", Name: "Inbox"}}, nil },
+		createNote: func(n *Note) error {
+			n.GUID = "copy"
+			created = n
+			return nil
+		},
+	}
+
+	t.Run("Copy to notebook", func(t *testing.T) {
+		assert := assert.New(t)
+		var deleted []string
+		copied, err := CopyNoteTo(new(mockStore), newSource(&deleted), dst, "Plan", "inbox", false)
+		assert.NoError(err)
+		assert.Equal(created, copied)
+		assert.Equal("content", copied.Body)
+		assert.Equal("d1", copied.Notebook.GUID)
+		assert.Equal([]string{"urgent"}, copied.Tags)
+		assert.Len(copied.Resources, 1)
+		assert.Empty(deleted, "The source should be kept")
+	})
+
+	t.Run("Move to default notebook", func(t *testing.T) {
+		assert := assert.New(t)
+		var deleted []string
+		copied, err := CopyNoteTo(new(mockStore), newSource(&deleted), dst, "Plan", "", true)
+		assert.NoError(err)
+		assert.Nil(copied.Notebook)
+		assert.Equal([]string{"n1"}, deleted)
+	})
+
+	t.Run("Notebook for note without notebook", func(t *testing.T) {
+		assert := assert.New(t)
+		var deleted []string
+		src := newSource(&deleted)
+		src.findNotes = func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			return []*Note{{GUID: "n1", Title: "Plan"}}, nil
+		}
+		_, err := CopyNoteTo(new(mockStore), src, dst, "Plan", "inbox", true)
+		assert.Equal(ErrNoteWithoutNotebook, err)
+		assert.Empty(deleted)
+	})
+
+	t.Run("Keep source if the copy lost attributes", func(t *testing.T) {
+		assert := assert.New(t)
+		var deleted []string
+		src := newSource(&deleted)
+		reminder := &Reminder{Order: 1, Time: 2000}
+		src.findNotes = func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			return []*Note{{GUID: "n1", Title: "Plan", Author: "Me", Reminder: reminder}}, nil
+		}
+		saved := &Note{GUID: "copy", Author: "Me"}
+		target := &mockMetadataNS{mockNS: dst, metadata: func(string) (*Note, error) { return saved, nil }}
+		_, err := CopyNoteTo(new(mockStore), src, target, "Plan", "", true)
+		assert.Equal(ErrCopyMismatch, err)
+		assert.Empty(deleted)
+		assert.Equal(reminder, created.Reminder, "Reminder should be copied")
+
+		saved.Reminder = &Reminder{Order: 1, Time: 2000}
+		_, err = CopyNoteTo(new(mockStore), src, target, "Plan", "", true)
+		assert.NoError(err)
+		assert.Equal([]string{"n1"}, deleted)
+
+		_, err = CopyNoteTo(new(mockStore), src, dst, "Plan", "", true)
+		assert.Equal(ErrCopyMismatch, err, "A copy that can't be checked should not be moved")
+	})
+
+	t.Run("Missing notebook", func(t *testing.T) {
+		assert := assert.New(t)
+		var deleted []string
+		_, err := CopyNoteTo(new(mockStore), newSource(&deleted), dst, "Plan", "Archive", true)
+		assert.Equal(ErrNoNotebookFound, err)
+		assert.Empty(deleted)
+	})
+}
+
+type mockMetadataNS struct {
+	*mockNS
+	metadata func(guid string) (*Note, error)
+}
+
+func (m *mockMetadataNS) FindNoteVersions(filter *NoteFilter, offset, count int) ([]*Note, error) {
+	return nil, ErrNotSupported
+}
+
+func (m *mockMetadataNS) GetNoteMetadata(guid string) (*Note, error) {
+	return m.metadata(guid)
+}
@@ -0,0 +1,174 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedProxy is returned if the proxy isn't an http, https or
+	// socks5 URL.
+	ErrUnsupportedProxy = errors.New("the proxy has to be an http, https or socks5 URL")
+	// ErrUnknownTLSVersion is returned if the TLS version isn't known.
+	ErrUnknownTLSVersion = errors.New("unknown TLS version, use 1.0, 1.1, 1.2 or 1.3")
+	// ErrInvalidCABundle is returned if no certificates could be read from
+	// the CA bundle.
+	ErrInvalidCABundle = errors.New("no certificates found in the CA bundle")
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseProxyURL parses the proxy address. The scheme has to be http, https
+// or socks5. If no scheme is given, http is used.
+func ParseProxyURL(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, ErrUnsupportedProxy
+	}
+	if u.Host == "" {
+		return nil, ErrUnsupportedProxy
+	}
+	return u, nil
+}
+
+// ParseTLSVersion returns the TLS version for a version number like 1.2.
+func ParseTLSVersion(s string) (uint16, error) {
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(s), "tls")]
+	if !ok {
+		return 0, ErrUnknownTLSVersion
+	}
+	return v, nil
+}
+
+// ConfigureTransport applies the proxy and TLS settings to the transport.
+// If no proxy is set, the proxy from the environment is used. The proxy
+// isn't used for localhost and the hosts in NO_PROXY. The certificates in
+// the CA bundle are trusted in addition to the system's.
+func ConfigureTransport(t *http.Transport, s *Settings) error {
+	if s.Proxy != "" {
+		u, err := ParseProxyURL(s.Proxy)
+		if err != nil {
+			return err
+		}
+		noProxy := os.Getenv("NO_PROXY")
+		if noProxy == "" {
+			noProxy = os.Getenv("no_proxy")
+		}
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL.Host, noProxy) {
+				return nil, nil
+			}
+			return u, nil
+		}
+	}
+	if s.CABundle == "" && s.TLSMinVersion == "" {
+		return nil
+	}
+	cfg := new(tls.Config)
+	if t.TLSClientConfig != nil {
+		cfg = t.TLSClientConfig.Clone()
+	}
+	if s.CABundle != "" {
+		pem, err := ioutil.ReadFile(s.CABundle)
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return ErrInvalidCABundle
+		}
+		cfg.RootCAs = pool
+	}
+	if s.TLSMinVersion != "" {
+		v, err := ParseTLSVersion(s.TLSMinVersion)
+		if err != nil {
+			return err
+		}
+		cfg.MinVersion = v
+	}
+	t.TLSClientConfig = cfg
+	return nil
+}
+
+// bypassProxy returns true if the host is localhost, a loopback address or
+// matches an entry in the comma separated NO_PROXY list. An entry matches
+// the host and its subdomains, and "*" matches all hosts.
+func bypassProxy(hostport, noProxy string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = hostport, ""
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProxyURL(t *testing.T) {
+	assert := assert.New(t)
+	u, err := ParseProxyURL("socks5://localhost:1080")
+	assert.NoError(err)
+	assert.Equal("socks5", u.Scheme)
+	u, err = ParseProxyURL("proxy.corp:3128")
+	assert.NoError(err)
+	assert.Equal("http://proxy.corp:3128", u.String(), "Should default to http")
+	_, err = ParseProxyURL("ftp://proxy.corp")
+	assert.Equal(ErrUnsupportedProxy, err)
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	assert := assert.New(t)
+	v, err := ParseTLSVersion("1.2")
+	assert.NoError(err)
+	assert.Equal(uint16(tls.VersionTLS12), v)
+	v, err = ParseTLSVersion("TLS1.3")
+	assert.NoError(err)
+	assert.Equal(uint16(tls.VersionTLS13), v)
+	_, err = ParseTLSVersion("2.0")
+	assert.Equal(ErrUnknownTLSVersion, err)
+}
+
+func TestConfigureTransport(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("proxy_and_tls_version", func(t *testing.T) {
+		tr := new(http.Transport)
+		assert.NoError(ConfigureTransport(tr, &Settings{Proxy: "http://proxy.corp:3128", TLSMinVersion: "1.2"}))
+		req, _ := http.NewRequest("GET", "https://www.evernote.com", nil)
+		u, err := tr.Proxy(req)
+		assert.NoError(err)
+		assert.Equal("proxy.corp:3128", u.Host)
+		assert.Equal(uint16(tls.VersionTLS12), tr.TLSClientConfig.MinVersion)
+	})
+
+	t.Run("no_proxy", func(t *testing.T) {
+		defer os.Setenv("NO_PROXY", os.Getenv("NO_PROXY"))
+		os.Setenv("NO_PROXY", "internal.corp, .example.com,10.0.0.0/8")
+		tr := new(http.Transport)
+		assert.NoError(ConfigureTransport(tr, &Settings{Proxy: "http://proxy.corp:3128"}))
+		for _, u := range []string{"http://localhost:41184/notes", "http://127.0.0.1:41184", "https://internal.corp", "https://wiki.internal.corp", "https://www.example.com", "http://10.1.2.3"} {
+			req, _ := http.NewRequest("GET", u, nil)
+			proxy, err := tr.Proxy(req)
+			assert.NoError(err)
+			assert.Nil(proxy, "Should not use the proxy for "+u)
+		}
+		req, _ := http.NewRequest("GET", "https://www.evernote.com", nil)
+		proxy, err := tr.Proxy(req)
+		assert.NoError(err)
+		assert.NotNil(proxy, "Should use the proxy for other hosts")
+	})
+
+	t.Run("no_settings", func(t *testing.T) {
+		tr := new(http.Transport)
+		assert.NoError(ConfigureTransport(tr, new(Settings)))
+		assert.Nil(tr.Proxy)
+		assert.Nil(tr.TLSClientConfig)
+	})
+
+	t.Run("ca_bundle", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer srv.Close()
+		dir, err := ioutil.TempDir("", "clinote-test")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "ca.pem")
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		assert.NoError(ioutil.WriteFile(path, data, 0600))
+
+		tr := new(http.Transport)
+		assert.NoError(ConfigureTransport(tr, &Settings{CABundle: path}))
+		resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if assert.NoError(err, "The server's certificate should be trusted") {
+			resp.Body.Close()
+		}
+	})
+
+	t.Run("invalid_ca_bundle", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "clinote-test")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "ca.pem")
+		assert.NoError(ioutil.WriteFile(path, []byte("not a certificate"), 0600))
+		err = ConfigureTransport(new(http.Transport), &Settings{CABundle: path})
+		assert.Equal(ErrInvalidCABundle, err)
+	})
+}
@@ -21,10 +21,13 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"strconv"
 	"strings"
 
@@ -59,6 +62,14 @@ var (
 var (
 	// ErrNoNoteFound is returned if search resulted in no notes found.
 	ErrNoNoteFound = errors.New("no note found")
+	// ErrNotInSearch is returned if a note is referred to by a number that
+	// isn't in the last search result.
+	ErrNotInSearch = errors.New("no note with the number in the last search result, list it with clinote last")
+	// ErrNoMetaChange is returned if no metadata fields were given to update.
+	ErrNoMetaChange = errors.New("no metadata changes given")
+	// ErrNoChanges is returned if an edited note is identical to the saved
+	// version, in which case it isn't uploaded.
+	ErrNoChanges = errors.New("no changes")
 )
 
 // NoteOption are used for options around notes.
@@ -72,6 +83,9 @@ const (
 	// UseRecoveryPointNote should be used to signal that the user wants to
 	// reopen the note that the note store failed to save.
 	UseRecoveryPointNote
+	// RenderNote option will display the note's Markdown rendered with
+	// terminal styling instead of the markup.
+	RenderNote
 )
 
 // Note is the structure of an Evernote note.
@@ -92,6 +106,40 @@ type Note struct {
 	Created int64
 	// Updated
 	Updated int64
+	// USN is the note's update sequence number. Zero if the backend
+	// doesn't have one.
+	USN int32
+	// Location is where the note was created. Nil if not set.
+	Location *Location
+	// Tags is the names of the note's tags. Nil if the tags are unknown.
+	Tags []string
+	// TagGUIDs is the GUIDs of the note's tags, set by note searches
+	// where the names are unknown.
+	TagGUIDs []string
+	// SourceURL is the URL the note's content originates from.
+	SourceURL string
+	// Author is the note's author.
+	Author string
+	// Source describes how the note was created, for example "mail.smtp".
+	Source string
+	// Reminder is the note's reminder. Nil if the note has no reminder.
+	Reminder *Reminder
+	// Resources are the files attached to the note. Nil if they haven't
+	// been fetched.
+	Resources []*Resource `xml:"-"`
+}
+
+// NoteMeta holds the metadata changes for a note. Empty fields are
+// left unchanged. A non-nil empty Tags removes all tags from the note.
+type NoteMeta struct {
+	// Title is the new note title.
+	Title string
+	// Notebook is the name of the notebook the note is moved to.
+	Notebook string
+	// Tags replaces the note's tags.
+	Tags []string
+	// SourceURL is the new source URL.
+	SourceURL string
 }
 
 // Hash returns the hash for the note. If raw equals true, the raw
@@ -107,6 +155,28 @@ func (n *Note) Hash(raw bool) []byte {
 	return hasher.Sum(nil)
 }
 
+// UploadHash returns a hash of the content and the metadata that would be
+// uploaded for the note. The content is hashed in its converted ENML
+// form, so formatting that doesn't survive the conversion is ignored. The
+// updated timestamp isn't included.
+func (n *Note) UploadHash(raw bool) []byte {
+	meta, _ := json.Marshal(struct {
+		Title     string
+		Notebook  string
+		Tags      []string
+		SourceURL string
+		Author    string
+		Source    string
+		Created   int64
+		Location  *Location
+		Reminder  *Reminder
+	}{n.Title, getNotebookName(n), n.Tags, n.SourceURL, n.Author, n.Source, n.Created, n.Location, n.Reminder})
+	hasher := sha256.New()
+	hasher.Write(meta)
+	hasher.Write([]byte(uploadContent(n, raw)))
+	return hasher.Sum(nil)
+}
+
 // NoteFilter is the search filter for notes.
 type NoteFilter struct {
 	// NotebookGUID is the GUID for the notebook to limit the search to.
@@ -122,23 +192,51 @@ func FindNotes(ns NotestoreClient, filter *NoteFilter, offset int, count int) ([
 	return ns.FindNotes(filter, offset, count)
 }
 
+// FindAllNotes returns all the notes matching the filter. The notes are
+// fetched from the server pageSize notes at a time.
+func FindAllNotes(ns NotestoreClient, filter *NoteFilter, pageSize int) ([]*Note, error) {
+	var notes []*Note
+	for offset := 0; ; offset += pageSize {
+		page, err := ns.FindNotes(filter, offset, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, page...)
+		if len(page) < pageSize {
+			return notes, nil
+		}
+	}
+}
+
 // GetNote gets the note metadata in the notebook from the server.
-// If the notebook is an empty string, the first matching note will
-// be returned.
+// If the notebook is an empty string, all notebooks are searched. If the
+// title doesn't match exactly one note, the NotePicker is used to pick
+// the note. A number always refers to the note with the number in the
+// last search result, ErrNotInSearch is returned if there's no such note.
 func GetNote(db Storager, ns NotestoreClient, title, notebook string) (*Note, error) {
-	// Check if the title is a number. If it is
-	// assume that the user wants to get the note
-	// from a saved search.
+	return getNote(db, ns, title, notebook, true)
+}
+
+// GetNoteExact gets the note with the title in the notebook from the
+// server, like GetNote, but notes with similar titles are never picked.
+// If several notes have the title, the NotePicker picks one of them. It's
+// used for titles made by clinote, where a similar title is a different
+// note, and for titles already resolved by the user.
+func GetNoteExact(db Storager, ns NotestoreClient, title, notebook string) (*Note, error) {
+	return getNote(db, ns, title, notebook, false)
+}
+
+func getNote(db Storager, ns NotestoreClient, title, notebook string, pick bool) (*Note, error) {
 	index, err := strconv.Atoi(title)
 	if err == nil && index > 0 {
-		// Get note from saved search
 		notes, err := db.GetSearch()
 		if err != nil {
 			return nil, err
 		}
-		if index <= len(notes) {
-			return notes[index-1], nil
+		if index > len(notes) {
+			return nil, ErrNotInSearch
 		}
+		return notes[index-1], nil
 	}
 
 	filter := new(NoteFilter)
@@ -154,37 +252,74 @@ func GetNote(db Storager, ns NotestoreClient, title, notebook string) (*Note, er
 	if err != nil {
 		return nil, err
 	}
-	var note *Note
+	var exact []*Note
 	for _, n := range notes {
 		if n.Title == title {
-			note = n
-			break
+			exact = append(exact, n)
 		}
 	}
-	if note == nil {
+	if !pick && len(exact) == 0 {
 		return nil, ErrNoNoteFound
 	}
-	return note, nil
+	if !pick && len(exact) > 1 && NotePicker != nil {
+		return NotePicker(title, exact)
+	}
+	if !pick || len(exact) == 1 {
+		return exact[0], nil
+	}
+	return pickNote(ns, filter, title, notes, exact)
 }
 
 // GetNoteWithContent returns the note with content from the user's notestore.
 func GetNoteWithContent(db Storager, ns NotestoreClient, title string) (*Note, error) {
 	n, err := GetNote(db, ns, title, "")
-	content, err := ns.GetNoteContent(n.GUID)
 	if err != nil {
 		return nil, err
 	}
-	err = decodeXML(content, n)
+	return withNoteContent(db, ns, n)
+}
+
+// getNoteExactWithContent returns the note with exactly the title, with
+// its content. The NotePicker isn't used.
+func getNoteExactWithContent(db Storager, ns NotestoreClient, title string) (*Note, error) {
+	n, err := GetNoteExact(db, ns, title, "")
 	if err != nil {
 		return nil, err
 	}
-	n.MD, err = markdown.FromHTML(n.Body)
+	return withNoteContent(db, ns, n)
+}
+
+// withNoteContent adds the content to the note.
+func withNoteContent(db Storager, ns NotestoreClient, n *Note) (*Note, error) {
+	content, err := getCachedNoteContent(db, ns, n)
 	if err != nil {
 		return nil, err
 	}
+	if err = parseNoteContent(content, n); err != nil {
+		return nil, err
+	}
+	warnMirrorNote(db, ns, n, historyFetch, false)
 	return n, nil
 }
 
+// getNoteContent fetches the note's content from the notestore.
+func getNoteContent(ns NotestoreClient, n *Note) error {
+	content, err := ns.GetNoteContent(n.GUID)
+	if err != nil {
+		return err
+	}
+	return parseNoteContent(content, n)
+}
+
+func parseNoteContent(content string, n *Note) error {
+	err := decodeXML(content, n)
+	if err != nil {
+		return err
+	}
+	n.MD, err = markdown.FromHTML(n.Body)
+	return err
+}
+
 // SaveChanges updates the changes to the note on the server.
 func SaveChanges(ns NotestoreClient, n *Note, opts NoteOption) error {
 	return saveChanges(ns, n, true, opts&RawNote != 0)
@@ -196,8 +331,9 @@ func ChangeTitle(db Storager, ns NotestoreClient, old, new string) error {
 	if err != nil {
 		return err
 	}
+	prev := *n
 	n.Title = new
-	return saveChanges(ns, n, false, false)
+	return updateNote(db, ns, &prev, n)
 }
 
 // MoveNote moves the note to a new notebook.
@@ -210,30 +346,66 @@ func MoveNote(db Storager, ns NotestoreClient, noteTitle, notebookName string) e
 	if err != nil {
 		return err
 	}
+	prev := *n
 	n.Notebook = b
-	return saveChanges(ns, n, false, false)
+	return updateNote(db, ns, &prev, n)
 }
 
-// DeleteNote moves a note from the notebook to the trash can.
+// UpdateNoteMeta updates the note's metadata without downloading or
+// uploading the note content.
+func UpdateNoteMeta(db Storager, ns NotestoreClient, title string, meta *NoteMeta) error {
+	if meta.Title == "" && meta.Notebook == "" && meta.Tags == nil && meta.SourceURL == "" {
+		return ErrNoMetaChange
+	}
+	n, err := GetNote(db, ns, title, "")
+	if err != nil {
+		return err
+	}
+	prev := *n
+	if meta.Notebook != "" {
+		b, err := FindNotebook(db, ns, meta.Notebook)
+		if err != nil {
+			return err
+		}
+		n.Notebook = b
+	}
+	if meta.Title != "" {
+		n.Title = meta.Title
+	}
+	if meta.Tags != nil {
+		n.Tags = meta.Tags
+	}
+	if meta.SourceURL != "" {
+		n.SourceURL = meta.SourceURL
+	}
+	// Don't send any content, the server keeps the current content.
+	n.Body = ""
+	return updateNote(db, ns, &prev, n)
+}
+
+// DeleteNote moves a note from the notebook to the trash can. If the server
+// can't be reached, the deletion is queued and ErrChangeQueued is returned.
 func DeleteNote(db Storager, ns NotestoreClient, title, notebook string) error {
 	n, err := GetNote(db, ns, title, notebook)
 	if err != nil {
 		return err
 	}
+	if err = runNoteHook(ns, HookPreNoteDelete, n); err != nil {
+		return err
+	}
 	err = ns.DeleteNote(n.GUID)
 	if err != nil {
+		return QueueIfOffline(db, ChangeDelete, n, err)
+	}
+	if err = recordUndo(db, ns, UndoDelete, n); err != nil {
 		return err
 	}
-	return nil
+	return runNoteHook(ns, HookPostNoteDelete, n)
 }
 
 func saveChanges(ns NotestoreClient, n *Note, updateContent, useRawContent bool) error {
 	if updateContent {
-		body := toXML(n.MD)
-		if useRawContent {
-			body = fmt.Sprintf("%s<en-note>%s</en-note>", XMLHeader, n.Body)
-		}
-		n.Body = body
+		n.Body = uploadContent(n, useRawContent)
 	}
 	err := ns.UpdateNote(n)
 	if err != nil {
@@ -242,6 +414,14 @@ func saveChanges(ns NotestoreClient, n *Note, updateContent, useRawContent bool)
 	return nil
 }
 
+// uploadContent returns the ENML content that is uploaded for the note.
+func uploadContent(n *Note, raw bool) string {
+	if raw {
+		return fmt.Sprintf("%s<en-note>%s</en-note>", XMLHeader, n.Body)
+	}
+	return toXML(n.MD)
+}
+
 // SaveNewNote pushes the new note to the server.
 func SaveNewNote(ns NotestoreClient, n *Note, raw bool) error {
 	var body string
@@ -253,10 +433,13 @@ func SaveNewNote(ns NotestoreClient, n *Note, raw bool) error {
 		body = XMLHeader + "<en-note></en-note>"
 	}
 	n.Body = body
+	if err := runNoteHook(ns, HookPreNoteCreate, n); err != nil {
+		return err
+	}
 	if err := ns.CreateNote(n); err != nil {
 		return err
 	}
-	return nil
+	return runNoteHook(ns, HookPostNoteCreate, n)
 }
 
 // EditNote opens the editor so the user can edit the note. Once the user closes the
@@ -276,57 +459,146 @@ func EditNote(client *Client, title string, opts NoteOption) error {
 	if err != nil {
 		return err
 	}
-	oldHash := note.Hash(opts&RawNote != 0)
+	prev := *note
 	nb, err := GetNotebook(client.NoteStore, note.Notebook.GUID)
 	if err != nil {
 		return err
 	}
 	note.Notebook = nb
+	if err = runNoteHook(ns, HookPreNoteEdit, note); err != nil {
+		return err
+	}
 	initialNotebook := getNotebookName(note)
-	cacheFile, err := editNote(client, note, opts)
+	oldHash := note.UploadHash(opts&RawNote != 0)
+	edit, err := beginEdit(db, note, opts)
 	if err != nil {
 		return err
 	}
+	cacheFile, err := editNote(client, note, opts, edit)
+	if err != nil {
+		endEdit(db, edit)
+		return err
+	}
 	defer cacheFile.CloseAndRemove()
-	err = parseNote(cacheFile, note, opts)
+	err = parseEditedNote(db, cacheFile, note, opts, edit)
 	if err != nil {
 		return err
 	}
+	// Formats that don't keep all the Markdown would change the content
+	// of notes that weren't edited, so the saved content is kept if the
+	// edited content is what the note was shown as.
+	if opts&RawNote == 0 && note.MD == parseFormattedContent(strings.Trim(formatContent(prev.MD), "\n")) {
+		note.MD = prev.MD
+	}
 	err = checkForNotebookAndUpdate(client, note, initialNotebook)
 	if err != nil {
 		return err
 	}
-	if bytes.Equal(oldHash, note.Hash(opts&RawNote != 0)) && initialNotebook == note.Notebook.Name {
-		return nil
+	if bytes.Equal(oldHash, note.UploadHash(opts&RawNote != 0)) {
+		if err = endEdit(db, edit); err != nil {
+			return err
+		}
+		return ErrNoChanges
 	}
 	err = SaveChanges(ns, note, opts)
 	if err != nil {
 		saveErr := db.SaveNoteRecoveryPoint(note)
 		if saveErr != nil {
 			err = errors.New("Error when saving note: " + err.Error() + "\nFailed to create recovery point: " + saveErr.Error())
+		} else if queueErr := QueueChange(db, ChangeEdit, note, err); queueErr != nil {
+			err = errors.New("Error when saving note: " + err.Error() + "\nFailed to queue the change: " + queueErr.Error())
+		} else {
+			// The queued change replaces the edit in the journal.
+			if endErr := endEdit(db, edit); endErr != nil {
+				return endErr
+			}
+			if IsOffline(err) {
+				err = ErrChangeQueued
+			}
 		}
+		return err
 	}
-	return err
+	if err = endEdit(db, edit); err != nil {
+		return err
+	}
+	if IsDryRun(ns) {
+		return nil
+	}
+	warnMirrorNote(db, ns, note, historyEdit, opts&RawNote != 0)
+	if opts&UseRecoveryPointNote == 0 {
+		if err = recordUndo(db, ns, UndoEdit, &prev); err != nil {
+			return err
+		}
+	}
+	// The queued edits are older than the saved version.
+	if err = discardQueuedEdits(db, note.GUID); err != nil {
+		return err
+	}
+	return runNoteHook(ns, HookPostNoteEdit, note)
 }
 
 // CreateAndEditNewNote creates a new note and opens it in the client's editor.
 // Once the editor has been closed, the note is saved to the notestore.
 func CreateAndEditNewNote(client *Client, note *Note, opts NoteOption) error {
 	initialNotebook := getNotebookName(note)
-	cacheFile, err := editNote(client, note, opts)
+	edit, err := beginEdit(client.Store, note, opts)
+	if err != nil {
+		return err
+	}
+	cacheFile, err := editNote(client, note, opts, edit)
 	if err != nil {
+		endEdit(client.Store, edit)
 		return err
 	}
 	defer cacheFile.CloseAndRemove()
-	err = parseNote(cacheFile, note, opts)
+	err = parseEditedNote(client.Store, cacheFile, note, opts, edit)
 	if err != nil {
 		return err
 	}
+	if strings.TrimSpace(note.Title) == "" {
+		settings, err := client.Store.GetSettings()
+		if err != nil {
+			return err
+		}
+		SetNewNoteTitle(settings, note, opts)
+	}
 	err = checkForNotebookAndUpdate(client, note, initialNotebook)
 	if err != nil {
 		return err
 	}
-	return SaveNewNote(client.NoteStore, note, opts&RawNote != 0)
+	err = SaveNewNote(client.NoteStore, note, opts&RawNote != 0)
+	if err != nil {
+		if queueErr := QueueChange(client.Store, ChangeCreate, note, err); queueErr != nil {
+			return errors.New("Error when saving note: " + err.Error() + "\nFailed to queue the change: " + queueErr.Error())
+		} else if IsOffline(err) {
+			err = ErrChangeQueued
+		}
+	} else if !IsDryRun(client.NoteStore) {
+		warnMirrorNote(client.Store, client.NoteStore, note, historyCreate, opts&RawNote != 0)
+	}
+	if endErr := endEdit(client.Store, edit); err == nil {
+		err = endErr
+	}
+	return err
+}
+
+// parseEditedNote reads the note from the file closed by the editor. The
+// content is saved to the edit in the journal before the note is parsed,
+// so the edit can be resumed if the upload is interrupted.
+func parseEditedNote(db Storager, r io.Reader, note *Note, opts NoteOption, edit *JournalEdit) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if edit != nil {
+		if err = edit.setContent(db, string(data)); err != nil {
+			return err
+		}
+		if err = saveEdit(db, edit); err != nil {
+			return err
+		}
+	}
+	return parseNote(bytes.NewReader(data), note, opts)
 }
 
 func checkForNotebookAndUpdate(client *Client, note *Note, initialNotebook string) error {
@@ -357,7 +629,7 @@ func randomFilename(prepend string) (string, error) {
 	return prepend + id.String(), nil
 }
 
-func editNote(client *Client, note *Note, opts NoteOption) (CacheFile, error) {
+func editNote(client *Client, note *Note, opts NoteOption, edit *JournalEdit) (CacheFile, error) {
 	filename := ""
 
 	// If the note has a GUID == "", it is a new note.
@@ -374,8 +646,7 @@ func editNote(client *Client, note *Note, opts NoteOption) (CacheFile, error) {
 		// Since the GUID is an empty string for new notes, we can allow a append of it.
 		filename += note.GUID + ".xml"
 	} else {
-		// body = note.MD
-		filename += note.GUID + ".md"
+		filename += note.GUID + NoteFormat.Extension()
 	}
 	cacheFile, err := client.NewCacheFile(filename)
 	if err != nil {
@@ -392,6 +663,14 @@ func editNote(client *Client, note *Note, opts NoteOption) (CacheFile, error) {
 	if err != nil {
 		return nil, err
 	}
+	if edit != nil {
+		// Record the file so the content can be recovered if the program
+		// is stopped while the note is edited.
+		edit.File = cacheFile.FilePath()
+		if err = saveEdit(client.Store, edit); err != nil {
+			return nil, err
+		}
+	}
 	err = client.Edit(cacheFile)
 	if err != nil {
 		return nil, err
@@ -456,7 +735,7 @@ func parseContent(scanner *bufio.Scanner, n *Note, opts NoteOption) error {
 	if opts&RawNote != 0 {
 		n.Body = strings.Trim(buf.String(), "\n")
 	} else {
-		n.MD = strings.Trim(buf.String(), "\n")
+		n.MD = parseFormattedContent(strings.Trim(buf.String(), "\n"))
 	}
 	return nil
 }
@@ -479,16 +758,30 @@ func writeNoteHeader(w io.Writer, n *Note) error {
 	return nil
 }
 
-// WriteNote writes the note using the provided writer.
+// WriteNote writes the note using the provided writer. If the writer is a
+// terminal, the Markdown is styled by the output theme.
 func WriteNote(w io.Writer, n *Note, opts NoteOption) error {
-	if err := writeNoteHeader(w, n); err != nil {
+	return writeNote(w, n, opts, colorTheme(w, DefaultTableOption), TerminalWidth(w))
+}
+
+// writeNote writes the note with the theme. The width is the terminal's
+// width, used when the note is rendered.
+func writeNote(w io.Writer, n *Note, opts NoteOption, theme *Theme, width int) error {
+	if opts&RenderNote != 0 && opts&RawNote == 0 {
+		return writeRenderedNote(w, n, theme, width)
+	}
+	var header bytes.Buffer
+	writeNoteHeader(&header, n)
+	if _, err := io.WriteString(w, theme.NoteHeader.Paint(strings.TrimSuffix(header.String(), "\n"))+"\n"); err != nil {
 		return err
 	}
 	var err error
 	if opts&RawNote != 0 {
 		_, err = w.Write([]byte(n.Body))
+	} else if isMarkdownFormat() {
+		_, err = io.WriteString(w, paintMarkdown(n.MD, theme))
 	} else {
-		_, err = w.Write([]byte(n.MD))
+		_, err = io.WriteString(w, formatContent(n.MD))
 	}
 	if err != nil {
 		return err
@@ -501,6 +794,18 @@ func WriteNote(w io.Writer, n *Note, opts NoteOption) error {
 	return err
 }
 
+// writeRenderedNote writes the note's title and notebook followed by the
+// rendered Markdown.
+func writeRenderedNote(w io.Writer, n *Note, t *Theme, width int) error {
+	width = RenderWidth(width)
+	header := t.Heading.Paint(n.Title)
+	if n.Notebook != nil && n.Notebook.Name != "" {
+		header += "\n" + t.NoteHeader.Paint(n.Notebook.Name)
+	}
+	_, err := fmt.Fprintf(w, "%s\n\n%s\n", header, RenderMarkdown(n.MD, width, t))
+	return err
+}
+
 func toXML(mdBody string) string {
 	b := []byte("")
 	content := bytes.NewBuffer(b)
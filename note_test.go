@@ -54,12 +54,15 @@ func TestGetNote(t *testing.T) {
 		store.getSearch = func() ([]*Note, error) {
 			return []*Note{new(Note), new(Note), new(Note)}, nil
 		}
-		notes := []*Note{new(Note), new(Note)}
 		ns := new(mockNS)
-		ns.findNotes = func(filter *NoteFilter, o, max int) ([]*Note, error) { return notes, nil }
 		_, err := GetNote(store, ns, "4", "")
-		assert.Error(err)
-		assert.EqualError(err, ErrNoNoteFound.Error())
+		assert.Equal(ErrNotInSearch, err)
+	})
+	t.Run("number without saved search", func(t *testing.T) {
+		store.getSearch = func() ([]*Note, error) { return nil, nil }
+		ns := new(mockNS)
+		_, err := GetNote(store, ns, "1", "")
+		assert.Equal(ErrNotInSearch, err)
 	})
 	t.Run("return error from FindNotes", func(t *testing.T) {
 		expectedError := errors.New("Expected error")
@@ -285,6 +288,50 @@ func TestMoveNote(t *testing.T) {
 	})
 }
 
+func TestUpdateNoteMeta(t *testing.T) {
+	assert := assert.New(t)
+	noteName := "Expected Note"
+	notebook := &Notebook{Name: "New notebook", GUID: "Notebook GUID"}
+	store := &mockStore{
+		getNotebookCache:  func() (*NotebookCacheList, error) { return &NotebookCacheList{Notebooks: []*Notebook{}}, nil },
+		storeNotebookList: func(list *NotebookCacheList) error { return nil },
+	}
+
+	t.Run("should update all fields", func(t *testing.T) {
+		ns := new(mockNS)
+		ns.getAllNotebooks = func() ([]*Notebook, error) { return []*Notebook{notebook}, nil }
+		note := &Note{Title: noteName, Notebook: &Notebook{Name: "Old", GUID: "Old GUID"}}
+		var savedNote *Note
+		ns.findNotes = func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{note}, nil }
+		ns.updateNote = func(n *Note) error { savedNote = n; return nil }
+		meta := &NoteMeta{Title: "New title", Notebook: notebook.Name, Tags: []string{"a", "b"}, SourceURL: "https://example.com"}
+
+		err := UpdateNoteMeta(store, ns, noteName, meta)
+		assert.NoError(err, "Should not return an error")
+		assert.Equal("New title", savedNote.Title, "Wrong title")
+		assert.Equal(notebook, savedNote.Notebook, "Wrong notebook")
+		assert.Equal([]string{"a", "b"}, savedNote.Tags, "Wrong tags")
+		assert.Equal("https://example.com", savedNote.SourceURL, "Wrong source URL")
+		assert.Empty(savedNote.Body, "Content should not be sent")
+	})
+	t.Run("should only change given fields", func(t *testing.T) {
+		ns := new(mockNS)
+		note := &Note{Title: noteName, Notebook: notebook, Tags: []string{"old"}}
+		var savedNote *Note
+		ns.findNotes = func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{note}, nil }
+		ns.updateNote = func(n *Note) error { savedNote = n; return nil }
+
+		err := UpdateNoteMeta(store, ns, noteName, &NoteMeta{SourceURL: "https://example.com"})
+		assert.NoError(err, "Should not return an error")
+		assert.Equal(noteName, savedNote.Title, "Title should not change")
+		assert.Equal([]string{"old"}, savedNote.Tags, "Tags should not change")
+	})
+	t.Run("should return error if nothing to change", func(t *testing.T) {
+		err := UpdateNoteMeta(store, new(mockNS), noteName, &NoteMeta{})
+		assert.Equal(ErrNoMetaChange, err, "Wrong error")
+	})
+}
+
 func TestDeleteNote(t *testing.T) {
 	assert := assert.New(t)
 	noteGUID := "Note GUID"
@@ -433,7 +480,7 @@ func TestEditNote(t *testing.T) {
 			return nil
 		}
 		err := EditNote(c, expectedNote.Title, DefaultNoteOption)
-		assert.NoError(err, "Should not return an error")
+		assert.Equal(ErrNoChanges, err, "Should report that nothing changed")
 		assert.NotNil(writtenData, "Should record the data")
 		assert.Contains(string(*writtenData), expectedNote.Title, "Should write title to file")
 		assert.Contains(string(*writtenData), originalContent, "Should write content to file")
@@ -449,7 +496,7 @@ func TestEditNote(t *testing.T) {
 			return nil
 		}
 		err := EditNote(c, expectedNote.Title, RawNote)
-		assert.NoError(err, "Should not return an error")
+		assert.Equal(ErrNoChanges, err, "Should report that nothing changed")
 		assert.NotNil(writtenData, "Should record the data")
 		assert.Contains(string(*writtenData), expectedNote.Title, "Should write title to file")
 		assert.Contains(string(*writtenData), originalContent, "Should write content to file")
@@ -680,6 +727,67 @@ notebook: New name of the notebook
 		assert.Error(err, "Should return an error")
 
 	})
+
+	t.Run("journal_edit", func(t *testing.T) {
+		c, ns, _, expectedNote, _, store := setupClientAndStore("Journaled content")
+		db := &mockJournalStore{mockStore: store}
+		c.Store = db
+		var journaled []*JournalEdit
+		ns.updateNote = func(n *Note) error {
+			journaled = append([]*JournalEdit(nil), db.edits...)
+			return nil
+		}
+
+		err := EditNote(c, expectedNote.Title, DefaultNoteOption)
+
+		assert.NoError(err)
+		if assert.Len(journaled, 1, "The edit should be journaled during the upload") {
+			assert.Equal("NOTEGUID", journaled[0].Note.GUID)
+			assert.Empty(journaled[0].Note.Body, "The content is not stored in the note")
+			assert.Contains(journaled[0].Content, "Journaled content")
+			assert.False(journaled[0].Raw)
+		}
+		assert.Empty(db.edits, "The edit should be removed when uploaded")
+	})
+}
+
+func TestUploadHash(t *testing.T) {
+	assert := assert.New(t)
+	newNote := func() *Note {
+		return &Note{
+			Title:    "Title",
+			MD:       "Some **content**",
+			Body:     "<p>Some <b>content</b></p>",
+			Notebook: &Notebook{Name: "Notebook"},
+			Tags:     []string{"tag"},
+			Updated:  1,
+		}
+	}
+	hash := newNote().UploadHash(false)
+
+	n := newNote()
+	n.MD = "Some **content**\n\n"
+	assert.Equal(hash, n.UploadHash(false), "Trailing blank lines should be ignored")
+	n.Updated = 2
+	assert.Equal(hash, n.UploadHash(false), "The updated timestamp should be ignored")
+
+	changes := map[string]func(*Note){
+		"content":   func(n *Note) { n.MD = "Other content" },
+		"title":     func(n *Note) { n.Title = "Other" },
+		"notebook":  func(n *Note) { n.Notebook = &Notebook{Name: "Other"} },
+		"tags":      func(n *Note) { n.Tags = nil },
+		"sourceURL": func(n *Note) { n.SourceURL = "https://example.com" },
+		"reminder":  func(n *Note) { n.Reminder = &Reminder{Time: 1} },
+	}
+	for name, change := range changes {
+		n := newNote()
+		change(n)
+		assert.NotEqual(hash, n.UploadHash(false), "A changed "+name+" should change the hash")
+	}
+
+	raw := newNote()
+	raw.MD = "Other content"
+	assert.Equal(newNote().UploadHash(true), raw.UploadHash(true), "Raw hash should only use the raw content")
 }
 
 func TestCreateAndEditNewNote(t *testing.T) {
@@ -780,3 +888,25 @@ func nsWithNote(note *Note) *mockNS {
 	ns.findNotes = func(filter *NoteFilter, o, max int) ([]*Note, error) { return notes, nil }
 	return ns
 }
+
+func TestFindAllNotes(t *testing.T) {
+	assert := assert.New(t)
+	var offsets []int
+	ns := &mockNS{findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+		offsets = append(offsets, offset)
+		if offset >= 5 {
+			return []*Note{&Note{GUID: "last"}}, nil
+		}
+		return make([]*Note, count), nil
+	}}
+
+	notes, err := FindAllNotes(ns, new(NoteFilter), 5)
+
+	assert.NoError(err)
+	assert.Len(notes, 6)
+	assert.Equal([]int{0, 5}, offsets)
+
+	ns.findNotes = func(*NoteFilter, int, int) ([]*Note, error) { return nil, expectedError }
+	_, err = FindAllNotes(ns, new(NoteFilter), 5)
+	assert.Equal(expectedError, err)
+}
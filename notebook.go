@@ -35,6 +35,9 @@ type Notebook struct {
 	GUID string
 	// Stack is the stack that the notebook belongs too.
 	Stack string
+	// Default is true for the account's default notebook, where notes
+	// created without a notebook end up.
+	Default bool
 }
 
 // UpdateNotebook updates the notebook.
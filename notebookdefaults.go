@@ -0,0 +1,199 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// NotebookDefaults are applied to new notes created in a notebook.
+type NotebookDefaults struct {
+	// Template is the name of the template used for the note content.
+	Template string
+	// Tags are added to the note.
+	Tags []string
+}
+
+// IsEmpty returns true if no defaults are set.
+func (d *NotebookDefaults) IsEmpty() bool {
+	return d == nil || (d.Template == "" && len(d.Tags) == 0)
+}
+
+// GetNotebookDefaults returns the defaults for the notebook. The notebook
+// name is matched case insensitive. Nil is returned if the notebook has
+// no defaults.
+func (s *Settings) GetNotebookDefaults(name string) *NotebookDefaults {
+	for k, d := range s.NotebookDefaults {
+		if strings.EqualFold(k, name) {
+			return d
+		}
+	}
+	return nil
+}
+
+// SetNotebookDefaults sets the defaults for the notebook. Empty defaults
+// removes the notebook's entry.
+func (s *Settings) SetNotebookDefaults(name string, d *NotebookDefaults) {
+	for k := range s.NotebookDefaults {
+		if strings.EqualFold(k, name) {
+			delete(s.NotebookDefaults, k)
+		}
+	}
+	if d.IsEmpty() {
+		return
+	}
+	if s.NotebookDefaults == nil {
+		s.NotebookDefaults = make(map[string]*NotebookDefaults)
+	}
+	s.NotebookDefaults[name] = d
+}
+
+// NotebookRules returns the notebooks with default tags, sorted by the
+// notebook name.
+func (s *Settings) NotebookRules() []string {
+	var names []string
+	for k, d := range s.NotebookDefaults {
+		if len(d.Tags) != 0 {
+			names = append(names, k)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	return names
+}
+
+// AddNotebookRule adds the tags to the default tags of the notebook.
+// Tags the notebook already has are skipped.
+func (s *Settings) AddNotebookRule(name string, tags []string) {
+	d := new(NotebookDefaults)
+	if current := s.GetNotebookDefaults(name); current != nil {
+		*d = *current
+		d.Tags = append([]string(nil), current.Tags...)
+	}
+	for _, tag := range tags {
+		if !containsName(d.Tags, tag) {
+			d.Tags = append(d.Tags, tag)
+		}
+	}
+	s.SetNotebookDefaults(name, d)
+}
+
+// RemoveNotebookRule removes the tags from the default tags of the
+// notebook. If no tags are given, all the default tags are removed.
+func (s *Settings) RemoveNotebookRule(name string, tags []string) {
+	current := s.GetNotebookDefaults(name)
+	if current == nil {
+		return
+	}
+	d := &NotebookDefaults{Template: current.Template}
+	for _, tag := range current.Tags {
+		if len(tags) != 0 && !containsName(tags, tag) {
+			d.Tags = append(d.Tags, tag)
+		}
+	}
+	s.SetNotebookDefaults(name, d)
+}
+
+// ApplyNotebookRules adds the default tags of the note's notebook to the
+// note. Unlike ApplyNotebookDefaults, the template isn't used. It's used
+// for notes imported from other sources, like web clips and captures.
+func ApplyNotebookRules(db Storager, ns NotestoreClient, note *Note) error {
+	d, _, err := noteNotebookDefaults(db, ns, note)
+	if err != nil {
+		return err
+	}
+	addNotebookTags(note, d)
+	return nil
+}
+
+// noteNotebookDefaults returns the defaults of the note's notebook and the
+// notebook's name. A note without a notebook is created in the account's
+// default notebook, so the defaults of that notebook are returned.
+func noteNotebookDefaults(db Storager, ns NotestoreClient, note *Note) (*NotebookDefaults, string, error) {
+	settings, err := db.GetSettings()
+	if err != nil || len(settings.NotebookDefaults) == 0 {
+		return nil, "", err
+	}
+	var name string
+	if note.Notebook != nil {
+		name = note.Notebook.Name
+	} else if name, err = accountDefaultNotebook(db, ns); err != nil {
+		return nil, "", err
+	}
+	if name == "" {
+		return nil, "", nil
+	}
+	return settings.GetNotebookDefaults(name), name, nil
+}
+
+// accountDefaultNotebook returns the name of the account's default
+// notebook. An empty name is returned if the backend has no default
+// notebook.
+func accountDefaultNotebook(db Storager, ns NotestoreClient) (string, error) {
+	notebooks, err := GetNotebooks(db, ns, false)
+	if err != nil {
+		return "", err
+	}
+	for _, nb := range notebooks {
+		if nb.Default {
+			return nb.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func addNotebookTags(note *Note, d *NotebookDefaults) {
+	if d == nil {
+		return
+	}
+	for _, tag := range d.Tags {
+		if !containsName(note.Tags, tag) {
+			note.Tags = append(note.Tags, tag)
+		}
+	}
+}
+
+// ApplyNotebookDefaults adds the default tags of the note's notebook to the
+// note and, if the note has no content, renders the notebook's template as
+// the content. For a note without a notebook, the defaults of the account's
+// default notebook are used.
+func ApplyNotebookDefaults(db Storager, ns NotestoreClient, cfgFolder string, note *Note, opts NoteOption) error {
+	d, name, err := noteNotebookDefaults(db, ns, note)
+	if err != nil || d.IsEmpty() {
+		return err
+	}
+	addNotebookTags(note, d)
+	if d.Template == "" || note.MD != "" || note.Body != "" {
+		return nil
+	}
+	tmpl, err := LoadTemplate(cfgFolder, d.Template)
+	if err != nil {
+		return err
+	}
+	content, err := RenderTemplate(tmpl, &TemplateData{Title: note.Title, Notebook: name, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	if opts&RawNote != 0 {
+		note.Body = content
+	} else {
+		note.MD = content
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotebookDefaultsSettings(t *testing.T) {
+	assert := assert.New(t)
+	s := new(Settings)
+	d := &NotebookDefaults{Template: "meeting", Tags: []string{"meetings"}}
+
+	s.SetNotebookDefaults("Meetings", d)
+	assert.Equal(d, s.GetNotebookDefaults("meetings"), "Should match case insensitive")
+	assert.Nil(s.GetNotebookDefaults("Other"))
+
+	s.SetNotebookDefaults("MEETINGS", &NotebookDefaults{})
+	assert.Nil(s.GetNotebookDefaults("Meetings"), "Empty defaults should remove the entry")
+	assert.Len(s.NotebookDefaults, 0)
+}
+
+func TestApplyNotebookDefaults(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-defaults")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(os.Mkdir(filepath.Join(dir, TemplateFolder), 0700))
+	assert.NoError(ioutil.WriteFile(TemplatePath(dir, "meeting"), []byte("# {{.Title}}"), 0600))
+	settings := new(Settings)
+	settings.SetNotebookDefaults("Meetings", &NotebookDefaults{Template: "meeting", Tags: []string{"meetings", "work"}})
+	db := &mockStore{getSettings: func() (*Settings, error) { return settings, nil }}
+
+	t.Run("template and tags", func(t *testing.T) {
+		note := &Note{Title: "Standup", Notebook: &Notebook{Name: "meetings"}, Tags: []string{"Work"}}
+		err := ApplyNotebookDefaults(db, nil, dir, note, DefaultNoteOption)
+		assert.NoError(err)
+		assert.Equal("# Standup", note.MD)
+		assert.Equal([]string{"Work", "meetings"}, note.Tags)
+	})
+	t.Run("raw template", func(t *testing.T) {
+		note := &Note{Title: "Standup", Notebook: &Notebook{Name: "Meetings"}}
+		err := ApplyNotebookDefaults(db, nil, dir, note, RawNote)
+		assert.NoError(err)
+		assert.Equal("# Standup", note.Body)
+		assert.Empty(note.MD)
+	})
+	t.Run("keep content", func(t *testing.T) {
+		note := &Note{Title: "Standup", Notebook: &Notebook{Name: "Meetings"}, MD: "Content"}
+		err := ApplyNotebookDefaults(db, nil, dir, note, DefaultNoteOption)
+		assert.NoError(err)
+		assert.Equal("Content", note.MD)
+	})
+	t.Run("no defaults", func(t *testing.T) {
+		note := &Note{Title: "Note", Notebook: &Notebook{Name: "Other"}}
+		err := ApplyNotebookDefaults(db, nil, dir, note, DefaultNoteOption)
+		assert.NoError(err)
+		assert.Empty(note.MD)
+		assert.Nil(note.Tags)
+	})
+	t.Run("missing template", func(t *testing.T) {
+		settings.SetNotebookDefaults("Missing", &NotebookDefaults{Template: "missing"})
+		note := &Note{Title: "Note", Notebook: &Notebook{Name: "Missing"}}
+		err := ApplyNotebookDefaults(db, nil, dir, note, DefaultNoteOption)
+		assert.Equal(ErrTemplateNotFound, err)
+	})
+}
+
+func TestNotebookRules(t *testing.T) {
+	assert := assert.New(t)
+	s := new(Settings)
+	s.SetNotebookDefaults("Meetings", &NotebookDefaults{Template: "meeting"})
+
+	s.AddNotebookRule("Receipts", []string{"finance"})
+	s.AddNotebookRule("receipts", []string{"Finance", "tax"})
+	s.AddNotebookRule("Meetings", []string{"work"})
+	assert.Equal([]string{"finance", "tax"}, s.GetNotebookDefaults("Receipts").Tags)
+	assert.Equal(&NotebookDefaults{Template: "meeting", Tags: []string{"work"}}, s.GetNotebookDefaults("Meetings"))
+	assert.Equal([]string{"Meetings", "receipts"}, s.NotebookRules())
+
+	s.RemoveNotebookRule("Receipts", []string{"FINANCE"})
+	assert.Equal([]string{"tax"}, s.GetNotebookDefaults("Receipts").Tags)
+	s.RemoveNotebookRule("Receipts", nil)
+	assert.Nil(s.GetNotebookDefaults("Receipts"))
+	s.RemoveNotebookRule("Meetings", nil)
+	assert.Equal(&NotebookDefaults{Template: "meeting"}, s.GetNotebookDefaults("Meetings"), "The template should be kept")
+	assert.Empty(s.NotebookRules())
+
+	db := &mockStore{getSettings: func() (*Settings, error) { return s, nil }}
+	s.AddNotebookRule("Receipts", []string{"finance"})
+	note := &Note{Title: "Clip", Notebook: &Notebook{Name: "Receipts"}, Tags: []string{"web"}}
+	assert.NoError(ApplyNotebookRules(db, nil, note))
+	assert.Equal([]string{"web", "finance"}, note.Tags)
+
+	db.getNotebookCache = func() (*NotebookCacheList, error) {
+		return NewNotebookCacheList([]*Notebook{{Name: "Inbox"}, {Name: "Receipts", Default: true}}), nil
+	}
+	note = &Note{Title: "Clip"}
+	assert.NoError(ApplyNotebookRules(db, nil, note), "The default notebook's rules should be applied")
+	assert.Equal([]string{"finance"}, note.Tags)
+	assert.Nil(note.Notebook, "The note should still be created in the default notebook")
+}
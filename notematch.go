@@ -0,0 +1,157 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"sort"
+	"strings"
+)
+
+// NotePicker is called when a note title matches several notes or none
+// exactly. The notes are the candidates, best match first, and the picked
+// note is returned. If NotePicker is nil, only exact matches are used.
+var NotePicker func(title string, notes []*Note) (*Note, error)
+
+// fuzzyCandidates is the number of recently updated notes the title is
+// matched against if the search didn't find any similar titles.
+const fuzzyCandidates = 100
+
+// pickNote resolves a title that didn't match exactly one of the found notes.
+// Without a NotePicker, the first exact match is used.
+func pickNote(ns NotestoreClient, filter *NoteFilter, title string, notes, exact []*Note) (*Note, error) {
+	if NotePicker == nil {
+		if len(exact) == 0 {
+			return nil, ErrNoNoteFound
+		}
+		return exact[0], nil
+	}
+	candidates := exact
+	if len(candidates) == 0 {
+		candidates = FuzzyMatchNotes(title, notes)
+	}
+	if len(candidates) == 0 {
+		recent := &NoteFilter{NotebookGUID: filter.NotebookGUID, Order: NoteFilterOrderUpdated}
+		notes, err := ns.FindNotes(recent, 0, fuzzyCandidates)
+		if err != nil {
+			return nil, err
+		}
+		candidates = FuzzyMatchNotes(title, notes)
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoNoteFound
+	}
+	return NotePicker(title, candidates)
+}
+
+// FuzzyMatchNotes returns the notes with a title similar to the query, best
+// match first. The match ignores case. Titles containing the query are
+// ranked before titles containing its characters in order, which are ranked
+// before titles with a few typos.
+func FuzzyMatchNotes(query string, notes []*Note) []*Note {
+	type match struct {
+		note  *Note
+		score int
+	}
+	var matches []match
+	for _, n := range notes {
+		if s := fuzzyScore(query, n.Title); s > 0 {
+			matches = append(matches, match{note: n, score: s})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	found := make([]*Note, len(matches))
+	for i, m := range matches {
+		found[i] = m.note
+	}
+	return found
+}
+
+// fuzzyScore returns how well the title matches the query. Zero means no
+// match.
+func fuzzyScore(query, title string) int {
+	q := strings.ToLower(strings.TrimSpace(query))
+	t := strings.ToLower(strings.TrimSpace(title))
+	switch {
+	case q == "":
+		return 0
+	case t == q:
+		return 100
+	case strings.HasPrefix(t, q):
+		return 90
+	case strings.Contains(t, q):
+		return 80
+	}
+	if gaps, ok := subsequenceGaps(q, t); ok {
+		if gaps > 50 {
+			gaps = 50
+		}
+		return 60 - gaps
+	}
+	if editDistance(q, t) <= len([]rune(q))/4 {
+		return 5
+	}
+	return 0
+}
+
+// subsequenceGaps returns the number of characters in s between the
+// characters of sub, if all the characters of sub are found in order.
+func subsequenceGaps(sub, s string) (int, bool) {
+	r := []rune(sub)
+	i, gaps, started := 0, 0, false
+	for _, c := range s {
+		if i == len(r) {
+			break
+		}
+		if c == r[i] {
+			i++
+			started = true
+		} else if started {
+			gaps++
+		}
+	}
+	return gaps, i == len(r)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
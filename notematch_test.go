@@ -0,0 +1,151 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyMatchNotes(t *testing.T) {
+	assert := assert.New(t)
+	notes := []*Note{
+		{Title: "Groceries"},
+		{Title: "Shopping list for the party"},
+		{Title: "Meeting notes"},
+		{Title: "Shopping list"},
+		{Title: "Old shopping list"},
+		{Title: "Sopping lst"},
+	}
+	titles := func(notes []*Note) []string {
+		a := make([]string, len(notes))
+		for i, n := range notes {
+			a[i] = n.Title
+		}
+		return a
+	}
+
+	assert.Equal([]string{"Shopping list", "Shopping list for the party", "Old shopping list", "Sopping lst"},
+		titles(FuzzyMatchNotes("shopping list", notes)), "Exact, prefix, substring and typo matches in order")
+	assert.Equal([]string{"Meeting notes"}, titles(FuzzyMatchNotes("mtng", notes)), "Characters in order should match")
+	assert.Equal([]string{"Groceries"}, titles(FuzzyMatchNotes("Grocerise", notes)), "A typo should match")
+	assert.Empty(FuzzyMatchNotes("Recipes", notes))
+	assert.Empty(FuzzyMatchNotes("", notes))
+}
+
+func TestGetNotePicker(t *testing.T) {
+	assert := assert.New(t)
+	defer func() { NotePicker = nil }()
+	store := &mockStore{}
+
+	t.Run("several_exact_matches", func(t *testing.T) {
+		first, second := &Note{Title: "Todo", GUID: "1"}, &Note{Title: "Todo", GUID: "2"}
+		ns := &mockNS{findNotes: func(*NoteFilter, int, int) ([]*Note, error) {
+			return []*Note{{Title: "Todo list"}, first, second}, nil
+		}}
+		var candidates []*Note
+		NotePicker = func(title string, notes []*Note) (*Note, error) {
+			candidates = notes
+			return notes[1], nil
+		}
+		n, err := GetNote(store, ns, "Todo", "")
+		assert.NoError(err)
+		assert.Equal(second, n)
+		assert.Equal([]*Note{first, second}, candidates, "Only the exact matches should be offered")
+	})
+
+	t.Run("no_exact_match", func(t *testing.T) {
+		expected := &Note{Title: "Shopping list"}
+		var filters []*NoteFilter
+		ns := &mockNS{findNotes: func(f *NoteFilter, o, c int) ([]*Note, error) {
+			filters = append(filters, f)
+			if f.Words != "" {
+				return nil, nil
+			}
+			return []*Note{{Title: "Meeting"}, expected}, nil
+		}}
+		NotePicker = func(title string, notes []*Note) (*Note, error) {
+			assert.Equal("Shoping list", title)
+			assert.Equal([]*Note{expected}, notes)
+			return notes[0], nil
+		}
+		n, err := GetNote(store, ns, "Shoping list", "")
+		assert.NoError(err)
+		assert.Equal(expected, n)
+		if assert.Len(filters, 2, "Recent notes should be searched if the search found no similar titles") {
+			assert.Equal(NoteFilterOrderUpdated, filters[1].Order)
+		}
+	})
+
+	t.Run("no_similar_notes", func(t *testing.T) {
+		ns := &mockNS{findNotes: func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{{Title: "Meeting"}}, nil }}
+		NotePicker = func(string, []*Note) (*Note, error) {
+			t.Fatal("The picker shouldn't be used without candidates")
+			return nil, nil
+		}
+		_, err := GetNote(store, ns, "Recipes", "")
+		assert.Equal(ErrNoNoteFound, err)
+	})
+
+	t.Run("picker_error", func(t *testing.T) {
+		expected := errors.New("canceled")
+		ns := &mockNS{findNotes: func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{{Title: "Todo list"}}, nil }}
+		NotePicker = func(string, []*Note) (*Note, error) { return nil, expected }
+		_, err := GetNote(store, ns, "Todo", "")
+		assert.Equal(expected, err)
+	})
+
+	t.Run("exact_lookup", func(t *testing.T) {
+		ns := &mockNS{findNotes: func(*NoteFilter, int, int) ([]*Note, error) {
+			return []*Note{{Title: "Tracking: clinote"}}, nil
+		}}
+		NotePicker = func(string, []*Note) (*Note, error) {
+			t.Fatal("Similar titles shouldn't be picked")
+			return nil, nil
+		}
+		_, err := GetNoteExact(store, ns, "Tracking: clinote2", "")
+		assert.Equal(ErrNoNoteFound, err)
+
+		first, second := &Note{Title: "Todo", GUID: "1"}, &Note{Title: "Todo", GUID: "2"}
+		ns.findNotes = func(*NoteFilter, int, int) ([]*Note, error) {
+			return []*Note{{Title: "Todo list"}, first, second}, nil
+		}
+		NotePicker = func(title string, notes []*Note) (*Note, error) {
+			assert.Equal([]*Note{first, second}, notes, "Only the exact matches should be offered")
+			return notes[1], nil
+		}
+		n, err := GetNoteExact(store, ns, "Todo", "")
+		assert.NoError(err)
+		assert.Equal(second, n)
+	})
+
+	t.Run("exact", func(t *testing.T) {
+		NotePicker = nil
+		first := &Note{Title: "Todo", GUID: "1"}
+		ns := &mockNS{findNotes: func(*NoteFilter, int, int) ([]*Note, error) {
+			return []*Note{{Title: "Todo list"}, first, {Title: "Todo", GUID: "2"}}, nil
+		}}
+		n, err := GetNote(store, ns, "Todo", "")
+		assert.NoError(err)
+		assert.Equal(first, n, "The first exact match should be used")
+		_, err = GetNote(store, ns, "Todo lst", "")
+		assert.Equal(ErrNoNoteFound, err)
+	})
+}
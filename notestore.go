@@ -12,7 +12,8 @@ type NotestoreClient interface {
 	CreateNotebook(b *Notebook, defaultNotebook bool) error
 	// GetNoteContent gets the note's content from the notestore.
 	GetNoteContent(guid string) (string, error)
-	// UpdateNote update's the note.
+	// UpdateNote update's the note. A note without a notebook is kept in
+	// its notebook.
 	UpdateNote(note *Note) error
 	// DeleteNote removes a note from the user's notebook.
 	DeleteNote(guid string) error
@@ -20,4 +21,10 @@ type NotestoreClient interface {
 	CreateNote(note *Note) error
 	// UpdateNotebook updates the notebook on the server.
 	UpdateNotebook(book *Notebook) error
+	// GetSyncState returns the current state of the user's account on the server.
+	GetSyncState() (*SyncState, error)
+	// GetNoteResources returns the note's resources, including the data.
+	GetNoteResources(guid string) ([]*Resource, error)
+	// GetAllTags returns all the user's tags.
+	GetAllTags() ([]*Tag, error)
 }
@@ -0,0 +1,152 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import "time"
+
+// NewLoggingNotestore returns a notestore that logs the calls made to ns,
+// with how long they took and any error, at the verbose level.
+func NewLoggingNotestore(ns NotestoreClient) NotestoreClient {
+	return &loggingNotestore{ns: ns}
+}
+
+type loggingNotestore struct {
+	ns NotestoreClient
+}
+
+// logCall logs the API call. The key-value pairs are only logged at the
+// debug level.
+func logCall(method string, start time.Time, err error, kv ...interface{}) {
+	entry := []interface{}{"method", method, "duration", time.Since(start).Round(time.Millisecond)}
+	if err != nil {
+		entry = append(entry, "error", err)
+	}
+	if LogEnabled(LogLevelDebug) {
+		entry = append(entry, kv...)
+	}
+	LogVerbose("api call", entry...)
+}
+
+func (l *loggingNotestore) FindNotes(filter *NoteFilter, offset, count int) ([]*Note, error) {
+	start := time.Now()
+	notes, err := l.ns.FindNotes(filter, offset, count)
+	logCall("FindNotes", start, err, "words", filter.Words, "notebook", filter.NotebookGUID, "offset", offset, "count", count, "found", len(notes))
+	return notes, err
+}
+
+func (l *loggingNotestore) FindNoteVersions(filter *NoteFilter, offset, count int) ([]*Note, error) {
+	start := time.Now()
+	notes, err := findNoteVersions(l.ns, filter, offset, count)
+	logCall("FindNoteVersions", start, err, "words", filter.Words, "notebook", filter.NotebookGUID, "offset", offset, "count", count, "found", len(notes))
+	return notes, err
+}
+
+func (l *loggingNotestore) GetNoteMetadata(guid string) (*Note, error) {
+	start := time.Now()
+	n, err := getNoteMetadata(l.ns, guid)
+	logCall("GetNoteMetadata", start, err, "guid", guid)
+	return n, err
+}
+
+func (l *loggingNotestore) GetAllNotebooks() ([]*Notebook, error) {
+	start := time.Now()
+	books, err := l.ns.GetAllNotebooks()
+	logCall("GetAllNotebooks", start, err, "found", len(books))
+	return books, err
+}
+
+func (l *loggingNotestore) GetNotebook(guid string) (*Notebook, error) {
+	start := time.Now()
+	book, err := l.ns.GetNotebook(guid)
+	logCall("GetNotebook", start, err, "guid", guid)
+	return book, err
+}
+
+func (l *loggingNotestore) CreateNotebook(b *Notebook, defaultNotebook bool) error {
+	start := time.Now()
+	err := l.ns.CreateNotebook(b, defaultNotebook)
+	logCall("CreateNotebook", start, err, "name", b.Name, "default", defaultNotebook)
+	return err
+}
+
+func (l *loggingNotestore) GetNoteContent(guid string) (string, error) {
+	start := time.Now()
+	content, err := l.ns.GetNoteContent(guid)
+	logCall("GetNoteContent", start, err, "guid", guid, "size", len(content))
+	return content, err
+}
+
+func (l *loggingNotestore) UpdateNote(n *Note) error {
+	start := time.Now()
+	err := l.ns.UpdateNote(n)
+	logCall("UpdateNote", start, err, "guid", n.GUID, "title", n.Title, "size", len(n.Body))
+	return err
+}
+
+func (l *loggingNotestore) DeleteNote(guid string) error {
+	start := time.Now()
+	err := l.ns.DeleteNote(guid)
+	logCall("DeleteNote", start, err, "guid", guid)
+	return err
+}
+
+func (l *loggingNotestore) CreateNote(n *Note) error {
+	start := time.Now()
+	err := l.ns.CreateNote(n)
+	logCall("CreateNote", start, err, "title", n.Title, "size", len(n.Body))
+	return err
+}
+
+func (l *loggingNotestore) UpdateNotebook(b *Notebook) error {
+	start := time.Now()
+	err := l.ns.UpdateNotebook(b)
+	logCall("UpdateNotebook", start, err, "guid", b.GUID, "name", b.Name)
+	return err
+}
+
+func (l *loggingNotestore) GetSyncState() (*SyncState, error) {
+	start := time.Now()
+	state, err := l.ns.GetSyncState()
+	if err != nil {
+		logCall("GetSyncState", start, err)
+	} else {
+		logCall("GetSyncState", start, err, "usn", state.UpdateCount)
+	}
+	return state, err
+}
+
+func (l *loggingNotestore) GetNoteResources(guid string) ([]*Resource, error) {
+	start := time.Now()
+	resources, err := l.ns.GetNoteResources(guid)
+	logCall("GetNoteResources", start, err, "guid", guid, "found", len(resources))
+	return resources, err
+}
+
+func (l *loggingNotestore) GetNoteRecognition(guid string) ([]*Resource, error) {
+	start := time.Now()
+	resources, err := GetNoteRecognition(l.ns, guid)
+	logCall("GetNoteRecognition", start, err, "guid", guid, "found", len(resources))
+	return resources, err
+}
+
+func (l *loggingNotestore) GetAllTags() ([]*Tag, error) {
+	start := time.Now()
+	tags, err := l.ns.GetAllTags()
+	logCall("GetAllTags", start, err, "found", len(tags))
+	return tags, err
+}
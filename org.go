@@ -0,0 +1,262 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"regexp"
+	"strings"
+)
+
+// orgFormatter shows and edits notes in Org-mode. Headings, lists,
+// checkboxes, tables, source and quote blocks, links and the emphasis
+// markup are converted.
+type orgFormatter struct{}
+
+func (orgFormatter) Name() string      { return FormatOrg }
+func (orgFormatter) Extension() string { return ".org" }
+
+var (
+	orgHeading    = regexp.MustCompile(`^(\*+)\s+(.*)$`)
+	orgBullet     = regexp.MustCompile(`^(\s*)[-+]\s+(.*)$`)
+	orgNumbered   = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	orgRule       = regexp.MustCompile(`^\s*-{5,}\s*$`)
+	orgTableSep   = regexp.MustCompile(`^\s*\|[-+|\s]*-[-+|\s]*$`)
+	orgBeginBlock = regexp.MustCompile(`(?i)^\s*#\+begin_(src|example|quote)\b\s*(\S*)`)
+	orgEndBlock   = regexp.MustCompile(`(?i)^\s*#\+end_(src|example|quote)\b`)
+	orgCheckbox   = regexp.MustCompile(`^\[([ xX])\]\s+`)
+	orgLink       = regexp.MustCompile(`\[\[([^\]]+)\]\[([^\]]+)\]\]`)
+	orgPlainLink  = regexp.MustCompile(`\[\[([^\]]+)\]\]`)
+	orgCode       = regexp.MustCompile(`(^|[\s(\[{"'])[~=]([^\s~=](?:[^~=]*?[^\s~=])?)[~=]($|[\s)\]}"'.,;:!?-])`)
+)
+
+var mdToOrgProtected = []inlineRule{
+	{mdCodeSpan, func(m []string) string { return "~" + strings.Trim(m[0], "` ") + "~" }},
+	{mdImage, func(m []string) string { return "[[" + m[2] + "]]" }},
+	{mdLink, func(m []string) string { return "[[" + m[2] + "][" + m[1] + "]]" }},
+	{mdAutoLink, func(m []string) string { return "[[" + m[1] + "]]" }},
+}
+
+var mdToOrgRules = []inlineRule{
+	{mdBold, func(m []string) string { return boldMarker + firstNonEmpty(m[1], m[2]) + boldMarker }},
+	{mdStrike, func(m []string) string { return "+" + m[1] + "+" }},
+	{mdItalic, func(m []string) string { return "/" + m[1] + "/" }},
+	markupRule("_", func(s string) string { return "/" + s + "/" }),
+}
+
+var orgToMDProtected = []inlineRule{
+	{orgCode, func(m []string) string { return m[1] + "`" + m[2] + "`" + m[3] }},
+	{orgLink, func(m []string) string { return "[" + m[2] + "](" + m[1] + ")" }},
+	{orgPlainLink, func(m []string) string {
+		if imageExtension.MatchString(m[1]) {
+			return "![](" + m[1] + ")"
+		}
+		return "<" + m[1] + ">"
+	}},
+}
+
+var orgToMDRules = []inlineRule{
+	markupRule("+", func(s string) string { return "~~" + s + "~~" }),
+	markupRule("*", func(s string) string { return boldMarker + boldMarker + s + boldMarker + boldMarker }),
+	markupRule("/", func(s string) string { return "_" + s + "_" }),
+}
+
+// FromMarkdown converts the Markdown to Org-mode.
+func (orgFormatter) FromMarkdown(md string) string {
+	var out []string
+	var fence string
+	var table [][]string
+	quote := false
+	inline := func(s string) string { return convertInline(s, mdToOrgProtected, mdToOrgRules) }
+	flushTable := func() {
+		for i, row := range table {
+			out = append(out, "| "+strings.Join(row, " | ")+" |")
+			if i == 0 && len(table) > 1 {
+				sep := make([]string, len(row))
+				for j := range sep {
+					sep[j] = strings.Repeat("-", len(row[j])+2)
+				}
+				out = append(out, "|"+strings.Join(sep, "+")+"|")
+			}
+		}
+		table = nil
+	}
+	for _, line := range strings.Split(md, "\n") {
+		if fence != "" {
+			if closingFence(line, fence) {
+				out = append(out, "#+END_SRC")
+				fence = ""
+			} else {
+				out = append(out, line)
+			}
+			continue
+		}
+		if table != nil && !mdTableRow.MatchString(line) {
+			flushTable()
+		}
+		if m := mdQuote.FindStringSubmatch(line); m != nil {
+			if !quote {
+				out = append(out, "#+BEGIN_QUOTE")
+				quote = true
+			}
+			out = append(out, inline(m[1]))
+			continue
+		}
+		if quote {
+			out = append(out, "#+END_QUOTE")
+			quote = false
+		}
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			out = append(out, strings.TrimSpace("#+BEGIN_SRC "+m[2]))
+			continue
+		}
+		if mdTableRow.MatchString(line) {
+			if !mdTableSep.MatchString(line) {
+				cells := splitTableRow(line)
+				for i := range cells {
+					cells[i] = inline(cells[i])
+				}
+				table = append(table, cells)
+			}
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			out = append(out, strings.Repeat("*", len(m[1]))+" "+inline(m[2]))
+			continue
+		}
+		if mdRule.MatchString(line) {
+			out = append(out, "-----")
+			continue
+		}
+		if m := mdBullet.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+"- "+orgCheckboxFromMarkdown(inline(m[2])))
+			continue
+		}
+		if m := mdNumbered.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+m[2]+" "+inline(m[3]))
+			continue
+		}
+		out = append(out, inline(line))
+	}
+	if table != nil {
+		flushTable()
+	}
+	if quote {
+		out = append(out, "#+END_QUOTE")
+	}
+	if fence != "" {
+		out = append(out, "#+END_SRC")
+	}
+	return strings.Join(out, "\n")
+}
+
+// orgCheckboxFromMarkdown converts a Markdown task list checkbox at the
+// start of the list item.
+func orgCheckboxFromMarkdown(item string) string {
+	if strings.HasPrefix(item, "[x] ") {
+		return "[X] " + item[4:]
+	}
+	return item
+}
+
+// ToMarkdown converts the Org-mode text to Markdown.
+func (orgFormatter) ToMarkdown(text string) string {
+	var out []string
+	var block string
+	var table [][]string
+	inline := func(s string) string { return convertInline(s, orgToMDProtected, orgToMDRules) }
+	flushTable := func() {
+		out = append(out, markdownTable(table)...)
+		table = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if m := orgEndBlock.FindStringSubmatch(line); m != nil && strings.EqualFold(m[1], block) {
+			if !strings.EqualFold(block, "quote") {
+				out = append(out, "```")
+			}
+			block = ""
+			continue
+		}
+		if block != "" && !strings.EqualFold(block, "quote") {
+			out = append(out, line)
+			continue
+		}
+		if table != nil && !mdTableRow.MatchString(line) {
+			flushTable()
+		}
+		if m := orgBeginBlock.FindStringSubmatch(line); m != nil && block == "" {
+			block = m[1]
+			if !strings.EqualFold(block, "quote") {
+				lang := ""
+				if strings.EqualFold(block, "src") {
+					lang = m[2]
+				}
+				out = append(out, "```"+lang)
+			}
+			continue
+		}
+		prefix := ""
+		if block != "" {
+			prefix = "> "
+		}
+		if mdTableRow.MatchString(line) {
+			if !orgTableSep.MatchString(line) {
+				cells := splitTableRow(line)
+				for i := range cells {
+					cells[i] = inline(cells[i])
+				}
+				table = append(table, cells)
+			}
+			continue
+		}
+		if m := orgHeading.FindStringSubmatch(line); m != nil && block == "" {
+			level := len(m[1])
+			if level > 6 {
+				level = 6
+			}
+			out = append(out, strings.Repeat("#", level)+" "+inline(m[2]))
+			continue
+		}
+		if orgRule.MatchString(line) {
+			out = append(out, prefix+"---")
+			continue
+		}
+		if m := orgBullet.FindStringSubmatch(line); m != nil {
+			item := m[2]
+			if c := orgCheckbox.FindStringSubmatch(item); c != nil {
+				item = "[" + strings.ToLower(c[1]) + "] " + inline(item[len(c[0]):])
+			} else {
+				item = inline(item)
+			}
+			out = append(out, prefix+m[1]+"- "+item)
+			continue
+		}
+		if m := orgNumbered.FindStringSubmatch(line); m != nil {
+			out = append(out, prefix+m[1]+m[2]+". "+inline(m[3]))
+			continue
+		}
+		out = append(out, strings.TrimRight(prefix+inline(line), " "))
+	}
+	if table != nil {
+		flushTable()
+	}
+	if block != "" && !strings.EqualFold(block, "quote") {
+		out = append(out, "```")
+	}
+	return strings.Join(out, "\n")
+}
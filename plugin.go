@@ -0,0 +1,147 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// PluginPrefix is the prefix of the executables that are run as clinote
+// commands. The executable clinote-foo on the PATH is run by "clinote foo".
+const PluginPrefix = "clinote-"
+
+// The environment variables passed to plugins, in addition to HomeEnv.
+const (
+	// PluginProfileEnv is the name of the active credential.
+	PluginProfileEnv = "CLINOTE_PROFILE"
+	// PluginSocketEnv is the daemon's socket. It's only set if the daemon
+	// is running, the plugin can use it to access the storage and the
+	// notestore over RPC.
+	PluginSocketEnv = "CLINOTE_DAEMON_SOCKET"
+	// PluginBinEnv is the path to the clinote executable.
+	PluginBinEnv = "CLINOTE_BIN"
+)
+
+// ErrNoPlugin is returned if there's no plugin for the command.
+var ErrNoPlugin = errors.New("no plugin found")
+
+// FindPlugin returns the path to the plugin executable for the command.
+// ErrNoPlugin is returned if there's no executable on the PATH.
+func FindPlugin(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") {
+		return "", ErrNoPlugin
+	}
+	path, err := exec.LookPath(PluginPrefix + name)
+	if err != nil {
+		return "", ErrNoPlugin
+	}
+	return path, nil
+}
+
+// ListPlugins returns the plugins on the PATH, mapped from the command name
+// to the executable. The first executable on the PATH is used if several
+// have the same name.
+func ListPlugins() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range files {
+			name := pluginName(fi)
+			if name == "" {
+				continue
+			}
+			if _, ok := plugins[name]; !ok {
+				plugins[name] = filepath.Join(dir, fi.Name())
+			}
+		}
+	}
+	return plugins
+}
+
+// PluginNames returns the names of the plugins, sorted.
+func PluginNames(plugins map[string]string) []string {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pluginName returns the command name of the plugin executable. An empty
+// string is returned if the file isn't a plugin.
+func pluginName(fi os.FileInfo) string {
+	if fi.IsDir() || !strings.HasPrefix(fi.Name(), PluginPrefix) {
+		return ""
+	}
+	name := strings.TrimPrefix(fi.Name(), PluginPrefix)
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext != ".exe" && ext != ".bat" && ext != ".cmd" {
+			return ""
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	} else if fi.Mode()&0111 == 0 {
+		return ""
+	}
+	return name
+}
+
+// PluginEnv returns the environment for a plugin. The config folder, the
+// active credential and the daemon's socket are added to the process'
+// environment. Empty values aren't added.
+func PluginEnv(cfgFolder, profile, socket string) []string {
+	env := os.Environ()
+	for _, v := range [][2]string{
+		{HomeEnv, cfgFolder},
+		{PluginProfileEnv, profile},
+		{PluginSocketEnv, socket},
+	} {
+		if v[1] != "" {
+			env = append(env, v[0]+"="+v[1])
+		}
+	}
+	if bin, err := os.Executable(); err == nil {
+		env = append(env, PluginBinEnv+"="+bin)
+	}
+	return env
+}
+
+// RunPlugin runs the plugin with the arguments and the environment. The
+// plugin uses the standard input and output of the process.
+func RunPlugin(path string, args, env []string) error {
+	if SafeMode {
+		return ErrSafeMode
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,68 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin test uses executable bits")
+	}
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-plugins")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	assert.NoError(os.Mkdir(first, 0700))
+	assert.NoError(os.Mkdir(second, 0700))
+	assert.NoError(ioutil.WriteFile(filepath.Join(first, "clinote-foo"), []byte("#!/bin/sh\n"), 0700))
+	assert.NoError(ioutil.WriteFile(filepath.Join(second, "clinote-foo"), []byte("#!/bin/sh\n"), 0700))
+	assert.NoError(ioutil.WriteFile(filepath.Join(second, "clinote-bar"), []byte("#!/bin/sh\n"), 0700))
+	assert.NoError(ioutil.WriteFile(filepath.Join(second, "clinote-data"), []byte("data"), 0600))
+	assert.NoError(os.Mkdir(filepath.Join(second, "clinote-dir"), 0700))
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+	os.Setenv("PATH", first+string(os.PathListSeparator)+second)
+
+	path, err := FindPlugin("foo")
+	assert.NoError(err)
+	assert.Equal(filepath.Join(first, "clinote-foo"), path)
+	_, err = FindPlugin("missing")
+	assert.Equal(ErrNoPlugin, err)
+	_, err = FindPlugin("../foo")
+	assert.Equal(ErrNoPlugin, err)
+
+	plugins := ListPlugins()
+	assert.Equal([]string{"bar", "foo"}, PluginNames(plugins))
+	assert.Equal(filepath.Join(first, "clinote-foo"), plugins["foo"])
+
+	env := PluginEnv("/config", "work", "")
+	assert.Contains(env, HomeEnv+"=/config")
+	assert.Contains(env, PluginProfileEnv+"=work")
+	for _, v := range env {
+		assert.NotContains(v, PluginSocketEnv+"=")
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var (
+	enTodoPattern      = regexp.MustCompile(`<en-todo\b([^>]*?)/?>(</en-todo>)?`)
+	enNoteTagPattern   = regexp.MustCompile(`</?en-note[^>]*>`)
+	xmlHeaderPattern   = regexp.MustCompile(`(?s)^\s*<\?xml.*?\?>\s*(<!DOCTYPE[^>]*>)?`)
+	checkedAttrPattern = regexp.MustCompile(`checked="true"`)
+)
+
+// previewStyle is the style sheet of the HTML preview.
+const previewStyle = `body{max-width:50em;margin:2em auto;padding:0 1em;font-family:sans-serif;line-height:1.5}
+img{max-width:100%}
+pre,code{background:#f4f4f4}
+.notebook{color:#777}
+.encrypted{color:#777;font-style:italic}`
+
+// NoteHTML returns the note as a standalone HTML page. The resources are
+// inlined as data URIs, images are shown, audio and video can be played
+// and other files are linked for download. Encrypted sections are shown
+// as a placeholder.
+func NoteHTML(n *Note, resources []*Resource) string {
+	body := xmlHeaderPattern.ReplaceAllString(n.Body, "")
+	body = enNoteTagPattern.ReplaceAllString(body, "")
+	body = enMediaPattern.ReplaceAllStringFunc(body, func(elem string) string {
+		return mediaHTML(elem, resources)
+	})
+	body = enTodoPattern.ReplaceAllStringFunc(body, func(elem string) string {
+		if checkedAttrPattern.MatchString(elem) {
+			return `<input type="checkbox" checked disabled>`
+		}
+		return `<input type="checkbox" disabled>`
+	})
+	body = encryptedBlockPattern.ReplaceAllString(body, `<span class="encrypted">[encrypted section]</span>`)
+	title := html.EscapeString(n.Title)
+	notebook := ""
+	if n.Notebook != nil && n.Notebook.Name != "" {
+		notebook = fmt.Sprintf("<p class=\"notebook\">%s</p>\n", html.EscapeString(n.Notebook.Name))
+	}
+	return fmt.Sprintf("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n<h1>%s</h1>\n%s%s\n</body>\n</html>\n",
+		title, previewStyle, title, notebook, body)
+}
+
+// mediaHTML returns the HTML element showing the resource referenced by the
+// en-media element. The element is removed if the resource isn't found.
+func mediaHTML(elem string, resources []*Resource) string {
+	r := mediaResource(elem, resources)
+	if r == nil || r.Data == nil {
+		return ""
+	}
+	mimeType := r.Mime
+	if mt := mediaTypePattern.FindStringSubmatch(elem); mimeType == "" && mt != nil {
+		mimeType = mt[1]
+	}
+	uri := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(r.Data)
+	name := r.Filename
+	if name == "" {
+		name = r.Hash + resourceExtension(r)
+	}
+	name = html.EscapeString(name)
+	switch strings.SplitN(mimeType, "/", 2)[0] {
+	case "image":
+		return fmt.Sprintf(`<img src="%s" alt="%s">`, uri, name)
+	case "audio":
+		return fmt.Sprintf(`<audio controls src="%s"></audio>`, uri)
+	case "video":
+		return fmt.Sprintf(`<video controls src="%s"></video>`, uri)
+	}
+	return fmt.Sprintf(`<a href="%s" download="%s">%s</a>`, uri, name, name)
+}
+
+// WriteNotePreview writes the note, with its resources, as an HTML page to
+// a temporary file and returns the file name.
+func WriteNotePreview(db Storager, ns NotestoreClient, title string) (string, error) {
+	n, err := GetNoteWithContent(db, ns, title)
+	if err != nil {
+		return "", err
+	}
+	if n.Notebook != nil && n.Notebook.Name == "" && n.Notebook.GUID != "" {
+		if nb, err := GetNotebook(ns, n.Notebook.GUID); err == nil && nb != nil {
+			n.Notebook = nb
+		}
+	}
+	resources, err := getCachedNoteResources(db, ns, n)
+	if err == ErrNotSupported {
+		resources, err = nil, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile("", "clinote-preview-*.html")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err = f.WriteString(NoteHTML(n, resources)); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,75 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoteHTML(t *testing.T) {
+	assert := assert.New(t)
+	image := &Resource{Hash: "aabb", Mime: "image/png", Data: []byte("png"), Filename: "cat.png"}
+	file := &Resource{Hash: "ccdd", Mime: "application/pdf", Data: []byte("pdf")}
+	n := &Note{
+		Title:    "Cats & dogs",
+		Notebook: &Notebook{Name: "Pets"},
+		Body: XMLHeader + `<en-note><p>Look:</p><en-media type="image/png" hash="AABB"/>` +
+			`<en-media type="application/pdf" hash="ccdd"></en-media><en-media type="image/png" hash="missing"/>` +
+			`<div><en-todo checked="true"/>Done<en-todo/>Todo</div><en-crypt cipher="AES">c2VjcmV0</en-crypt></en-note>`,
+	}
+
+	page := NoteHTML(n, []*Resource{image, file})
+
+	assert.Contains(page, "<!DOCTYPE html>")
+	assert.Contains(page, "<title>Cats &amp; dogs</title>")
+	assert.Contains(page, `<p class="notebook">Pets</p>`)
+	assert.Contains(page, `<img src="data:image/png;base64,cG5n" alt="cat.png">`, "Images should be inlined")
+	assert.Contains(page, `<a href="data:application/pdf;base64,cGRm" download="ccdd.pdf">ccdd.pdf</a>`, "Files should be linked")
+	assert.Contains(page, `<input type="checkbox" checked disabled>Done<input type="checkbox" disabled>Todo`)
+	assert.Contains(page, `[encrypted section]`)
+	assert.NotContains(page, "en-media", "Missing resources should be removed")
+	assert.NotContains(page, "en-note")
+	assert.NotContains(page, "<?xml")
+}
+
+func TestWriteNotePreview(t *testing.T) {
+	assert := assert.New(t)
+	n := &Note{Title: "Note", GUID: "GUID", Notebook: &Notebook{GUID: "NB"}}
+	ns := nsWithNote(n)
+	ns.getNoteContent = func(string) (string, error) { return "<en-note><p>Content</p></en-note>", nil }
+	ns.getNotebook = func(string) (*Notebook, error) { return &Notebook{GUID: "NB", Name: "Notebook"}, nil }
+	ns.getResources = func(string) ([]*Resource, error) { return nil, ErrNotSupported }
+
+	file, err := WriteNotePreview(&mockStore{}, ns, "Note")
+	assert.NoError(err)
+	defer os.Remove(file)
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(err)
+	assert.Contains(string(data), "<p>Content</p>")
+	assert.Contains(string(data), "Notebook")
+
+	expected := errors.New("resources")
+	ns.getResources = func(string) ([]*Resource, error) { return nil, expected }
+	_, err = WriteNotePreview(&mockStore{}, ns, "Note")
+	assert.Equal(expected, err)
+}
@@ -0,0 +1,181 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+// RecognizedText is a word or phrase the note service recognized in an
+// attachment, like the text in a photo of a whiteboard.
+type RecognizedText struct {
+	// Text is the most likely reading.
+	Text string
+	// Weight is the confidence in the reading, from 0 to 100.
+	Weight int
+	// Alternatives are the other possible readings, most likely first.
+	Alternatives []string
+}
+
+// recoIndex is Evernote's recognition document. Each item is an area of
+// the image with the possible readings of its text.
+type recoIndex struct {
+	Items []struct {
+		Texts []struct {
+			Weight int    `xml:"w,attr"`
+			Text   string `xml:",chardata"`
+		} `xml:"t"`
+	} `xml:"item"`
+}
+
+// ParseRecognition parses Evernote's recognition data for a resource, the
+// recoIndex document. Items without text, like recognized shapes, are
+// skipped.
+func ParseRecognition(data []byte) ([]RecognizedText, error) {
+	var index recoIndex
+	if err := xml.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	var texts []RecognizedText
+	for _, item := range index.Items {
+		if len(item.Texts) == 0 {
+			continue
+		}
+		sort.SliceStable(item.Texts, func(i, j int) bool { return item.Texts[i].Weight > item.Texts[j].Weight })
+		t := RecognizedText{Text: strings.TrimSpace(item.Texts[0].Text), Weight: item.Texts[0].Weight}
+		for _, alt := range item.Texts[1:] {
+			t.Alternatives = append(t.Alternatives, strings.TrimSpace(alt.Text))
+		}
+		texts = append(texts, t)
+	}
+	return texts, nil
+}
+
+// Keywords returns the most likely readings of the text recognized in the
+// resource, without duplicates.
+func (r *Resource) Keywords() []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, t := range r.Recognition {
+		key := strings.ToLower(t.Text)
+		if t.Text == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keywords = append(keywords, t.Text)
+	}
+	return keywords
+}
+
+// MatchRecognition returns the readings recognized in the resource that
+// match the words of the query. Nil is returned unless every word is
+// found in a reading. The match ignores case.
+func (r *Resource) MatchRecognition(query string) []string {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil
+	}
+	var matches []string
+	found := make(map[string]bool)
+	for _, t := range r.Recognition {
+		for _, reading := range append([]string{t.Text}, t.Alternatives...) {
+			matched := false
+			for _, word := range words {
+				if strings.Contains(strings.ToLower(reading), word) {
+					found[word] = true
+					matched = true
+				}
+			}
+			if matched {
+				matches = append(matches, t.Text)
+				break
+			}
+		}
+	}
+	if len(found) != len(words) {
+		return nil
+	}
+	return matches
+}
+
+// RecognitionGetter is implemented by the notestores that can fetch the
+// text recognized in the note's attachments without the attachments' data.
+type RecognitionGetter interface {
+	// GetNoteRecognition returns the note's resources with the recognized
+	// text but without the data.
+	GetNoteRecognition(guid string) ([]*Resource, error)
+}
+
+// GetNoteRecognition returns the note's resources with the recognized text
+// but without the data. Notestores that can't leave out the data fetch the
+// whole resources.
+func GetNoteRecognition(ns NotestoreClient, guid string) ([]*Resource, error) {
+	if g, ok := ns.(RecognitionGetter); ok {
+		return g.GetNoteRecognition(guid)
+	}
+	resources, err := ns.GetNoteResources(guid)
+	for _, r := range resources {
+		r.Data = nil
+	}
+	return resources, err
+}
+
+// AttachmentMatch is an attachment whose recognized text matches a search.
+type AttachmentMatch struct {
+	// Note is the note the attachment belongs to.
+	Note *Note
+	// Resource is the attachment, without its data.
+	Resource *Resource
+	// Index is the attachment's position in the note, starting at 1.
+	Index int
+	// Matches are the recognized readings matching the query.
+	Matches []string
+}
+
+// SearchAttachments returns the image attachments of the notes matching the
+// filter whose recognized text matches the query. The note service picks
+// the notes with images matching the query, the attachments of each note
+// are then checked for the words.
+func SearchAttachments(ns NotestoreClient, filter *NoteFilter, query string) ([]*AttachmentMatch, error) {
+	f := *filter
+	f.Words = strings.TrimSpace(f.Words + " " + query + " resource:image/*")
+	notes, err := FindAllNotes(ns, &f, DefaultBulkPageSize)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*AttachmentMatch
+	for _, n := range notes {
+		resources, err := GetNoteRecognition(ns, n.GUID)
+		if err == ErrNotSupported {
+			continue
+		}
+		if err != nil {
+			return matches, err
+		}
+		for i, r := range resources {
+			found := r.MatchRecognition(query)
+			if found == nil {
+				continue
+			}
+			matches = append(matches, &AttachmentMatch{Note: n, Resource: r, Index: i + 1, Matches: found})
+		}
+	}
+	return matches, nil
+}
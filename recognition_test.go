@@ -0,0 +1,113 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRecoIndex = `<?xml version="1.0" encoding="UTF-8"?>
+<recoIndex docType="handwritten" objType="image" objID="a284273e482578224145f2560b67bf45" engineVersion="3.0.17.14" recoType="client" lang="en" objWidth="2398" objHeight="1798">
+<item x="437" y="589" w="1415" h="190">
+<t w="87">EVERNOTE</t>
+<t w="83">EVER NOTE</t>
+</item>
+<item x="1850" y="1465" w="14" h="12">
+<object type="circle" w="31"/>
+</item>
+<item x="10" y="10" w="50" h="20">
+<t w="20">goals</t>
+<t w="50">Goals</t>
+</item>
+<item x="10" y="50" w="50" h="20">
+<t w="35">goals</t>
+</item>
+</recoIndex>`
+
+func TestParseRecognition(t *testing.T) {
+	assert := assert.New(t)
+	texts, err := ParseRecognition([]byte(testRecoIndex))
+	assert.NoError(err)
+	assert.Equal([]RecognizedText{
+		{Text: "EVERNOTE", Weight: 87, Alternatives: []string{"EVER NOTE"}},
+		{Text: "Goals", Weight: 50, Alternatives: []string{"goals"}},
+		{Text: "goals", Weight: 35},
+	}, texts)
+
+	_, err = ParseRecognition([]byte("<recoIndex"))
+	assert.Error(err)
+}
+
+func TestResourceRecognition(t *testing.T) {
+	assert := assert.New(t)
+	texts, _ := ParseRecognition([]byte(testRecoIndex))
+	r := &Resource{Recognition: texts}
+
+	assert.Equal([]string{"EVERNOTE", "Goals"}, r.Keywords())
+	assert.Equal([]string{"Goals", "goals"}, r.MatchRecognition("GOALS"))
+	assert.Equal([]string{"EVERNOTE", "Goals", "goals"}, r.MatchRecognition("ever goals"))
+	assert.Nil(r.MatchRecognition("evernote plans"), "All words should be recognized")
+	assert.Nil(r.MatchRecognition(" "))
+	assert.Nil(new(Resource).MatchRecognition("goals"))
+}
+
+func TestSearchAttachments(t *testing.T) {
+	assert := assert.New(t)
+	texts, _ := ParseRecognition([]byte(testRecoIndex))
+	notes := []*Note{{Title: "Whiteboard", GUID: "1"}, {Title: "Receipt", GUID: "2"}}
+	resources := map[string][]*Resource{
+		"1": {
+			{Mime: "application/pdf", Filename: "plan.pdf", Data: []byte("pdf")},
+			{Mime: "image/jpeg", Filename: "board.jpg", Data: []byte("jpg"), Recognition: texts},
+		},
+		"2": {{Mime: "image/jpeg", Filename: "receipt.jpg", Data: []byte("jpg")}},
+	}
+	var query string
+	ns := &mockNS{
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			query = f.Words
+			return notes, nil
+		},
+		getResources: func(guid string) ([]*Resource, error) { return resources[guid], nil },
+	}
+
+	matches, err := SearchAttachments(ns, &NoteFilter{Words: "tag:work"}, "goals")
+
+	assert.NoError(err)
+	assert.Equal("tag:work goals resource:image/*", query)
+	if assert.Len(matches, 1) {
+		assert.Equal(notes[0], matches[0].Note)
+		assert.Equal(2, matches[0].Index)
+		assert.Equal("board.jpg", matches[0].Resource.Filename)
+		assert.Nil(matches[0].Resource.Data, "The data should not be kept")
+		assert.Equal([]string{"Goals", "goals"}, matches[0].Matches)
+	}
+}
+
+func TestWriteRecognizedText(t *testing.T) {
+	texts, _ := ParseRecognition([]byte(testRecoIndex))
+	resources := []*Resource{{Filename: "plan.pdf"}, {Filename: "board.jpg", Recognition: texts}, {Recognition: texts}}
+	buf := new(bytes.Buffer)
+
+	WriteRecognizedText(buf, resources, DefaultTableOption)
+
+	assert.Equal(t, "Attachment 2 (board.jpg): EVERNOTE, Goals\nAttachment 3:             EVERNOTE, Goals\n", buf.String())
+}
@@ -0,0 +1,121 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+)
+
+// RedactionMarker is the text that replaces redacted content.
+const RedactionMarker = "[REDACTED]"
+
+var (
+	// ErrNothingToRedact is returned if the pattern doesn't match any of the note's content.
+	ErrNothingToRedact = errors.New("pattern did not match any content")
+	// ErrNoRedactedCopy is returned if no redacted copy of the note has been saved.
+	ErrNoRedactedCopy = errors.New("no redacted copy of the note found")
+)
+
+// RedactNote replaces all matches of the pattern in the note's content with
+// the redaction marker. If the raw option is set, the raw content is
+// redacted. Otherwise the Markdown content is redacted. The number of
+// replaced matches is returned.
+func RedactNote(n *Note, pattern *regexp.Regexp, opts NoteOption) int {
+	content := &n.MD
+	if opts&RawNote != 0 {
+		content = &n.Body
+	}
+	count := len(pattern.FindAllStringIndex(*content, -1))
+	if count == 0 {
+		return 0
+	}
+	*content = pattern.ReplaceAllLiteralString(*content, RedactionMarker)
+	return count
+}
+
+// RedactAndSaveNote redacts the note's content and saves the changes to the
+// notestore. Before the note is updated, an encrypted copy of the original
+// note is saved to the local storage. The copy is encrypted with a key derived
+// from the passphrase. If the note already has been redacted, the first copy
+// is kept so restoring the note undoes all the redactions. The passphrase
+// has to decrypt the kept copy.
+func RedactAndSaveNote(db Storager, ns NotestoreClient, title string, pattern *regexp.Regexp, passphrase string, opts NoteOption) (int, error) {
+	n, err := GetNoteWithContent(db, ns, title)
+	if err != nil {
+		return 0, err
+	}
+	original := *n
+	count := RedactNote(n, pattern, opts)
+	if count == 0 {
+		return 0, ErrNothingToRedact
+	}
+	saved, err := db.GetRedactedNote(n.GUID)
+	if err != nil {
+		return 0, err
+	}
+	if len(saved) != 0 {
+		if _, err = decryptWithPassphrase(passphrase, saved); err != nil {
+			return 0, err
+		}
+		return count, SaveChanges(ns, n, opts)
+	}
+	data, err := json.Marshal(&original)
+	if err != nil {
+		return 0, err
+	}
+	encrypted, err := encryptWithPassphrase(passphrase, data)
+	if err != nil {
+		return 0, err
+	}
+	if err = db.SaveRedactedNote(n.GUID, encrypted); err != nil {
+		return 0, err
+	}
+	return count, SaveChanges(ns, n, opts)
+}
+
+// RestoreRedactedNote decrypts the saved copy of the note and restores the
+// note's content to the content it had before it was redacted. The copy is
+// removed once the note has been restored.
+func RestoreRedactedNote(db Storager, ns NotestoreClient, title, passphrase string) error {
+	n, err := GetNote(db, ns, title, "")
+	if err != nil {
+		return err
+	}
+	data, err := db.GetRedactedNote(n.GUID)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return ErrNoRedactedCopy
+	}
+	plaintext, err := decryptWithPassphrase(passphrase, data)
+	if err != nil {
+		return err
+	}
+	original := new(Note)
+	if err = json.Unmarshal(plaintext, original); err != nil {
+		return err
+	}
+	original.Notebook = n.Notebook
+	if err = SaveChanges(ns, original, RawNote); err != nil {
+		return err
+	}
+	return db.SaveRedactedNote(n.GUID, nil)
+}
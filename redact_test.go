@@ -0,0 +1,130 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactNote(t *testing.T) {
+	assert := assert.New(t)
+	pattern := regexp.MustCompile(`\b\d{16}\b`)
+	t.Run("redact markdown", func(t *testing.T) {
+		n := &Note{MD: "Card 1234567812345678 and 8765432187654321.", Body: "1234567812345678"}
+		count := RedactNote(n, pattern, DefaultNoteOption)
+		assert.Equal(2, count)
+		assert.Equal("Card [REDACTED] and [REDACTED].", n.MD)
+		assert.Equal("1234567812345678", n.Body, "Raw content should not be changed")
+	})
+	t.Run("redact raw", func(t *testing.T) {
+		n := &Note{Body: "<div>1234567812345678</div>"}
+		count := RedactNote(n, pattern, RawNote)
+		assert.Equal(1, count)
+		assert.Equal("<div>[REDACTED]</div>", n.Body)
+	})
+	t.Run("no match", func(t *testing.T) {
+		n := &Note{MD: "Nothing to see here"}
+		count := RedactNote(n, pattern, DefaultNoteOption)
+		assert.Equal(0, count)
+		assert.Equal("Nothing to see here", n.MD)
+	})
+}
+
+func TestRedactAndRestoreNote(t *testing.T) {
+	assert := assert.New(t)
+	guid := "GUID"
+	title := "Note"
+	passphrase := "secret"
+	content := XMLHeader + "<en-note><div>Card 1234567812345678</div></en-note>"
+	var saved []byte
+	var updated *Note
+	store := &mockStore{
+		saveRedactedNote: func(g string, data []byte) error {
+			assert.Equal(guid, g)
+			saved = data
+			return nil
+		},
+		getRedactedNote: func(g string) ([]byte, error) { return saved, nil },
+	}
+	ns := &mockNS{
+		findNotes: func(*NoteFilter, int, int) ([]*Note, error) {
+			return []*Note{&Note{Title: title, GUID: guid, Notebook: &Notebook{GUID: "Book"}}}, nil
+		},
+		getNoteContent: func(string) (string, error) { return content, nil },
+		updateNote:     func(n *Note) error { updated = n; return nil },
+	}
+
+	t.Run("redact", func(t *testing.T) {
+		count, err := RedactAndSaveNote(store, ns, title, regexp.MustCompile(`\d{16}`), passphrase, RawNote)
+		assert.NoError(err)
+		assert.Equal(1, count)
+		assert.NotContains(string(saved), "1234567812345678", "Copy should be encrypted")
+		assert.Contains(updated.Body, RedactionMarker)
+	})
+	t.Run("redact again", func(t *testing.T) {
+		first := saved
+		content = XMLHeader + "<en-note><div>Card [REDACTED] code 1234</div></en-note>"
+		_, err := RedactAndSaveNote(store, ns, title, regexp.MustCompile(`\d{4}`), "wrong", RawNote)
+		assert.Equal(ErrDecryptionFailed, err, "The passphrase has to open the first copy")
+		count, err := RedactAndSaveNote(store, ns, title, regexp.MustCompile(`\d{4}`), passphrase, RawNote)
+		assert.NoError(err)
+		assert.Equal(1, count)
+		assert.Equal(first, saved, "The first copy should be kept")
+		content = XMLHeader + "<en-note><div>Card 1234567812345678</div></en-note>"
+	})
+	t.Run("nothing to redact", func(t *testing.T) {
+		_, err := RedactAndSaveNote(store, ns, title, regexp.MustCompile(`nomatch`), passphrase, RawNote)
+		assert.Equal(ErrNothingToRedact, err)
+	})
+	t.Run("wrong passphrase", func(t *testing.T) {
+		err := RestoreRedactedNote(store, ns, title, "wrong")
+		assert.Equal(ErrDecryptionFailed, err)
+	})
+	t.Run("restore", func(t *testing.T) {
+		err := RestoreRedactedNote(store, ns, title, passphrase)
+		assert.NoError(err)
+		assert.Contains(updated.Body, "1234567812345678")
+		assert.NotContains(updated.Body, RedactionMarker)
+		assert.Nil(saved, "The copy should be removed")
+	})
+	t.Run("no copy saved", func(t *testing.T) {
+		err := RestoreRedactedNote(store, ns, title, passphrase)
+		assert.Equal(ErrNoRedactedCopy, err)
+	})
+}
+
+func TestPassphraseEncryption(t *testing.T) {
+	assert := assert.New(t)
+	plaintext := []byte("Secret data")
+	data, err := encryptWithPassphrase("passphrase", plaintext)
+	assert.NoError(err)
+	assert.NotEqual(plaintext, data)
+
+	decrypted, err := decryptWithPassphrase("passphrase", data)
+	assert.NoError(err)
+	assert.Equal(plaintext, decrypted)
+
+	_, err = decryptWithPassphrase("wrong", data)
+	assert.Equal(ErrDecryptionFailed, err)
+
+	_, err = encryptWithPassphrase("", plaintext)
+	assert.Equal(ErrEmptyPassphrase, err)
+}
@@ -0,0 +1,235 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReminderSearch is the search grammar that matches all notes with a reminder.
+const ReminderSearch = "reminderOrder:*"
+
+var (
+	// ErrNoReminder is returned if the note doesn't have a reminder.
+	ErrNoReminder = errors.New("the note has no reminder")
+	// ErrInvalidDuration is returned if a duration can't be parsed.
+	ErrInvalidDuration = errors.New("invalid duration, expected for example 3d, 2w or 12h")
+)
+
+// Reminder is a note reminder. The times are in milliseconds since the epoch.
+type Reminder struct {
+	// Order is used to order reminders without a due time.
+	Order int64
+	// Time is when the reminder is due. Zero if no due time is set.
+	Time int64
+	// Done is when the reminder was completed. Zero if not completed.
+	Done int64
+}
+
+// ReminderState is the state of a reminder.
+type ReminderState int
+
+const (
+	// ReminderOpen is a reminder that isn't done or overdue.
+	ReminderOpen ReminderState = iota
+	// ReminderOverdue is a reminder that is past its due time.
+	ReminderOverdue
+	// ReminderDone is a completed reminder.
+	ReminderDone
+)
+
+func (s ReminderState) String() string {
+	switch s {
+	case ReminderOverdue:
+		return "Overdue"
+	case ReminderDone:
+		return "Done"
+	default:
+		return "Open"
+	}
+}
+
+// State returns the state of the reminder at the time.
+func (r *Reminder) State(now time.Time) ReminderState {
+	if r.Done != 0 {
+		return ReminderDone
+	}
+	if r.Time != 0 && r.Due().Before(now) {
+		return ReminderOverdue
+	}
+	return ReminderOpen
+}
+
+// Due returns the due time of the reminder.
+func (r *Reminder) Due() time.Time {
+	return fromMillis(r.Time)
+}
+
+// ReminderFilter is used to filter reminders. If no field is set, all
+// reminders are included. Otherwise, reminders that aren't done and
+// match any of the fields are included.
+type ReminderFilter struct {
+	// Overdue includes reminders past their due time.
+	Overdue bool
+	// DueWithin includes reminders due within the duration.
+	DueWithin time.Duration
+}
+
+// GetReminders returns the notes with a reminder, sorted by due time.
+// Notes without a due time are sorted last.
+func GetReminders(ns NotestoreClient, count int) ([]*Note, error) {
+	notes, err := ns.FindNotes(&NoteFilter{Words: ReminderSearch}, 0, count)
+	if err != nil {
+		return nil, err
+	}
+	reminders := make([]*Note, 0, len(notes))
+	for _, n := range notes {
+		if n.Reminder != nil {
+			reminders = append(reminders, n)
+		}
+	}
+	SortReminders(reminders)
+	return reminders, nil
+}
+
+// SortReminders sorts the notes by the reminders' due time. Notes without
+// a due time are sorted last by the reminder order.
+func SortReminders(notes []*Note) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		a, b := notes[i].Reminder, notes[j].Reminder
+		switch {
+		case a.Time != 0 && b.Time != 0:
+			return a.Time < b.Time
+		case a.Time != 0 || b.Time != 0:
+			return a.Time != 0
+		}
+		return a.Order > b.Order
+	})
+}
+
+// FilterReminders returns the notes with reminders matching the filter.
+func FilterReminders(notes []*Note, filter ReminderFilter, now time.Time) []*Note {
+	if !filter.Overdue && filter.DueWithin == 0 {
+		return notes
+	}
+	var matched []*Note
+	for _, n := range notes {
+		r := n.Reminder
+		if r == nil || r.Done != 0 || r.Time == 0 {
+			continue
+		}
+		due := r.Due()
+		if (filter.Overdue && due.Before(now)) ||
+			(filter.DueWithin > 0 && !due.Before(now) && !due.After(now.Add(filter.DueWithin))) {
+			matched = append(matched, n)
+		}
+	}
+	return matched
+}
+
+// ReminderShift is the result of moving a note's reminder.
+type ReminderShift struct {
+	// Note is the note with the reminder.
+	Note *Note
+	// From is the old due time.
+	From time.Time
+	// To is the new due time.
+	To time.Time
+	// Err is set if the note failed to be updated.
+	Err error
+}
+
+// ShiftReminders moves the due time of the reminders on the notes matching
+// the search query by the duration. Reminders that are done or don't have
+// a due time are skipped. If dryRun is true, the notes are not updated.
+// A failed update doesn't stop the other notes from being updated.
+func ShiftReminders(ns NotestoreClient, query string, count int, by time.Duration, dryRun bool) ([]*ReminderShift, error) {
+	words := strings.TrimSpace(query + " " + ReminderSearch)
+	notes, err := ns.FindNotes(&NoteFilter{Words: words}, 0, count)
+	if err != nil {
+		return nil, err
+	}
+	var shifts []*ReminderShift
+	for _, n := range notes {
+		r := n.Reminder
+		if r == nil || r.Time == 0 || r.Done != 0 {
+			continue
+		}
+		from := r.Due()
+		s := &ReminderShift{Note: n, From: from, To: from.Add(by)}
+		shifts = append(shifts, s)
+		if dryRun {
+			continue
+		}
+		r.Time = toMillis(s.To)
+		n.Body = ""
+		if s.Err = saveChanges(ns, n, false, false); s.Err != nil {
+			r.Time = toMillis(from)
+		}
+	}
+	sort.SliceStable(shifts, func(i, j int) bool { return shifts[i].From.Before(shifts[j].From) })
+	return shifts, nil
+}
+
+// CompleteReminder marks the note's reminder as done.
+func CompleteReminder(db Storager, ns NotestoreClient, title string) error {
+	n, err := GetNoteExact(db, ns, title, "")
+	if err != nil {
+		return err
+	}
+	if n.Reminder == nil {
+		return ErrNoReminder
+	}
+	n.Reminder.Done = toMillis(time.Now())
+	n.Body = ""
+	return saveChanges(ns, n, false, false)
+}
+
+// ParseDuration parses a duration. In addition to the units supported by
+// time.ParseDuration, days (d) and weeks (w) are supported, for example 3d.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidDuration
+	}
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if unit, ok := units[s[len(s)-1]]; ok {
+		n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		if err != nil {
+			return 0, ErrInvalidDuration
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, ErrInvalidDuration
+	}
+	return d, nil
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,183 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReminders(t *testing.T) {
+	assert := assert.New(t)
+	noDue := &Note{Title: "No due", Reminder: &Reminder{Order: 2}}
+	noDueOld := &Note{Title: "No due old", Reminder: &Reminder{Order: 1}}
+	later := &Note{Title: "Later", Reminder: &Reminder{Order: 3, Time: 2000}}
+	sooner := &Note{Title: "Sooner", Reminder: &Reminder{Order: 4, Time: 1000}}
+	var filter *NoteFilter
+	ns := &mockNS{findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+		filter = f
+		return []*Note{noDueOld, later, &Note{Title: "No reminder"}, noDue, sooner}, nil
+	}}
+
+	notes, err := GetReminders(ns, 100)
+	assert.NoError(err)
+	assert.Equal(ReminderSearch, filter.Words)
+	assert.Equal([]*Note{sooner, later, noDue, noDueOld}, notes)
+}
+
+func TestFilterReminders(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2018, 3, 10, 12, 0, 0, 0, time.UTC)
+	overdue := &Note{Reminder: &Reminder{Time: toMillis(now.Add(-time.Hour))}}
+	doneOverdue := &Note{Reminder: &Reminder{Time: toMillis(now.Add(-time.Hour)), Done: toMillis(now)}}
+	soon := &Note{Reminder: &Reminder{Time: toMillis(now.Add(48 * time.Hour))}}
+	later := &Note{Reminder: &Reminder{Time: toMillis(now.Add(96 * time.Hour))}}
+	noDue := &Note{Reminder: &Reminder{Order: 1}}
+	notes := []*Note{overdue, doneOverdue, soon, later, noDue}
+
+	assert.Equal(notes, FilterReminders(notes, ReminderFilter{}, now))
+	assert.Equal([]*Note{overdue}, FilterReminders(notes, ReminderFilter{Overdue: true}, now))
+	assert.Equal([]*Note{soon}, FilterReminders(notes, ReminderFilter{DueWithin: 72 * time.Hour}, now))
+	assert.Equal([]*Note{overdue, soon}, FilterReminders(notes, ReminderFilter{Overdue: true, DueWithin: 72 * time.Hour}, now))
+}
+
+func TestReminderState(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, ReminderOpen, (&Reminder{Order: 1}).State(now))
+	assert.Equal(t, ReminderOpen, (&Reminder{Time: toMillis(now.Add(time.Hour))}).State(now))
+	assert.Equal(t, ReminderOverdue, (&Reminder{Time: toMillis(now.Add(-time.Hour))}).State(now))
+	assert.Equal(t, ReminderDone, (&Reminder{Time: toMillis(now.Add(-time.Hour)), Done: toMillis(now)}).State(now))
+}
+
+func TestCompleteReminder(t *testing.T) {
+	assert := assert.New(t)
+	store := new(mockStore)
+
+	t.Run("should set done time", func(t *testing.T) {
+		note := &Note{Title: "Note", Notebook: &Notebook{GUID: "GUID"}, Reminder: &Reminder{Order: 1}}
+		var saved *Note
+		ns := &mockNS{
+			findNotes:  func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{note}, nil },
+			updateNote: func(n *Note) error { saved = n; return nil },
+		}
+		before := toMillis(time.Now())
+		assert.NoError(CompleteReminder(store, ns, "Note"))
+		assert.True(saved.Reminder.Done >= before, "Done time should be set")
+	})
+	t.Run("should return error if no reminder", func(t *testing.T) {
+		ns := &mockNS{findNotes: func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{&Note{Title: "Note"}}, nil }}
+		assert.Equal(ErrNoReminder, CompleteReminder(store, ns, "Note"))
+	})
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		err      error
+	}{
+		{"3d", 72 * time.Hour, nil},
+		{"2w", 14 * 24 * time.Hour, nil},
+		{"-1d", -24 * time.Hour, nil},
+		{"1.5d", 36 * time.Hour, nil},
+		{"12h", 12 * time.Hour, nil},
+		{"", 0, ErrInvalidDuration},
+		{"xd", 0, ErrInvalidDuration},
+		{"3 days", 0, ErrInvalidDuration},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			d, err := ParseDuration(test.input)
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.expected, d)
+		})
+	}
+}
+
+func TestReminderTable(t *testing.T) {
+	buf := new(bytes.Buffer)
+	now := time.Now()
+	due := now.Add(-time.Hour)
+	notes := []*Note{
+		&Note{Title: "Overdue", Reminder: &Reminder{Time: toMillis(due)}},
+		&Note{Title: "Done", Reminder: &Reminder{Order: 1, Done: toMillis(now)}},
+	}
+
+	WriteReminderListing(buf, notes, now, DefaultTableOption)
+
+	assert.Contains(t, buf.String(), "| 1 | Overdue | "+due.Format(reminderTimeFormat)+" | Overdue |")
+	assert.Contains(t, buf.String(), "| 2 | Done    |                  | Done    |")
+}
+
+func TestShiftReminders(t *testing.T) {
+	assert := assert.New(t)
+	due := time.Date(2018, 3, 10, 12, 0, 0, 0, time.UTC)
+	newNotes := func() []*Note {
+		return []*Note{
+			&Note{Title: "Later", GUID: "1", Notebook: &Notebook{}, Reminder: &Reminder{Time: toMillis(due.Add(time.Hour))}},
+			&Note{Title: "Due", GUID: "2", Notebook: &Notebook{}, Reminder: &Reminder{Time: toMillis(due)}},
+			&Note{Title: "Done", GUID: "3", Notebook: &Notebook{}, Reminder: &Reminder{Time: toMillis(due), Done: toMillis(due)}},
+			&Note{Title: "No due", GUID: "4", Notebook: &Notebook{}, Reminder: &Reminder{Order: 1}},
+		}
+	}
+
+	t.Run("should move reminders", func(t *testing.T) {
+		notes := newNotes()
+		var filter *NoteFilter
+		var updated []*Note
+		ns := &mockNS{
+			findNotes:  func(f *NoteFilter, _, _ int) ([]*Note, error) { filter = f; return notes, nil },
+			updateNote: func(n *Note) error { updated = append(updated, n); return nil },
+		}
+		shifts, err := ShiftReminders(ns, "tag:followup", 100, 7*24*time.Hour, false)
+		assert.NoError(err)
+		assert.Equal("tag:followup "+ReminderSearch, filter.Words)
+		assert.Len(shifts, 2)
+		assert.Equal("Due", shifts[0].Note.Title, "Should be sorted by due time")
+		assert.Equal(due.Add(7*24*time.Hour), shifts[0].To.UTC())
+		assert.Len(updated, 2)
+		assert.Equal(toMillis(due.Add(7*24*time.Hour)), notes[1].Reminder.Time)
+	})
+	t.Run("dry run should not update", func(t *testing.T) {
+		notes := newNotes()
+		ns := &mockNS{
+			findNotes:  func(*NoteFilter, int, int) ([]*Note, error) { return notes, nil },
+			updateNote: func(n *Note) error { t.Fatal("Should not update"); return nil },
+		}
+		shifts, err := ShiftReminders(ns, "", 100, time.Hour, true)
+		assert.NoError(err)
+		assert.Len(shifts, 2)
+		assert.Equal(toMillis(due), notes[1].Reminder.Time, "Reminder should not change")
+	})
+	t.Run("should continue on update error", func(t *testing.T) {
+		notes := newNotes()
+		ns := &mockNS{
+			findNotes:  func(*NoteFilter, int, int) ([]*Note, error) { return notes, nil },
+			updateNote: func(n *Note) error { return expectedError },
+		}
+		shifts, err := ShiftReminders(ns, "", 100, time.Hour, false)
+		assert.NoError(err)
+		assert.Len(shifts, 2)
+		assert.Equal(expectedError, shifts[0].Err)
+		assert.Equal(expectedError, shifts[1].Err)
+		assert.Equal(toMillis(due), notes[1].Reminder.Time, "Reminder should be restored")
+	})
+}
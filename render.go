@@ -0,0 +1,246 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultRenderWidth is the line width of rendered notes if the
+	// terminal width is unknown.
+	defaultRenderWidth = 80
+	// maxRenderWidth limits the line width of rendered notes on wide
+	// terminals to keep the paragraphs readable.
+	maxRenderWidth = 100
+	// minWrapWidth is the narrowest text column that is wrapped. Narrower
+	// columns are written on one line.
+	minWrapWidth = 20
+)
+
+var (
+	listItemLine   = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	taskItemText   = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	horizontalRule = regexp.MustCompile(`^((\*\s*){3,}|(-\s*){3,}|(_\s*){3,})$`)
+	inlineCodeSpan = regexp.MustCompile("`([^`]+)`")
+	inlineEscape   = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!|>])`)
+	inlineImage    = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]*)\)`)
+	inlineLink     = regexp.MustCompile(`\[([^\]]+)\]\(([^)]*)\)`)
+	inlineStrong   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	inlineEmphasis = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_\s][^_]*)_\b`)
+	placeholder    = regexp.MustCompile("\x00([0-9]+)\x00")
+)
+
+// RenderWidth returns the line width used to render notes for a terminal
+// of the given width. Zero means the width is unknown.
+func RenderWidth(terminal int) int {
+	if terminal <= 0 {
+		return defaultRenderWidth
+	}
+	if terminal > maxRenderWidth {
+		return maxRenderWidth
+	}
+	return terminal
+}
+
+// RenderMarkdown renders the Markdown for a terminal. The markup is
+// replaced by the theme's styles, lists are indented, code blocks are
+// highlighted and paragraphs are wrapped to the width.
+func RenderMarkdown(md string, width int, t *Theme) string {
+	var out, para []string
+	flush := func() {
+		if len(para) != 0 {
+			out = append(out, wrapText(renderInline(strings.Join(para, " "), t), width, "", "")...)
+			para = nil
+		}
+	}
+	fence := ""
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+				continue
+			}
+			out = append(out, "    "+highlightCode(line, t))
+			continue
+		}
+		m := listItemLine.FindStringSubmatch(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence = trimmed[:3]
+		case trimmed == "":
+			flush()
+			out = append(out, "")
+		case isHeading(trimmed):
+			flush()
+			out = append(out, renderHeading(trimmed, t)...)
+		case horizontalRule.MatchString(trimmed):
+			flush()
+			out = append(out, t.Quote.Paint(strings.Repeat("─", width)))
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			bar := t.Quote.Paint("│ ")
+			text := strings.TrimSpace(strings.TrimLeft(trimmed, "> "))
+			out = append(out, wrapText(t.Quote.Paint(renderInline(text, Themes[ThemeNone])), width, bar, bar)...)
+		case strings.HasPrefix(trimmed, "|"):
+			flush()
+			out = append(out, renderInline(line, t))
+		case m != nil:
+			flush()
+			out = append(out, renderListItem(m[1], m[2], m[3], width, t)...)
+		default:
+			para = append(para, trimmed)
+			// A line ending with two spaces or a backslash is a line break.
+			if strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\") {
+				para[len(para)-1] = strings.TrimSuffix(trimmed, "\\")
+				flush()
+			}
+		}
+	}
+	flush()
+	return strings.Join(collapseBlankLines(out), "\n")
+}
+
+// renderHeading renders the heading in the heading style. The first two
+// levels are underlined.
+func renderHeading(line string, t *Theme) []string {
+	level := strings.IndexFunc(line, func(r rune) bool { return r != '#' })
+	if level == -1 {
+		level = len(line)
+	}
+	text := renderInline(strings.Trim(strings.TrimSpace(line[level:]), "#"), Themes[ThemeNone])
+	text = strings.TrimSpace(text)
+	lines := []string{t.Heading.Paint(text)}
+	switch level {
+	case 1:
+		lines = append(lines, t.Heading.Paint(strings.Repeat("═", utf8.RuneCountInString(text))))
+	case 2:
+		lines = append(lines, t.Heading.Paint(strings.Repeat("─", utf8.RuneCountInString(text))))
+	}
+	return lines
+}
+
+// renderListItem renders a list item indented by its level. Bullets are
+// replaced by a dot and tasks by a check box.
+func renderListItem(indent, marker, text string, width int, t *Theme) []string {
+	level := len(strings.Replace(indent, "\t", "    ", -1)) / 2
+	if marker == "-" || marker == "*" || marker == "+" {
+		marker = "•"
+	}
+	if task := taskItemText.FindStringSubmatch(text); task != nil {
+		marker = "☐"
+		if task[1] != " " {
+			marker = "☑"
+		}
+		text = task[2]
+	}
+	prefix := strings.Repeat("  ", level+1) + marker + " "
+	rest := strings.Repeat(" ", utf8.RuneCountInString(prefix))
+	return wrapText(renderInline(text, t), width, prefix, rest)
+}
+
+// renderInline replaces the inline markup, like bold text, code and links,
+// with the theme's styles.
+func renderInline(text string, t *Theme) string {
+	var tokens []string
+	hold := func(s string) string {
+		tokens = append(tokens, s)
+		return fmt.Sprintf("\x00%d\x00", len(tokens)-1)
+	}
+	text = inlineCodeSpan.ReplaceAllStringFunc(text, func(s string) string {
+		return hold(t.Code.Paint(s[1 : len(s)-1]))
+	})
+	text = inlineEscape.ReplaceAllStringFunc(text, func(s string) string { return hold(s[1:]) })
+	text = inlineImage.ReplaceAllStringFunc(text, func(s string) string {
+		alt := inlineImage.FindStringSubmatch(s)[1]
+		if alt == "" {
+			return hold(t.Link.Paint("[image]"))
+		}
+		return hold(t.Link.Paint("[image: " + alt + "]"))
+	})
+	text = inlineLink.ReplaceAllStringFunc(text, func(s string) string {
+		m := inlineLink.FindStringSubmatch(s)
+		if m[1] == m[2] {
+			return hold(t.Link.Paint(m[2]))
+		}
+		return hold(t.Link.Paint(m[1]) + " (" + m[2] + ")")
+	})
+	text = inlineStrong.ReplaceAllStringFunc(text, func(s string) string {
+		return t.Strong.Paint(s[2 : len(s)-2])
+	})
+	text = inlineEmphasis.ReplaceAllStringFunc(text, func(s string) string {
+		return t.Emphasis.Paint(s[1 : len(s)-1])
+	})
+	for placeholder.MatchString(text) {
+		text = placeholder.ReplaceAllStringFunc(text, func(s string) string {
+			var i int
+			fmt.Sscanf(placeholder.FindStringSubmatch(s)[1], "%d", &i)
+			return tokens[i]
+		})
+	}
+	return text
+}
+
+// wrapText wraps the text to the width. The first line starts with first
+// and the following lines with rest.
+func wrapText(text string, width int, first, rest string) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{strings.TrimRight(first, " ")}
+	}
+	var lines []string
+	line, lineLen := first+words[0], visibleLen(first)+visibleLen(words[0])
+	wrap := width-visibleLen(rest) >= minWrapWidth
+	for _, w := range words[1:] {
+		l := visibleLen(w)
+		if wrap && lineLen+1+l > width {
+			lines = append(lines, line)
+			line, lineLen = rest+w, visibleLen(rest)+l
+			continue
+		}
+		line += " " + w
+		lineLen += 1 + l
+	}
+	return append(lines, line)
+}
+
+// visibleLen returns the number of characters shown for the text, without
+// the escape codes.
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(ansiCodes.ReplaceAllString(s, ""))
+}
+
+// collapseBlankLines removes leading, trailing and repeated blank lines.
+func collapseBlankLines(lines []string) []string {
+	var out []string
+	for _, l := range lines {
+		if l == "" && (len(out) == 0 || out[len(out)-1] == "") {
+			continue
+		}
+		out = append(out, l)
+	}
+	for len(out) != 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
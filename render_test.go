@@ -0,0 +1,96 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	assert := assert.New(t)
+	none := Themes[ThemeNone]
+
+	t.Run("blocks", func(t *testing.T) {
+		md := "# Shopping\n\n\n\n## Fruit\n\n- Apples\n  - Green\n1. First\n- [ ] Milk\n- [x] Bread\n\n> Don't *forget*\n\n---\n\n```go\nx := 1\n```\n"
+		expected := strings.Join([]string{
+			"Shopping",
+			"════════",
+			"",
+			"Fruit",
+			"─────",
+			"",
+			"  • Apples",
+			"    • Green",
+			"  1. First",
+			"  ☐ Milk",
+			"  ☑ Bread",
+			"",
+			"│ Don't forget",
+			"",
+			"──────────",
+			"",
+			"    x := 1",
+		}, "\n")
+		assert.Equal(expected, RenderMarkdown(md, 10, none))
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		md := "Some **bold**, *italic*, `**code**`, snake_case_name, 2 * 3 * 4, \\*stars\\*, [link](https://example.com) and ![cat](cat.png)."
+		expected := "Some bold, italic, **code**, snake_case_name, 2 * 3 * 4, *stars*, link (https://example.com) and [image: cat]."
+		assert.Equal(expected, RenderMarkdown(md, 200, none))
+
+		theme := &Theme{Strong: Style{1}, Code: Style{2}, Link: Style{4}}
+		assert.Equal("\033[1mbold\033[0m \033[2m*x*\033[0m \033[4mhttps://a.b\033[0m",
+			RenderMarkdown("**bold** `*x*` [https://a.b](https://a.b)", 80, theme))
+	})
+
+	t.Run("wrap", func(t *testing.T) {
+		md := "The quick brown fox jumps over the lazy dog\nand keeps running.\n\n- A list item that is long enough to wrap"
+		expected := strings.Join([]string{
+			"The quick brown fox jumps",
+			"over the lazy dog and keeps",
+			"running.",
+			"",
+			"  • A list item that is long",
+			"    enough to wrap",
+		}, "\n")
+		assert.Equal(expected, RenderMarkdown(md, 28, none))
+	})
+
+	t.Run("line_break", func(t *testing.T) {
+		assert.Equal("Line one\nLine two", RenderMarkdown("Line one  \nLine two", 80, none))
+	})
+}
+
+func TestRenderWidth(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(defaultRenderWidth, RenderWidth(0))
+	assert.Equal(60, RenderWidth(60))
+	assert.Equal(maxRenderWidth, RenderWidth(300))
+}
+
+func TestWriteRenderedNote(t *testing.T) {
+	buf := new(bytes.Buffer)
+	n := &Note{Title: "Title", MD: "**Bold** text", Notebook: &Notebook{Name: "Notebook"}}
+	assert.NoError(t, WriteNote(buf, n, RenderNote))
+	assert.Equal(t, "Title\nNotebook\n\nBold text\n", buf.String())
+}
@@ -0,0 +1,64 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"mime"
+	"path/filepath"
+)
+
+// Resource is a file attached to a note, for example an image or a PDF.
+type Resource struct {
+	// GUID is the unique identifier.
+	GUID string
+	// Hash is the hex encoded MD5 hash of the data. The note content
+	// references the resource by the hash in an en-media element.
+	Hash string
+	// Mime is the resource's mime type.
+	Mime string
+	// Filename is the name of the file the resource was created from.
+	Filename string
+	// Size is the size of the data in bytes.
+	Size int
+	// Data is the resource's content. Nil if it hasn't been fetched.
+	Data []byte
+	// Recognition is the text the note service recognized in the
+	// resource, for images. Nil if the service doesn't recognize text.
+	Recognition []RecognizedText
+}
+
+// resourceHash returns the hash used to reference the data from the note
+// content.
+func resourceHash(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// resourceExtension returns the file extension for the resource, from its
+// file name or its mime type.
+func resourceExtension(r *Resource) string {
+	if ext := filepath.Ext(r.Filename); ext != "" {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(r.Mime); err == nil && len(exts) != 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,223 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultResourceName is the default file name template for pulled
+// attachments.
+const DefaultResourceName = "{{.Title}} - {{.Filename}}"
+
+// ErrResourcePathOutsideDir is returned if the file name template gives a
+// path outside of the download folder.
+var ErrResourcePathOutsideDir = errors.New("the file name must be inside the download folder")
+
+// ResourceNameData is the data available to the file name template. The
+// title and file name are safe to use in a path.
+type ResourceNameData struct {
+	// Title is the note's title.
+	Title string
+	// GUID is the note's GUID.
+	GUID string
+	// Filename is the attachment's file name. Attachments without a name
+	// are named after their index in the note.
+	Filename string
+	// Ext is the file extension, including the dot.
+	Ext string
+	// Mime is the attachment's mime type.
+	Mime string
+	// Index is the attachment's position in the note, starting at 1.
+	Index int
+	// Created is when the note was created.
+	Created time.Time
+	// Updated is when the note was last updated.
+	Updated time.Time
+}
+
+// PullOptions are the options for PullResources.
+type PullOptions struct {
+	// Dir is the folder the attachments are saved in.
+	Dir string
+	// Name is the file name template, relative to Dir. Folders in the name
+	// are created.
+	Name string
+	// Mime limits the attachments to the mime type. A type ending with a
+	// slash, like "image/", matches all subtypes.
+	Mime string
+}
+
+// PullResult is the result of PullResources.
+type PullResult struct {
+	// Notes is the number of notes matching the search.
+	Notes int
+	// Saved are the paths of the saved attachments.
+	Saved []string
+	// Skipped is the number of attachments that were already downloaded.
+	Skipped int
+}
+
+// PullResources downloads the attachments of the notes matching the filter
+// to the folder in the options. The note content is not downloaded. Files
+// that already exist with the same content are skipped, so the pull can be
+// repeated to fetch new attachments. The saved function, if not nil, is
+// called with the path of each saved file.
+func PullResources(ns NotestoreClient, filter *NoteFilter, opts *PullOptions, saved func(path string)) (*PullResult, error) {
+	name := opts.Name
+	if name == "" {
+		name = DefaultResourceName
+	}
+	tmpl, err := template.New("name").Funcs(template.FuncMap{
+		"date": func(layout string, t time.Time) string { return t.Format(layout) },
+	}).Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	f := *filter
+	if opts.Mime != "" {
+		// Let the server skip the notes without matching attachments.
+		query := "resource:" + opts.Mime
+		if strings.HasSuffix(opts.Mime, "/") {
+			query += "*"
+		}
+		f.Words = strings.TrimSpace(f.Words + " " + query)
+	}
+	notes, err := FindAllNotes(ns, &f, DefaultBulkPageSize)
+	if err != nil {
+		return nil, err
+	}
+	result := &PullResult{Notes: len(notes)}
+	for _, n := range notes {
+		resources, err := ns.GetNoteResources(n.GUID)
+		if err != nil {
+			return result, err
+		}
+		for i, r := range resources {
+			if !mimeMatches(r.Mime, opts.Mime) {
+				continue
+			}
+			path, err := resourcePath(tmpl, opts.Dir, n, r, i+1)
+			if err != nil {
+				return result, err
+			}
+			path, exists, err := uniqueResourcePath(path, r.Data)
+			if err != nil {
+				return result, err
+			}
+			if exists {
+				result.Skipped++
+				continue
+			}
+			if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return result, err
+			}
+			if err = ioutil.WriteFile(path, r.Data, 0600); err != nil {
+				return result, err
+			}
+			result.Saved = append(result.Saved, path)
+			if saved != nil {
+				saved(path)
+			}
+		}
+	}
+	return result, nil
+}
+
+func mimeMatches(mime, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	if strings.HasSuffix(filter, "/") {
+		return strings.HasPrefix(mime, filter)
+	}
+	return mime == filter
+}
+
+// resourcePath returns the path for the attachment given by the template.
+func resourcePath(tmpl *template.Template, dir string, n *Note, r *Resource, index int) (string, error) {
+	ext := resourceExtension(r)
+	filename := r.Filename
+	if filename == "" {
+		filename = "attachment-" + strconv.Itoa(index) + ext
+	}
+	data := &ResourceNameData{
+		Title:    safeFilename(n.Title),
+		GUID:     n.GUID,
+		Filename: safeFilename(filename),
+		Ext:      ext,
+		Mime:     r.Mime,
+		Index:    index,
+		Created:  time.Unix(n.Created/1000, 0),
+		Updated:  time.Unix(n.Updated/1000, 0),
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	name := filepath.Clean(filepath.FromSlash(buf.String()))
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", ErrResourcePathOutsideDir
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// uniqueResourcePath returns the path the data should be saved to. If a
+// file with the same content already exists, its path is returned and
+// exists is true. If a different file exists, a number is added to the name.
+func uniqueResourcePath(path string, data []byte) (string, bool, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		p := path
+		if i > 1 {
+			p = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+		existing, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			return p, false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if bytes.Equal(existing, data) {
+			return p, true, nil
+		}
+	}
+}
+
+// safeFilename replaces the characters that aren't allowed in file names
+// on some systems.
+func safeFilename(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	return strings.Trim(s, ". ")
+}
@@ -0,0 +1,105 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullResources(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-pull")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	created := time.Date(2018, 3, 14, 12, 0, 0, 0, time.Local).Unix() * 1000
+	notes := []*Note{
+		{GUID: "1", Title: "Receipt: lunch", Created: created},
+		{GUID: "2", Title: "Receipt/taxi", Created: created},
+	}
+	resources := map[string][]*Resource{
+		"1": {
+			{Mime: "application/pdf", Filename: "lunch.pdf", Data: []byte("pdf")},
+			{Mime: "image/png", Data: []byte("png")},
+		},
+		"2": {{Mime: "image/jpeg", Filename: "taxi.jpg", Data: []byte("jpg")}},
+	}
+	var query string
+	ns := &mockNS{
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			query = f.Words
+			return notes, nil
+		},
+		getResources: func(guid string) ([]*Resource, error) { return resources[guid], nil },
+	}
+	filter := &NoteFilter{Words: "tag:receipts"}
+
+	t.Run("default name", func(t *testing.T) {
+		var saved []string
+		result, err := PullResources(ns, filter, &PullOptions{Dir: dir}, func(p string) { saved = append(saved, p) })
+		assert.NoError(err)
+		assert.Equal(2, result.Notes)
+		assert.Equal(0, result.Skipped)
+		assert.Equal([]string{
+			filepath.Join(dir, "Receipt_ lunch - lunch.pdf"),
+			filepath.Join(dir, "Receipt_ lunch - attachment-2.png"),
+			filepath.Join(dir, "Receipt_taxi - taxi.jpg"),
+		}, result.Saved)
+		assert.Equal(result.Saved, saved)
+		data, err := ioutil.ReadFile(result.Saved[0])
+		assert.NoError(err)
+		assert.Equal("pdf", string(data))
+		assert.Equal("tag:receipts", query, "The filter should not be changed")
+	})
+
+	t.Run("skip downloaded", func(t *testing.T) {
+		result, err := PullResources(ns, filter, &PullOptions{Dir: dir}, nil)
+		assert.NoError(err)
+		assert.Empty(result.Saved)
+		assert.Equal(3, result.Skipped)
+	})
+
+	t.Run("name collision", func(t *testing.T) {
+		resources["2"][0].Data = []byte("new jpg")
+		defer func() { resources["2"][0].Data = []byte("jpg") }()
+		result, err := PullResources(ns, filter, &PullOptions{Dir: dir}, nil)
+		assert.NoError(err)
+		assert.Equal([]string{filepath.Join(dir, "Receipt_taxi - taxi (2).jpg")}, result.Saved)
+	})
+
+	t.Run("template and mime", func(t *testing.T) {
+		opts := &PullOptions{Dir: dir, Name: `{{date "2006-01" .Created}}/{{.Index}}{{.Ext}}`, Mime: "image/"}
+		result, err := PullResources(ns, filter, opts, nil)
+		assert.NoError(err)
+		assert.Equal([]string{
+			filepath.Join(dir, "2018-03", "2.png"),
+			filepath.Join(dir, "2018-03", "1.jpg"),
+		}, result.Saved)
+		assert.Equal("tag:receipts resource:image/*", query)
+	})
+
+	t.Run("outside dir", func(t *testing.T) {
+		_, err := PullResources(ns, filter, &PullOptions{Dir: dir, Name: "../{{.Filename}}"}, nil)
+		assert.Equal(ErrResourcePathOutsideDir, err)
+	})
+}
@@ -0,0 +1,96 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	// DefaultRetryAttempts is how many times a call is made before a
+	// transient network error is returned, if not set.
+	DefaultRetryAttempts = 3
+	// DefaultRetryDelay is the wait before the first retry, if not set.
+	DefaultRetryDelay = 500 * time.Millisecond
+)
+
+var (
+	// ErrInvalidRetryAttempts is returned if the number of attempts is less than one.
+	ErrInvalidRetryAttempts = errors.New("the number of attempts has to be at least 1")
+	// ErrInvalidRetryJitter is returned if the jitter isn't between 0 and 1.
+	ErrInvalidRetryJitter = errors.New("the jitter has to be between 0 and 1")
+)
+
+// RetryPolicy is how calls that fail because of a transient network error
+// are retried. The wait doubles for each retry.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a call is made. One turns retries off.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry.
+	BaseDelay time.Duration
+	// Jitter is the fraction of the wait that is randomized, between 0
+	// and 1. The wait is changed by up to that fraction in either
+	// direction so clients don't retry in lockstep.
+	Jitter float64
+	// JitterSet is true if Jitter is set, so an override without jitter
+	// replaces the jitter in the settings.
+	JitterSet bool
+}
+
+// GetRetryPolicy returns the retry policy. The fields set in override are
+// used first, then the ones in the settings and then the defaults. The
+// settings may be nil.
+func GetRetryPolicy(s *Settings, override RetryPolicy) RetryPolicy {
+	p := override
+	if s != nil {
+		if p.MaxAttempts == 0 {
+			p.MaxAttempts = s.RetryAttempts
+		}
+		if p.BaseDelay == 0 {
+			p.BaseDelay = s.RetryDelay
+		}
+		if !p.JitterSet {
+			p.Jitter = s.RetryJitter
+		}
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryDelay
+	}
+	return p
+}
+
+// Delay returns the wait before the retry. Attempt is zero for the first
+// retry. r is a random number in [0, 1) used for the jitter.
+func (p RetryPolicy) Delay(attempt int, r float64) time.Duration {
+	d := float64(p.BaseDelay) * math.Pow(2, float64(attempt))
+	d += d * p.Jitter * (2*r - 1)
+	return time.Duration(d)
+}
+
+// ValidateRetryJitter returns an error if the jitter isn't between 0 and 1.
+func ValidateRetryJitter(j float64) error {
+	if j < 0 || j > 1 {
+		return ErrInvalidRetryJitter
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRetryPolicy(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(RetryPolicy{MaxAttempts: DefaultRetryAttempts, BaseDelay: DefaultRetryDelay}, GetRetryPolicy(nil, RetryPolicy{}))
+
+	s := &Settings{RetryAttempts: 5, RetryDelay: time.Second, RetryJitter: 0.2}
+	assert.Equal(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, Jitter: 0.2}, GetRetryPolicy(s, RetryPolicy{}))
+	assert.Equal(RetryPolicy{MaxAttempts: 1, BaseDelay: time.Second, Jitter: 0.2}, GetRetryPolicy(s, RetryPolicy{MaxAttempts: 1}), "The override should be used first")
+	assert.Equal(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, JitterSet: true}, GetRetryPolicy(s, RetryPolicy{JitterSet: true}), "A zero jitter should override the settings")
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	assert := assert.New(t)
+	p := RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}
+	assert.Equal(time.Second, p.Delay(0, 0.5))
+	assert.Equal(4*time.Second, p.Delay(2, 0.5))
+	assert.Equal(2*time.Second, p.Delay(2, 0))
+	assert.Equal(time.Duration(5.6*float64(time.Second)), p.Delay(2, 0.9))
+}
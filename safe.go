@@ -0,0 +1,31 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import "errors"
+
+// SafeEnv is the environment variable that enables safe mode.
+const SafeEnv = "CLINOTE_SAFE"
+
+// SafeMode disables hooks, template commands and other external commands
+// configured by the user. It's used for troubleshooting and when working
+// with untrusted content. The editor is still started.
+var SafeMode = false
+
+// ErrSafeMode is returned instead of running an external command in safe mode.
+var ErrSafeMode = errors.New("external commands are disabled in safe mode")
@@ -0,0 +1,130 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSearchDate is returned for a date in a search that isn't in
+// the search grammar's format.
+var ErrInvalidSearchDate = errors.New("invalid search date, use YYYYMMDD, YYYYMMDDThhmmssZ or a relative date like week-1")
+
+// SearchQuery is a note search given as separate parts. The parts are
+// combined into a search string in the Evernote search grammar, for
+// example `tag:work intitle:plan created:20240101T000000Z`. All the
+// parts have to match.
+type SearchQuery struct {
+	// Words is a search string, it can use the full search grammar.
+	Words string
+	// Tags are the tags the notes must have. A tag ending with * matches
+	// all tags starting with the text.
+	Tags []string
+	// InTitle is text the note titles must contain.
+	InTitle string
+	// Resource is the mime type of an attachment the notes must have. A
+	// type ending with *, like image/*, matches all subtypes.
+	Resource string
+	// CreatedAfter limits the search to notes created after the time.
+	CreatedAfter time.Time
+	// CreatedBefore limits the search to notes created before the time.
+	CreatedBefore time.Time
+}
+
+// String returns the search in the search grammar. Values with spaces are
+// quoted.
+func (q *SearchQuery) String() string {
+	var terms []string
+	if words := strings.TrimSpace(q.Words); words != "" {
+		terms = append(terms, words)
+	}
+	for _, tag := range q.Tags {
+		if tag != "" {
+			terms = append(terms, "tag:"+quoteSearchValue(tag))
+		}
+	}
+	if q.InTitle != "" {
+		terms = append(terms, "intitle:"+quoteSearchValue(q.InTitle))
+	}
+	if q.Resource != "" {
+		terms = append(terms, "resource:"+quoteSearchValue(q.Resource))
+	}
+	if created := CreatedSearch(q.CreatedAfter, q.CreatedBefore); created != "" {
+		terms = append(terms, created)
+	}
+	return strings.Join(terms, " ")
+}
+
+// quoteSearchValue quotes the value if it has spaces. Quotes in the value
+// are removed since the grammar can't escape them.
+func quoteSearchValue(value string) string {
+	value = strings.Replace(value, `"`, "", -1)
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// searchDateUnits are the units of the relative dates in the search
+// grammar.
+var searchDateUnits = map[string]func(t time.Time, n int) time.Time{
+	"day": func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) },
+	"week": func(t time.Time, n int) time.Time {
+		return t.AddDate(0, 0, -int(t.Weekday())+7*n)
+	},
+	"month": func(t time.Time, n int) time.Time { return t.AddDate(0, n, 1-t.Day()) },
+	"year":  func(t time.Time, n int) time.Time { return time.Date(t.Year()+n, 1, 1, 0, 0, 0, 0, t.Location()) },
+}
+
+// ParseSearchDate parses a date in the search grammar, as used by created:
+// and updated:. Absolute dates are given as YYYYMMDD, in local time, or
+// YYYYMMDDThhmmss with a Z suffix for UTC. Relative dates are day, week,
+// month or year, optionally followed by a minus and a count, and mean the
+// start of the current or an earlier period. Weeks start on Sunday.
+func ParseSearchDate(value string, now time.Time) (time.Time, error) {
+	lower := strings.ToLower(value)
+	unit, count := lower, 0
+	if i := strings.IndexAny(lower, "-+"); i > 0 {
+		n, err := strconv.Atoi(lower[i:])
+		if err != nil {
+			return time.Time{}, ErrInvalidSearchDate
+		}
+		unit, count = lower[:i], n
+	}
+	if period, ok := searchDateUnits[unit]; ok {
+		return period(startOfDay(now), count), nil
+	}
+	switch {
+	case len(value) == len("20060102"):
+		if t, err := time.ParseInLocation("20060102", value, now.Location()); err == nil {
+			return t, nil
+		}
+	case strings.HasSuffix(lower, "z"):
+		if t, err := time.Parse("20060102T150405Z", strings.ToUpper(value)); err == nil {
+			return t, nil
+		}
+	default:
+		if t, err := time.ParseInLocation("20060102T150405", strings.ToUpper(value), now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidSearchDate
+}
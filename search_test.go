@@ -0,0 +1,61 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQuery(t *testing.T) {
+	assert := assert.New(t)
+	q := &SearchQuery{
+		Words:        " meeting -draft ",
+		Tags:         []string{"work", "to do", ""},
+		InTitle:      `"plan"`,
+		Resource:     "image/*",
+		CreatedAfter: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	assert.Equal(`meeting -draft tag:work tag:"to do" intitle:plan resource:image/* created:20240102T030405Z`, q.String())
+	assert.Equal("", new(SearchQuery).String())
+}
+
+func TestParseSearchDate(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 9, 26, 0, time.Local) // A Thursday.
+	for value, expected := range map[string]time.Time{
+		"day":              time.Date(2024, 3, 14, 0, 0, 0, 0, time.Local),
+		"day-2":            time.Date(2024, 3, 12, 0, 0, 0, 0, time.Local),
+		"week":             time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local),
+		"Week-1":           time.Date(2024, 3, 3, 0, 0, 0, 0, time.Local),
+		"month-3":          time.Date(2023, 12, 1, 0, 0, 0, 0, time.Local),
+		"year":             time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+		"20230215":         time.Date(2023, 2, 15, 0, 0, 0, 0, time.Local),
+		"20230215T101500":  time.Date(2023, 2, 15, 10, 15, 0, 0, time.Local),
+		"20230215T101500Z": time.Date(2023, 2, 15, 10, 15, 0, 0, time.UTC),
+	} {
+		d, err := ParseSearchDate(value, now)
+		assert.NoError(t, err, value)
+		assert.True(t, expected.Equal(d), "%s: %s", value, d)
+	}
+	for _, value := range []string{"", "decade", "day-x", "2023-02-15", "20231315"} {
+		_, err := ParseSearchDate(value, now)
+		assert.Equal(t, ErrInvalidSearchDate, err, value)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import "errors"
+
+// ErrNoSavedSearch is returned when refreshing the search result if no
+// search has been saved.
+var ErrNoSavedSearch = errors.New("no saved search, search for notes first")
+
+// NoteVersionFinder is implemented by the notestores that can search for
+// the notes' update sequence numbers without the rest of the metadata. It's
+// used to refresh a saved search without fetching the unchanged notes.
+type NoteVersionFinder interface {
+	// FindNoteVersions returns the notes matching the filter with only the
+	// GUID and the USN set.
+	FindNoteVersions(filter *NoteFilter, offset, count int) ([]*Note, error)
+	// GetNoteMetadata returns the note's metadata, without the content.
+	GetNoteMetadata(guid string) (*Note, error)
+}
+
+// SavedSearch is the query of the saved search result.
+type SavedSearch struct {
+	// Filter is the search filter.
+	Filter NoteFilter
+	// Count is the maximum number of notes in the result.
+	Count int
+	// Near, if set, limits the result to the notes within Radius
+	// kilometers of the location.
+	Near *Location `json:",omitempty"`
+	// Radius is the radius of the Near filter in kilometers.
+	Radius float64 `json:",omitempty"`
+	// UpdateCount is the account's update count when the result was last
+	// refreshed. Zero if it's unknown.
+	UpdateCount int32 `json:",omitempty"`
+}
+
+// SearchQueryStore stores the query of the saved search result.
+type SearchQueryStore interface {
+	// GetSearchQuery returns the saved query, or nil if none is saved.
+	GetSearchQuery() (*SavedSearch, error)
+	// SaveSearchQuery replaces the saved query.
+	SaveSearchQuery(*SavedSearch) error
+}
+
+// SearchRefresh is the result of RefreshSearch.
+type SearchRefresh struct {
+	// Notes is the refreshed search result.
+	Notes []*Note
+	// Fetched is the number of notes whose metadata was fetched.
+	Fetched int
+	// Unchanged is true if the account hasn't changed since the last
+	// refresh, so the saved result was used as is.
+	Unchanged bool
+}
+
+// SaveSearchResult saves the notes found by the search, and the query if
+// the storage supports it, so the search can be refreshed.
+func SaveSearchResult(db Storager, notes []*Note, query *SavedSearch) error {
+	if err := db.SaveSearch(notes); err != nil {
+		return err
+	}
+	if s, ok := db.(SearchQueryStore); ok {
+		return s.SaveSearchQuery(query)
+	}
+	return nil
+}
+
+// RefreshSearch runs the saved search again and saves the new result. If
+// the account hasn't changed since the last refresh, the saved result is
+// kept. Otherwise only the GUIDs and USNs of the matching notes are
+// searched for, and the metadata is fetched for the notes that are new to
+// the result or have a different USN. The other notes are taken from the
+// saved result. Note services that can't search for the USNs run the
+// full search.
+func RefreshSearch(db Storager, ns NotestoreClient) (*SearchRefresh, error) {
+	s, ok := db.(SearchQueryStore)
+	if !ok {
+		return nil, ErrNoSavedSearch
+	}
+	query, err := s.GetSearchQuery()
+	if err != nil {
+		return nil, err
+	}
+	if query == nil {
+		return nil, ErrNoSavedSearch
+	}
+	saved, err := db.GetSearch()
+	if err != nil {
+		return nil, err
+	}
+	state, err := ns.GetSyncState()
+	if err != nil && err != ErrNotSupported {
+		return nil, err
+	}
+	if state != nil && query.UpdateCount != 0 && state.UpdateCount == query.UpdateCount {
+		return &SearchRefresh{Notes: saved, Unchanged: true}, nil
+	}
+	result := new(SearchRefresh)
+	versions, err := findNoteVersions(ns, &query.Filter, 0, query.Count)
+	switch {
+	case err == ErrNotSupported:
+		if result.Notes, err = FindNotes(ns, &query.Filter, 0, query.Count); err != nil {
+			return nil, err
+		}
+		result.Fetched = len(result.Notes)
+	case err != nil:
+		return nil, err
+	default:
+		if result.Notes, result.Fetched, err = mergeNoteVersions(ns, saved, versions); err != nil {
+			return nil, err
+		}
+	}
+	if query.Near != nil {
+		result.Notes = FilterNotesNear(result.Notes, query.Near, query.Radius)
+	}
+	query.UpdateCount = 0
+	if state != nil {
+		query.UpdateCount = state.UpdateCount
+	}
+	return result, SaveSearchResult(db, result.Notes, query)
+}
+
+// mergeNoteVersions returns the notes in the order of the versions. Saved
+// notes with the same USN are reused, the others are fetched. The number
+// of fetched notes is returned.
+func mergeNoteVersions(ns NotestoreClient, saved, versions []*Note) ([]*Note, int, error) {
+	byGUID := make(map[string]*Note, len(saved))
+	for _, n := range saved {
+		byGUID[n.GUID] = n
+	}
+	notes := make([]*Note, len(versions))
+	fetched := 0
+	for i, v := range versions {
+		if n, ok := byGUID[v.GUID]; ok && n.USN != 0 && n.USN == v.USN {
+			notes[i] = n
+			continue
+		}
+		n, err := getNoteMetadata(ns, v.GUID)
+		if err != nil {
+			return nil, fetched, err
+		}
+		notes[i] = n
+		fetched++
+	}
+	return notes, fetched, nil
+}
+
+func findNoteVersions(ns NotestoreClient, filter *NoteFilter, offset, count int) ([]*Note, error) {
+	if f, ok := ns.(NoteVersionFinder); ok {
+		return f.FindNoteVersions(filter, offset, count)
+	}
+	return nil, ErrNotSupported
+}
+
+func getNoteMetadata(ns NotestoreClient, guid string) (*Note, error) {
+	if f, ok := ns.(NoteVersionFinder); ok {
+		return f.GetNoteMetadata(guid)
+	}
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,130 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSearchQueryStore struct {
+	*mockStore
+	query *SavedSearch
+}
+
+func (m *mockSearchQueryStore) GetSearchQuery() (*SavedSearch, error) {
+	return m.query, nil
+}
+
+func (m *mockSearchQueryStore) SaveSearchQuery(query *SavedSearch) error {
+	m.query = query
+	return nil
+}
+
+type mockVersionNS struct {
+	*mockNS
+	versions []*Note
+	fetched  []string
+}
+
+func (m *mockVersionNS) FindNoteVersions(filter *NoteFilter, offset, count int) ([]*Note, error) {
+	return m.versions, nil
+}
+
+func (m *mockVersionNS) GetNoteMetadata(guid string) (*Note, error) {
+	m.fetched = append(m.fetched, guid)
+	return &Note{GUID: guid, Title: "Fetched " + guid, USN: 9}, nil
+}
+
+func TestRefreshSearch(t *testing.T) {
+	state := &SyncState{UpdateCount: 10}
+	newStore := func() *mockSearchQueryStore {
+		return &mockSearchQueryStore{
+			mockStore: &mockStore{savedSearch: []*Note{
+				{GUID: "a", Title: "Cached a", USN: 1},
+				{GUID: "b", Title: "Cached b", USN: 2},
+			}},
+			query: &SavedSearch{Filter: NoteFilter{Words: "tag:work"}, Count: 20, UpdateCount: 5},
+		}
+	}
+
+	t.Run("Only changed notes are fetched", func(t *testing.T) {
+		assert := assert.New(t)
+		db := newStore()
+		ns := &mockVersionNS{
+			mockNS:   &mockNS{getSyncState: func() (*SyncState, error) { return state, nil }},
+			versions: []*Note{{GUID: "c", USN: 7}, {GUID: "a", USN: 1}, {GUID: "b", USN: 3}},
+		}
+		result, err := RefreshSearch(db, ns)
+		assert.NoError(err)
+		assert.False(result.Unchanged)
+		assert.Equal(2, result.Fetched)
+		assert.Equal([]string{"c", "b"}, ns.fetched)
+		assert.Equal([]string{"Fetched c", "Cached a", "Fetched b"}, noteTitles(result.Notes))
+		assert.Equal(result.Notes, db.savedSearch)
+		assert.Equal(int32(10), db.query.UpdateCount)
+	})
+
+	t.Run("Unchanged account keeps the saved result", func(t *testing.T) {
+		assert := assert.New(t)
+		db := newStore()
+		db.query.UpdateCount = 10
+		ns := &mockVersionNS{mockNS: &mockNS{getSyncState: func() (*SyncState, error) { return state, nil }}}
+		result, err := RefreshSearch(db, ns)
+		assert.NoError(err)
+		assert.True(result.Unchanged)
+		assert.Equal([]string{"Cached a", "Cached b"}, noteTitles(result.Notes))
+		assert.Empty(ns.fetched)
+	})
+
+	t.Run("Full search without note versions", func(t *testing.T) {
+		assert := assert.New(t)
+		db := newStore()
+		var words string
+		ns := &mockNS{
+			getSyncState: func() (*SyncState, error) { return nil, ErrNotSupported },
+			findNotes: func(filter *NoteFilter, offset, count int) ([]*Note, error) {
+				words = filter.Words
+				return []*Note{{GUID: "a", Title: "Found a"}}, nil
+			},
+		}
+		result, err := RefreshSearch(db, ns)
+		assert.NoError(err)
+		assert.Equal("tag:work", words)
+		assert.Equal(1, result.Fetched)
+		assert.Equal([]string{"Found a"}, noteTitles(result.Notes))
+		assert.Equal(int32(0), db.query.UpdateCount)
+	})
+
+	t.Run("No saved search", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := RefreshSearch(&mockSearchQueryStore{mockStore: &mockStore{}}, &mockNS{})
+		assert.Equal(ErrNoSavedSearch, err)
+		_, err = RefreshSearch(&mockStore{}, &mockNS{})
+		assert.Equal(ErrNoSavedSearch, err)
+	})
+}
+
+func noteTitles(notes []*Note) []string {
+	titles := make([]string, len(notes))
+	for i, n := range notes {
+		titles[i] = n.Title
+	}
+	return titles
+}
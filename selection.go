@@ -0,0 +1,93 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import "strings"
+
+// NotebookSelected returns true if the notebook is part of the notebooks
+// selected by the SyncInclude and SyncExclude settings. Notebook names
+// are matched case insensitive.
+func (s *Settings) NotebookSelected(name string) bool {
+	if len(s.SyncInclude) != 0 && !containsName(s.SyncInclude, name) {
+		return false
+	}
+	return !containsName(s.SyncExclude, name)
+}
+
+// FilterSelectedNotes removes the notes that belong to notebooks that are
+// not selected by the sync settings.
+func FilterSelectedNotes(db Storager, ns NotestoreClient, notes []*Note) ([]*Note, error) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if len(settings.SyncInclude) == 0 && len(settings.SyncExclude) == 0 {
+		return notes, nil
+	}
+	bs, err := GetNotebooks(db, ns, false)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(bs))
+	for _, b := range bs {
+		names[b.GUID] = b.Name
+	}
+	selected := make([]*Note, 0, len(notes))
+	for _, n := range notes {
+		if n.Notebook != nil {
+			if name, ok := names[n.Notebook.GUID]; ok && !settings.NotebookSelected(name) {
+				continue
+			}
+		}
+		selected = append(selected, n)
+	}
+	return selected, nil
+}
+
+// ParseNotebookList splits a comma separated list of notebook names.
+func ParseNotebookList(list string) []string {
+	return splitList(list)
+}
+
+// ParseTagList splits a comma separated list of tag names. An empty
+// list results in an empty, non-nil, slice.
+func ParseTagList(list string) []string {
+	if tags := splitList(list); tags != nil {
+		return tags
+	}
+	return []string{}
+}
+
+func splitList(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotebookSelected(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		include  []string
+		exclude  []string
+		notebook string
+		expected bool
+	}{
+		{nil, nil, "Work", true},
+		{[]string{"Work"}, nil, "work", true},
+		{[]string{"Work"}, nil, "Archive", false},
+		{nil, []string{"Archive"}, "Archive", false},
+		{nil, []string{"Archive"}, "Work", true},
+		{[]string{"Work"}, []string{"Work"}, "Work", false},
+	}
+	for _, test := range tests {
+		settings := &Settings{SyncInclude: test.include, SyncExclude: test.exclude}
+		assert.Equal(test.expected, settings.NotebookSelected(test.notebook), "Wrong selection for %v", test)
+	}
+}
+
+func TestFilterSelectedNotes(t *testing.T) {
+	assert := assert.New(t)
+	settings := &Settings{}
+	db := &mockStore{
+		getSettings:       func() (*Settings, error) { return settings, nil },
+		getNotebookCache:  func() (*NotebookCacheList, error) { return &NotebookCacheList{}, nil },
+		storeNotebookList: func(*NotebookCacheList) error { return nil },
+	}
+	ns := &mockNS{getAllNotebooks: func() ([]*Notebook, error) {
+		return []*Notebook{&Notebook{Name: "Work", GUID: "1"}, &Notebook{Name: "Archive", GUID: "2"}}, nil
+	}}
+	notes := []*Note{
+		&Note{Title: "Work note", Notebook: &Notebook{GUID: "1"}},
+		&Note{Title: "Archived note", Notebook: &Notebook{GUID: "2"}},
+	}
+
+	t.Run("No selection", func(t *testing.T) {
+		selected, err := FilterSelectedNotes(db, ns, notes)
+		assert.NoError(err)
+		assert.Equal(notes, selected)
+	})
+
+	t.Run("Exclude", func(t *testing.T) {
+		settings.SyncExclude = []string{"archive"}
+		selected, err := FilterSelectedNotes(db, ns, notes)
+		assert.NoError(err)
+		assert.Equal(notes[:1], selected)
+	})
+}
+
+func TestParseNotebookList(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"Work", "Personal Stuff"}, ParseNotebookList("Work, Personal Stuff,"))
+	assert.Nil(ParseNotebookList(""))
+}
@@ -0,0 +1,193 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultStaleDays is the default number of days without updates
+	// before a note is counted as stale.
+	DefaultStaleDays = 365
+	// DefaultStatsTop is the default number of largest and stale notes
+	// listed by the statistics.
+	DefaultStatsTop = 5
+)
+
+// StatsOptions are the options for NoteStatistics.
+type StatsOptions struct {
+	// StaleDays is the number of days without updates before a note is
+	// counted as stale.
+	StaleDays int
+	// Top is the number of largest and stale notes listed.
+	Top int
+}
+
+// NoteStat is the size of a note.
+type NoteStat struct {
+	// Note is the note, without its content.
+	Note *Note
+	// Notebook is the name of the note's notebook.
+	Notebook string
+	// Words is the number of words in the note.
+	Words int
+	// Characters is the number of characters in the note, not counting
+	// whitespace.
+	Characters int
+}
+
+// GroupStat is the number of notes and words in a notebook or with a tag.
+type GroupStat struct {
+	// Name is the notebook's or tag's name.
+	Name string
+	// Notes is the number of notes.
+	Notes int
+	// Words is the number of words in the notes.
+	Words int
+}
+
+// NoteStats are the statistics of a set of notes.
+type NoteStats struct {
+	// Notes is the number of notes.
+	Notes int
+	// Words is the number of words in the notes.
+	Words int
+	// Characters is the number of characters in the notes, not counting
+	// whitespace.
+	Characters int
+	// Notebooks are the notebooks with notes, most notes first.
+	Notebooks []*GroupStat
+	// Tags are the tags used by the notes, most notes first.
+	Tags []*GroupStat
+	// Largest are the notes with the most words, largest first.
+	Largest []*NoteStat
+	// StaleNotes is the number of notes not updated in StaleDays.
+	StaleNotes int
+	// StaleDays is the number of days without updates for a stale note.
+	StaleDays int
+	// Stale are the notes that were updated longest ago, oldest first.
+	// Only notes not updated in StaleDays are included.
+	Stale []*NoteStat
+}
+
+// NoteStatistics returns the statistics for the notes. The note content
+// is read from the content cache, and fetched from the server if it
+// isn't cached. The words and characters are counted in the note's
+// Markdown text. The progress function is called after each note, if
+// not nil.
+func NoteStatistics(db Storager, ns NotestoreClient, notes []*Note, opts *StatsOptions, progress func(*Note)) (*NoteStats, error) {
+	nbs, err := GetNotebooks(db, ns, false)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := ns.GetAllTags()
+	if err != nil && err != ErrNotSupported {
+		return nil, err
+	}
+	notebookNames := make(map[string]string, len(nbs))
+	for _, nb := range nbs {
+		notebookNames[nb.GUID] = nb.Name
+	}
+	stats := &NoteStats{Notes: len(notes), StaleDays: opts.StaleDays}
+	notebooks := make(map[string]*GroupStat)
+	tagged := make(map[string]*GroupStat)
+	staleBefore := time.Now().AddDate(0, 0, -opts.StaleDays).Unix() * 1000
+	var sizes, stale []*NoteStat
+	for _, n := range notes {
+		content, err := getCachedNoteContent(db, ns, n)
+		if err != nil {
+			return nil, err
+		}
+		parsed := new(Note)
+		if err = parseNoteContent(content, parsed); err != nil {
+			return nil, err
+		}
+		s := &NoteStat{Note: n, Words: len(strings.Fields(parsed.MD)), Characters: countCharacters(parsed.MD)}
+		if n.Notebook != nil {
+			s.Notebook = notebookNames[n.Notebook.GUID]
+		}
+		stats.Words += s.Words
+		stats.Characters += s.Characters
+		addGroupStat(notebooks, s.Notebook, s.Words)
+		for _, tag := range noteTagNames(n, tags) {
+			addGroupStat(tagged, tag, s.Words)
+		}
+		sizes = append(sizes, s)
+		if n.Updated < staleBefore {
+			stale = append(stale, s)
+		}
+		if progress != nil {
+			progress(n)
+		}
+	}
+	stats.Notebooks = sortedGroupStats(notebooks)
+	stats.Tags = sortedGroupStats(tagged)
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].Words > sizes[j].Words })
+	stats.Largest = topNoteStats(sizes, opts.Top)
+	sort.SliceStable(stale, func(i, j int) bool { return stale[i].Note.Updated < stale[j].Note.Updated })
+	stats.StaleNotes = len(stale)
+	stats.Stale = topNoteStats(stale, opts.Top)
+	return stats, nil
+}
+
+// countCharacters returns the number of characters in the text, not
+// counting whitespace.
+func countCharacters(text string) int {
+	count := 0
+	for _, word := range strings.Fields(text) {
+		count += utf8.RuneCountInString(word)
+	}
+	return count
+}
+
+func addGroupStat(groups map[string]*GroupStat, name string, words int) {
+	g, ok := groups[name]
+	if !ok {
+		g = &GroupStat{Name: name}
+		groups[name] = g
+	}
+	g.Notes++
+	g.Words += words
+}
+
+// sortedGroupStats returns the groups with the most notes first. Groups
+// with the same number of notes are sorted by name.
+func sortedGroupStats(groups map[string]*GroupStat) []*GroupStat {
+	sorted := make([]*GroupStat, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Notes != sorted[j].Notes {
+			return sorted[i].Notes > sorted[j].Notes
+		}
+		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+	})
+	return sorted
+}
+
+func topNoteStats(stats []*NoteStat, top int) []*NoteStat {
+	if top >= 0 && len(stats) > top {
+		return stats[:top]
+	}
+	return stats
+}
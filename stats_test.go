@@ -0,0 +1,98 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoteStatistics(t *testing.T) {
+	assert := assert.New(t)
+	contents := map[string]string{
+		"1": XMLHeader + "<en-note><div>One two three four five.</div></en-note>",
+		"2": XMLHeader + "<en-note><div>Åtta nio</div></en-note>",
+		"3": XMLHeader + "<en-note><div>Ten</div></en-note>",
+	}
+	old := time.Now().AddDate(-2, 0, 0).Unix() * 1000
+	older := time.Now().AddDate(-3, 0, 0).Unix() * 1000
+	recent := time.Now().Unix() * 1000
+	ns := &mockNS{
+		getNoteContent: func(guid string) (string, error) { return contents[guid], nil },
+		getAllNotebooks: func() ([]*Notebook, error) {
+			return []*Notebook{{GUID: "work", Name: "Work"}, {GUID: "home", Name: "Home"}}, nil
+		},
+		getAllTags: func() ([]*Tag, error) { return []*Tag{{GUID: "t1", Name: "todo"}}, nil },
+	}
+	notes := []*Note{
+		{GUID: "1", Title: "Plan", Notebook: &Notebook{GUID: "work"}, Updated: recent, TagGUIDs: []string{"t1"}},
+		{GUID: "2", Title: "Shopping", Notebook: &Notebook{GUID: "home"}, Updated: old, Tags: []string{"todo", "home"}},
+		{GUID: "3", Title: "Old", Notebook: &Notebook{GUID: "work"}, Updated: older},
+	}
+	db := &mockStore{
+		getNotebookCache:  func() (*NotebookCacheList, error) { return new(NotebookCacheList), nil },
+		storeNotebookList: func(*NotebookCacheList) error { return nil },
+	}
+	counted := 0
+
+	stats, err := NoteStatistics(db, ns, notes, &StatsOptions{StaleDays: 365, Top: 2}, func(*Note) { counted++ })
+
+	assert.NoError(err)
+	assert.Equal(3, counted)
+	assert.Equal(3, stats.Notes)
+	assert.Equal(8, stats.Words)
+	assert.Equal(30, stats.Characters)
+	assert.Equal([]*GroupStat{{Name: "Work", Notes: 2, Words: 6}, {Name: "Home", Notes: 1, Words: 2}}, stats.Notebooks)
+	assert.Equal([]*GroupStat{{Name: "todo", Notes: 2, Words: 7}, {Name: "home", Notes: 1, Words: 2}}, stats.Tags)
+	if assert.Len(stats.Largest, 2) {
+		assert.Equal("Plan", stats.Largest[0].Note.Title)
+		assert.Equal("Work", stats.Largest[0].Notebook)
+		assert.Equal("Shopping", stats.Largest[1].Note.Title)
+	}
+	assert.Equal(2, stats.StaleNotes)
+	if assert.Len(stats.Stale, 2) {
+		assert.Equal("Old", stats.Stale[0].Note.Title, "Oldest note should be first")
+		assert.Equal("Shopping", stats.Stale[1].Note.Title)
+	}
+}
+
+func TestWriteNoteStats(t *testing.T) {
+	assert := assert.New(t)
+	note := &Note{Title: "Plan", Updated: time.Date(2018, 5, 1, 12, 0, 0, 0, time.Local).Unix() * 1000}
+	stats := &NoteStats{
+		Notes:      1,
+		Words:      5,
+		Characters: 20,
+		StaleDays:  365,
+		Notebooks:  []*GroupStat{{Name: "Work", Notes: 1, Words: 5}},
+		Largest:    []*NoteStat{{Note: note, Notebook: "Work", Words: 5}},
+	}
+	buf := new(bytes.Buffer)
+
+	WriteNoteStats(buf, stats, DefaultTableOption)
+
+	assert.Contains(buf.String(), "Words:      5\n")
+	assert.Contains(buf.String(), "Stale:      0 notes not updated in 365 days\n")
+	assert.Contains(buf.String(), "| Work     |     1 |     5 |")
+	assert.Contains(buf.String(), "| Plan  | Work     |     5 | 2018-05-01 |")
+	assert.NotContains(buf.String(), "TAG")
+	assert.NotContains(buf.String(), "Least recently updated")
+}
@@ -0,0 +1,126 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultStaleWarning is the number of days since the last sync or backup
+// after which the user is warned about it.
+const DefaultStaleWarning = 7
+
+// Status is an overview of the account and the local state.
+type Status struct {
+	// Credential is the active credential. Nil if not logged in.
+	Credential *Credential
+	// Expires is when the credential expires. Zero if not known.
+	Expires time.Time
+	// LastSync is the time of the last successful sync. Zero if the
+	// notes have never been synced.
+	LastSync time.Time
+	// LastBackup is when the last vault was created. Zero if no vault
+	// has been created.
+	LastBackup time.Time
+	// Pending is the number of changes in the offline queue.
+	Pending int
+	// Failed is the number of queued changes in an error state.
+	Failed int
+}
+
+// GetStatus returns the status from the local storage. The server isn't
+// contacted.
+func GetStatus(store UserCredentialStore, db Storager) (*Status, error) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	state, err := db.GetSyncState()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return nil, err
+	}
+	s := &Status{LastSync: state.Time, LastBackup: settings.LastBackup, Pending: len(changes)}
+	for _, c := range changes {
+		if c.Error != "" {
+			s.Failed++
+		}
+	}
+	if s.Credential, err = SessionCredential(store, db); err != nil {
+		return nil, err
+	}
+	if s.Credential != nil {
+		s.Expires = s.Credential.ExpiresAt()
+	}
+	return s, nil
+}
+
+// RecordBackup saves the time of the backup so the user can be warned when
+// the last backup is too old.
+func RecordBackup(db Storager, t time.Time) error {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return err
+	}
+	settings.LastBackup = t
+	return db.StoreSettings(settings)
+}
+
+// StaleWarnings returns warnings if the last sync or backup is older than
+// the days set in the settings. No warning is given for a sync or backup
+// that has never been done.
+func StaleWarnings(db Storager, now time.Time) ([]string, error) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	days := settings.StaleWarning
+	if days == 0 {
+		days = DefaultStaleWarning
+	}
+	if days < 0 {
+		return nil, nil
+	}
+	state, err := db.GetSyncState()
+	if err != nil {
+		return nil, err
+	}
+	var warnings []string
+	if msg := staleWarning("sync", state.Time, now, days, "run \"clinote sync\""); msg != "" {
+		warnings = append(warnings, msg)
+	}
+	if msg := staleWarning("backup", settings.LastBackup, now, days, "make one with \"clinote backup run\""); msg != "" {
+		warnings = append(warnings, msg)
+	}
+	return warnings, nil
+}
+
+func staleWarning(what string, last, now time.Time, days int, hint string) string {
+	if last.IsZero() {
+		return ""
+	}
+	age := now.Sub(last)
+	if age <= time.Duration(days)*24*time.Hour {
+		return ""
+	}
+	return fmt.Sprintf("The last %s was %s ago, %s.", what, FormatTimeLeft(age), hint)
+}
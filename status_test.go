@@ -0,0 +1,90 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStatus(t *testing.T) {
+	assert := assert.New(t)
+	synced := time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	settings := &Settings{LastBackup: synced.Add(-time.Hour)}
+	db := &mockStore{
+		getSettings:    func() (*Settings, error) { return settings, nil },
+		storeSettings:  func(s *Settings) error { settings = s; return nil },
+		getSyncState:   func() (*SyncState, error) { return &SyncState{Time: synced}, nil },
+		pendingChanges: []*PendingChange{{ID: "1"}, {ID: "2", Error: "failed"}},
+	}
+	var creds []*Credential
+	store := &mockCredentialStore{getAll: func() ([]*Credential, error) { return creds, nil }}
+
+	s, err := GetStatus(store, db)
+	assert.NoError(err)
+	assert.Equal(&Status{LastSync: synced, LastBackup: synced.Add(-time.Hour), Pending: 2, Failed: 1}, s)
+
+	settings.APIKey = "S=s1:U=1:E=162b6e5a000:C=1"
+	s, err = GetStatus(store, db)
+	assert.NoError(err)
+	if assert.NotNil(s.Credential) {
+		assert.Equal("OAuth", s.Credential.Name)
+	}
+	assert.False(s.Expires.IsZero())
+
+	creds = []*Credential{{Name: "Work", Secret: "work"}, {Name: "Home", Secret: "home", Active: true}}
+	s, err = GetStatus(store, db)
+	assert.NoError(err)
+	assert.Equal(creds[1], s.Credential, "Should use the active credential")
+
+	now := synced.Add(time.Minute)
+	assert.NoError(RecordBackup(db, now))
+	assert.Equal(now, settings.LastBackup)
+}
+
+func TestStaleWarnings(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2018, 3, 20, 12, 0, 0, 0, time.UTC)
+	settings := new(Settings)
+	state := new(SyncState)
+	db := &mockStore{
+		getSettings:  func() (*Settings, error) { return settings, nil },
+		getSyncState: func() (*SyncState, error) { return state, nil },
+	}
+
+	warnings, err := StaleWarnings(db, now)
+	assert.NoError(err)
+	assert.Empty(warnings, "Never synced or backed up shouldn't warn")
+
+	state.Time = now.Add(-10 * 24 * time.Hour)
+	settings.LastBackup = now.Add(-3 * 24 * time.Hour)
+	warnings, err = StaleWarnings(db, now)
+	assert.NoError(err)
+	assert.Equal([]string{`The last sync was 10 days ago, run "clinote sync".`}, warnings)
+
+	settings.StaleWarning = 2
+	warnings, _ = StaleWarnings(db, now)
+	assert.Len(warnings, 2)
+	assert.Contains(warnings[1], "The last backup was 3 days ago")
+
+	settings.StaleWarning = -1
+	warnings, _ = StaleWarnings(db, now)
+	assert.Empty(warnings)
+}
@@ -0,0 +1,161 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TcM1911/clinote"
+)
+
+const (
+	// BoltBackend stores the data in a BoltDB database. This is the default backend.
+	BoltBackend = "bolt"
+	// SQLiteBackend stores the data in a SQLite database.
+	SQLiteBackend = "sqlite"
+
+	backendFilename = "backend"
+)
+
+// ErrUnknownBackend is returned if the storage backend is not known.
+var ErrUnknownBackend = errors.New("unknown storage backend")
+
+// OpenBackend opens the storage backend selected for the config folder.
+func OpenBackend(cfgFolder string) (clinote.Storage, error) {
+	name, err := GetBackend(cfgFolder)
+	if err != nil {
+		return nil, err
+	}
+	return openBackend(cfgFolder, name)
+}
+
+func openBackend(cfgFolder, name string) (clinote.Storage, error) {
+	switch name {
+	case BoltBackend:
+		return Open(cfgFolder)
+	case SQLiteBackend:
+		return OpenSQLite(cfgFolder)
+	default:
+		return nil, ErrUnknownBackend
+	}
+}
+
+// GetBackend returns the name of the storage backend selected for the
+// config folder.
+func GetBackend(cfgFolder string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(cfgFolder, backendFilename))
+	if os.IsNotExist(err) {
+		return BoltBackend, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetBackend selects the storage backend used for the config folder. The
+// settings and the user's credentials are copied to the new backend.
+func SetBackend(cfgFolder, name string) error {
+	current, err := GetBackend(cfgFolder)
+	if err != nil {
+		return err
+	}
+	if current == name {
+		return nil
+	}
+	dst, err := openBackend(cfgFolder, name)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	src, err := openBackend(cfgFolder, current)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err = copyStorage(dst, src); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(cfgFolder, backendFilename), []byte(name+"\n"), 0600)
+}
+
+// copyStorage copies the settings, the credentials and the notebook keys
+// from src to dst. Cached data is not copied since it will be fetched
+// again when needed.
+func copyStorage(dst, src clinote.Storage) error {
+	settings, err := src.GetSettings()
+	if err != nil {
+		return err
+	}
+	if err = dst.StoreSettings(settings); err != nil {
+		return err
+	}
+	creds, err := src.GetAll()
+	if err != nil {
+		return err
+	}
+	existing, err := dst.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, cred := range creds {
+		if containsCredential(existing, cred) {
+			continue
+		}
+		if err = dst.Add(cred); err != nil {
+			return err
+		}
+	}
+	return copyNotebookKeys(dst, src)
+}
+
+// copyNotebookKeys copies the keys of the encrypted notebooks, so the
+// notes in them can be read and are encrypted when saved.
+func copyNotebookKeys(dst, src clinote.Storage) error {
+	srcKeys, ok := src.(clinote.NotebookKeyStore)
+	if !ok {
+		return nil
+	}
+	dstKeys, ok := dst.(clinote.NotebookKeyStore)
+	if !ok {
+		return clinote.ErrKeyStoreNotSupported
+	}
+	keys, err := srcKeys.GetNotebookKeys()
+	if err != nil {
+		return err
+	}
+	for guid, key := range keys {
+		if err = dstKeys.SaveNotebookKey(guid, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsCredential(list []*clinote.Credential, cred *clinote.Credential) bool {
+	for _, c := range list {
+		if c.SameIdentity(cred) {
+			return true
+		}
+	}
+	return false
+}
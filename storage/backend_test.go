@@ -0,0 +1,88 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TcM1911/clinote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackendSelection(t *testing.T) {
+	assert := assert.New(t)
+	tmpDir, err := ioutil.TempDir("", "clinote-test")
+	if err != nil {
+		t.Fatalf("Problem with creating temp folder: %s\n", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	t.Run("Default to bolt", func(t *testing.T) {
+		name, err := GetBackend(tmpDir)
+		assert.NoError(err)
+		assert.Equal(BoltBackend, name)
+		db, err := OpenBackend(tmpDir)
+		assert.NoError(err)
+		assert.IsType(&Database{}, db)
+		db.Close()
+	})
+
+	t.Run("Unknown backend", func(t *testing.T) {
+		err := SetBackend(tmpDir, "unknown")
+		assert.Equal(ErrUnknownBackend, err)
+		name, _ := GetBackend(tmpDir)
+		assert.Equal(BoltBackend, name, "Backend should not be changed")
+	})
+
+	t.Run("Read selected backend", func(t *testing.T) {
+		err := ioutil.WriteFile(filepath.Join(tmpDir, backendFilename), []byte("sqlite\n"), 0600)
+		assert.NoError(err)
+		name, err := GetBackend(tmpDir)
+		assert.NoError(err)
+		assert.Equal(SQLiteBackend, name)
+	})
+}
+
+func TestCopyStorage(t *testing.T) {
+	assert := assert.New(t)
+	src, srcDir := setupTestDB(t)
+	defer os.RemoveAll(srcDir)
+	defer src.Close()
+	dst, dstDir := setupTestDB(t)
+	defer os.RemoveAll(dstDir)
+	defer dst.Close()
+
+	cred := &clinote.Credential{Name: "Cred", Secret: "Secret", CredType: clinote.EvernoteCredential}
+	settings := &clinote.Settings{APIKey: "Secret", Credential: cred}
+	assert.NoError(src.StoreSettings(settings))
+	assert.NoError(src.Add(cred))
+	// The credential already exists in the destination and should not be duplicated.
+	assert.NoError(dst.Add(cred))
+
+	err := copyStorage(dst, src)
+	assert.NoError(err)
+	actual, err := dst.GetSettings()
+	assert.NoError(err)
+	assert.Equal(settings, actual)
+	creds, err := dst.GetAll()
+	assert.NoError(err)
+	assert.Len(creds, 1)
+}
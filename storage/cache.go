@@ -0,0 +1,224 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/TcM1911/clinote"
+)
+
+// cacheIndex tracks the size and last use of each cache entry.
+type cacheIndex struct {
+	// Clock is incremented each time an entry is used.
+	Clock uint64
+	// Entries maps cache name to the entries in the cache.
+	Entries map[string]map[string]*cacheIndexEntry
+	// Evictions is the number of evicted entries per cache.
+	Evictions map[string]int64
+}
+
+type cacheIndexEntry struct {
+	Size     int64
+	LastUsed uint64
+}
+
+func cacheEntryBucket(cache string) []byte {
+	return []byte("cache_" + cache)
+}
+
+func (s *store) getCacheIndex() (*cacheIndex, error) {
+	index := &cacheIndex{}
+	data, err := s.kv.getData(cacheIdxBucket, cacheIndexKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, index)
+	}
+	if index.Entries == nil {
+		index.Entries = make(map[string]map[string]*cacheIndexEntry)
+	}
+	if index.Evictions == nil {
+		index.Evictions = make(map[string]int64)
+	}
+	return index, err
+}
+
+func (s *store) saveCacheIndex(index *cacheIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(cacheIdxBucket, cacheIndexKey, data)
+}
+
+type cacheUse struct {
+	cache, key string
+}
+
+// GetCacheEntry returns the entry for the key in the cache. The use is
+// recorded in memory and saved to the cache index with the next stored
+// entry.
+func (s *store) GetCacheEntry(cache, key string) ([]byte, error) {
+	data, err := s.kv.getData(cacheEntryBucket(cache), []byte(key))
+	if err != nil || data == nil {
+		return data, err
+	}
+	s.cacheMu.Lock()
+	s.cacheUses = append(s.cacheUses, cacheUse{cache: cache, key: key})
+	s.cacheMu.Unlock()
+	return data, nil
+}
+
+// PutCacheEntry saves the entry in the cache and evicts the least recently
+// used entries if the caches are larger than the size limit.
+func (s *store) PutCacheEntry(cache, key string, data []byte) error {
+	if err := s.kv.storeData(cacheEntryBucket(cache), []byte(key), data); err != nil {
+		return err
+	}
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	index, err := s.getCacheIndex()
+	if err != nil {
+		return err
+	}
+	if err := s.applyCacheUses(index); err != nil {
+		return err
+	}
+	if index.Entries[cache] == nil {
+		index.Entries[cache] = make(map[string]*cacheIndexEntry)
+	}
+	index.Clock++
+	index.Entries[cache][key] = &cacheIndexEntry{Size: int64(len(data)), LastUsed: index.Clock}
+	settings, err := s.GetSettings()
+	if err != nil {
+		return err
+	}
+	if err := s.evict(index, settings.CacheMaxSize); err != nil {
+		return err
+	}
+	if err := s.saveCacheIndex(index); err != nil {
+		return err
+	}
+	s.cacheUses = nil
+	return nil
+}
+
+// flushCacheUses saves the uses of the entries read since the index was
+// saved. It's called when the storage is closed so the uses aren't lost if
+// no entry is stored before that.
+func (s *store) flushCacheUses() error {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	if len(s.cacheUses) == 0 {
+		return nil
+	}
+	index, err := s.getCacheIndex()
+	if err != nil {
+		return err
+	}
+	if err = s.applyCacheUses(index); err != nil {
+		return err
+	}
+	if err = s.saveCacheIndex(index); err != nil {
+		return err
+	}
+	s.cacheUses = nil
+	return nil
+}
+
+// applyCacheUses updates the last use of the entries read since the index
+// was saved.
+func (s *store) applyCacheUses(index *cacheIndex) error {
+	for _, u := range s.cacheUses {
+		entry, ok := index.Entries[u.cache][u.key]
+		if !ok {
+			// The entry was saved by an older version, or evicted since it
+			// was read.
+			data, err := s.kv.getData(cacheEntryBucket(u.cache), []byte(u.key))
+			if err != nil {
+				return err
+			}
+			if data == nil {
+				continue
+			}
+			entry = &cacheIndexEntry{Size: int64(len(data))}
+			if index.Entries[u.cache] == nil {
+				index.Entries[u.cache] = make(map[string]*cacheIndexEntry)
+			}
+			index.Entries[u.cache][u.key] = entry
+		}
+		index.Clock++
+		entry.LastUsed = index.Clock
+	}
+	return nil
+}
+
+// evict removes the least recently used entries until the caches are
+// smaller than maxSize.
+func (s *store) evict(index *cacheIndex, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	var size int64
+	for _, entries := range index.Entries {
+		for _, e := range entries {
+			size += e.Size
+		}
+	}
+	for size > maxSize {
+		cache, key := "", ""
+		var oldest *cacheIndexEntry
+		for c, entries := range index.Entries {
+			for k, e := range entries {
+				if oldest == nil || e.LastUsed < oldest.LastUsed {
+					cache, key, oldest = c, k, e
+				}
+			}
+		}
+		if oldest == nil {
+			return nil
+		}
+		if err := s.kv.deleteData(cacheEntryBucket(cache), []byte(key)); err != nil {
+			return err
+		}
+		delete(index.Entries[cache], key)
+		index.Evictions[cache]++
+		size -= oldest.Size
+	}
+	return nil
+}
+
+// GetCacheStats returns the statistics for the caches.
+func (s *store) GetCacheStats() (*clinote.CacheStats, error) {
+	index, err := s.getCacheIndex()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	stats := &clinote.CacheStats{MaxSize: settings.CacheMaxSize, Caches: make(map[string]*clinote.CacheBucketStats)}
+	for _, cache := range clinote.Caches {
+		b := &clinote.CacheBucketStats{Entries: len(index.Entries[cache]), Evictions: index.Evictions[cache]}
+		for _, e := range index.Entries[cache] {
+			b.Size += e.Size
+		}
+		stats.Caches[cache] = b
+	}
+	return stats, nil
+}
@@ -0,0 +1,95 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/TcM1911/clinote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheEviction(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+	assert.NoError(db.StoreSettings(&clinote.Settings{CacheMaxSize: 10}))
+
+	t.Run("Get non existing", func(t *testing.T) {
+		data, err := db.GetCacheEntry(clinote.ContentCache, "missing")
+		assert.NoError(err)
+		assert.Nil(data)
+	})
+
+	t.Run("Evict least recently used", func(t *testing.T) {
+		assert.NoError(db.PutCacheEntry(clinote.ContentCache, "a", []byte("aaaa")))
+		assert.NoError(db.PutCacheEntry(clinote.ResourceCache, "b", []byte("bbbb")))
+		// Use "a" so "b" is the least recently used entry.
+		_, err := db.GetCacheEntry(clinote.ContentCache, "a")
+		assert.NoError(err)
+		assert.NoError(db.PutCacheEntry(clinote.ContentCache, "c", []byte("cccc")))
+
+		data, err := db.GetCacheEntry(clinote.ResourceCache, "b")
+		assert.NoError(err)
+		assert.Nil(data, "Least recently used entry should be evicted")
+		data, err = db.GetCacheEntry(clinote.ContentCache, "a")
+		assert.NoError(err)
+		assert.Equal([]byte("aaaa"), data)
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		stats, err := db.GetCacheStats()
+		assert.NoError(err)
+		assert.Equal(int64(10), stats.MaxSize)
+		assert.Equal(int64(8), stats.Size())
+		assert.Equal(&clinote.CacheBucketStats{Entries: 2, Size: 8}, stats.Caches[clinote.ContentCache])
+		assert.Equal(&clinote.CacheBucketStats{Evictions: 1}, stats.Caches[clinote.ResourceCache])
+	})
+}
+
+func TestCacheReadDoesNotSaveIndex(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+	assert.NoError(db.PutCacheEntry(clinote.ContentCache, "a", []byte("aaaa")))
+	saved, err := db.kv.getData(cacheIdxBucket, cacheIndexKey)
+	assert.NoError(err)
+
+	_, err = db.GetCacheEntry(clinote.ContentCache, "a")
+	assert.NoError(err)
+	index, err := db.kv.getData(cacheIdxBucket, cacheIndexKey)
+	assert.NoError(err)
+	assert.Equal(saved, index, "Reading an entry should not save the index")
+
+	assert.NoError(db.PutCacheEntry(clinote.ResourceCache, "b", []byte("bbbb")))
+	idx, err := db.getCacheIndex()
+	assert.NoError(err)
+	assert.Equal(uint64(2), idx.Entries[clinote.ContentCache]["a"].LastUsed, "Use should be saved with the next entry")
+	assert.Equal(uint64(3), idx.Entries[clinote.ResourceCache]["b"].LastUsed)
+
+	// The uses not saved with an entry are saved when the storage is closed.
+	_, err = db.GetCacheEntry(clinote.ContentCache, "a")
+	assert.NoError(err)
+	assert.NoError(db.Close())
+	idx, err = db.getCacheIndex()
+	assert.NoError(err)
+	assert.Equal(uint64(4), idx.Entries[clinote.ContentCache]["a"].LastUsed, "Use should be saved on close")
+}
@@ -19,13 +19,11 @@ package storage
 
 import (
 	"encoding/binary"
-	"encoding/json"
 	"errors"
 	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/TcM1911/clinote"
 	"github.com/boltdb/bolt"
 )
 
@@ -40,20 +38,47 @@ var softwareDBVersion = uint64(1)
 // This is what the current wait time before the database is closed.
 var currentWaitTime = 5 * time.Second
 
+// DBLinger is how long the database is held open after it was last used.
+// It overrides the DBLinger setting. If zero, the setting is used.
+var DBLinger time.Duration
+
+// LockTimeout is how long to wait for another process to close the database
+// before ErrDatabaseInUse is returned. The lock is retried until then. If
+// zero, the wait has no limit.
+var LockTimeout = 30 * time.Second
+
 // List of buckets
 var (
-	dbBucket       = []byte("db_data")
-	settingsBucket = []byte("settings")
-	cacheBucket    = []byte("cache")
+	dbBucket         = []byte("db_data")
+	settingsBucket   = []byte("settings")
+	cacheBucket      = []byte("cache")
+	redactedBucket   = []byte("redacted")
+	syncBucket       = []byte("sync")
+	cacheIdxBucket   = []byte("cache_index")
+	journalBucket    = []byte("journal")
+	transcriptBucket = []byte("transcripts")
+	outboxBucket     = []byte("outbox")
+	undoBucket       = []byte("undo")
+	checkpointBucket = []byte("checkpoints")
 )
 
 // List of keys
 var (
 	settingsKey         = []byte("user_settings")
 	credentialsKey      = []byte("user_credentials")
+	notebookKeysKey     = []byte("notebook_keys")
 	notebookCacheKey    = []byte("notebook_cache")
 	searchCacheKey      = []byte("note_search_cache")
+	searchQueryKey      = []byte("note_search_query")
 	noteRecoverCacheKey = []byte("note_recover_cache")
+	syncStateKey        = []byte("sync_state")
+	pendingChangesKey   = []byte("pending_changes")
+	backlinksKey        = []byte("backlinks")
+	cacheIndexKey       = []byte("index")
+	intentsKey          = []byte("intents")
+	editsKey            = []byte("edits")
+	capturesKey         = []byte("captures")
+	undoLogKey          = []byte("log")
 	dbVersionKey        = []byte("dbVersion")
 )
 
@@ -68,51 +93,81 @@ var (
 // Open returns an instance of the database.
 func Open(cfgFolder string) (*Database, error) {
 	filename := filepath.Join(cfgFolder, dbFilename)
-	b, err := bolt.Open(filename, 0600, nil)
+	b, err := openBolt(filename)
 	if err != nil {
 		return nil, err
 	}
 	d := &Database{
 		bolt:       b,
 		dbFilename: filename,
-		resetChan:  make(chan struct{}, 1),
-		// TODO: This property should be configurable.
-		waitTime: currentWaitTime,
+		waitTime:   currentWaitTime,
+		lastUsed:   time.Now(),
 	}
-	go dbWaitingLoop(d)
+	d.store = newStore(d, cfgFolder)
 
 	// Check if migration is needed.
 	currVersion, err := d.getDBVersion()
+	if err == nil && currVersion < softwareDBVersion {
+		err = migrate(d, currVersion)
+		if err == nil {
+			err = d.saveDBVersion(softwareDBVersion)
+		}
+	}
 	if err != nil {
+		d.closeDB()
 		return nil, err
 	}
-	if currVersion < softwareDBVersion {
-		err = migrate(d, currVersion)
-		if err != nil {
-			return nil, err
-		}
-		err = d.saveDBVersion(softwareDBVersion)
+	d.waitTime = lingerTime(d)
+	go dbWaitingLoop(d)
+	return d, nil
+}
+
+// lingerTime returns how long the database should be held open after it was
+// last used.
+func lingerTime(d *Database) time.Duration {
+	if DBLinger > 0 {
+		return DBLinger
 	}
-	return d, err
+	if s, err := d.GetSettings(); err == nil && s.DBLinger > 0 {
+		return s.DBLinger
+	}
+	return currentWaitTime
 }
 
+// dbWaitingLoop closes the database when it hasn't been used for the wait
+// time. The loop ends when the database is closed.
 func dbWaitingLoop(d *Database) {
-	defer d.closeDB()
-	for {
-		for {
-			select {
-			case <-d.resetChan:
-				break
-
-			case <-time.After(d.waitTime):
-				return
-			}
+	timer := time.NewTimer(d.waitTime)
+	defer timer.Stop()
+	for range timer.C {
+		idle, closed := d.closeIdle()
+		if closed {
+			return
 		}
+		timer.Reset(d.waitTime - idle)
+	}
+}
+
+// closeIdle closes the database if it has been idle for the wait time. It
+// returns how long the database has been idle and true if it's closed.
+func (d *Database) closeIdle() (time.Duration, bool) {
+	d.handlerMu.Lock()
+	defer d.handlerMu.Unlock()
+	if d.bolt == nil {
+		return 0, true
+	}
+	idle := time.Since(d.lastUsed)
+	if idle < d.waitTime {
+		return idle, false
 	}
+	d.bolt.Close()
+	d.bolt = nil
+	return idle, true
 }
 
 // Database is a representation of the backend storage.
 type Database struct {
+	*store
 	// bolt is the database handler. This should not be accessed directly.
 	// Instead getDBHandler() should be used to get a handler. Accessing this directly
 	// is not thread safe.
@@ -122,10 +177,11 @@ type Database struct {
 
 	// dbFilename is the absolute path of the database file.
 	dbFilename string
-	// resetChan is used to reset the timer before the database is closed.
-	resetChan chan struct{}
 	// waitTime is how long the database should be held open.
 	waitTime time.Duration
+	// lastUsed is when the handler was last released. It's guarded by
+	// handlerMu.
+	lastUsed time.Time
 }
 
 // open is used internally to reopen the database file. This method is not thread safe and
@@ -134,9 +190,19 @@ func (d *Database) open() (*bolt.DB, error) {
 	if d.bolt != nil {
 		return d.bolt, nil
 	}
-	// Start closing wait loop
-	go dbWaitingLoop(d)
-	return bolt.Open(d.dbFilename, 0600, nil)
+	return openBolt(d.dbFilename)
+}
+
+// openBolt opens the database file. BoltDB holds a lock on the file while it's
+// open so only one process can use it at a time. Since the database is
+// closed when it hasn't been used for a while, the lock is retried until
+// LockTimeout has passed.
+func openBolt(filename string) (*bolt.DB, error) {
+	b, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: LockTimeout})
+	if err == bolt.ErrTimeout {
+		return nil, ErrDatabaseInUse
+	}
+	return b, err
 }
 
 func (d *Database) closeDB() error {
@@ -157,12 +223,6 @@ func (d *Database) closeDB() error {
 func (d *Database) getDBHandler() (*bolt.DB, error) {
 	d.handlerMu.Lock()
 	if d.bolt != nil {
-		// Wrap the call in a go func to prevent deadlock.
-		// TODO: Wait-loop should be rewritten so this is not needed.
-		go func() {
-			// Reset timer
-			d.resetChan <- struct{}{}
-		}()
 		return d.bolt, nil
 	}
 	b, err := d.open()
@@ -170,12 +230,16 @@ func (d *Database) getDBHandler() (*bolt.DB, error) {
 		return nil, err
 	}
 	d.bolt = b
+	// Start closing wait loop
+	go dbWaitingLoop(d)
 
 	return d.bolt, nil
 }
 
 // releaseDBHandler unlocks the mutex to get other go routines access to the database handler.
+// The wait before the database is closed starts over.
 func (d *Database) releaseDBHandler() {
+	d.lastUsed = time.Now()
 	d.handlerMu.Unlock()
 }
 
@@ -243,149 +307,26 @@ func (d *Database) storeData(bucket, key, data []byte) error {
 	})
 }
 
-// GetSettings returns the settings from the database.
-func (d *Database) GetSettings() (*clinote.Settings, error) {
-	var settings clinote.Settings
-	data, err := d.getData(settingsBucket, settingsKey)
-	if err == nil && data != nil {
-		err = json.Unmarshal(data, &settings)
-	}
-	return &settings, err
-}
-
-// StoreSettings saves the settings to the database.
-func (d *Database) StoreSettings(settings *clinote.Settings) error {
-	data, err := json.Marshal(settings)
-	if err != nil {
-		return err
-	}
-	return d.storeData(settingsBucket, settingsKey, data)
-}
-
-// GetNotebookCache returns the stored NotebookCacheList.
-func (d *Database) GetNotebookCache() (*clinote.NotebookCacheList, error) {
-	var list clinote.NotebookCacheList
-	data, err := d.getData(cacheBucket, notebookCacheKey)
-	if err == nil && data != nil {
-		err = json.Unmarshal(data, &list)
-	}
-	return &list, err
-}
-
-// StoreNotebookList saves the list to the database.
-func (d *Database) StoreNotebookList(list *clinote.NotebookCacheList) error {
-	data, err := json.Marshal(list)
-	if err != nil {
-		return err
-	}
-	return d.storeData(cacheBucket, notebookCacheKey, data)
-}
-
-// SaveSearch stores the search to the database.
-func (d *Database) SaveSearch(notes []*clinote.Note) error {
-	data, err := json.Marshal(notes)
-	if err != nil {
-		return err
-	}
-	return d.storeData(cacheBucket, searchCacheKey, data)
-}
-
-// GetSearch gets the saved search from the database.
-func (d *Database) GetSearch() ([]*clinote.Note, error) {
-	var notes []*clinote.Note
-	data, err := d.getData(cacheBucket, searchCacheKey)
-	if err == nil && data != nil {
-		err = json.Unmarshal(data, &notes)
-	}
-	return notes, err
-}
-
-// SaveNoteRecoveryPoint saves the note to the database so it can be
-// recovered in the case something fails.
-func (d *Database) SaveNoteRecoveryPoint(note *clinote.Note) error {
-	data, err := json.Marshal(note)
+func (d *Database) deleteData(bucket, key []byte) error {
+	db, err := d.getDBHandler()
+	defer d.releaseDBHandler()
 	if err != nil {
 		return err
 	}
-	return d.storeData(cacheBucket, noteRecoverCacheKey, data)
-}
-
-// GetNoteRecoveryPoint returns the saved note that failed to save.
-func (d *Database) GetNoteRecoveryPoint() (*clinote.Note, error) {
-	var note clinote.Note
-	data, err := d.getData(cacheBucket, noteRecoverCacheKey)
-	if err == nil && data != nil {
-		err = json.Unmarshal(data, &note)
-	}
-	return &note, err
+	return db.Update(func(t *bolt.Tx) error {
+		b := t.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		return b.Delete(key)
+	})
 }
 
 // Close shuts down the connection to the database.
 func (d *Database) Close() error {
-	return d.closeDB()
-}
-
-// Add adds a new credential to the database.
-func (d *Database) Add(c *clinote.Credential) error {
-	creds, err := d.GetAll()
-	if err != nil {
-		return err
-	}
-	creds = append(creds, c)
-	data, err := json.Marshal(creds)
-	if err != nil {
-		return err
-	}
-	return d.storeData(settingsBucket, credentialsKey, data)
-}
-
-// Remove removes the credential from the database.
-func (d *Database) Remove(c *clinote.Credential) error {
-	credList, err := d.GetAll()
-	if err != nil {
-		return err
+	err := d.flushCacheUses()
+	if closeErr := d.closeDB(); err == nil {
+		err = closeErr
 	}
-	index := -1
-	for i := 0; i < len(credList); i++ {
-		if *credList[i] == *c {
-			index = i
-			break
-		}
-	}
-	if index == -1 {
-		return clinote.ErrNoMatchingCredentialFound
-	}
-	// Remove the entry
-	copy(credList[index:], credList[index+1:])
-	credList[len(credList)-1] = nil
-	credList = credList[:len(credList)-1]
-
-	data, err := json.Marshal(credList)
-	if err != nil {
-		return err
-	}
-	// Save the list
-	return d.storeData(settingsBucket, credentialsKey, data)
-}
-
-// GetAll returns all the credentials in the database.
-func (d *Database) GetAll() ([]*clinote.Credential, error) {
-	var creds []*clinote.Credential
-	data, err := d.getData(settingsBucket, credentialsKey)
-	if err == nil && data != nil {
-		err = json.Unmarshal(data, &creds)
-	}
-	return creds, err
-}
-
-// GetByIndex returns a credential by its index.
-func (d *Database) GetByIndex(index int) (*clinote.Credential, error) {
-	creds, err := d.GetAll()
-	if err != nil {
-		return nil, err
-	}
-	if index < 0 || index >= len(creds) {
-		return nil, ErrIndexOutOfRange
-	}
-	return creds[index], nil
+	return err
 }
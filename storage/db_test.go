@@ -20,9 +20,12 @@ package storage
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/TcM1911/clinote"
+	"github.com/boltdb/bolt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,6 +59,35 @@ func TestSettings(t *testing.T) {
 	os.RemoveAll(tmpDir)
 }
 
+func TestSettingsWithConfigFile(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+	stored := &clinote.Settings{APIKey: "key", DefaultNotebook: "Stored", Aliases: map[string]string{"ls": "note list"}}
+	assert.NoError(db.StoreSettings(stored))
+	cfg := "notebook.default = \"File\"\n[alias.cmd]\nll = \"notebook list\"\n"
+	assert.NoError(ioutil.WriteFile(filepath.Join(tmpDir, clinote.ConfigFileName), []byte(cfg), 0600))
+
+	settings, err := db.GetSettings()
+	assert.NoError(err)
+	assert.Equal("File", settings.DefaultNotebook, "Config file should take precedence")
+	assert.Equal(map[string]string{"ls": "note list", "ll": "notebook list"}, settings.Aliases)
+
+	settings.APIKey = "new key"
+	assert.NoError(db.StoreSettings(settings))
+	raw, err := db.getStoredSettings()
+	assert.NoError(err)
+	assert.Equal("new key", raw.APIKey)
+	assert.Equal("Stored", raw.DefaultNotebook, "Config file values should not be saved")
+	assert.Equal(map[string]string{"ls": "note list"}, raw.Aliases, "Config file aliases should not be saved")
+	assert.Equal("File", settings.DefaultNotebook, "The caller's settings should not change")
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(tmpDir, clinote.ConfigFileName), []byte("unknown = 1"), 0600))
+	_, err = db.GetSettings()
+	assert.Error(err, "Should return an error for an invalid config file")
+}
+
 func TestNotebookCaching(t *testing.T) {
 	assert := assert.New(t)
 	db, tmpDir := setupTestDB(t)
@@ -97,6 +129,42 @@ func TestSearchCaching(t *testing.T) {
 		assert.NoError(err, "Should not return an error")
 		assert.Equal(expected, actual, "Wrong data returned from store")
 	})
+
+	t.Run("Per credential", func(t *testing.T) {
+		other := []*clinote.Note{&clinote.Note{Title: "Other note"}}
+		settings := &clinote.Settings{Credential: &clinote.Credential{Name: "other"}}
+		assert.NoError(db.StoreSettings(settings))
+		actual, err := db.GetSearch()
+		assert.NoError(err)
+		assert.Empty(actual, "Should not return the search of another credential")
+		assert.NoError(db.SaveSearch(other))
+
+		assert.NoError(db.StoreSettings(&clinote.Settings{}))
+		actual, err = db.GetSearch()
+		assert.NoError(err)
+		assert.Equal(expected, actual, "Should keep the search when switching back")
+
+		assert.NoError(db.StoreSettings(settings))
+		actual, err = db.GetSearch()
+		assert.NoError(err)
+		assert.Equal(other, actual)
+	})
+
+	t.Run("Active credential", func(t *testing.T) {
+		// The stale credential in the settings isn't used.
+		assert.NoError(db.Add(&clinote.Credential{Name: "work", Secret: "secret", Active: true}))
+		actual, err := db.GetSearch()
+		assert.NoError(err)
+		assert.Empty(actual, "Should not return the search of another credential")
+		work := []*clinote.Note{&clinote.Note{Title: "Work note"}}
+		assert.NoError(db.SaveSearch(work))
+
+		// A label doesn't change the key.
+		assert.NoError(db.Update(0, &clinote.Credential{Name: "work", Secret: "secret", Label: "Work", Active: true}))
+		actual, err = db.GetSearch()
+		assert.NoError(err)
+		assert.Equal(work, actual)
+	})
 }
 
 func TestRecoveryPoint(t *testing.T) {
@@ -118,6 +186,265 @@ func TestRecoveryPoint(t *testing.T) {
 	})
 }
 
+func TestRedactedNote(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+	expected := []byte("encrypted data")
+
+	t.Run("Get non existing", func(t *testing.T) {
+		data, err := db.GetRedactedNote("GUID")
+		assert.NoError(err, "Should not fail when no copy exists")
+		assert.Nil(data, "No data should be returned")
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		err := db.SaveRedactedNote("GUID", expected)
+		assert.NoError(err, "Should not fail to save")
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		actual, err := db.GetRedactedNote("GUID")
+		assert.NoError(err, "Should not fail to return the copy")
+		assert.Equal(expected, actual, "Wrong data returned")
+	})
+}
+
+func TestNotebookKeys(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	t.Run("Get non existing", func(t *testing.T) {
+		keys, err := db.GetNotebookKeys()
+		assert.NoError(err)
+		assert.Empty(keys)
+	})
+
+	t.Run("Store and get", func(t *testing.T) {
+		assert.NoError(db.SaveNotebookKey("GUID", []byte("key")))
+		keys, err := db.GetNotebookKeys()
+		assert.NoError(err)
+		assert.Equal(map[string][]byte{"GUID": []byte("key")}, keys)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		assert.NoError(db.SaveNotebookKey("GUID", nil))
+		keys, err := db.GetNotebookKeys()
+		assert.NoError(err)
+		assert.Empty(keys)
+	})
+}
+
+func TestIntents(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	intents, err := db.GetIntents()
+	assert.NoError(err)
+	assert.Empty(intents)
+
+	assert.NoError(db.SaveIntents([]*clinote.Intent{{ID: "1", Operation: clinote.OpUpdateNote, NoteGUID: "GUID"}}))
+	intents, err = db.GetIntents()
+	assert.NoError(err)
+	if assert.Len(intents, 1) {
+		assert.Equal("GUID", intents[0].NoteGUID)
+	}
+
+	assert.NoError(db.SaveIntents(nil))
+	intents, err = db.GetIntents()
+	assert.NoError(err)
+	assert.Empty(intents)
+}
+
+func TestEdits(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	edits, err := db.GetEdits()
+	assert.NoError(err)
+	assert.Empty(edits)
+
+	edit := &clinote.JournalEdit{ID: "1", Note: &clinote.Note{GUID: "GUID", Title: "Plan"}, Content: "content", Raw: true}
+	assert.NoError(db.SaveEdits([]*clinote.JournalEdit{edit}))
+	edits, err = db.GetEdits()
+	assert.NoError(err)
+	assert.Equal([]*clinote.JournalEdit{edit}, edits)
+
+	assert.NoError(db.SaveEdits(nil))
+	edits, err = db.GetEdits()
+	assert.NoError(err)
+	assert.Empty(edits)
+}
+
+func TestCaptures(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	captures, err := db.GetCaptures()
+	assert.NoError(err)
+	assert.Empty(captures)
+
+	assert.NoError(db.SaveCaptures([]*clinote.Capture{{ID: "1", Text: "Buy milk"}}))
+	captures, err = db.GetCaptures()
+	assert.NoError(err)
+	if assert.Len(captures, 1) {
+		assert.Equal("Buy milk", captures[0].Text)
+	}
+
+	assert.NoError(db.SaveCaptures(nil))
+	captures, err = db.GetCaptures()
+	assert.NoError(err)
+	assert.Empty(captures)
+}
+
+func TestUndoLog(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	entries, err := db.GetUndoLog()
+	assert.NoError(err)
+	assert.Empty(entries)
+
+	entry := &clinote.UndoEntry{Op: clinote.UndoDelete, Note: &clinote.Note{GUID: "GUID", Title: "Title"}}
+	assert.NoError(db.SaveUndoLog([]*clinote.UndoEntry{entry}))
+	entries, err = db.GetUndoLog()
+	assert.NoError(err)
+	if assert.Len(entries, 1) {
+		assert.Equal(clinote.UndoDelete, entries[0].Op)
+		assert.Equal("Title", entries[0].Note.Title)
+	}
+
+	assert.NoError(db.SaveUndoLog(nil))
+	entries, err = db.GetUndoLog()
+	assert.NoError(err)
+	assert.Empty(entries)
+}
+
+func TestCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	c, err := db.GetCheckpoint("export")
+	assert.NoError(err)
+	assert.Nil(c)
+
+	assert.NoError(db.SaveCheckpoint(&clinote.Checkpoint{Name: "export", Done: []string{"a", "b"}}))
+	c, err = db.GetCheckpoint("export")
+	assert.NoError(err)
+	if assert.NotNil(c) {
+		assert.Equal([]string{"a", "b"}, c.Done)
+	}
+
+	assert.NoError(db.DeleteCheckpoint("export"))
+	c, err = db.GetCheckpoint("export")
+	assert.NoError(err)
+	assert.Nil(c)
+}
+
+func TestTranscriptProgress(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	p, err := db.GetTranscriptProgress("GUID")
+	assert.NoError(err)
+	assert.Nil(p)
+
+	expected := &clinote.TranscriptProgress{
+		NoteGUID:  "GUID",
+		Resources: map[string]*clinote.ResourceTranscript{"hash": {Segments: []*clinote.TranscriptSegment{{End: time.Second, Text: "Text"}}}},
+	}
+	assert.NoError(db.SaveTranscriptProgress(expected))
+	p, err = db.GetTranscriptProgress("GUID")
+	assert.NoError(err)
+	assert.Equal(expected, p)
+
+	assert.NoError(db.DeleteTranscriptProgress("GUID"))
+	p, err = db.GetTranscriptProgress("GUID")
+	assert.NoError(err)
+	assert.Nil(p)
+}
+
+func TestBacklinkIndex(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	index, err := db.GetBacklinkIndex()
+	assert.NoError(err)
+	assert.Empty(index)
+
+	expected := map[string][]*clinote.Backlink{"GUID": {{GUID: "Other", Title: "Other note"}}}
+	assert.NoError(db.SaveBacklinkIndex(expected))
+	index, err = db.GetBacklinkIndex()
+	assert.NoError(err)
+	assert.Equal(expected, index)
+}
+
+func TestSearchQuery(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	query, err := db.GetSearchQuery()
+	assert.NoError(err)
+	assert.Nil(query)
+
+	expected := &clinote.SavedSearch{Filter: clinote.NoteFilter{Words: "tag:work", NotebookGUID: "GUID"}, Count: 20, UpdateCount: 42}
+	assert.NoError(db.SaveSearchQuery(expected))
+	query, err = db.GetSearchQuery()
+	assert.NoError(err)
+	assert.Equal(expected, query)
+}
+
+func TestSyncState(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+
+	t.Run("Get non existing", func(t *testing.T) {
+		state, err := db.GetSyncState()
+		assert.NoError(err, "Should not fail when no state exists")
+		assert.True(state.Time.IsZero(), "Should return an empty state")
+		changes, err := db.GetPendingChanges()
+		assert.NoError(err, "Should not fail when no queue exists")
+		assert.Empty(changes, "Queue should be empty")
+	})
+
+	t.Run("Store and get", func(t *testing.T) {
+		expectedState := &clinote.SyncState{UpdateCount: 42, Time: time.Unix(1500000000, 0).UTC()}
+		expectedChanges := []*clinote.PendingChange{
+			&clinote.PendingChange{ID: "id", Type: clinote.ChangeEdit, Note: &clinote.Note{GUID: "GUID"}, Error: "failed"},
+		}
+		assert.NoError(db.SaveSyncState(expectedState), "Should save the state")
+		assert.NoError(db.SavePendingChanges(expectedChanges), "Should save the queue")
+		state, err := db.GetSyncState()
+		assert.NoError(err, "Should get the state")
+		assert.Equal(expectedState, state, "Wrong state returned")
+		changes, err := db.GetPendingChanges()
+		assert.NoError(err, "Should get the queue")
+		assert.Equal(expectedChanges[0].ID, changes[0].ID, "Wrong change returned")
+		assert.Equal(expectedChanges[0].Error, changes[0].Error, "Wrong error returned")
+	})
+}
+
 func TestCredentialStore(t *testing.T) {
 	assert := assert.New(t)
 	db, tmpDir := setupTestDB(t)
@@ -158,6 +485,17 @@ func TestCredentialStore(t *testing.T) {
 		}
 	})
 
+	t.Run("Update a credential", func(t *testing.T) {
+		updated := *expectedCredentials[0]
+		updated.Endpoint = clinote.Endpoint{Host: clinote.YinxiangHost, ConsumerKey: "key", ConsumerSecret: "secret"}
+		assert.NoError(db.Update(0, &updated))
+		cred, err := db.GetByIndex(0)
+		assert.NoError(err)
+		assert.Equal(updated, *cred)
+		assert.Equal(ErrIndexOutOfRange, db.Update(len(expectedCredentials), &updated))
+		assert.NoError(db.Update(0, expectedCredentials[0]))
+	})
+
 	t.Run("Remove a credential", func(t *testing.T) {
 		err := db.Remove(expectedCredentials[len(expectedCredentials)-1])
 		assert.NoError(err, "Should not fail removing")
@@ -183,6 +521,82 @@ func compareCacheList(assert *assert.Assertions, expected *clinote.NotebookCache
 	assert.True(expected.Timestamp.Equal(actual.Timestamp), "Wrong timestamp")
 }
 
+func TestLockTimeout(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(db.Close())
+	defer func(d time.Duration) { LockTimeout = d }(LockTimeout)
+	LockTimeout = 100 * time.Millisecond
+
+	other, err := bolt.Open(filepath.Join(tmpDir, dbFilename), 0600, nil)
+	assert.NoError(err)
+
+	t.Run("in_use", func(t *testing.T) {
+		_, err := Open(tmpDir)
+		assert.Equal(ErrDatabaseInUse, err)
+	})
+
+	t.Run("released_while_waiting", func(t *testing.T) {
+		LockTimeout = 5 * time.Second
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			other.Close()
+		}()
+		db, err := Open(tmpDir)
+		assert.NoError(err)
+		if db != nil {
+			db.Close()
+		}
+	})
+}
+
+func TestDBLinger(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	assert.Equal(currentWaitTime, db.waitTime, "Should use the default")
+	assert.NoError(db.StoreSettings(&clinote.Settings{DBLinger: time.Minute}))
+	assert.NoError(db.Close())
+
+	t.Run("setting", func(t *testing.T) {
+		db, err := Open(tmpDir)
+		assert.NoError(err)
+		assert.Equal(time.Minute, db.waitTime)
+		db.Close()
+	})
+
+	t.Run("override", func(t *testing.T) {
+		defer func() { DBLinger = 0 }()
+		DBLinger = time.Hour
+		db, err := Open(tmpDir)
+		assert.NoError(err)
+		assert.Equal(time.Hour, db.waitTime)
+		db.Close()
+	})
+
+	t.Run("close_idle", func(t *testing.T) {
+		db, err := Open(tmpDir)
+		assert.NoError(err)
+		defer db.Close()
+		db.handlerMu.Lock()
+		db.lastUsed = time.Now().Add(-2 * time.Minute)
+		db.handlerMu.Unlock()
+		_, err = db.getDBHandler()
+		assert.NoError(err)
+		db.releaseDBHandler()
+		_, closed := db.closeIdle()
+		assert.False(closed, "Releasing the handler should reset the wait")
+
+		db.handlerMu.Lock()
+		db.lastUsed = time.Now().Add(-2 * time.Minute)
+		db.handlerMu.Unlock()
+		idle, closed := db.closeIdle()
+		assert.True(closed)
+		assert.True(idle >= time.Minute)
+	})
+}
+
 func setupTestDB(t *testing.T) (*Database, string) {
 	tmpDir, err := ioutil.TempDir("", "clinote-test")
 	if err != nil {
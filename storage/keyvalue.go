@@ -0,0 +1,111 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"io"
+
+	"github.com/TcM1911/clinote"
+)
+
+// KeyValueStore gives access to the raw data of a storage backend. The
+// data is stored as key-value pairs grouped into buckets.
+type KeyValueStore interface {
+	// GetData returns the value for the key in the bucket. If no value is
+	// found, nil is returned.
+	GetData(bucket, key []byte) ([]byte, error)
+	// StoreData saves the value for the key in the bucket.
+	StoreData(bucket, key, data []byte) error
+	// DeleteData removes the key from the bucket.
+	DeleteData(bucket, key []byte) error
+}
+
+// KeyValue returns the key-value store used by the storage backend. If the
+// storage is not one of the backends in this package, false is returned.
+func KeyValue(s clinote.Storage) (KeyValueStore, bool) {
+	switch db := s.(type) {
+	case *Database:
+		return &kvExporter{kv: db}, true
+	case *SQLiteDatabase:
+		return &kvExporter{kv: db}, true
+	case *MemoryDatabase:
+		return &kvExporter{kv: db}, true
+	case *kvStorage:
+		return db.kv, true
+	default:
+		return nil, false
+	}
+}
+
+// NewKeyValueStorage returns a storage that saves its data in the key-value
+// store. The settings are merged with the config file in the config folder,
+// unless the folder is empty. When the storage is closed, the closer is
+// closed.
+func NewKeyValueStorage(kv KeyValueStore, closer io.Closer, cfgFolder string) clinote.Storage {
+	s := &kvStorage{kv: kv, closer: closer}
+	s.store = newStore(&kvImporter{kv: kv}, cfgFolder)
+	return s
+}
+
+type kvStorage struct {
+	*store
+	kv     KeyValueStore
+	closer io.Closer
+}
+
+func (s *kvStorage) Close() error {
+	err := s.flushCacheUses()
+	if closeErr := s.closer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// kvExporter exports the internal key-value store.
+type kvExporter struct {
+	kv kvStore
+}
+
+func (e *kvExporter) GetData(bucket, key []byte) ([]byte, error) {
+	return e.kv.getData(bucket, key)
+}
+
+func (e *kvExporter) StoreData(bucket, key, data []byte) error {
+	return e.kv.storeData(bucket, key, data)
+}
+
+func (e *kvExporter) DeleteData(bucket, key []byte) error {
+	return e.kv.deleteData(bucket, key)
+}
+
+// kvImporter wraps an external key-value store.
+type kvImporter struct {
+	kv KeyValueStore
+}
+
+func (i *kvImporter) getData(bucket, key []byte) ([]byte, error) {
+	return i.kv.GetData(bucket, key)
+}
+
+func (i *kvImporter) storeData(bucket, key, data []byte) error {
+	return i.kv.StoreData(bucket, key, data)
+}
+
+func (i *kvImporter) deleteData(bucket, key []byte) error {
+	return i.kv.DeleteData(bucket, key)
+}
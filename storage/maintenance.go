@@ -0,0 +1,466 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/boltdb/bolt"
+)
+
+// compactLockTimeout is how long compaction waits for another process to
+// close the database.
+const compactLockTimeout = 5 * time.Second
+
+var (
+	// ErrDatabaseInUse is returned if the database is used by another
+	// process.
+	ErrDatabaseInUse = errors.New("the database is used by another process")
+	// ErrVerifyNotSupported is returned if the storage can't be verified.
+	ErrVerifyNotSupported = errors.New("the storage can't be verified")
+)
+
+// knownBuckets are the buckets used by the storage. The cache entry
+// buckets are not included.
+var knownBuckets = [][]byte{
+	dbBucket, settingsBucket, cacheBucket, redactedBucket, syncBucket, cacheIdxBucket,
+	journalBucket, transcriptBucket, outboxBucket, undoBucket, checkpointBucket,
+}
+
+// kvWalker is implemented by the backends whose data can be verified.
+type kvWalker interface {
+	// walk calls fn for each key in each bucket.
+	walk(fn func(bucket, key, value []byte) error) error
+	// checkFile checks the integrity of the database file and returns the
+	// problems found.
+	checkFile() ([]string, error)
+}
+
+// CompactResult is the result of a compaction.
+type CompactResult struct {
+	// Before is the size of the database file before the compaction.
+	Before int64
+	// After is the size of the database file after the compaction.
+	After int64
+}
+
+// Compact reclaims the unused space in the database file of the config
+// folder. A BoltDB database is copied to a new file that replaces the old
+// one. A SQLite database is vacuumed. The database must not be open.
+func Compact(cfgFolder string) (*CompactResult, error) {
+	name, err := GetBackend(cfgFolder)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case BoltBackend:
+		return compactBolt(filepath.Join(cfgFolder, dbFilename))
+	case SQLiteBackend:
+		return compactSQLite(cfgFolder)
+	default:
+		return nil, ErrUnknownBackend
+	}
+}
+
+func compactBolt(filename string) (*CompactResult, error) {
+	before, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	src, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: compactLockTimeout})
+	if err == bolt.ErrTimeout {
+		return nil, ErrDatabaseInUse
+	}
+	if err != nil {
+		return nil, err
+	}
+	tmp := filename + ".compact"
+	os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0600, nil)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	err = src.View(func(st *bolt.Tx) error {
+		return dst.Update(func(dt *bolt.Tx) error {
+			return st.ForEach(func(name []byte, b *bolt.Bucket) error {
+				nb, err := dt.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(nb, b)
+			})
+		})
+	})
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := src.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filename)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	after, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &CompactResult{Before: before.Size(), After: after.Size()}, nil
+}
+
+// copyBucket copies the keys and the nested buckets from src to dst.
+func copyBucket(dst, src *bolt.Bucket) error {
+	// The keys are added in order, so the pages can be filled.
+	dst.FillPercent = 1
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		nb, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(nb, src.Bucket(k))
+	})
+}
+
+func compactSQLite(cfgFolder string) (*CompactResult, error) {
+	filename := filepath.Join(cfgFolder, sqliteFilename)
+	before, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	db, err := OpenSQLite(cfgFolder)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.db.Exec("VACUUM")
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	after, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &CompactResult{Before: before.Size(), After: after.Size()}, nil
+}
+
+// VerifyProblem is a problem found when verifying the storage.
+type VerifyProblem struct {
+	// Bucket is the bucket with the problem. Empty for problems with the
+	// database file.
+	Bucket string
+	// Key is the key with the problem. Empty for problems with the bucket.
+	Key string
+	// Problem describes the problem.
+	Problem string
+	// Orphaned is true for cache data not tracked by the cache index and
+	// index entries without data. Orphans are removed by a fix.
+	Orphaned bool
+}
+
+// VerifyReport is the result of a verification of the storage.
+type VerifyReport struct {
+	// Buckets is the number of buckets checked.
+	Buckets int
+	// Keys is the number of keys checked.
+	Keys int
+	// Problems are the problems found.
+	Problems []*VerifyProblem
+	// Fixed is the number of problems fixed: the orphans removed and the
+	// rebuilt cache index.
+	Fixed int
+	// IndexRebuilt is true if the cache index couldn't be decoded. With a
+	// fix, it's rebuilt from the cache entries.
+	IndexRebuilt bool
+}
+
+// Verify walks all the buckets of the storage and checks that the values
+// can be decoded and that the cache entries match the cache index. The
+// integrity of the database file is checked too. With fix, the orphaned
+// cache entries are removed. If the cache index can't be decoded, the
+// cache entries aren't orphans and the index is rebuilt from them instead.
+func Verify(s clinote.Storage, fix bool) (*VerifyReport, error) {
+	var st *store
+	var w kvWalker
+	switch db := s.(type) {
+	case *Database:
+		st, w = db.store, db
+	case *SQLiteDatabase:
+		st, w = db.store, db
+	case *MemoryDatabase:
+		st, w = db.store, db
+	default:
+		return nil, ErrVerifyNotSupported
+	}
+	report := new(VerifyReport)
+	problems, err := w.checkFile()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range problems {
+		report.Problems = append(report.Problems, &VerifyProblem{Problem: p})
+	}
+	index, err := st.getCacheIndex()
+	if err != nil {
+		report.Problems = append(report.Problems, &VerifyProblem{Bucket: string(cacheIdxBucket), Key: string(cacheIndexKey), Problem: "invalid JSON: " + err.Error()})
+		report.IndexRebuilt = true
+		index = &cacheIndex{Entries: make(map[string]map[string]*cacheIndexEntry), Evictions: make(map[string]int64)}
+	}
+	// found tracks the indexed cache entries that have data.
+	found := make(map[string]map[string]bool)
+	var orphans [][2]string
+	var bucket string
+	err = w.walk(func(b, key, value []byte) error {
+		if string(b) != bucket {
+			bucket = string(b)
+			report.Buckets++
+			if !isKnownBucket(b) {
+				report.Problems = append(report.Problems, &VerifyProblem{Bucket: bucket, Problem: "unknown bucket"})
+			}
+		}
+		report.Keys++
+		if cache, ok := cacheName(b); ok {
+			if report.IndexRebuilt {
+				if index.Entries[cache] == nil {
+					index.Entries[cache] = make(map[string]*cacheIndexEntry)
+				}
+				index.Entries[cache][string(key)] = &cacheIndexEntry{Size: int64(len(value))}
+			}
+			if _, ok := index.Entries[cache][string(key)]; !ok {
+				report.Problems = append(report.Problems, &VerifyProblem{Bucket: bucket, Key: string(key), Problem: "cache entry not in the index", Orphaned: true})
+				orphans = append(orphans, [2]string{bucket, string(key)})
+				return nil
+			}
+			if found[cache] == nil {
+				found[cache] = make(map[string]bool)
+			}
+			found[cache][string(key)] = true
+			return nil
+		}
+		if problem := checkValue(b, key, value); problem != "" {
+			report.Problems = append(report.Problems, &VerifyProblem{Bucket: bucket, Key: string(key), Problem: problem})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var stale int
+	for cache, entries := range index.Entries {
+		for key := range entries {
+			if found[cache][key] {
+				continue
+			}
+			report.Problems = append(report.Problems, &VerifyProblem{Bucket: string(cacheEntryBucket(cache)), Key: key, Problem: "index entry without data", Orphaned: true})
+			stale++
+			if fix {
+				delete(entries, key)
+			}
+		}
+	}
+	if !fix {
+		return report, nil
+	}
+	for _, o := range orphans {
+		if err = st.kv.deleteData([]byte(o[0]), []byte(o[1])); err != nil {
+			return nil, err
+		}
+		report.Fixed++
+	}
+	if stale != 0 || report.IndexRebuilt {
+		if err = st.saveCacheIndex(index); err != nil {
+			return nil, err
+		}
+		report.Fixed += stale
+	}
+	if report.IndexRebuilt {
+		report.Fixed++
+	}
+	return report, nil
+}
+
+func isKnownBucket(b []byte) bool {
+	for _, known := range knownBuckets {
+		if string(known) == string(b) {
+			return true
+		}
+	}
+	_, ok := cacheName(b)
+	return ok
+}
+
+// cacheName returns the name of the cache if the bucket holds cache
+// entries.
+func cacheName(b []byte) (string, bool) {
+	// The cache index bucket shares the prefix.
+	if string(b) == string(cacheIdxBucket) || !strings.HasPrefix(string(b), "cache_") {
+		return "", false
+	}
+	return strings.TrimPrefix(string(b), "cache_"), true
+}
+
+// checkValue returns the problem with the value, or an empty string if the
+// value can be decoded.
+func checkValue(bucket, key, value []byte) string {
+	switch string(bucket) {
+	case string(dbBucket):
+		if _, n := binary.Uvarint(value); n <= 0 {
+			return "invalid database version"
+		}
+		return ""
+	case string(redactedBucket):
+		// The redacted notes are encrypted.
+		return ""
+	case string(cacheIdxBucket):
+		// The cache index is checked when it's read.
+		return ""
+	}
+	if !json.Valid(value) {
+		return "invalid JSON"
+	}
+	return ""
+}
+
+func (d *Database) walk(fn func(bucket, key, value []byte) error) error {
+	db, err := d.getDBHandler()
+	defer d.releaseDBHandler()
+	if err != nil {
+		return err
+	}
+	return db.View(func(t *bolt.Tx) error {
+		return t.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return b.ForEach(func(k, v []byte) error {
+				if v == nil {
+					// Nested buckets are not used by the storage.
+					return nil
+				}
+				return fn(name, k, v)
+			})
+		})
+	})
+}
+
+func (d *Database) checkFile() ([]string, error) {
+	db, err := d.getDBHandler()
+	defer d.releaseDBHandler()
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	err = db.View(func(t *bolt.Tx) error {
+		for err := range t.Check() {
+			problems = append(problems, err.Error())
+		}
+		return nil
+	})
+	return problems, err
+}
+
+func (d *SQLiteDatabase) walk(fn func(bucket, key, value []byte) error) error {
+	rows, err := d.db.Query("SELECT bucket, key, value FROM data ORDER BY bucket, key")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket string
+		var key, value []byte
+		if err = rows.Scan(&bucket, &key, &value); err != nil {
+			return err
+		}
+		if err = fn([]byte(bucket), key, value); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (d *SQLiteDatabase) checkFile() ([]string, error) {
+	rows, err := d.db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err = rows.Scan(&result); err != nil {
+			return nil, err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	return problems, rows.Err()
+}
+
+func (d *MemoryDatabase) walk(fn func(bucket, key, value []byte) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	buckets := make([]string, 0, len(d.buckets))
+	for name := range d.buckets {
+		buckets = append(buckets, name)
+	}
+	sort.Strings(buckets)
+	for _, name := range buckets {
+		keys := make([]string, 0, len(d.buckets[name]))
+		for key := range d.buckets[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := fn([]byte(name), []byte(key), d.buckets[name][key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkFile returns no problems since there is no file.
+func (d *MemoryDatabase) checkFile() ([]string, error) {
+	return nil, nil
+}
+
+// String returns the problem as a line of text.
+func (p *VerifyProblem) String() string {
+	switch {
+	case p.Bucket == "":
+		return p.Problem
+	case p.Key == "":
+		return fmt.Sprintf("%s: %s", p.Bucket, p.Problem)
+	}
+	return fmt.Sprintf("%s/%s: %s", p.Bucket, p.Key, p.Problem)
+}
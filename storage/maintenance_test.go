@@ -0,0 +1,118 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/TcM1911/clinote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompact(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	settings := &clinote.Settings{DefaultNotebook: "Work"}
+	assert.NoError(db.StoreSettings(settings))
+	data := bytes.Repeat([]byte("x"), 64*1024)
+	for i := 0; i < 20; i++ {
+		key := []byte{byte(i)}
+		assert.NoError(db.storeData(cacheEntryBucket(clinote.ContentCache), key, data))
+	}
+	for i := 0; i < 20; i++ {
+		assert.NoError(db.deleteData(cacheEntryBucket(clinote.ContentCache), []byte{byte(i)}))
+	}
+	assert.NoError(db.Close())
+
+	result, err := Compact(tmpDir)
+
+	assert.NoError(err)
+	assert.True(result.After < result.Before, "The file should shrink, before: %d after: %d", result.Before, result.After)
+	db, err = Open(tmpDir)
+	assert.NoError(err)
+	defer db.Close()
+	stored, err := db.GetSettings()
+	assert.NoError(err)
+	assert.Equal(settings, stored)
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+	assert.NoError(db.StoreSettings(new(clinote.Settings)))
+	assert.NoError(db.PutCacheEntry(clinote.ContentCache, "tracked", []byte("<en-note/>")))
+	assert.NoError(db.PutCacheEntry(clinote.ContentCache, "stale", []byte("<en-note/>")))
+	assert.NoError(db.deleteData(cacheEntryBucket(clinote.ContentCache), []byte("stale")))
+	assert.NoError(db.storeData(cacheEntryBucket(clinote.ContentCache), []byte("orphan"), []byte("data")))
+	assert.NoError(db.storeData(syncBucket, pendingChangesKey, []byte("{broken")))
+	assert.NoError(db.storeData([]byte("leftover"), []byte("key"), []byte("{}")))
+
+	report, err := Verify(db, false)
+
+	assert.NoError(err)
+	assert.Zero(report.Fixed)
+	problems := make(map[string]bool)
+	for _, p := range report.Problems {
+		problems[p.String()] = p.Orphaned
+	}
+	assert.Equal(map[string]bool{
+		"cache_content/orphan: cache entry not in the index": true,
+		"cache_content/stale: index entry without data":      true,
+		"sync/pending_changes: invalid JSON":                 false,
+		"leftover: unknown bucket":                           false,
+	}, problems)
+
+	report, err = Verify(db, true)
+	assert.NoError(err)
+	assert.Equal(2, report.Fixed)
+
+	report, err = Verify(db, false)
+	assert.NoError(err)
+	assert.Len(report.Problems, 2, "Only the orphans should be fixed")
+	data, err := db.GetCacheEntry(clinote.ContentCache, "tracked")
+	assert.NoError(err)
+	assert.Equal([]byte("<en-note/>"), data)
+}
+
+func TestVerifyRebuildsCacheIndex(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	defer db.Close()
+	assert.NoError(db.StoreSettings(new(clinote.Settings)))
+	assert.NoError(db.PutCacheEntry(clinote.ContentCache, "note", []byte("<en-note/>")))
+	assert.NoError(db.storeData(cacheIdxBucket, cacheIndexKey, []byte("{broken")))
+
+	report, err := Verify(db, true)
+
+	assert.NoError(err)
+	assert.True(report.IndexRebuilt)
+	assert.Equal(1, report.Fixed)
+	assert.Len(report.Problems, 1, "The cache entries shouldn't be orphans")
+	data, err := db.GetCacheEntry(clinote.ContentCache, "note")
+	assert.NoError(err)
+	assert.Equal([]byte("<en-note/>"), data, "The cache entry should be kept")
+	report, err = Verify(db, false)
+	assert.NoError(err)
+	assert.Empty(report.Problems)
+}
@@ -0,0 +1,116 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// NewMemory returns a storage that keeps its data in memory. Nothing is
+// written to disk and the data is lost when the process exits. It can be
+// used in tests and by tools that don't need to keep any state.
+func NewMemory() *MemoryDatabase {
+	d := &MemoryDatabase{buckets: make(map[string]map[string][]byte)}
+	d.store = newStore(d, "")
+	return d
+}
+
+// OpenEphemeral returns an in-memory storage with the settings and the
+// credentials copied from the storage backend of the config folder. If the
+// config folder has no database, the storage is empty. The settings are
+// merged with the config file like for the backend. Changes are not written
+// back.
+func OpenEphemeral(cfgFolder string) (*MemoryDatabase, error) {
+	d := NewMemory()
+	d.store = newStore(d, cfgFolder)
+	name, err := GetBackend(cfgFolder)
+	if err != nil {
+		return nil, err
+	}
+	filename := dbFilename
+	if name == SQLiteBackend {
+		filename = sqliteFilename
+	}
+	if _, err = os.Stat(filepath.Join(cfgFolder, filename)); os.IsNotExist(err) {
+		return d, nil
+	}
+	src, err := openBackend(cfgFolder, name)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	if err = copyStorage(d, src); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// MemoryDatabase is a storage backend that keeps the data in memory.
+type MemoryDatabase struct {
+	*store
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+func (d *MemoryDatabase) getData(bucket, key []byte) ([]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	data, ok := d.buckets[string(bucket)][string(key)]
+	if !ok {
+		return nil, nil
+	}
+	return copyBytes(data), nil
+}
+
+func (d *MemoryDatabase) storeData(bucket, key, data []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b, ok := d.buckets[string(bucket)]
+	if !ok {
+		b = make(map[string][]byte)
+		d.buckets[string(bucket)] = b
+	}
+	b[string(key)] = copyBytes(data)
+	return nil
+}
+
+func (d *MemoryDatabase) deleteData(bucket, key []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.buckets[string(bucket)], string(key))
+	return nil
+}
+
+// Close does nothing. The data is kept until the storage is garbage
+// collected.
+func (d *MemoryDatabase) Close() error {
+	return nil
+}
+
+// copyBytes returns a copy of the data so callers can't change the stored
+// value.
+func copyBytes(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	cpy := make([]byte, len(data))
+	copy(cpy, data)
+	return cpy
+}
@@ -0,0 +1,162 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TcM1911/clinote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDatabase(t *testing.T) {
+	assert := assert.New(t)
+	db := NewMemory()
+	defer db.Close()
+
+	t.Run("settings", func(t *testing.T) {
+		expected := &clinote.Settings{APIKey: "key", DefaultNotebook: "Work"}
+		assert.NoError(db.StoreSettings(expected))
+		actual, err := db.GetSettings()
+		assert.NoError(err)
+		assert.Equal(expected, actual)
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		cred := &clinote.Credential{Name: "test", Secret: "secret", CredType: clinote.EvernoteCredential}
+		assert.NoError(db.Add(cred))
+		list, err := db.GetAll()
+		assert.NoError(err)
+		assert.Equal([]*clinote.Credential{cred}, list)
+		assert.NoError(db.Remove(cred))
+		list, err = db.GetAll()
+		assert.NoError(err)
+		assert.Empty(list)
+	})
+
+	t.Run("values_are_copied", func(t *testing.T) {
+		data := []byte("value")
+		assert.NoError(db.storeData([]byte("b"), []byte("k"), data))
+		data[0] = 'X'
+		actual, err := db.getData([]byte("b"), []byte("k"))
+		assert.NoError(err)
+		assert.Equal([]byte("value"), actual)
+		assert.NoError(db.deleteData([]byte("b"), []byte("k")))
+		actual, err = db.getData([]byte("b"), []byte("k"))
+		assert.NoError(err)
+		assert.Nil(actual)
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		report, err := Verify(db, false)
+		assert.NoError(err)
+		assert.Empty(report.Problems)
+		assert.NotZero(report.Keys)
+	})
+}
+
+func TestOpenEphemeral(t *testing.T) {
+	assert := assert.New(t)
+	db, tmpDir := setupTestDB(t)
+	defer os.RemoveAll(tmpDir)
+	settings := &clinote.Settings{DefaultNotebook: "Work"}
+	assert.NoError(db.StoreSettings(settings))
+	assert.NoError(db.Add(&clinote.Credential{Name: "test", Secret: "secret"}))
+	assert.NoError(db.Close())
+
+	t.Run("copy_from_config_folder", func(t *testing.T) {
+		mem, err := OpenEphemeral(tmpDir)
+		assert.NoError(err)
+		actual, err := mem.GetSettings()
+		assert.NoError(err)
+		assert.Equal("Work", actual.DefaultNotebook)
+		creds, err := mem.GetAll()
+		assert.NoError(err)
+		assert.Len(creds, 1)
+
+		actual.DefaultNotebook = "Home"
+		assert.NoError(mem.StoreSettings(actual))
+		db, err := Open(tmpDir)
+		assert.NoError(err)
+		stored, err := db.GetSettings()
+		assert.NoError(err)
+		assert.Equal("Work", stored.DefaultNotebook, "Changes should not be written back")
+		db.Close()
+	})
+
+	t.Run("merge_config_file", func(t *testing.T) {
+		cfg := filepath.Join(tmpDir, clinote.ConfigFileName)
+		assert.NoError(ioutil.WriteFile(cfg, []byte("notebook.default = \"File\"\n"), 0600))
+		defer os.Remove(cfg)
+		mem, err := OpenEphemeral(tmpDir)
+		assert.NoError(err)
+		actual, err := mem.GetSettings()
+		assert.NoError(err)
+		assert.Equal("File", actual.DefaultNotebook, "Config file should take precedence")
+	})
+
+	t.Run("decrypt_encrypted_notebook", func(t *testing.T) {
+		db, err := Open(tmpDir)
+		assert.NoError(err)
+		key := make([]byte, 32)
+		assert.NoError(db.SaveNotebookKey("secret", key))
+		ns := &contentNotestore{content: make(map[string]string)}
+		body := clinote.XMLHeader + "<en-note>Secret</en-note>"
+		n := &clinote.Note{GUID: "1", Body: body, Notebook: &clinote.Notebook{GUID: "secret"}}
+		assert.NoError(clinote.NewEncryptedNotestore(ns, db).CreateNote(n))
+		assert.NotContains(ns.content["1"], "Secret")
+		db.Close()
+
+		mem, err := OpenEphemeral(tmpDir)
+		assert.NoError(err)
+		content, err := clinote.NewEncryptedNotestore(ns, mem).GetNoteContent("1")
+		assert.NoError(err)
+		assert.Equal(body, content)
+	})
+
+	t.Run("empty_config_folder", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "clinote-test")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+		mem, err := OpenEphemeral(dir)
+		assert.NoError(err)
+		creds, err := mem.GetAll()
+		assert.NoError(err)
+		assert.Empty(creds)
+		_, err = os.Stat(filepath.Join(dir, dbFilename))
+		assert.True(os.IsNotExist(err), "No database should be created")
+	})
+}
+
+// contentNotestore keeps the content of the created notes.
+type contentNotestore struct {
+	clinote.NotestoreClient
+	content map[string]string
+}
+
+func (c *contentNotestore) CreateNote(n *clinote.Note) error {
+	c.content[n.GUID] = n.Body
+	return nil
+}
+
+func (c *contentNotestore) GetNoteContent(guid string) (string, error) {
+	return c.content[guid], nil
+}
@@ -49,7 +49,7 @@ func migrateOAuthCredential(db *Database) error {
 		data = b.Get(settingsKey)
 		return nil
 	})
-	if err == errNoBucket {
+	if err == errNoBucket || data == nil {
 		db.releaseDBHandler()
 		return nil
 	}
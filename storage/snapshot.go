@@ -0,0 +1,225 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/TcM1911/clinote"
+	"github.com/boltdb/bolt"
+)
+
+// SnapshotBackup stores the backup as a gzip compressed BoltDB database
+// with the notes and the manifest.
+var SnapshotBackup = &clinote.BackupFormat{Name: clinote.SnapshotBackupFormat, Ext: ".db.gz", NewWriter: newSnapshotWriter, Open: openSnapshot}
+
+var (
+	snapshotNotesBucket = []byte("notes")
+	snapshotMetaBucket  = []byte("meta")
+	snapshotManifestKey = []byte("manifest")
+)
+
+// snapshotWriter writes the notes to a database in a temporary file. The
+// file is compressed to the writer when the snapshot is closed.
+type snapshotWriter struct {
+	w        io.Writer
+	file     string
+	db       *bolt.DB
+	manifest *clinote.BackupManifest
+	seq      uint64
+}
+
+func newSnapshotWriter(w io.Writer, m *clinote.BackupManifest) (clinote.BackupWriter, error) {
+	file, err := tempFilename()
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(file, 0600, nil)
+	if err != nil {
+		os.Remove(file)
+		return nil, err
+	}
+	return &snapshotWriter{w: w, file: file, db: db, manifest: m}, nil
+}
+
+func (s *snapshotWriter) Add(n *clinote.Note) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	book := n.Notebook
+	if book == nil {
+		book = &clinote.Notebook{}
+	}
+	list := s.manifest.Notebooks
+	if len(list) == 0 || list[len(list)-1].Name != book.Name || list[len(list)-1].Stack != book.Stack {
+		s.manifest.Notebooks = append(list, &clinote.BackupNotebook{Name: book.Name, Stack: book.Stack})
+	}
+	current := s.manifest.Notebooks[len(s.manifest.Notebooks)-1]
+	current.GUIDs = append(current.GUIDs, n.GUID)
+	s.seq++
+	return s.db.Update(func(t *bolt.Tx) error {
+		b, err := t.CreateBucketIfNotExists(snapshotNotesBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(snapshotKey(s.seq), data)
+	})
+}
+
+func (s *snapshotWriter) Close() error {
+	defer os.Remove(s.file)
+	data, err := json.Marshal(s.manifest)
+	if err != nil {
+		s.db.Close()
+		return err
+	}
+	err = s.db.Update(func(t *bolt.Tx) error {
+		b, err := t.CreateBucketIfNotExists(snapshotMetaBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(snapshotManifestKey, data)
+	})
+	if err != nil {
+		s.db.Close()
+		return err
+	}
+	if err = s.db.Close(); err != nil {
+		return err
+	}
+	f, err := os.Open(s.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(s.w)
+	if _, err = io.Copy(zw, f); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// snapshotReader reads the notes from a snapshot decompressed to a
+// temporary file.
+type snapshotReader struct {
+	file     string
+	db       *bolt.DB
+	manifest *clinote.BackupManifest
+	seq      uint64
+}
+
+func openSnapshot(file string) (clinote.BackupReader, error) {
+	tmp, err := decompressSnapshot(file)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(tmp, 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	r := &snapshotReader{file: tmp, db: db}
+	err = db.View(func(t *bolt.Tx) error {
+		b := t.Bucket(snapshotMetaBucket)
+		if b == nil {
+			return errNoBucket
+		}
+		return json.Unmarshal(b.Get(snapshotManifestKey), &r.manifest)
+	})
+	if err == nil && r.manifest.Version > clinote.BackupVersion {
+		err = clinote.ErrUnsupportedBackupVersion
+	}
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func decompressSnapshot(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile("", "clinote-snapshot")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, zr)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func (r *snapshotReader) Manifest() *clinote.BackupManifest {
+	return r.manifest
+}
+
+func (r *snapshotReader) Next() (*clinote.Note, error) {
+	var n *clinote.Note
+	err := r.db.View(func(t *bolt.Tx) error {
+		b := t.Bucket(snapshotNotesBucket)
+		if b == nil {
+			return io.EOF
+		}
+		k, v := b.Cursor().Seek(snapshotKey(r.seq + 1))
+		if k == nil {
+			return io.EOF
+		}
+		r.seq = binary.BigEndian.Uint64(k)
+		return json.Unmarshal(v, &n)
+	})
+	return n, err
+}
+
+func (r *snapshotReader) Close() error {
+	defer os.Remove(r.file)
+	return r.db.Close()
+}
+
+func snapshotKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// tempFilename returns the name of a new empty temporary file.
+func tempFilename() (string, error) {
+	f, err := ioutil.TempFile("", "clinote-snapshot")
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	return name, f.Close()
+}
@@ -0,0 +1,82 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TcM1911/clinote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotBackup(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-snapshot")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "backup.db.gz")
+	f, err := os.Create(file)
+	assert.NoError(err)
+	created := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	w, err := SnapshotBackup.NewWriter(f, &clinote.BackupManifest{Version: clinote.BackupVersion, Created: created, Format: clinote.SnapshotBackupFormat})
+	assert.NoError(err)
+	notes := []*clinote.Note{
+		{GUID: "n1", Title: "Plan", Body: "<en-note>Plan</en-note>", Notebook: &clinote.Notebook{Name: "Work"},
+			Resources: []*clinote.Resource{{Mime: "text/plain", Filename: "a.txt", Data: []byte("attachment")}}},
+		{GUID: "n2", Title: "Budget", Notebook: &clinote.Notebook{Name: "Work"}},
+		{GUID: "n3", Title: "Milk", Notebook: &clinote.Notebook{Name: "Home", Stack: "Life"}},
+	}
+	for _, n := range notes {
+		assert.NoError(w.Add(n))
+	}
+	assert.NoError(w.Close())
+	assert.NoError(f.Close())
+
+	r, err := SnapshotBackup.Open(file)
+	assert.NoError(err)
+	defer r.Close()
+	m := r.Manifest()
+	assert.True(created.Equal(m.Created))
+	assert.Equal([]*clinote.BackupNotebook{
+		{Name: "Work", GUIDs: []string{"n1", "n2"}},
+		{Name: "Home", Stack: "Life", GUIDs: []string{"n3"}},
+	}, m.Notebooks)
+	for _, n := range notes {
+		read, err := r.Next()
+		assert.NoError(err)
+		assert.Equal(n, read)
+	}
+	_, err = r.Next()
+	assert.Equal(io.EOF, err)
+}
+
+func TestOpenSnapshotNotABackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clinote-snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "backup.db.gz")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("not gzip"), 0600))
+
+	_, err = SnapshotBackup.Open(file)
+	assert.Error(t, err)
+}
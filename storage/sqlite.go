@@ -0,0 +1,107 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+)
+
+const (
+	sqliteFilename = "clinote.sqlite"
+	sqliteDriver   = "sqlite3"
+	// The busy timeout makes concurrent writers wait for the lock instead of failing.
+	sqliteOptions = "?_busy_timeout=5000&_journal_mode=WAL"
+)
+
+const (
+	createDataTable = `CREATE TABLE IF NOT EXISTS data (
+	bucket TEXT NOT NULL,
+	key BLOB NOT NULL,
+	value BLOB,
+	PRIMARY KEY (bucket, key))`
+	selectData = `SELECT value FROM data WHERE bucket = ? AND key = ?`
+	upsertData = `INSERT OR REPLACE INTO data (bucket, key, value) VALUES (?, ?, ?)`
+	deleteData = `DELETE FROM data WHERE bucket = ? AND key = ?`
+)
+
+// ErrSQLiteNotSupported is returned if clinote was built without a SQLite driver.
+var ErrSQLiteNotSupported = errors.New("SQLite support not included, rebuild with the sqlite build tag")
+
+// OpenSQLite returns an instance of the SQLite database.
+func OpenSQLite(cfgFolder string) (*SQLiteDatabase, error) {
+	if !sqliteSupported() {
+		return nil, ErrSQLiteNotSupported
+	}
+	db, err := sql.Open(sqliteDriver, filepath.Join(cfgFolder, sqliteFilename)+sqliteOptions)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec(createDataTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	d := &SQLiteDatabase{db: db}
+	d.store = newStore(d, cfgFolder)
+	return d, nil
+}
+
+// SQLiteDatabase is a storage backend using SQLite. Unlike BoltDB, SQLite
+// allows multiple processes to use the database at the same time.
+type SQLiteDatabase struct {
+	*store
+	db *sql.DB
+}
+
+func (d *SQLiteDatabase) getData(bucket, key []byte) ([]byte, error) {
+	var data []byte
+	err := d.db.QueryRow(selectData, string(bucket), key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (d *SQLiteDatabase) storeData(bucket, key, data []byte) error {
+	_, err := d.db.Exec(upsertData, string(bucket), key, data)
+	return err
+}
+
+func (d *SQLiteDatabase) deleteData(bucket, key []byte) error {
+	_, err := d.db.Exec(deleteData, string(bucket), key)
+	return err
+}
+
+// Close shuts down the connection to the database.
+func (d *SQLiteDatabase) Close() error {
+	err := d.flushCacheUses()
+	if closeErr := d.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func sqliteSupported() bool {
+	for _, name := range sql.Drivers() {
+		if name == sqliteDriver {
+			return true
+		}
+	}
+	return false
+}
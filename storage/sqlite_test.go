@@ -0,0 +1,79 @@
+// +build sqlite
+
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/TcM1911/clinote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteDatabase(t *testing.T) {
+	assert := assert.New(t)
+	tmpDir, err := ioutil.TempDir("", "clinote-test")
+	if err != nil {
+		t.Fatalf("Problem with creating temp folder: %s\n", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(SetBackend(tmpDir, SQLiteBackend))
+	s, err := OpenBackend(tmpDir)
+	if !assert.NoError(err) {
+		return
+	}
+	db := s.(*SQLiteDatabase)
+	defer db.Close()
+
+	t.Run("settings", func(t *testing.T) {
+		expected := &clinote.Settings{APIKey: "key", DefaultNotebook: "Work"}
+		assert.NoError(db.StoreSettings(expected))
+		actual, err := db.GetSettings()
+		assert.NoError(err)
+		assert.Equal(expected, actual)
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		cred := &clinote.Credential{Name: "test", Secret: "secret", CredType: clinote.EvernoteCredential}
+		assert.NoError(db.Add(cred))
+		list, err := db.GetAll()
+		assert.NoError(err)
+		assert.Equal([]*clinote.Credential{cred}, list)
+		assert.NoError(db.Remove(cred))
+		list, err = db.GetAll()
+		assert.NoError(err)
+		assert.Empty(list)
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		assert.NoError(db.PutCacheEntry(clinote.ContentCache, "a", []byte("aaaa")))
+		data, err := db.GetCacheEntry(clinote.ContentCache, "a")
+		assert.NoError(err)
+		assert.Equal([]byte("aaaa"), data)
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		report, err := Verify(db, false)
+		assert.NoError(err)
+		assert.Empty(report.Problems)
+		assert.NotZero(report.Keys)
+	})
+}
@@ -0,0 +1,593 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package storage
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+
+	"github.com/TcM1911/clinote"
+)
+
+// kvStore is a key-value store where the values are grouped into buckets.
+// It is implemented by the storage backends.
+type kvStore interface {
+	// getData returns the value for the key in the bucket. If no value is
+	// found, nil is returned.
+	getData(bucket, key []byte) ([]byte, error)
+	// storeData saves the value for the key in the bucket.
+	storeData(bucket, key, data []byte) error
+	// deleteData removes the key from the bucket.
+	deleteData(bucket, key []byte) error
+}
+
+// store implements the clinote.Storage interface on top of a key-value store.
+type store struct {
+	kv kvStore
+	// configFile is the path to the config file merged with the stored
+	// settings. If empty, only the stored settings are used.
+	configFile string
+
+	// cacheMu guards cacheUses.
+	cacheMu sync.Mutex
+	// cacheUses are the cache entries read since the cache index was last
+	// saved, in the order they were used. They are applied to the index
+	// when an entry is stored, or the storage is closed, instead of saving
+	// the index on every read.
+	cacheUses []cacheUse
+}
+
+// newStore returns a store for the backend in the config folder. If the
+// config folder is empty, no config file is merged with the settings.
+func newStore(kv kvStore, cfgFolder string) *store {
+	s := &store{kv: &loggingKV{kv: kv}}
+	if cfgFolder != "" {
+		s.configFile = filepath.Join(cfgFolder, clinote.ConfigFileName)
+	}
+	return s
+}
+
+// loggingKV logs the operations on the key-value store at the debug level.
+type loggingKV struct {
+	kv kvStore
+}
+
+func (l *loggingKV) getData(bucket, key []byte) ([]byte, error) {
+	data, err := l.kv.getData(bucket, key)
+	clinote.LogDebug("storage get", "bucket", string(bucket), "key", string(key), "size", len(data), "error", err)
+	return data, err
+}
+
+func (l *loggingKV) storeData(bucket, key, data []byte) error {
+	err := l.kv.storeData(bucket, key, data)
+	clinote.LogDebug("storage store", "bucket", string(bucket), "key", string(key), "size", len(data), "error", err)
+	return err
+}
+
+func (l *loggingKV) deleteData(bucket, key []byte) error {
+	err := l.kv.deleteData(bucket, key)
+	clinote.LogDebug("storage delete", "bucket", string(bucket), "key", string(key), "error", err)
+	return err
+}
+
+// GetSettings returns the settings from the storage merged with the
+// values in the config file.
+func (s *store) GetSettings() (*clinote.Settings, error) {
+	settings, err := s.getStoredSettings()
+	if err != nil || s.configFile == "" {
+		return settings, err
+	}
+	cfg, err := clinote.LoadConfigFile(s.configFile)
+	if err != nil {
+		return settings, err
+	}
+	return settings, cfg.Apply(settings)
+}
+
+// StoreSettings saves the settings to the storage. Values set in the
+// config file are not saved, the stored values are kept instead.
+func (s *store) StoreSettings(settings *clinote.Settings) error {
+	if s.configFile != "" {
+		cfg, err := clinote.LoadConfigFile(s.configFile)
+		if err != nil {
+			return err
+		}
+		stored, err := s.getStoredSettings()
+		if err != nil {
+			return err
+		}
+		// Copy the aliases so the caller's settings are not changed.
+		cpy := *settings
+		if settings.Aliases != nil {
+			cpy.Aliases = make(map[string]string, len(settings.Aliases))
+			for k, v := range settings.Aliases {
+				cpy.Aliases[k] = v
+			}
+		}
+		cfg.Keep(&cpy, stored)
+		settings = &cpy
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(settingsBucket, settingsKey, data)
+}
+
+func (s *store) getStoredSettings() (*clinote.Settings, error) {
+	var settings clinote.Settings
+	data, err := s.kv.getData(settingsBucket, settingsKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &settings)
+	}
+	return &settings, err
+}
+
+// GetNotebookCache returns the stored NotebookCacheList.
+func (s *store) GetNotebookCache() (*clinote.NotebookCacheList, error) {
+	var list clinote.NotebookCacheList
+	data, err := s.kv.getData(cacheBucket, notebookCacheKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &list)
+	}
+	return &list, err
+}
+
+// StoreNotebookList saves the list to the storage.
+func (s *store) StoreNotebookList(list *clinote.NotebookCacheList) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(cacheBucket, notebookCacheKey, data)
+}
+
+// searchKey returns the key for the saved search of the active
+// credential. Each credential keeps its own search, so the numbers in the
+// result stay the same when switching between credentials.
+func (s *store) searchKey(key []byte) ([]byte, error) {
+	active, err := clinote.ActiveCredential(s)
+	if err != nil {
+		return key, err
+	}
+	if active == nil {
+		// Sessions from before credentials were marked as active.
+		settings, err := s.getStoredSettings()
+		if err != nil || settings.Credential == nil {
+			return key, err
+		}
+		active = settings.Credential
+	}
+	return []byte(string(key) + "/" + credentialKey(active)), nil
+}
+
+// credentialKey returns a key for the account of the credential. The label
+// and the secret can change, so only the name, the type and the host are
+// used. The key of an Evernote credential is its name, as before the type
+// and host were added.
+func credentialKey(c *clinote.Credential) string {
+	if c.CredType == clinote.EvernoteCredential && c.Host == "" {
+		return c.Name
+	}
+	return c.Name + "/" + c.CredType.String() + "/" + c.Host
+}
+
+// SaveSearch stores the search of the active credential to the storage.
+func (s *store) SaveSearch(notes []*clinote.Note) error {
+	key, err := s.searchKey(searchCacheKey)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(notes)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(cacheBucket, key, data)
+}
+
+// GetSearch gets the saved search of the active credential from the
+// storage.
+func (s *store) GetSearch() ([]*clinote.Note, error) {
+	var notes []*clinote.Note
+	key, err := s.searchKey(searchCacheKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.kv.getData(cacheBucket, key)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &notes)
+	}
+	return notes, err
+}
+
+// GetSearchQuery returns the query of the saved search, or nil if no query
+// has been saved.
+func (s *store) GetSearchQuery() (*clinote.SavedSearch, error) {
+	key, err := s.searchKey(searchQueryKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.kv.getData(cacheBucket, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	query := new(clinote.SavedSearch)
+	return query, json.Unmarshal(data, query)
+}
+
+// SaveSearchQuery stores the query of the saved search.
+func (s *store) SaveSearchQuery(query *clinote.SavedSearch) error {
+	key, err := s.searchKey(searchQueryKey)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(cacheBucket, key, data)
+}
+
+// SaveNoteRecoveryPoint saves the note to the storage so it can be
+// recovered in the case something fails.
+func (s *store) SaveNoteRecoveryPoint(note *clinote.Note) error {
+	data, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(cacheBucket, noteRecoverCacheKey, data)
+}
+
+// GetNoteRecoveryPoint returns the saved note that failed to save.
+func (s *store) GetNoteRecoveryPoint() (*clinote.Note, error) {
+	var note clinote.Note
+	data, err := s.kv.getData(cacheBucket, noteRecoverCacheKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &note)
+	}
+	return &note, err
+}
+
+// SaveRedactedNote saves the encrypted copy of the note from before it was
+// redacted. A nil copy removes it.
+func (s *store) SaveRedactedNote(guid string, data []byte) error {
+	if data == nil {
+		return s.kv.deleteData(redactedBucket, []byte(guid))
+	}
+	return s.kv.storeData(redactedBucket, []byte(guid), data)
+}
+
+// GetRedactedNote returns the encrypted copy of the note from before it was redacted.
+func (s *store) GetRedactedNote(guid string) ([]byte, error) {
+	return s.kv.getData(redactedBucket, []byte(guid))
+}
+
+// GetSyncState returns the state saved at the last successful sync.
+func (s *store) GetSyncState() (*clinote.SyncState, error) {
+	var state clinote.SyncState
+	data, err := s.kv.getData(syncBucket, syncStateKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &state)
+	}
+	return &state, err
+}
+
+// SaveSyncState saves the state of a successful sync.
+func (s *store) SaveSyncState(state *clinote.SyncState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(syncBucket, syncStateKey, data)
+}
+
+// GetPendingChanges returns the queued changes.
+func (s *store) GetPendingChanges() ([]*clinote.PendingChange, error) {
+	var changes []*clinote.PendingChange
+	data, err := s.kv.getData(syncBucket, pendingChangesKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &changes)
+	}
+	return changes, err
+}
+
+// SavePendingChanges saves the queued changes.
+func (s *store) SavePendingChanges(changes []*clinote.PendingChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(syncBucket, pendingChangesKey, data)
+}
+
+// Add adds a new credential to the storage.
+func (s *store) Add(c *clinote.Credential) error {
+	creds, err := s.GetAll()
+	if err != nil {
+		return err
+	}
+	creds = append(creds, c)
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(settingsBucket, credentialsKey, data)
+}
+
+// Remove removes the credential from the storage.
+func (s *store) Remove(c *clinote.Credential) error {
+	credList, err := s.GetAll()
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i := 0; i < len(credList); i++ {
+		if credList[i].SameIdentity(c) {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return clinote.ErrNoMatchingCredentialFound
+	}
+	// Remove the entry
+	copy(credList[index:], credList[index+1:])
+	credList[len(credList)-1] = nil
+	credList = credList[:len(credList)-1]
+
+	data, err := json.Marshal(credList)
+	if err != nil {
+		return err
+	}
+	// Save the list
+	return s.kv.storeData(settingsBucket, credentialsKey, data)
+}
+
+// GetAll returns all the credentials in the storage.
+func (s *store) GetAll() ([]*clinote.Credential, error) {
+	var creds []*clinote.Credential
+	data, err := s.kv.getData(settingsBucket, credentialsKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &creds)
+	}
+	return creds, err
+}
+
+// Update replaces the credential at the index.
+func (s *store) Update(index int, c *clinote.Credential) error {
+	creds, err := s.GetAll()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(creds) {
+		return ErrIndexOutOfRange
+	}
+	creds[index] = c
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(settingsBucket, credentialsKey, data)
+}
+
+// GetByIndex returns a credential by its index.
+func (s *store) GetByIndex(index int) (*clinote.Credential, error) {
+	creds, err := s.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(creds) {
+		return nil, ErrIndexOutOfRange
+	}
+	return creds[index], nil
+}
+
+// GetNotebookKeys returns the keys for the client encrypted notebooks,
+// keyed by notebook GUID.
+func (s *store) GetNotebookKeys() (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	data, err := s.kv.getData(settingsBucket, notebookKeysKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &keys)
+	}
+	return keys, err
+}
+
+// SaveNotebookKey saves the key for the notebook. A nil key removes the
+// notebook's key.
+func (s *store) SaveNotebookKey(guid string, key []byte) error {
+	keys, err := s.GetNotebookKeys()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		delete(keys, guid)
+	} else {
+		keys[guid] = key
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(settingsBucket, notebookKeysKey, data)
+}
+
+// GetIntents returns the intents in the write journal.
+func (s *store) GetIntents() ([]*clinote.Intent, error) {
+	var intents []*clinote.Intent
+	data, err := s.kv.getData(journalBucket, intentsKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &intents)
+	}
+	return intents, err
+}
+
+// SaveIntents replaces the intents in the write journal.
+func (s *store) SaveIntents(intents []*clinote.Intent) error {
+	if len(intents) == 0 {
+		return s.kv.deleteData(journalBucket, intentsKey)
+	}
+	data, err := json.Marshal(intents)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(journalBucket, intentsKey, data)
+}
+
+// GetEdits returns the note edits in the write journal.
+func (s *store) GetEdits() ([]*clinote.JournalEdit, error) {
+	var edits []*clinote.JournalEdit
+	data, err := s.kv.getData(journalBucket, editsKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &edits)
+	}
+	return edits, err
+}
+
+// SaveEdits replaces the note edits in the write journal.
+func (s *store) SaveEdits(edits []*clinote.JournalEdit) error {
+	if len(edits) == 0 {
+		return s.kv.deleteData(journalBucket, editsKey)
+	}
+	data, err := json.Marshal(edits)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(journalBucket, editsKey, data)
+}
+
+// GetCaptures returns the captures in the outbox.
+func (s *store) GetCaptures() ([]*clinote.Capture, error) {
+	var captures []*clinote.Capture
+	data, err := s.kv.getData(outboxBucket, capturesKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &captures)
+	}
+	return captures, err
+}
+
+// SaveCaptures replaces the captures in the outbox.
+func (s *store) SaveCaptures(captures []*clinote.Capture) error {
+	if len(captures) == 0 {
+		return s.kv.deleteData(outboxBucket, capturesKey)
+	}
+	data, err := json.Marshal(captures)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(outboxBucket, capturesKey, data)
+}
+
+// GetUndoLog returns the operations in the undo log, oldest first.
+func (s *store) GetUndoLog() ([]*clinote.UndoEntry, error) {
+	var entries []*clinote.UndoEntry
+	data, err := s.kv.getData(undoBucket, undoLogKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &entries)
+	}
+	return entries, err
+}
+
+// SaveUndoLog replaces the operations in the undo log.
+func (s *store) SaveUndoLog(entries []*clinote.UndoEntry) error {
+	if len(entries) == 0 {
+		return s.kv.deleteData(undoBucket, undoLogKey)
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(undoBucket, undoLogKey, data)
+}
+
+// GetCheckpoint returns the checkpoint with the name. Nil is returned if
+// there is none.
+func (s *store) GetCheckpoint(name string) (*clinote.Checkpoint, error) {
+	data, err := s.kv.getData(checkpointBucket, []byte(name))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var c clinote.Checkpoint
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SaveCheckpoint saves the checkpoint.
+func (s *store) SaveCheckpoint(c *clinote.Checkpoint) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(checkpointBucket, []byte(c.Name), data)
+}
+
+// DeleteCheckpoint removes the checkpoint with the name.
+func (s *store) DeleteCheckpoint(name string) error {
+	return s.kv.deleteData(checkpointBucket, []byte(name))
+}
+
+// GetTranscriptProgress returns the saved transcription progress for the
+// note. Nil is returned if no progress is saved.
+func (s *store) GetTranscriptProgress(guid string) (*clinote.TranscriptProgress, error) {
+	data, err := s.kv.getData(transcriptBucket, []byte(guid))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var p clinote.TranscriptProgress
+	if err = json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveTranscriptProgress saves the transcription progress for the note.
+func (s *store) SaveTranscriptProgress(p *clinote.TranscriptProgress) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(transcriptBucket, []byte(p.NoteGUID), data)
+}
+
+// DeleteTranscriptProgress removes the saved transcription progress for the
+// note.
+func (s *store) DeleteTranscriptProgress(guid string) error {
+	return s.kv.deleteData(transcriptBucket, []byte(guid))
+}
+
+// GetBacklinkIndex returns the backlinks keyed by the GUID of the linked note.
+func (s *store) GetBacklinkIndex() (map[string][]*clinote.Backlink, error) {
+	index := make(map[string][]*clinote.Backlink)
+	data, err := s.kv.getData(syncBucket, backlinksKey)
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &index)
+	}
+	return index, err
+}
+
+// SaveBacklinkIndex replaces the backlink index.
+func (s *store) SaveBacklinkIndex(index map[string][]*clinote.Backlink) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return s.kv.storeData(syncBucket, backlinksKey, data)
+}
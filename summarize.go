@@ -0,0 +1,119 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/TcM1911/clinote/markdown"
+)
+
+var (
+	// ErrNoSummarizeCommand is returned if no summarize command is set.
+	ErrNoSummarizeCommand = errors.New("no summarize command set, set it with \"user set summarize.command\"")
+	// ErrEmptySummary is returned if the summarize command didn't write a summary.
+	ErrEmptySummary = errors.New("the summarize command didn't write a summary")
+)
+
+// RunSummarizer pipes the note's Markdown content through the shell command
+// and returns what it writes to stdout. The note's title and GUID are passed
+// in the environment variables CLINOTE_NOTE_TITLE and CLINOTE_NOTE_GUID.
+// Anything the command writes to stderr is shown to the user.
+func RunSummarizer(command string, n *Note) (string, error) {
+	if SafeMode {
+		return "", ErrSafeMode
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"CLINOTE_NOTE_TITLE="+n.Title,
+		"CLINOTE_NOTE_GUID="+n.GUID,
+	)
+	var out bytes.Buffer
+	cmd.Stdin = strings.NewReader(n.MD)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("summarize command failed: %s", err)
+	}
+	summary := strings.TrimSpace(out.String())
+	if summary == "" {
+		return "", ErrEmptySummary
+	}
+	return summary, nil
+}
+
+// SummarizeNote returns the note, with its content, and the summary written
+// by the summarize command in the settings.
+func SummarizeNote(db Storager, ns NotestoreClient, title string) (*Note, string, error) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, "", err
+	}
+	if settings.SummarizeCommand == "" {
+		return nil, "", ErrNoSummarizeCommand
+	}
+	n, err := getNoteExactWithContent(db, ns, title)
+	if err != nil {
+		return nil, "", err
+	}
+	summary, err := RunSummarizer(settings.SummarizeCommand, n)
+	if err != nil {
+		return nil, "", err
+	}
+	return n, summary, nil
+}
+
+// PrependSummary adds the summary to the top of the note, separated from the
+// content by a horizontal rule. The summary is formatted as Markdown.
+func PrependSummary(ns NotestoreClient, n *Note, summary string) error {
+	n.Body = fmt.Sprintf("<div><b>Summary</b></div>%s<hr/>%s", markdown.ToXML(summary), n.Body)
+	return saveChanges(ns, n, true, true)
+}
+
+// CreateSummaryNote saves the summary as a new note in the same notebook as
+// the summarized note. The new note is returned.
+func CreateSummaryNote(db Storager, ns NotestoreClient, n *Note, summary string) (*Note, error) {
+	s := &Note{Title: "Summary of " + n.Title, MD: summary}
+	if n.Notebook != nil {
+		books, err := GetNotebooks(db, ns, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range books {
+			if b.GUID == n.Notebook.GUID {
+				s.Notebook = b
+				break
+			}
+		}
+	}
+	if err := SaveNewNote(ns, s, false); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,89 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeNote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need a POSIX shell")
+	}
+	assert := assert.New(t)
+	settings := new(Settings)
+	db := &mockStore{
+		getSettings:       func() (*Settings, error) { return settings, nil },
+		getNotebookCache:  func() (*NotebookCacheList, error) { return &NotebookCacheList{}, nil },
+		storeNotebookList: func(*NotebookCacheList) error { return nil },
+	}
+	note := &Note{GUID: "GUID", Title: "Long note", Notebook: &Notebook{GUID: "NB"}}
+	var updated, created *Note
+	ns := &mockNS{
+		findNotes: func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{note}, nil },
+		getNoteContent: func(string) (string, error) {
+			return XMLHeader + "<en-note><div>First line</div><div>Second line</div></en-note>", nil
+		},
+		getAllNotebooks: func() ([]*Notebook, error) { return []*Notebook{{GUID: "NB", Name: "Notes"}}, nil },
+		updateNote:      func(n *Note) error { updated = n; return nil },
+		createNote:      func(n *Note) error { created = n; return nil },
+	}
+
+	t.Run("No command", func(t *testing.T) {
+		_, _, err := SummarizeNote(db, ns, "Long note")
+		assert.Equal(ErrNoSummarizeCommand, err)
+	})
+
+	t.Run("Empty summary", func(t *testing.T) {
+		settings.SummarizeCommand = "cat > /dev/null"
+		_, _, err := SummarizeNote(db, ns, "Long note")
+		assert.Equal(ErrEmptySummary, err)
+	})
+
+	t.Run("Failing command", func(t *testing.T) {
+		settings.SummarizeCommand = "exit 1"
+		_, _, err := SummarizeNote(db, ns, "Long note")
+		assert.Error(err)
+	})
+
+	settings.SummarizeCommand = `head -n 1; echo "$CLINOTE_NOTE_TITLE"`
+	n, summary, err := SummarizeNote(db, ns, "Long note")
+	assert.NoError(err)
+	assert.Equal("First line\nLong note", summary)
+
+	t.Run("Prepend", func(t *testing.T) {
+		body := n.Body
+		assert.NoError(PrependSummary(ns, n, "Short"))
+		if assert.NotNil(updated) {
+			assert.Contains(updated.Body, "<en-note><div><b>Summary</b></div>")
+			assert.Contains(updated.Body, "Short")
+			assert.Contains(updated.Body, "<hr/>"+body+"</en-note>")
+		}
+	})
+
+	t.Run("Companion note", func(t *testing.T) {
+		s, err := CreateSummaryNote(db, ns, note, "Short")
+		assert.NoError(err)
+		assert.Equal(created, s)
+		assert.Equal("Summary of Long note", s.Title)
+		assert.Equal("Notes", s.Notebook.Name)
+	})
+}
@@ -0,0 +1,432 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrNoPendingChange is returned if no queued change matches the id.
+	ErrNoPendingChange = errors.New("no queued change found")
+	// ErrSyncFailed is returned if one or more queued changes failed to sync.
+	ErrSyncFailed = errors.New("one or more queued changes failed to sync")
+	// ErrAmbiguousChangeID is returned if the id matches more than one queued change.
+	ErrAmbiguousChangeID = errors.New("id matches more than one queued change")
+	// ErrChangeQueued is returned if the server couldn't be reached and the
+	// change was queued instead.
+	ErrChangeQueued = errors.New("the server can't be reached, the change has been queued and will be pushed once it's reachable")
+)
+
+// ChangeType is the type of a locally queued change.
+type ChangeType uint8
+
+const (
+	// ChangeCreate is a note that should be created on the server.
+	ChangeCreate ChangeType = iota
+	// ChangeEdit is a note that should be updated on the server.
+	ChangeEdit
+	// ChangeDelete is a note that should be removed from the server.
+	ChangeDelete
+)
+
+var changeTypeStringMapper = []string{"create", "edit", "delete"}
+
+func (c ChangeType) String() string {
+	return changeTypeStringMapper[c]
+}
+
+// PendingChange is a local change that hasn't been pushed to the server.
+type PendingChange struct {
+	// ID identifies the change in the queue.
+	ID string
+	// Type is the type of change.
+	Type ChangeType
+	// Note is the note the change should be applied to.
+	Note *Note
+	// Queued is the time the change was added to the queue.
+	Queued time.Time
+	// Error is the error from the last attempt to push the change. If set,
+	// the change is stuck and needs to be retried or discarded.
+	Error string
+	// Interrupted is true if the program stopped while the change was
+	// pushed. The change may already be on the server, so it's skipped
+	// by Sync and has to be retried or discarded.
+	Interrupted bool
+}
+
+// SyncState is the state of the user's account at a point in time.
+type SyncState struct {
+	// UpdateCount is the update sequence number (USN) of the account.
+	UpdateCount int32
+	// Time is when the state was retrieved.
+	Time time.Time
+	// Uploaded is the number of bytes uploaded in the current upload cycle.
+	Uploaded int64
+	// BacklinkUSN is the highest USN of the notes in the backlink index.
+	// Only notes with a higher USN are indexed again.
+	BacklinkUSN int32
+}
+
+// SyncStatus is the sync status of the local storage compared to the server.
+type SyncStatus struct {
+	// LastSync is the time of the last successful sync.
+	LastSync time.Time
+	// LocalUSN is the update sequence number at the last successful sync.
+	LocalUSN int32
+	// ServerUSN is the current update sequence number on the server.
+	ServerUSN int32
+	// Creations is the number of queued note creations.
+	Creations int
+	// Edits is the number of queued note edits.
+	Edits int
+	// Deletions is the number of queued note deletions.
+	Deletions int
+	// Failed are the queued changes that are in an error state.
+	Failed []*PendingChange
+}
+
+// QueueChange adds a change to the pending queue. If the change is queued because
+// it failed to be pushed to the server, the error should be passed as cause.
+// Changes queued because the server couldn't be reached are not marked as
+// failed, they are pushed automatically on the next successful connection.
+func QueueChange(db Storager, changeType ChangeType, n *Note, cause error) error {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	change := &PendingChange{ID: id.String(), Type: changeType, Note: n, Queued: time.Now()}
+	if cause != nil && !IsOffline(cause) {
+		change.Error = cause.Error()
+	}
+	LogVerbose("queued change", "id", change.ID, "type", changeType, "guid", n.GUID, "cause", cause)
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return err
+	}
+	return db.SavePendingChanges(append(changes, change))
+}
+
+// QueueIfOffline queues the change if err means the server couldn't be
+// reached. ErrChangeQueued is returned if the change was queued, otherwise
+// err is returned.
+func QueueIfOffline(db Storager, changeType ChangeType, n *Note, err error) error {
+	if !IsOffline(err) {
+		return err
+	}
+	if queueErr := QueueChange(db, changeType, n, err); queueErr != nil {
+		return errors.New(err.Error() + "\nFailed to queue the change: " + queueErr.Error())
+	}
+	return ErrChangeQueued
+}
+
+// IsOffline returns true if the error means the server couldn't be reached,
+// for example if the connection was refused, the host name couldn't be
+// resolved or the request timed out. Calls that were canceled, or ran past
+// the command's timeout, are not offline.
+func IsOffline(err error) bool {
+	if IsCanceled(err) {
+		return false
+	}
+	for err != nil {
+		if _, ok := err.(net.Error); ok {
+			return true
+		}
+		switch e := err.(type) {
+		case interface{ Err() error }:
+			err = e.Err()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// IsCanceled returns true if the error is caused by a canceled context or a
+// context whose deadline has passed.
+func IsCanceled(err error) bool {
+	for err != nil {
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return true
+		}
+		switch e := err.(type) {
+		case interface{ Err() error }:
+			err = e.Err()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// GetSyncStatus returns the sync status. The notestore is used to get
+// the current state on the server.
+func GetSyncStatus(db Storager, ns NotestoreClient) (*SyncStatus, error) {
+	local, err := db.GetSyncState()
+	if err != nil {
+		return nil, err
+	}
+	server, err := ns.GetSyncState()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return nil, err
+	}
+	status := &SyncStatus{LastSync: local.Time, LocalUSN: local.UpdateCount, ServerUSN: server.UpdateCount}
+	for _, c := range changes {
+		switch c.Type {
+		case ChangeCreate:
+			status.Creations++
+		case ChangeEdit:
+			status.Edits++
+		case ChangeDelete:
+			status.Deletions++
+		}
+		if c.Error != "" {
+			status.Failed = append(status.Failed, c)
+		}
+	}
+	return status, nil
+}
+
+// RetryChange tries to push the queued change with the id to the server. The id
+// can be shortened as long as it only matches one change. If the push fails,
+// the error is recorded on the change and returned.
+func RetryChange(db Storager, ns NotestoreClient, id string) error {
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return err
+	}
+	i, err := findPendingChange(changes, id)
+	if err != nil {
+		return err
+	}
+	if IsDryRun(ns) {
+		return applyChange(ns, changes[i])
+	}
+	intent, err := beginIntent(db, OpPushChange, changes[i].Note.GUID, changes[i].ID)
+	if err != nil {
+		return err
+	}
+	if err := applyChange(ns, changes[i]); err != nil {
+		changes[i].Error = err.Error()
+		changes[i].Interrupted = false
+		if saveErr := db.SavePendingChanges(changes); saveErr != nil {
+			return saveErr
+		}
+		if endErr := endIntent(db, intent); endErr != nil {
+			return endErr
+		}
+		return err
+	}
+	if err := db.SavePendingChanges(append(changes[:i], changes[i+1:]...)); err != nil {
+		return err
+	}
+	return endIntent(db, intent)
+}
+
+// DiscardChange removes the queued change with the id without pushing it
+// to the server.
+func DiscardChange(db Storager, id string) error {
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return err
+	}
+	i, err := findPendingChange(changes, id)
+	if err != nil {
+		return err
+	}
+	return db.SavePendingChanges(append(changes[:i], changes[i+1:]...))
+}
+
+// Sync pushes all queued changes to the server. If all changes were pushed,
+// the server's state is saved as the last successful sync. Otherwise
+// ErrSyncFailed is returned and the failed changes are kept in the queue.
+// Interrupted changes are not pushed. Each push is recorded in the journal
+// and the change is removed from the queue as soon as it's on the server.
+// If the server can't be reached, the remaining changes are kept in the
+// queue and the error is returned. With a dry run notestore the queue and
+// the sync state are left untouched.
+func Sync(db Storager, ns NotestoreClient) error {
+	if IsDryRun(ns) {
+		return previewChanges(db, ns, func(c *PendingChange) bool { return !c.Interrupted })
+	}
+	remaining, _, err := pushChanges(db, ns, func(c *PendingChange) bool {
+		return !c.Interrupted
+	})
+	if err != nil {
+		return err
+	}
+	if len(remaining) != 0 {
+		LogVerbose("sync incomplete", "remaining", len(remaining))
+		return ErrSyncFailed
+	}
+	state, err := ns.GetSyncState()
+	if err != nil {
+		return err
+	}
+	local, err := db.GetSyncState()
+	if err != nil {
+		return err
+	}
+	state.BacklinkUSN = local.BacklinkUSN
+	return db.SaveSyncState(state)
+}
+
+// ReplayChanges pushes the changes that were queued while the server
+// couldn't be reached. Failed and interrupted changes are left for Sync.
+// The number of pushed changes is returned.
+func ReplayChanges(db Storager, ns NotestoreClient) (int, error) {
+	if IsDryRun(ns) {
+		return 0, nil
+	}
+	_, pushed, err := pushChanges(db, ns, func(c *PendingChange) bool {
+		return !c.Interrupted && c.Error == ""
+	})
+	return pushed, err
+}
+
+// pushChanges pushes the queued changes selected by push to the server and
+// returns the changes left in the queue. Changes that fail are marked with
+// the error. If the server can't be reached, the rest of the queue is kept
+// as is and the error is returned.
+func pushChanges(db Storager, ns NotestoreClient, push func(*PendingChange) bool) ([]*PendingChange, int, error) {
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return nil, 0, err
+	}
+	var remaining []*PendingChange
+	pushed := 0
+	for i, c := range changes {
+		if !push(c) {
+			LogDebug("skipped queued change", "id", c.ID, "type", c.Type, "interrupted", c.Interrupted, "failed", c.Error != "")
+			remaining = append(remaining, c)
+			continue
+		}
+		intent, err := beginIntent(db, OpPushChange, c.Note.GUID, c.ID)
+		if err != nil {
+			return nil, pushed, err
+		}
+		pushErr := applyChange(ns, c)
+		offline := IsOffline(pushErr)
+		LogVerbose("pushed queued change", "id", c.ID, "type", c.Type, "guid", c.Note.GUID, "error", pushErr)
+		switch {
+		case pushErr == nil:
+			pushed++
+			queue := append(append([]*PendingChange{}, remaining...), changes[i+1:]...)
+			if err := db.SavePendingChanges(queue); err != nil {
+				return nil, pushed, err
+			}
+		case offline:
+			remaining = append(remaining, changes[i:]...)
+		default:
+			c.Error = pushErr.Error()
+			remaining = append(remaining, c)
+		}
+		if err := endIntent(db, intent); err != nil {
+			return nil, pushed, err
+		}
+		if offline {
+			LogVerbose("server unreachable, stopped pushing", "remaining", len(remaining))
+			if err := db.SavePendingChanges(remaining); err != nil {
+				return nil, pushed, err
+			}
+			return remaining, pushed, pushErr
+		}
+	}
+	if err := db.SavePendingChanges(remaining); err != nil {
+		return nil, pushed, err
+	}
+	return remaining, pushed, nil
+}
+
+// previewChanges sends the queued changes selected by push to the dry run
+// notestore without changing the queue.
+func previewChanges(db Storager, ns NotestoreClient, push func(*PendingChange) bool) error {
+	changes, err := db.GetPendingChanges()
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if !push(c) {
+			continue
+		}
+		if err := applyChange(ns, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func discardQueuedEdits(db Storager, guid string) error {
+	changes, err := db.GetPendingChanges()
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	var remaining []*PendingChange
+	for _, c := range changes {
+		if c.Type != ChangeEdit || c.Note.GUID != guid {
+			remaining = append(remaining, c)
+		}
+	}
+	if len(remaining) == len(changes) {
+		return nil
+	}
+	return db.SavePendingChanges(remaining)
+}
+
+func findPendingChange(changes []*PendingChange, id string) (int, error) {
+	index := -1
+	for i, c := range changes {
+		if id == "" || !strings.HasPrefix(c.ID, id) {
+			continue
+		}
+		if index != -1 {
+			return -1, ErrAmbiguousChangeID
+		}
+		index = i
+	}
+	if index == -1 {
+		return -1, ErrNoPendingChange
+	}
+	return index, nil
+}
+
+func applyChange(ns NotestoreClient, c *PendingChange) error {
+	switch c.Type {
+	case ChangeCreate:
+		return ns.CreateNote(c.Note)
+	case ChangeEdit:
+		return ns.UpdateNote(c.Note)
+	case ChangeDelete:
+		return ns.DeleteNote(c.Note.GUID)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var expectedError = errors.New("Expected error")
+
+func TestSyncStatus(t *testing.T) {
+	assert := assert.New(t)
+	lastSync := time.Unix(1500000000, 0)
+	db := &mockStore{getSyncState: func() (*SyncState, error) { return &SyncState{UpdateCount: 10, Time: lastSync}, nil }}
+	ns := &mockNS{getSyncState: func() (*SyncState, error) { return &SyncState{UpdateCount: 12}, nil }}
+	assert.NoError(QueueChange(db, ChangeCreate, &Note{Title: "New"}, nil))
+	assert.NoError(QueueChange(db, ChangeEdit, &Note{Title: "Edited", GUID: "GUID"}, errors.New("failed")))
+
+	status, err := GetSyncStatus(db, ns)
+	assert.NoError(err)
+	assert.Equal(lastSync, status.LastSync)
+	assert.Equal(int32(10), status.LocalUSN)
+	assert.Equal(int32(12), status.ServerUSN)
+	assert.Equal(1, status.Creations)
+	assert.Equal(1, status.Edits)
+	assert.Equal(0, status.Deletions)
+	if assert.Len(status.Failed, 1) {
+		assert.Equal("Edited", status.Failed[0].Note.Title)
+		assert.Equal("failed", status.Failed[0].Error)
+	}
+}
+
+func TestRetryAndDiscardChange(t *testing.T) {
+	assert := assert.New(t)
+	var updated *Note
+	updateErr := expectedError
+	ns := &mockNS{updateNote: func(n *Note) error { updated = n; return updateErr }}
+	db := &mockStore{pendingChanges: []*PendingChange{
+		&PendingChange{ID: "aaaa1111", Type: ChangeEdit, Note: &Note{GUID: "GUID1"}},
+		&PendingChange{ID: "aaaa2222", Type: ChangeEdit, Note: &Note{GUID: "GUID2"}},
+	}}
+
+	t.Run("Unknown id", func(t *testing.T) {
+		assert.Equal(ErrNoPendingChange, RetryChange(db, ns, "bbbb"))
+		assert.Equal(ErrNoPendingChange, DiscardChange(db, "bbbb"))
+	})
+
+	t.Run("Ambiguous id", func(t *testing.T) {
+		assert.Equal(ErrAmbiguousChangeID, RetryChange(db, ns, "aaaa"))
+	})
+
+	t.Run("Failed retry", func(t *testing.T) {
+		assert.Equal(expectedError, RetryChange(db, ns, "aaaa1"))
+		assert.Equal("GUID1", updated.GUID)
+		assert.Equal(expectedError.Error(), db.pendingChanges[0].Error)
+		assert.Len(db.pendingChanges, 2)
+	})
+
+	t.Run("Successful retry", func(t *testing.T) {
+		updateErr = nil
+		assert.NoError(RetryChange(db, ns, "aaaa1"))
+		assert.Len(db.pendingChanges, 1)
+		assert.Equal("aaaa2222", db.pendingChanges[0].ID)
+	})
+
+	t.Run("Discard", func(t *testing.T) {
+		assert.NoError(DiscardChange(db, "aaaa2"))
+		assert.Empty(db.pendingChanges)
+	})
+}
+
+func TestSync(t *testing.T) {
+	assert := assert.New(t)
+	var saved *SyncState
+	db := &mockStore{
+		getSyncState:  func() (*SyncState, error) { return &SyncState{UpdateCount: 3, BacklinkUSN: 2}, nil },
+		saveSyncState: func(s *SyncState) error { saved = s; return nil },
+	}
+	deleted := ""
+	ns := &mockNS{
+		createNote:   func(n *Note) error { return expectedError },
+		deleteNote:   func(guid string) error { deleted = guid; return nil },
+		getSyncState: func() (*SyncState, error) { return &SyncState{UpdateCount: 5}, nil },
+	}
+	assert.NoError(QueueChange(db, ChangeDelete, &Note{GUID: "GUID"}, nil))
+	assert.NoError(QueueChange(db, ChangeCreate, &Note{Title: "New"}, nil))
+
+	t.Run("Failed change is kept", func(t *testing.T) {
+		assert.Equal(ErrSyncFailed, Sync(db, ns))
+		assert.Equal("GUID", deleted)
+		assert.Nil(saved, "Sync state should not be saved")
+		if assert.Len(db.pendingChanges, 1) {
+			assert.Equal(ChangeCreate, db.pendingChanges[0].Type)
+			assert.Equal(expectedError.Error(), db.pendingChanges[0].Error)
+		}
+	})
+
+	t.Run("Successful sync", func(t *testing.T) {
+		ns.createNote = func(n *Note) error { return nil }
+		assert.NoError(Sync(db, ns))
+		assert.Empty(db.pendingChanges)
+		assert.Equal(int32(5), saved.UpdateCount)
+		assert.Equal(int32(2), saved.BacklinkUSN, "The backlink index state should be kept")
+	})
+}
+
+// offlineError is the kind of error returned when the server can't be reached.
+var offlineError = &url.Error{Op: "Post", URL: "https://www.evernote.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+
+type wrappedError struct{ err error }
+
+func (e *wrappedError) Error() string { return e.err.Error() }
+func (e *wrappedError) Err() error    { return e.err }
+
+func TestIsOffline(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(IsOffline(offlineError))
+	assert.True(IsOffline(&wrappedError{offlineError}))
+	assert.False(IsOffline(expectedError))
+	assert.False(IsOffline(&wrappedError{expectedError}))
+	assert.False(IsOffline(nil))
+	canceled := &url.Error{Op: "Post", URL: "https://www.evernote.com", Err: context.DeadlineExceeded}
+	assert.False(IsOffline(canceled), "A call past the timeout is not offline")
+	assert.False(IsOffline(&wrappedError{canceled}))
+}
+
+func TestIsCanceled(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(IsCanceled(context.Canceled))
+	assert.True(IsCanceled(&url.Error{Op: "Post", URL: "https://www.evernote.com", Err: context.DeadlineExceeded}))
+	assert.False(IsCanceled(offlineError))
+	assert.False(IsCanceled(nil))
+}
+
+func TestQueueIfOffline(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockStore{}
+	n := &Note{GUID: "GUID"}
+
+	assert.Equal(expectedError, QueueIfOffline(db, ChangeDelete, n, expectedError))
+	assert.Empty(db.pendingChanges)
+
+	assert.Equal(ErrChangeQueued, QueueIfOffline(db, ChangeDelete, n, offlineError))
+	if assert.Len(db.pendingChanges, 1) {
+		assert.Equal(ChangeDelete, db.pendingChanges[0].Type)
+		assert.Empty(db.pendingChanges[0].Error, "Offline changes should not be marked as failed")
+	}
+}
+
+func TestReplayChanges(t *testing.T) {
+	assert := assert.New(t)
+	var created []string
+	createErr := error(offlineError)
+	ns := &mockNS{createNote: func(n *Note) error {
+		if createErr != nil {
+			return createErr
+		}
+		created = append(created, n.Title)
+		return nil
+	}}
+	db := &mockStore{pendingChanges: []*PendingChange{
+		&PendingChange{ID: "1", Type: ChangeCreate, Note: &Note{Title: "Failed"}, Error: "failed"},
+		&PendingChange{ID: "2", Type: ChangeCreate, Note: &Note{Title: "First"}},
+		&PendingChange{ID: "3", Type: ChangeCreate, Note: &Note{Title: "Second"}},
+	}}
+
+	t.Run("Still offline", func(t *testing.T) {
+		n, err := ReplayChanges(db, ns)
+		assert.Equal(offlineError, err)
+		assert.Equal(0, n)
+		assert.Len(db.pendingChanges, 3)
+		assert.Empty(db.pendingChanges[1].Error)
+	})
+
+	t.Run("Online", func(t *testing.T) {
+		createErr = nil
+		n, err := ReplayChanges(db, ns)
+		assert.NoError(err)
+		assert.Equal(2, n)
+		assert.Equal([]string{"First", "Second"}, created)
+		if assert.Len(db.pendingChanges, 1) {
+			assert.Equal("1", db.pendingChanges[0].ID, "Failed changes are left for sync")
+		}
+	})
+}
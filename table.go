@@ -0,0 +1,322 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/olekukonko/tablewriter"
+)
+
+// TableOption are used to control how tables are written.
+type TableOption int32
+
+const (
+	// DefaultTableOption limits the table to the terminal width. Columns are
+	// truncated in priority order if the table is too wide.
+	DefaultTableOption TableOption = 0
+	// WideTable writes the table without limiting it to the terminal width.
+	WideTable TableOption = 1 << iota
+	// NoTruncate wraps the cells of columns that are too wide instead of
+	// truncating them.
+	NoTruncate
+	// Accessible writes each row as a record with one labeled field per
+	// line instead of aligned columns. Colors are not used. It's meant
+	// for screen readers.
+	Accessible
+	// Hyperlinks writes the cells with a link as OSC 8 hyperlinks. It's
+	// ignored if the Accessible option is set.
+	Hyperlinks
+)
+
+// ansiCodes matches the escape codes used for colors.
+var ansiCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Linked cells are marked with escape codes that the table writer treats
+// as zero width while the table is rendered. The markers are replaced with
+// the hyperlinks afterwards.
+var (
+	linkStartMarker = regexp.MustCompile("\x1b\\[8;([0-9]{1,3});([0-9]{1,3})K")
+	linkEndMarker   = "\x1b[8K"
+)
+
+const (
+	// ellipsis is appended to truncated cells.
+	ellipsis = "…"
+	// minColumnWidth is the smallest width a column is shrunk to.
+	minColumnWidth = 3
+)
+
+// Table is a table that is fitted to the width of the output before
+// it's written.
+type Table struct {
+	header []string
+	rows   [][]string
+	footer []string
+	// shrinkOrder holds the indexes of the columns that can be shrunk, in
+	// the order they should be shrunk.
+	shrinkOrder []int
+	opts        TableOption
+	// links holds the URLs of the linked cells, keyed by row and column.
+	links map[[2]int]string
+}
+
+// NewTable creates a new table with the header.
+func NewTable(header []string, opts TableOption) *Table {
+	return &Table{header: header, opts: opts}
+}
+
+// SetShrinkOrder sets the columns that can be shrunk if the table is wider
+// than the output, in the order they are shrunk. Columns not included
+// are never shrunk, and columns the table doesn't have are ignored.
+func (t *Table) SetShrinkOrder(columns ...int) {
+	t.shrinkOrder = columns
+}
+
+// Append adds a row to the table.
+func (t *Table) Append(row []string) {
+	t.rows = append(t.rows, row)
+}
+
+// SetLink links the cell at the row and column to the URL. The link is
+// only written if the Hyperlinks option is set.
+func (t *Table) SetLink(row, col int, link string) {
+	if t.links == nil {
+		t.links = make(map[[2]int]string)
+	}
+	t.links[[2]int{row, col}] = link
+}
+
+// SetFooter sets the table footer.
+func (t *Table) SetFooter(footer []string) {
+	t.footer = footer
+}
+
+// Render writes the table to the writer. If the writer is a terminal, the
+// table is fitted to the terminal width unless the WideTable option is set.
+func (t *Table) Render(w io.Writer) {
+	t.RenderWithWidth(w, TerminalWidth(w))
+}
+
+// RenderWithWidth writes the table to the writer fitted to the width. If the
+// width is 0 or the WideTable option is set, the table isn't fitted.
+func (t *Table) RenderWithWidth(w io.Writer, width int) {
+	if t.opts&Accessible != 0 {
+		t.renderRecords(w)
+		return
+	}
+	rows := t.rows
+	if width > 0 && t.opts&WideTable == 0 {
+		rows = t.fitRows(width)
+	}
+	var links []string
+	out := w
+	var buf bytes.Buffer
+	if t.opts&Hyperlinks != 0 && len(t.links) != 0 {
+		rows, links = t.markLinks(rows)
+		out = &buf
+	}
+	table := tablewriter.NewWriter(out)
+	table.SetAutoWrapText(false)
+	table.SetHeader(t.header)
+	if style := colorTheme(w, t.opts).TableHeader; len(style) != 0 {
+		colors := make([]tablewriter.Colors, len(t.header))
+		for i := range colors {
+			colors[i] = tablewriter.Colors(style)
+		}
+		table.SetHeaderColor(colors...)
+	}
+	table.AppendBulk(rows)
+	if t.footer != nil {
+		table.SetFooter(t.footer)
+	}
+	table.Render()
+	if links != nil {
+		io.WriteString(w, replaceLinkMarkers(buf.String(), links))
+	}
+}
+
+// markLinks returns a copy of the rows with the linked cells marked and
+// the URLs of the markers. Each line of a wrapped cell is marked on its
+// own so the borders between the lines are not part of the link.
+func (t *Table) markLinks(rows [][]string) ([][]string, []string) {
+	var links []string
+	marked := make([][]string, len(rows))
+	for r, row := range rows {
+		marked[r] = append([]string(nil), row...)
+		for i, cell := range row {
+			link, ok := t.links[[2]int{r, i}]
+			if !ok || cell == "" {
+				continue
+			}
+			lines := strings.Split(cell, "\n")
+			for j, line := range lines {
+				if line == "" {
+					continue
+				}
+				idx := len(links)
+				lines[j] = fmt.Sprintf("\x1b[8;%d;%dK%s%s", idx/1000, idx%1000, line, linkEndMarker)
+				links = append(links, link)
+			}
+			marked[r][i] = strings.Join(lines, "\n")
+		}
+	}
+	return marked, links
+}
+
+// replaceLinkMarkers replaces the link markers in the rendered table with
+// OSC 8 hyperlinks.
+func replaceLinkMarkers(s string, links []string) string {
+	s = linkStartMarker.ReplaceAllStringFunc(s, func(m string) string {
+		parts := linkStartMarker.FindStringSubmatch(m)
+		high, _ := strconv.Atoi(parts[1])
+		low, _ := strconv.Atoi(parts[2])
+		idx := high*1000 + low
+		if idx >= len(links) {
+			return ""
+		}
+		return "\x1b]8;;" + links[idx] + "\x1b\\"
+	})
+	return strings.Replace(s, linkEndMarker, "\x1b]8;;\x1b\\", -1)
+}
+
+// renderRecords writes each row as a record with a "label: value" line
+// for each cell. The records are separated by an empty line.
+func (t *Table) renderRecords(w io.Writer) {
+	rows := t.rows
+	if t.footer != nil {
+		rows = append(rows[:len(rows):len(rows)], t.footer)
+	}
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "No entries.")
+		return
+	}
+	for r, row := range rows {
+		if r > 0 {
+			fmt.Fprintln(w)
+		}
+		for i, cell := range row {
+			label := ""
+			if i < len(t.header) {
+				label = t.header[i]
+			}
+			if label == "#" {
+				label = "Number"
+			}
+			fmt.Fprintf(w, "%s: %s\n", label, ansiCodes.ReplaceAllString(cell, ""))
+		}
+	}
+}
+
+// fitRows returns the rows with the cells truncated or wrapped so the table
+// fits in the width.
+func (t *Table) fitRows(width int) [][]string {
+	widths := make([]int, len(t.header))
+	for i, h := range t.header {
+		widths[i] = runewidth.StringWidth(h)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			// Color codes don't take up any space.
+			if i < len(widths) && tablewriter.DisplayWidth(cell) > widths[i] {
+				widths[i] = tablewriter.DisplayWidth(cell)
+			}
+		}
+	}
+	// Each column is padded with a space on each side and separated by a border.
+	available := width - 3*len(widths) - 1
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	for _, col := range t.shrinkOrder {
+		if total <= available {
+			break
+		}
+		if col < 0 || col >= len(widths) {
+			continue
+		}
+		min := runewidth.StringWidth(t.header[col])
+		if min < minColumnWidth {
+			min = minColumnWidth
+		}
+		shrink := total - available
+		if widths[col]-shrink < min {
+			shrink = widths[col] - min
+		}
+		if shrink > 0 {
+			widths[col] -= shrink
+			total -= shrink
+		}
+	}
+
+	rows := make([][]string, len(t.rows))
+	for r, row := range t.rows {
+		rows[r] = make([]string, len(row))
+		for i, cell := range row {
+			if i >= len(widths) || tablewriter.DisplayWidth(cell) <= widths[i] {
+				rows[r][i] = cell
+			} else if t.opts&NoTruncate != 0 {
+				lines, _ := tablewriter.WrapString(cell, widths[i])
+				rows[r][i] = strings.Join(lines, "\n")
+			} else {
+				rows[r][i] = runewidth.Truncate(cell, widths[i], ellipsis)
+			}
+		}
+		if t.opts&NoTruncate != 0 {
+			padLines(rows[r])
+		}
+	}
+	return rows
+}
+
+// padLines adds empty lines to the cells so all the cells in the row have
+// the same number of lines. Otherwise the table writer pads the cells
+// with the wrong width.
+func padLines(row []string) {
+	max := 0
+	for _, cell := range row {
+		if n := strings.Count(cell, "\n"); n > max {
+			max = n
+		}
+	}
+	for i, cell := range row {
+		row[i] = cell + strings.Repeat("\n", max-strings.Count(cell, "\n"))
+	}
+}
+
+// TerminalWidth returns the width of the terminal if the writer is a
+// terminal. The COLUMNS environment variable overrides the width. If
+// the width can't be determined, 0 is returned.
+func TerminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return terminalWidth(f)
+}
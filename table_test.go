@@ -0,0 +1,161 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableFitting(t *testing.T) {
+	assert := assert.New(t)
+	setup := func(opts TableOption) *Table {
+		table := NewTable([]string{"#", "Title", "Notebook"}, opts)
+		table.SetShrinkOrder(2, 1)
+		table.Append([]string{"1", "A long note title", "A long notebook name"})
+		return table
+	}
+
+	t.Run("Shrink by priority", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		setup(DefaultTableOption).RenderWithWidth(buf, 40)
+		assert.Equal(expectedShrunkTable, buf.String())
+	})
+
+	t.Run("Shrink all columns", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		setup(DefaultTableOption).RenderWithWidth(buf, 30)
+		assert.Equal(expectedNarrowTable, buf.String())
+	})
+
+	t.Run("No truncate", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		setup(NoTruncate).RenderWithWidth(buf, 40)
+		assert.Equal(expectedWrappedTable, buf.String())
+	})
+
+	t.Run("Wide", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		setup(WideTable).RenderWithWidth(buf, 30)
+		assert.Equal(expectedWideTable, buf.String())
+	})
+
+	t.Run("Accessible", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		table := setup(Accessible)
+		table.Append([]string{"2", "\x1b[31mRed\x1b[0m", "Other"})
+		table.RenderWithWidth(buf, 30)
+		assert.Equal(expectedAccessibleTable, buf.String())
+	})
+
+	t.Run("Hyperlinks", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		table := setup(Hyperlinks)
+		table.SetLink(0, 1, "https://example.com/note")
+		table.RenderWithWidth(buf, 40)
+		link := Hyperlink("https://example.com/note", "A long note title")
+		assert.Equal(strings.Replace(expectedShrunkTable, "A long note title", link, 1), buf.String())
+	})
+
+	t.Run("Hyperlinks wrapped", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		table := setup(NoTruncate | Hyperlinks)
+		table.SetLink(0, 2, "file:///notes")
+		table.RenderWithWidth(buf, 40)
+		expected := expectedWrappedTable
+		for _, cell := range []string{"A long   |", "notebook |", "name     |"} {
+			text := strings.TrimRight(cell, " |")
+			expected = strings.Replace(expected, cell, Hyperlink("file:///notes", text)+cell[len(text):], 1)
+		}
+		assert.Equal(expected, buf.String())
+	})
+
+	t.Run("Hyperlinks option not set", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		table := setup(DefaultTableOption)
+		table.SetLink(0, 1, "https://example.com/note")
+		table.RenderWithWidth(buf, 40)
+		assert.Equal(expectedShrunkTable, buf.String())
+	})
+
+	t.Run("Accessible empty", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		NewTable([]string{"Title"}, Accessible).RenderWithWidth(buf, 30)
+		assert.Equal("No entries.\n", buf.String())
+	})
+}
+
+func TestWriteFields(t *testing.T) {
+	assert := assert.New(t)
+	fields := [][2]string{{"Host", "www.evernote.com"}, {"Consumer key", "key"}}
+
+	buf := new(bytes.Buffer)
+	WriteFields(buf, fields, DefaultTableOption)
+	assert.Equal("Host:         www.evernote.com\nConsumer key: key\n", buf.String())
+
+	buf.Reset()
+	WriteFields(buf, fields, Accessible)
+	assert.Equal("Host: www.evernote.com\nConsumer key: key\n", buf.String())
+}
+
+func TestTerminalWidth(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0, TerminalWidth(new(bytes.Buffer)), "Buffer is not a terminal")
+}
+
+const expectedShrunkTable = `+---+-------------------+--------------+
+| # |       TITLE       |   NOTEBOOK   |
++---+-------------------+--------------+
+| 1 | A long note title | A long note… |
++---+-------------------+--------------+
+`
+
+const expectedNarrowTable = `+---+-------------+----------+
+| # |    TITLE    | NOTEBOOK |
++---+-------------+----------+
+| 1 | A long not… | A long … |
++---+-------------+----------+
+`
+
+const expectedWrappedTable = `+---+-------------------+----------+
+| # |       TITLE       | NOTEBOOK |
++---+-------------------+----------+
+| 1 | A long note title | A long   |
+|   |                   | notebook |
+|   |                   | name     |
++---+-------------------+----------+
+`
+
+const expectedWideTable = `+---+-------------------+----------------------+
+| # |       TITLE       |       NOTEBOOK       |
++---+-------------------+----------------------+
+| 1 | A long note title | A long notebook name |
++---+-------------------+----------------------+
+`
+
+const expectedAccessibleTable = `Number: 1
+Title: A long note title
+Notebook: A long notebook name
+
+Number: 2
+Title: Red
+Notebook: Other
+`
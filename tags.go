@@ -0,0 +1,264 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultTagSuggestions is the number of tags suggested by SuggestTags.
+const DefaultTagSuggestions = 5
+
+// Tag is a tag that can be applied to notes.
+type Tag struct {
+	// GUID is the unique identifier.
+	GUID string
+	// Name is the tag name.
+	Name string
+}
+
+// TagSuggestion is an existing tag suggested for a note.
+type TagSuggestion struct {
+	// Tag is the suggested tag.
+	Tag *Tag
+	// Score is how well the tag matches the note's keywords.
+	Score float64
+	// Keywords are the keyword phrases in the note that matched the tag.
+	Keywords []string
+}
+
+// Keyword is a keyword phrase extracted from a text.
+type Keyword struct {
+	// Phrase is the keyword phrase, in lower case.
+	Phrase string
+	// Score is the phrase's score. Higher is more relevant.
+	Score float64
+}
+
+// stopWords are the words that separate the keyword phrases.
+var stopWords = make(map[string]bool)
+
+func init() {
+	for _, w := range strings.Fields(`a about above after again against all also am an and any are as at be
+		because been before being below between both but by can could did do does doing down during
+		each few for from further had has have having he her here hers herself him himself his how i
+		if in into is it its itself just me more most my myself no nor not now of off on once only or
+		other our ours ourselves out over own same she should so some such than that the their theirs
+		them themselves then there these they this those through to too under until up very was we
+		were what when where which while who whom why will with would you your yours yourself
+		yourselves`) {
+		stopWords[w] = true
+	}
+}
+
+// ExtractKeywords extracts the keyword phrases from the text with the
+// RAKE algorithm. The text is split into phrases at punctuation and stop
+// words. Each word is scored by the ratio of its degree, the total length
+// of the phrases it's in, to its frequency. A phrase's score is the sum of
+// its words' scores. The keywords are sorted by score, highest first.
+func ExtractKeywords(text string) []*Keyword {
+	var phrases [][]string
+	var phrase []string
+	var word []rune
+	endPhrase := func() {
+		if len(phrase) != 0 {
+			phrases = append(phrases, phrase)
+			phrase = nil
+		}
+	}
+	endWord := func() {
+		w := strings.Trim(string(word), "-'")
+		word = word[:0]
+		if w == "" {
+			return
+		}
+		if stopWords[w] || isNumber(w) {
+			endPhrase()
+			return
+		}
+		phrase = append(phrase, w)
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '\'':
+			word = append(word, r)
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			// Punctuation and symbols end the phrase.
+			endWord()
+			endPhrase()
+		}
+	}
+	endWord()
+	endPhrase()
+
+	frequency := make(map[string]int)
+	degree := make(map[string]int)
+	for _, p := range phrases {
+		for _, w := range p {
+			frequency[w]++
+			degree[w] += len(p)
+		}
+	}
+	scores := make(map[string]float64)
+	for _, p := range phrases {
+		phrase := strings.Join(p, " ")
+		if _, ok := scores[phrase]; ok {
+			continue
+		}
+		score := 0.0
+		for _, w := range p {
+			score += float64(degree[w]) / float64(frequency[w])
+		}
+		scores[phrase] = score
+	}
+	keywords := make([]*Keyword, 0, len(scores))
+	for phrase, score := range scores {
+		keywords = append(keywords, &Keyword{Phrase: phrase, Score: score})
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if keywords[i].Score == keywords[j].Score {
+			return keywords[i].Phrase < keywords[j].Phrase
+		}
+		return keywords[i].Score > keywords[j].Score
+	})
+	return keywords
+}
+
+func isNumber(word string) bool {
+	return strings.IndexFunc(word, func(r rune) bool { return !unicode.IsDigit(r) }) == -1
+}
+
+// SuggestTags returns the note and the existing tags suggested for it, best
+// match first. The keywords are extracted from the note's title and content
+// and a tag is suggested if all the words in its name are part of a
+// keyword phrase. Words in the title count twice. Tags already on the note
+// are not suggested. At most count tags are returned.
+func SuggestTags(db Storager, ns NotestoreClient, title string, count int) (*Note, []*TagSuggestion, error) {
+	n, err := GetNoteWithContent(db, ns, title)
+	if err != nil {
+		return nil, nil, err
+	}
+	tags, err := ns.GetAllTags()
+	if err != nil {
+		return nil, nil, err
+	}
+	n.Tags = noteTagNames(n, tags)
+	return n, suggestTags(n, tags, count), nil
+}
+
+// ApplyTagSuggestions adds the suggested tags to the note. The note's other
+// tags are kept.
+func ApplyTagSuggestions(db Storager, ns NotestoreClient, n *Note, suggestions []*TagSuggestion) error {
+	prev := *n
+	tags := append([]string{}, n.Tags...)
+	for _, s := range suggestions {
+		if !containsName(tags, s.Tag.Name) {
+			tags = append(tags, s.Tag.Name)
+		}
+	}
+	n.Tags = tags
+	// Don't send any content, the server keeps the current content.
+	n.Body = ""
+	return updateNote(db, ns, &prev, n)
+}
+
+func suggestTags(n *Note, tags []*Tag, count int) []*TagSuggestion {
+	keywords := ExtractKeywords(n.MD)
+	for _, k := range ExtractKeywords(n.Title) {
+		keywords = append(keywords, &Keyword{Phrase: k.Phrase, Score: 2 * k.Score})
+	}
+	phrases := make([]map[string]bool, len(keywords))
+	for i, k := range keywords {
+		phrases[i] = stemmedWords(k.Phrase)
+	}
+	var suggestions []*TagSuggestion
+	for _, t := range tags {
+		if containsName(n.Tags, t.Name) {
+			continue
+		}
+		words := stemmedWords(t.Name)
+		if len(words) == 0 {
+			continue
+		}
+		s := &TagSuggestion{Tag: t}
+		for i, k := range keywords {
+			if containsWords(phrases[i], words) {
+				s.Score += k.Score
+				s.Keywords = append(s.Keywords, k.Phrase)
+			}
+		}
+		if s.Score > 0 {
+			suggestions = append(suggestions, s)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score == suggestions[j].Score {
+			return suggestions[i].Tag.Name < suggestions[j].Tag.Name
+		}
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	if count > 0 && len(suggestions) > count {
+		suggestions = suggestions[:count]
+	}
+	return suggestions
+}
+
+// noteTagNames returns the names of the note's tags.
+func noteTagNames(n *Note, tags []*Tag) []string {
+	if n.Tags != nil {
+		return n.Tags
+	}
+	names := []string{}
+	for _, guid := range n.TagGUIDs {
+		for _, t := range tags {
+			if t.GUID == guid {
+				names = append(names, t.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// stemmedWords returns the words in the text, in lower case and with any
+// plural s removed.
+func stemmedWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+			w = w[:len(w)-1]
+		}
+		words[w] = true
+	}
+	return words
+}
+
+func containsWords(phrase, words map[string]bool) bool {
+	for w := range words {
+		if !phrase[w] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractKeywords(t *testing.T) {
+	assert := assert.New(t)
+	keywords := ExtractKeywords("Compatibility of systems of linear constraints. Linear constraints, 2 times!")
+	if assert.Len(keywords, 4) {
+		assert.Equal("linear constraints", keywords[0].Phrase)
+		assert.Equal(4.0, keywords[0].Score)
+		assert.Equal("compatibility", keywords[1].Phrase)
+		assert.Equal("systems", keywords[2].Phrase)
+		assert.Equal("times", keywords[3].Phrase)
+	}
+	assert.Empty(ExtractKeywords("and the of 42"))
+}
+
+func TestSuggestTags(t *testing.T) {
+	assert := assert.New(t)
+	tags := []*Tag{
+		{GUID: "1", Name: "Travel"},
+		{GUID: "2", Name: "Flight booking"},
+		{GUID: "3", Name: "Recipes"},
+		{GUID: "4", Name: "Japan"},
+	}
+	content := XMLHeader + "<en-note><div>Flight bookings for the Japan travel. Book hotels in Tokyo.</div></en-note>"
+	note := &Note{GUID: "GUID", Title: "Japan trip", TagGUIDs: []string{"4"}}
+	var updated *Note
+	ns := &mockNS{
+		findNotes:      func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{note}, nil },
+		getNoteContent: func(string) (string, error) { return content, nil },
+		getAllTags:     func() ([]*Tag, error) { return tags, nil },
+		updateNote:     func(n *Note) error { updated = n; return nil },
+	}
+	db := new(mockStore)
+
+	n, suggestions, err := SuggestTags(db, ns, "Japan trip", DefaultTagSuggestions)
+	assert.NoError(err)
+	assert.Equal([]string{"Japan"}, n.Tags, "Tag names should be looked up")
+	if assert.Len(suggestions, 2) {
+		assert.Equal("Flight booking", suggestions[0].Tag.Name)
+		assert.Equal([]string{"flight bookings"}, suggestions[0].Keywords)
+		assert.Equal("Travel", suggestions[1].Tag.Name)
+	}
+
+	_, limited, err := SuggestTags(db, ns, "Japan trip", 1)
+	assert.NoError(err)
+	assert.Len(limited, 1)
+
+	assert.NoError(ApplyTagSuggestions(db, ns, n, suggestions))
+	if assert.NotNil(updated) {
+		assert.Equal([]string{"Japan", "Flight booking", "Travel"}, updated.Tags)
+		assert.Empty(updated.Body, "Content should not be sent")
+	}
+}
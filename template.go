@@ -0,0 +1,127 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateFolder is the folder, relative to the config folder, where
+// note templates are stored.
+const TemplateFolder = "templates"
+
+// templateExt is the file extension of template files.
+const templateExt = ".md"
+
+// TemplateCommandTimeout is how long a command run by a template may take
+// before it's stopped.
+var TemplateCommandTimeout = 10 * time.Second
+
+// ErrTemplateNotFound is returned if no template exists with the name.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// TemplateData is the data available to a template when it's rendered.
+type TemplateData struct {
+	// Title is the note title.
+	Title string
+	// Notebook is the name of the notebook.
+	Notebook string
+	// Time is the time the note is created.
+	Time time.Time
+}
+
+// TemplatePath returns the path to the template file with the name.
+func TemplatePath(cfgFolder, name string) string {
+	return filepath.Join(cfgFolder, TemplateFolder, name+templateExt)
+}
+
+// LoadTemplate reads the template with the name from the config folder.
+func LoadTemplate(cfgFolder, name string) (string, error) {
+	b, err := ioutil.ReadFile(TemplatePath(cfgFolder, name))
+	if os.IsNotExist(err) {
+		return "", ErrTemplateNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// RenderTemplate executes the template with the data.
+func RenderTemplate(tmpl string, data *TemplateData) (string, error) {
+	t, err := template.New("note").Funcs(templateFuncs(data)).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err = t.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func templateFuncs(data *TemplateData) template.FuncMap {
+	return template.FuncMap{
+		// date formats the time using a Go time layout.
+		"date": func(layout string, t time.Time) string { return t.Format(layout) },
+		// cmd runs the shell command and returns its output.
+		"cmd": func(command string) (string, error) { return runTemplateCommand(command, data) },
+		// env returns the value of the environment variable.
+		"env": os.Getenv,
+	}
+}
+
+// runTemplateCommand runs the shell command in the current directory and
+// returns what it writes to stdout without the trailing newline. The note's
+// title and notebook are passed in the environment variables
+// CLINOTE_NOTE_TITLE and CLINOTE_NOTEBOOK.
+func runTemplateCommand(command string, data *TemplateData) (string, error) {
+	if SafeMode {
+		return "", ErrSafeMode
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TemplateCommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"CLINOTE_NOTE_TITLE="+data.Title,
+		"CLINOTE_NOTEBOOK="+data.Notebook,
+	)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("template command %q failed: %s", command, err)
+	}
+	return strings.TrimRight(out.String(), "\r\n"), nil
+}
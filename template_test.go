@@ -0,0 +1,85 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	assert := assert.New(t)
+	data := &TemplateData{Title: "Standup", Notebook: "Meetings", Time: time.Date(2018, 3, 4, 0, 0, 0, 0, time.UTC)}
+
+	content, err := RenderTemplate("# {{.Title}}\n{{.Notebook}} {{date \"2006-01-02\" .Time}}", data)
+	assert.NoError(err)
+	assert.Equal("# Standup\nMeetings 2018-03-04", content)
+
+	_, err = RenderTemplate("{{.Title", data)
+	assert.Error(err, "Should return a parse error")
+}
+
+func TestRenderTemplateCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need a POSIX shell")
+	}
+	assert := assert.New(t)
+	data := &TemplateData{Title: "Dev log", Notebook: "Work"}
+	os.Setenv("CLINOTE_TEST_PROJECT", "clinote")
+	defer os.Unsetenv("CLINOTE_TEST_PROJECT")
+
+	content, err := RenderTemplate(`{{env "CLINOTE_TEST_PROJECT"}}: {{cmd "echo branch"}}`, data)
+	assert.NoError(err)
+	assert.Equal("clinote: branch", content)
+
+	content, err = RenderTemplate(`{{cmd "echo $CLINOTE_NOTE_TITLE in $CLINOTE_NOTEBOOK"}}`, data)
+	assert.NoError(err)
+	assert.Equal("Dev log in Work", content)
+
+	_, err = RenderTemplate(`{{cmd "exit 1"}}`, data)
+	assert.Error(err, "Should return the command's error")
+
+	SafeMode = true
+	defer func() { SafeMode = false }()
+	_, err = RenderTemplate(`{{cmd "echo branch"}}`, data)
+	if assert.Error(err, "Commands should not run in safe mode") {
+		assert.Contains(err.Error(), ErrSafeMode.Error())
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-template")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(os.Mkdir(filepath.Join(dir, TemplateFolder), 0700))
+	assert.NoError(ioutil.WriteFile(TemplatePath(dir, "meeting"), []byte("content"), 0600))
+
+	tmpl, err := LoadTemplate(dir, "meeting")
+	assert.NoError(err)
+	assert.Equal("content", tmpl)
+
+	_, err = LoadTemplate(dir, "missing")
+	assert.Equal(ErrTemplateNotFound, err)
+}
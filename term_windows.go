@@ -0,0 +1,40 @@
+// +build windows
+
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import "os"
+
+// terminalWidth returns 0 since the terminal width can't be determined.
+// The COLUMNS environment variable can be used to set the width.
+func terminalWidth(f *os.File) int {
+	return 0
+}
+
+// IsTerminal returns true if the file is a character device, such as the
+// console.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// setEcho does nothing since echo can't be turned off.
+func setEcho(f *os.File, on bool) error {
+	return nil
+}
@@ -0,0 +1,183 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ThemeDefault is the theme for terminals with a dark background.
+	ThemeDefault = "default"
+	// ThemeLight is the theme for terminals with a light background.
+	ThemeLight = "light"
+	// ThemeMono only uses bold, dim and underlined text.
+	ThemeMono = "mono"
+	// ThemeNone turns the colors off.
+	ThemeNone = "none"
+)
+
+// ErrUnknownTheme is returned if the theme doesn't exist.
+var ErrUnknownTheme = errors.New("unknown theme, use default, light, mono or none")
+
+// Style is a list of SGR codes, for example 1 for bold and 34 for blue.
+type Style []int
+
+// Paint returns the text wrapped in the style's escape codes. The text is
+// returned unchanged if the style is empty.
+func (s Style) Paint(text string) string {
+	if len(s) == 0 || text == "" {
+		return text
+	}
+	codes := make([]string, len(s))
+	for i, c := range s {
+		codes[i] = strconv.Itoa(c)
+	}
+	return "\033[" + strings.Join(codes, ";") + "m" + text + colorReset
+}
+
+// Theme holds the styles of the colored terminal output.
+type Theme struct {
+	// TableHeader is the style of the listing headers.
+	TableHeader Style
+	// NoteHeader is the style of the title and notebook block shown
+	// before the note content.
+	NoteHeader Style
+	// Heading is the style of Markdown headings.
+	Heading Style
+	// Strong is the style of bold text in rendered notes.
+	Strong Style
+	// Emphasis is the style of italic text in rendered notes.
+	Emphasis Style
+	// Link is the style of links in rendered notes.
+	Link Style
+	// Quote is the style of block quotes in rendered notes.
+	Quote Style
+	// Code is the style of inline code and code blocks.
+	Code Style
+	// Keyword is the style of keywords in code blocks.
+	Keyword Style
+	// String is the style of string literals in code blocks.
+	String Style
+	// Number is the style of numbers in code blocks.
+	Number Style
+	// Comment is the style of comments in code blocks.
+	Comment Style
+	// Overdue is the style of overdue reminders.
+	Overdue Style
+	// DiffAdded is the style of added lines in diffs.
+	DiffAdded Style
+	// DiffRemoved is the style of removed lines in diffs.
+	DiffRemoved Style
+	// DiffHunk is the style of the lines separating the parts of a diff.
+	DiffHunk Style
+}
+
+// Themes are the available themes by name.
+var Themes = map[string]*Theme{
+	ThemeDefault: {
+		TableHeader: Style{1, 36},
+		NoteHeader:  Style{2},
+		Heading:     Style{1, 34},
+		Strong:      Style{1},
+		Emphasis:    Style{3},
+		Link:        Style{4, 34},
+		Quote:       Style{90},
+		Code:        Style{36},
+		Keyword:     Style{35},
+		String:      Style{32},
+		Number:      Style{33},
+		Comment:     Style{90},
+		Overdue:     Style{31},
+		DiffAdded:   Style{32},
+		DiffRemoved: Style{31},
+		DiffHunk:    Style{36},
+	},
+	ThemeLight: {
+		TableHeader: Style{1, 34},
+		NoteHeader:  Style{2},
+		Heading:     Style{1, 34},
+		Strong:      Style{1},
+		Emphasis:    Style{3},
+		Link:        Style{4, 34},
+		Quote:       Style{2},
+		Code:        Style{34},
+		Keyword:     Style{35},
+		String:      Style{32},
+		Number:      Style{31},
+		Comment:     Style{2},
+		Overdue:     Style{1, 31},
+		DiffAdded:   Style{32},
+		DiffRemoved: Style{31},
+		DiffHunk:    Style{34},
+	},
+	ThemeMono: {
+		TableHeader: Style{1},
+		NoteHeader:  Style{2},
+		Heading:     Style{1, 4},
+		Strong:      Style{1},
+		Emphasis:    Style{3},
+		Link:        Style{4},
+		Quote:       Style{2},
+		Keyword:     Style{1},
+		Comment:     Style{2},
+		Overdue:     Style{1},
+		DiffAdded:   Style{1},
+		DiffRemoved: Style{2},
+		DiffHunk:    Style{4},
+	},
+	ThemeNone: {},
+}
+
+// OutputTheme is the theme of the colored output. It's set from the
+// output.theme setting.
+var OutputTheme = Themes[ThemeDefault]
+
+// ParseTheme validates the theme name. An empty name is the default theme.
+func ParseTheme(s string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return ThemeDefault, nil
+	}
+	if _, ok := Themes[name]; !ok {
+		return "", ErrUnknownTheme
+	}
+	return name, nil
+}
+
+// SetOutputTheme sets the theme of the colored output.
+func SetOutputTheme(name string) error {
+	name, err := ParseTheme(name)
+	if err != nil {
+		return err
+	}
+	OutputTheme = Themes[name]
+	return nil
+}
+
+// colorTheme returns the output theme if colors are used for the writer.
+// Otherwise, a theme without styles is returned.
+func colorTheme(w io.Writer, opts TableOption) *Theme {
+	if !useColor(w) || opts&Accessible != 0 || OutputTheme == nil {
+		return Themes[ThemeNone]
+	}
+	return OutputTheme
+}
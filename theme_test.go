@@ -0,0 +1,85 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStylePaint(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("\033[1;34mtext\033[0m", Style{1, 34}.Paint("text"))
+	assert.Equal("text", Style{}.Paint("text"), "Empty style should not add codes")
+	assert.Equal("", Style{1}.Paint(""))
+}
+
+func TestSetOutputTheme(t *testing.T) {
+	assert := assert.New(t)
+	defer func() { OutputTheme = Themes[ThemeDefault] }()
+
+	name, err := ParseTheme(" Light ")
+	assert.NoError(err)
+	assert.Equal(ThemeLight, name)
+	name, err = ParseTheme("")
+	assert.NoError(err)
+	assert.Equal(ThemeDefault, name)
+	_, err = ParseTheme("rainbow")
+	assert.Equal(ErrUnknownTheme, err)
+
+	assert.NoError(SetOutputTheme("mono"))
+	assert.Equal(Themes[ThemeMono], OutputTheme)
+	assert.Equal(ErrUnknownTheme, SetOutputTheme("rainbow"))
+	assert.Equal(Themes[ThemeMono], OutputTheme, "The theme should not change on error")
+
+	assert.Equal(Themes[ThemeNone], colorTheme(new(bytes.Buffer), DefaultTableOption), "No colors if the writer isn't a terminal")
+}
+
+func TestPaintMarkdown(t *testing.T) {
+	assert := assert.New(t)
+	theme := &Theme{Heading: Style{1}, Code: Style{2}, Keyword: Style{3}, String: Style{4}, Number: Style{5}, Comment: Style{6}}
+	md := "# Title\n\nUse `go test` to test.\n#hashtag\n```go\nfunc main() {\n\tfmt.Println(\"hi\", 42) // say hi\n}\n```\n## Done"
+	expected := "\033[1m# Title\033[0m\n\nUse \033[2m`go test`\033[0m to test.\n#hashtag\n" +
+		"\033[2m```go\033[0m\n" +
+		"\033[3mfunc\033[0m main() {\n" +
+		"\tfmt.Println(\033[4m\"hi\"\033[0m, \033[5m42\033[0m) \033[6m// say hi\033[0m\n" +
+		"}\n" +
+		"\033[2m```\033[0m\n" +
+		"\033[1m## Done\033[0m"
+	assert.Equal(expected, paintMarkdown(md, theme))
+	assert.Equal(md, paintMarkdown(md, Themes[ThemeNone]), "No theme should leave the Markdown unchanged")
+}
+
+func TestHighlightCode(t *testing.T) {
+	assert := assert.New(t)
+	theme := &Theme{Keyword: Style{3}, String: Style{4}, Comment: Style{6}}
+	assert.Equal("x = \033[4m'it\\'s'\033[0m \033[6m# note\033[0m", highlightCode(`x = 'it\'s' # note`, theme))
+	assert.Equal("\033[3mreturn\033[0m returned", highlightCode("return returned", theme), "Only whole words are keywords")
+	assert.Equal("a[#1]", highlightCode("a[#1]", theme), "A hash inside an expression isn't a comment")
+}
+
+func TestPaintDiffLine(t *testing.T) {
+	assert := assert.New(t)
+	theme := &Theme{DiffAdded: Style{32}, DiffRemoved: Style{31}, DiffHunk: Style{36}}
+	assert.Equal("\033[32m+added\033[0m", paintDiffLine("+added", theme))
+	assert.Equal("\033[31m-removed\033[0m", paintDiffLine("-removed", theme))
+	assert.Equal("\033[36m@@\033[0m", paintDiffLine("@@", theme))
+	assert.Equal(" same", paintDiffLine(" same", theme))
+}
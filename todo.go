@@ -0,0 +1,166 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrTodoNotFound is returned if the note doesn't have a todo with the
+// number.
+var ErrTodoNotFound = errors.New("the note doesn't have a todo with that number")
+
+// checkedAttr matches the checked attribute of an en-todo element.
+var checkedAttr = regexp.MustCompile(`\bchecked\s*=\s*("[^"]*"|'[^']*')`)
+
+// Todo is a checkbox, an en-todo element, in a note.
+type Todo struct {
+	// Note is the note with the todo, without its content.
+	Note *Note
+	// Number is the position of the todo in the note, starting at 1.
+	Number int
+	// Text is the text after the checkbox.
+	Text string
+	// Checked is true if the todo is checked.
+	Checked bool
+}
+
+// todoBlockElements end the text of a todo.
+var todoBlockElements = toSet("div", "p", "li", "br", "ul", "ol", "table", "tr", "td", "th",
+	"h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "hr", "en-note")
+
+// NoteTodos returns the todos in the note content. The text of a todo is
+// the text after the checkbox up to the end of the line or the next
+// checkbox.
+func NoteTodos(content string) []*Todo {
+	var todos []*Todo
+	var text []string
+	var current *Todo
+	end := func() {
+		if current != nil {
+			current.Text = strings.Join(strings.Fields(strings.Join(text, "")), " ")
+		}
+		current, text = nil, nil
+	}
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			end()
+			return todos
+		case html.TextToken:
+			if current != nil {
+				text = append(text, string(z.Text()))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) == "en-todo" {
+				if tt == html.EndTagToken {
+					continue
+				}
+				end()
+				current = &Todo{Number: len(todos) + 1}
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					if string(key) == "checked" {
+						current.Checked = strings.EqualFold(string(val), "true")
+					}
+				}
+				todos = append(todos, current)
+			} else if todoBlockElements[string(name)] {
+				end()
+			}
+		}
+	}
+}
+
+// FindTodos returns the unchecked todos in the notes. The content is read
+// using the content cache. The progress function is called after each
+// note, if not nil.
+func FindTodos(db Storager, ns NotestoreClient, notes []*Note, progress func(*Note)) ([]*Todo, error) {
+	var todos []*Todo
+	for _, n := range notes {
+		content, err := getCachedNoteContent(db, ns, n)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range NoteTodos(content) {
+			if !t.Checked {
+				t.Note = n
+				todos = append(todos, t)
+			}
+		}
+		if progress != nil {
+			progress(n)
+		}
+	}
+	return todos, nil
+}
+
+// SetTodo checks or unchecks the todo with the number, starting at 1, in
+// the note. Only the todo's element is changed in the content. The note
+// isn't updated if the todo already has the state. The todo is returned
+// with the state it had before the change, also if the change was queued
+// and ErrChangeQueued is returned.
+func SetTodo(db Storager, ns NotestoreClient, title string, number int, checked bool) (*Todo, error) {
+	n, err := getNoteExactWithContent(db, ns, title)
+	if err != nil {
+		return nil, err
+	}
+	todos := NoteTodos(n.Body)
+	if number < 1 || number > len(todos) {
+		return nil, ErrTodoNotFound
+	}
+	todo := todos[number-1]
+	todo.Note = n
+	if todo.Checked == checked {
+		return todo, nil
+	}
+	elems := enTodoPattern.FindAllStringSubmatchIndex(n.Body, -1)
+	if len(elems) != len(todos) {
+		return nil, ErrTodoNotFound
+	}
+	m := elems[number-1]
+	prev := *n
+	n.Body = n.Body[:m[0]] + todoElement(n.Body[m[2]:m[3]], checked) + n.Body[m[1]:]
+	if err = updateNoteContent(db, ns, &prev, n, RawNote); err != nil && err != ErrChangeQueued {
+		return nil, err
+	}
+	return todo, err
+}
+
+// todoElement returns the en-todo element with the attributes and the
+// checked state.
+func todoElement(attrs string, checked bool) string {
+	value := `checked="false"`
+	if checked {
+		value = `checked="true"`
+	}
+	if checkedAttr.MatchString(attrs) {
+		attrs = checkedAttr.ReplaceAllLiteralString(attrs, value)
+	} else {
+		attrs += " " + value
+	}
+	return "<en-todo" + strings.TrimRight(attrs, " ") + "/>"
+}
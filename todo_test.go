@@ -0,0 +1,91 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoteTodos(t *testing.T) {
+	assert := assert.New(t)
+	content := XMLHeader + `<en-note><div><en-todo checked="false"/>Buy <b>milk</b></div>` +
+		`<div><en-todo checked="true"/>Call&nbsp;bank</div>` +
+		`<ul><li><en-todo/>First<en-todo checked="false"></en-todo>Second</li></ul>` +
+		`<div>Not a todo</div></en-note>`
+	todos := NoteTodos(content)
+	if assert.Len(todos, 4) {
+		assert.Equal(&Todo{Number: 1, Text: "Buy milk"}, todos[0])
+		assert.Equal(&Todo{Number: 2, Text: "Call bank", Checked: true}, todos[1])
+		assert.Equal(&Todo{Number: 3, Text: "First"}, todos[2])
+		assert.Equal(&Todo{Number: 4, Text: "Second"}, todos[3])
+	}
+}
+
+func TestFindTodos(t *testing.T) {
+	assert := assert.New(t)
+	contents := map[string]string{
+		"1": XMLHeader + `<en-note><div><en-todo checked="true"/>Done</div><div><en-todo/>Open</div></en-note>`,
+		"2": XMLHeader + `<en-note><div>No todos</div></en-note>`,
+	}
+	ns := &mockNS{getNoteContent: func(guid string) (string, error) { return contents[guid], nil }}
+	notes := []*Note{{GUID: "1", Title: "One"}, {GUID: "2", Title: "Two"}}
+	read := 0
+	todos, err := FindTodos(new(mockStore), ns, notes, func(*Note) { read++ })
+	assert.NoError(err)
+	assert.Equal(2, read)
+	assert.Equal([]*Todo{{Note: notes[0], Number: 2, Text: "Open"}}, todos)
+}
+
+func TestSetTodo(t *testing.T) {
+	assert := assert.New(t)
+	content := XMLHeader + `<en-note><div><en-todo checked="true"/>Done</div><div><en-todo/>Open</div><div>Text &amp; more</div></en-note>`
+	var updated *Note
+	ns := &mockNS{
+		findNotes: func(f *NoteFilter, offset, count int) ([]*Note, error) {
+			return []*Note{{GUID: "1", Title: "Tasks"}}, nil
+		},
+		getNoteContent: func(guid string) (string, error) { return content, nil },
+		updateNote:     func(n *Note) error { updated = n; return nil },
+	}
+
+	todo, err := SetTodo(new(mockStore), ns, "Tasks", 2, true)
+	assert.NoError(err)
+	assert.Equal("Open", todo.Text)
+	assert.False(todo.Checked)
+	if assert.NotNil(updated) {
+		assert.Equal(XMLHeader+`<en-note><div><en-todo checked="true"/>Done</div><div><en-todo checked="true"/>Open</div><div>Text &amp; more</div></en-note>`, updated.Body)
+	}
+
+	updated = nil
+	todo, err = SetTodo(new(mockStore), ns, "Tasks", 1, true)
+	assert.NoError(err)
+	assert.True(todo.Checked)
+	assert.Nil(updated, "Should not update a todo that already has the state")
+
+	_, err = SetTodo(new(mockStore), ns, "Tasks", 3, false)
+	assert.Equal(ErrTodoNotFound, err)
+
+	db := &mockUndoStore{mockStore: &mockStore{}}
+	_, err = SetTodo(db, ns, "Tasks", 1, false)
+	assert.NoError(err)
+	if assert.Len(db.entries, 1, "The change should be added to the undo log") {
+		assert.Contains(db.entries[0].Note.Body, `<en-todo checked="true"/>Done`)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultExpiryWarning is the number of days before a credential expires
+// when the user is warned about it.
+const DefaultExpiryWarning = 7
+
+// TokenInfo is the information encoded in an Evernote access token.
+type TokenInfo struct {
+	// Shard is the shard the user's notes are stored on.
+	Shard string
+	// UserID is the ID of the user.
+	UserID int64
+	// Expires is when the token expires. Zero if not known.
+	Expires time.Time
+}
+
+// ParseToken parses the fields of an Evernote access token. Both OAuth
+// and developer tokens have the form "S=s1:U=8f:E=15e...:C=...".
+func ParseToken(token string) *TokenInfo {
+	info := new(TokenInfo)
+	for _, field := range strings.Split(token, ":") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "S":
+			info.Shard = kv[1]
+		case "U":
+			info.UserID, _ = strconv.ParseInt(kv[1], 16, 64)
+		case "E":
+			if ms, err := strconv.ParseInt(kv[1], 16, 64); err == nil && ms > 0 {
+				info.Expires = fromMillis(ms)
+			}
+		}
+	}
+	return info
+}
+
+// ExpiresAt returns when the credential expires. If the expiry time isn't
+// stored with the credential, it's read from the token. A zero time is
+// returned if it's not known.
+func (c *Credential) ExpiresAt() time.Time {
+	if c.Expires != 0 {
+		return fromMillis(c.Expires)
+	}
+	return ParseToken(c.Secret).Expires
+}
+
+// ExpiryWarning returns a warning if the credential expires within the
+// number of days or has expired. An empty string is returned otherwise.
+func ExpiryWarning(c *Credential, now time.Time, days int) string {
+	if c == nil || days <= 0 {
+		return ""
+	}
+	expires := c.ExpiresAt()
+	if expires.IsZero() {
+		return ""
+	}
+	left := expires.Sub(now)
+	if left <= 0 {
+		return fmt.Sprintf("The credential %q expired on %s, log in again to renew it.", c.DisplayName(), expires.Format(timeFormat))
+	}
+	if left > time.Duration(days)*24*time.Hour {
+		return ""
+	}
+	return fmt.Sprintf("The credential %q expires in %s, log in again to renew it.", c.DisplayName(), FormatTimeLeft(left))
+}
+
+// FormatTimeLeft formats the duration in days, or hours if it's less than
+// a day.
+func FormatTimeLeft(d time.Duration) string {
+	if d < 24*time.Hour {
+		h := int(d.Hours())
+		if h == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", h)
+	}
+	days := int(d.Hours() / 24)
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
@@ -0,0 +1,63 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseToken(t *testing.T) {
+	assert := assert.New(t)
+	expires := time.Date(2018, 3, 12, 9, 0, 0, 0, time.UTC)
+	token := "S=s1:U=8f:E=" + strconv.FormatInt(toMillis(expires), 16) + ":C=16200e15a28:P=1cd:A=en-devtoken:V=2:H=abc"
+
+	info := ParseToken(token)
+	assert.Equal("s1", info.Shard)
+	assert.Equal(int64(0x8f), info.UserID)
+	assert.True(expires.Equal(info.Expires))
+
+	info = ParseToken("not a token")
+	assert.Equal(&TokenInfo{}, info)
+}
+
+func TestExpiryWarning(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2018, 3, 12, 9, 0, 0, 0, time.UTC)
+	cred := &Credential{Name: "OAuth", Label: "Work", Expires: toMillis(now.Add(72 * time.Hour))}
+
+	assert.Equal(now.Add(72*time.Hour).Unix(), cred.ExpiresAt().Unix())
+	assert.Equal("The credential \"Work\" expires in 3 days, log in again to renew it.", ExpiryWarning(cred, now, 7))
+	assert.Empty(ExpiryWarning(cred, now, 2), "Not within the warning period")
+	assert.Empty(ExpiryWarning(cred, now, -1), "Warning turned off")
+	assert.Equal("The credential \"Work\" expired on 2018-03-15, log in again to renew it.", ExpiryWarning(cred, now.Add(96*time.Hour), 7))
+	assert.Empty(ExpiryWarning(&Credential{Secret: "secret"}, now, 7), "Unknown expiry")
+	assert.Empty(ExpiryWarning(nil, now, 7))
+
+	token := &Credential{Name: "Developer token", Secret: "S=s1:U=1:E=" + strconv.FormatInt(toMillis(now.Add(time.Hour)), 16)}
+	assert.Equal("The credential \"Developer token\" expires in 1 hour, log in again to renew it.", ExpiryWarning(token, now, 7))
+}
+
+func TestFormatTimeLeft(t *testing.T) {
+	assert.Equal(t, "5 hours", FormatTimeLeft(5*time.Hour+30*time.Minute))
+	assert.Equal(t, "1 day", FormatTimeLeft(30*time.Hour))
+	assert.Equal(t, "12 days", FormatTimeLeft(12*24*time.Hour))
+}
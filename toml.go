@@ -0,0 +1,192 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// TOMLError is returned when a TOML file can't be parsed.
+type TOMLError struct {
+	// Line is the line number, starting at 1, of the error.
+	Line int
+	// Msg describes the error.
+	Msg string
+}
+
+func (e *TOMLError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// tomlErrorPrefix matches the position the TOML decoder adds to its errors.
+var tomlErrorPrefix = regexp.MustCompile(`^toml: line \d+( \(last key "[^"]*"\))?: `)
+
+// parseTOML parses the config file. The tables are flattened and their keys
+// are returned joined with a dot, for example "sync.include" for the key
+// include in the sync table. Arrays of tables aren't used by the config
+// files and are rejected.
+func parseTOML(r io.Reader) (map[string]interface{}, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if _, err = toml.Decode(string(data), &doc); err != nil {
+		pe, ok := err.(toml.ParseError)
+		if !ok {
+			return nil, err
+		}
+		// Errors at the end of the file have no line.
+		line := pe.Position.Line
+		if line == 0 {
+			line = strings.Count(strings.TrimRight(string(data), "\n"), "\n") + 1
+		}
+		return nil, &TOMLError{line, tomlErrorPrefix.ReplaceAllString(pe.Error(), "")}
+	}
+	vals := make(map[string]interface{})
+	if err := flattenTOML(vals, "", doc); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// flattenTOML adds the values in the table to vals with the prefix added
+// to their keys.
+func flattenTOML(vals map[string]interface{}, prefix string, table map[string]interface{}) error {
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := prefix + k
+		switch v := table[k].(type) {
+		case map[string]interface{}:
+			if err := flattenTOML(vals, key+".", v); err != nil {
+				return err
+			}
+		case []map[string]interface{}:
+			return fmt.Errorf("%s: arrays of tables aren't supported", key)
+		default:
+			if _, ok := vals[key]; ok {
+				return fmt.Errorf("duplicate key %s", key)
+			}
+			vals[key] = v
+		}
+	}
+	return nil
+}
+
+// The functions below are used to edit the config file without losing
+// its comments and layout. The file is parsed with parseTOML.
+
+// assignIndex returns the index of the equals sign of the key = value line,
+// or -1 if the line doesn't assign a key. Quoted keys can contain equals
+// signs.
+func assignIndex(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && c == '=':
+			return i
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		}
+	}
+	return -1
+}
+
+// stripComment removes a comment that is not part of a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && c == '#':
+			return strings.TrimSpace(line[:i])
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		}
+	}
+	return line
+}
+
+// openBrackets returns the number of unclosed brackets outside of strings.
+func openBrackets(line string) int {
+	var quote byte
+	n := 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		case quote == 0 && c == '[':
+			n++
+		case quote == 0 && c == ']':
+			n--
+		}
+	}
+	return n
+}
+
+func unquoteKey(key string) string {
+	if len(key) > 1 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// tomlStringList converts a TOML value to a list of strings. A single
+// string is returned as a list with one element.
+func tomlStringList(val interface{}) ([]string, bool) {
+	switch v := val.(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, s)
+		}
+		return list, true
+	}
+	return nil, false
+}
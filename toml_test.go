@@ -0,0 +1,99 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTOML(t *testing.T) {
+	assert := assert.New(t)
+	input := `# Comment
+title = "A \"quoted\" # title" # trailing comment
+literal = 'C:\path'
+count = 1_000
+ratio = 0.5
+enabled = true
+tags = [
+  "a", # first
+  "b",
+]
+
+[sync]
+include = ["Work", "Home"]
+"quoted key" = false
+`
+	vals, err := parseTOML(strings.NewReader(input))
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"title":           `A "quoted" # title`,
+		"literal":         `C:\path`,
+		"count":           int64(1000),
+		"ratio":           0.5,
+		"enabled":         true,
+		"tags":            []interface{}{"a", "b"},
+		"sync.include":    []interface{}{"Work", "Home"},
+		"sync.quoted key": false,
+	}, vals)
+}
+
+func TestParseTOMLKeys(t *testing.T) {
+	assert := assert.New(t)
+	input := `"a = b" = 1
+notebook.default = "Inbox"
+alias = { cmd = { ls = "note list" } }
+`
+	vals, err := parseTOML(strings.NewReader(input))
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"a = b":            int64(1),
+		"notebook.default": "Inbox",
+		"alias.cmd.ls":     "note list",
+	}, vals)
+
+	_, err = parseTOML(strings.NewReader("[[alias]]\ncmd = 1\n"))
+	assert.EqualError(err, "alias: arrays of tables aren't supported")
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{"missing value", "a =", 1},
+		{"no key", "\n= 1", 2},
+		{"invalid value", "a = yes", 1},
+		{"unterminated string", "a = \"abc", 1},
+		{"duplicate key", "a = 1\na = 2", 2},
+		{"unterminated array", "a = [1,\n2", 1},
+		{"trailing data", "a = \"b\" c", 1},
+		{"invalid table", "[a", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTOML(strings.NewReader(test.input))
+			if assert.IsType(t, &TOMLError{}, err) {
+				assert.Equal(t, test.line, err.(*TOMLError).Line)
+			}
+		})
+	}
+}
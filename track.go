@@ -0,0 +1,257 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrackingTitlePrefix is the title prefix of the time tracking notes. The
+// project name follows the prefix.
+const TrackingTitlePrefix = "Time tracking: "
+
+var (
+	// ErrNoTrackingProject is returned if no project name is given.
+	ErrNoTrackingProject = errors.New("no project given")
+	// ErrTrackingNotRunning is returned if time tracking is stopped but no
+	// timer is running.
+	ErrTrackingNotRunning = errors.New("no time tracking is running")
+)
+
+// TrackingEntry is a tracked period of time. Stop is zero if the timer is
+// still running.
+type TrackingEntry struct {
+	Start time.Time
+	Stop  time.Time
+}
+
+// Duration returns the time tracked by the entry. A running entry is
+// counted until now.
+func (e *TrackingEntry) Duration(now time.Time) time.Duration {
+	if e.Stop.IsZero() {
+		return now.Sub(e.Start)
+	}
+	return e.Stop.Sub(e.Start)
+}
+
+// TrackingSummary is the time tracked for a project.
+type TrackingSummary struct {
+	Project  string
+	Entries  int
+	Duration time.Duration
+	// Running is true if the project's timer is running.
+	Running bool
+}
+
+// TrackingNoteTitle returns the title of the project's time tracking note.
+func TrackingNoteTitle(project string) string {
+	return TrackingTitlePrefix + project
+}
+
+// StartTracking starts the timer for the project. A start entry is appended
+// to the project's tracking note, which is created in the notebook if it
+// doesn't exist. An empty notebook uses the default notebook. If another
+// project's timer is running, it's stopped first and its name is returned.
+func StartTracking(db Storager, ns NotestoreClient, project, notebook string, now time.Time) (string, error) {
+	project = strings.TrimSpace(project)
+	if project == "" {
+		return "", ErrNoTrackingProject
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		return "", err
+	}
+	stopped := settings.TrackingProject
+	if stopped == project {
+		// Already running, nothing to do.
+		return "", nil
+	}
+	if stopped != "" {
+		if _, _, err = StopTracking(db, ns, now); err != nil {
+			return "", err
+		}
+		if settings, err = db.GetSettings(); err != nil {
+			return "", err
+		}
+	}
+	if err = appendTrackingLine(db, ns, project, notebook, "start "+now.Format(time.RFC3339)); err != nil {
+		return "", err
+	}
+	settings.TrackingProject = project
+	return stopped, db.StoreSettings(settings)
+}
+
+// StopTracking stops the running timer. The stop entry is appended to the
+// project's tracking note. The project and the tracked time are returned.
+func StopTracking(db Storager, ns NotestoreClient, now time.Time) (string, time.Duration, error) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return "", 0, err
+	}
+	project := settings.TrackingProject
+	if project == "" {
+		return "", 0, ErrTrackingNotRunning
+	}
+	n, err := getNoteExactWithContent(db, ns, TrackingNoteTitle(project))
+	if err != nil {
+		return "", 0, err
+	}
+	var d time.Duration
+	if entries := ParseTrackingEntries(n.MD); len(entries) > 0 && entries[len(entries)-1].Stop.IsZero() {
+		d = now.Sub(entries[len(entries)-1].Start).Truncate(time.Second)
+	}
+	n.MD = appendLine(n.MD, "stop "+now.Format(time.RFC3339)+" ("+d.String()+")")
+	if err = SaveChanges(ns, n, DefaultNoteOption); err != nil {
+		return "", 0, err
+	}
+	settings.TrackingProject = ""
+	return project, d, db.StoreSettings(settings)
+}
+
+// TrackingReport summarizes the time tracked since the time. If the project
+// is empty, all projects are included. Entries overlapping the start of the
+// period are only counted from the start.
+func TrackingReport(db Storager, ns NotestoreClient, project string, since, now time.Time) ([]*TrackingSummary, error) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	var notes []*Note
+	if project != "" {
+		n, err := GetNoteExact(db, ns, TrackingNoteTitle(project), "")
+		if err != nil {
+			return nil, err
+		}
+		notes = []*Note{n}
+	} else {
+		filter := &NoteFilter{Words: "intitle:\"" + strings.TrimSpace(TrackingTitlePrefix) + "\""}
+		found, err := FindAllNotes(ns, filter, 50)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range found {
+			if strings.HasPrefix(n.Title, TrackingTitlePrefix) {
+				notes = append(notes, n)
+			}
+		}
+	}
+	var summaries []*TrackingSummary
+	for _, n := range notes {
+		content, err := getCachedNoteContent(db, ns, n)
+		if err != nil {
+			return nil, err
+		}
+		if err = parseNoteContent(content, n); err != nil {
+			return nil, err
+		}
+		sum := &TrackingSummary{Project: strings.TrimPrefix(n.Title, TrackingTitlePrefix)}
+		sum.Running = sum.Project == settings.TrackingProject
+		for _, e := range ParseTrackingEntries(n.MD) {
+			end := e.Stop
+			if end.IsZero() {
+				end = now
+			}
+			if !end.After(since) {
+				continue
+			}
+			start := e.Start
+			if start.Before(since) {
+				start = since
+			}
+			sum.Entries++
+			sum.Duration += end.Sub(start)
+		}
+		if sum.Entries > 0 {
+			summaries = append(summaries, sum)
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Duration > summaries[j].Duration
+	})
+	return summaries, nil
+}
+
+// ParseTrackingEntries parses the start and stop entries in the content of
+// a tracking note. Lines that aren't entries are ignored.
+func ParseTrackingEntries(md string) []*TrackingEntry {
+	var entries []*TrackingEntry
+	for _, line := range strings.Split(md, "\n") {
+		fields := strings.Fields(strings.TrimLeft(strings.TrimSpace(line), "*- "))
+		if len(fields) < 2 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "start":
+			entries = append(entries, &TrackingEntry{Start: t})
+		case "stop":
+			if len(entries) > 0 && entries[len(entries)-1].Stop.IsZero() {
+				entries[len(entries)-1].Stop = t
+			}
+		}
+	}
+	return entries
+}
+
+// StartOfWeek returns the start of the week, Monday at midnight, for the time.
+func StartOfWeek(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -days).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func appendTrackingLine(db Storager, ns NotestoreClient, project, notebook, line string) error {
+	title := TrackingNoteTitle(project)
+	n, err := getNoteExactWithContent(db, ns, title)
+	if err == nil {
+		n.MD = appendLine(n.MD, line)
+		return SaveChanges(ns, n, DefaultNoteOption)
+	}
+	if err != ErrNoNoteFound {
+		return err
+	}
+	n = &Note{Title: title, MD: appendLine("", line)}
+	if notebook == "" {
+		settings, err := db.GetSettings()
+		if err != nil {
+			return err
+		}
+		notebook = settings.DefaultNotebook
+	}
+	if notebook != "" {
+		if n.Notebook, err = FindNotebook(db, ns, notebook); err != nil {
+			return err
+		}
+	}
+	return SaveNewNote(ns, n, false)
+}
+
+// appendLine adds the line to the content as a list item.
+func appendLine(md, line string) string {
+	md = strings.TrimRight(md, "\n")
+	if md != "" {
+		md += "\n"
+	}
+	return md + "* " + line + "\n"
+}
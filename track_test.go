@@ -0,0 +1,155 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracking(t *testing.T) {
+	assert := assert.New(t)
+	settings := new(Settings)
+	db := &mockStore{
+		getSettings:   func() (*Settings, error) { return settings, nil },
+		storeSettings: func(s *Settings) error { settings = s; return nil },
+	}
+	var notes []*Note
+	content := make(map[string]string)
+	ns := &mockNS{
+		findNotes: func(filter *NoteFilter, offset, count int) ([]*Note, error) {
+			if offset > 0 {
+				return nil, nil
+			}
+			var found []*Note
+			for _, n := range notes {
+				if filter.Words == n.Title || filter.Words == "intitle:\"Time tracking:\"" {
+					cpy := *n
+					found = append(found, &cpy)
+				}
+			}
+			return found, nil
+		},
+		getNoteContent: func(guid string) (string, error) { return content[guid], nil },
+		createNote: func(n *Note) error {
+			n.GUID = n.Title
+			content[n.GUID] = n.Body
+			notes = append(notes, &Note{Title: n.Title, GUID: n.GUID})
+			return nil
+		},
+		updateNote: func(n *Note) error {
+			content[n.GUID] = n.Body
+			return nil
+		},
+	}
+	monday := time.Date(2018, 3, 12, 9, 0, 0, 0, time.UTC)
+
+	t.Run("start and stop", func(t *testing.T) {
+		stopped, err := StartTracking(db, ns, " Project X ", "", monday.Add(-72*time.Hour))
+		assert.NoError(err)
+		assert.Empty(stopped)
+		assert.Equal("Project X", settings.TrackingProject)
+		project, d, err := StopTracking(db, ns, monday.Add(-70*time.Hour))
+		assert.NoError(err)
+		assert.Equal("Project X", project)
+		assert.Equal(2*time.Hour, d)
+		assert.Empty(settings.TrackingProject)
+
+		_, _, err = StopTracking(db, ns, monday)
+		assert.Equal(ErrTrackingNotRunning, err)
+	})
+
+	t.Run("switch project", func(t *testing.T) {
+		_, err := StartTracking(db, ns, "Project X", "", monday)
+		assert.NoError(err)
+		stopped, err := StartTracking(db, ns, "Project Y", "", monday.Add(90*time.Minute))
+		assert.NoError(err)
+		assert.Equal("Project X", stopped)
+		assert.Equal("Project Y", settings.TrackingProject)
+		assert.Len(notes, 2)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		now := monday.Add(2 * time.Hour)
+		summaries, err := TrackingReport(db, ns, "", StartOfWeek(now), now)
+		assert.NoError(err)
+		assert.Equal([]*TrackingSummary{
+			{Project: "Project X", Entries: 1, Duration: 90 * time.Minute},
+			{Project: "Project Y", Entries: 1, Duration: 30 * time.Minute, Running: true},
+		}, summaries)
+
+		summaries, err = TrackingReport(db, ns, "Project X", time.Time{}, now)
+		assert.NoError(err)
+		assert.Equal([]*TrackingSummary{{Project: "Project X", Entries: 2, Duration: 210 * time.Minute}}, summaries)
+
+		_, err = TrackingReport(db, ns, "Unknown", time.Time{}, now)
+		assert.Equal(ErrNoNoteFound, err)
+	})
+
+	t.Run("empty project", func(t *testing.T) {
+		_, err := StartTracking(db, ns, " ", "", monday)
+		assert.Equal(ErrNoTrackingProject, err)
+	})
+}
+
+func TestParseTrackingEntries(t *testing.T) {
+	md := "Notes about the project\n* start 2018-03-12T09:00:00Z\n* stop 2018-03-12T10:00:00Z (1h0m0s)\n- start 2018-03-12T11:00:00+01:00\n* start invalid"
+	entries := ParseTrackingEntries(md)
+	assert.Equal(t, []*TrackingEntry{
+		{Start: time.Date(2018, 3, 12, 9, 0, 0, 0, time.UTC), Stop: time.Date(2018, 3, 12, 10, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2018, 3, 12, 10, 0, 0, 0, time.UTC)},
+	}, normalizeEntries(entries))
+}
+
+func normalizeEntries(entries []*TrackingEntry) []*TrackingEntry {
+	for _, e := range entries {
+		e.Start = e.Start.UTC()
+		if !e.Stop.IsZero() {
+			e.Stop = e.Stop.UTC()
+		}
+	}
+	return entries
+}
+
+func TestStartOfWeek(t *testing.T) {
+	sunday := time.Date(2018, 3, 18, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2018, 3, 12, 0, 0, 0, 0, time.UTC), StartOfWeek(sunday))
+	assert.Equal(t, time.Date(2018, 3, 19, 0, 0, 0, 0, time.UTC), StartOfWeek(sunday.Add(time.Hour)))
+}
+
+func TestTrackingReportTable(t *testing.T) {
+	buf := new(bytes.Buffer)
+	WriteTrackingReport(buf, []*TrackingSummary{
+		{Project: "Project X", Entries: 2, Duration: 90 * time.Minute},
+		{Project: "Y", Entries: 1, Duration: 29*time.Minute + 40*time.Second, Running: true},
+	}, DefaultTableOption)
+	assert.Equal(t, expectedTrackingReport, buf.String())
+}
+
+const expectedTrackingReport = `+-------------+---------+-------+
+|   PROJECT   | ENTRIES | TIME  |
++-------------+---------+-------+
+| Project X   |       2 | 1h30m |
+| Y (running) |       1 | 0h30m |
++-------------+---------+-------+
+|    TOTAL    |    3    | 2H00M |
++-------------+---------+-------+
+`
@@ -0,0 +1,311 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoTranscribeCommand is returned if no transcribe command is set.
+	ErrNoTranscribeCommand = errors.New("no transcribe command set, set it with \"user set transcribe.command\"")
+	// ErrNoAudio is returned if the note has no audio attachments.
+	ErrNoAudio = errors.New("the note has no audio attachments")
+)
+
+// transcriptLinePattern matches the timestamped lines written by whisper.cpp
+// and similar tools, for example "[00:00:01.000 --> 00:00:04.500] Text".
+var transcriptLinePattern = regexp.MustCompile(`^\[\s*([0-9:.,]+)\s*-->\s*([0-9:.,]+)\s*\]\s*(.*)$`)
+
+// TranscriptSegment is a part of a transcript.
+type TranscriptSegment struct {
+	// Start is the offset in the recording where the segment starts.
+	Start time.Duration
+	// End is the offset in the recording where the segment ends. Zero if
+	// the command didn't write timestamps.
+	End time.Duration
+	// Text is the transcribed text.
+	Text string
+}
+
+// ResourceTranscript is the transcript of an audio attachment.
+type ResourceTranscript struct {
+	// Segments are the transcribed parts of the recording.
+	Segments []*TranscriptSegment
+	// Done is true when the whole recording has been transcribed.
+	Done bool
+}
+
+// TranscriptProgress is the transcription progress for a note. It's saved
+// after each segment so an interrupted transcription can resume.
+type TranscriptProgress struct {
+	// NoteGUID is the transcribed note.
+	NoteGUID string
+	// Resources are the transcripts keyed by the resource hash.
+	Resources map[string]*ResourceTranscript
+}
+
+// TranscriptStore stores transcription progress.
+type TranscriptStore interface {
+	// GetTranscriptProgress returns the saved progress for the note. Nil is
+	// returned if no progress is saved.
+	GetTranscriptProgress(guid string) (*TranscriptProgress, error)
+	// SaveTranscriptProgress saves the progress.
+	SaveTranscriptProgress(p *TranscriptProgress) error
+	// DeleteTranscriptProgress removes the saved progress for the note.
+	DeleteTranscriptProgress(guid string) error
+}
+
+// TranscribeNote transcribes the note's audio attachments with the
+// transcribe command in the settings and appends the transcripts to the
+// note. The progress is saved after each segment if the storage supports
+// it, and a rerun after a failure resumes where the last run stopped. The
+// progress function is called for each new segment.
+func TranscribeNote(db Storager, ns NotestoreClient, title string, progress func(*Resource, *TranscriptSegment)) (*Note, error) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if settings.TranscribeCommand == "" {
+		return nil, ErrNoTranscribeCommand
+	}
+	n, err := GetNoteWithContent(db, ns, title)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := ns.GetNoteResources(n.GUID)
+	if err != nil {
+		return nil, err
+	}
+	var audio []*Resource
+	for _, r := range resources {
+		if strings.HasPrefix(r.Mime, "audio/") {
+			audio = append(audio, r)
+		}
+	}
+	if len(audio) == 0 {
+		return nil, ErrNoAudio
+	}
+	store, _ := db.(TranscriptStore)
+	var p *TranscriptProgress
+	if store != nil {
+		if p, err = store.GetTranscriptProgress(n.GUID); err != nil {
+			return nil, err
+		}
+	}
+	if p == nil {
+		p = &TranscriptProgress{NoteGUID: n.GUID}
+	}
+	if p.Resources == nil {
+		p.Resources = make(map[string]*ResourceTranscript)
+	}
+	save := func() error {
+		if store == nil {
+			return nil
+		}
+		return store.SaveTranscriptProgress(p)
+	}
+	for _, r := range audio {
+		t := p.Resources[r.Hash]
+		if t == nil {
+			t = &ResourceTranscript{}
+			p.Resources[r.Hash] = t
+		}
+		if t.Done {
+			continue
+		}
+		// Without timestamps it isn't known where the last run stopped,
+		// so the recording is transcribed from the start.
+		var offset time.Duration
+		if len(t.Segments) != 0 {
+			if offset = t.Segments[len(t.Segments)-1].End; offset == 0 {
+				t.Segments = nil
+			}
+		}
+		err = RunTranscriber(settings.TranscribeCommand, n, r, offset, func(s *TranscriptSegment) error {
+			t.Segments = append(t.Segments, s)
+			if progress != nil {
+				progress(r, s)
+			}
+			return save()
+		})
+		if err != nil {
+			return nil, err
+		}
+		t.Done = true
+		if err = save(); err != nil {
+			return nil, err
+		}
+	}
+	n.Body += transcriptENML(audio, p)
+	if err = saveChanges(ns, n, true, true); err != nil {
+		return nil, err
+	}
+	if store != nil {
+		if err = store.DeleteTranscriptProgress(n.GUID); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// RunTranscriber writes the resource to a temporary file and runs the shell
+// command to transcribe it. The file's path is passed in the environment
+// variable CLINOTE_AUDIO_FILE, the mime type in CLINOTE_AUDIO_MIME and the
+// offset in milliseconds to start from in CLINOTE_AUDIO_OFFSET. The note's
+// title and GUID are passed in CLINOTE_NOTE_TITLE and CLINOTE_NOTE_GUID.
+// Each line written to stdout is a segment and passed to the segment
+// function. Lines starting with a whisper.cpp style timestamp, like
+// "[00:00:01.000 --> 00:00:04.500]", get the segment's start and end.
+func RunTranscriber(command string, n *Note, r *Resource, offset time.Duration, segment func(*TranscriptSegment) error) error {
+	if SafeMode {
+		return ErrSafeMode
+	}
+	f, err := ioutil.TempFile("", "clinote-audio-*"+resourceExtension(r))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(r.Data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"CLINOTE_AUDIO_FILE="+f.Name(),
+		"CLINOTE_AUDIO_MIME="+r.Mime,
+		"CLINOTE_AUDIO_OFFSET="+strconv.FormatInt(int64(offset/time.Millisecond), 10),
+		"CLINOTE_NOTE_TITLE="+n.Title,
+		"CLINOTE_NOTE_GUID="+n.GUID,
+	)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("transcribe command failed: %s", err)
+	}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		s := parseTranscriptLine(scanner.Text())
+		if s == nil {
+			continue
+		}
+		if err = segment(s); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	if err = cmd.Wait(); err != nil {
+		return fmt.Errorf("transcribe command failed: %s", err)
+	}
+	return scanner.Err()
+}
+
+// parseTranscriptLine returns the segment for a line of the transcribe
+// command's output. Nil is returned for empty lines.
+func parseTranscriptLine(line string) *TranscriptSegment {
+	line = strings.TrimSpace(line)
+	if m := transcriptLinePattern.FindStringSubmatch(line); m != nil {
+		start, serr := parseTimestamp(m[1])
+		end, eerr := parseTimestamp(m[2])
+		if serr == nil && eerr == nil {
+			if text := strings.TrimSpace(m[3]); text != "" {
+				return &TranscriptSegment{Start: start, End: end, Text: text}
+			}
+			return nil
+		}
+	}
+	if line == "" {
+		return nil
+	}
+	return &TranscriptSegment{Text: line}
+}
+
+// parseTimestamp parses timestamps like "01:02:03.456", "02:03,456" and
+// "3.5" as a duration.
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	var whole int
+	for _, part := range parts[:len(parts)-1] {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		whole = whole*60 + v
+	}
+	sec, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || sec < 0 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return time.Duration(whole)*time.Minute + time.Duration(sec*float64(time.Second)), nil
+}
+
+// transcriptENML returns the transcripts of the resources as ENML. Each
+// segment with a timestamp is prefixed with its start time.
+func transcriptENML(audio []*Resource, p *TranscriptProgress) string {
+	var b strings.Builder
+	for _, r := range audio {
+		t := p.Resources[r.Hash]
+		if t == nil {
+			continue
+		}
+		name := r.Filename
+		if name == "" {
+			name = "audio"
+		}
+		fmt.Fprintf(&b, "<hr/><div><b>Transcript of %s</b></div>", html.EscapeString(name))
+		for _, s := range t.Segments {
+			if s.End != 0 {
+				fmt.Fprintf(&b, "<div>[%s] %s</div>", formatTimestamp(s.Start), html.EscapeString(s.Text))
+			} else {
+				fmt.Fprintf(&b, "<div>%s</div>", html.EscapeString(s.Text))
+			}
+		}
+	}
+	return b.String()
+}
+
+func formatTimestamp(d time.Duration) string {
+	d = d / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", d/3600, d/60%60, d%60)
+}
@@ -0,0 +1,134 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockTranscriptStore struct {
+	*mockStore
+	progress map[string]*TranscriptProgress
+}
+
+func (m *mockTranscriptStore) GetTranscriptProgress(guid string) (*TranscriptProgress, error) {
+	return m.progress[guid], nil
+}
+
+func (m *mockTranscriptStore) SaveTranscriptProgress(p *TranscriptProgress) error {
+	m.progress[p.NoteGUID] = p
+	return nil
+}
+
+func (m *mockTranscriptStore) DeleteTranscriptProgress(guid string) error {
+	delete(m.progress, guid)
+	return nil
+}
+
+func TestTranscribeNote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need a POSIX shell")
+	}
+	assert := assert.New(t)
+	settings := new(Settings)
+	db := &mockTranscriptStore{
+		mockStore: &mockStore{getSettings: func() (*Settings, error) { return settings, nil }},
+		progress:  make(map[string]*TranscriptProgress),
+	}
+	note := &Note{GUID: "GUID", Title: "Interview"}
+	resources := []*Resource{
+		{Hash: "img", Mime: "image/png", Filename: "photo.png", Data: []byte("png")},
+		{Hash: "rec", Mime: "audio/wav", Filename: "recording.wav", Data: []byte("RIFF")},
+	}
+	var updated *Note
+	ns := &mockNS{
+		findNotes: func(*NoteFilter, int, int) ([]*Note, error) { return []*Note{note}, nil },
+		getNoteContent: func(string) (string, error) {
+			return XMLHeader + "<en-note><div>Notes</div></en-note>", nil
+		},
+		getResources: func(string) ([]*Resource, error) { return resources, nil },
+		updateNote:   func(n *Note) error { updated = n; return nil },
+	}
+
+	t.Run("No command", func(t *testing.T) {
+		_, err := TranscribeNote(db, ns, "Interview", nil)
+		assert.Equal(ErrNoTranscribeCommand, err)
+	})
+
+	settings.TranscribeCommand = `
+case "$CLINOTE_AUDIO_FILE" in *.wav) ;; *) exit 2 ;; esac
+if [ "$CLINOTE_AUDIO_OFFSET" = 0 ]; then
+	echo "[00:00:00.000 --> 00:00:02.000]  Hello"
+	echo "[00:00:02.000 --> 00:00:04.500]  world"
+	exit 1
+fi
+echo "[00:00:04.500 --> 00:01:05.000]  <again> & more"`
+
+	t.Run("Interrupted", func(t *testing.T) {
+		var segments []string
+		_, err := TranscribeNote(db, ns, "Interview", func(r *Resource, s *TranscriptSegment) {
+			assert.Equal("rec", r.Hash)
+			segments = append(segments, s.Text)
+		})
+		assert.Error(err)
+		assert.Equal([]string{"Hello", "world"}, segments)
+		assert.Nil(updated)
+		if p := db.progress["GUID"]; assert.NotNil(p) {
+			assert.Len(p.Resources["rec"].Segments, 2)
+			assert.False(p.Resources["rec"].Done)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		n, err := TranscribeNote(db, ns, "Interview", nil)
+		assert.NoError(err)
+		assert.Equal(updated, n)
+		assert.Equal(XMLHeader+"<en-note><div>Notes</div><hr/><div><b>Transcript of recording.wav</b></div>"+
+			"<div>[00:00:00] Hello</div><div>[00:00:02] world</div><div>[00:00:04] &lt;again&gt; &amp; more</div></en-note>", n.Body)
+		assert.Empty(db.progress)
+	})
+
+	t.Run("No audio", func(t *testing.T) {
+		resources = resources[:1]
+		_, err := TranscribeNote(db, ns, "Interview", nil)
+		assert.Equal(ErrNoAudio, err)
+	})
+}
+
+func TestParseTranscriptLine(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		line     string
+		expected *TranscriptSegment
+	}{
+		{"[00:00:01.000 --> 00:00:04.500]   Text", &TranscriptSegment{Start: time.Second, End: 4500 * time.Millisecond, Text: "Text"}},
+		{"[01:02:03,250 --> 01:02:05,000] Text", &TranscriptSegment{Start: time.Hour + 2*time.Minute + 3250*time.Millisecond, End: time.Hour + 2*time.Minute + 5*time.Second, Text: "Text"}},
+		{"[02:03.5 --> 02:04] Text", &TranscriptSegment{Start: 2*time.Minute + 3500*time.Millisecond, End: 2*time.Minute + 4*time.Second, Text: "Text"}},
+		{"Plain text", &TranscriptSegment{Text: "Plain text"}},
+		{"[note] Text", &TranscriptSegment{Text: "[note] Text"}},
+		{"[00:00:01.000 --> 00:00:02.000]", nil},
+		{"  ", nil},
+	}
+	for _, test := range tests {
+		assert.Equal(test.expected, parseTranscriptLine(test.line), test.line)
+	}
+}
@@ -17,7 +17,10 @@
 
 package clinote
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // Storager is the interface for backend storage.
 type Storager interface {
@@ -36,6 +39,34 @@ type Storager interface {
 	SaveNoteRecoveryPoint(*Note) error
 	// GetNoteREcoveryPoint returns the saved note.
 	GetNoteRecoveryPoint() (*Note, error)
+	// SaveRedactedNote saves the encrypted copy of a note before it was
+	// redacted. A nil copy removes it.
+	SaveRedactedNote(guid string, data []byte) error
+	// GetRedactedNote returns the encrypted copy of the redacted note.
+	GetRedactedNote(guid string) ([]byte, error)
+	// GetSyncState returns the state saved at the last successful sync.
+	GetSyncState() (*SyncState, error)
+	// SaveSyncState saves the state of a successful sync.
+	SaveSyncState(*SyncState) error
+	// GetPendingChanges returns the queued changes.
+	GetPendingChanges() ([]*PendingChange, error)
+	// SavePendingChanges saves the queued changes.
+	SavePendingChanges([]*PendingChange) error
+	// GetCacheEntry returns the entry for the key in the cache. If no
+	// entry is found, nil is returned.
+	GetCacheEntry(cache, key string) ([]byte, error)
+	// PutCacheEntry saves the entry in the cache. If the caches are larger
+	// than the size limit, the least recently used entries are evicted.
+	PutCacheEntry(cache, key string, data []byte) error
+	// GetCacheStats returns the statistics for the caches.
+	GetCacheStats() (*CacheStats, error)
+}
+
+// Storage is the interface for a storage backend. It holds both the
+// application data and the user's credentials.
+type Storage interface {
+	Storager
+	UserCredentialStore
 }
 
 // UserCredentialStore provides an interface to a backend that stores
@@ -49,6 +80,8 @@ type UserCredentialStore interface {
 	GetAll() ([]*Credential, error)
 	// GetByIndex returns a user credential by its index.
 	GetByIndex(index int) (*Credential, error)
+	// Update replaces the credential at the index.
+	Update(index int, c *Credential) error
 }
 
 // Settings is a struct holding the user's settings for the application.
@@ -57,6 +90,93 @@ type Settings struct {
 	APIKey string
 	// Credential holds the user's credential data.
 	Credential *Credential
+	// SyncInclude is a list of notebook names. If set, sync, export and
+	// mirror operations are limited to these notebooks.
+	SyncInclude []string
+	// SyncExclude is a list of notebook names that are skipped by sync,
+	// export and mirror operations.
+	SyncExclude []string
+	// CacheMaxSize is the size limit in bytes for the local caches. Zero
+	// means no limit.
+	CacheMaxSize int64
+	// Aliases maps user defined command aliases to the commands they
+	// are expanded to.
+	Aliases map[string]string
+	// DefaultNotebook is the name of the notebook used for new notes if
+	// no notebook is given. If empty, the account's default notebook is used.
+	DefaultNotebook string
+	// NotebookDefaults holds the defaults applied to new notes, keyed
+	// by notebook name.
+	NotebookDefaults map[string]*NotebookDefaults
+	// ExpiryWarning is the number of days before the credential expires
+	// when the user is warned. Zero uses DefaultExpiryWarning and a
+	// negative value turns the warning off.
+	ExpiryWarning int
+	// StaleWarning is the number of days since the last sync or backup
+	// after which the user is warned. Zero uses DefaultStaleWarning and a
+	// negative value turns the warning off.
+	StaleWarning int
+	// LastBackup is when the last vault or backup was created. Zero if
+	// none has been created.
+	LastBackup time.Time
+	// MeetingNotebook is the name of the notebook used for meeting notes.
+	// If empty, the default notebook is used.
+	MeetingNotebook string
+	// TrackingProject is the project with a running time tracking timer.
+	TrackingProject string
+	// Hyperlinks controls if note titles and file paths are written as
+	// terminal hyperlinks. It's auto, on or off. Empty means auto.
+	Hyperlinks string
+	// Theme is the name of the color theme of the terminal output. Empty
+	// means the default theme.
+	Theme string
+	// SummarizeCommand is the shell command used to summarize notes. The
+	// note content is written to its stdin and the summary read from stdout.
+	SummarizeCommand string
+	// TranscribeCommand is the shell command used to transcribe audio
+	// attachments. The transcript is read from its stdout.
+	TranscribeCommand string
+	// BackupDir is the folder scheduled backups are written to. If empty,
+	// the backups folder in the config folder is used.
+	BackupDir string
+	// BackupKeep is the number of backups kept. Zero uses
+	// DefaultBackupKeep.
+	BackupKeep int
+	// BackupFormat is the format of the backups, enex or snapshot. Empty
+	// uses DefaultBackupFormat.
+	BackupFormat string
+	// BackupInterval is how often a backup is made. Zero turns the
+	// scheduled backups off.
+	BackupInterval time.Duration
+	// DBLinger is how long the database is held open after it was last
+	// used. Zero uses the storage's default.
+	DBLinger time.Duration
+	// Proxy is the http, https or socks5 proxy used for the API calls. If
+	// empty, the proxy from the environment is used.
+	Proxy string
+	// CABundle is the path to a PEM file with certificates that are trusted
+	// in addition to the system's.
+	CABundle string
+	// TLSMinVersion is the lowest TLS version accepted, for example 1.2.
+	// Empty uses Go's default.
+	TLSMinVersion string
+	// RetryAttempts is how many times an API call is made before a
+	// transient network error is returned. Zero uses DefaultRetryAttempts.
+	RetryAttempts int
+	// RetryDelay is the wait before the first retry. Zero uses
+	// DefaultRetryDelay.
+	RetryDelay time.Duration
+	// RetryJitter is the fraction of the retry wait that is randomized.
+	RetryJitter float64
+	// DisableAutoTitle turns off deriving the title of new notes without a
+	// title from their content.
+	DisableAutoTitle bool
+	// Format is the name of the format notes are shown and edited in.
+	// Empty means Markdown.
+	Format string
+	// HistoryRepo is the folder of the git repository fetched and edited
+	// notes are mirrored to. Empty turns the mirror off.
+	HistoryRepo string
 }
 
 // Credential is a struct that holds credential information.
@@ -67,6 +187,15 @@ type Credential struct {
 	Secret string
 	// CredType is used to identify credential type.
 	CredType CredentialType
+	// Endpoint is the server the credential authenticates against.
+	Endpoint
+	// Label is a user friendly name shown instead of the name.
+	Label string `json:",omitempty"`
+	// Active is true for the credential used by the client.
+	Active bool `json:",omitempty"`
+	// Expires is when the credential expires, in milliseconds since the
+	// epoch. Zero if not known.
+	Expires int64 `json:",omitempty"`
 }
 
 // CredentialType is a type of credential. Used to identify which backend to use
@@ -82,6 +211,12 @@ const (
 	// EvernoteSandboxCredential is used for credentials that can authenticate with
 	// Evernote's sandbox server.
 	EvernoteSandboxCredential
+	// JoplinCredential is used for credentials that can authenticate with
+	// the Joplin data API.
+	JoplinCredential
+	// FolderCredential is used for a local folder of Markdown files. The
+	// secret is the path to the folder.
+	FolderCredential
 )
 
-var credtypeStringMapper = []string{"Evernote", "Evernote Sandbox"}
+var credtypeStringMapper = []string{"Evernote", "Evernote Sandbox", "Joplin", "Folder"}
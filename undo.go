@@ -0,0 +1,133 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxUndoEntries is the number of operations kept in the undo log.
+const MaxUndoEntries = 50
+
+var (
+	// ErrNothingToUndo is returned if the undo log is empty.
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrUndoNotSupported is returned if the storage doesn't have an undo log.
+	ErrUndoNotSupported = errors.New("the storage doesn't support undo")
+)
+
+// UndoOp is the type of operation recorded in the undo log.
+type UndoOp uint8
+
+const (
+	// UndoEdit is an edit of the note's content.
+	UndoEdit UndoOp = iota
+	// UndoUpdate is a change of the note's title, notebook or other metadata.
+	UndoUpdate
+	// UndoDelete is a note moved to the trash.
+	UndoDelete
+)
+
+var undoOpStringMapper = []string{"edit", "update", "delete"}
+
+func (o UndoOp) String() string {
+	return undoOpStringMapper[o]
+}
+
+// UndoEntry is an operation that can be undone.
+type UndoEntry struct {
+	// Op is the operation.
+	Op UndoOp
+	// Time is when the operation was done.
+	Time time.Time
+	// Note is the note as it was before the operation. The body is only
+	// set if the content was changed.
+	Note *Note
+}
+
+// UndoLog is implemented by storage that can record the operations that
+// can be undone.
+type UndoLog interface {
+	// GetUndoLog returns the operations, oldest first.
+	GetUndoLog() ([]*UndoEntry, error)
+	// SaveUndoLog replaces the operations in the log.
+	SaveUndoLog(entries []*UndoEntry) error
+}
+
+// LastUndo returns the operation that Undo would revert. ErrNothingToUndo
+// is returned if the log is empty.
+func LastUndo(db Storager) (*UndoEntry, error) {
+	log, ok := db.(UndoLog)
+	if !ok {
+		return nil, ErrUndoNotSupported
+	}
+	entries, err := log.GetUndoLog()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNothingToUndo
+	}
+	return entries[len(entries)-1], nil
+}
+
+// Undo reverts the last operation in the undo log by saving the note as
+// it was before the operation. Deleted notes are restored from the trash.
+// The operation is removed from the log once it has been reverted.
+func Undo(db Storager, ns NotestoreClient) (*UndoEntry, error) {
+	entry, err := LastUndo(db)
+	if err != nil {
+		return nil, err
+	}
+	n := *entry.Note
+	if err = saveChanges(ns, &n, n.Body != "", true); err != nil {
+		return nil, err
+	}
+	if IsDryRun(ns) {
+		return entry, nil
+	}
+	log := db.(UndoLog)
+	entries, err := log.GetUndoLog()
+	if err != nil {
+		return nil, err
+	}
+	return entry, log.SaveUndoLog(entries[:len(entries)-1])
+}
+
+// recordUndo adds the note's state before the operation to the undo log.
+// Nothing is recorded if the storage doesn't have an undo log or if the
+// notestore doesn't send changes to the server.
+func recordUndo(db Storager, ns NotestoreClient, op UndoOp, prev *Note) error {
+	log, ok := db.(UndoLog)
+	if !ok || IsDryRun(ns) {
+		return nil
+	}
+	entries, err := log.GetUndoLog()
+	if err != nil {
+		return err
+	}
+	// The Markdown and the resources aren't needed to restore the note.
+	n := *prev
+	n.MD, n.Resources = "", nil
+	entries = append(entries, &UndoEntry{Op: op, Time: time.Now(), Note: &n})
+	if len(entries) > MaxUndoEntries {
+		entries = entries[len(entries)-MaxUndoEntries:]
+	}
+	return log.SaveUndoLog(entries)
+}
@@ -0,0 +1,84 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockUndoStore struct {
+	*mockStore
+	entries []*UndoEntry
+}
+
+func (m *mockUndoStore) GetUndoLog() ([]*UndoEntry, error) {
+	return append([]*UndoEntry{}, m.entries...), nil
+}
+
+func (m *mockUndoStore) SaveUndoLog(entries []*UndoEntry) error {
+	m.entries = entries
+	return nil
+}
+
+func TestUndo(t *testing.T) {
+	assert := assert.New(t)
+	db := &mockUndoStore{mockStore: &mockStore{}}
+	note := &Note{Title: "Title", GUID: "GUID", Notebook: &Notebook{GUID: "Book"}}
+	var updated *Note
+	ns := nsWithNote(note)
+	ns.deleteNote = func(string) error { return nil }
+	ns.updateNote = func(n *Note) error { updated = n; return nil }
+
+	t.Run("Nothing to undo", func(t *testing.T) {
+		_, err := Undo(db, ns)
+		assert.Equal(ErrNothingToUndo, err)
+	})
+
+	t.Run("Restore deleted note", func(t *testing.T) {
+		assert.NoError(DeleteNote(db, ns, "Title", ""))
+		entry, err := Undo(db, ns)
+		assert.NoError(err)
+		assert.Equal(UndoDelete, entry.Op)
+		assert.Equal("GUID", updated.GUID)
+		assert.Empty(updated.Body, "Content should not be sent")
+		assert.Empty(db.entries)
+	})
+
+	t.Run("Restore title", func(t *testing.T) {
+		assert.NoError(ChangeTitle(db, ns, "Title", "New title"))
+		_, err := Undo(db, ns)
+		assert.NoError(err)
+		assert.Equal("Title", updated.Title)
+	})
+
+	t.Run("Restore content", func(t *testing.T) {
+		db.entries = []*UndoEntry{{Op: UndoEdit, Note: &Note{Title: "Title", GUID: "GUID", Body: "<div>Old</div>", Notebook: note.Notebook}}}
+		_, err := Undo(db, ns)
+		assert.NoError(err)
+		assert.Equal(XMLHeader+"<en-note><div>Old</div></en-note>", updated.Body)
+	})
+
+	t.Run("Log is limited", func(t *testing.T) {
+		for i := 0; i < MaxUndoEntries+5; i++ {
+			assert.NoError(recordUndo(db, ns, UndoUpdate, note))
+		}
+		assert.Len(db.entries, MaxUndoEntries)
+	})
+}
@@ -31,6 +31,10 @@ type mockNS struct {
 	createNote      func(n *Note) error
 	updateNotebook  func(b *Notebook) error
 	getNotebook     func(guid string) (*Notebook, error)
+	getSyncState    func() (*SyncState, error)
+	getResources    func(guid string) ([]*Resource, error)
+	getAllTags      func() ([]*Tag, error)
+	createNotebook  func(b *Notebook, defaultNotebook bool) error
 }
 
 func (s *mockNS) UpdateNotebook(b *Notebook) error {
@@ -65,20 +69,82 @@ func (s *mockNS) GetAllNotebooks() ([]*Notebook, error) {
 	return s.getAllNotebooks()
 }
 
+func (s *mockNS) GetAllTags() ([]*Tag, error) {
+	return s.getAllTags()
+}
+
 func (s *mockNS) CreateNotebook(b *Notebook, defaultNotebook bool) error {
-	panic("not implemented")
+	return s.createNotebook(b, defaultNotebook)
 }
 
 func (s *mockNS) GetNotebook(guid string) (*Notebook, error) {
 	return s.getNotebook(guid)
 }
 
+func (s *mockNS) GetSyncState() (*SyncState, error) {
+	return s.getSyncState()
+}
+
+func (s *mockNS) GetNoteResources(guid string) ([]*Resource, error) {
+	return s.getResources(guid)
+}
+
 type mockStore struct {
 	getNotebookCache      func() (*NotebookCacheList, error)
 	storeNotebookList     func(list *NotebookCacheList) error
 	getSearch             func() ([]*Note, error)
 	saveNoteRecoveryPoint func(*Note) error
 	getNoteRecoveryPoint  func() (*Note, error)
+	saveRedactedNote      func(string, []byte) error
+	getRedactedNote       func(string) ([]byte, error)
+	getSyncState          func() (*SyncState, error)
+	saveSyncState         func(*SyncState) error
+	pendingChanges        []*PendingChange
+	getSettings           func() (*Settings, error)
+	storeSettings         func(*Settings) error
+	cache                 map[string][]byte
+	savedSearch           []*Note
+}
+
+func (m *mockStore) GetCacheEntry(cache, key string) ([]byte, error) {
+	return m.cache[cache+key], nil
+}
+
+func (m *mockStore) PutCacheEntry(cache, key string, data []byte) error {
+	if m.cache == nil {
+		m.cache = make(map[string][]byte)
+	}
+	m.cache[cache+key] = data
+	return nil
+}
+
+func (m *mockStore) GetCacheStats() (*CacheStats, error) {
+	panic("not implemented")
+}
+
+func (m *mockStore) GetSyncState() (*SyncState, error) {
+	return m.getSyncState()
+}
+
+func (m *mockStore) SaveSyncState(s *SyncState) error {
+	return m.saveSyncState(s)
+}
+
+func (m *mockStore) GetPendingChanges() ([]*PendingChange, error) {
+	return m.pendingChanges, nil
+}
+
+func (m *mockStore) SavePendingChanges(changes []*PendingChange) error {
+	m.pendingChanges = changes
+	return nil
+}
+
+func (m *mockStore) SaveRedactedNote(guid string, data []byte) error {
+	return m.saveRedactedNote(guid, data)
+}
+
+func (m *mockStore) GetRedactedNote(guid string) ([]byte, error) {
+	return m.getRedactedNote(guid)
 }
 
 func (m *mockStore) SaveNoteRecoveryPoint(n *Note) error {
@@ -89,11 +155,15 @@ func (m *mockStore) GetNoteRecoveryPoint() (*Note, error) {
 	return m.getNoteRecoveryPoint()
 }
 
-func (m *mockStore) SaveSearch([]*Note) error {
-	panic("not implemented")
+func (m *mockStore) SaveSearch(notes []*Note) error {
+	m.savedSearch = notes
+	return nil
 }
 
 func (m *mockStore) GetSearch() ([]*Note, error) {
+	if m.getSearch == nil {
+		return m.savedSearch, nil
+	}
 	return m.getSearch()
 }
 
@@ -102,11 +172,14 @@ func (m *mockStore) Close() error {
 }
 
 func (m *mockStore) GetSettings() (*Settings, error) {
-	panic("not implemented")
+	if m.getSettings == nil {
+		return new(Settings), nil
+	}
+	return m.getSettings()
 }
 
-func (m *mockStore) StoreSettings(*Settings) error {
-	panic("not implemented")
+func (m *mockStore) StoreSettings(s *Settings) error {
+	return m.storeSettings(s)
 }
 
 func (m *mockStore) GetNotebookCache() (*NotebookCacheList, error) {
@@ -174,6 +247,7 @@ type mockCredentialStore struct {
 	remove     func(*Credential) error
 	getAll     func() ([]*Credential, error)
 	getByIndex func(int) (*Credential, error)
+	update     func(int, *Credential) error
 }
 
 func (m *mockCredentialStore) Add(c *Credential) error {
@@ -191,3 +265,7 @@ func (m *mockCredentialStore) GetAll() ([]*Credential, error) {
 func (m *mockCredentialStore) GetByIndex(index int) (*Credential, error) {
 	return m.getByIndex(index)
 }
+
+func (m *mockCredentialStore) Update(index int, c *Credential) error {
+	return m.update(index, c)
+}
@@ -0,0 +1,558 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// VaultVersion is the version of the vault format written by CLInote.
+	VaultVersion = 1
+	// vaultChunkSize is the size of the plaintext chunks that are encrypted.
+	vaultChunkSize = 64 * 1024
+	// vaultNoncePrefixSize is the size of the random part of the chunk nonces.
+	// The rest of the nonce is the chunk counter.
+	vaultNoncePrefixSize = 4
+	vaultManifestName    = "vault.json"
+)
+
+// vaultMagic identifies a vault file.
+var vaultMagic = []byte("CNVAULT\x00")
+
+var (
+	// ErrNotAVault is returned if the file isn't a vault.
+	ErrNotAVault = errors.New("not a clinote vault")
+	// ErrUnsupportedVaultVersion is returned if the vault was written by a
+	// newer version of CLInote.
+	ErrUnsupportedVaultVersion = errors.New("unsupported vault version")
+	// ErrVaultTruncated is returned if the vault ends before all the data
+	// has been read.
+	ErrVaultTruncated = errors.New("vault is truncated")
+	// ErrCorruptVault is returned if the content of the vault can't be read.
+	ErrCorruptVault = errors.New("vault is corrupt")
+)
+
+// VaultManifest describes the content of a vault.
+type VaultManifest struct {
+	// Version is the version of the vault format.
+	Version int
+	// Created is when the vault was created.
+	Created time.Time
+	// Notebooks are the user's notebooks.
+	Notebooks []*Notebook
+	// Settings are the user's settings without the credentials.
+	Settings *Settings
+}
+
+// A vault is a single encrypted file holding notes with their resources,
+// the notebooks and the settings. The file starts with a header holding
+// the magic, the version, the salt used to derive the key from the
+// passphrase and a nonce prefix. The rest of the file is a gzip
+// compressed tar archive split into chunks that are encrypted with
+// AES-GCM. Each chunk is prefixed by its length. The last chunk is marked
+// so a truncated vault is detected.
+//
+// The archive starts with the manifest, vault.json. Each note follows as
+// notes/<guid>.json with the note's resources, without the data, and the
+// data of each resource as notes/<guid>/<index>.
+
+// CreateVault writes the notes, with their content and resources, the
+// notebooks and the settings to an encrypted vault. The credentials are
+// not included. Notes that fail to be fetched are skipped and reported in
+// the returned FetchError.
+func CreateVault(w io.Writer, db Storager, ns NotestoreClient, notes []*Note, passphrase string, progress FetchProgress) error {
+	books, err := GetNotebooks(db, ns, false)
+	if err != nil {
+		return err
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		return err
+	}
+	cpy := *settings
+	cpy.APIKey = ""
+	cpy.Credential = nil
+	vw, err := NewVaultWriter(w, passphrase, &VaultManifest{Created: time.Now(), Notebooks: books, Settings: &cpy})
+	if err != nil {
+		return err
+	}
+	fetchErr := &FetchError{Errors: make(map[string]error), Total: len(notes)}
+	for i, n := range notes {
+		err := getNoteContent(ns, n)
+		if err == nil {
+			n.Resources, err = ns.GetNoteResources(n.GUID)
+		}
+		if err != nil {
+			fetchErr.Errors[n.GUID] = err
+		} else {
+			for _, b := range books {
+				if n.Notebook != nil && b.GUID == n.Notebook.GUID {
+					n.Notebook = b
+				}
+			}
+			if err = vw.Add(n); err != nil {
+				return err
+			}
+			// The resources can be large, don't keep them around.
+			n.Resources = nil
+		}
+		if progress != nil {
+			progress(i+1, len(notes))
+		}
+	}
+	if err = vw.Close(); err != nil {
+		return err
+	}
+	if len(fetchErr.Errors) != 0 {
+		return fetchErr
+	}
+	return nil
+}
+
+// ExtractVault writes the notes in the vault to the folder. Each notebook
+// gets a sub folder and the resources of a note are written to a folder
+// named after the note. The manifest is written to vault.json in the
+// folder. The number of extracted notes is returned.
+func ExtractVault(v *VaultReader, folder string, opts NoteOption) (int, error) {
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return 0, err
+	}
+	data, err := json.MarshalIndent(v.Manifest, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err = ioutil.WriteFile(filepath.Join(folder, vaultManifestName), data, 0600); err != nil {
+		return 0, err
+	}
+	ext := NoteFormat.Extension()
+	if opts&RawNote != 0 {
+		ext = ".xml"
+	}
+	used := make(map[string]bool)
+	count := 0
+	for {
+		n, err := v.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		dir := folder
+		if name := vaultNotebookName(v.Manifest, n); name != "" {
+			dir = filepath.Join(folder, exportFilename(name))
+		}
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			return count, err
+		}
+		name := filepath.Join(dir, exportFilename(n.Title))
+		if used[name] {
+			name += "-" + n.GUID
+		}
+		used[name] = true
+		if err = writeNoteFile(name+ext, n, opts); err != nil {
+			return count, err
+		}
+		if err = writeResourceFiles(name, n.Resources); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// vaultNotebookName returns the name of the note's notebook. If the note
+// only has the notebook's GUID, the name is looked up in the manifest.
+func vaultNotebookName(m *VaultManifest, n *Note) string {
+	if n.Notebook == nil || n.Notebook.Name != "" {
+		return getNotebookName(n)
+	}
+	for _, b := range m.Notebooks {
+		if b.GUID == n.Notebook.GUID {
+			return b.Name
+		}
+	}
+	return ""
+}
+
+// writeResourceFiles writes the resources to files in the folder. The folder
+// is only created if there are resources.
+func writeResourceFiles(folder string, resources []*Resource) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return err
+	}
+	used := make(map[string]bool)
+	for i, r := range resources {
+		name := resourceFilename(r)
+		if used[name] {
+			name = strconv.Itoa(i+1) + "-" + name
+		}
+		used[name] = true
+		if err := ioutil.WriteFile(filepath.Join(folder, name), r.Data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourceFilename returns the resource's file name. If it has none, the
+// hash is used with an extension based on the mime type.
+func resourceFilename(r *Resource) string {
+	if r.Filename != "" {
+		return exportFilename(r.Filename)
+	}
+	name := r.Hash
+	if name == "" {
+		name = resourceHash(r.Data)
+	}
+	if ext, ok := preferredExtensions[r.Mime]; ok {
+		name += ext
+	} else if exts, _ := mime.ExtensionsByType(r.Mime); len(exts) != 0 {
+		name += exts[0]
+	}
+	return name
+}
+
+// preferredExtensions are used for mime types with more than one common
+// extension.
+var preferredExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"text/plain": ".txt",
+	"audio/mpeg": ".mp3",
+}
+
+// VaultWriter writes notes to a vault.
+type VaultWriter struct {
+	enc *vaultEncrypter
+	gz  *gzip.Writer
+	tw  *tar.Writer
+}
+
+// NewVaultWriter returns a writer that writes a vault to w. The vault is
+// encrypted with a key derived from the passphrase. The manifest is written
+// first.
+func NewVaultWriter(w io.Writer, passphrase string, m *VaultManifest) (*VaultWriter, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	salt := make([]byte, saltSize)
+	prefix := make([]byte, vaultNoncePrefixSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := append(append(append([]byte{}, vaultMagic...), VaultVersion), salt...)
+	if _, err = w.Write(append(header, prefix...)); err != nil {
+		return nil, err
+	}
+	enc := &vaultEncrypter{w: w, gcm: gcm, prefix: prefix}
+	gz := gzip.NewWriter(enc)
+	v := &VaultWriter{enc: enc, gz: gz, tw: tar.NewWriter(gz)}
+	m.Version = VaultVersion
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err = v.writeFile(vaultManifestName, data); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Add writes the note and its resources to the vault.
+func (v *VaultWriter) Add(n *Note) error {
+	cpy := *n
+	if n.Resources != nil {
+		cpy.Resources = make([]*Resource, len(n.Resources))
+	}
+	for i, r := range n.Resources {
+		meta := *r
+		meta.Data = nil
+		cpy.Resources[i] = &meta
+	}
+	data, err := json.Marshal(&cpy)
+	if err != nil {
+		return err
+	}
+	if err = v.writeFile(path.Join("notes", n.GUID+".json"), data); err != nil {
+		return err
+	}
+	for i, r := range n.Resources {
+		if err = v.writeFile(path.Join("notes", n.GUID, strconv.Itoa(i)), r.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes the vault. It doesn't close the underlying writer.
+func (v *VaultWriter) Close() error {
+	if err := v.tw.Close(); err != nil {
+		return err
+	}
+	if err := v.gz.Close(); err != nil {
+		return err
+	}
+	return v.enc.Close()
+}
+
+func (v *VaultWriter) writeFile(name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := v.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := v.tw.Write(data)
+	return err
+}
+
+// VaultReader reads the notes in a vault.
+type VaultReader struct {
+	// Manifest describes the vault.
+	Manifest *VaultManifest
+	tr       *tar.Reader
+}
+
+// OpenVault decrypts the vault with the passphrase and reads the manifest.
+// The notes are read with Next.
+func OpenVault(r io.Reader, passphrase string) (*VaultReader, error) {
+	header := make([]byte, len(vaultMagic)+1+saltSize+vaultNoncePrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrNotAVault
+	}
+	if !bytes.Equal(header[:len(vaultMagic)], vaultMagic) {
+		return nil, ErrNotAVault
+	}
+	if header[len(vaultMagic)] != VaultVersion {
+		return nil, ErrUnsupportedVaultVersion
+	}
+	salt := header[len(vaultMagic)+1 : len(vaultMagic)+1+saltSize]
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	dec := &vaultDecrypter{r: r, gcm: gcm, prefix: header[len(header)-vaultNoncePrefixSize:]}
+	gz, err := gzip.NewReader(dec)
+	if err != nil {
+		return nil, vaultReadError(err)
+	}
+	v := &VaultReader{tr: tar.NewReader(gz)}
+	name, data, err := v.readFile()
+	if err != nil {
+		return nil, err
+	}
+	if name != vaultManifestName {
+		return nil, ErrCorruptVault
+	}
+	v.Manifest = new(VaultManifest)
+	if err = json.Unmarshal(data, v.Manifest); err != nil {
+		return nil, ErrCorruptVault
+	}
+	return v, nil
+}
+
+// Next returns the next note in the vault with its resources. io.EOF is
+// returned when there are no more notes.
+func (v *VaultReader) Next() (*Note, error) {
+	name, data, err := v.readFile()
+	if err != nil {
+		return nil, err
+	}
+	n := new(Note)
+	if !strings.HasSuffix(name, ".json") || json.Unmarshal(data, n) != nil {
+		return nil, ErrCorruptVault
+	}
+	for _, r := range n.Resources {
+		if _, r.Data, err = v.readFile(); err == io.EOF {
+			return nil, ErrVaultTruncated
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (v *VaultReader) readFile() (string, []byte, error) {
+	hdr, err := v.tr.Next()
+	if err == io.EOF {
+		return "", nil, io.EOF
+	}
+	if err != nil {
+		return "", nil, vaultReadError(err)
+	}
+	data, err := ioutil.ReadAll(v.tr)
+	if err != nil {
+		return "", nil, vaultReadError(err)
+	}
+	return hdr.Name, data, nil
+}
+
+// vaultReadError returns the decryption errors as is. Other errors mean
+// the decrypted data can't be read.
+func vaultReadError(err error) error {
+	if err == ErrDecryptionFailed || err == ErrVaultTruncated {
+		return err
+	}
+	if err == io.ErrUnexpectedEOF {
+		return ErrVaultTruncated
+	}
+	return ErrCorruptVault
+}
+
+// vaultEncrypter encrypts the data written to it in chunks.
+type vaultEncrypter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint64
+	buf     []byte
+}
+
+func (e *vaultEncrypter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		take := vaultChunkSize - len(e.buf)
+		if take > len(p) {
+			take = len(p)
+		}
+		e.buf = append(e.buf, p[:take]...)
+		p = p[take:]
+		if len(e.buf) == vaultChunkSize {
+			if err := e.seal(false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close writes the last chunk.
+func (e *vaultEncrypter) Close() error {
+	return e.seal(true)
+}
+
+func (e *vaultEncrypter) seal(last bool) error {
+	sealed := e.gcm.Seal(nil, vaultNonce(e.prefix, e.counter), e.buf, vaultChunkAD(last))
+	e.counter++
+	e.buf = e.buf[:0]
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(sealed)))
+	if _, err := e.w.Write(size); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// vaultDecrypter decrypts the chunks written by vaultEncrypter.
+type vaultDecrypter struct {
+	r       io.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint64
+	buf     []byte
+	done    bool
+}
+
+func (d *vaultDecrypter) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *vaultDecrypter) open() error {
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(d.r, size); err != nil {
+		return ErrVaultTruncated
+	}
+	length := binary.BigEndian.Uint32(size)
+	if length > vaultChunkSize+uint32(d.gcm.Overhead()) {
+		return ErrCorruptVault
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return ErrVaultTruncated
+	}
+	nonce := vaultNonce(d.prefix, d.counter)
+	plain, err := d.gcm.Open(nil, nonce, sealed, vaultChunkAD(false))
+	if err != nil {
+		if plain, err = d.gcm.Open(nil, nonce, sealed, vaultChunkAD(true)); err != nil {
+			return ErrDecryptionFailed
+		}
+		d.done = true
+	}
+	d.counter++
+	d.buf = plain
+	return nil
+}
+
+func vaultNonce(prefix []byte, counter uint64) []byte {
+	nonce := make([]byte, vaultNoncePrefixSize+8)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[vaultNoncePrefixSize:], counter)
+	return nonce
+}
+
+// vaultChunkAD returns the additional data for a chunk. It marks the last
+// chunk.
+func vaultChunkAD(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
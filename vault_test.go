@@ -0,0 +1,199 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestVault(t *testing.T, notes ...*Note) []byte {
+	buf := new(bytes.Buffer)
+	m := &VaultManifest{Notebooks: []*Notebook{&Notebook{Name: "Work", GUID: "nb"}}, Settings: &Settings{DefaultNotebook: "Work"}}
+	w, err := NewVaultWriter(buf, "secret", m)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, n := range notes {
+		assert.NoError(t, w.Add(n))
+	}
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	// Larger than a chunk to cover data spanning chunks.
+	large := make([]byte, 3*vaultChunkSize+100)
+	for i := range large {
+		large[i] = byte(i * 7)
+	}
+	notes := []*Note{
+		&Note{GUID: "1", Title: "First", Body: "<div>One</div>", Notebook: &Notebook{Name: "Work", GUID: "nb"}, Tags: []string{"a"},
+			Resources: []*Resource{&Resource{GUID: "r1", Hash: resourceHash(large), Mime: "application/pdf", Filename: "doc.pdf", Data: large}}},
+		&Note{GUID: "2", Title: "Second"},
+	}
+	data := writeTestVault(t, notes...)
+	assert.False(bytes.Contains(data, []byte("First")), "Vault should be encrypted")
+
+	v, err := OpenVault(bytes.NewReader(data), "secret")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(VaultVersion, v.Manifest.Version)
+	assert.Equal("Work", v.Manifest.Settings.DefaultNotebook)
+	assert.Equal("Work", v.Manifest.Notebooks[0].Name)
+	for _, expected := range notes {
+		n, err := v.Next()
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(expected, n)
+	}
+	_, err = v.Next()
+	assert.Equal(io.EOF, err)
+}
+
+func TestVaultErrors(t *testing.T) {
+	data := writeTestVault(t, &Note{GUID: "1", Title: "First", Body: "<div>One</div>"})
+	t.Run("wrong passphrase", func(t *testing.T) {
+		_, err := OpenVault(bytes.NewReader(data), "wrong")
+		assert.Equal(t, ErrDecryptionFailed, err)
+	})
+	t.Run("not a vault", func(t *testing.T) {
+		_, err := OpenVault(bytes.NewReader([]byte("just some text in a file")), "secret")
+		assert.Equal(t, ErrNotAVault, err)
+	})
+	t.Run("newer version", func(t *testing.T) {
+		cpy := append([]byte{}, data...)
+		cpy[len(vaultMagic)] = VaultVersion + 1
+		_, err := OpenVault(bytes.NewReader(cpy), "secret")
+		assert.Equal(t, ErrUnsupportedVaultVersion, err)
+	})
+	t.Run("truncated", func(t *testing.T) {
+		v, err := OpenVault(bytes.NewReader(data[:len(data)-10]), "secret")
+		if err == nil {
+			_, err = v.Next()
+		}
+		assert.Equal(t, ErrVaultTruncated, err)
+	})
+	t.Run("tampered", func(t *testing.T) {
+		cpy := append([]byte{}, data...)
+		cpy[len(cpy)-1] ^= 1
+		v, err := OpenVault(bytes.NewReader(cpy), "secret")
+		if err == nil {
+			_, err = v.Next()
+		}
+		assert.Equal(t, ErrDecryptionFailed, err)
+	})
+	t.Run("empty passphrase", func(t *testing.T) {
+		_, err := NewVaultWriter(new(bytes.Buffer), "", new(VaultManifest))
+		assert.Equal(t, ErrEmptyPassphrase, err)
+	})
+}
+
+func TestCreateVault(t *testing.T) {
+	assert := assert.New(t)
+	books := []*Notebook{&Notebook{Name: "Work", GUID: "nb"}}
+	db := &mockStore{
+		getNotebookCache: func() (*NotebookCacheList, error) { return NewNotebookCacheList(books), nil },
+		getSettings: func() (*Settings, error) {
+			return &Settings{APIKey: "token", Credential: &Credential{Secret: "token"}, DefaultNotebook: "Work"}, nil
+		},
+	}
+	ns := &mockNS{
+		getNoteContent: func(guid string) (string, error) {
+			if guid == "2" {
+				return "", expectedError
+			}
+			return XMLHeader + "<en-note><div>Content</div></en-note>", nil
+		},
+		getResources: func(guid string) ([]*Resource, error) {
+			return []*Resource{&Resource{Mime: "image/png", Data: []byte("png")}}, nil
+		},
+	}
+	notes := []*Note{&Note{GUID: "1", Title: "First", Notebook: &Notebook{GUID: "nb"}}, &Note{GUID: "2", Title: "Second"}}
+	buf := new(bytes.Buffer)
+	var progress int
+
+	err := CreateVault(buf, db, ns, notes, "secret", func(done, total int) { progress = done })
+
+	if fetchErr, ok := err.(*FetchError); assert.True(ok, "Should return a FetchError") {
+		assert.Equal(expectedError, fetchErr.Errors["2"])
+	}
+	assert.Equal(2, progress)
+	v, err := OpenVault(buf, "secret")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("", v.Manifest.Settings.APIKey, "The token should not be included")
+	assert.Nil(v.Manifest.Settings.Credential, "The credential should not be included")
+	n, err := v.Next()
+	if assert.NoError(err) {
+		assert.Equal("First", n.Title)
+		assert.Equal("Work", n.Notebook.Name)
+		assert.Equal("<div>Content</div>", n.Body)
+		assert.Equal([]byte("png"), n.Resources[0].Data)
+	}
+	_, err = v.Next()
+	assert.Equal(io.EOF, err)
+}
+
+func TestExtractVault(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-vault")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	data := writeTestVault(t,
+		&Note{GUID: "1", Title: "a/b", MD: "Content", Notebook: &Notebook{Name: "Work"},
+			Resources: []*Resource{
+				&Resource{Hash: "abc", Mime: "image/png", Data: []byte("png")},
+				&Resource{Filename: "doc.pdf", Data: []byte("pdf")},
+				&Resource{Filename: "doc.pdf", Data: []byte("pdf2")},
+			}},
+		&Note{GUID: "2", Title: "a/b", MD: "Other", Notebook: &Notebook{GUID: "nb"}},
+	)
+	v, err := OpenVault(bytes.NewReader(data), "secret")
+	if !assert.NoError(err) {
+		return
+	}
+
+	count, err := ExtractVault(v, dir, DefaultNoteOption)
+
+	assert.NoError(err)
+	assert.Equal(2, count)
+	for file, content := range map[string]string{
+		"Work/a_b.md":        "Content",
+		"Work/a_b-2.md":      "Other",
+		"Work/a_b/abc.png":   "png",
+		"Work/a_b/doc.pdf":   "pdf",
+		"Work/a_b/3-doc.pdf": "pdf2",
+		vaultManifestName:    "Work",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if assert.NoError(err, file) {
+			assert.Contains(string(data), content, file)
+		}
+	}
+}
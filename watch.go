@@ -0,0 +1,165 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// DefaultWatchInterval is the default time between polls for changes.
+const DefaultWatchInterval = time.Minute
+
+// NoteChangeType is the type of change to a watched note.
+type NoteChangeType uint8
+
+const (
+	// NoteAdded is a note that started to match the search.
+	NoteAdded NoteChangeType = iota
+	// NoteUpdated is a note that has been updated.
+	NoteUpdated
+	// NoteRemoved is a note that no longer matches the search.
+	NoteRemoved
+)
+
+var noteChangeTypeStringMapper = []string{"added", "updated", "removed"}
+
+func (c NoteChangeType) String() string {
+	return noteChangeTypeStringMapper[c]
+}
+
+// NoteChange is a change to a watched note.
+type NoteChange struct {
+	// Type is the type of change.
+	Type NoteChangeType
+	// Note is the changed note.
+	Note *Note
+}
+
+// Watcher polls the server for changes to the notes matching a filter.
+// The server's sync state is used to detect when something has changed
+// so the search is only performed when the account has been updated.
+type Watcher struct {
+	// Filter is the search filter for the watched notes.
+	Filter *NoteFilter
+	// Count is the maximum number of notes that are watched.
+	Count int
+	// Interval is the time between polls.
+	Interval time.Duration
+
+	ns    NotestoreClient
+	usn   int32
+	notes map[string]*Note
+}
+
+// NewWatcher creates a new watcher for the notes matching the filter.
+func NewWatcher(ns NotestoreClient, filter *NoteFilter, count int) *Watcher {
+	return &Watcher{Filter: filter, Count: count, Interval: DefaultWatchInterval, ns: ns}
+}
+
+// Watch polls for changes until the done channel is closed. The handler is
+// called with the changes each time watched notes have changed. If the
+// handler or a poll returns an error, the watch is stopped.
+func (w *Watcher) Watch(done <-chan struct{}, handler func([]*NoteChange) error) error {
+	if _, err := w.Poll(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+			changes, err := w.Poll()
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			if err = handler(changes); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Poll checks the server for changes since the last poll. The first poll
+// records the current state and doesn't return any changes.
+func (w *Watcher) Poll() ([]*NoteChange, error) {
+	state, err := w.ns.GetSyncState()
+	if err != nil {
+		return nil, err
+	}
+	if w.notes != nil && state.UpdateCount == w.usn {
+		return nil, nil
+	}
+	notes, err := w.ns.FindNotes(w.Filter, 0, w.Count)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]*Note, len(notes))
+	for _, n := range notes {
+		current[n.GUID] = n
+	}
+	var changes []*NoteChange
+	if w.notes != nil {
+		for _, n := range notes {
+			old, ok := w.notes[n.GUID]
+			if !ok {
+				changes = append(changes, &NoteChange{Type: NoteAdded, Note: n})
+			} else if old.Updated != n.Updated {
+				changes = append(changes, &NoteChange{Type: NoteUpdated, Note: n})
+			}
+		}
+		for guid, n := range w.notes {
+			if _, ok := current[guid]; !ok {
+				changes = append(changes, &NoteChange{Type: NoteRemoved, Note: n})
+			}
+		}
+	}
+	w.usn = state.UpdateCount
+	w.notes = current
+	return changes, nil
+}
+
+// RunHook runs the shell command for the change. The change is passed
+// to the command in the environment variables CLINOTE_CHANGE,
+// CLINOTE_NOTE_TITLE and CLINOTE_NOTE_GUID.
+func RunHook(command string, change *NoteChange) error {
+	if SafeMode {
+		return ErrSafeMode
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"CLINOTE_CHANGE="+change.Type.String(),
+		"CLINOTE_NOTE_TITLE="+change.Note.Title,
+		"CLINOTE_NOTE_GUID="+change.Note.GUID,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
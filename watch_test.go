@@ -0,0 +1,79 @@
+/*
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, see <http://www.gnu.org/licenses/>.
+ *
+ * Copyright (C) Joakim Kennedy, 2018
+ */
+
+package clinote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	assert := assert.New(t)
+	usn := int32(1)
+	notes := []*Note{&Note{GUID: "1", Title: "One", Updated: 1}, &Note{GUID: "2", Title: "Two", Updated: 1}}
+	searches := 0
+	ns := &mockNS{
+		getSyncState: func() (*SyncState, error) { return &SyncState{UpdateCount: usn}, nil },
+		findNotes: func(*NoteFilter, int, int) ([]*Note, error) {
+			searches++
+			return notes, nil
+		},
+	}
+	w := NewWatcher(ns, &NoteFilter{Words: "search"}, 10)
+
+	changes, err := w.Poll()
+	assert.NoError(err)
+	assert.Empty(changes, "First poll should not return changes")
+
+	changes, err = w.Poll()
+	assert.NoError(err)
+	assert.Empty(changes)
+	assert.Equal(1, searches, "Should not search when the USN is unchanged")
+
+	usn = 2
+	notes = []*Note{&Note{GUID: "1", Title: "One", Updated: 2}, &Note{GUID: "3", Title: "Three", Updated: 1}}
+	changes, err = w.Poll()
+	assert.NoError(err)
+	assert.Equal([]*NoteChange{
+		&NoteChange{Type: NoteUpdated, Note: notes[0]},
+		&NoteChange{Type: NoteAdded, Note: notes[1]},
+		&NoteChange{Type: NoteRemoved, Note: &Note{GUID: "2", Title: "Two", Updated: 1}},
+	}, changes)
+}
+
+func TestRunHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "clinote-hook")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	err = RunHook(`echo "$CLINOTE_CHANGE $CLINOTE_NOTE_GUID $CLINOTE_NOTE_TITLE" > `+out, &NoteChange{Type: NoteUpdated, Note: &Note{GUID: "GUID", Title: "Title"}})
+	assert.NoError(err)
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(err)
+	assert.Equal("updated GUID Title\n", string(data))
+}
@@ -18,11 +18,12 @@
 package clinote
 
 import (
+	"fmt"
 	"io"
+	"os"
 	"strconv"
+	"strings"
 	"time"
-
-	"github.com/olekukonko/tablewriter"
 )
 
 const timeFormat = "2006-01-02"
@@ -30,14 +31,47 @@ const timeFormat = "2006-01-02"
 var (
 	noteListingHeader     = []string{"#", "Title", "Notebook", "Modified", "Created"}
 	notebookListingHeader = []string{"#", "Name"}
-	credentialHeader      = append(notebookListingHeader, "Type")
+	credentialHeader      = append(notebookListingHeader, "Type", "Active")
 	settingsHeader        = []string{"Setting", "Arguments", "Description"}
+	cacheStatsHeader      = []string{"Cache", "Entries", "Size", "Evictions"}
+	pendingChangeHeader   = []string{"ID", "Change", "Title", "Error"}
+	reminderHeader        = []string{"#", "Title", "Due", "Status"}
+	reminderShiftHeader   = []string{"Title", "From", "To", "Error"}
+	trackingReportHeader  = []string{"Project", "Entries", "Time"}
+	exportReportHeader    = []string{"Title", "File"}
+	captureHeader         = []string{"Captured", "Title", "Error"}
+	backupHeader          = []string{"#", "Created", "Format", "Size", "File"}
+	backupNoteHeader      = []string{"Title", "Notebook", "GUID"}
+	attachmentMatchHeader = []string{"Title", "#", "Attachment", "Recognized"}
+	attachmentHeader      = []string{"#", "Hash", "Type", "Size", "File"}
+	statsNotebookHeader   = []string{"Notebook", "Notes", "Words"}
+	statsTagHeader        = []string{"Tag", "Notes", "Words"}
+	statsNoteHeader       = []string{"Title", "Notebook", "Words", "Updated"}
+	journalEditHeader     = []string{"ID", "Title", "Started", "State"}
+	notebookRuleHeader    = []string{"Notebook", "Tags"}
+	aliasHeader           = []string{"Alias", "Command"}
+	todoHeader            = []string{"Title", "#", "Todo"}
+	hookHeader            = []string{"Event", "Script"}
+	pluginHeader          = []string{"Plugin", "Executable"}
+)
+
+const (
+	reminderTimeFormat = "2006-01-02 15:04"
+	colorReset         = "\033[0m"
 )
 
 // WriteNoteListing creates and writes a note listing table using the writer.
-func WriteNoteListing(w io.Writer, ns []*Note, nbs []*Notebook) {
-	table := tablewriter.NewWriter(w)
-	table.SetHeader(noteListingHeader)
+func WriteNoteListing(w io.Writer, ns []*Note, nbs []*Notebook, opts TableOption) {
+	WriteNoteListingWithLinks(w, ns, nbs, opts, nil)
+}
+
+// WriteNoteListingWithLinks creates and writes a note listing table using
+// the writer. If the Hyperlinks option is set, the titles are linked to the
+// notes in the web client of the credential's service.
+func WriteNoteListingWithLinks(w io.Writer, ns []*Note, nbs []*Notebook, opts TableOption, cred *Credential) {
+	table := NewTable(noteListingHeader, opts)
+	// Shrink the notebook name before the title.
+	table.SetShrinkOrder(2, 1)
 
 	for i, n := range ns {
 		index := strconv.Itoa(i + 1)
@@ -51,59 +85,444 @@ func WriteNoteListing(w io.Writer, ns []*Note, nbs []*Notebook) {
 			}
 		}
 		table.Append([]string{index, n.Title, notebook, modified, created})
+		if cred != nil && n.GUID != "" {
+			table.SetLink(i, 1, NoteWebURL(cred, n.GUID))
+		}
 	}
-	table.Render()
+	table.Render(w)
 }
 
 // WriteNotebookListing creates and writes a notebook listing table using the writer.
-func WriteNotebookListing(w io.Writer, nbs []*Notebook) {
-	table := tablewriter.NewWriter(w)
-	table.SetHeader(notebookListingHeader)
+func WriteNotebookListing(w io.Writer, nbs []*Notebook, opts TableOption) {
+	table := NewTable(notebookListingHeader, opts)
+	table.SetShrinkOrder(1)
 	for i, nb := range nbs {
 		index := strconv.Itoa(i + 1)
 		table.Append([]string{index, nb.Name})
 	}
-	table.Render()
+	table.Render(w)
 }
 
 // WriteCredentialListing creates and writes a credential listing table using the writer.
-func WriteCredentialListing(w io.Writer, creds []*Credential) {
-	writeCredentialList(w, creds, false)
+func WriteCredentialListing(w io.Writer, creds []*Credential, opts TableOption) {
+	writeCredentialList(w, creds, false, opts)
 }
 
 // WriteCredentialListingWithSecret creates and writes a credential listing table using the writer.
-func WriteCredentialListingWithSecret(w io.Writer, creds []*Credential) {
-	writeCredentialList(w, creds, true)
+func WriteCredentialListingWithSecret(w io.Writer, creds []*Credential, opts TableOption) {
+	writeCredentialList(w, creds, true, opts)
+}
+
+func writeCredentialList(w io.Writer, creds []*Credential, includeToken bool, opts TableOption) {
+	credentialTable(creds, includeToken, opts).Render(w)
 }
 
-func writeCredentialList(w io.Writer, creds []*Credential, includeToken bool) {
-	table := tablewriter.NewWriter(w)
-	header := credentialHeader
+// credentialTable returns the credential listing table.
+func credentialTable(creds []*Credential, includeToken bool, opts TableOption) *Table {
+	header := append([]string{}, credentialHeader...)
 	if includeToken {
 		header = append(header, "Secret")
 	}
-	table.SetHeader(header)
+	table := NewTable(header, opts)
+	if includeToken {
+		// Shrink the secret before the name.
+		table.SetShrinkOrder(4, 1)
+	} else {
+		table.SetShrinkOrder(1)
+	}
 
 	for i, cred := range creds {
 		index := strconv.Itoa(i + 1)
-		line := []string{index, cred.Name, cred.CredType.String()}
+		active := ""
+		if cred.Active {
+			active = "yes"
+		}
+		line := []string{index, cred.DisplayName(), cred.ServiceName(), active}
 		if includeToken {
 			line = append(line, cred.Secret)
 		}
 		table.Append(line)
 	}
-	table.Render()
+	return table
 }
 
 // WriteSettingsListing writes the settings table to writer.
-func WriteSettingsListing(w io.Writer, vals, args, desc []string) {
+func WriteSettingsListing(w io.Writer, vals, args, desc []string, opts TableOption) {
 	if len(vals) != len(args) || len(vals) != len(desc) {
 		return
 	}
-	table := tablewriter.NewWriter(w)
-	table.SetHeader(settingsHeader)
+	table := NewTable(settingsHeader, opts)
+	table.SetShrinkOrder(2, 1)
 	for i, val := range vals {
 		table.Append([]string{val, args[i], desc[i]})
 	}
-	table.Render()
+	table.Render(w)
+}
+
+// WriteCacheStats writes the cache statistics table to the writer.
+func WriteCacheStats(w io.Writer, stats *CacheStats, opts TableOption) {
+	table := NewTable(cacheStatsHeader, opts)
+	for _, cache := range Caches {
+		c, ok := stats.Caches[cache]
+		if !ok {
+			continue
+		}
+		table.Append([]string{cache, strconv.Itoa(c.Entries), FormatSize(c.Size), strconv.FormatInt(c.Evictions, 10)})
+	}
+	limit := "none"
+	if stats.MaxSize > 0 {
+		limit = FormatSize(stats.MaxSize)
+	}
+	table.SetFooter([]string{"", "Total", FormatSize(stats.Size()), "Limit: " + limit})
+	table.Render(w)
+}
+
+// WriteTrackingReport writes the time tracking summaries to the writer.
+func WriteTrackingReport(w io.Writer, summaries []*TrackingSummary, opts TableOption) {
+	table := NewTable(trackingReportHeader, opts)
+	var entries int
+	var total time.Duration
+	for _, s := range summaries {
+		project := s.Project
+		if s.Running {
+			project += " (running)"
+		}
+		table.Append([]string{project, strconv.Itoa(s.Entries), FormatTrackedTime(s.Duration)})
+		entries += s.Entries
+		total += s.Duration
+	}
+	table.SetFooter([]string{"Total", strconv.Itoa(entries), FormatTrackedTime(total)})
+	table.Render(w)
+}
+
+// FormatTrackedTime formats the duration as hours and minutes, for example 12h05m.
+func FormatTrackedTime(d time.Duration) string {
+	m := int64(d.Round(time.Minute) / time.Minute)
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
+
+// WriteExportReport writes the exported files to the writer. If the
+// Hyperlinks option is set, the paths are linked with file:// URLs.
+func WriteExportReport(w io.Writer, files []*ExportedFile, opts TableOption) {
+	table := NewTable(exportReportHeader, opts)
+	table.SetShrinkOrder(0, 1)
+	for i, f := range files {
+		table.Append([]string{f.Note.Title, f.Path})
+		table.SetLink(i, 1, FileURL(f.Path))
+	}
+	table.Render(w)
+}
+
+// WriteNotebookRules writes the default tags of the notebooks to the writer.
+func WriteNotebookRules(w io.Writer, s *Settings, opts TableOption) {
+	table := NewTable(notebookRuleHeader, opts)
+	table.SetShrinkOrder(1, 0)
+	for _, name := range s.NotebookRules() {
+		table.Append([]string{name, strings.Join(s.NotebookDefaults[name].Tags, ", ")})
+	}
+	table.Render(w)
+}
+
+// WriteAliases writes the user's command aliases to the writer.
+func WriteAliases(w io.Writer, s *Settings, opts TableOption) {
+	table := NewTable(aliasHeader, opts)
+	table.SetShrinkOrder(1, 0)
+	for _, name := range s.AliasNames() {
+		table.Append([]string{name, s.Aliases[name]})
+	}
+	table.Render(w)
+}
+
+// WriteHookListing writes the hook events and their scripts as a table. The
+// hooks are mapped from the event to the script, events without a script
+// are listed with "none".
+func WriteHookListing(w io.Writer, hooks map[string]string, opts TableOption) {
+	table := NewTable(hookHeader, opts)
+	table.SetShrinkOrder(1)
+	for _, event := range HookEvents {
+		path := hooks[event]
+		if path == "" {
+			path = "none"
+		}
+		table.Append([]string{event, path})
+	}
+	table.Render(w)
+}
+
+// WritePluginListing writes the plugins, mapped from the command name to
+// the executable, as a table sorted by name.
+func WritePluginListing(w io.Writer, plugins map[string]string, opts TableOption) {
+	table := NewTable(pluginHeader, opts)
+	table.SetShrinkOrder(1)
+	for _, name := range PluginNames(plugins) {
+		table.Append([]string{name, plugins[name]})
+	}
+	table.Render(w)
+}
+
+// WriteTodoListing writes the todos as a table. The number is the todo's
+// position in the note.
+func WriteTodoListing(w io.Writer, todos []*Todo, opts TableOption) {
+	table := NewTable(todoHeader, opts)
+	table.SetShrinkOrder(2, 0)
+	for _, t := range todos {
+		table.Append([]string{t.Note.Title, strconv.Itoa(t.Number), t.Text})
+	}
+	table.Render(w)
+}
+
+// WritePendingChangeListing writes the queued changes table to the writer.
+func WritePendingChangeListing(w io.Writer, changes []*PendingChange, opts TableOption) {
+	table := NewTable(pendingChangeHeader, opts)
+	// Shrink the error message before the title.
+	table.SetShrinkOrder(3, 2)
+	for _, c := range changes {
+		id := c.ID
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		table.Append([]string{id, c.Type.String(), c.Note.Title, c.Error})
+	}
+	table.Render(w)
+}
+
+// WriteReminderListing writes the reminder table to the writer. Overdue
+// reminders are highlighted by the output theme if the writer is a terminal.
+func WriteReminderListing(w io.Writer, notes []*Note, now time.Time, opts TableOption) {
+	WriteReminderListingWithLinks(w, notes, now, opts, nil)
+}
+
+// WriteReminderListingWithLinks writes the reminder table to the writer. If
+// the Hyperlinks option is set, the titles are linked to the notes in the
+// web client of the credential's service.
+func WriteReminderListingWithLinks(w io.Writer, notes []*Note, now time.Time, opts TableOption, cred *Credential) {
+	table := NewTable(reminderHeader, opts)
+	table.SetShrinkOrder(1)
+	theme := colorTheme(w, opts)
+	for i, n := range notes {
+		due := ""
+		if n.Reminder.Time != 0 {
+			due = n.Reminder.Due().Format(reminderTimeFormat)
+		}
+		state := n.Reminder.State(now)
+		status := state.String()
+		if state == ReminderOverdue {
+			due = theme.Overdue.Paint(due)
+			status = theme.Overdue.Paint(status)
+		}
+		table.Append([]string{strconv.Itoa(i + 1), n.Title, due, status})
+		if cred != nil && n.GUID != "" {
+			table.SetLink(i, 1, NoteWebURL(cred, n.GUID))
+		}
+	}
+	table.Render(w)
+}
+
+// WriteReminderShiftListing writes the reminder shift table to the writer.
+func WriteReminderShiftListing(w io.Writer, shifts []*ReminderShift, opts TableOption) {
+	table := NewTable(reminderShiftHeader, opts)
+	// Shrink the error message before the title.
+	table.SetShrinkOrder(3, 0)
+	for _, s := range shifts {
+		errMsg := ""
+		if s.Err != nil {
+			errMsg = s.Err.Error()
+		}
+		table.Append([]string{s.Note.Title, s.From.Format(reminderTimeFormat), s.To.Format(reminderTimeFormat), errMsg})
+	}
+	table.Render(w)
+}
+
+// useColor returns true if the writer is a terminal and the NO_COLOR
+// environment variable isn't set.
+func useColor(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && os.Getenv("NO_COLOR") == "" && terminalWidth(f) > 0
+}
+
+// WriteNoteMeta writes the note's metadata to the writer.
+func WriteNoteMeta(w io.Writer, n *Note, opts TableOption) error {
+	fields := [][2]string{{"GUID", n.GUID}}
+	if n.Notebook != nil && n.Notebook.Name != "" {
+		fields = append(fields, [2]string{"Notebook", n.Notebook.Name})
+	}
+	fields = append(fields,
+		[2]string{"Created", time.Unix(n.Created/1000, 0).Format(time.RFC3339)},
+		[2]string{"Updated", time.Unix(n.Updated/1000, 0).Format(time.RFC3339)},
+	)
+	if len(n.Tags) != 0 {
+		fields = append(fields, [2]string{"Tags", strings.Join(n.Tags, ", ")})
+	}
+	if n.Author != "" {
+		fields = append(fields, [2]string{"Author", n.Author})
+	}
+	if n.Source != "" {
+		fields = append(fields, [2]string{"Source", n.Source})
+	}
+	if n.SourceURL != "" {
+		fields = append(fields, [2]string{"URL", n.SourceURL})
+	}
+	if n.Location != nil {
+		fields = append(fields, [2]string{"Location", n.Location.String()})
+	}
+	return WriteFields(w, fields, opts)
+}
+
+// WriteRecognizedText writes the keywords recognized in each resource, like
+// the text in images. Resources without recognized text are skipped.
+func WriteRecognizedText(w io.Writer, resources []*Resource, opts TableOption) error {
+	var fields [][2]string
+	for i, r := range resources {
+		if keywords := r.Keywords(); len(keywords) != 0 {
+			label := fmt.Sprintf("Attachment %d", i+1)
+			if r.Filename != "" {
+				label += " (" + r.Filename + ")"
+			}
+			fields = append(fields, [2]string{label, strings.Join(keywords, ", ")})
+		}
+	}
+	return WriteFields(w, fields, opts)
+}
+
+// WriteFields writes the label and value pairs with one pair per line. The
+// values are aligned unless the Accessible option is set.
+func WriteFields(w io.Writer, fields [][2]string, opts TableOption) error {
+	width := 0
+	if opts&Accessible == 0 {
+		for _, f := range fields {
+			if len(f[0]) > width {
+				width = len(f[0])
+			}
+		}
+	}
+	for _, f := range fields {
+		if _, err := fmt.Fprintf(w, "%-*s %s\n", width+1, f[0]+":", f[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCaptureListing writes the captures in the outbox as a table.
+func WriteCaptureListing(w io.Writer, captures []*Capture, opts TableOption) {
+	table := NewTable(captureHeader, opts)
+	table.SetShrinkOrder(2, 1)
+	for _, c := range captures {
+		table.Append([]string{c.Created.Local().Format(reminderTimeFormat), CaptureTitle(c), c.Error})
+	}
+	table.Render(w)
+}
+
+// WriteAttachmentListing writes the note's attachments as a table. The
+// number is the attachment's position in the note.
+func WriteAttachmentListing(w io.Writer, resources []*Resource, opts TableOption) {
+	table := NewTable(attachmentHeader, opts)
+	table.SetShrinkOrder(4, 1)
+	for i, r := range resources {
+		table.Append([]string{strconv.Itoa(i + 1), r.Hash, r.Mime, FormatSize(int64(r.Size)), r.Filename})
+	}
+	table.Render(w)
+}
+
+// WriteAttachmentMatches writes the attachments found by their recognized
+// text as a table.
+func WriteAttachmentMatches(w io.Writer, matches []*AttachmentMatch, opts TableOption) {
+	table := NewTable(attachmentMatchHeader, opts)
+	table.SetShrinkOrder(3, 0)
+	for _, m := range matches {
+		table.Append([]string{m.Note.Title, strconv.Itoa(m.Index), m.Resource.Filename, strings.Join(m.Matches, ", ")})
+	}
+	table.Render(w)
+}
+
+// WriteNoteStats writes the note statistics: the totals followed by tables
+// of the notebooks, the tags, the largest notes and the stale notes.
+func WriteNoteStats(w io.Writer, s *NoteStats, opts TableOption) {
+	WriteFields(w, [][2]string{
+		{"Notes", strconv.Itoa(s.Notes)},
+		{"Words", strconv.Itoa(s.Words)},
+		{"Characters", strconv.Itoa(s.Characters)},
+		{"Stale", fmt.Sprintf("%d notes not updated in %d days", s.StaleNotes, s.StaleDays)},
+	}, opts)
+	if len(s.Notebooks) != 0 {
+		fmt.Fprintln(w)
+		writeGroupStats(w, statsNotebookHeader, s.Notebooks, opts)
+	}
+	if len(s.Tags) != 0 {
+		fmt.Fprintln(w)
+		writeGroupStats(w, statsTagHeader, s.Tags, opts)
+	}
+	if len(s.Largest) != 0 {
+		fmt.Fprintln(w, "\nLargest notes:")
+		writeNoteStats(w, s.Largest, opts)
+	}
+	if len(s.Stale) != 0 {
+		fmt.Fprintln(w, "\nLeast recently updated notes:")
+		writeNoteStats(w, s.Stale, opts)
+	}
+}
+
+func writeGroupStats(w io.Writer, header []string, groups []*GroupStat, opts TableOption) {
+	table := NewTable(header, opts)
+	table.SetShrinkOrder(0)
+	for _, g := range groups {
+		table.Append([]string{g.Name, strconv.Itoa(g.Notes), strconv.Itoa(g.Words)})
+	}
+	table.Render(w)
+}
+
+func writeNoteStats(w io.Writer, stats []*NoteStat, opts TableOption) {
+	table := NewTable(statsNoteHeader, opts)
+	table.SetShrinkOrder(1, 0)
+	for _, s := range stats {
+		updated := time.Unix(s.Note.Updated/1000, 0).Format(timeFormat)
+		table.Append([]string{s.Note.Title, s.Notebook, strconv.Itoa(s.Words), updated})
+	}
+	table.Render(w)
+}
+
+// WriteBackupListing writes the backups as a table.
+func WriteBackupListing(w io.Writer, backups []*BackupFile, opts TableOption) {
+	table := NewTable(backupHeader, opts)
+	table.SetShrinkOrder(4)
+	for i, b := range backups {
+		table.Append([]string{strconv.Itoa(i + 1), b.Created.Local().Format(reminderTimeFormat), b.Format.Name, FormatSize(b.Size), b.Path})
+	}
+	table.Render(w)
+}
+
+// WriteBackupNoteListing writes the notes in a backup as a table.
+func WriteBackupNoteListing(w io.Writer, notes []*Note, opts TableOption) {
+	table := NewTable(backupNoteHeader, opts)
+	table.SetShrinkOrder(0, 1)
+	for _, n := range notes {
+		var book string
+		if n.Notebook != nil {
+			book = n.Notebook.Name
+		}
+		table.Append([]string{n.Title, book, n.GUID})
+	}
+	table.Render(w)
+}
+
+// WriteEditListing writes the interrupted edits in the journal as a table.
+func WriteEditListing(w io.Writer, edits []*JournalEdit, opts TableOption) {
+	table := NewTable(journalEditHeader, opts)
+	table.SetShrinkOrder(1)
+	for _, e := range edits {
+		id := e.ID
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		state := "not uploaded"
+		if e.Content == "" {
+			state = "in editor"
+		}
+		title := e.Note.Title
+		if e.Note.GUID == "" {
+			title += " (new)"
+		}
+		table.Append([]string{id, title, e.Started.Local().Format(reminderTimeFormat), state})
+	}
+	table.Render(w)
 }
@@ -39,13 +39,13 @@ func TestWritingNoteAndNotebookTables(t *testing.T) {
 
 	t.Run("NotebookList", func(t *testing.T) {
 		buf := new(bytes.Buffer)
-		WriteNotebookListing(buf, nbs)
+		WriteNotebookListing(buf, nbs, DefaultTableOption)
 		assert.Equal(expectedNotebooklist, string(buf.Bytes()), "Notebook list table doesn't match")
 	})
 
 	t.Run("NoteList", func(t *testing.T) {
 		buf := new(bytes.Buffer)
-		WriteNoteListing(buf, notes, nbs)
+		WriteNoteListing(buf, notes, nbs, DefaultTableOption)
 		assert.Equal(expectedNotelist, string(buf.Bytes()), "Note list table doesn't match")
 	})
 }
@@ -54,30 +54,94 @@ func TestCredentialTable(t *testing.T) {
 	assert := assert.New(t)
 	creds := []*Credential{
 		&Credential{Name: "Cred1", Secret: "test12", CredType: EvernoteCredential},
-		&Credential{Name: "Cred2", Secret: "test23", CredType: EvernoteSandboxCredential},
+		&Credential{Name: "Cred2", Secret: "test23", CredType: EvernoteSandboxCredential, Label: "Testing", Active: true},
 	}
 	t.Run("print without secret", func(t *testing.T) {
 		buf := new(bytes.Buffer)
-		WriteCredentialListing(buf, creds)
+		WriteCredentialListing(buf, creds, DefaultTableOption)
 		assert.Equal(expectedCredentialList, string(buf.Bytes()))
 	})
 
 	t.Run("print with secret", func(t *testing.T) {
 		buf := new(bytes.Buffer)
-		WriteCredentialListingWithSecret(buf, creds)
+		WriteCredentialListingWithSecret(buf, creds, DefaultTableOption)
 		assert.Equal(expectedCredentialListWithSecret, string(buf.Bytes()))
 	})
+
+	t.Run("narrow terminal", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		credentialTable(creds, false, DefaultTableOption).RenderWithWidth(buf, 20)
+		assert.Equal(expectedNarrowCredentialList, buf.String())
+	})
+
+	t.Run("narrow terminal with secret", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		credentialTable(creds, true, DefaultTableOption).RenderWithWidth(buf, 20)
+		assert.Equal(expectedNarrowCredentialListWithSecret, buf.String())
+	})
 }
 
 func TestSettingsTable(t *testing.T) {
 	assert := assert.New(t)
 	buf := new(bytes.Buffer)
 
-	WriteSettingsListing(buf, []string{"credential"}, []string{"An index value."}, []string{"Set the active credential for the user."})
+	WriteSettingsListing(buf, []string{"credential"}, []string{"An index value."}, []string{"Set the active credential for the user."}, DefaultTableOption)
 
 	assert.Equal(expectedSettingList, string(buf.Bytes()))
 }
 
+func TestCacheStatsTable(t *testing.T) {
+	assert := assert.New(t)
+	buf := new(bytes.Buffer)
+	stats := &CacheStats{MaxSize: 1 << 20, Caches: map[string]*CacheBucketStats{
+		ContentCache:  &CacheBucketStats{Entries: 2, Size: 2048, Evictions: 1},
+		ResourceCache: &CacheBucketStats{},
+	}}
+
+	WriteCacheStats(buf, stats, DefaultTableOption)
+
+	assert.Equal(expectedCacheStats, buf.String())
+}
+
+func TestPendingChangeTable(t *testing.T) {
+	assert := assert.New(t)
+	buf := new(bytes.Buffer)
+	changes := []*PendingChange{&PendingChange{ID: "0123456789", Type: ChangeEdit, Note: &Note{Title: "Note"}, Error: "failed"}}
+
+	WritePendingChangeListing(buf, changes, DefaultTableOption)
+
+	assert.Equal(expectedPendingChanges, buf.String())
+}
+
+func TestAttachmentTable(t *testing.T) {
+	assert := assert.New(t)
+	buf := new(bytes.Buffer)
+	resources := []*Resource{&Resource{Hash: "5d41402abc4b2a76b9719d911017c592", Mime: "application/pdf", Size: 2048, Filename: "report.pdf"}}
+
+	WriteAttachmentListing(buf, resources, DefaultTableOption)
+
+	assert.Equal(expectedAttachments, buf.String())
+}
+
+func TestHookTable(t *testing.T) {
+	assert := assert.New(t)
+	buf := new(bytes.Buffer)
+
+	WriteHookListing(buf, map[string]string{HookPreNoteEdit: "/hooks/pre-note-edit"}, DefaultTableOption)
+
+	assert.Contains(buf.String(), "| pre-note-edit    | /hooks/pre-note-edit |")
+	assert.Contains(buf.String(), "| post-note-delete | none                 |")
+}
+
+func TestPluginTable(t *testing.T) {
+	assert := assert.New(t)
+	buf := new(bytes.Buffer)
+
+	WritePluginListing(buf, map[string]string{"wc": "/bin/clinote-wc", "sync-dir": "/usr/bin/clinote-sync-dir"}, DefaultTableOption)
+
+	assert.Equal(expectedPlugins, buf.String())
+}
+
 const expectedNotebooklist = `+---+-----------+
 | # |   NAME    |
 +---+-----------+
@@ -94,26 +158,73 @@ const expectedNotelist = `+---+-------+-----------+------------+------------+
 | 3 | Note3 | Notebook3 | 1970-01-01 | 1970-01-01 |
 +---+-------+-----------+------------+------------+
 `
-const expectedCredentialList = `+---+-------+------------------+
-| # | NAME  |       TYPE       |
-+---+-------+------------------+
-| 1 | Cred1 | Evernote         |
-| 2 | Cred2 | Evernote Sandbox |
-+---+-------+------------------+
+const expectedCredentialList = `+---+---------+------------------+--------+
+| # |  NAME   |       TYPE       | ACTIVE |
++---+---------+------------------+--------+
+| 1 | Cred1   | Evernote         |        |
+| 2 | Testing | Evernote Sandbox | yes    |
++---+---------+------------------+--------+
+`
+
+const expectedCredentialListWithSecret = `+---+---------+------------------+--------+--------+
+| # |  NAME   |       TYPE       | ACTIVE | SECRET |
++---+---------+------------------+--------+--------+
+| 1 | Cred1   | Evernote         |        | test12 |
+| 2 | Testing | Evernote Sandbox | yes    | test23 |
++---+---------+------------------+--------+--------+
+`
+
+const expectedNarrowCredentialList = `+---+------+------------------+--------+
+| # | NAME |       TYPE       | ACTIVE |
++---+------+------------------+--------+
+| 1 | Cre… | Evernote         |        |
+| 2 | Tes… | Evernote Sandbox | yes    |
++---+------+------------------+--------+
+`
+
+const expectedNarrowCredentialListWithSecret = `+---+------+------------------+--------+--------+
+| # | NAME |       TYPE       | ACTIVE | SECRET |
++---+------+------------------+--------+--------+
+| 1 | Cre… | Evernote         |        | test12 |
+| 2 | Tes… | Evernote Sandbox | yes    | test23 |
++---+------+------------------+--------+--------+
+`
+
+const expectedSettingList = `+------------+-----------------+-----------------------------------------+
+|  SETTING   |    ARGUMENTS    |               DESCRIPTION               |
++------------+-----------------+-----------------------------------------+
+| credential | An index value. | Set the active credential for the user. |
++------------+-----------------+-----------------------------------------+
+`
+
+const expectedCacheStats = `+----------+---------+-------+--------------+
+|  CACHE   | ENTRIES | SIZE  |  EVICTIONS   |
++----------+---------+-------+--------------+
+| content  |       2 | 2.0KB |            1 |
+| resource |       0 | 0B    |            0 |
++----------+---------+-------+--------------+
+|             TOTAL  | 2.0KB | LIMIT: 1.0MB |
++----------+---------+-------+--------------+
+`
+
+const expectedPendingChanges = `+----------+--------+-------+--------+
+|    ID    | CHANGE | TITLE | ERROR  |
++----------+--------+-------+--------+
+| 01234567 | edit   | Note  | failed |
++----------+--------+-------+--------+
 `
 
-const expectedCredentialListWithSecret = `+---+-------+------------------+--------+
-| # | NAME  |       TYPE       | SECRET |
-+---+-------+------------------+--------+
-| 1 | Cred1 | Evernote         | test12 |
-| 2 | Cred2 | Evernote Sandbox | test23 |
-+---+-------+------------------+--------+
+const expectedAttachments = `+---+----------------------------------+-----------------+-------+------------+
+| # |               HASH               |      TYPE       | SIZE  |    FILE    |
++---+----------------------------------+-----------------+-------+------------+
+| 1 | 5d41402abc4b2a76b9719d911017c592 | application/pdf | 2.0KB | report.pdf |
++---+----------------------------------+-----------------+-------+------------+
 `
 
-const expectedSettingList = `+------------+-----------------+--------------------------------+
-|  SETTING   |    ARGUMENTS    |          DESCRIPTION           |
-+------------+-----------------+--------------------------------+
-| credential | An index value. | Set the active credential for  |
-|            |                 | the user.                      |
-+------------+-----------------+--------------------------------+
+const expectedPlugins = `+----------+---------------------------+
+|  PLUGIN  |        EXECUTABLE         |
++----------+---------------------------+
+| sync-dir | /usr/bin/clinote-sync-dir |
+| wc       | /bin/clinote-wc           |
++----------+---------------------------+
 `